@@ -0,0 +1,57 @@
+-- finger.lua - Global command: look up another user's profile
+-- Type /finger <user> at any menu, or /finger to pick from the user list.
+local function size_str(bytes)
+    if bytes >= 1048576 then
+        return string.format("%.1f MB", bytes / 1048576)
+    end
+    return string.format("%d KB", math.floor(bytes / 1024))
+end
+
+return {
+    description = "Show a user's profile",
+    run = function(node, args)
+        local name = args
+        if name == nil or name == "" then
+            local u = users.pick("Finger which user?")
+            if u == nil then
+                return
+            end
+            name = u.name
+        end
+
+        local p, err = users.profile(name)
+        node:sendln("")
+        if p == nil then
+            node:sendln("  " .. err .. ": " .. name)
+            node:pause()
+            return
+        end
+
+        if p.avatar ~= nil then
+            node:display("avatars/" .. p.avatar)
+        end
+        node:sendln("  User:         " .. p.name)
+        if p.real_name ~= nil and p.real_name ~= "" then
+            node:sendln("  Real name:    " .. p.real_name)
+        end
+        if p.location ~= nil and p.location ~= "" then
+            node:sendln("  Location:     " .. p.location)
+        end
+        node:sendln("  Member since: " .. p.member_since)
+        if p.last_seen ~= nil then
+            node:sendln("  Last seen:    " .. p.last_seen)
+        end
+        if p.calls ~= nil then
+            node:sendln(string.format("  Calls: %d   Posts: %d   Up/down: %s / %s",
+                p.calls, p.posts, size_str(p.uploaded), size_str(p.downloaded)))
+        end
+        if p.bio ~= "" then
+            node:sendln("")
+            for line in (p.bio .. "\n"):gmatch("(.-)\n") do
+                node:sendln("  " .. line)
+            end
+        end
+        node:sendln("")
+        node:pause()
+    end,
+}
@@ -0,0 +1,36 @@
+-- join.lua - Global command: join a live event such as a trivia night
+-- Type /join at any menu to pick from the running events, or /join trivia.
+return {
+    description = "Join a live event",
+    run = function(node, args)
+        local events = live.events()
+        node:sendln("")
+        if #events == 0 then
+            node:sendln("  No live events are running.")
+            node:pause()
+            return
+        end
+
+        local name = args
+        if name == nil or name == "" then
+            node:sendln("  -- Live Events --")
+            node:sendln("")
+            for i, ev in ipairs(events) do
+                node:sendln(string.format("  %d) %-30s hosted by %s, %d playing",
+                    i, ev.title, ev.host, ev.players))
+            end
+            node:sendln("")
+            local pick = tonumber(node:ask("  Join which event (Enter to cancel)? ", 3))
+            if pick == nil or events[pick] == nil then
+                return
+            end
+            name = events[pick].name
+        end
+
+        local err = live.join(name)
+        if err ~= nil then
+            node:sendln("  " .. err)
+        end
+        node:pause()
+    end,
+}
@@ -0,0 +1,85 @@
+-- page.lua - Global command: page a user for a private chat, or answer a page
+-- Type /page to answer a page or pick who to page, /page <node> to page a
+-- node, or /page dnd to turn do not disturb on or off.
+local function answer(node, p)
+    local yn = node:ask(string.format("  %s on node %d wants to chat privately. Chat now (Y/n)? ",
+        p.from, p.node), 1)
+    if yn == nil then
+        return
+    end
+    local room, err = chat.answer(yn ~= "n" and yn ~= "N")
+    if err ~= nil then
+        node:sendln("  " .. err)
+        node:pause()
+    elseif room ~= nil then
+        node:enter_chat(room)
+    end
+end
+
+local function page(node, id)
+    node:sendln(string.format("  Paging node %d, press any key to give up...", id))
+    local room, err = chat.page(id)
+    if room == nil then
+        node:sendln("  No chat: " .. err)
+        node:pause()
+        return
+    end
+    node:enter_chat(room)
+end
+
+local function toggle_dnd(node)
+    local on = false
+    for _, u in ipairs(chat.online()) do
+        if u.you then
+            on = not u.dnd
+        end
+    end
+    local err = chat.set_dnd(on)
+    if err ~= nil then
+        node:sendln("  " .. err)
+    elseif on then
+        node:sendln("  Do not disturb is on; nobody can page you.")
+    else
+        node:sendln("  Do not disturb is off.")
+    end
+    node:pause()
+end
+
+return {
+    description = "Page a user for a private chat",
+    run = function(node, args)
+        node:sendln("")
+        if args == "dnd" then
+            toggle_dnd(node)
+            return
+        end
+        local id = tonumber(args)
+        if id ~= nil then
+            page(node, id)
+            return
+        end
+
+        local p = chat.paged()
+        if p ~= nil then
+            answer(node, p)
+            return
+        end
+
+        local items, ids = {}, {}
+        for _, u in ipairs(chat.online()) do
+            if not u.you and not u.dnd and u.name ~= "(logging in)" then
+                table.insert(items, { label = u.name, detail = "node " .. u.node_id })
+                table.insert(ids, u.node_id)
+            end
+        end
+        if #items == 0 then
+            node:sendln("  Nobody else can be paged right now.")
+            node:pause()
+            return
+        end
+        local pick = node:pick("Page whom for a private chat?", items)
+        if pick ~= nil then
+            page(node, ids[pick])
+        end
+    end,
+}
@@ -0,0 +1,11 @@
+-- time.lua - Global command: show the date and time on the board
+-- Type /time at any menu, or press Ctrl-T.
+return {
+    key = "^T",
+    description = "Show the date and time",
+    run = function(node, args)
+        node:sendln("")
+        node:sendln("  It is " .. os.date("%A, %B %d %Y, %H:%M") .. " here.")
+        node:pause()
+    end,
+}
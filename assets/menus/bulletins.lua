@@ -0,0 +1,80 @@
+-- bulletins.lua - Sysop bulletins
+-- After login this shows each bulletin the caller has not seen yet, then
+-- says how many files are new since the caller's last file scan and
+-- continues to the tour on a first call, otherwise the main menu. Called with gosub_menu("bulletins",
+-- {browse = true}) it lets the caller pick from all current bulletins.
+local menu = {}
+
+local function show(node, b)
+    node:cls()
+    if b.art ~= "" then
+        node:display(b.art)
+        node:sendln("")
+        node:pause()
+        return
+    end
+    node:view_text(b.body, { title = b.title .. "  (" .. b.date .. ")" })
+end
+
+local function browse(node)
+    local list = bulletins.list()
+    if list == nil or #list == 0 then
+        node:sendln("")
+        node:sendln("  No bulletins posted.")
+        node:pause(2)
+        return
+    end
+
+    local items = {}
+    for i, b in ipairs(list) do
+        items[i] = { label = b.title, detail = b.date }
+    end
+    while true do
+        local idx = node:pick("Bulletins", items)
+        if idx == nil then
+            return
+        end
+        show(node, list[idx])
+        bulletins.mark_seen(list[idx].id)
+    end
+end
+
+-- new_files reports the files uploaded since the caller last scanned.
+local function new_files(node)
+    if files == nil then
+        return
+    end
+    local _, count, areas = files.new_since_last_call(0)
+    if count == nil or count == 0 then
+        return
+    end
+    node:sendln("")
+    node:sendln(string.format("  %d new file%s in %d area%s since your last scan.",
+        count, count == 1 and "" or "s", areas, areas == 1 and "" or "s"))
+    node:sendln("  Press N in the file menu to see them.")
+    node:pause()
+end
+
+function menu.on_enter(node)
+    local args = node:args()
+    if type(args) == "table" and args.browse then
+        browse(node)
+        node:return_menu()
+        return
+    end
+
+    local unseen = bulletins.unseen()
+    for _, b in ipairs(unseen or {}) do
+        show(node, b)
+        bulletins.mark_seen(b.id)
+    end
+    new_files(node)
+    node:cls()
+    if tour ~= nil and tour.due() then
+        node:goto_menu("tour")
+        return
+    end
+    node:goto_menu("main_menu")
+end
+
+return menu
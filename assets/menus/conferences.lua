@@ -0,0 +1,67 @@
+-- conferences.lua - Join, switch and leave conferences. Message and file
+-- area lists, new scans and file searches cover the current conference.
+local menu = {}
+
+local function show(node, list)
+    node:sendln("")
+    node:sendln("  -- Conferences --")
+    node:sendln("")
+    node:sendln("       #  Name                      Msg  File  Description")
+    for _, c in ipairs(list) do
+        local mark = " "
+        if c.current then
+            mark = "*"
+        elseif c.joined then
+            mark = "+"
+        end
+        node:sendln(string.format("    %s %3d  %-24s %4d %5d  %s", mark, c.id,
+            c.name, c.message_areas, c.file_areas, c.description))
+    end
+    node:sendln("")
+    node:sendln("  * current  + joined")
+end
+
+function menu.on_enter(node)
+    node:cls()
+    local list, err = conf.list()
+    if list == nil then
+        node:sendln("  " .. (err or "No conferences") .. ".")
+        node:pause()
+        node:goto_menu("main_menu")
+        return
+    end
+    show(node, list)
+
+    local answer = node:ask("  Join # (L# to leave, Enter to return): ", 5)
+    if answer == nil or answer == "" then
+        node:goto_menu("main_menu")
+        return
+    end
+    answer = string.upper(answer)
+    if answer:sub(1, 1) == "L" then
+        local id = tonumber(answer:sub(2))
+        if id ~= nil then
+            err = conf.leave(id)
+            if err ~= nil then
+                node:sendln("  " .. err .. ".")
+            else
+                node:sendln("  Left conference " .. id .. ".")
+            end
+        end
+    else
+        local id = tonumber(answer)
+        if id ~= nil then
+            local c
+            c, err = conf.join(id)
+            if c == nil then
+                node:sendln("  " .. err .. ".")
+            else
+                node:sendln("  Now in " .. c.name .. ".")
+            end
+        end
+    end
+    node:pause()
+    node:goto_menu("conferences")
+end
+
+return menu
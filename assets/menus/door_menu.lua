@@ -10,9 +10,11 @@ local menu = {}
 -- - command (string, DOS path inside drive C, e.g. "C:\\HELLO\\HELLO.BAT")
 -- Optional:
 -- - description (string)
--- - drop_file_type (string; default "DOOR.SYS")
+-- - drop_file_type (string; default "DOOR.SYS"; also "DORINFO1.DEF",
+--   "CHAIN.TXT", "DOORFILE.SR", "SFDOORS.DAT" or "PCBOARD.SYS")
 -- - security_level (number; default 10)
 -- - multiuser (bool; default true). If false, only one user may run the door at a time.
+-- - daily_minutes (number; default unlimited). Minutes each user may play per day.
 local doors = {
     -- Example smoke-test door
     --["H"] = {
@@ -25,12 +27,43 @@ local doors = {
     ["D"] = {
         name = "Darkness",
         description = "Darkness v2.0 - Post-apocalyptic cyberpunk adventure",
-        command = "C:\\DOORS\\DARKNESS\\DARK16.EXE /N{NODE} /D3 /PC:\\NODES\\TEMP{NODE}",
+        command = "C:\\DOORS\\DARKNESS\\DARK16.EXE /N{NODE} /D3 /P{DROP}",
         drop_file_type = "DOOR.SYS",
         security_level = 10,
     },
 }
 
+-- Door usage: the board's favourites and the caller's own time.
+local function show_stats(node)
+    node:cls()
+    node:sendln("")
+    node:sendln("  -- Most played doors (30 days) --")
+    local top = door.top(30, 5) or {}
+    for i, d in ipairs(top) do
+        node:sendln(string.format("  %d. %-30s %4d plays %5d min", i, d.name, d.launches, d.minutes))
+    end
+    if #top == 0 then
+        node:sendln("  Nobody has played a door yet.")
+    end
+    node:sendln("")
+    node:sendln("  -- Your time in doors (30 days) --")
+    local mine = door.playtime(30) or {}
+    for _, d in ipairs(mine) do
+        node:sendln(string.format("  %-33s %4d plays %5d min", d.name, d.launches, d.minutes))
+    end
+    if #mine == 0 then
+        node:sendln("  You have not played any doors.")
+    end
+    for _, cfg in pairs(doors) do
+        local left = door.time_left(cfg)
+        if left ~= nil then
+            node:sendln(string.format("  %s: %d min left today", cfg.name, left))
+        end
+    end
+    node:sendln("")
+    node:pause()
+end
+
 function menu.on_load(node)
     node:cls()
 end
@@ -60,6 +93,11 @@ function menu.on_key(node, key)
     end
 
     local cfg = doors[k]
+    if cfg == nil and k == "S" then
+        show_stats(node)
+        node:goto_menu("door_menu")
+        return
+    end
     if cfg == nil then
         --node:sendln("\r\n  Unknown door option.")
         --node:pause()
@@ -37,38 +37,44 @@ local function require_transfer(node)
     return true
 end
 
-local function parse_id_list(raw)
-    local set = {}
-    local order = {}
-    if raw == nil or raw == "" then
-        return set, order
+local function protocol_name()
+    local p = transfer.protocol()
+    if p == nil then
+        return "none"
     end
+    return p.name
+end
 
-    for token in string.gmatch(raw, "[^,%s]+") do
-        local id = tonumber(token)
-        if id and not set[id] then
-            set[id] = true
-            table.insert(order, id)
-        end
+-- Bytes, time and speed of the transfer that just finished
+local function show_throughput(node)
+    local t = transfer.last()
+    if t == nil then
+        return
     end
-    return set, order
+    node:sendln(string.format("  %d bytes in %ds (%d cps)", t.bytes, math.floor(t.seconds + 0.5), t.cps))
 end
 
-local function save_id_list(node, key, order)
-    if order == nil or #order == 0 then
-        node:set_session(key, nil)
+local function choose_protocol(node)
+    if not require_transfer(node) then
         return
     end
-
-    local parts = {}
-    for _, id in ipairs(order) do
-        table.insert(parts, tostring(id))
+    local p, err = transfer.pick_protocol("Select a transfer protocol")
+    if p then
+        node:sendln("\r\n  Transfers now use " .. p.name .. ".")
+        node:pause()
+    elseif err ~= "cancelled" then
+        node:sendln("\r\n  " .. err)
+        node:pause()
     end
-    node:set_session(key, table.concat(parts, ","))
 end
 
-local function get_marked_ids(node)
-    return parse_id_list(node:get_session("download_basket"))
+local function get_tagged(node)
+    local tagged, summary = files.tagged()
+    local set = {}
+    for _, f in ipairs(tagged) do
+        set[f.id] = true
+    end
+    return set, summary
 end
 
 function menu.on_load(node)
@@ -87,12 +93,27 @@ function menu.on_key(node, key)
     elseif key == "F" or key == "f" then
         download_file(node)
         node:goto_menu("file_menu")
+    elseif key == "W" or key == "w" then
+        web_download(node)
+        node:goto_menu("file_menu")
     elseif key == "U" or key == "u" then
         upload_file(node)
         node:goto_menu("file_menu")
     elseif key == "S" or key == "s" then
         search_files(node)
         node:goto_menu("file_menu")
+    elseif key == "V" or key == "v" then
+        view_archive(node)
+        node:goto_menu("file_menu")
+    elseif key == "P" or key == "p" then
+        choose_protocol(node)
+        node:goto_menu("file_menu")
+    elseif key == "N" or key == "n" then
+        browse_new(node)
+        node:goto_menu("file_menu")
+    elseif key == "T" or key == "t" then
+        top_downloads(node)
+        node:goto_menu("file_menu")
     elseif key == "Q" or key == "q" then
         node:goto_menu("main_menu")
     end
@@ -102,41 +123,12 @@ end
 -- List and select file areas
 -- -----------------------------------------------------------------------
 function list_areas(node)
-    local areas = files.areas()
-    if areas == nil or #areas == 0 then
-        node:sendln("\r\n  No file areas available.")
+    local area, err = files.pick_area("Select a file area")
+    if area then
+        set_current_area(node, area.id, area.name)
+    elseif err ~= "cancelled" then
+        node:sendln("\r\n  " .. err)
         node:pause()
-        node:goto_menu("file_menu")
-        return
-    end
-
-    node:sendln("")
-    node:sendln("  #   Name                          Files")
-    node:sendln("  --- ------------------------------ -----")
-    for i, area in ipairs(areas) do
-        local line = string.format("  %-3d %-30s %5d",
-            area.id, area.name, area.files)
-        node:sendln(line)
-    end
-    node:sendln("")
-
-    local choice = node:ask("  Enter area # (or Q to cancel): ", 5)
-    if choice == nil or choice == "" or string.upper(choice) == "Q" then
-        node:goto_menu("file_menu")
-        return
-    end
-
-    local area_id = tonumber(choice)
-    if area_id then
-        local area = files.get_area(area_id)
-        if area then
-            set_current_area(node, area_id, area.name)
-            node:sendln("  Current area: " .. area.name)
-            node:pause()
-        else
-            node:sendln("  Area not found.")
-            node:pause()
-        end
     end
     node:goto_menu("file_menu")
 end
@@ -204,6 +196,47 @@ local function parse_selection(input, max)
     return picks
 end
 
+-- show_info shows a file's whole description.
+local function show_info(node, f)
+    if f == nil then
+        node:sendln("  Invalid selection.")
+        node:pause()
+        return
+    end
+    node:cls()
+    local text = string.format("Size: %s  Downloads: %d  Uploaded: %s by %s\n\n%s",
+        f.size_str, f.downloads, f.date, f.uploader, f.description)
+    node:view_text(text, { title = f.filename })
+end
+
+-- toggle_tags tags or untags the files picked by number from file_list.
+local function toggle_tags(node, choice, file_list, marked_set)
+    local picks = parse_selection(choice, #file_list)
+    if next(picks) == nil then
+        node:sendln("  Invalid selection.")
+        node:pause()
+        return
+    end
+    local refused = false
+    for idx, _ in pairs(picks) do
+        local f = file_list[idx]
+        if f then
+            if marked_set[f.id] then
+                files.untag(f.id)
+            else
+                local err = files.tag(f.id)
+                if err then
+                    node:sendln("  " .. f.filename .. ": " .. err)
+                    refused = true
+                end
+            end
+        end
+    end
+    if refused then
+        node:pause()
+    end
+end
+
 function browse_and_mark(node)
     local area_id = get_or_default_area(node)
     if not area_id then
@@ -217,15 +250,15 @@ function browse_and_mark(node)
 
     while true do
         node:cls()
-        local marked_set, marked_order = get_marked_ids(node)
+        local marked_set, summary = get_tagged(node)
         local file_list = list_files(node, area_id, offset, limit, marked_set)
         if file_list == nil or #file_list == 0 then
             return
         end
 
         node:sendln("")
-        node:sendln("  Marked files: " .. tostring(#marked_order))
-        node:sendln("  [#] Toggle  [N]ext  [P]rev  [D]ownload marked  [C]lear  [Q]uit")
+        node:sendln(string.format("  Tagged files: %d (%s)", summary.count, summary.size_str))
+        node:sendln("  [#] Toggle  [I#] Info  [N]ext  [P]rev  [D]ownload marked  [C]lear  [Q]uit")
 
         local choice = node:ask("  Selection: ", 20)
         if choice == nil or choice == "" then
@@ -251,78 +284,149 @@ function browse_and_mark(node)
         elseif upper == "D" then
             download_marked(node)
         elseif upper == "C" then
-            save_id_list(node, "download_basket", {})
-            node:sendln("  Cleared marked files.")
+            files.clear_tags()
+            node:sendln("  Cleared tagged files.")
             node:pause()
+        elseif upper:match("^I%d+$") then
+            show_info(node, file_list[tonumber(upper:sub(2))])
         else
-            local picks = parse_selection(choice, #file_list)
-            if next(picks) == nil then
-                node:sendln("  Invalid selection.")
-                node:pause()
-            else
-                for idx, _ in pairs(picks) do
-                    local f = file_list[idx]
-                    if f then
-                        if marked_set[f.id] then
-                            marked_set[f.id] = nil
-                            for i, id in ipairs(marked_order) do
-                                if id == f.id then
-                                    table.remove(marked_order, i)
-                                    break
-                                end
-                            end
-                        else
-                            marked_set[f.id] = true
-                            table.insert(marked_order, f.id)
-                        end
-                    end
-                end
-                save_id_list(node, "download_basket", marked_order)
-            end
+            toggle_tags(node, choice, file_list, marked_set)
         end
     end
 end
 
 -- -----------------------------------------------------------------------
--- Download a file via ZMODEM-8K
+-- Browse the files uploaded since the last scan, area by area
 -- -----------------------------------------------------------------------
-function download_file(node)
-    local area_id = get_or_default_area(node)
-    if not area_id then
-        node:sendln("\r\n  No file areas available.")
+function browse_new(node)
+    local new_list, count, areas = files.new_since_last_call(200)
+    if new_list == nil then
+        node:sendln("\r\n  " .. count)
         node:pause()
         return
     end
-
-    if not require_transfer(node) then
+    if #new_list == 0 then
+        node:sendln("\r\n  No new files since your last scan.")
+        node:pause()
+        files.mark_scanned()
         return
     end
 
-    if not transfer.available() then
-        node:sendln("\r\n  File transfer is not available (SEXYZ not found).")
-        node:pause()
-        return
+    local offset = 0
+    local limit = 20
+
+    while true do
+        node:cls()
+        local marked_set, summary = get_tagged(node)
+        local page = {}
+        for i = offset + 1, math.min(offset + limit, #new_list) do
+            page[#page + 1] = new_list[i]
+        end
+
+        node:sendln("")
+        node:sendln(string.format("  New files %d-%d of %d in %d area%s", offset + 1, offset + #page,
+            count, areas, areas == 1 and "" or "s"))
+        local area = nil
+        for i, f in ipairs(page) do
+            if f.area ~= area then
+                area = f.area
+                node:sendln("")
+                node:sendln("  " .. area)
+                node:sendln("  M  #   Filename             Size      DLs  Date        Description")
+                node:sendln("  -- --- -------------------- --------- ---- ----------  -------------------")
+            end
+            local mark = " "
+            if marked_set[f.id] then
+                mark = "*"
+            end
+            node:sendln(string.format("  %s  %-3d %-20s %9s %4d %10s  %s",
+                mark,
+                i,
+                string.sub(f.filename, 1, 20),
+                f.size_str,
+                f.downloads,
+                f.date,
+                string.sub(f.description, 1, 19)))
+        end
+
+        node:sendln("")
+        node:sendln(string.format("  Tagged files: %d (%s)", summary.count, summary.size_str))
+        node:sendln("  [#] Toggle  [I#] Info  [N]ext  [P]rev  [D]ownload marked  [C]lear  [Q]uit")
+
+        local choice = node:ask("  Selection: ", 20)
+        local upper = string.upper(choice or "")
+        if upper == "" or upper == "Q" then
+            files.mark_scanned()
+            return
+        elseif upper == "N" then
+            if offset + limit < #new_list then
+                offset = offset + limit
+            else
+                node:sendln("  End of list.")
+                node:pause()
+            end
+        elseif upper == "P" then
+            offset = math.max(offset - limit, 0)
+        elseif upper == "D" then
+            download_marked(node)
+        elseif upper == "C" then
+            files.clear_tags()
+            node:sendln("  Cleared tagged files.")
+            node:pause()
+        elseif upper:match("^I%d+$") then
+            show_info(node, page[tonumber(upper:sub(2))])
+        else
+            toggle_tags(node, choice, page, marked_set)
+        end
     end
+end
 
-    -- Show file listing and let user pick one
+-- -----------------------------------------------------------------------
+-- Download a file via ZMODEM-8K
+-- -----------------------------------------------------------------------
+-- Show the area's files and let the user pick one to download
+function choose_file(node, area_id)
     local file_list = list_files(node, area_id)
     if file_list == nil or #file_list == 0 then
-        return
+        return nil
     end
 
     local choice = node:ask("  Enter file # to download (or Q to cancel): ", 5)
     if choice == nil or choice == "" or string.upper(choice) == "Q" then
-        return
+        return nil
     end
 
     local idx = tonumber(choice)
     if not idx or idx < 1 or idx > #file_list then
         node:sendln("  Invalid selection.")
         node:pause()
+        return nil
+    end
+    return file_list[idx]
+end
+
+function download_file(node)
+    local area_id = get_or_default_area(node)
+    if not area_id then
+        node:sendln("\r\n  No file areas available.")
+        node:pause()
         return
     end
 
-    local f = file_list[idx]
+    if not require_transfer(node) then
+        return
+    end
+
+    if not transfer.available() then
+        node:sendln("\r\n  File transfer is not available (no protocol installed).")
+        node:pause()
+        return
+    end
+
+    local f = choose_file(node, area_id)
+    if not f then
+        return
+    end
     local area = files.get_area(area_id)
     if not area then
         node:sendln("  Error: could not load area information.")
@@ -335,14 +439,15 @@ function download_file(node)
 
     node:sendln("")
     node:sendln("  Sending: " .. f.filename .. " (" .. f.size_str .. ")")
-    node:sendln("  Protocol: ZMODEM-8K")
+    node:sendln("  Protocol: " .. protocol_name())
     node:sendln("")
-    node:sendln("  Start your ZMODEM download now...")
+    node:sendln("  Start your " .. protocol_name() .. " download now...")
     node:sendln("")
 
     local ok, err = transfer.send(filepath)
     if ok then
         node:sendln("\r\n  Transfer complete!")
+        show_throughput(node)
         files.increment_download(f.id)
     else
         node:sendln("\r\n  Transfer failed: " .. (err or "unknown error"))
@@ -350,62 +455,71 @@ function download_file(node)
     node:pause()
 end
 
-function download_marked(node)
-    local marked_set, marked_order = get_marked_ids(node)
-    if marked_order == nil or #marked_order == 0 then
-        node:sendln("\r\n  No files marked for download.")
+-- -----------------------------------------------------------------------
+-- Download with a web browser through a one-time link
+-- -----------------------------------------------------------------------
+function web_download(node)
+    local area_id = get_or_default_area(node)
+    if not area_id then
+        node:sendln("\r\n  No file areas available.")
         node:pause()
         return
     end
 
-    if not require_transfer(node) then
+    local f = choose_file(node, area_id)
+    if not f then
         return
     end
 
-    if not transfer.available() then
-        node:sendln("\r\n  File transfer is not available (SEXYZ not found).")
+    local link, err = files.web_link(f.id)
+    if not link then
+        node:sendln("\r\n  No web download: " .. err)
         node:pause()
         return
     end
+    node:sendln("")
+    node:sendln("  Open this address in your browser to download " .. f.filename .. ":")
+    node:sendln("")
+    node:sendln("  " .. link.url)
+    node:sendln("")
+    node:sendln(string.format("  The link works once, for the next %d minutes.", link.minutes))
+    node:pause()
+end
 
-    local paths = {}
-    local entries = {}
-    for _, file_id in ipairs(marked_order) do
-        local f = files.get_file(file_id)
-        if f then
-            local area = files.get_area(f.area_id)
-            if area and area.path then
-                table.insert(paths, area.path .. "/" .. f.filename)
-                table.insert(entries, f)
-            end
-        end
+function download_marked(node)
+    local tagged, summary = files.tagged()
+    if #tagged == 0 then
+        node:sendln("\r\n  No files tagged for download.")
+        node:pause()
+        return
     end
 
-    if #paths == 0 then
-        node:sendln("\r\n  No valid files found for download.")
+    if not require_transfer(node) then
+        return
+    end
+
+    if not transfer.available() then
+        node:sendln("\r\n  File transfer is not available (no protocol installed).")
         node:pause()
         return
     end
 
     node:sendln("")
-    node:sendln("  Preparing to send " .. tostring(#paths) .. " file(s):")
-    for _, f in ipairs(entries) do
+    node:sendln(string.format("  Preparing to send %d file(s), %s:", summary.count, summary.size_str))
+    for _, f in ipairs(tagged) do
         node:sendln("  " .. f.filename .. " (" .. f.size_str .. ")")
     end
     node:sendln("")
-    node:sendln("  Protocol: ZMODEM-8K")
-    node:sendln("  Start your ZMODEM download now...")
+    node:sendln("  Protocol: " .. protocol_name())
+    node:sendln("  Start your " .. protocol_name() .. " download now...")
     node:sendln("")
 
-    local ok, err = transfer.send(paths)
-    if ok then
-        node:sendln("\r\n  Transfer complete!")
-        for _, f in ipairs(entries) do
-            files.increment_download(f.id)
-        end
-        save_id_list(node, "download_basket", {})
+    local sent, err = files.download_tagged()
+    if err then
+        node:sendln("\r\n  Transfer failed: " .. err)
     else
-        node:sendln("\r\n  Transfer failed: " .. (err or "unknown error"))
+        node:sendln(string.format("\r\n  Transfer complete! %d file(s) sent.", sent))
+        show_throughput(node)
     end
     node:pause()
 end
@@ -426,7 +540,7 @@ function upload_file(node)
     end
 
     if not transfer.available() then
-        node:sendln("\r\n  File transfer is not available (SEXYZ not found).")
+        node:sendln("\r\n  File transfer is not available (no protocol installed).")
         node:pause()
         return
     end
@@ -440,9 +554,9 @@ function upload_file(node)
 
     node:sendln("")
     node:sendln("  Upload to: " .. area.name)
-    node:sendln("  Protocol: ZMODEM-8K")
+    node:sendln("  Protocol: " .. protocol_name())
     node:sendln("")
-    node:sendln("  Start your ZMODEM upload now...")
+    node:sendln("  Start your " .. protocol_name() .. " upload now...")
     node:sendln("")
 
     local received, err = transfer.receive(area.path)
@@ -454,6 +568,7 @@ function upload_file(node)
 
     -- Catalog each received file
     node:sendln("\r\n  Received " .. #received .. " file(s):")
+    show_throughput(node)
     node:sendln("")
     for _, rf in ipairs(received) do
         node:sendln("  " .. rf.name .. " (" .. rf.size .. " bytes)")
@@ -473,6 +588,131 @@ function upload_file(node)
     node:pause()
 end
 
+-- -----------------------------------------------------------------------
+-- Look inside an archive and read its text files online
+-- -----------------------------------------------------------------------
+local function page_size(node)
+    local rows = (node.height or 24) - 4
+    if rows < 5 then
+        rows = 5
+    end
+    return rows
+end
+
+local function show_text(node, path, name)
+    local fh = io.open(path, "rb")
+    if not fh then
+        node:sendln("  Could not open " .. name .. ".")
+        node:pause()
+        return
+    end
+
+    node:cls()
+    node:sendln("  --- " .. name .. " ---")
+    local rows = page_size(node)
+    local shown = 0
+    for line in fh:lines() do
+        line = string.gsub(line, "\r$", "")
+        node:sendln(line)
+        shown = shown + 1
+        if shown >= rows then
+            node:send("  -- More -- [Enter] continue, [Q]uit ")
+            local key = node:getkey()
+            node:sendln("")
+            if key == "Q" or key == "q" then
+                fh:close()
+                return
+            end
+            shown = 0
+        end
+    end
+    fh:close()
+    node:sendln("  --- end of " .. name .. " ---")
+    node:pause()
+end
+
+function view_archive(node)
+    local area_id = get_or_default_area(node)
+    if not area_id then
+        node:sendln("\r\n  No file areas available.")
+        node:pause()
+        return
+    end
+
+    local file_list = list_files(node, area_id)
+    if file_list == nil or #file_list == 0 then
+        return
+    end
+
+    local choice = node:ask("  Enter file # to view (or Q to cancel): ", 5)
+    if choice == nil or choice == "" or string.upper(choice) == "Q" then
+        return
+    end
+    local idx = tonumber(choice)
+    if not idx or idx < 1 or idx > #file_list then
+        node:sendln("  Invalid selection.")
+        node:pause()
+        return
+    end
+
+    local f = file_list[idx]
+    local members, err = files.view_archive(f.id)
+    if not members then
+        node:sendln("  " .. f.filename .. ": " .. (err or "cannot read archive"))
+        node:pause()
+        return
+    end
+
+    local offset = 0
+    local rows = page_size(node) - 4
+    while true do
+        node:cls()
+        node:sendln("  Contents of " .. f.filename .. " (" .. #members .. " files)")
+        node:sendln("  #   Name                           Size       Date")
+        node:sendln("  --- ------------------------------ ---------- ----------------")
+        local last = math.min(offset + rows, #members)
+        for i = offset + 1, last do
+            local m = members[i]
+            node:sendln(string.format("  %-3d %-30s %10s %s",
+                i,
+                string.sub(m.name, 1, 30),
+                m.dir and "<DIR>" or m.size_str,
+                m.date or ""))
+        end
+        node:sendln("")
+        node:sendln("  [#] Read  [N]ext  [P]rev  [Q]uit")
+
+        choice = node:ask("  Selection: ", 5)
+        if choice == nil or choice == "" or string.upper(choice) == "Q" then
+            return
+        end
+
+        local upper = string.upper(choice)
+        if upper == "N" then
+            if last < #members then
+                offset = offset + rows
+            end
+        elseif upper == "P" then
+            offset = math.max(offset - rows, 0)
+        else
+            local n = tonumber(choice)
+            local m = n and members[n]
+            if not m or m.dir then
+                node:sendln("  Invalid selection.")
+                node:pause()
+            else
+                local path, xerr = files.extract_member(f.id, m.name)
+                if path then
+                    show_text(node, path, m.name)
+                else
+                    node:sendln("  " .. m.name .. ": " .. (xerr or "cannot extract"))
+                    node:pause()
+                end
+            end
+        end
+    end
+end
+
 -- -----------------------------------------------------------------------
 -- Search files across all areas
 -- -----------------------------------------------------------------------
@@ -506,4 +746,41 @@ function search_files(node)
     node:pause()
 end
 
+-- -----------------------------------------------------------------------
+-- Top downloads
+-- -----------------------------------------------------------------------
+local top_periods = { D = "day", W = "week", M = "month", Y = "year", A = "all" }
+
+function top_downloads(node)
+    node:sendln("")
+    node:send("  Top downloads for [D]ay, [W]eek, [M]onth, [Y]ear or [A]ll time? ")
+    local key = string.upper(node:getkey() or "")
+    node:sendln("")
+    local period = top_periods[key]
+    if period == nil then
+        return
+    end
+
+    local top, err = files.top(10, period)
+    if top == nil then
+        node:sendln("  " .. err)
+        node:pause()
+        return
+    end
+    node:sendln("")
+    if #top == 0 then
+        node:sendln("  Nothing has been downloaded yet.")
+        node:pause()
+        return
+    end
+    node:sendln("  ##  Filename             Downloads  Area")
+    node:sendln("  --  -------------------- ---------  --------------------")
+    for i, f in ipairs(top) do
+        node:sendln(string.format("  %2d  %-20s %9d  %s",
+            i, string.sub(f.filename, 1, 20), f.downloads, string.sub(f.area, 1, 20)))
+    end
+    node:sendln("")
+    node:pause()
+end
+
 return menu
@@ -8,11 +8,22 @@ function menu.on_enter(node)
 
     local username = nil
 
-    -- Prefer placeholders in the art file (e.g. {{USER,30}} / {{PASS,30}}).
-    username = node:input_field("USER", 30)
-    if username == nil then
-        node:sendln("")
-        username = node:ask("  Username (NEW for new user): ", 30)
+    -- SSH callers already gave their username; only ask for the password.
+    if node.ssh_username ~= "" then
+        username = node.ssh_username
+        if node:field("USER") ~= nil then
+            node:output_field("USER", username)
+        else
+            node:sendln("")
+            node:sendln("  Username: " .. username)
+        end
+    else
+        -- Prefer placeholders in the art file (e.g. {{USER,30}} / {{PASS,30}}).
+        username = node:input_field("USER", 30)
+        if username == nil then
+            node:sendln("")
+            username = node:ask("  Username (NEW for new user): ", 30)
+        end
     end
 
     if username == nil or username == "" then
@@ -44,23 +55,34 @@ function menu.on_enter(node)
     node:restore_cursor()
 
     local user, err = users.login(username, password)
+    if err == "totp required" or err == "totp enrollment required" then
+        node:goto_menu("login_totp")
+        return
+    end
     if user == nil then
         node:sendln("")
         node:sendln("  Login failed: " .. (err or "unknown error"))
         node:sendln("")
-        node:send("  Create a new account? [Y/N]: ")
-        local choice = node:getkey()
+        if users.reset_available() then
+            node:send("  Create a new account, or reset a forgotten password? [Y/R/N]: ")
+        else
+            node:send("  Create a new account? [Y/N]: ")
+        end
+        local choice = string.upper(node:getkey() or "")
         node:sendln("")
-        if string.upper(choice) == 'Y' then
+        if choice == 'Y' then
             node:goto_menu("registration")
             return
         end
+        if choice == 'R' and users.reset_available() then
+            node:goto_menu("password_reset")
+            return
+        end
         node:goto_menu("login")
         return
     end
 
-    node:sendln("")
-    node:sendln("  Welcome back, " .. user.name .. "!")
+    -- The greeting was shown as part of login (greetings.at_login).
     node:sendln("  Security level: " .. user.level)
     node:sendln("  Total calls: " .. user.calls)
     if user.last_on then
@@ -69,7 +91,7 @@ function menu.on_enter(node)
     node:sendln("")
     node:pause(2)
     node:cls()
-    node:goto_menu("main_menu")
+    node:goto_menu("bulletins")
 end
 
 return menu
@@ -0,0 +1,99 @@
+-- login_totp.lua - Second login step for accounts with two-factor authentication
+local menu = {}
+
+local function show_backup_codes(node, codes)
+    node:sendln("")
+    node:sendln("  Backup codes - each works once if you lose your device.")
+    node:sendln("  Write them down now; they will not be shown again:")
+    node:sendln("")
+    for i = 1, #codes, 2 do
+        node:sendln("    " .. codes[i] .. "    " .. (codes[i + 1] or ""))
+    end
+    node:sendln("")
+end
+
+-- Mandatory enrollment: returns the user once enrollment is confirmed.
+local function enroll(node)
+    node:sendln("  Your account requires two-factor authentication.")
+    local secret, uri = users.totp_enroll()
+    if secret == nil then
+        node:sendln("  " .. (uri or "enrollment failed"))
+        return nil
+    end
+    node:sendln("  Add this key to an authenticator app (TOTP, 6 digits):")
+    node:sendln("")
+    node:sendln("    " .. secret:gsub("(....)", "%1 "))
+    node:sendln("")
+    node:sendln("  Or import: " .. uri)
+    node:sendln("")
+    for _ = 1, 3 do
+        local code = node:ask("  Code shown by your app: ", 6)
+        if code == nil or code == "" then
+            return nil
+        end
+        local codes, err = users.totp_confirm(code)
+        if codes ~= nil then
+            show_backup_codes(node, codes)
+            node:pause()
+            return users.get_current()
+        end
+        node:sendln("  " .. err .. ".")
+    end
+    return nil
+end
+
+local function verify(node)
+    node:sendln("  Two-factor authentication is enabled for this account.")
+    while true do
+        local code = node:ask("  Code from your app (or a backup code): ", 12)
+        if code == nil or code == "" then
+            return nil
+        end
+        local user, err = users.totp_verify(code)
+        if user ~= nil then
+            return user
+        end
+        node:sendln("  " .. (err or "unknown error") .. ".")
+        if err ~= "invalid code" then
+            return nil
+        end
+    end
+end
+
+function menu.on_enter(node)
+    local mode = users.totp_pending()
+    if mode == nil then
+        node:goto_menu("login")
+        return
+    end
+
+    node:sendln("")
+    local user
+    if mode == "enroll" then
+        user = enroll(node)
+    else
+        user = verify(node)
+    end
+
+    if user == nil then
+        node:sendln("")
+        node:sendln("  Login failed.")
+        node:pause(2)
+        node:cls()
+        node:goto_menu("login")
+        return
+    end
+
+    -- The greeting was shown as part of login (greetings.at_login).
+    node:sendln("  Security level: " .. user.level)
+    node:sendln("  Total calls: " .. user.calls)
+    if user.last_on then
+        node:sendln("  Last on: " .. user.last_on)
+    end
+    node:sendln("")
+    node:pause(2)
+    node:cls()
+    node:goto_menu("bulletins")
+end
+
+return menu
@@ -0,0 +1,171 @@
+-- mail_menu.lua - Private user-to-user mail
+local menu = {}
+
+local function read_mail(node, m)
+    local full = msg.read_private(m.id)
+    if full == nil then
+        node:sendln("  Mail not found.")
+        return
+    end
+    node:cls()
+    node:sendln("")
+    node:sendln("  From:    " .. full.from)
+    node:sendln("  To:      " .. full.to)
+    node:sendln("  Date:    " .. full.date)
+    node:sendln("  Subject: " .. full.subject)
+    node:sendln("  ---------------------------------------------------")
+    node:view_text(full.body, { row = 7, col = 3 })
+    node:cls()
+    node:sendln("")
+    node:sendln("  " .. full.subject)
+end
+
+local function inbox(node)
+    while true do
+        node:cls()
+        node:sendln("")
+        node:sendln("  -- Inbox --")
+        node:sendln("")
+
+        local mails = msg.inbox()
+        if mails == nil or #mails == 0 then
+            node:sendln("  Your mailbox is empty.")
+            node:pause()
+            return
+        end
+
+        node:sendln("  #    New  From             Date              Subject")
+        node:sendln("  ---- ---  ---------------- ----------------  ------------------------")
+        for i, m in ipairs(mails) do
+            local new = " "
+            if not m.read then
+                new = "*"
+            end
+            node:sendln(string.format("  %-4d  %s   %-16s %-16s  %s",
+                i, new, m.from, m.date, m.subject))
+        end
+        node:sendln("")
+
+        local input = node:ask("  Read # (Enter to return): ", 4)
+        local n = tonumber(input or "")
+        if n == nil or mails[n] == nil then
+            return
+        end
+
+        read_mail(node, mails[n])
+        if node:yesno("  Delete this mail?") then
+            local err = msg.delete_private(mails[n].id)
+            if err then
+                node:sendln("  Delete failed: " .. err)
+            else
+                node:sendln("  Deleted.")
+            end
+        end
+        node:pause()
+    end
+end
+
+local function outbox(node)
+    node:cls()
+    node:sendln("")
+    node:sendln("  -- Outbox --")
+    node:sendln("")
+
+    local mails = msg.outbox()
+    if mails == nil or #mails == 0 then
+        node:sendln("  You have not sent any mail.")
+        node:pause()
+        return
+    end
+
+    node:sendln("  To               Date              Read  Subject")
+    node:sendln("  ---------------- ----------------  ----  ------------------------")
+    for _, m in ipairs(mails) do
+        local read = "no"
+        if m.read then
+            read = "yes"
+        end
+        node:sendln(string.format("  %-16s %-16s  %-4s  %s", m.to, m.date, read, m.subject))
+    end
+    node:sendln("")
+    node:pause()
+end
+
+local function send(node)
+    node:sendln("")
+    local to = node:ask("  To (? to pick): ", 30)
+    if to == "?" then
+        local picked = users.pick("Send mail to")
+        if picked == nil then
+            return
+        end
+        to = picked.name
+        node:sendln("  To: " .. to)
+    end
+    if to == nil or to == "" then
+        return
+    end
+    if not users.exists(to) then
+        node:sendln("  No such user.")
+        node:pause()
+        return
+    end
+
+    local subject = node:ask("  Subject: ", 60)
+    if subject == nil or subject == "" then
+        node:sendln("  Cancelled.")
+        node:pause()
+        return
+    end
+
+    node:sendln("  Enter your message. A blank line ends it.")
+    local lines = {}
+    while true do
+        local line = node:ask("> ", 78)
+        if line == nil or line == "" then
+            break
+        end
+        table.insert(lines, line)
+    end
+    if #lines == 0 then
+        node:sendln("  Empty message, cancelled.")
+        node:pause()
+        return
+    end
+
+    local id, err = msg.send_private(to, subject, table.concat(lines, "\n"))
+    if id then
+        node:sendln("  Mail sent.")
+    else
+        node:sendln("  Error sending: " .. tostring(err or "unknown"))
+    end
+    node:pause()
+end
+
+function menu.on_load(node)
+    node:cls()
+end
+
+function menu.on_enter(node)
+    local unread = msg.unread_mail()
+    if unread > 0 then
+        node:sendln(string.format("  You have %d unread mail message(s).", unread))
+    end
+end
+
+function menu.on_key(node, key)
+    if key == "I" or key == "i" then
+        inbox(node)
+        node:goto_menu("mail_menu")
+    elseif key == "O" or key == "o" then
+        outbox(node)
+        node:goto_menu("mail_menu")
+    elseif key == "S" or key == "s" then
+        send(node)
+        node:goto_menu("mail_menu")
+    elseif key == "Q" or key == "q" then
+        node:goto_menu("message_menu")
+    end
+end
+
+return menu
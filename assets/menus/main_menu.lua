@@ -1,11 +1,37 @@
 -- main_menu.lua - Main BBS menu
 local menu = {}
 
+-- Who's online, offering to page one of them for a private chat
+local function who_online(node)
+    if chat == nil then
+        node:show_online()
+        return
+    end
+    node:show_online(false)
+    local id = node:ask("  Page which node for a private chat (Enter to return)? ", 3)
+    id = tonumber(id)
+    if id == nil then
+        return
+    end
+    node:sendln(string.format("  Paging node %d, press any key to give up...", id))
+    local room, err = chat.page(id)
+    if room == nil then
+        node:sendln("  No chat: " .. err)
+        node:pause()
+        return
+    end
+    node:enter_chat(room)
+end
+
 function menu.on_load(node)
     node:cls()
 end
 
 function menu.on_key(node, key)
+    -- The caller's own one-key macros come before the menu's keys.
+    if node:macro(key) then
+        return
+    end
     if key == "M" or key == "m" then
         node:goto_menu("message_menu")
     elseif key == "F" or key == "f" then
@@ -15,10 +41,16 @@ function menu.on_key(node, key)
     elseif key == "D" or key == "d" then
         node:goto_menu("door_menu")
     elseif key == "W" or key == "w" then
-        node:show_online()
+        who_online(node)
         node:goto_menu("main_menu")
     elseif key == "Y" or key == "y" then
         node:goto_menu("user_stats")
+    elseif key == "S" or key == "s" then
+        node:goto_menu("security")
+    elseif key == "B" or key == "b" then
+        node:gosub_menu("bulletins", { browse = true })
+    elseif key == "J" or key == "j" then
+        node:goto_menu("conferences")
     elseif key == "!" then
         node:goto_menu("sysop_menu")
     elseif key == "G" or key == "g" then
@@ -26,12 +58,31 @@ function menu.on_key(node, key)
     end
 end
 
+-- Slash commands, typed after / at the menu
+local commands = slash.commands({
+    { name = "who", aliases = { "w" }, help = "Who's online" },
+    { name = "quit", aliases = { "q", "g" }, help = "Log off" },
+    { name = "sysop", acs = "s100", help = "Sysop menu" },
+})
+
 function menu.on_input(node, input)
-    if input == "/who" then
-        node:show_online()
+    local cmd, err = commands.parse(input)
+    if cmd == nil then
+        if err ~= nil and input ~= "/?" then
+            node:sendln("  " .. err)
+        end
+        for _, line in ipairs(commands.help()) do
+            node:sendln("  " .. line)
+        end
+        node:pause()
+        node:goto_menu("main_menu")
+    elseif cmd.name == "who" then
+        who_online(node)
         node:goto_menu("main_menu")
-    elseif input == "/quit" or input == "/q" then
+    elseif cmd.name == "quit" then
         node:goto_menu("goodbye")
+    elseif cmd.name == "sysop" then
+        node:goto_menu("sysop_menu")
     end
 end
 
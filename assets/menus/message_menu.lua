@@ -14,6 +14,8 @@ function menu.on_key(node, key)
         node:goto_menu("message_post")
     elseif key == "S" or key == "s" then
         node:goto_menu("message_scan")
+    elseif key == "E" or key == "e" then
+        node:goto_menu("mail_menu")
     elseif key == "Q" or key == "q" then
         node:goto_menu("main_menu")
     end
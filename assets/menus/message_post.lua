@@ -69,6 +69,11 @@ function menu.on_enter(node)
 
     while true do
         node:output_field("BODY_PREVIEW", preview_text(lines, 10))
+        -- on_shutdown cannot run while this loop has the caller; check here.
+        local left = node:shutdown_in()
+        if left then
+            status(node, string.format("Board goes down in %ds - a blank line posts now.", left))
+        end
         local line = node:input_field("BODY_LINE", 78)
         if line == nil then
             line = node:ask("> ", 78)
@@ -93,6 +98,11 @@ function menu.on_enter(node)
     end
 
     local body = table.concat(lines, "\n")
+    local me = users.get_current()
+    local profile = me and users.profile(me.name)
+    if profile ~= nil and profile.signature ~= "" then
+        body = body .. "\n\n" .. profile.signature
+    end
     local id, err = msg.post(area_id, subject, body)
     if id then
         status(node, "Message posted! (#" .. tostring(id) .. ")")
@@ -1,4 +1,4 @@
--- message_read.lua - Read a message
+-- message_read.lua - Read messages starting at the selected one
 local menu = {}
 
 local function status(node, text)
@@ -14,35 +14,25 @@ function menu.on_enter(node)
     status(node, "")
 
     local msg_id = tonumber(node:get_session("current_msg_id"))
-    if msg_id == nil then
-        status(node, "No message selected.")
-        node:pause()
-        node:goto_menu("message_menu")
-        return
-    end
-
-    local m = msg.read(msg_id)
+    local m = msg_id and msg.read(msg_id)
     if m == nil then
-        status(node, "Message not found.")
+        status(node, msg_id and "Message not found." or "No message selected.")
         node:pause()
         node:goto_menu("message_menu")
         return
     end
 
-    node:output_field("FROM", m.from or "")
-    node:output_field("TO", m.to or "")
-    node:output_field("SUBJECT", m.subject or "")
-    node:output_field("DATE", m.date or "")
-    node:output_field("BODY", m.body or "")
-
-    -- Move cursor to STATUS line so pause prompt doesn't overwrite BODY.
-    local row, col = node:field("STATUS")
-    if row ~= nil then
-        node:goto_xy(row, col)
+    -- The reader handles paging and N/P/R/A/Q itself.
+    local action, last = msg.read_loop(m.area_id, { start = m.id })
+    if last then
+        node:set_session("current_msg_id", last.id)
+    end
+    if action == "reply" then
+        node:set_session("current_area", m.area_id)
+        node:goto_menu("message_post")
+        return
     end
-    node:pause()
     node:goto_menu("message_menu")
 end
 
 return menu
-
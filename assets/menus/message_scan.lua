@@ -1,10 +1,48 @@
--- message_scan.lua - Show new message counts
+-- message_scan.lua - Show new message counts, then offer to read them
+-- area by area
 local menu = {}
 
 local function status(node, text)
     node:output_field("STATUS", text or "")
 end
 
+-- Steps through every area with new messages. Returns false when the
+-- caller left the scan for another menu.
+local function new_scan(node)
+    local scan, err = msg.new_scan()
+    if scan == nil then
+        node:sendln("  " .. (err or "Scan failed."))
+        node:pause()
+        return true
+    end
+    for area in scan do
+        node:cls()
+        node:sendln("")
+        node:sendln(string.format("  -- %s: %d new --", area.name, area.new))
+        node:sendln("")
+        node:send("  [R]ead  [S]kip area  [C]atch up (mark all read)  [Q]uit scan: ")
+        local key = string.upper(node:getkey() or "")
+        node:sendln("")
+        if key == "Q" then
+            break
+        elseif key == "C" then
+            msg.catch_up(area.id, area.last)
+        elseif key == "R" or key == "\r" then
+            -- Without a start the reader opens the first unread message.
+            local action, last = msg.read_loop(area.id)
+            if last then
+                node:set_session("current_msg_id", last.id)
+            end
+            if action == "reply" then
+                node:set_session("current_area", area.id)
+                node:goto_menu("message_post")
+                return false
+            end
+        end
+    end
+    return true
+end
+
 function menu.on_load(node)
     node:cls()
 end
@@ -31,7 +69,13 @@ function menu.on_enter(node)
     if total_new == 0 then
         status(node, "No new messages.")
     else
-        status(node, "Total new messages: " .. tostring(total_new))
+        status(node, "Total new messages: " .. tostring(total_new) .. "  Scan all areas for new messages? (Y/N)")
+        local key = string.upper(node:getkey() or "")
+        if key == "Y" and not new_scan(node) then
+            return
+        end
+        node:goto_menu("message_menu")
+        return
     end
 
     node:pause()
@@ -0,0 +1,64 @@
+-- password_reset.lua - Reset a forgotten password with a code sent by email
+local menu = {}
+
+local function back(node)
+    node:pause(2)
+    node:cls()
+    node:goto_menu("login")
+end
+
+function menu.on_enter(node)
+    node:sendln("")
+    node:sendln("  -- Password Reset --")
+    node:sendln("")
+    if not users.reset_available() then
+        node:sendln("  This board cannot send email. Ask the sysop to reset your password.")
+        back(node)
+        return
+    end
+
+    local username = node:ask("  Username: ", 30)
+    if username == nil or username == "" then
+        back(node)
+        return
+    end
+    local err = users.request_reset(username)
+    if err ~= nil then
+        node:sendln("  Sorry, " .. err .. ".")
+        back(node)
+        return
+    end
+    node:sendln("  A reset code is on its way to the email address on the account.")
+    node:sendln("")
+
+    local code = node:ask("  Reset code: ", 12)
+    if code == nil or code == "" then
+        back(node)
+        return
+    end
+    node:sendln("  Passwords must be " .. users.password_rules() .. ".")
+    node:send("  New password: ")
+    local password = node:password() or ""
+    local problem = users.check_password(password, username)
+    if problem ~= nil then
+        node:sendln("  Sorry, that " .. problem .. ".")
+        back(node)
+        return
+    end
+    node:send("  Confirm password: ")
+    if node:password() ~= password then
+        node:sendln("  Passwords do not match.")
+        back(node)
+        return
+    end
+
+    err = users.reset_password(username, code, password)
+    if err ~= nil then
+        node:sendln("  Password not changed: " .. err .. ".")
+    else
+        node:sendln("  Password changed. Log in with your new password.")
+    end
+    back(node)
+end
+
+return menu
@@ -21,10 +21,12 @@ function menu.on_enter(node)
         return
     end
 
+    node:sendln("  Passwords must be " .. users.password_rules() .. ".")
     node:send("  Password: ")
-    local password = node:password()
-    if password == nil or password == "" or #password < 6 then
-        node:sendln("  Password must be at least 6 characters.")
+    local password = node:password() or ""
+    local problem = users.check_password(password, username)
+    if problem ~= nil then
+        node:sendln("  Sorry, that " .. problem .. ".")
         node:pause(2)
         node:cls()
         node:goto_menu("login")
@@ -44,6 +46,7 @@ function menu.on_enter(node)
     local real_name = node:ask("  Real name (optional): ", 50)
     local location = node:ask("  Location (optional): ", 50)
     local email = node:ask("  Email (optional): ", 80)
+    local birthday = node:ask("  Birthday, MM-DD (optional): ", 5)
 
     local user, err = users.register(username, password, real_name, location, email)
     if user == nil then
@@ -54,12 +57,19 @@ function menu.on_enter(node)
         return
     end
 
+    if birthday ~= nil and birthday ~= "" then
+        local berr = users.set_birthday(birthday)
+        if berr ~= nil then
+            node:sendln("  Birthday not saved: " .. berr)
+        end
+    end
+
     node:sendln("")
     node:sendln("  Account created! Welcome, " .. user.name .. "!")
     node:sendln("")
     node:pause(2)
     node:cls()
-    node:goto_menu("main_menu")
+    node:goto_menu("bulletins")
 end
 
 return menu
@@ -0,0 +1,283 @@
+-- security.lua - Account settings for the current user: two-factor
+-- authentication, emulated modem speed, character set and the tour of
+-- the board
+local menu = {}
+
+local function enable(node)
+    local secret, uri = users.totp_enroll()
+    if secret == nil then
+        node:sendln("  " .. (uri or "enrollment failed"))
+        return
+    end
+    node:sendln("")
+    node:sendln("  Add this key to an authenticator app (TOTP, 6 digits):")
+    node:sendln("")
+    node:sendln("    " .. secret:gsub("(....)", "%1 "))
+    node:sendln("")
+    node:sendln("  Or import: " .. uri)
+    node:sendln("")
+    local code = node:ask("  Code shown by your app: ", 6)
+    if code == nil or code == "" then
+        node:sendln("  Cancelled; two-factor authentication stays off.")
+        return
+    end
+    local codes, err = users.totp_confirm(code)
+    if codes == nil then
+        node:sendln("  " .. err .. "; two-factor authentication stays off.")
+        return
+    end
+    node:sendln("")
+    node:sendln("  Two-factor authentication is now ON.")
+    node:sendln("  Backup codes - each works once if you lose your device.")
+    node:sendln("  Write them down now; they will not be shown again:")
+    node:sendln("")
+    for i = 1, #codes, 2 do
+        node:sendln("    " .. codes[i] .. "    " .. (codes[i + 1] or ""))
+    end
+end
+
+local function disable(node)
+    local code = node:ask("  Code from your app (or a backup code): ", 12)
+    if code == nil or code == "" then
+        return
+    end
+    local err = users.totp_disable(code)
+    if err ~= nil then
+        node:sendln("  " .. err .. ".")
+        return
+    end
+    node:sendln("  Two-factor authentication is now OFF.")
+end
+
+local SPEEDS = { 0, 300, 1200, 2400, 9600, 14400, 28800 }
+
+local function speed_name(bps)
+    if bps == 0 then
+        return "full speed"
+    end
+    return bps .. " baud"
+end
+
+local function set_speed(node)
+    node:sendln("")
+    for i, bps in ipairs(SPEEDS) do
+        node:sendln(string.format("  %d) %s", i, speed_name(bps)))
+    end
+    local pick = tonumber(node:ask("  Modem speed to emulate (Enter to keep): ", 1))
+    if pick == nil or SPEEDS[pick] == nil then
+        return
+    end
+    local err = users.set_baud(SPEEDS[pick])
+    if err ~= nil then
+        node:sendln("  " .. err .. ".")
+        return
+    end
+    node:set_baud(SPEEDS[pick])
+    node:sendln("  Screens now draw at " .. speed_name(SPEEDS[pick]) .. ".")
+end
+
+local CHARSETS = {
+    { "utf8", "UTF-8 (most modern terminals)" },
+    { "cp437", "CP437 (SyncTERM, NetRunner and other DOS-style terminals)" },
+    { "latin1", "Latin-1 (ISO-8859-1)" },
+}
+
+local function set_charset(node)
+    node:sendln("")
+    for i, cs in ipairs(CHARSETS) do
+        node:sendln(string.format("  %d) %s", i, cs[2]))
+    end
+    local pick = tonumber(node:ask("  Character set your terminal sends (Enter to keep): ", 1))
+    if pick == nil or CHARSETS[pick] == nil then
+        return
+    end
+    local name = CHARSETS[pick][1]
+    local err = users.set_charset(name)
+    if err ~= nil then
+        node:sendln("  " .. err .. ".")
+        return
+    end
+    node:set_charset(name)
+    node:sendln("  Accented and line-drawing characters you type are now read as " .. name .. ".")
+end
+
+local PALETTES = {
+    { "auto", "Automatic (adjusts if your terminal reports a light background)" },
+    { "normal", "Normal (colors as drawn)" },
+    { "safe", "Readable on dark backgrounds (brightens dark blue on black and the like)" },
+    { "light", "Readable on light backgrounds" },
+}
+
+local function set_palette(node)
+    node:sendln("")
+    for i, p in ipairs(PALETTES) do
+        node:sendln(string.format("  %d) %s", i, p[2]))
+    end
+    local pick = tonumber(node:ask("  Colors for menus and prompts (Enter to keep): ", 1))
+    if pick == nil or PALETTES[pick] == nil then
+        return
+    end
+    local name = PALETTES[pick][1]
+    local err = users.set_palette(name)
+    if err ~= nil then
+        node:sendln("  " .. err .. ".")
+        return
+    end
+    node:set_palette(name)
+    node:sendln("  Colors set to " .. name .. ".")
+end
+
+local DEPTHS = {
+    { "auto", "Automatic (as your terminal reports)" },
+    { "16", "16 colors, for classic BBS terminals" },
+    { "256", "256 colors" },
+    { "truecolor", "Millions of colors (24-bit)" },
+}
+
+-- Macros: a short name that types a run of menu keys, | for Enter.
+local function edit_macros(node)
+    while true do
+        local list, err = users.macros()
+        if list == nil then
+            node:sendln("  " .. err .. ".")
+            return
+        end
+        node:sendln("")
+        node:sendln("  -- Macros --")
+        node:sendln("  Type a macro's name at a menu, or press Ctrl-K first at menus")
+        node:sendln("  that take single keys. | in the keys stands for Enter.")
+        node:sendln("")
+        for _, m in ipairs(list) do
+            node:sendln(string.format("  %-8s %s", m.name, m.keys))
+        end
+        if #list == 0 then
+            node:sendln("  You have no macros. Example: J2 with keys J2| joins conference 2.")
+        end
+        node:sendln("")
+        local name = node:ask("  Macro to add, change or remove (Enter to finish): ", 8)
+        if name == nil or name == "" then
+            return
+        end
+        local keys = node:ask("  Keys (Enter to remove): ", 60)
+        if keys == nil or keys == "" then
+            err = users.delete_macro(name)
+        else
+            err = users.set_macro(name, keys)
+        end
+        if err ~= nil then
+            node:sendln("  " .. err .. ".")
+        end
+    end
+end
+
+local function set_colors(node)
+    node:sendln("")
+    for i, d in ipairs(DEPTHS) do
+        node:sendln(string.format("  %d) %s", i, d[2]))
+    end
+    node:send("  Sample: ")
+    for c = 0, 5 do
+        node:color256(16 + c * 36 + 5 - c)
+        node:send("##")
+    end
+    node:color(0)
+    node:sendln("")
+    local pick = tonumber(node:ask("  Colors your terminal shows (Enter to keep): ", 1))
+    if pick == nil or DEPTHS[pick] == nil then
+        return
+    end
+    local name = DEPTHS[pick][1]
+    local err = users.set_colors(name)
+    if err ~= nil then
+        node:sendln("  " .. err .. ".")
+        return
+    end
+    node:set_colors(name)
+    node:sendln("  Color depth set to " .. node.colors .. ".")
+end
+
+function menu.on_enter(node)
+    node:cls()
+    local status = users.totp_status()
+    if status == nil then
+        node:goto_menu("main_menu")
+        return
+    end
+
+    node:sendln("")
+    node:sendln("  -- Account Settings --")
+    node:sendln("")
+    local options = {}
+    if status.enabled then
+        node:sendln("  Two-factor authentication: ON (" .. status.backup_codes .. " backup codes left)")
+        if status.required then
+            node:sendln("  It is required for your account and cannot be turned off.")
+        else
+            table.insert(options, "[D]isable")
+        end
+    else
+        node:sendln("  Two-factor authentication: OFF")
+        table.insert(options, "[E]nable")
+    end
+    node:sendln("  Modem speed: " .. speed_name(node.baud))
+    table.insert(options, "[B]aud")
+    node:sendln("  Character set: " .. node.charset)
+    table.insert(options, "[C]harset")
+    local palette = node.palette
+    if node.background ~= "" then
+        palette = palette .. " (your terminal reports a " .. node.background .. " background)"
+    end
+    node:sendln("  Colors: " .. palette)
+    table.insert(options, "[P]alette")
+    node:sendln("  Color depth: " .. node.colors)
+    table.insert(options, "[R]GB colors")
+    local macros = users.macros() or {}
+    node:sendln("  Macros: " .. #macros)
+    table.insert(options, "[K]ey macros")
+    local me = users.get_current()
+    if users.reset_available() and me.email ~= "" then
+        node:sendln("  Email me about new private mail: " .. (me.mail_notify and "YES" or "NO"))
+        table.insert(options, "[M]ail notices")
+    end
+    if tour ~= nil and #tour.steps() > 0 then
+        node:sendln("  Tour of the board: take it again any time")
+        table.insert(options, "[T]our")
+    end
+
+    if #options > 0 then
+        node:send("  " .. table.concat(options, ", ") .. " or [Q]uit: ")
+        local key = string.upper(node:getkey() or "")
+        node:sendln("")
+        if key == "D" and status.enabled and not status.required then
+            disable(node)
+        elseif key == "E" and not status.enabled then
+            enable(node)
+        elseif key == "B" then
+            set_speed(node)
+        elseif key == "C" then
+            set_charset(node)
+        elseif key == "P" then
+            set_palette(node)
+        elseif key == "R" then
+            set_colors(node)
+        elseif key == "K" then
+            edit_macros(node)
+        elseif key == "M" and users.reset_available() and me.email ~= "" then
+            local err = users.set_mail_notify(not me.mail_notify)
+            if err ~= nil then
+                node:sendln("  " .. err .. ".")
+            else
+                node:sendln("  Mail notices " .. (me.mail_notify and "off" or "on") .. ".")
+            end
+        elseif key == "T" and tour ~= nil then
+            node:gosub_menu("tour", { settings = true })
+            return
+        end
+    end
+
+    node:sendln("")
+    node:pause()
+    node:goto_menu("main_menu")
+end
+
+return menu
@@ -1,16 +1,9 @@
 -- sysop_menu.lua - Sysop administration menu
--- Requires security level 100 (LevelSysop)
+-- Requires security level 100 (LevelSysop), see sysop_menu.yaml
 local menu = {}
 
 function menu.on_load(node)
     node:cls()
-    local user = users.get_current()
-    if user == nil or user.level < 100 then
-        node:sendln("\r\n  Access denied. Sysop level required.")
-        node:pause()
-        node:goto_menu("main_menu")
-        return
-    end
 end
 
 function menu.on_key(node, key)
@@ -27,6 +20,14 @@ function menu.on_key(node, key)
     elseif key == "S" or key == "s" then
         system_stats(node)
         node:goto_menu("sysop_menu")
+    elseif key == "T" or key == "t" then
+        node:goto_menu("trivia")
+    elseif key == "V" or key == "v" then
+        view_recordings(node)
+        node:goto_menu("sysop_menu")
+    elseif key == "F" or key == "f" then
+        search_recordings(node)
+        node:goto_menu("sysop_menu")
     elseif key == "Q" or key == "q" then
         node:goto_menu("main_menu")
     end
@@ -75,11 +76,131 @@ function node_control(node)
                 u.node_id, u.name, status)
             node:sendln(line)
         end
+
+        node:sendln("")
+        local input = node:ask("  Spy on node # (Enter to skip): ", 3)
+        local target = tonumber(input or "")
+        if target then
+            local takeover = node:yesno("  Start in takeover mode?")
+            local err = node:spy(target, takeover)
+            if err then
+                node:sendln("  Spy failed: " .. err)
+            end
+        end
     end
     node:sendln("")
     node:pause()
 end
 
+function view_recordings(node)
+    node:sendln("")
+    node:sendln("  -- Session Recordings --")
+    node:sendln("")
+
+    if recordings == nil then
+        node:sendln("  Session recording is off (recording.enabled in the config).")
+        node:pause()
+        return
+    end
+    local list = recordings.list()
+    if list == nil or #list == 0 then
+        node:sendln("  No recordings yet.")
+        node:pause()
+        return
+    end
+
+    node:sendln("  #   Node  User              Started           Length")
+    node:sendln("  --- ----  ----------------  ----------------  ------")
+    local shown = math.min(#list, 15)
+    for i = 1, shown do
+        local r = list[i]
+        node:sendln(string.format("  %-3d %-4d  %-16s  %s  %3d:%02d",
+            i, r.node, r.user ~= "" and r.user or "(no login)", r.started,
+            math.floor(r.seconds / 60), r.seconds % 60))
+    end
+    node:sendln("")
+    local pick = tonumber(node:ask("  Play # (Enter to skip): ", 3) or "")
+    if pick == nil or list[pick] == nil or pick > shown then
+        return
+    end
+    local speed = tonumber(node:ask("  Speed (1-8, Enter for 1): ", 1) or "") or 1
+    node:sendln("  Press any key to stop.")
+    node:pause(2)
+    node:cls()
+    local _, err = recordings.play(list[pick].name, speed)
+    node:sendln("\27[0m")
+    if err then
+        node:sendln("  Playback failed: " .. err)
+    end
+    node:pause()
+end
+
+function search_recordings(node)
+    node:sendln("")
+    node:sendln("  -- Find in Recordings --")
+    node:sendln("")
+
+    if recordings == nil then
+        node:sendln("  Session recording is off (recording.enabled in the config).")
+        node:pause()
+        return
+    end
+    node:sendln("  Searches look into callers' sessions. Each one is logged")
+    node:sendln("  with your name and reason.")
+    node:sendln("")
+    local reason = node:ask("  Reason (e.g. report number): ", 60)
+    if reason == nil or reason == "" then
+        return
+    end
+    local who = node:ask("  User (Enter for anyone): ", 30) or ""
+    local from = node:ask("  From date YYYY-MM-DD (Enter for any): ", 10) or ""
+    local to = node:ask("  To date YYYY-MM-DD (Enter for any): ", 10) or ""
+    local keyword = node:ask("  Text shown (Enter for any): ", 40) or ""
+
+    local ok, hits, err = pcall(recordings.search, {
+        reason = reason, user = who, from = from, to = to, keyword = keyword, limit = 15,
+    })
+    if not ok then
+        err = hits
+        hits = nil
+    end
+    node:sendln("")
+    if hits == nil then
+        node:sendln("  Search failed: " .. tostring(err))
+        node:pause()
+        return
+    end
+    if #hits == 0 then
+        node:sendln("  Nothing found.")
+        node:pause()
+        return
+    end
+
+    for i, h in ipairs(hits) do
+        local where = string.format("%s node %d %s", h.started, h.node, h.user ~= "" and h.user or "(no login)")
+        if h.text ~= "" then
+            node:sendln(string.format("  %-3d %s +%d:%02d", i, where, math.floor(h.at / 60), h.at % 60))
+            node:sendln("      " .. string.sub(h.text, 1, 70))
+        else
+            node:sendln(string.format("  %-3d %s", i, where))
+        end
+    end
+    node:sendln("")
+    local pick = tonumber(node:ask("  Play # (Enter to skip): ", 3) or "")
+    if pick == nil or hits[pick] == nil then
+        return
+    end
+    node:sendln("  Press any key to stop.")
+    node:pause(2)
+    node:cls()
+    local _, perr = recordings.play(hits[pick].name, 4)
+    node:sendln("\27[0m")
+    if perr then
+        node:sendln("  Playback failed: " .. perr)
+    end
+    node:pause()
+end
+
 function system_stats(node)
     node:sendln("")
     node:sendln("  -- System Statistics --")
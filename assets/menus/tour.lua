@@ -0,0 +1,52 @@
+-- tour.lua - Guided tour of the board for new callers
+-- Started after login on a caller's first call (onboarding.first_call) and
+-- with gosub_menu("tour") from the account settings. The stops come from
+-- the onboarding section of the config.
+local menu = {}
+
+local function finish(node)
+    node:cls()
+    if node:args() ~= nil then
+        node:return_menu()
+    else
+        node:goto_menu("main_menu")
+    end
+end
+
+function menu.on_enter(node)
+    local steps = tour.steps()
+    local i = 1
+    while i <= #steps do
+        local st = steps[i]
+        node:cls()
+        if st.art ~= "" then
+            node:display(st.art)
+        end
+        node:sendln("")
+        node:sendln("  -- Tour " .. i .. "/" .. #steps .. ": " .. st.title .. " --")
+        if st.text ~= "" then
+            node:sendln("")
+            for line in (st.text .. "\n"):gmatch("(.-)\r?\n") do
+                node:sendln("  " .. line)
+            end
+        end
+        node:sendln("")
+        if i > 1 then
+            node:send("  [Enter] Next  [B]ack  [S]kip tour: ")
+        else
+            node:send("  [Enter] Next  [S]kip tour: ")
+        end
+        local key = string.upper(node:getkey() or "")
+        node:sendln("")
+        if key == "S" or key == "Q" then
+            break
+        elseif key == "B" then
+            i = math.max(i - 1, 1)
+        else
+            i = i + 1
+        end
+    end
+    finish(node)
+end
+
+return menu
@@ -0,0 +1,109 @@
+-- trivia.lua - Host a trivia night as a live event
+-- Entered from the sysop menu. Other callers type /join to play; each
+-- question is open for a while, the first right answer scores 3 points and
+-- every other right answer 1.
+local menu = {}
+
+-- Only sysops may host.
+menu.meta = { level = 100 }
+
+local SECONDS = 20
+
+local questions = {
+    { q = "What does BBS stand for?", a = { "bulletin board system" } },
+    { q = "Which protocol did Chuck Forsberg write in 1986: Xmodem, Ymodem or Zmodem?", a = { "zmodem" } },
+    { q = "What was the name of Tom Jennings' BBS software and network?", a = { "fido", "fidonet" } },
+    { q = "How many bits per second is a 14.4 modem?", a = { "14400", "14,400" } },
+    { q = "Which drop file does a DOOR.SYS door read: DOOR.SYS or DORINFO1.DEF?", a = { "door.sys" } },
+}
+
+local function normalize(s)
+    return (s:lower():gsub("^%s+", ""):gsub("%s+$", ""):gsub("%s+", " "))
+end
+
+local function is_right(question, text)
+    text = normalize(text)
+    for _, a in ipairs(question.a) do
+        if text == a then
+            return true
+        end
+    end
+    return false
+end
+
+local function show_scores(node, ev)
+    local lines = { "", "  -- Scores --" }
+    for i, s in ipairs(ev:scores()) do
+        lines[#lines + 1] = string.format("  %2d. %-20s %d", i, s.name, s.points)
+    end
+    lines[#lines + 1] = ""
+    local text = table.concat(lines, "\n")
+    ev:broadcast(text)
+    for _, line in ipairs(lines) do
+        node:sendln(line)
+    end
+end
+
+function menu.on_enter(node)
+    node:cls()
+
+    local ev, err = live.host("trivia", "Trivia Night")
+    if ev == nil then
+        node:sendln("\r\n  Could not start trivia: " .. err)
+        node:pause()
+        node:goto_menu("sysop_menu")
+        return
+    end
+
+    local told = ev:announce("Trivia Night is starting! Type /join trivia to play.")
+    node:sendln("")
+    node:sendln("  Trivia Night is open; " .. told .. " callers were told.")
+    node:sendln("  Waiting up to 2 minutes for the first player...")
+    if ev:wait(1, 120) == 0 then
+        node:sendln("  Nobody joined.")
+        ev:close()
+        node:pause()
+        node:goto_menu("sysop_menu")
+        return
+    end
+    node:ask("  Press Enter to start once everyone is in: ", 1)
+
+    for i, question in ipairs(questions) do
+        local first = nil
+        node:sendln("")
+        node:sendln(string.format("  Q%d: %s", i, question.q))
+        local _, err = ev:ask(string.format("\n  Question %d of %d (%d seconds):\n  %s",
+            i, #questions, SECONDS, question.q), {
+            seconds = SECONDS,
+            on_answer = function(a)
+                local right = is_right(question, a.text)
+                node:sendln(string.format("    %-16s %-30s %s", a.name, a.text, right and "right" or ""))
+                if right then
+                    if first == nil then
+                        first = a.name
+                        ev:score(a.name, 3)
+                    else
+                        ev:score(a.name, 1)
+                    end
+                end
+            end,
+        })
+        if err ~= nil then
+            break
+        end
+        local result = "  Time's up! The answer was: " .. question.a[1]
+        if first ~= nil then
+            result = result .. ". " .. first .. " was first."
+        end
+        ev:broadcast(result)
+        node:sendln(result)
+    end
+
+    show_scores(node, ev)
+    ev:broadcast("  Thanks for playing!")
+    ev:close()
+    node:pause()
+    node:goto_menu("sysop_menu")
+end
+
+return menu
@@ -1,11 +1,92 @@
--- user_stats.lua - Display current user's statistics
+-- user_stats.lua - Display current user's statistics and edit the profile
+-- others see with /finger
 local menu = {}
 
 function menu.on_load(node)
     node:cls()
 end
 
+-- read_lines reads up to max lines, ending at an empty line.
+local function read_lines(node, max, width)
+    local lines = {}
+    while #lines < max do
+        local line = node:ask(string.format("  %2d: ", #lines + 1), width)
+        if line == nil or line == "" then
+            break
+        end
+        table.insert(lines, line)
+    end
+    return table.concat(lines, "\n")
+end
+
+local function edit_bio(node, p)
+    node:sendln("")
+    node:sendln("  Tell other callers about yourself (up to 10 lines, empty line ends).")
+    local bio = read_lines(node, 10, 70)
+    local err = users.update_bio(bio, p.signature)
+    if err ~= nil then
+        node:sendln("  " .. err .. ".")
+    else
+        node:sendln("  Bio saved.")
+    end
+end
+
+local function edit_signature(node, p)
+    node:sendln("")
+    node:sendln("  Signature for your messages (up to 3 lines, empty line ends).")
+    local sig = read_lines(node, 3, 76)
+    local err = users.update_bio(p.bio, sig)
+    if err ~= nil then
+        node:sendln("  " .. err .. ".")
+    else
+        node:sendln("  Signature saved.")
+    end
+end
+
+local PRIVATE = {
+    { key = "real_name", label = "Real name" },
+    { key = "location", label = "Location" },
+    { key = "last_seen", label = "Last seen" },
+    { key = "stats", label = "Calls, posts and transfers" },
+}
+
+local function edit_privacy(node, p)
+    node:sendln("")
+    node:sendln("  Choose what other callers see on your profile. Sysops see everything.")
+    local hide = {}
+    for _, f in ipairs(PRIVATE) do
+        hide[f.key] = not node:yesno(string.format("  Show %s? ", f.label))
+    end
+    local err = users.set_privacy(hide)
+    if err ~= nil then
+        node:sendln("  " .. err .. ".")
+    else
+        node:sendln("  Privacy settings saved.")
+    end
+end
+
 function menu.on_enter(node)
+    local me = users.get_current()
+    local p = me and users.profile(me.name)
+    if p == nil then
+        node:pause()
+        node:goto_menu("main_menu")
+        return
+    end
+
+    node:send("  [B]io, [S]ignature, [P]rivacy or [Q]uit: ")
+    local key = string.upper(node:getkey() or "")
+    node:sendln("")
+    if key == "B" then
+        edit_bio(node, p)
+    elseif key == "S" then
+        edit_signature(node, p)
+    elseif key == "P" then
+        edit_privacy(node, p)
+    else
+        node:goto_menu("main_menu")
+        return
+    end
     node:pause()
     node:goto_menu("main_menu")
 end
@@ -1,8 +1,125 @@
 -- welcome.lua - Pre-login welcome screen
+-- Waits for a key on the welcome art. When nobody presses one for IDLE
+-- seconds the node goes into attract mode: it cycles through ANSImations
+-- in menus/attract/, bulletin previews and today's stats, like an arcade
+-- cabinet, until a key is pressed, then shows the login prompt.
 local menu = {}
 
+local IDLE = 30     -- seconds on the welcome screen before attract mode
+local SHOW = 10     -- seconds each attract screen stays up
+local BAUD = 2400   -- speed ANSImations draw in at
+
+local function footer(node)
+    node:sendln("")
+    node:send("  Press any key to log in...")
+end
+
+-- hold waits on a finished screen: key, nil (no key) or nil, err.
+local function hold(node)
+    footer(node)
+    return node:poll_key(SHOW * 1000)
+end
+
+-- Each screen draws itself and returns the key pressed, nil when it ran
+-- its time without one, false when it has nothing to show, or nil, err
+-- when the caller has gone.
+local next_bulletin = 1
+
+local screens = {
+    -- The welcome art again, drawn in slowly
+    function(node)
+        node:cls()
+        local key = node:animate("welcome", BAUD)
+        if key ~= nil then
+            return key
+        end
+        return hold(node)
+    end,
+
+    -- A random ANSImation
+    function(node)
+        node:cls()
+        local key, err = node:animate("attract/*", BAUD)
+        if err ~= nil then
+            return false
+        end
+        if key ~= nil then
+            return key
+        end
+        return hold(node)
+    end,
+
+    -- One bulletin at a time
+    function(node)
+        local list = bulletins ~= nil and bulletins.list() or nil
+        if list == nil or #list == 0 then
+            return false
+        end
+        if next_bulletin > #list then
+            next_bulletin = 1
+        end
+        local b = list[next_bulletin]
+        next_bulletin = next_bulletin + 1
+
+        node:cls()
+        node:sendln("")
+        node:sendln("  -- Bulletin: " .. b.title .. " (" .. b.date .. ") --")
+        node:sendln("")
+        local shown = 0
+        for line in (b.body .. "\n"):gmatch("(.-)\r?\n") do
+            if shown >= node.height - 8 then
+                node:sendln("  ...")
+                break
+            end
+            node:sendln("  " .. line)
+            shown = shown + 1
+        end
+        return hold(node)
+    end,
+
+    -- Today's numbers and the top callers
+    function(node)
+        local today = stats ~= nil and stats.today() or nil
+        if today == nil then
+            return false
+        end
+        node:cls()
+        node:sendln("")
+        node:sendln("  -- Today on the board (" .. today.date .. ") --")
+        node:sendln("")
+        node:sendln(string.format("  Calls: %-6d New users: %-6d Posts: %d",
+            today.calls, today.new_users, today.posts))
+        node:sendln(string.format("  Uploads: %-4d Downloads: %-6d Doors: %d",
+            today.uploads, today.downloads, today.door_launches))
+        local top = stats.top_callers(5)
+        if top ~= nil and #top > 0 then
+            node:sendln("")
+            node:sendln("  Top callers:")
+            for i, c in ipairs(top) do
+                node:sendln(string.format("  %d. %-20s %5d calls", i, c.name, c.calls))
+            end
+        end
+        return hold(node)
+    end,
+}
+
+local function attract(node)
+    local i = 0
+    while true do
+        i = i % #screens + 1
+        local key, err = screens[i](node)
+        if key or err ~= nil then
+            return
+        end
+    end
+end
+
 function menu.on_enter(node)
-    node:pause(2)
+    footer(node)
+    local key, err = node:poll_key(IDLE * 1000)
+    if key == nil and err == nil then
+        attract(node)
+    end
     node:cls()
     node:goto_menu("login")
 end
@@ -5,10 +5,8 @@ function menu.on_enter(node)
     node:pause(2)
     node:cls()
 
-    local username = node:preauth_username()
-    local password = node:preauth_password()
-
-    if username == "" or password == "" then
+    local username = node.ssh_username
+    if username == "" then
         node:goto_menu("welcome")
         return
     end
@@ -16,7 +14,11 @@ function menu.on_enter(node)
     node:sendln("")
     node:sendln("  Attempting auto-login as: " .. username)
 
-    local user, err = users.login(username, password)
+    local user, err = users.login_preauth()
+    if err == "totp required" or err == "totp enrollment required" then
+        node:goto_menu("login_totp")
+        return
+    end
     if user == nil then
         node:sendln("")
         node:sendln("  Invalid SSH credentials.")
@@ -28,8 +30,7 @@ function menu.on_enter(node)
         return
     end
 
-    node:sendln("")
-    node:sendln("  Welcome back, " .. user.name .. "!")
+    -- The greeting was shown as part of login (greetings.at_login).
     node:sendln("  Security level: " .. user.level)
     node:sendln("  Total calls: " .. user.calls)
     if user.last_on then
@@ -38,7 +39,7 @@ function menu.on_enter(node)
     node:sendln("")
     node:pause(2)
     node:cls()
-    node:goto_menu("main_menu")
+    node:goto_menu("bulletins")
 end
 
 return menu
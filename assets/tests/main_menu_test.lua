@@ -0,0 +1,63 @@
+-- main_menu_test.lua - Tests for the main menu
+-- Run with: bbsctl script test assets/tests
+return {
+    script = "../menus/main_menu.lua",
+    user = { id = 2, name = "alice", level = 10 },
+    tests = {
+        {
+            name = "G logs off",
+            call = "on_key", args = { "G" },
+            calls = { { "node:goto_menu", "goodbye" } },
+        },
+        {
+            name = "a macro comes before the menu's keys",
+            call = "on_key", args = { "G" },
+            returns = { ["node:macro"] = true },
+            not_called = { "node:goto_menu" },
+        },
+        {
+            name = "W pages the node typed",
+            call = "on_key", args = { "W" },
+            input = { "3" },
+            returns = {
+                ["chat.page"] = function(id)
+                    return "private-" .. id
+                end,
+            },
+            calls = {
+                { "node:show_online", false },
+                { "chat.page", 3 },
+                { "node:enter_chat", "private-3" },
+            },
+            output = { "Paging node 3" },
+        },
+        {
+            name = "W without chat only lists who is on",
+            call = "on_key", args = { "W" },
+            calls = { { "node:show_online" }, { "node:goto_menu", "main_menu" } },
+            not_called = { "node:ask" },
+        },
+        {
+            name = "/sysop is refused below level 100",
+            call = "on_input", args = { "/sysop" },
+            output = { "/sysop: unknown command", "/who" },
+            calls = { { "node:goto_menu", "main_menu" } },
+            check = function(run)
+                for _, c in ipairs(run.calls) do
+                    assert(c.args[1] ~= "sysop_menu", "went to the sysop menu")
+                end
+            end,
+        },
+        {
+            name = "/sysop opens the sysop menu for the sysop",
+            call = "on_input", args = { "/sysop" },
+            user = { id = 1, name = "sysop", level = 100 },
+            calls = { { "node:goto_menu", "sysop_menu" } },
+        },
+        {
+            name = "/quit logs off",
+            call = "on_input", args = { "/q" },
+            calls = { { "node:goto_menu", "goodbye" } },
+        },
+    },
+}
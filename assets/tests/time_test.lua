@@ -0,0 +1,12 @@
+-- time_test.lua - Tests for the /time command
+return {
+    script = "../commands/time.lua",
+    tests = {
+        {
+            name = "shows the time and waits",
+            call = "run", args = { "" },
+            output = { "It is " },
+            calls = { { "node:pause" } },
+        },
+    },
+}
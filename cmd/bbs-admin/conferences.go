@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/notepid/twilight_bbs/internal/admin/app"
+	"github.com/notepid/twilight_bbs/internal/conference"
+)
+
+// runConferences prints the conferences.
+func runConferences(a *app.App, args []string) error {
+	confs, err := a.Confs.All()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tLEVEL\tFLAGS\tSORT\tMSG AREAS\tFILE AREAS\tDESCRIPTION")
+	for _, c := range confs {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%d\t%d\t%d\t%s\n", c.ID, c.Name, c.JoinLevel, c.Flags,
+			c.SortOrder, c.MsgAreas, c.FileAreas, c.Description)
+	}
+	return w.Flush()
+}
+
+// runSetConference adds a conference or changes an existing one.
+func runSetConference(a *app.App, args []string) error {
+	fs := flag.NewFlagSet("set-conference", flag.ContinueOnError)
+	id := fs.Int("id", 0, "conference to change, 0 to add one")
+	name := fs.String("name", "", "conference name")
+	desc := fs.String("desc", "", "one-line description")
+	level := fs.Int("level", 10, "lowest security level that may join")
+	flags := fs.String("flags", "", "group flags a member must hold")
+	sort := fs.Int("sort", 0, "position in conference lists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c := &conference.Conference{ID: *id, Name: *name, Description: *desc,
+		JoinLevel: *level, Flags: *flags, SortOrder: *sort}
+	saved, err := a.Confs.Save(c)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved conference %d (%s).\n", saved, c.Name)
+	return nil
+}
+
+// runDeleteConference removes a conference.
+func runDeleteConference(a *app.App, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: bbs-admin delete-conference N")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("conference %q: not a number", args[0])
+	}
+	if err := a.Confs.Delete(id); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Deleted conference %d; its areas are on the main board.\n", id)
+	return nil
+}
+
+// runAssignArea moves message and file areas into a conference.
+func runAssignArea(a *app.App, args []string) error {
+	fs := flag.NewFlagSet("assign-area", flag.ContinueOnError)
+	conf := fs.Int("conf", 0, "conference to move the areas into")
+	msgArea := fs.Int("msg", 0, "message area to move")
+	fileArea := fs.Int("file", 0, "file area to move")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *conf == 0 || (*msgArea == 0 && *fileArea == 0) {
+		return fmt.Errorf("usage: bbs-admin assign-area -conf N [-msg A] [-file A]")
+	}
+	if *msgArea != 0 {
+		if err := a.Confs.AssignMessageArea(*msgArea, *conf); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Message area %d is in conference %d.\n", *msgArea, *conf)
+	}
+	if *fileArea != 0 {
+		if err := a.Confs.AssignFileArea(*fileArea, *conf); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "File area %d is in conference %d.\n", *fileArea, *conf)
+	}
+	return nil
+}
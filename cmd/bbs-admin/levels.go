@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/notepid/twilight_bbs/internal/admin/app"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// runLevels prints the security level table.
+func runLevels(a *app.App, args []string) error {
+	levels, err := a.Users.Levels()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LEVEL\tNAME\tCOLOR\tTIME\tRATIO\tFLAGS")
+	for _, l := range levels {
+		limit, ratio := "-", "board"
+		if l.TimeLimit > 0 {
+			limit = fmt.Sprintf("%dm", l.TimeLimit)
+		}
+		switch {
+		case l.Ratio < 0:
+			ratio = "exempt"
+		case l.Ratio > 0:
+			ratio = fmt.Sprintf("1:%d", l.Ratio)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\n", l.Value, l.Name, l.Color, limit, ratio, l.Flags)
+	}
+	return w.Flush()
+}
+
+// runSetLevel adds or replaces an entry of the security level table.
+func runSetLevel(a *app.App, args []string) error {
+	fs := flag.NewFlagSet("set-level", flag.ContinueOnError)
+	value := fs.Int("value", -1, "security level, 0-100")
+	name := fs.String("name", "", "level name")
+	color := fs.Int("color", 7, "color the name is shown in, 0-15")
+	limit := fs.Int("time", 0, "minutes per call, 0 for the board's limit")
+	ratio := fs.Int("ratio", 0, "download ratio, 0 for the board's, -1 for exempt")
+	flags := fs.String("flags", "", "flags granted when a user is set to this level")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	l := user.Level{Value: *value, Name: *name, Color: *color, TimeLimit: *limit, Ratio: *ratio, Flags: *flags}
+	if err := a.Users.SaveLevel(l); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved level %d (%s).\n", l.Value, l.Name)
+	return nil
+}
+
+// runDeleteLevel removes an entry of the security level table.
+func runDeleteLevel(a *app.App, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: bbs-admin delete-level N")
+	}
+	value, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("level %q: not a number", args[0])
+	}
+	if err := a.Users.DeleteLevel(value); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Deleted level %d.\n", value)
+	return nil
+}
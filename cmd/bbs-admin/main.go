@@ -3,18 +3,59 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"log"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/notepid/twilight_bbs/internal/admin/app"
 	"github.com/notepid/twilight_bbs/internal/admin/ui"
+	"github.com/notepid/twilight_bbs/internal/control"
 )
 
+const usage = `usage: bbs-admin [-config file] [-remote socket] [command]
+
+Without a command, bbs-admin opens the full-screen admin UI.
+
+Commands:
+  export-messages -area N [-format mbox|json] [-o file]
+                      write a message area as an archive
+  import-messages -area N [-format mbox|json] [-user name] <file|->
+                      add an archive's messages to an area
+  levels              list the security level table
+  set-level -value N -name X [-color C] [-time M] [-ratio R] [-flags F]
+                      add or change a security level
+  delete-level N      remove a security level
+  conferences         list the conferences
+  set-conference [-id N] -name X [-desc D] [-level L] [-flags F] [-sort S]
+                      add a conference, or change conference N
+  delete-conference N remove a conference; its areas move to the main board
+  assign-area -conf N [-msg A] [-file A]
+                      move a message or file area into a conference
+
+Flags:
+`
+
 func main() {
 	configPath := flag.String("config", "config.yaml", "path to configuration file")
+	remote := flag.String("remote", "", "manage a running BBS through its control socket instead of the database")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	if flag.NArg() > 0 {
+		runCommand(*configPath, flag.Arg(0), flag.Args()[1:])
+		return
+	}
+
+	if *remote != "" {
+		runRemote(*remote)
+		return
+	}
+
 	a, cleanup, err := app.New(*configPath)
 	if err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
@@ -22,9 +63,70 @@ func main() {
 	}
 	defer cleanup()
 
+	// Log output would draw over the full-screen UI.
+	log.SetOutput(io.Discard)
+
 	p := tea.NewProgram(ui.NewRootModel(a), tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
+
+// runRemote runs the admin UI against a running BBS over its control
+// socket.
+func runRemote(socket string) {
+	c, err := control.Dial(socket)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer c.Close()
+
+	p := tea.NewProgram(ui.NewRemoteModel(c), tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// runCommand runs a command-line task against the database and exits.
+func runCommand(configPath, name string, args []string) {
+	var run func(*app.App, []string) error
+	switch name {
+	case "export-messages":
+		run = runExportMessages
+	case "import-messages":
+		run = runImportMessages
+	case "levels":
+		run = runLevels
+	case "set-level":
+		run = runSetLevel
+	case "delete-level":
+		run = runDeleteLevel
+	case "conferences":
+		run = runConferences
+	case "set-conference":
+		run = runSetConference
+	case "delete-conference":
+		run = runDeleteConference
+	case "assign-area":
+		run = runAssignArea
+	default:
+		fmt.Fprintf(os.Stderr, "bbs-admin: unknown command %q\n\n", name)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	a, cleanup, err := app.New(configPath)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	err = run(a, args)
+	cleanup()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bbs-admin: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/notepid/twilight_bbs/internal/admin/app"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+// runExportMessages writes one message area as an mbox or JSON archive.
+func runExportMessages(a *app.App, args []string) error {
+	fs := flag.NewFlagSet("export-messages", flag.ContinueOnError)
+	areaID := fs.Int("area", 0, "message area ID")
+	format := fs.String("format", message.FormatJSON, "archive format: mbox or json")
+	out := fs.String("o", "-", "output file, - for standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	area, err := a.Messages.GetArea(*areaID)
+	if err != nil {
+		return fmt.Errorf("area %d: %w", *areaID, err)
+	}
+	msgs, err := a.Messages.MessagesAfter(area.ID, 0)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := message.ExportArchive(w, *format, area.Name, message.ToArchive(msgs)); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d message(s) from %s.\n", len(msgs), area.Name)
+	return nil
+}
+
+// runImportMessages adds the messages of an mbox or JSON archive to a
+// message area.
+func runImportMessages(a *app.App, args []string) error {
+	fs := flag.NewFlagSet("import-messages", flag.ContinueOnError)
+	areaID := fs.Int("area", 0, "message area ID")
+	format := fs.String("format", message.FormatJSON, "archive format: mbox or json")
+	owner := fs.String("user", "sysop", "account that owns messages from authors without one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: bbs-admin import-messages -area N [-format mbox|json] [-user name] <file|->")
+	}
+	area, err := a.Messages.GetArea(*areaID)
+	if err != nil {
+		return fmt.Errorf("area %d: %w", *areaID, err)
+	}
+	u, err := a.Users.GetByUsername(*owner)
+	if err != nil {
+		return fmt.Errorf("user %s: %w", *owner, err)
+	}
+
+	var r io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	msgs, err := message.ReadArchive(r, *format)
+	if err != nil {
+		return err
+	}
+	n, err := a.Messages.ImportArchive(area.ID, u.ID, msgs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d message(s) into %s.\n", n, area.Name)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxHeldLog bounds the log output held while the local console is open.
+const maxHeldLog = 1 << 20
+
+// heldLog is the log's terminal output. While the local console has the
+// terminal, log lines are held back so they do not scribble over the
+// session, and written out when it closes.
+type heldLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	held    *bytes.Buffer // nil when not holding
+	dropped int           // lines past maxHeldLog
+}
+
+func (h *heldLog) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.held == nil {
+		return h.w.Write(p)
+	}
+	if h.held.Len()+len(p) > maxHeldLog {
+		h.dropped++
+		return len(p), nil
+	}
+	return h.held.Write(p)
+}
+
+// hold starts holding log lines back.
+func (h *heldLog) hold() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.held == nil {
+		h.held = new(bytes.Buffer)
+	}
+}
+
+// release writes out the held lines and stops holding.
+func (h *heldLog) release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.held == nil {
+		return
+	}
+	h.w.Write(h.held.Bytes())
+	if h.dropped > 0 {
+		fmt.Fprintf(h.w, "(%d more log lines were dropped while the local console was open)\n", h.dropped)
+	}
+	h.held, h.dropped = nil, 0
+}
@@ -1,34 +1,76 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/notepid/twilight_bbs/internal/announce"
 	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/bulletin"
+	"github.com/notepid/twilight_bbs/internal/callers"
 	"github.com/notepid/twilight_bbs/internal/chat"
+	"github.com/notepid/twilight_bbs/internal/cleanup"
 	"github.com/notepid/twilight_bbs/internal/config"
+	"github.com/notepid/twilight_bbs/internal/control"
+	"github.com/notepid/twilight_bbs/internal/dashboard"
 	"github.com/notepid/twilight_bbs/internal/db"
 	"github.com/notepid/twilight_bbs/internal/door"
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/feed"
 	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/flood"
+	"github.com/notepid/twilight_bbs/internal/forum"
+	"github.com/notepid/twilight_bbs/internal/gopher"
+	"github.com/notepid/twilight_bbs/internal/greeting"
+	"github.com/notepid/twilight_bbs/internal/health"
+	"github.com/notepid/twilight_bbs/internal/mailer"
+	"github.com/notepid/twilight_bbs/internal/mailgate"
 	"github.com/notepid/twilight_bbs/internal/menu"
 	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/nntp"
 	"github.com/notepid/twilight_bbs/internal/node"
+	"github.com/notepid/twilight_bbs/internal/preflight"
+	"github.com/notepid/twilight_bbs/internal/recording"
+	"github.com/notepid/twilight_bbs/internal/schedule"
+	"github.com/notepid/twilight_bbs/internal/scripting"
 	"github.com/notepid/twilight_bbs/internal/server"
+	"github.com/notepid/twilight_bbs/internal/sftp"
+	"github.com/notepid/twilight_bbs/internal/sshexec"
+	"github.com/notepid/twilight_bbs/internal/stats"
+	"github.com/notepid/twilight_bbs/internal/status"
 	"github.com/notepid/twilight_bbs/internal/terminal"
 	"github.com/notepid/twilight_bbs/internal/transfer"
 	"github.com/notepid/twilight_bbs/internal/user"
+	"github.com/notepid/twilight_bbs/internal/weblink"
 )
 
 func main() {
 	configPath := flag.String("config", "config.yaml", "path to configuration file")
+	local := flag.Bool("local", false, "open a node on this terminal for the sysop, beside the listeners")
 	flag.Parse()
+	startedAt := time.Now()
+
+	// A listener or server that fails is fatal, except with a local
+	// console: it must keep working when the ports are misconfigured.
+	serverDown := log.Fatalf
+	if *local {
+		serverDown = log.Printf
+	}
 
 	// Load configuration
 	cfg, err := config.Load(*configPath)
@@ -36,6 +78,17 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// The dashboard shows the latest log lines. The local console holds
+	// back those for the terminal while it is open.
+	logOut := &heldLog{w: os.Stderr}
+	var logBuffer *dashboard.LogBuffer
+	if cfg.Dashboard.Enabled {
+		logBuffer = dashboard.NewLogBuffer(logLines)
+		log.SetOutput(io.MultiWriter(logOut, logBuffer))
+	} else {
+		log.SetOutput(logOut)
+	}
+
 	// Ensure data directory exists
 	if err := os.MkdirAll(cfg.Paths.Data, 0755); err != nil {
 		log.Fatalf("Failed to create data directory: %v", err)
@@ -58,18 +111,107 @@ func main() {
 
 	// Create repositories
 	userRepo := user.NewRepo(database.DB)
+	userRepo.SetPasswordPolicy(passwordPolicy(cfg))
+	userRepo.SetTOTPPolicy(totpPolicy(cfg, bbsSettings.Name))
+	userRepo.SetSettingsQuota(cfg.Scripting.StoreKeys)
 	messageRepo := message.NewRepo(database.DB)
 	fileRepo := filearea.NewRepo(database.DB)
+	bulletinRepo := bulletin.NewRepo(database.DB)
+	callerLog := callers.NewRepo(database.DB)
+
+	// Session-end hooks run from each node's deferred cleanup, so they
+	// also fire when the caller drops carrier.
+	events := event.NewBus()
+	events.Subscribe(event.Logoff, callerLog.HandleLogoff)
+	statsRepo := stats.NewRepo(database.DB)
+	statsRepo.Subscribe(events)
+
+	// Flood control, shared by all nodes. Refusals are logged as security
+	// events.
+	floodLimiter := flood.New(floodRules(cfg), cfg.Flood.ExemptLevel)
+	events.Subscribe(event.Flood, func(ev event.Event) {
+		if v, ok := ev.Data.(*flood.Violation); ok {
+			log.Printf("Security: node %d: %s exceeded the %s limit, refused for %s",
+				ev.NodeID, v.Username, v.Action, v.Retry.Round(time.Second))
+		}
+	})
+
+	// Outgoing mail: reset codes, new-mail notices and sysop alerts, sent
+	// from a queue in the database.
+	var outMail *mailer.Mailer
+	if sc := cfg.SMTP; sc.Enabled {
+		outMail, err = mailer.New(database.DB, mailer.Config{
+			Server:    sc.Server,
+			TLS:       sc.TLS,
+			Username:  sc.Username,
+			Password:  sc.Password,
+			From:      sc.From,
+			Sysop:     sc.SysopAddress,
+			BBSName:   bbsSettings.Name,
+			Templates: sc.Templates,
+			Retries:   sc.Retries,
+		})
+		if err != nil {
+			log.Fatalf("Mail: %v", err)
+		}
+		if outMail.CanAlertSysop() {
+			events.Subscribe(event.NewUser, func(ev event.Event) {
+				u, ok := ev.Data.(*user.User)
+				if !ok {
+					return
+				}
+				err := outMail.NotifySysop("new_user", map[string]any{
+					"User": u.Username, "Location": u.Location, "Level": u.SecurityLevel,
+				})
+				if err != nil {
+					log.Printf("Mail: new user alert: %v", err)
+				}
+			})
+		}
+	}
 
 	// Create chat broker
 	chatBroker := chat.NewBroker()
+	if cfg.Chat.HistoryLines > 0 {
+		chatBroker.SetHistory(chat.NewHistory(database.DB, cfg.Chat.HistoryLines,
+			time.Duration(cfg.Chat.HistoryDays)*24*time.Hour))
+	}
 
 	// Create door launcher
+	doorsTmpDir := filepath.Join(cfg.Paths.Data, "doors_tmp")
+	uploadTmpDir := filepath.Join(cfg.Paths.Data, "upload_tmp")
+	archiveTmpDir := filepath.Join(cfg.Paths.Data, "archive_tmp")
 	doorLauncher := door.NewLauncher(
 		cfg.Doors.DosemuPath,
 		cfg.Doors.DriveC,
-		filepath.Join(cfg.Paths.Data, "doors_tmp"),
+		doorsTmpDir,
 	)
+	doorLauncher.DropFileTTL = time.Duration(cfg.Doors.DropFileTTL) * time.Second
+	doorLauncher.TranslateOutput = cfg.Doors.TranslateOutput
+	if sb := cfg.Doors.Sandbox; sb.Enabled {
+		doorLauncher.Sandbox = &door.Sandbox{
+			UID:        sb.UID,
+			GID:        sb.GID,
+			Network:    sb.Network,
+			Overlay:    sb.Overlay,
+			MemoryMB:   sb.MemoryMB,
+			CPUPercent: sb.CPUPercent,
+			MaxPids:    sb.MaxPids,
+		}
+		doorLauncher.CgroupRoot = sb.Cgroup
+		doorLauncher.OverlayDir = filepath.Join(cfg.Paths.Data, "doors_overlay")
+	}
+
+	// Session recording
+	var recordings *recording.Store
+	if rc := cfg.Recording; rc.Enabled {
+		dir := rc.Dir
+		if dir == "" {
+			dir = filepath.Join(cfg.Paths.Data, "recordings")
+		}
+		recordings = recording.NewStore(dir, rc.Keep, int64(rc.MaxSizeKB)*1024)
+		recordings.MaxAge = time.Duration(rc.MaxAgeDays) * 24 * time.Hour
+	}
 
 	// Create menu registry and scan for menus
 	menuRegistry := menu.NewRegistry(cfg.Paths.Menus)
@@ -77,42 +219,285 @@ func main() {
 		log.Fatalf("Failed to scan menus: %v", err)
 	}
 	log.Printf("Loaded %d menus from %s", len(menuRegistry.List()), cfg.Paths.Menus)
+	commands := menu.NewCommands(cfg.Paths.Commands)
+	if err := commands.Scan(); err != nil {
+		log.Fatalf("Failed to scan commands: %v", err)
+	}
 
 	// Create ANSI display file loader
 	ansiLoader := ansi.NewLoader(cfg.Paths.Menus, cfg.Paths.Text)
 
 	// Create transfer config for ZMODEM file transfers
 	transferConfig := &transfer.Config{
-		SexyzPath: cfg.Transfer.SexyzPath,
+		SexyzPath:  cfg.Transfer.SexyzPath,
+		StagingDir: uploadTmpDir,
 	}
-	if transferConfig.Available() {
-		log.Printf("File transfer: SEXYZ found at %s", cfg.Transfer.SexyzPath)
-	} else {
-		log.Printf("File transfer: SEXYZ not found at %s (file transfers disabled)", cfg.Transfer.SexyzPath)
+	for _, pc := range cfg.Transfer.Protocols {
+		transferConfig.Extra = append(transferConfig.Extra, transfer.Protocol{
+			Key:        pc.Key,
+			Name:       pc.Name,
+			Command:    pc.Command,
+			Send:       pc.Send,
+			Receive:    pc.Receive,
+			Env:        pc.Env,
+			TelnetFlag: pc.TelnetFlag,
+		})
+	}
+
+	// One-time HTTP download links, served on the health server. A completed
+	// download is counted like a ZMODEM one: the file's download count, the
+	// day's statistics and the user's download total, logged as a node 0
+	// call like SFTP sessions.
+	var webLinks *weblink.Store
+	if cfg.Transfer.WebLinks {
+		baseURL := cfg.Transfer.WebBaseURL
+		if baseURL == "" {
+			baseURL = fmt.Sprintf("http://localhost:%d", cfg.Server.HealthPort)
+		}
+		webLinks, err = weblink.New(baseURL, time.Duration(cfg.Transfer.WebLinkMinutes)*time.Minute)
+		if err != nil {
+			log.Fatalf("Web downloads: %v", err)
+		}
+		webLinks.Complete = func(g weblink.Grant, remote string) {
+			if err := fileRepo.IncrementDownload(g.EntryID); err != nil {
+				log.Printf("Web download: %v", err)
+			}
+			if err := userRepo.AddTransfer(g.UserID, 0, g.Size); err != nil {
+				log.Printf("Web download: %v", err)
+			}
+			events.Publish(event.Event{Name: event.Download, Data: 1})
+		}
+	}
+
+	// Download ratio, on the BBS and over SFTP/SCP
+	ratio := filearea.Ratio{
+		PerUpload:   cfg.Transfer.Ratio,
+		FreeKB:      cfg.Transfer.RatioFreeKB,
+		ExemptLevel: cfg.Transfer.RatioExemptLevel,
+	}
+
+	// Per-session limits on Lua menu scripts
+	scriptLimits := scripting.Limits{
+		RegistrySize:  cfg.Scripting.RegistrySize,
+		CallStackSize: cfg.Scripting.CallStackSize,
+	}
+
+	greetings, err := greeting.New(greetingSet(cfg))
+	if err != nil {
+		log.Fatalf("Greetings: %v", err)
+	}
+	tour := onboardingTour(cfg)
+
+	// Verify door and transfer prerequisites up front so missing pieces are
+	// reported now rather than failing mid-session.
+	preflightReport := preflight.Run(preflight.Options{
+		DosemuPath: cfg.Doors.DosemuPath,
+		SexyzPath:  cfg.Transfer.SexyzPath,
+		DriveC:     cfg.Doors.DriveC,
+		DoorsTmp:   doorsTmpDir,
+		UploadTmp:  uploadTmpDir,
+	})
+	for _, line := range preflightReport.Lines() {
+		log.Printf("Preflight: %s", line)
 	}
 
 	// Create node manager
 	nodeMgr := node.NewManager(bbsSettings.MaxNodes, bbsSettings.Name, bbsSettings.Sysop)
+	nodeMgr.ReplaceSettings(nodeSettings(cfg, bbsSettings.MaxNodes))
+	nodeMgr.SetQueueSize(cfg.WaitingRoom.Size)
 
-	// handleConnection wires up a new node session from any connection type.
-	handleConnection := func(term *terminal.Terminal, remoteAddr, username, password string) {
-		nodeID, ok := nodeMgr.Acquire()
-		if !ok {
-			term.SendLn("Sorry, all nodes are busy. Please try again later.")
-			term.Close()
-			return
+	// Clean up temp data left behind by crashed sessions, then keep sweeping
+	// on a schedule. Per-node dirs of live nodes are never touched.
+	maxAge := time.Duration(cfg.Cleanup.MaxAge) * time.Minute
+	maxBytes := int64(cfg.Cleanup.MaxSizeMB) << 20
+	sweeper := cleanup.NewSweeper(
+		cleanup.Policy{Dir: doorsTmpDir, Pattern: "node*", MaxAge: maxAge, MaxBytes: maxBytes},
+		cleanup.Policy{Dir: filepath.Join(cfg.Doors.DriveC, "NODES"), MaxAge: maxAge, MaxBytes: maxBytes},
+		cleanup.Policy{Dir: uploadTmpDir, Pattern: "upload-*", MaxAge: maxAge, MaxBytes: maxBytes},
+		cleanup.Policy{Dir: archiveTmpDir, Pattern: "view-*", MaxAge: maxAge, MaxBytes: maxBytes},
+	)
+	sweeper.InUse = func(name string) bool {
+		id, ok := door.NodeOfTempDir(name)
+		return ok && nodeMgr.Get(id) != nil
+	}
+	if stats := sweeper.Purge(); stats.Entries > 0 {
+		log.Printf("Cleanup: removed %d leftover temp entries (%d bytes)", stats.Entries, stats.Bytes)
+	}
+	stopSweeper := make(chan struct{})
+	defer close(stopSweeper)
+	if cfg.Cleanup.Interval > 0 {
+		go sweeper.Run(time.Duration(cfg.Cleanup.Interval)*time.Minute, stopSweeper)
+	}
+
+	// Nightly maintenance. Later maintenance tasks register here too.
+	maintHour, maintMinute, _ := schedule.ParseClock(cfg.Maintenance.Time)
+	scheduler := schedule.New()
+	if cfg.Maintenance.PurgeMessages {
+		scheduler.Daily("message purge", maintHour, maintMinute, func() error {
+			report, err := messageRepo.PurgeExpired(message.PurgeOptions{Archive: cfg.Maintenance.ArchiveMessages})
+			if err != nil {
+				return err
+			}
+			for _, line := range report.Lines() {
+				log.Printf("Maintenance: %s", line)
+			}
+			return nil
+		})
+	}
+	if fs := cfg.FileStats; fs.KeepDays > 0 {
+		scheduler.Daily("download log retention", maintHour, maintMinute, func() error {
+			n, err := fileRepo.PruneDownloads(time.Now().AddDate(0, 0, -fs.KeepDays))
+			if n > 0 {
+				log.Printf("Maintenance: removed %d old download log entries", n)
+			}
+			return err
+		})
+	}
+	if cfg.FileStats.Bulletin {
+		refresh := func() error { return topDownloadsBulletin(cfg.FileStats, fileRepo, bulletinRepo) }
+		if err := refresh(); err != nil {
+			log.Printf("Top downloads: %v", err)
+		}
+		scheduler.Every("top downloads", time.Hour, refresh)
+	}
+	if a := cfg.Announce; a.Enabled {
+		bridge, err := newAnnounceBridge(a, bbsSettings.Name, cfg.Server.HealthPort, database.DB, messageRepo)
+		if err != nil {
+			log.Fatalf("Announcements: %v", err)
+		}
+		scheduler.Every("announce", time.Duration(a.Interval)*time.Minute, func() error {
+			n, err := bridge.Run()
+			if n > 0 {
+				log.Printf("Announcements: posted %d message(s)", n)
+			}
+			return err
+		})
+	}
+	if m := cfg.Maintenance; m.ExpireInactiveDays > 0 || m.PurgeDeletedDays > 0 {
+		scheduler.Daily("account expiry", maintHour, maintMinute, func() error {
+			expired, err := userRepo.ExpireInactive(m.ExpireInactiveDays, m.ExpireExemptLevel)
+			if err != nil {
+				return err
+			}
+			purged, err := userRepo.PurgeDeleted(m.PurgeDeletedDays)
+			if expired > 0 || purged > 0 {
+				log.Printf("Maintenance: expired %d inactive accounts, purged %d deleted accounts", expired, purged)
+			}
+			return err
+		})
+	}
+	if m := cfg.Maintenance; m.IntegrityCheck || m.Analyze || m.Vacuum {
+		scheduler.Daily("database upkeep", maintHour, maintMinute, func() error {
+			return databaseUpkeep(database, m)
+		})
+	}
+	if m := cfg.Maintenance; m.CheckpointMinutes > 0 {
+		scheduler.Every("wal checkpoint", time.Duration(m.CheckpointMinutes)*time.Minute, func() error {
+			_, err := database.Checkpoint()
+			return err
+		})
+	}
+	if h := chatBroker.History(); h != nil && cfg.Chat.HistoryDays > 0 {
+		scheduler.Daily("chat history retention", maintHour, maintMinute, func() error {
+			n, err := h.Prune()
+			if n > 0 {
+				log.Printf("Maintenance: removed %d old chat lines", n)
+			}
+			return err
+		})
+	}
+	if recordings != nil && recordings.MaxAge > 0 {
+		scheduler.Daily("recording retention", maintHour, maintMinute, func() error {
+			recordings.Prune()
+			return nil
+		})
+	}
+	var newsGateway *nntp.Gateway
+	if cfg.NNTP.Enabled {
+		gw, err := nntpGateway(cfg, bbsSettings.Name, userRepo, database, messageRepo)
+		if err != nil {
+			log.Fatalf("NNTP gateway: %v", err)
 		}
+		newsGateway = gw
+		newsGateway.Events = events
+		scheduler.Every("nntp sync", time.Duration(cfg.NNTP.Interval)*time.Minute, func() error {
+			_, err := syncNews(newsGateway)
+			return err
+		})
+	}
+	if cfg.Email.Enabled {
+		mailGateway, err := emailGateway(cfg, userRepo, database, messageRepo, fileRepo)
+		if err != nil {
+			log.Fatalf("Email gateway: %v", err)
+		}
+		mailGateway.Events = events
+		mailGateway.Notify = func(username, text string) { chatBroker.NotifyUser(username, text) }
+		scheduler.Every("email poll", time.Duration(cfg.Email.Interval)*time.Minute, func() error {
+			report, err := mailGateway.Sync()
+			if report != nil && *report != (mailgate.Report{}) {
+				for _, line := range report.Lines() {
+					log.Printf("Email: %s", line)
+				}
+			}
+			return err
+		})
+	}
+	if outMail != nil {
+		scheduler.Every("mail queue", time.Minute, func() error {
+			_, err := outMail.Flush()
+			return err
+		})
+		if cfg.SMTP.DiskWarnMB > 0 && outMail.CanAlertSysop() {
+			disks := &mailer.DiskWatch{
+				Mailer:  outMail,
+				Paths:   []string{cfg.Paths.Data, filepath.Dir(cfg.Paths.Database)},
+				MinFree: int64(cfg.SMTP.DiskWarnMB) << 20,
+			}
+			scheduler.Every("disk space", 30*time.Minute, disks.Check)
+		}
+	}
+	scheduler.Every("flood prune", time.Hour, func() error {
+		floodLimiter.Prune()
+		return nil
+	})
+	for _, line := range scheduler.Describe(time.Now()) {
+		log.Printf("Schedule: %s", line)
+	}
+	go scheduler.Run(stopSweeper)
+
+	// Set while a shutdown drains the nodes; new calls are turned away
+	// meanwhile.
+	var draining atomic.Bool
 
+	// startNode runs a session on a node the caller has been given.
+	startNode := func(nodeID int, term *terminal.Terminal, remoteAddr, username, password string) {
 		n := node.NewNode(nodeID, term, remoteAddr)
 		n.MenuRegistry = menuRegistry
 		n.ANSILoader = ansiLoader
 		n.UserRepo = userRepo
 		n.MessageRepo = messageRepo
 		n.FileRepo = fileRepo
+		n.BulletinRepo = bulletinRepo
+		n.Events = events
+		n.Flood = floodLimiter
 		n.ChatBroker = chatBroker
 		n.DoorLauncher = doorLauncher
 		n.TransferConfig = transferConfig
+		n.ArchiveDir = archiveTmpDir
+		n.Ratio = ratio
 		n.DB = database.DB
+		n.ScriptLimits = scriptLimits
+		n.SharedVM = cfg.Scripting.SharedVM
+		n.Mailer = outMail
+		n.ResetCodeTTL = time.Duration(cfg.SMTP.ResetMinutes) * time.Minute
+		n.ScriptAlerts = cfg.SMTP.ScriptAlerts
+		n.ChatHistory = cfg.Chat.HistoryShow
+		n.Greetings = greetings
+		n.GreetAtLogin = cfg.Greetings.AtLogin
+		n.Tour = tour
+		n.Commands = commands
+		n.Recordings = recordings
+		n.WebLinks = webLinks
 		n.PreAuthUsername = username
 		n.PreAuthPassword = password
 
@@ -120,8 +505,59 @@ func main() {
 		n.Run(nodeMgr)
 	}
 
-	// --- Telnet server ---
-	telnetListener := server.NewListener(cfg.Server.TelnetPort, func(tc *server.TelnetConn) {
+	// handleConnection wires up a new node session from any connection type.
+	handleConnection := func(term *terminal.Terminal, remoteAddr, username, password string) {
+		if draining.Load() {
+			term.SendLn("Sorry, the board is going down for maintenance. Please try again later.")
+			term.Close()
+			return
+		}
+
+		// Returning users (known up front via SSH) get their previous node
+		// back when it is free; some doors key state off the node number.
+		// Their level also decides whether reserved nodes may be used.
+		preferredNode := 0
+		level := node.LevelUnknown
+		if username != "" {
+			if u, err := userRepo.GetByUsername(username); err == nil {
+				preferredNode = u.LastNode
+				level = u.SecurityLevel
+			}
+		}
+
+		nodeID, err := nodeMgr.AcquireFor(preferredNode, level)
+		if errors.Is(err, node.ErrAllNodesBusy) {
+			if ticket, qerr := nodeMgr.Enqueue(remoteAddr, preferredNode, level); qerr == nil {
+				wr := cfg.WaitingRoom
+				nodeID, err = ticket.Wait(term, time.Duration(wr.MaxWait)*time.Minute, time.Duration(wr.Update)*time.Second)
+				if err != nil {
+					log.Printf("Waiting room: %s left: %v", remoteAddr, err)
+					switch {
+					case errors.Is(err, node.ErrWaitTimeout):
+						term.SendLn("Sorry, no node came free in time. Please try again later.")
+					case errors.Is(err, node.ErrQueueClosed):
+						term.SendLn("Sorry, the board is going down for maintenance. Please try again later.")
+					}
+					term.Close()
+					return
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, node.ErrNodesReserved) {
+				term.SendLn("Sorry, the board is full. The remaining nodes are reserved. Please try again later.")
+			} else {
+				term.SendLn("Sorry, all nodes are busy. Please try again later.")
+			}
+			term.Close()
+			return
+		}
+
+		startNode(nodeID, term, remoteAddr, username, password)
+	}
+
+	// --- Telnet and SSH listeners ---
+	telnetHandler := func(tc *server.TelnetConn) {
 		// Negotiate telnet options (echo, SGA, NAWS, terminal type)
 		if err := tc.Negotiate(); err != nil {
 			log.Printf("Telnet negotiation error from %s: %v", tc.RemoteAddr(), err)
@@ -131,42 +567,203 @@ func main() {
 
 		term := terminal.New(tc, tc.Width, tc.Height, tc.ANSICapable)
 		term.SetEchoControl(tc.SetEcho)
+		term.TermColors = terminal.DetectColorDepth(tc.TermType, "")
+
+		// Ask the client itself for ANSI support and, without NAWS, its
+		// screen size; TTYPE alone is often missing or wrong.
+		if p := term.Detect(terminal.DefaultProbeTimeout); p.ANSI {
+			log.Printf("Telnet %s: ANSI detected, %dx%d", tc.RemoteAddr(), term.Width, term.Height)
+		}
 
 		handleConnection(term, tc.RemoteAddr().String(), "", "")
-	})
 
-	go func() {
-		if err := telnetListener.ListenAndServe(); err != nil {
-			log.Fatalf("Telnet server error: %v", err)
+		if plain, wire := tc.CompressionStats(); plain > 0 {
+			log.Printf("Telnet %s: MCCP2 sent %d bytes as %d", tc.RemoteAddr(), plain, wire)
 		}
-	}()
+	}
 
-	// --- SSH server ---
 	hostKeyPath := filepath.Join(cfg.Paths.Data, "ssh_host_key")
 	sshAuthenticator := user.NewSSHAuthenticator(userRepo)
-	sshListener, err := server.NewSSHListener(cfg.Server.SSHPort, hostKeyPath, sshAuthenticator, func(sc *server.SSHConn, remoteAddr, username, password string) {
+	execRunner := sshexec.New(userRepo, messageRepo)
+	execRunner.Events = events
+	// fileSession serves one SFTP or SCP session over the file areas.
+	// These sessions have no node and are not calls; what they moved is
+	// added to the user's transfer totals.
+	fileSession := func(username, kind string, serve func(fsys *sftp.AreaFS) error) error {
+		u, err := userRepo.GetByUsername(username)
+		if err != nil {
+			return err
+		}
+		opts := sftp.AreaOptions{
+			Uploads:    cfg.Transfer.SFTPUploads,
+			StagingDir: uploadTmpDir,
+			Ratio:      ratio,
+		}
+		if webLinks != nil {
+			opts.Pending = webLinks.Pending
+		}
+		fsys := sftp.NewAreaFS(fileRepo, u, opts)
+		err = serve(fsys)
+		up, down := fsys.Transferred()
+		filesUp, filesDown := fsys.Files()
+		if filesUp > 0 {
+			events.Publish(event.Event{Name: event.Upload, Data: filesUp})
+		}
+		if filesDown > 0 {
+			events.Publish(event.Event{Name: event.Download, Data: filesDown})
+		}
+		if up > 0 || down > 0 {
+			if aerr := userRepo.AddTransfer(u.ID, up, down); aerr != nil {
+				log.Printf("%s session for %s: %v", strings.ToUpper(kind), u.Username, aerr)
+			}
+		}
+		return err
+	}
+	sftpHandler := func(username, remoteAddr string, ch io.ReadWriter) {
+		err := fileSession(username, "sftp", func(fsys *sftp.AreaFS) error {
+			return sftp.Serve(ch, fsys)
+		})
+		if err != nil {
+			log.Printf("SFTP session for %s from %s: %v", username, remoteAddr, err)
+		}
+	}
+	scpHandler := func(username, remoteAddr, command string, ch io.ReadWriter, stderr io.Writer) int {
+		err := fileSession(username, "scp", func(fsys *sftp.AreaFS) error {
+			return sftp.ServeSCP(command, ch, fsys)
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "scp: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	sshHandler := func(sc *server.SSHConn, remoteAddr, username, password string) {
 		term := terminal.New(sc, sc.Width, sc.Height, sc.ANSICapable)
+		term.TermColors = terminal.DetectColorDepth(sc.TermType, sc.ColorTerm)
 
 		handleConnection(term, remoteAddr, username, password)
-	})
-	if err != nil {
-		log.Fatalf("Failed to create SSH listener: %v", err)
 	}
 
-	go func() {
-		if err := sshListener.ListenAndServe(); err != nil {
-			log.Fatalf("SSH server error: %v", err)
+	var limiters []*server.RateLimiter
+	var listening []health.Listener
+	for _, lc := range cfg.Listeners {
+		opts := server.Options{
+			MaxPerIP:    lc.MaxPerIP,
+			Compress:    lc.Compress,
+			IdleTimeout: time.Duration(lc.IdleTimeout) * time.Minute,
+			Rate: server.RateLimits{
+				Burst:      lc.RateBurst,
+				Max:        lc.RateMax,
+				Window:     time.Duration(lc.RateWindow) * time.Second,
+				AcceptRate: lc.AcceptRate,
+			},
+			SSH: server.SSHAlgorithms{
+				Legacy:       cfg.Server.SSH.LegacyCiphers,
+				KeyExchanges: cfg.Server.SSH.KeyExchanges,
+				Ciphers:      cfg.Server.SSH.Ciphers,
+				MACs:         cfg.Server.SSH.MACs,
+			},
 		}
-	}()
+		if lc.TLSCert != "" {
+			cert, err := tls.LoadX509KeyPair(lc.TLSCert, lc.TLSKey)
+			if err != nil {
+				log.Fatalf("Failed to load TLS certificate for %s: %v", lc.Addr(), err)
+			}
+			opts.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		}
+
+		var serve func() error
+		switch lc.Type {
+		case config.ListenerTelnet:
+			telnetListener := server.NewListener(lc.Addr(), opts, telnetHandler)
+			limiters = append(limiters, telnetListener.Limiter())
+			listening = append(listening, health.Listener{Type: string(lc.Type), Addr: lc.Addr(), Listening: telnetListener.Listening})
+			serve = telnetListener.ListenAndServe
+		case config.ListenerSSH:
+			sshListener, err := server.NewSSHListener(lc.Addr(), opts, hostKeyPath, sshAuthenticator, sshHandler)
+			if err != nil {
+				log.Fatalf("Failed to create SSH listener: %v", err)
+			}
+			sshListener.SetExecHandler(func(req server.ExecRequest, stdin io.Reader, stdout, stderr io.Writer) int {
+				return execRunner.Run(req.Username, req.Command, stdin, stdout, stderr)
+			})
+			if cfg.Transfer.SFTP {
+				sshListener.SetSFTPHandler(sftpHandler)
+				sshListener.SetSCPHandler(scpHandler)
+			}
+			limiters = append(limiters, sshListener.Limiter())
+			listening = append(listening, health.Listener{Type: string(lc.Type), Addr: lc.Addr(), Listening: sshListener.Listening})
+			serve = sshListener.ListenAndServe
+		}
+
+		go func(lc config.ListenerConfig) {
+			if err := serve(); err != nil {
+				serverDown("%s server error on %s: %v", lc.Type, lc.Addr(), err)
+			}
+		}(lc)
+	}
+
+	// --- Gopher front-end ---
+	if cfg.Gopher.Enabled {
+		gopherServer := &gopher.Server{
+			Name:      bbsSettings.Name,
+			Host:      cfg.Gopher.Hostname,
+			Port:      cfg.Gopher.Port,
+			Bulletins: bulletinRepo,
+			Messages:  messageRepo,
+			Art:       ansiLoader,
+			TextDir:   cfg.Paths.Text,
+			Areas:     cfg.Gopher.MessageAreas,
+		}
+		go func() {
+			if err := gopherServer.ListenAndServe(cfg.Gopher.Addr()); err != nil {
+				serverDown("Gopher server error: %v", err)
+			}
+		}()
+	}
 
 	// --- Health server ---
 	healthMux := http.NewServeMux()
-	healthMux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+	checker := &health.Checker{
+		DB:        database.DB,
+		Nodes:     nodeMgr.Count,
+		Capacity:  bbsSettings.MaxNodes,
+		Listeners: listening,
+		Scheduler: scheduler,
+		Doors:     doorLauncher.Available,
+		Draining:  draining.Load,
+		Started:   startedAt,
+		Details:   preflightReport.Lines,
+	}
+	healthMux.HandleFunc("/healthz", checker.ServeHealth)
+	healthMux.HandleFunc("/readyz", checker.ServeReady)
+
+	healthMux.HandleFunc("/cleanupz", func(w http.ResponseWriter, r *http.Request) {
+		total, sweeps := sweeper.Totals()
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
+		fmt.Fprintf(w, "sweeps %d\nentries_removed %d\nbytes_reclaimed %d\n", sweeps, total.Entries, total.Bytes)
 	})
 
+	healthMux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = server.WriteMetrics(w, limiters)
+	})
+
+	healthMux.Handle("/forum/", forum.New(bbsSettings.Name, messageRepo))
+	if webLinks != nil {
+		healthMux.Handle(weblink.Prefix, webLinks)
+	}
+
+	if len(cfg.Feeds.Areas) > 0 {
+		baseURL := cfg.Feeds.BaseURL
+		if baseURL == "" {
+			baseURL = fmt.Sprintf("http://localhost:%d", cfg.Server.HealthPort)
+		}
+		feeds := feed.New(bbsSettings.Name, baseURL, messageRepo, cfg.Feeds.Areas, cfg.Feeds.Items)
+		events.Subscribe(event.Post, feeds.HandlePost)
+		healthMux.Handle("/feeds/", feeds)
+	}
+
 	healthServer := &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.Server.HealthPort),
 		Handler:           healthMux,
@@ -175,29 +772,481 @@ func main() {
 
 	go func() {
 		if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Health server error: %v", err)
+			serverDown("Health server error: %v", err)
 		}
 	}()
 
+	// --- Control socket ---
+	type shutdownRequest struct {
+		drain time.Duration
+		msg   string
+	}
+	shutdownCh := make(chan shutdownRequest, 1)
+	// The dashboard uses the same server as the socket, so it is set up
+	// even when the socket is off.
+	controlServer := &control.Server{
+		Nodes:    nodeMgr,
+		Stats:    statsRepo,
+		DB:       database,
+		MaxNodes: bbsSettings.MaxNodes,
+		Started:  startedAt,
+		ReloadMenus: func() (int, error) {
+			if err := menuRegistry.Scan(); err != nil {
+				return 0, err
+			}
+			if err := commands.Scan(); err != nil {
+				return 0, err
+			}
+			return len(menuRegistry.List()), nil
+		},
+		ReloadConfig: func() (*control.Reload, error) {
+			return reloadConfig(*configPath, cfg, userRepo, nodeMgr, floodLimiter, bbsSettings)
+		},
+		Shutdown: func(drain time.Duration, msg string) {
+			select {
+			case shutdownCh <- shutdownRequest{drain, msg}:
+			default: // already shutting down
+			}
+		},
+		Draining: draining.Load,
+	}
+	if newsGateway != nil {
+		controlServer.NNTPSync = func() ([]string, error) { return syncNews(newsGateway) }
+	}
+	if cfg.Server.ControlSocket != "" {
+		controlListener, err := control.Listen(cfg.Server.ControlSocket)
+		if err != nil {
+			log.Fatalf("Failed to open control socket: %v", err)
+		}
+		defer controlListener.Close()
+		go func() {
+			if err := controlServer.Serve(controlListener); err != nil {
+				log.Printf("Control socket error: %v", err)
+			}
+		}()
+	}
+
+	// Public status on the health server, with the control socket's figures.
+	if cfg.PublicStats.Enabled {
+		publicStats, err := status.New(bbsSettings.Name, controlServer, userRepo, cfg.PublicStats.Fields)
+		if err != nil {
+			log.Fatalf("Public stats: %v", err)
+		}
+		healthMux.Handle("/api/public/", publicStats)
+	}
+
+	// --- Sysop dashboard ---
+	if dc := cfg.Dashboard; dc.Enabled {
+		dashServer := &http.Server{
+			Addr:              dc.Addr(),
+			Handler:           dashboard.New(bbsSettings.Name, controlServer, callerLog, userRepo, logBuffer, dc.MinLevel, time.Duration(dc.Session)*time.Minute),
+			ReadHeaderTimeout: 2 * time.Second,
+		}
+		go func() {
+			if err := dashServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serverDown("Dashboard server error: %v", err)
+			}
+		}()
+	}
+
 	// --- Graceful shutdown ---
 	fmt.Printf("\n%s is running\n", bbsSettings.Name)
-	fmt.Printf("  Telnet: port %d\n", cfg.Server.TelnetPort)
-	fmt.Printf("  SSH:    port %d\n", cfg.Server.SSHPort)
+	for _, lc := range cfg.Listeners {
+		fmt.Printf("  %-7s %s\n", strings.ToUpper(lc.Type)+":", lc.Addr())
+	}
+	if cfg.Gopher.Enabled {
+		fmt.Printf("  Gopher: %s\n", cfg.Gopher.Addr())
+	}
 	fmt.Printf("  Health: port %d\n", cfg.Server.HealthPort)
+	if cfg.Dashboard.Enabled {
+		fmt.Printf("  Dashboard: http://%s/\n", cfg.Dashboard.Addr())
+	}
+	if recordings != nil {
+		fmt.Printf("  Recording: %s\n", recordings.Dir)
+	}
+	if cfg.Server.ControlSocket != "" {
+		fmt.Printf("  Control: %s\n", cfg.Server.ControlSocket)
+	}
 	fmt.Printf("  Nodes:  0/%d\n", bbsSettings.MaxNodes)
-	fmt.Println("\nPress Ctrl+C to shut down.")
+	fmt.Println("\nPress Ctrl+C to shut down (twice to skip the drain).")
+
+	// --- Local console ---
+	// The sysop's own node on this terminal, outside the node limit and
+	// the listeners. The board keeps running when it closes.
+	if *local {
+		lc, err := server.NewLocalConn(os.Stdin, os.Stdout)
+		if err != nil {
+			log.Printf("Local console: %v", err)
+		} else {
+			go func() {
+				logOut.hold()
+				term := terminal.New(lc, lc.Width, lc.Height, true)
+				term.TermColors = terminal.DetectColorDepth(lc.TermType, lc.ColorTerm)
+				startNode(nodeMgr.AcquireLocal(), term, "local console", "", "")
+				logOut.release()
+				fmt.Println("\nLocal console closed. Press Ctrl+C to shut down.")
+			}()
+		}
+	}
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-sigCh
-
-	log.Printf("Received signal %v, shutting down...", sig)
+	countdown := time.Duration(cfg.Shutdown.Countdown) * time.Second
+	grace := time.Duration(cfg.Shutdown.Grace) * time.Second
+	var msg string
+	select {
+	case sig := <-sigCh:
+		log.Printf("Received signal %v, shutting down...", sig)
+	case req := <-shutdownCh:
+		log.Printf("Shutdown requested on the control socket (drain %v)", req.drain)
+		countdown, msg = req.drain, req.msg
+		if countdown == 0 {
+			grace = 0
+			if msg != "" {
+				nodeMgr.Broadcast(msg)
+			}
+		}
+	}
+	if countdown+grace > 0 && nodeMgr.Count() > 0 {
+		draining.Store(true)
+		log.Printf("Draining %d node(s): countdown %v, grace %v", nodeMgr.Count(), countdown, grace)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case sig := <-sigCh:
+				log.Printf("Received signal %v while draining, shutting down now", sig)
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		nodeMgr.Drain(ctx, countdown, grace, msg)
+		cancel()
+	}
 
 	// Notify all connected nodes
 	nodeMgr.Broadcast("System is shutting down NOW. Goodbye!")
 	for _, n := range nodeMgr.List() {
 		n.Disconnect()
 	}
+	logOut.release()
 
 	log.Printf("%s shut down complete.", bbsSettings.Name)
 }
+
+// logLines is how many log lines the dashboard keeps.
+const logLines = 200
+
+// passwordPolicy returns the password policy from the config.
+// floodRules converts the flood config section into limiter rules.
+func floodRules(cfg *config.Config) map[string]flood.Rule {
+	return map[string]flood.Rule{
+		flood.Post: {Max: cfg.Flood.PostsPerHour, Window: time.Hour},
+		flood.Chat: {Max: cfg.Flood.ChatPer10s, Window: 10 * time.Second},
+		flood.File: {Max: cfg.Flood.FilesPerDay, Window: 24 * time.Hour},
+	}
+}
+
+func passwordPolicy(cfg *config.Config) user.PasswordPolicy {
+	return user.PasswordPolicy{
+		MinLength:  cfg.Passwords.MinLength,
+		MinClasses: cfg.Passwords.MinClasses,
+		BanCommon:  cfg.Passwords.BanCommon,
+		BcryptCost: cfg.Passwords.BcryptCost,
+	}
+}
+
+// totpPolicy returns the two-factor policy from the config.
+func totpPolicy(cfg *config.Config, bbsName string) user.TOTPPolicy {
+	return user.TOTPPolicy{
+		Issuer:       bbsName,
+		RequireLevel: cfg.TwoFactor.RequireLevel,
+	}
+}
+
+// nodeSettings returns the per-node overrides from the config.
+func nodeSettings(cfg *config.Config, maxNodes int) map[int]node.Settings {
+	settings := make(map[int]node.Settings)
+	for _, nc := range cfg.Nodes {
+		if nc.ID > maxNodes {
+			log.Printf("Warning: config for node %d ignored (max_nodes is %d)", nc.ID, maxNodes)
+			continue
+		}
+		minLevel := nc.MinLevel
+		if nc.Reserved && minLevel < user.LevelSysop {
+			minLevel = user.LevelSysop
+		}
+		settings[nc.ID] = node.Settings{
+			Name:      nc.Name,
+			MinLevel:  minLevel,
+			TimeLimit: time.Duration(nc.TimeLimit) * time.Minute,
+		}
+	}
+	return settings
+}
+
+// greetingSet converts the greetings config section.
+func greetingSet(cfg *config.Config) greeting.Set {
+	gc := cfg.Greetings
+	msg := func(m config.GreetingMessage) greeting.Message {
+		return greeting.Message{Text: m.Text, Art: m.Art}
+	}
+	set := greeting.Set{
+		Birthday:    msg(gc.Birthday),
+		FirstCall:   msg(gc.FirstCall),
+		Return:      msg(gc.Return),
+		AbsenceDays: gc.AbsenceDays,
+	}
+	for _, p := range gc.Periods {
+		set.Periods = append(set.Periods, greeting.Period{From: p.From, Message: msg(p.GreetingMessage)})
+	}
+	for _, h := range gc.Holidays {
+		set.Holidays = append(set.Holidays, greeting.Holiday{Date: h.Date, Until: h.Until, Message: msg(h.GreetingMessage)})
+	}
+	return set
+}
+
+// onboardingTour converts the onboarding config section.
+func onboardingTour(cfg *config.Config) *scripting.Tour {
+	tour := &scripting.Tour{FirstCall: cfg.Onboarding.FirstCall}
+	for _, st := range cfg.Onboarding.Steps {
+		tour.Steps = append(tour.Steps, scripting.TourStep{Title: st.Title, Art: st.Art, Text: st.Text})
+	}
+	return tour
+}
+
+// nntpGateway sets up the newsgroup gateway. Imported articles belong to
+// the configured account, which is created locked (no login) if missing.
+func nntpGateway(cfg *config.Config, bbsName string, userRepo *user.Repo, database *db.DB, messageRepo *message.Repo) (*nntp.Gateway, error) {
+	owner, err := userRepo.GetByUsername(cfg.NNTP.User)
+	if err != nil {
+		if owner, err = userRepo.CreateLocked(cfg.NNTP.User); err != nil {
+			return nil, err
+		}
+		log.Printf("NNTP: created account %s for imported articles", owner.Username)
+	}
+	org := cfg.NNTP.Organization
+	if org == "" {
+		org = bbsName
+	}
+	gc := nntp.Config{
+		Server:       cfg.NNTP.Server,
+		TLS:          cfg.NNTP.TLS,
+		Username:     cfg.NNTP.Username,
+		Password:     cfg.NNTP.Password,
+		Domain:       cfg.NNTP.Domain,
+		Organization: org,
+		UserID:       owner.ID,
+		MaxArticles:  cfg.NNTP.MaxArticles,
+		Backfill:     cfg.NNTP.Backfill,
+	}
+	for _, g := range cfg.NNTP.Groups {
+		gc.Groups = append(gc.Groups, nntp.Group{AreaID: g.Area, Newsgroup: g.Newsgroup, Post: g.Post})
+	}
+	return nntp.NewGateway(database.DB, messageRepo, gc), nil
+}
+
+// topDownloadsBulletin renders the top downloads and saves them as the
+// body of the top downloads bulletin, creating it the first time. Callers
+// who read it are not shown it again when it changes.
+func topDownloadsBulletin(fs config.FileStatsConfig, files *filearea.Repo, bulletins *bulletin.Repo) error {
+	period, _ := filearea.ParsePeriod(fs.Period)
+	var text string
+	if fs.Template != "" {
+		data, err := os.ReadFile(fs.Template)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+	now := time.Now()
+	top, err := files.TopDownloads(fs.Top, period, now, fs.Level)
+	if err != nil {
+		return err
+	}
+	title := fs.Title
+	if title == "" {
+		title = filearea.TopTitle(fs.Top, period)
+	}
+	body, err := filearea.RenderTop(text, filearea.TopReport{Title: title, Period: period, Files: top, Generated: now})
+	if err != nil {
+		return err
+	}
+
+	b, err := bulletins.GetByTitle(title)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		_, err = bulletins.Create(&bulletin.Bulletin{Title: title, Body: body, PublishedAt: now})
+		return err
+	}
+	b.Body = body
+	return bulletins.Update(b)
+}
+
+// newAnnounceBridge creates the announcement bridge from its config,
+// reading the post template file if one is set.
+func newAnnounceBridge(a config.AnnounceConfig, bbsName string, healthPort int, database *sql.DB, msgs *message.Repo) (*announce.Bridge, error) {
+	var text string
+	if a.Template != "" {
+		data, err := os.ReadFile(a.Template)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	baseURL := a.BaseURL
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%d", healthPort)
+	}
+	return announce.New(database, msgs, announce.Config{
+		Area:       a.Area,
+		Kind:       a.Kind,
+		Server:     a.Server,
+		Outbox:     a.Outbox,
+		Actor:      a.Actor,
+		Token:      a.Token,
+		Visibility: a.Visibility,
+		Template:   text,
+		MaxLength:  a.MaxLength,
+		MaxPerHour: a.MaxPerHour,
+		Replies:    a.Replies,
+		BBS:        bbsName,
+		BaseURL:    baseURL,
+	})
+}
+
+// emailGateway sets up the email gateway. Mail is delivered from the
+// configured account, which is created locked (no login) if missing.
+func emailGateway(cfg *config.Config, userRepo *user.Repo, database *db.DB, messageRepo *message.Repo, fileRepo *filearea.Repo) (*mailgate.Gateway, error) {
+	ec := cfg.Email
+	owner, err := userRepo.GetByUsername(ec.User)
+	if err != nil {
+		if owner, err = userRepo.CreateLocked(ec.User); err != nil {
+			return nil, err
+		}
+		log.Printf("Email: created account %s for inbound mail", owner.Username)
+	}
+	if ec.QuarantineArea > 0 {
+		area, err := fileRepo.GetArea(ec.QuarantineArea)
+		if err != nil {
+			return nil, fmt.Errorf("quarantine area: %w", err)
+		}
+		if area.DownloadLevel < user.LevelCoSysop {
+			log.Printf("Email: warning: quarantine area %q can be downloaded at level %d", area.Name, area.DownloadLevel)
+		}
+	}
+	users := make(map[string]string, len(ec.Users))
+	for local, name := range ec.Users {
+		users[strings.ToLower(local)] = name
+	}
+	return mailgate.NewGateway(database.DB, messageRepo, userRepo, fileRepo, mailgate.Config{
+		Server:         ec.Server,
+		TLS:            ec.TLS,
+		Username:       ec.Username,
+		Password:       ec.Password,
+		Mailbox:        ec.Mailbox,
+		Address:        ec.Address,
+		UserID:         owner.ID,
+		Users:          users,
+		MapUsernames:   ec.MapUsernames,
+		FeedbackArea:   ec.FeedbackArea,
+		QuarantineArea: ec.QuarantineArea,
+		MaxAttachment:  int64(ec.MaxAttachmentKB) << 10,
+		MaxPerSender:   ec.MaxPerSender,
+		MaxMessages:    ec.MaxMessages,
+		Delete:         ec.Delete,
+	}), nil
+}
+
+// databaseUpkeep runs the nightly database jobs m turns on. A database that
+// fails its integrity check is not vacuumed, so a damaged file is left as
+// it is for the sysop to look at.
+func databaseUpkeep(database *db.DB, m config.MaintenanceConfig) error {
+	if m.IntegrityCheck {
+		problems, err := database.Integrity()
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			log.Printf("Maintenance: database integrity: %s", p)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("integrity check found %d problem(s)", len(problems))
+		}
+	}
+	if m.Analyze {
+		if err := database.Analyze(); err != nil {
+			return err
+		}
+	}
+	if m.Vacuum {
+		before, err := database.Info()
+		if err != nil {
+			return err
+		}
+		if err := database.Vacuum(); err != nil {
+			return err
+		}
+		log.Printf("Maintenance: vacuumed the database, %d free pages given back", before.FreePages)
+	}
+	return nil
+}
+
+// syncNews runs the gateway once and logs what it did.
+func syncNews(gw *nntp.Gateway) ([]string, error) {
+	report, err := gw.Sync()
+	if err != nil {
+		return nil, err
+	}
+	lines := report.Lines()
+	for _, line := range lines {
+		log.Printf("NNTP: %s", line)
+	}
+	return lines, nil
+}
+
+// reloadConfig re-reads the config file and applies the sections that can
+// change while the BBS runs: passwords, two_factor, nodes and flood. Other
+// changed sections are reported as needing a restart.
+func reloadConfig(path string, running *config.Config, userRepo *user.Repo, nodeMgr *node.Manager, floodLimiter *flood.Limiter, bbsSettings *db.BBSSettings) (*control.Reload, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	userRepo.SetPasswordPolicy(passwordPolicy(cfg))
+	userRepo.SetTOTPPolicy(totpPolicy(cfg, bbsSettings.Name))
+	nodeMgr.ReplaceSettings(nodeSettings(cfg, bbsSettings.MaxNodes))
+	floodLimiter.SetRules(floodRules(cfg), cfg.Flood.ExemptLevel)
+
+	r := &control.Reload{Applied: []string{"passwords", "two_factor", "nodes", "flood"}}
+	for _, s := range []struct {
+		name    string
+		changed bool
+	}{
+		{"server", !reflect.DeepEqual(running.Server, cfg.Server)},
+		{"listeners", !reflect.DeepEqual(running.Listeners, cfg.Listeners)},
+		{"paths", !reflect.DeepEqual(running.Paths, cfg.Paths)},
+		{"doors", !reflect.DeepEqual(running.Doors, cfg.Doors)},
+		{"transfer", !reflect.DeepEqual(running.Transfer, cfg.Transfer)},
+		{"cleanup", !reflect.DeepEqual(running.Cleanup, cfg.Cleanup)},
+		{"maintenance", !reflect.DeepEqual(running.Maintenance, cfg.Maintenance)},
+		{"gopher", !reflect.DeepEqual(running.Gopher, cfg.Gopher)},
+		{"scripting", !reflect.DeepEqual(running.Scripting, cfg.Scripting)},
+		{"greetings", !reflect.DeepEqual(running.Greetings, cfg.Greetings)},
+		{"onboarding", !reflect.DeepEqual(running.Onboarding, cfg.Onboarding)},
+		{"nntp", !reflect.DeepEqual(running.NNTP, cfg.NNTP)},
+		{"email", !reflect.DeepEqual(running.Email, cfg.Email)},
+		{"shutdown", !reflect.DeepEqual(running.Shutdown, cfg.Shutdown)},
+		{"waiting_room", !reflect.DeepEqual(running.WaitingRoom, cfg.WaitingRoom)},
+	} {
+		if s.changed {
+			r.Restart = append(r.Restart, s.name)
+		}
+	}
+	log.Printf("Control: reloaded %s", path)
+	if len(r.Restart) > 0 {
+		log.Printf("Control: changes to %s need a restart", strings.Join(r.Restart, ", "))
+	}
+	return r, nil
+}
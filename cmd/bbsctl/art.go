@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+)
+
+const artRenderUsage = "usage: bbsctl art render [-width n] [-font file] [-o dir] <file>..."
+
+func runArt(args []string) error {
+	if len(args) > 0 && args[0] == "render" {
+		return runArtRender(args[1:])
+	}
+	if len(args) < 1 || args[0] != "lint" {
+		return errors.New("usage: bbsctl art lint [-strict] <dir>...")
+	}
+
+	fs := flag.NewFlagSet("art lint", flag.ExitOnError)
+	strict := fs.Bool("strict", false, "treat warnings as errors")
+	fs.Parse(args[1:])
+	if fs.NArg() == 0 {
+		return errors.New("usage: bbsctl art lint [-strict] <dir>...")
+	}
+
+	var errCount, warnCount, files int
+	for _, dir := range fs.Args() {
+		issues, err := ansi.LintDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			fmt.Println(issue)
+			if issue.Severity == ansi.SeverityError {
+				errCount++
+			} else {
+				warnCount++
+			}
+		}
+		files++
+	}
+
+	fmt.Printf("\n%d error(s), %d warning(s) in %d director%s\n", errCount, warnCount, files, plural(files, "y", "ies"))
+	if errCount > 0 || (*strict && warnCount > 0) {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// runArtRender writes an HTML preview of each display file, and a PNG when
+// a CP437 bitmap font is given.
+func runArtRender(args []string) error {
+	fs := flag.NewFlagSet("art render", flag.ExitOnError)
+	width := fs.Int("width", 0, "columns (default: SAUCE width, or 80)")
+	fontPath := fs.String("font", "", "8-pixel-wide CP437 font (raw .f16, PSF1 or PSF2) for PNG output")
+	outDir := fs.String("o", ".", "output directory")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New(artRenderUsage)
+	}
+
+	var font *ansi.Font
+	if *fontPath != "" {
+		f, err := ansi.LoadFont(*fontPath)
+		if err != nil {
+			return err
+		}
+		font = f
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sauce, data := ansi.ParseSAUCE(data)
+		cols := *width
+		if cols <= 0 {
+			cols = 80
+			if sauce != nil {
+				cols = sauce.Width()
+			}
+		}
+		text := ansi.DecodeCP437(ansi.BlankPlaceholders(data))
+		base := filepath.Join(*outDir, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+
+		page := fmt.Sprintf("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title></head>\n"+
+			"<body style=\"background:#000\"><pre style=\"color:#aaa;font-family:monospace;line-height:1\">%s</pre></body></html>\n",
+			html.EscapeString(filepath.Base(path)), ansi.RenderHTML(text, cols))
+		if err := os.WriteFile(base+".html", []byte(page), 0644); err != nil {
+			return err
+		}
+		fmt.Println(base + ".html")
+
+		if font == nil {
+			continue
+		}
+		img, err := ansi.RenderImage(text, cols, font)
+		if err != nil {
+			return err
+		}
+		if err := writePNG(base+".png", img); err != nil {
+			return err
+		}
+		fmt.Println(base + ".png")
+	}
+	return nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
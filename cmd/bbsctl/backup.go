@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/backup"
+	"github.com/notepid/twilight_bbs/internal/config"
+	"github.com/notepid/twilight_bbs/internal/control"
+)
+
+const backupUsage = `usage: bbsctl backup create [-config config.yaml] [-o file]
+       bbsctl backup verify <archive>
+       bbsctl backup restore [-config config.yaml] [-force] <archive>`
+
+func runBackup(args []string) error {
+	if len(args) < 1 {
+		return errors.New(backupUsage)
+	}
+	switch args[0] {
+	case "create":
+		return runBackupCreate(args[1:])
+	case "verify":
+		return runBackupVerify(args[1:])
+	case "restore":
+		return runBackupRestore(args[1:])
+	}
+	return errors.New(backupUsage)
+}
+
+// sources lists what goes into a backup of the board configured by cfg.
+// The database is added by backup.Create.
+func sources(cfg *config.Config, configPath string) []backup.Source {
+	return []backup.Source{
+		{Name: "config.yaml", Path: configPath},
+		{Name: "menus", Path: cfg.Paths.Menus},
+		{Name: "commands", Path: cfg.Paths.Commands, Optional: true},
+		{Name: "text", Path: cfg.Paths.Text, Optional: true},
+		{Name: "doors", Path: cfg.Paths.Doors, Optional: true},
+		{Name: "ssh_host_key", Path: filepath.Join(cfg.Paths.Data, "ssh_host_key"), Optional: true},
+	}
+}
+
+func runBackupCreate(args []string) error {
+	fs := flag.NewFlagSet("backup create", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "config file of the board")
+	out := fs.String("o", "bbs-backup-"+time.Now().Format("20060102-150405")+".tar.gz", "archive to write")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New(backupUsage)
+	}
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	// Write next to the destination and rename, so a failed backup never
+	// leaves a partial archive under the final name.
+	tmp, err := os.CreateTemp(filepath.Dir(*out), ".bbs-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	m, err := backup.Create(tmp, cfg.Paths.Database, sources(cfg, *configPath))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), *out); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s: %d files, database schema %d\n", *out, len(m.Files), m.Schema)
+	return nil
+}
+
+// extract unpacks and checks an archive in a staging directory under the
+// current directory, which the caller removes.
+func extract(archive string) (string, *backup.Manifest, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	stage, err := os.MkdirTemp(".", ".bbs-restore-")
+	if err != nil {
+		return "", nil, err
+	}
+	m, err := backup.Extract(f, stage)
+	if err != nil {
+		os.RemoveAll(stage)
+		return "", nil, fmt.Errorf("%s: %w", archive, err)
+	}
+	return stage, m, nil
+}
+
+func printManifest(m *backup.Manifest) {
+	fmt.Printf("Backup of %s from %s, database schema %d\n", m.Host, m.Created.Local().Format("2006-01-02 15:04"), m.Schema)
+	for _, c := range m.Components {
+		fmt.Printf("  %s\n", c.Name)
+	}
+}
+
+func runBackupVerify(args []string) error {
+	if len(args) != 1 {
+		return errors.New(backupUsage)
+	}
+	stage, m, err := extract(args[0])
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stage)
+	printManifest(m)
+	fmt.Printf("%d files OK\n", len(m.Files))
+	return nil
+}
+
+func runBackupRestore(args []string) error {
+	fs := flag.NewFlagSet("backup restore", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "where to restore the config file")
+	force := fs.Bool("force", false, "replace existing files, keeping the old ones beside them")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New(backupUsage)
+	}
+	stage, m, err := extract(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stage)
+
+	// The paths to restore to come from the config in the archive, as the
+	// board will use it once restored.
+	cfgFile := filepath.Join(stage, "config.yaml")
+	if _, err := os.Stat(cfgFile); err != nil {
+		cfgFile = *configPath
+	}
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+	if cfg.Server.ControlSocket != "" {
+		if c, err := control.Dial(cfg.Server.ControlSocket); err == nil {
+			c.Close()
+			return errors.New("the BBS is running; stop it before restoring")
+		}
+	}
+
+	targets := map[string]string{backup.Database: cfg.Paths.Database}
+	for _, src := range sources(cfg, *configPath) {
+		targets[src.Name] = src.Path
+	}
+	printManifest(m)
+	lines, err := backup.Install(stage, m, targets, *force)
+	for _, line := range lines {
+		fmt.Println("Restored " + line)
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+// Command bbsctl is the sysop's command-line toolbox: offline tasks that do
+// not need the BBS running, and commands that control a running BBS over
+// its control socket.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: bbsctl <command> [arguments]
+
+Commands:
+  art lint <dir>...   check display files for common problems
+  art render <file>.. write HTML (and, with -font, PNG) previews of art
+  menu check <dir>    report broken menu links and unreachable menus
+  menu graph <dir>    print the menu navigation graph (Graphviz DOT)
+  script test <path>  run *_test.lua unit tests for menu and command scripts
+  backup create       archive the database, config, menus and text
+  backup verify <f>   check an archive without restoring it
+  backup restore <f>  restore an archive (the BBS must be stopped)
+  play <file>         replay a session recording (-speed, -max-idle)
+  recordings search   find sessions by user, node, date or text shown
+
+Running BBS (all take -socket path, default ./data/control.sock):
+  nodes               list connected nodes
+  kick <node> [msg]   disconnect a node
+  broadcast <msg>     show a message on every node
+  reload menus|config rescan menus, or re-read the config file
+  shutdown [-drain d] stop the BBS, optionally counting down for callers
+  stats               show today's statistics
+  nntp sync           exchange articles with the newsgroups now
+  db info|check       show the database size, or run an integrity check
+  db vacuum           rebuild the database file and give free space back
+  db backup <file>    copy the live database to a new file
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "art":
+		err = runArt(os.Args[2:])
+	case "menu":
+		err = runMenu(os.Args[2:])
+	case "script":
+		err = runScript(os.Args[2:])
+	case "backup":
+		err = runBackup(os.Args[2:])
+	case "play":
+		err = runPlay(os.Args[2:])
+	case "recordings":
+		err = runRecordings(os.Args[2:])
+	case "nodes":
+		err = runNodes(os.Args[2:])
+	case "kick":
+		err = runKick(os.Args[2:])
+	case "broadcast":
+		err = runBroadcast(os.Args[2:])
+	case "reload":
+		err = runReload(os.Args[2:])
+	case "shutdown":
+		err = runShutdown(os.Args[2:])
+	case "stats":
+		err = runStats(os.Args[2:])
+	case "nntp":
+		err = runNNTP(os.Args[2:])
+	case "db":
+		err = runDB(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "bbsctl: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bbsctl: %v\n", err)
+		os.Exit(1)
+	}
+}
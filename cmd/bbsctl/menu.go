@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/menu"
+)
+
+const menuUsage = "usage: bbsctl menu check|graph [-start welcome,welcome_ssh] <menu dir> [text dir...]"
+
+func runMenu(args []string) error {
+	if len(args) < 1 || (args[0] != "check" && args[0] != "graph") {
+		return errors.New(menuUsage)
+	}
+
+	fs := flag.NewFlagSet("menu "+args[0], flag.ExitOnError)
+	start := fs.String("start", strings.Join(menu.DefaultStartMenus, ","), "comma-separated start menus")
+	fs.Parse(args[1:])
+	if fs.NArg() == 0 {
+		return errors.New(menuUsage)
+	}
+
+	// The registry logs every menu it finds; keep the report readable.
+	log.SetOutput(io.Discard)
+	reg := menu.NewRegistry(fs.Arg(0))
+	if err := reg.Scan(); err != nil {
+		return err
+	}
+	graph, err := menu.BuildGraph(reg)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "graph" {
+		fmt.Print(graph.DOT())
+		return nil
+	}
+
+	loader := ansi.NewLoader(fs.Args()...)
+	problems := graph.Check(reg, loader, strings.Split(*start, ",")...)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	for _, ref := range graph.Dynamic() {
+		fmt.Printf("%s:%d: note: %s target is not a literal and was not checked\n", ref.From, ref.Line, ref.Kind)
+	}
+
+	fmt.Printf("\n%d problem(s) in %d menus\n", len(problems), len(graph.Menus))
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/recording"
+)
+
+const playUsage = "usage: bbsctl play [-speed 1] [-max-idle 5s] <recording.cast>"
+
+// runPlay replays a session recording on this terminal.
+func runPlay(args []string) error {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "playback speed, 2 = twice as fast")
+	maxIdle := fs.Duration("max-idle", 5*time.Second, "longest pause between events, 0 = as recorded")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New(playUsage)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = recording.Play(os.Stdout, f, recording.PlayOptions{Speed: *speed, MaxIdle: *maxIdle})
+	return err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	osuser "os/user"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/recording"
+)
+
+const recordingsUsage = `usage: bbsctl recordings search -reason why [-dir d] [-user u] [-node n]
+                               [-from YYYY-MM-DD] [-to YYYY-MM-DD] [keyword]`
+
+// runRecordings searches session recordings for an abuse report. Every
+// search is logged with who ran it and why.
+func runRecordings(args []string) error {
+	if len(args) == 0 || args[0] != "search" {
+		return errors.New(recordingsUsage)
+	}
+	fs := flag.NewFlagSet("recordings search", flag.ExitOnError)
+	dir := fs.String("dir", "data/recordings", "recordings directory")
+	reason := fs.String("reason", "", "why you are searching, for the search log (required)")
+	userName := fs.String("user", "", "only this caller's sessions")
+	node := fs.Int("node", 0, "only this node")
+	from := fs.String("from", "", "sessions on or after this day")
+	to := fs.String("to", "", "sessions on or before this day")
+	limit := fs.Int("limit", 100, "most hits to show")
+	fs.Parse(args[1:])
+	if *reason == "" || fs.NArg() > 1 {
+		return errors.New(recordingsUsage)
+	}
+
+	q := recording.Query{
+		User:    *userName,
+		Node:    *node,
+		Keyword: strings.Join(fs.Args(), " "),
+		Limit:   *limit,
+		Reason:  *reason,
+		By:      "bbsctl",
+	}
+	if u, err := osuser.Current(); err == nil {
+		q.By = u.Username + " (bbsctl)"
+	}
+	var err error
+	if q.From, err = parseDay(*from, false); err != nil {
+		return err
+	}
+	if q.To, err = parseDay(*to, true); err != nil {
+		return err
+	}
+
+	hits, err := recording.NewStore(*dir, 0, 0).Search(q)
+	if err != nil {
+		return err
+	}
+	for _, h := range hits {
+		user := h.User
+		if user == "" {
+			user = "-"
+		}
+		if q.Keyword == "" {
+			fmt.Printf("%s  node %-3d %-16s %s\n", h.Started.Format("2006-01-02 15:04"), h.Node, user, h.Name)
+			continue
+		}
+		fmt.Printf("%s  node %-3d %-16s %s +%s\n    %s\n", h.Started.Format("2006-01-02 15:04"), h.Node, user, h.Name,
+			h.At.Truncate(time.Second), h.Text)
+	}
+	if len(hits) == 0 {
+		fmt.Fprintln(os.Stderr, "nothing found")
+	}
+	return nil
+}
+
+// parseDay reads a YYYY-MM-DD flag; endOfDay moves it to the next
+// midnight so the day is included.
+func parseDay(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q: want YYYY-MM-DD", s)
+	}
+	if endOfDay {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d, nil
+}
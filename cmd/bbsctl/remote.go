@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/control"
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/stats"
+)
+
+// defaultSocket matches the server.control_socket default.
+const defaultSocket = "./data/control.sock"
+
+// remoteFlags returns a flag set with -socket for commands that talk to a
+// running BBS.
+func remoteFlags(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	socket := fs.String("socket", defaultSocket, "control socket of the running BBS")
+	return fs, socket
+}
+
+// runRemote parses args and calls run with a client connected to the BBS.
+func runRemote(name, usage string, args []string, minArgs int, run func(c *control.Client, args []string) error) error {
+	fs, socket := remoteFlags(name)
+	fs.Parse(args)
+	if fs.NArg() < minArgs {
+		return errors.New(usage)
+	}
+	c, err := control.Dial(*socket)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return run(c, fs.Args())
+}
+
+func runNodes(args []string) error {
+	return runRemote("nodes", "usage: bbsctl nodes [-socket path]", args, 0, func(c *control.Client, _ []string) error {
+		nodes, err := c.Nodes()
+		if err != nil {
+			return err
+		}
+		if len(nodes) == 0 {
+			fmt.Println("No nodes online.")
+			return nil
+		}
+		fmt.Printf("%-4s %-16s %-22s %-16s %-10s %8s %8s\n", "Node", "User", "From", "Menu", "Online", "Sent", "Recv")
+		for _, n := range nodes {
+			where := n.Menu
+			if n.Busy != "" {
+				where = "(" + n.Busy + ")"
+			}
+			fmt.Printf("%-4d %-16s %-22s %-16s %-10s %7dK %7dK\n", n.ID, n.User, n.Remote, where,
+				time.Since(n.Since).Round(time.Second), n.Sent/1024, n.Received/1024)
+		}
+		return nil
+	})
+}
+
+func runKick(args []string) error {
+	const usage = "usage: bbsctl kick [-socket path] <node> [message]"
+	return runRemote("kick", usage, args, 1, func(c *control.Client, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return errors.New(usage)
+		}
+		if err := c.Kick(id, strings.Join(args[1:], " ")); err != nil {
+			return err
+		}
+		fmt.Printf("Node %d disconnected.\n", id)
+		return nil
+	})
+}
+
+func runBroadcast(args []string) error {
+	return runRemote("broadcast", "usage: bbsctl broadcast [-socket path] <message>", args, 1, func(c *control.Client, args []string) error {
+		n, err := c.Broadcast(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Sent to %d node(s).\n", n)
+		return nil
+	})
+}
+
+func runReload(args []string) error {
+	const usage = "usage: bbsctl reload [-socket path] menus|config"
+	return runRemote("reload", usage, args, 1, func(c *control.Client, args []string) error {
+		switch args[0] {
+		case "menus":
+			n, err := c.ReloadMenus()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Loaded %d menus.\n", n)
+		case "config":
+			r, err := c.ReloadConfig()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Applied: %s\n", strings.Join(r.Applied, ", "))
+			if len(r.Restart) > 0 {
+				fmt.Printf("Changed, needs a restart: %s\n", strings.Join(r.Restart, ", "))
+			}
+		default:
+			return errors.New(usage)
+		}
+		return nil
+	})
+}
+
+func runNNTP(args []string) error {
+	const usage = "usage: bbsctl nntp [-socket path] sync"
+	return runRemote("nntp", usage, args, 1, func(c *control.Client, args []string) error {
+		if args[0] != "sync" {
+			return errors.New(usage)
+		}
+		lines, err := c.NNTPSync()
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return nil
+	})
+}
+
+func runDB(args []string) error {
+	const usage = "usage: bbsctl db [-socket path] info|check|vacuum|backup <file>"
+	return runRemote("db", usage, args, 1, func(c *control.Client, args []string) error {
+		switch args[0] {
+		case "info":
+			info, err := c.Database()
+			if err != nil {
+				return err
+			}
+			printDBInfo(info)
+		case "check":
+			problems, err := c.CheckDatabase()
+			if err != nil {
+				return err
+			}
+			if len(problems) == 0 {
+				fmt.Println("Database OK.")
+				return nil
+			}
+			for _, p := range problems {
+				fmt.Println(p)
+			}
+			return fmt.Errorf("integrity check found %d problem(s)", len(problems))
+		case "vacuum":
+			info, err := c.VacuumDatabase()
+			if err != nil {
+				return err
+			}
+			printDBInfo(info)
+		case "backup":
+			if len(args) != 2 {
+				return errors.New(usage)
+			}
+			if err := c.BackupDatabase(args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("Database copied to %s.\n", args[1])
+		default:
+			return errors.New(usage)
+		}
+		return nil
+	})
+}
+
+func printDBInfo(info *db.Info) {
+	fmt.Printf("Schema:     %d\n", info.Schema)
+	fmt.Printf("Size:       %dK (%d pages of %d bytes)\n", info.Size()/1024, info.Pages, info.PageSize)
+	fmt.Printf("Free pages: %d\n", info.FreePages)
+}
+
+func runShutdown(args []string) error {
+	fs, socket := remoteFlags("shutdown")
+	drain := fs.Duration("drain", 0, "stop taking calls and warn callers for this long before disconnecting them")
+	fs.Parse(args)
+	if *drain < 0 {
+		return errors.New("usage: bbsctl shutdown [-socket path] [-drain 5m] [message]")
+	}
+	c, err := control.Dial(*socket)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Shutdown(*drain, strings.Join(fs.Args(), " ")); err != nil {
+		return err
+	}
+	if *drain > 0 {
+		fmt.Printf("Counting down %v, then shutting down.\n", *drain)
+	} else {
+		fmt.Println("Shutting down.")
+	}
+	return nil
+}
+
+func runStats(args []string) error {
+	return runRemote("stats", "usage: bbsctl stats [-socket path]", args, 0, func(c *control.Client, _ []string) error {
+		st, err := c.Stats()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Up since:  %s (%v)\n", st.Started.Format(time.DateTime), time.Since(st.Started).Round(time.Second))
+		fmt.Printf("Nodes:     %d/%d", st.Nodes, st.MaxNodes)
+		if st.Draining {
+			fmt.Print(" (draining)")
+		}
+		fmt.Printf("\n\nToday (%s)\n", st.Date)
+		for _, m := range stats.Metrics {
+			fmt.Printf("  %-16s %6d\n", m, st.Today[m])
+		}
+		if len(st.AreaPosts) > 0 {
+			ids := make([]int, 0, len(st.AreaPosts))
+			for id := range st.AreaPosts {
+				ids = append(ids, id)
+			}
+			sort.Ints(ids)
+			fmt.Println("\nPosts by area")
+			for _, id := range ids {
+				fmt.Printf("  area %-9d %6d\n", id, st.AreaPosts[id])
+			}
+		}
+		if len(st.TopCallers) > 0 {
+			fmt.Println("\nTop callers")
+			for i, tc := range st.TopCallers {
+				fmt.Printf("  %2d. %-20s %6d\n", i+1, tc.Username, tc.Calls)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/scripttest"
+)
+
+const scriptUsage = "usage: bbsctl script test [-v] <test file or dir>..."
+
+func runScript(args []string) error {
+	if len(args) < 1 || args[0] != "test" {
+		return errors.New(scriptUsage)
+	}
+
+	flags := flag.NewFlagSet("script test", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "show the calls and output of every test")
+	flags.Parse(args[1:])
+	if flags.NArg() == 0 {
+		return errors.New(scriptUsage)
+	}
+
+	files, err := testFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no *_test.lua files found")
+	}
+
+	passed, failed := 0, 0
+	for _, file := range files {
+		results, err := scripttest.RunFile(file)
+		if err != nil {
+			fmt.Printf("FAIL %s\n    %v\n", file, err)
+			failed++
+			continue
+		}
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Printf("FAIL %s: %s\n    %s\n", file, r.Name, strings.ReplaceAll(r.Err.Error(), "\n", "\n    "))
+				failed++
+			} else {
+				fmt.Printf("ok   %s: %s\n", file, r.Name)
+				passed++
+			}
+			if *verbose && r.Run != nil {
+				for _, c := range r.Run.Calls {
+					fmt.Printf("    %s\n", c)
+				}
+			}
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// testFiles returns the test files named, and the *_test.lua files under
+// the directories named.
+func testFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(path, "_test.lua") {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -3,12 +3,22 @@ package app
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/bulletin"
+	"github.com/notepid/twilight_bbs/internal/callers"
+	"github.com/notepid/twilight_bbs/internal/conference"
 	"github.com/notepid/twilight_bbs/internal/config"
 	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/door"
 	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/menu"
 	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/preflight"
+	"github.com/notepid/twilight_bbs/internal/stats"
 	"github.com/notepid/twilight_bbs/internal/user"
 )
 
@@ -18,9 +28,14 @@ type App struct {
 	DBPath     string
 	DB         *db.DB
 
-	Users    *user.Repo
-	Messages *message.Repo
-	Files    *filearea.Repo
+	Users     *user.Repo
+	Messages  *message.Repo
+	Files     *filearea.Repo
+	Bulletins *bulletin.Repo
+	Callers   *callers.Repo
+	Stats     *stats.Repo
+	Doors     *door.StatsRepo
+	Confs     *conference.Repo
 
 	BusyTimeout time.Duration
 }
@@ -48,9 +63,21 @@ func New(configPath string) (*App, func(), error) {
 		Users:        user.NewRepo(database.DB),
 		Messages:     message.NewRepo(database.DB),
 		Files:        filearea.NewRepo(database.DB),
+		Bulletins:    bulletin.NewRepo(database.DB),
+		Callers:      callers.NewRepo(database.DB),
+		Stats:        stats.NewRepo(database.DB),
+		Doors:        door.NewStatsRepo(database.DB),
+		Confs:        conference.NewRepo(database.DB),
 		BusyTimeout:  5 * time.Second,
 	}
 
+	a.Users.SetPasswordPolicy(user.PasswordPolicy{
+		MinLength:  cfg.Passwords.MinLength,
+		MinClasses: cfg.Passwords.MinClasses,
+		BanCommon:  cfg.Passwords.BanCommon,
+		BcryptCost: cfg.Passwords.BcryptCost,
+	})
+
 	// Best-effort online use: reduce SQLITE_BUSY failures.
 	_, _ = database.Exec("PRAGMA busy_timeout = 5000")
 
@@ -60,3 +87,189 @@ func New(configPath string) (*App, func(), error) {
 
 	return a, cleanup, nil
 }
+
+// Preflight runs the same door/transfer checks the BBS runs at startup.
+// They only read, so it is safe to run against a live BBS's directories.
+func (a *App) Preflight() *preflight.Report {
+	return preflight.Run(preflight.Options{
+		DosemuPath: a.Config.Doors.DosemuPath,
+		SexyzPath:  a.Config.Transfer.SexyzPath,
+		DriveC:     a.Config.Doors.DriveC,
+		DoorsTmp:   filepath.Join(a.Config.Paths.Data, "doors_tmp"),
+		UploadTmp:  filepath.Join(a.Config.Paths.Data, "upload_tmp"),
+	})
+}
+
+// MenuCheck reports broken menu links and unreachable menus, one per line.
+func (a *App) MenuCheck() []string {
+	reg := menu.NewRegistry(a.Config.Paths.Menus)
+	if err := reg.Scan(); err != nil {
+		return []string{"[ERR ] " + err.Error()}
+	}
+	graph, err := menu.BuildGraph(reg)
+	if err != nil {
+		return []string{"[ERR ] " + err.Error()}
+	}
+
+	loader := ansi.NewLoader(a.Config.Paths.Menus, a.Config.Paths.Text)
+	var lines []string
+	for _, p := range graph.Check(reg, loader, menu.DefaultStartMenus...) {
+		lines = append(lines, "[FAIL] "+p.String())
+	}
+	if len(lines) == 0 {
+		lines = append(lines, fmt.Sprintf("[ OK ] %d menus, no problems found", len(graph.Menus)))
+	}
+	return lines
+}
+
+// metricLabels names the daily statistics in reports.
+var metricLabels = map[string]string{
+	stats.Calls:        "Calls",
+	stats.NewUsers:     "New users",
+	stats.Posts:        "Messages posted",
+	stats.Uploads:      "Files uploaded",
+	stats.Downloads:    "Files downloaded",
+	stats.DoorLaunches: "Door launches",
+	stats.PeakNodes:    "Peak nodes",
+	stats.TermKBSent:   "Terminal KB sent",
+	stats.TermKBRecv:   "Terminal KB recv",
+}
+
+// StatsReport summarises the daily statistics over the last days days: each
+// metric today, over the period and as a sparkline, then posts per message
+// area and the top callers.
+func (a *App) StatsReport(days int) []string {
+	now := time.Now()
+	lines := []string{fmt.Sprintf("%-18s %7s %7s  %s", "", "Today", "Period", "Daily, oldest first")}
+	for _, m := range stats.Metrics {
+		series, err := a.Stats.Series(m, days, now)
+		if err != nil {
+			return []string{"[ERR ] " + err.Error()}
+		}
+		period := 0
+		for _, v := range series {
+			if m == stats.PeakNodes {
+				period = max(period, v)
+			} else {
+				period += v
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%-18s %7d %7d  %s",
+			metricLabels[m], series[len(series)-1], period, stats.Sparkline(series)))
+	}
+
+	posts, err := a.Stats.AreaPosts(days, now)
+	if err != nil {
+		return append(lines, "[ERR ] "+err.Error())
+	}
+	lines = append(lines, "", "Messages by area")
+	areas, err := a.Messages.ListAreas(user.LevelSysop)
+	if err != nil {
+		return append(lines, "[ERR ] "+err.Error())
+	}
+	for _, area := range areas {
+		if n := posts[area.ID]; n > 0 {
+			lines = append(lines, fmt.Sprintf("  %-30s %7d", area.Name, n))
+		}
+	}
+	if len(posts) == 0 {
+		lines = append(lines, "  (none)")
+	}
+
+	top, err := a.Stats.TopCallers(10)
+	if err != nil {
+		return append(lines, "[ERR ] "+err.Error())
+	}
+	lines = append(lines, "", "Top callers (all time)")
+	for i, c := range top {
+		lines = append(lines, fmt.Sprintf("  %2d. %-26s %7d", i+1, c.Username, c.Calls))
+	}
+	if len(top) == 0 {
+		lines = append(lines, "  (none)")
+	}
+	return lines
+}
+
+// DoorReport lists the most played doors over the last days days with
+// their launches, players and hours played.
+func (a *App) DoorReport(days int) []string {
+	top, err := a.Doors.TopDoors(time.Now().AddDate(0, 0, -days), 20)
+	if err != nil {
+		return []string{"[ERR ] " + err.Error()}
+	}
+	lines := []string{fmt.Sprintf("    %-26s %8s %8s %8s", "Door", "Launches", "Players", "Hours")}
+	for i, u := range top {
+		lines = append(lines, fmt.Sprintf("%2d. %-26s %8d %8d %8.1f",
+			i+1, u.Door, u.Launches, u.Players, float64(u.Seconds)/3600))
+	}
+	if len(top) == 0 {
+		lines = append(lines, "  (no doors played)")
+	}
+	return lines
+}
+
+// DatabaseReport describes the database file and runs SQLite's integrity
+// check on it. It is safe while the BBS is running.
+func (a *App) DatabaseReport() []string {
+	info, err := a.DB.Info()
+	if err != nil {
+		return []string{"[ERR ] " + err.Error()}
+	}
+	lines := []string{
+		fmt.Sprintf("File:        %s", a.DBPath),
+		fmt.Sprintf("Schema:      %d of %d", info.Schema, db.SchemaVersion()),
+		fmt.Sprintf("Size:        %dK (%d pages of %d bytes)", info.Size()/1024, info.Pages, info.PageSize),
+		fmt.Sprintf("Free pages:  %d", info.FreePages),
+		"",
+	}
+	problems, err := a.DB.Integrity()
+	if err != nil {
+		return append(lines, "[ERR ] "+err.Error())
+	}
+	if len(problems) == 0 {
+		return append(lines, "[ OK ] integrity check")
+	}
+	for _, p := range problems {
+		lines = append(lines, "[FAIL] integrity check: "+p)
+	}
+	return lines
+}
+
+// BackupDatabase copies the database, live, to a new file named for the
+// time in the backups directory beside it and returns the copy's path.
+func (a *App) BackupDatabase() (string, error) {
+	dir := filepath.Join(filepath.Dir(a.DBPath), "backups")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create backups directory: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(a.DBPath), filepath.Ext(a.DBPath))
+	path := filepath.Join(dir, name+"-"+time.Now().Format("20060102-150405")+".db")
+	if err := a.DB.Backup(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// VacuumDatabase rebuilds the database file, refreshes the planner
+// statistics and truncates the write-ahead log. Writers on a running BBS
+// wait while it runs.
+func (a *App) VacuumDatabase() (string, error) {
+	before, err := a.DB.Info()
+	if err != nil {
+		return "", err
+	}
+	if err := a.DB.Vacuum(); err != nil {
+		return "", err
+	}
+	if err := a.DB.Analyze(); err != nil {
+		return "", err
+	}
+	if _, err := a.DB.Checkpoint(); err != nil {
+		return "", err
+	}
+	after, err := a.DB.Info()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Vacuumed: %dK -> %dK.", before.Size()/1024, after.Size()/1024), nil
+}
@@ -0,0 +1,284 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+
+	"github.com/notepid/twilight_bbs/internal/admin/app"
+	"github.com/notepid/twilight_bbs/internal/bulletin"
+)
+
+const bulletinDate = "2006-01-02"
+
+type bulletinsModel struct {
+	app *app.App
+
+	width  int
+	height int
+
+	Done bool
+
+	state bulletinsState
+	list  list.Model
+	err   error
+
+	form *huh.Form
+
+	editID      int // 0 when adding
+	editTitle   string
+	editBody    string
+	editArt     string
+	editPublish string
+	editExpires string
+	editSave    bool
+
+	deleteID      int
+	deleteConfirm bool
+}
+
+type bulletinsState int
+
+const (
+	bulletinsStateList bulletinsState = iota
+	bulletinsStateEdit
+	bulletinsStateDelete
+)
+
+type bulletinItem struct {
+	id    int
+	title string
+	desc  string
+}
+
+func (i bulletinItem) Title() string       { return i.title }
+func (i bulletinItem) Description() string { return i.desc }
+func (i bulletinItem) FilterValue() string { return i.title }
+
+func newBulletinsModel(a *app.App) *bulletinsModel {
+	m := &bulletinsModel{app: a, state: bulletinsStateList}
+	m.reload()
+	return m
+}
+
+func (m *bulletinsModel) SetSize(w, h int) {
+	m.width, m.height = w, h
+	m.list.SetSize(w, h-2)
+}
+
+func (m *bulletinsModel) Update(msg tea.Msg) tea.Cmd {
+	if m.err != nil {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			if msg.String() == "esc" || msg.String() == "q" || msg.String() == "enter" {
+				m.err = nil
+				m.state = bulletinsStateList
+				m.reload()
+			}
+		}
+		return nil
+	}
+
+	if m.state != bulletinsStateList {
+		if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "esc" {
+			m.back()
+			return nil
+		}
+		return m.updateForm(msg)
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok && m.list.FilterState() != list.Filtering {
+		switch msg.String() {
+		case "q", "esc":
+			m.Done = true
+			return nil
+		case "a":
+			m.startEdit(nil)
+			return nil
+		case "e", "enter":
+			if it, ok := m.list.SelectedItem().(bulletinItem); ok {
+				b, err := m.app.Bulletins.Get(it.id)
+				if err != nil {
+					m.err = err
+					return nil
+				}
+				m.startEdit(b)
+			}
+			return nil
+		case "d":
+			if it, ok := m.list.SelectedItem().(bulletinItem); ok {
+				m.startDelete(it)
+			}
+			return nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return cmd
+}
+
+func (m *bulletinsModel) View() string {
+	if m.err != nil {
+		return fmt.Sprintf("Bulletins error: %v\n\nPress Enter/Esc to go back.", m.err)
+	}
+
+	switch m.state {
+	case bulletinsStateList:
+		m.list.Title = "Bulletins"
+		return m.list.View() + "\n(a add, e/enter edit, d delete, q back)"
+	case bulletinsStateEdit, bulletinsStateDelete:
+		return m.form.View() + "\n\n(esc back)"
+	default:
+		return "Bulletins"
+	}
+}
+
+func (m *bulletinsModel) reload() {
+	bulletins, err := m.app.Bulletins.List()
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	now := time.Now()
+	items := make([]list.Item, 0, len(bulletins))
+	for _, b := range bulletins {
+		desc := "published " + b.PublishedAt.Local().Format(bulletinDate)
+		switch {
+		case now.Before(b.PublishedAt):
+			desc = "scheduled for " + b.PublishedAt.Local().Format(bulletinDate)
+		case !b.Active(now):
+			desc += " • expired"
+		case b.ExpiresAt != nil:
+			desc += " • expires " + b.ExpiresAt.Local().Format(bulletinDate)
+		}
+		if b.Art != "" {
+			desc += " • art: " + b.Art
+		}
+		items = append(items, bulletinItem{id: b.ID, title: b.Title, desc: desc})
+	}
+
+	m.list = list.New(items, list.NewDefaultDelegate(), m.width, m.height-2)
+	m.list.SetShowStatusBar(false)
+	m.list.SetFilteringEnabled(true)
+	m.list.SetShowHelp(true)
+}
+
+// startEdit opens the bulletin form, for a new bulletin when b is nil.
+func (m *bulletinsModel) startEdit(b *bulletin.Bulletin) {
+	m.state = bulletinsStateEdit
+	m.editID = 0
+	m.editTitle, m.editBody, m.editArt = "", "", ""
+	m.editPublish = time.Now().Format(bulletinDate)
+	m.editExpires = ""
+	m.editSave = true
+	if b != nil {
+		m.editID = b.ID
+		m.editTitle, m.editBody, m.editArt = b.Title, b.Body, b.Art
+		m.editPublish = b.PublishedAt.Local().Format(bulletinDate)
+		if b.ExpiresAt != nil {
+			m.editExpires = b.ExpiresAt.Local().Format(bulletinDate)
+		}
+	}
+
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().Title("Title").Value(&m.editTitle).Validate(nonEmpty("title")),
+			huh.NewText().Title("Body").Description("Shown when no art file is set").Value(&m.editBody),
+			huh.NewInput().Title("Art file").Description("Display file name without extension (optional)").Value(&m.editArt),
+			huh.NewInput().Title("Publish date (YYYY-MM-DD)").Value(&m.editPublish).Validate(validDate(false)),
+			huh.NewInput().Title("Expiry date (YYYY-MM-DD, blank = never)").Value(&m.editExpires).Validate(validDate(true)),
+		),
+		huh.NewGroup(
+			huh.NewConfirm().Title("Save bulletin?").Value(&m.editSave),
+		),
+	)
+}
+
+func (m *bulletinsModel) startDelete(it bulletinItem) {
+	m.state = bulletinsStateDelete
+	m.deleteID = it.id
+	m.deleteConfirm = false
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().Title(fmt.Sprintf("Delete bulletin %q?", it.title)).Value(&m.deleteConfirm),
+		),
+	)
+}
+
+func (m *bulletinsModel) updateForm(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	updated, cmd := m.form.Update(msg)
+	f, ok := updated.(*huh.Form)
+	if !ok {
+		m.err = fmt.Errorf("internal error: unexpected form model type")
+		return nil
+	}
+	m.form = f
+	if m.form.State != huh.StateCompleted {
+		return cmd
+	}
+
+	switch m.state {
+	case bulletinsStateEdit:
+		if m.editSave {
+			if err := m.save(); err != nil {
+				m.err = err
+				return nil
+			}
+		}
+	case bulletinsStateDelete:
+		if m.deleteConfirm {
+			if err := m.app.Bulletins.Delete(m.deleteID); err != nil {
+				m.err = err
+				return nil
+			}
+		}
+	}
+	m.back()
+	return nil
+}
+
+func (m *bulletinsModel) save() error {
+	b := &bulletin.Bulletin{
+		ID:    m.editID,
+		Title: strings.TrimSpace(m.editTitle),
+		Body:  m.editBody,
+		Art:   strings.TrimSpace(m.editArt),
+	}
+	b.PublishedAt, _ = time.ParseInLocation(bulletinDate, strings.TrimSpace(m.editPublish), time.Local)
+	if s := strings.TrimSpace(m.editExpires); s != "" {
+		t, _ := time.ParseInLocation(bulletinDate, s, time.Local)
+		b.ExpiresAt = &t
+	}
+
+	if b.ID == 0 {
+		_, err := m.app.Bulletins.Create(b)
+		return err
+	}
+	return m.app.Bulletins.Update(b)
+}
+
+func (m *bulletinsModel) back() {
+	m.form = nil
+	m.state = bulletinsStateList
+	m.reload()
+}
+
+func validDate(optional bool) func(string) error {
+	return func(s string) error {
+		s = strings.TrimSpace(s)
+		if s == "" && optional {
+			return nil
+		}
+		if _, err := time.Parse(bulletinDate, s); err != nil {
+			return fmt.Errorf("date must be YYYY-MM-DD")
+		}
+		return nil
+	}
+}
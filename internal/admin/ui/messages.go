@@ -2,11 +2,15 @@ package ui
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/huh"
 
 	"github.com/notepid/twilight_bbs/internal/admin/app"
+	"github.com/notepid/twilight_bbs/internal/message"
 	"github.com/notepid/twilight_bbs/internal/user"
 )
 
@@ -29,6 +33,13 @@ type messagesModel struct {
 	selectedMsgID int
 	msgBody       string
 	msgHeader     string
+
+	form          *huh.Form
+	retMaxMsgs    string
+	retMaxAgeDays string
+	retSave       bool
+
+	purgeReport *message.PurgeReport
 }
 
 type messagesState int
@@ -37,6 +48,8 @@ const (
 	messagesStateAreas messagesState = iota
 	messagesStateList
 	messagesStateDetail
+	messagesStateRetention
+	messagesStatePurge
 )
 
 type msgItem struct {
@@ -100,9 +113,40 @@ func (m *messagesModel) Update(msg tea.Msg) tea.Cmd {
 				m.reloadMessages()
 				return nil
 			}
+		case "r":
+			if m.state == messagesStateAreas && m.list.FilterState() != list.Filtering {
+				if it, ok := m.list.SelectedItem().(msgItem); ok {
+					m.startRetention(it.id)
+				}
+				return nil
+			}
+		case "w":
+			if m.state == messagesStateAreas && m.list.FilterState() != list.Filtering {
+				if it, ok := m.list.SelectedItem().(msgItem); ok {
+					m.toggleWebPublic(it.id)
+				}
+				return nil
+			}
+		case "x":
+			if m.state == messagesStateAreas && m.list.FilterState() != list.Filtering {
+				m.runPurge(true)
+				return nil
+			}
+		case "y":
+			if m.state == messagesStatePurge && m.purgeReport != nil && m.purgeReport.DryRun {
+				m.runPurge(false)
+				return nil
+			}
 		}
 	}
 
+	if m.state == messagesStateRetention {
+		return m.updateForm(msg)
+	}
+	if m.state == messagesStatePurge {
+		return nil
+	}
+
 	var cmd tea.Cmd
 	m.list, cmd = m.list.Update(msg)
 
@@ -140,12 +184,16 @@ func (m *messagesModel) View() string {
 	switch m.state {
 	case messagesStateAreas:
 		m.list.Title = "Message Areas"
-		return m.list.View() + "\n(q to quit, enter to select)"
+		return m.list.View() + "\n(q to quit, enter to select, r retention, w web viewer, x purge expired)"
 	case messagesStateList:
 		m.list.Title = fmt.Sprintf("Messages (area %d)", m.selectedAreaID)
 		return m.list.View() + "\n(n next page, p prev page, esc back)"
 	case messagesStateDetail:
 		return m.msgHeader + "\n\n" + m.msgBody + "\n\n(esc back)"
+	case messagesStateRetention:
+		return m.form.View() + "\n\n(esc back)"
+	case messagesStatePurge:
+		return m.purgeView()
 	default:
 		return "Messages"
 	}
@@ -161,6 +209,12 @@ func (m *messagesModel) reloadAreas() {
 	items := make([]list.Item, 0, len(areas))
 	for _, a := range areas {
 		desc := fmt.Sprintf("%s • total %d", a.Description, a.TotalMsgs)
+		if a.MaxMessages > 0 || a.MaxAgeDays > 0 {
+			desc += fmt.Sprintf(" • keep %s", retentionSummary(a))
+		}
+		if a.WebPublic {
+			desc += " • web"
+		}
 		items = append(items, msgItem{id: a.ID, title: a.Name, desc: desc, kind: "area"})
 	}
 
@@ -212,5 +266,122 @@ func (m *messagesModel) back() {
 	case messagesStateDetail:
 		m.state = messagesStateList
 		m.reloadMessages()
+	case messagesStateRetention, messagesStatePurge:
+		m.form = nil
+		m.purgeReport = nil
+		m.state = messagesStateAreas
+		m.reloadAreas()
+	}
+}
+
+func retentionSummary(a *message.Area) string {
+	var parts []string
+	if a.MaxMessages > 0 {
+		parts = append(parts, fmt.Sprintf("%d msgs", a.MaxMessages))
+	}
+	if a.MaxAgeDays > 0 {
+		parts = append(parts, fmt.Sprintf("%d days", a.MaxAgeDays))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// toggleWebPublic shows or hides an area on the /forum web viewer.
+func (m *messagesModel) toggleWebPublic(areaID int) {
+	a, err := m.app.Messages.GetArea(areaID)
+	if err != nil {
+		m.err = err
+		return
+	}
+	if err := m.app.Messages.SetWebPublic(areaID, !a.WebPublic); err != nil {
+		m.err = err
+		return
+	}
+	index := m.list.Index()
+	m.reloadAreas()
+	m.list.Select(index)
+}
+
+func (m *messagesModel) startRetention(areaID int) {
+	a, err := m.app.Messages.GetArea(areaID)
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.selectedAreaID = areaID
+	m.state = messagesStateRetention
+	m.retMaxMsgs = strconv.Itoa(a.MaxMessages)
+	m.retMaxAgeDays = strconv.Itoa(a.MaxAgeDays)
+	m.retSave = true
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().Title(fmt.Sprintf("Max messages in %s (0 = unlimited)", a.Name)).
+				Value(&m.retMaxMsgs).Validate(validIntGreaterThan("max messages", -1)),
+			huh.NewInput().Title("Max age in days (0 = keep forever)").
+				Value(&m.retMaxAgeDays).Validate(validIntGreaterThan("max age", -1)),
+		),
+		huh.NewGroup(
+			huh.NewConfirm().Title("Save retention?").Value(&m.retSave),
+		),
+	)
+}
+
+func (m *messagesModel) updateForm(msg tea.Msg) tea.Cmd {
+	updated, cmd := m.form.Update(msg)
+	f, ok := updated.(*huh.Form)
+	if !ok {
+		m.err = fmt.Errorf("internal error: unexpected form model type")
+		return nil
+	}
+	m.form = f
+	if m.form.State == huh.StateCompleted {
+		if m.retSave {
+			maxMsgs, _ := strconv.Atoi(strings.TrimSpace(m.retMaxMsgs))
+			maxAge, _ := strconv.Atoi(strings.TrimSpace(m.retMaxAgeDays))
+			if err := m.app.Messages.SetRetention(m.selectedAreaID, maxMsgs, maxAge); err != nil {
+				m.err = err
+				return nil
+			}
+		}
+		m.back()
+		return nil
+	}
+	return cmd
+}
+
+// runPurge applies area retention limits. A dry run only builds the report
+// shown for confirmation.
+func (m *messagesModel) runPurge(dryRun bool) {
+	report, err := m.app.Messages.PurgeExpired(message.PurgeOptions{
+		Archive: m.app.Config.Maintenance.ArchiveMessages,
+		DryRun:  dryRun,
+	})
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.purgeReport = report
+	m.state = messagesStatePurge
+}
+
+func (m *messagesModel) purgeView() string {
+	var b strings.Builder
+	if m.purgeReport.DryRun {
+		b.WriteString("Purge expired messages (dry run)\n\n")
+	} else {
+		b.WriteString("Purge expired messages\n\n")
+	}
+	for _, line := range m.purgeReport.Lines() {
+		b.WriteString(line + "\n")
+	}
+	switch {
+	case !m.purgeReport.DryRun:
+		b.WriteString("\nDone. (esc back)")
+	case m.purgeReport.Total == 0:
+		b.WriteString("\nNothing to purge. (esc back)")
+	case m.app.Config.Maintenance.ArchiveMessages:
+		b.WriteString("\n(y archive and delete now, esc cancel)")
+	default:
+		b.WriteString("\n(y delete now, esc cancel)")
 	}
+	return b.String()
 }
@@ -0,0 +1,318 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+
+	"github.com/notepid/twilight_bbs/internal/control"
+	"github.com/notepid/twilight_bbs/internal/stats"
+)
+
+// remoteRefresh is how often the remote screen polls the BBS.
+const remoteRefresh = 5 * time.Second
+
+// remoteModel is bbs-admin's -remote mode: it manages a running BBS over
+// its control socket instead of opening the database.
+type remoteModel struct {
+	client *control.Client
+
+	width  int
+	height int
+
+	state  remoteState
+	list   list.Model
+	stats  *control.Stats
+	status string
+	err    error
+
+	form *huh.Form
+
+	kickNode int
+	kickMsg  string
+	kickOK   bool
+
+	broadcastMsg string
+	broadcastOK  bool
+
+	drain       string
+	shutdownMsg string
+	shutdownOK  bool
+}
+
+type remoteState int
+
+const (
+	remoteStateNodes remoteState = iota
+	remoteStateKick
+	remoteStateBroadcast
+	remoteStateShutdown
+)
+
+type remoteTickMsg struct{}
+
+type nodeItem struct {
+	node control.Node
+}
+
+func (i nodeItem) Title() string {
+	return fmt.Sprintf("Node %d: %s", i.node.ID, i.node.User)
+}
+
+func (i nodeItem) Description() string {
+	where := i.node.Menu
+	if i.node.Busy != "" {
+		where = "in " + i.node.Busy
+	}
+	return fmt.Sprintf("%s • %s • online %v • %dK sent, %dK received", i.node.Remote, where,
+		time.Since(i.node.Since).Round(time.Second), i.node.Sent/1024, i.node.Received/1024)
+}
+
+func (i nodeItem) FilterValue() string { return i.node.User }
+
+// NewRemoteModel returns the bbs-admin screen for a running BBS.
+func NewRemoteModel(c *control.Client) tea.Model {
+	l := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+	l.Title = "Nodes"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.SetShowHelp(false)
+	m := &remoteModel{client: c, list: l}
+	m.refresh()
+	return m
+}
+
+func (m *remoteModel) Init() tea.Cmd {
+	return remoteTick()
+}
+
+func remoteTick() tea.Cmd {
+	return tea.Tick(remoteRefresh, func(time.Time) tea.Msg { return remoteTickMsg{} })
+}
+
+func (m *remoteModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.list.SetSize(msg.Width, msg.Height-8)
+		return m, nil
+	case remoteTickMsg:
+		if m.state == remoteStateNodes {
+			m.refresh()
+		}
+		return m, remoteTick()
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" || (m.err != nil && msg.String() == "q") {
+			return m, tea.Quit
+		}
+		if m.err != nil {
+			m.err = nil
+			m.back()
+			return m, nil
+		}
+		if msg.String() == "esc" && m.state != remoteStateNodes {
+			m.back()
+			return m, nil
+		}
+	}
+
+	if m.state != remoteStateNodes {
+		return m, m.updateForm(msg)
+	}
+
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "q", "esc":
+			return m, tea.Quit
+		case "r":
+			m.refresh()
+			return m, nil
+		case "enter":
+			if it, ok := m.list.SelectedItem().(nodeItem); ok {
+				m.startKick(it.node)
+			}
+			return m, nil
+		case "b":
+			m.startBroadcast()
+			return m, nil
+		case "m":
+			if n, err := m.client.ReloadMenus(); err != nil {
+				m.err = err
+			} else {
+				m.status = fmt.Sprintf("Loaded %d menus.", n)
+			}
+			return m, nil
+		case "c":
+			m.reloadConfig()
+			return m, nil
+		case "s":
+			m.startShutdown()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m *remoteModel) updateForm(msg tea.Msg) tea.Cmd {
+	updated, cmd := m.form.Update(msg)
+	f, ok := updated.(*huh.Form)
+	if !ok {
+		m.err = fmt.Errorf("internal error: unexpected form model type")
+		return nil
+	}
+	m.form = f
+	if m.form.State != huh.StateCompleted {
+		return cmd
+	}
+
+	switch m.state {
+	case remoteStateKick:
+		if m.kickOK {
+			if err := m.client.Kick(m.kickNode, m.kickMsg); err != nil {
+				m.err = err
+				return nil
+			}
+			m.status = fmt.Sprintf("Node %d disconnected.", m.kickNode)
+		}
+	case remoteStateBroadcast:
+		if m.broadcastOK {
+			n, err := m.client.Broadcast(m.broadcastMsg)
+			if err != nil {
+				m.err = err
+				return nil
+			}
+			m.status = fmt.Sprintf("Sent to %d node(s).", n)
+		}
+	case remoteStateShutdown:
+		if m.shutdownOK {
+			drain, _ := time.ParseDuration(m.drain)
+			if err := m.client.Shutdown(drain, m.shutdownMsg); err != nil {
+				m.err = err
+				return nil
+			}
+			return tea.Quit
+		}
+	}
+	m.back()
+	return nil
+}
+
+func (m *remoteModel) back() {
+	m.state = remoteStateNodes
+	m.form = nil
+	m.refresh()
+}
+
+func (m *remoteModel) refresh() {
+	nodes, err := m.client.Nodes()
+	if err != nil {
+		m.err = err
+		return
+	}
+	items := make([]list.Item, 0, len(nodes))
+	for _, n := range nodes {
+		items = append(items, nodeItem{n})
+	}
+	m.list.SetItems(items)
+
+	if m.stats, err = m.client.Stats(); err != nil {
+		m.err = err
+	}
+}
+
+func (m *remoteModel) reloadConfig() {
+	r, err := m.client.ReloadConfig()
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.status = "Applied: " + strings.Join(r.Applied, ", ")
+	if len(r.Restart) > 0 {
+		m.status += " • needs a restart: " + strings.Join(r.Restart, ", ")
+	}
+}
+
+func (m *remoteModel) startKick(n control.Node) {
+	m.state = remoteStateKick
+	m.kickNode = n.ID
+	m.kickMsg = ""
+	m.kickOK = false
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().Title("Message shown before disconnecting (optional)").Value(&m.kickMsg),
+			huh.NewConfirm().Title(fmt.Sprintf("Disconnect node %d (%s)?", n.ID, n.User)).Value(&m.kickOK),
+		),
+	)
+}
+
+func (m *remoteModel) startBroadcast() {
+	m.state = remoteStateBroadcast
+	m.broadcastMsg = ""
+	m.broadcastOK = true
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().Title("Message to all nodes").Value(&m.broadcastMsg).Validate(nonEmpty("message")),
+			huh.NewConfirm().Title("Send?").Value(&m.broadcastOK),
+		),
+	)
+}
+
+func (m *remoteModel) startShutdown() {
+	m.state = remoteStateShutdown
+	m.drain = "5m"
+	m.shutdownMsg = ""
+	m.shutdownOK = false
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().Title("Count down for (e.g. 5m, 0 = now)").Value(&m.drain).Validate(func(s string) error {
+				if d, err := time.ParseDuration(s); err != nil || d < 0 {
+					return fmt.Errorf("enter a duration like 30s or 5m")
+				}
+				return nil
+			}),
+			huh.NewInput().Title("Message to callers (optional)").Value(&m.shutdownMsg),
+			huh.NewConfirm().Title("Shut down the BBS?").Value(&m.shutdownOK),
+		),
+	)
+}
+
+func (m *remoteModel) View() string {
+	if m.err != nil {
+		return fmt.Sprintf("Remote error: %v\n\nPress q to quit, any other key to retry.", m.err)
+	}
+	if m.state != remoteStateNodes {
+		return m.form.View() + "\n\n(esc to go back)"
+	}
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Twilight BBS Admin (remote)"))
+	b.WriteString("\n")
+	if st := m.stats; st != nil {
+		fmt.Fprintf(&b, "Up %v • nodes %d/%d", time.Since(st.Started).Round(time.Second), st.Nodes, st.MaxNodes)
+		if st.Draining {
+			b.WriteString(" • draining")
+		}
+		fmt.Fprintf(&b, "\nToday: %d calls, %d new users, %d posts, %d up / %d down, %d door launches\n",
+			st.Today[stats.Calls], st.Today[stats.NewUsers], st.Today[stats.Posts],
+			st.Today[stats.Uploads], st.Today[stats.Downloads], st.Today[stats.DoorLaunches])
+	}
+	b.WriteString("\n")
+	if len(m.list.Items()) == 0 {
+		b.WriteString("No nodes online.\n")
+	} else {
+		b.WriteString(m.list.View())
+		b.WriteString("\n")
+	}
+	if m.status != "" {
+		b.WriteString("\n" + m.status + "\n")
+	}
+	b.WriteString("\n(enter kick • b broadcast • m reload menus • c reload config • s shutdown • r refresh • q quit)")
+	return b.String()
+}
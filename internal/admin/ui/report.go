@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// reportModel shows the text output of a check (system check, menu check)
+// and can re-run it.
+type reportModel struct {
+	title   string
+	run     func() []string
+	actions []reportAction
+	status  string
+
+	width  int
+	height int
+
+	Done bool
+
+	lines []string
+}
+
+// reportAction is a key a report offers besides re-running it. The report
+// is re-run after the action.
+type reportAction struct {
+	key  string
+	help string // e.g. "b to back up"
+	run  func() (string, error)
+}
+
+func newReportModel(title string, run func() []string) *reportModel {
+	return &reportModel{title: title, run: run, lines: run()}
+}
+
+func (m *reportModel) SetSize(w, h int) {
+	m.width, m.height = w, h
+}
+
+func (m *reportModel) Update(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q", "enter":
+			m.Done = true
+		case "r":
+			m.status = ""
+			m.lines = m.run()
+		default:
+			for _, a := range m.actions {
+				if msg.String() == a.key {
+					m.status = ""
+					if out, err := a.run(); err != nil {
+						m.status = errStyle.Render("Error: ") + err.Error()
+					} else {
+						m.status = out
+					}
+					m.lines = m.run()
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (m *reportModel) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(m.title))
+	b.WriteString("\n\n")
+	for _, line := range m.lines {
+		if strings.HasPrefix(line, "[FAIL]") || strings.HasPrefix(line, "[ERR ]") {
+			line = errStyle.Render(line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if m.status != "" {
+		b.WriteString("\n" + m.status + "\n")
+	}
+	b.WriteString("\n(r to re-run")
+	for _, a := range m.actions {
+		b.WriteString(", " + a.help)
+	}
+	b.WriteString(", esc to go back)")
+	return b.String()
+}
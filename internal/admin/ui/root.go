@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/bubbles/list"
@@ -18,6 +19,13 @@ const (
 	screenUsers
 	screenMessages
 	screenFiles
+	screenBulletins
+	screenSystem
+	screenMenus
+	screenActivity
+	screenStats
+	screenDoors
+	screenDatabase
 )
 
 type rootModel struct {
@@ -31,10 +39,17 @@ type rootModel struct {
 	homeList list.Model
 	err     error
 
-	settings *settingsModel
-	users    *usersModel
-	messages *messagesModel
-	files    *filesModel
+	settings  *settingsModel
+	users     *usersModel
+	messages  *messagesModel
+	files     *filesModel
+	bulletins *bulletinsModel
+	system    *reportModel
+	menus     *reportModel
+	activity  *reportModel
+	stats     *reportModel
+	doors     *reportModel
+	database  *reportModel
 }
 
 type menuItem struct {
@@ -58,6 +73,13 @@ func NewRootModel(a *app.App) tea.Model {
 		menuItem{title: "Users", desc: "Manage user accounts", to: screenUsers},
 		menuItem{title: "Messages", desc: "View message areas and messages", to: screenMessages},
 		menuItem{title: "File Areas", desc: "View file areas and entries", to: screenFiles},
+		menuItem{title: "Bulletins", desc: "Post and edit login bulletins", to: screenBulletins},
+		menuItem{title: "System Check", desc: "Verify dosemu2, SEXYZ and door directories", to: screenSystem},
+		menuItem{title: "Menu Check", desc: "Find broken menu links and unreachable menus", to: screenMenus},
+		menuItem{title: "Activity", desc: "Calls by hour and weekday, peak and quiet hours", to: screenActivity},
+		menuItem{title: "Statistics", desc: "Daily calls, posts, transfers and door launches", to: screenStats},
+		menuItem{title: "Door Usage", desc: "Most played doors and time spent in them", to: screenDoors},
+		menuItem{title: "Database", desc: "Integrity check, live backup and vacuum", to: screenDatabase},
 		menuItem{title: "Quit", desc: "Exit", to: -1},
 	}
 
@@ -95,6 +117,27 @@ func (m *rootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.files != nil {
 			m.files.SetSize(msg.Width, msg.Height)
 		}
+		if m.bulletins != nil {
+			m.bulletins.SetSize(msg.Width, msg.Height)
+		}
+		if m.system != nil {
+			m.system.SetSize(msg.Width, msg.Height)
+		}
+		if m.menus != nil {
+			m.menus.SetSize(msg.Width, msg.Height)
+		}
+		if m.activity != nil {
+			m.activity.SetSize(msg.Width, msg.Height)
+		}
+		if m.stats != nil {
+			m.stats.SetSize(msg.Width, msg.Height)
+		}
+		if m.doors != nil {
+			m.doors.SetSize(msg.Width, msg.Height)
+		}
+		if m.database != nil {
+			m.database.SetSize(msg.Width, msg.Height)
+		}
 		return m, nil
 	case tea.KeyMsg:
 		if msg.Type == tea.KeyCtrlC {
@@ -149,6 +192,83 @@ func (m *rootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.files = nil
 		}
 		return m, cmd
+	case screenBulletins:
+		if m.bulletins == nil {
+			m.bulletins = newBulletinsModel(m.app)
+			m.bulletins.SetSize(m.width, m.height)
+		}
+		cmd := m.bulletins.Update(msg)
+		if m.bulletins.Done {
+			m.active = screenHome
+			m.bulletins = nil
+		}
+		return m, cmd
+	case screenSystem:
+		if m.system == nil {
+			m.system = newSystemModel(m.app)
+			m.system.SetSize(m.width, m.height)
+		}
+		cmd := m.system.Update(msg)
+		if m.system.Done {
+			m.active = screenHome
+			m.system = nil
+		}
+		return m, cmd
+	case screenMenus:
+		if m.menus == nil {
+			m.menus = newMenusModel(m.app)
+			m.menus.SetSize(m.width, m.height)
+		}
+		cmd := m.menus.Update(msg)
+		if m.menus.Done {
+			m.active = screenHome
+			m.menus = nil
+		}
+		return m, cmd
+	case screenActivity:
+		if m.activity == nil {
+			m.activity = newActivityModel(m.app)
+			m.activity.SetSize(m.width, m.height)
+		}
+		cmd := m.activity.Update(msg)
+		if m.activity.Done {
+			m.active = screenHome
+			m.activity = nil
+		}
+		return m, cmd
+	case screenStats:
+		if m.stats == nil {
+			m.stats = newStatsModel(m.app)
+			m.stats.SetSize(m.width, m.height)
+		}
+		cmd := m.stats.Update(msg)
+		if m.stats.Done {
+			m.active = screenHome
+			m.stats = nil
+		}
+		return m, cmd
+	case screenDoors:
+		if m.doors == nil {
+			m.doors = newDoorsModel(m.app)
+			m.doors.SetSize(m.width, m.height)
+		}
+		cmd := m.doors.Update(msg)
+		if m.doors.Done {
+			m.active = screenHome
+			m.doors = nil
+		}
+		return m, cmd
+	case screenDatabase:
+		if m.database == nil {
+			m.database = newDatabaseModel(m.app)
+			m.database.SetSize(m.width, m.height)
+		}
+		cmd := m.database.Update(msg)
+		if m.database.Done {
+			m.active = screenHome
+			m.database = nil
+		}
+		return m, cmd
 	default:
 		return m, nil
 	}
@@ -199,9 +319,97 @@ func (m *rootModel) activate(s screen) {
 			m.files = newFilesModel(m.app)
 			m.files.SetSize(m.width, m.height)
 		}
+	case screenBulletins:
+		if m.bulletins == nil {
+			m.bulletins = newBulletinsModel(m.app)
+			m.bulletins.SetSize(m.width, m.height)
+		}
+	case screenSystem:
+		if m.system == nil {
+			m.system = newSystemModel(m.app)
+			m.system.SetSize(m.width, m.height)
+		}
+	case screenMenus:
+		if m.menus == nil {
+			m.menus = newMenusModel(m.app)
+			m.menus.SetSize(m.width, m.height)
+		}
+	case screenActivity:
+		if m.activity == nil {
+			m.activity = newActivityModel(m.app)
+			m.activity.SetSize(m.width, m.height)
+		}
+	case screenStats:
+		if m.stats == nil {
+			m.stats = newStatsModel(m.app)
+			m.stats.SetSize(m.width, m.height)
+		}
+	case screenDoors:
+		if m.doors == nil {
+			m.doors = newDoorsModel(m.app)
+			m.doors.SetSize(m.width, m.height)
+		}
+	case screenDatabase:
+		if m.database == nil {
+			m.database = newDatabaseModel(m.app)
+			m.database.SetSize(m.width, m.height)
+		}
 	}
 }
 
+func newSystemModel(a *app.App) *reportModel {
+	return newReportModel("System Check", func() []string {
+		return a.Preflight().Lines()
+	})
+}
+
+func newMenusModel(a *app.App) *reportModel {
+	return newReportModel("Menu Check", a.MenuCheck)
+}
+
+// activityDays is the window the Activity screen covers.
+const activityDays = 90
+
+func newActivityModel(a *app.App) *reportModel {
+	return newReportModel(fmt.Sprintf("Activity (last %d days)", activityDays), func() []string {
+		act, err := a.Callers.Activity(time.Now().AddDate(0, 0, -activityDays))
+		if err != nil {
+			return []string{"[ERR ] " + err.Error()}
+		}
+		return act.Lines()
+	})
+}
+
+// statsDays is the window the Statistics screen covers.
+const statsDays = 30
+
+func newStatsModel(a *app.App) *reportModel {
+	return newReportModel(fmt.Sprintf("Statistics (last %d days)", statsDays), func() []string {
+		return a.StatsReport(statsDays)
+	})
+}
+
+func newDoorsModel(a *app.App) *reportModel {
+	return newReportModel(fmt.Sprintf("Door Usage (last %d days)", statsDays), func() []string {
+		return a.DoorReport(statsDays)
+	})
+}
+
+func newDatabaseModel(a *app.App) *reportModel {
+	m := newReportModel("Database", a.DatabaseReport)
+	m.actions = []reportAction{
+		{key: "b", help: "b to back up", run: func() (string, error) {
+			path, err := a.BackupDatabase()
+			if err != nil {
+				return "", err
+			}
+			return "Backed up to " + path + ".", nil
+		}},
+		{key: "v", help: "v to vacuum", run: a.VacuumDatabase},
+	}
+	return m
+}
+
 func (m *rootModel) View() string {
 	if m.err != nil {
 		return errStyle.Render("Error: ") + m.err.Error()
@@ -230,6 +438,41 @@ func (m *rootModel) View() string {
 			return "Loading files..."
 		}
 		return m.files.View()
+	case screenBulletins:
+		if m.bulletins == nil {
+			return "Loading bulletins..."
+		}
+		return m.bulletins.View()
+	case screenSystem:
+		if m.system == nil {
+			return "Running checks..."
+		}
+		return m.system.View()
+	case screenMenus:
+		if m.menus == nil {
+			return "Checking menus..."
+		}
+		return m.menus.View()
+	case screenActivity:
+		if m.activity == nil {
+			return "Loading activity..."
+		}
+		return m.activity.View()
+	case screenStats:
+		if m.stats == nil {
+			return "Loading statistics..."
+		}
+		return m.stats.View()
+	case screenDoors:
+		if m.doors == nil {
+			return "Loading door usage..."
+		}
+		return m.doors.View()
+	case screenDatabase:
+		if m.database == nil {
+			return "Checking the database..."
+		}
+		return m.database.View()
 	default:
 		return titleStyle.Render("Unknown screen") + "\n" + fmt.Sprint(m.active)
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/bubbles/list"
@@ -51,6 +52,18 @@ type usersModel struct {
 
 	ansiEnabled bool
 	ansiSave    bool
+
+	flags     string
+	flagsSave bool
+
+	sshKeys    string
+	sshKeySave bool
+
+	totpReset bool
+
+	stateAction string
+	stateReason string
+	stateSave   bool
 }
 
 type usersState int
@@ -63,6 +76,10 @@ const (
 	usersStateResetPassword
 	usersStateSetLevel
 	usersStateSetANSI
+	usersStateSetFlags
+	usersStateSSHKeys
+	usersStateResetTOTP
+	usersStateAccount
 )
 
 type userItem struct {
@@ -121,7 +138,7 @@ func (m *usersModel) Update(msg tea.Msg) tea.Cmd {
 		return m.updateList(msg)
 	case usersStateDetail:
 		return m.updateDetail(msg)
-	case usersStateCreate, usersStateEditProfile, usersStateResetPassword, usersStateSetLevel, usersStateSetANSI:
+	case usersStateCreate, usersStateEditProfile, usersStateResetPassword, usersStateSetLevel, usersStateSetANSI, usersStateSetFlags, usersStateSSHKeys, usersStateResetTOTP, usersStateAccount:
 		return m.updateForm(msg)
 	default:
 		return nil
@@ -177,8 +194,16 @@ func (m *usersModel) updateDetail(msg tea.Msg) tea.Cmd {
 				m.startSetLevel()
 			case "set_ansi":
 				m.startSetANSI()
+			case "set_flags":
+				m.startSetFlags()
 			case "reset_password":
 				m.startResetPassword()
+			case "ssh_keys":
+				m.startSSHKeys()
+			case "reset_totp":
+				m.startResetTOTP()
+			case "account":
+				m.startAccount()
 			case "back":
 				m.back()
 			}
@@ -247,7 +272,7 @@ func (m *usersModel) updateForm(msg tea.Msg) tea.Cmd {
 					m.err = err
 					return nil
 				}
-				if err := m.app.Users.UpdateSecurityLevel(m.selected.ID, lvl); err != nil {
+				if err := m.app.Users.SetLevel(m.selected.ID, lvl); err != nil {
 					m.err = err
 					return nil
 				}
@@ -267,6 +292,48 @@ func (m *usersModel) updateForm(msg tea.Msg) tea.Cmd {
 			m.form = nil
 			m.state = usersStateDetail
 			m.list = newActionList(m.width, m.height)
+		case usersStateSetFlags:
+			if m.flagsSave && m.selected != nil {
+				if err := m.app.Users.SetFlags(m.selected.ID, m.flags); err != nil {
+					m.err = err
+					return nil
+				}
+			}
+			m.refreshSelected()
+			m.form = nil
+			m.state = usersStateDetail
+			m.list = newActionList(m.width, m.height)
+		case usersStateSSHKeys:
+			if m.sshKeySave && m.selected != nil {
+				if err := m.app.Users.SetSSHKeys(m.selected.ID, strings.Split(m.sshKeys, "\n")); err != nil {
+					m.err = err
+					return nil
+				}
+			}
+			m.form = nil
+			m.state = usersStateDetail
+			m.list = newActionList(m.width, m.height)
+		case usersStateResetTOTP:
+			if m.totpReset && m.selected != nil {
+				if err := m.app.Users.DisableTOTP(m.selected.ID); err != nil {
+					m.err = err
+					return nil
+				}
+			}
+			m.form = nil
+			m.state = usersStateDetail
+			m.list = newActionList(m.width, m.height)
+		case usersStateAccount:
+			if m.stateSave && m.selected != nil {
+				if err := m.applyAccountAction(); err != nil {
+					m.err = err
+					return nil
+				}
+			}
+			m.refreshSelected()
+			m.form = nil
+			m.state = usersStateDetail
+			m.list = newActionList(m.width, m.height)
 		}
 		return nil
 	}
@@ -286,12 +353,14 @@ func (m *usersModel) View() string {
 		if m.selected == nil {
 			return "No user selected\n\n(esc to go back)"
 		}
-		header := fmt.Sprintf("User: %s (level %d)\n", m.selected.Username, m.selected.SecurityLevel)
-		meta := fmt.Sprintf("Real name: %s\nLocation: %s\nEmail: %s\nANSI: %v\nTotal calls: %d\n\n",
-			m.selected.RealName, m.selected.Location, m.selected.Email, m.selected.ANSIEnabled, m.selected.TotalCalls,
+		header := fmt.Sprintf("User: %s (level %d, %s)\n", m.selected.Username, m.selected.SecurityLevel, m.app.Users.LevelName(m.selected.SecurityLevel))
+		header += "Account: " + accountStatus(m.selected) + "\n"
+		meta := fmt.Sprintf("Real name: %s\nLocation: %s\nEmail: %s\nANSI: %v\nFlags: %s\nTotal calls: %d\nPosts: %d\nTime online: %d min\nUploaded/downloaded: %d/%d bytes\n",
+			m.selected.RealName, m.selected.Location, m.selected.Email, m.selected.ANSIEnabled, m.selected.Flags, m.selected.TotalCalls,
+			m.selected.TotalPosts, m.selected.TimeUsedSecs/60, m.selected.BytesUploaded, m.selected.BytesDownloaded,
 		)
 		m.list.Title = "Actions"
-		return header + meta + m.list.View() + "\n(esc to go back)"
+		return header + meta + m.twoFactorStatus() + "\n" + m.doorPlaytime() + "\n\n" + m.list.View() + "\n(esc to go back)"
 	default:
 		return m.form.View() + "\n\n(esc to go back)"
 	}
@@ -307,7 +376,10 @@ func (m *usersModel) reloadList() {
 	items := make([]list.Item, 0, len(users)+1)
 	items = append(items, userItem{title: "+ Create new user", desc: "Add a new account", kind: "create"})
 	for _, u := range users {
-		desc := fmt.Sprintf("level %d • calls %d", u.SecurityLevel, u.TotalCalls)
+		desc := fmt.Sprintf("level %d %s • calls %d", u.SecurityLevel, m.app.Users.LevelName(u.SecurityLevel), u.TotalCalls)
+		if u.State != user.StateActive {
+			desc += " • " + string(u.State)
+		}
 		items = append(items, userItem{id: u.ID, title: u.Username, desc: desc, kind: "user"})
 	}
 
@@ -321,9 +393,13 @@ func (m *usersModel) reloadList() {
 func newActionList(w, h int) list.Model {
 	items := []list.Item{
 		userItem{title: "Edit profile", desc: "Real name, location, email", kind: "edit_profile"},
-		userItem{title: "Set security level", desc: "New/Validated/Regular/Trusted/CoSysop/Sysop", kind: "set_level"},
+		userItem{title: "Set security level", desc: "Pick a level from the level table", kind: "set_level"},
 		userItem{title: "Toggle ANSI", desc: "Enable/disable ANSI for user", kind: "set_ansi"},
+		userItem{title: "Set flags", desc: "Group flags A-Z for menu access", kind: "set_flags"},
 		userItem{title: "Reset password", desc: "Set a new password", kind: "reset_password"},
+		userItem{title: "SSH keys", desc: "Public keys for SSH login and exec commands", kind: "ssh_keys"},
+		userItem{title: "Reset two-factor", desc: "Remove TOTP enrollment and backup codes (lost device)", kind: "reset_totp"},
+		userItem{title: "Lock / unlock / delete", desc: "Change whether the account can log in", kind: "account"},
 		userItem{title: "Back", desc: "Return to users list", kind: "back"},
 	}
 	l := list.New(items, list.NewDefaultDelegate(), w, h-8)
@@ -398,15 +474,15 @@ func (m *usersModel) startSetLevel() {
 	m.state = usersStateSetLevel
 	m.levelChoice = fmt.Sprintf("%d", m.selected.SecurityLevel)
 	m.levelSave = true
-	options := []huh.Option[string]{
-		huh.NewOption("New (10)", "new"),
-		huh.NewOption("Validated (20)", "validated"),
-		huh.NewOption("Regular (30)", "regular"),
-		huh.NewOption("Trusted (50)", "trusted"),
-		huh.NewOption("CoSysop (90)", "cosysop"),
-		huh.NewOption("Sysop (100)", "sysop"),
-		huh.NewOption("Custom (type number)", "custom"),
+	levels, err := m.app.Users.Levels()
+	if err != nil {
+		m.err = err
 	}
+	var options []huh.Option[string]
+	for _, l := range levels {
+		options = append(options, huh.NewOption(fmt.Sprintf("%s (%d)", l.Name, l.Value), strconv.Itoa(l.Value)))
+	}
+	options = append(options, huh.NewOption("Custom (type number)", "custom"))
 
 	custom := ""
 	m.form = huh.NewForm(
@@ -444,6 +520,151 @@ func (m *usersModel) startSetANSI() {
 	)
 }
 
+func (m *usersModel) startSetFlags() {
+	m.state = usersStateSetFlags
+	m.flags = m.selected.Flags
+	m.flagsSave = true
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().Title("Flags (letters A-Z)").Value(&m.flags).Validate(func(s string) error {
+				_, err := user.NormalizeFlags(s)
+				return err
+			}),
+		),
+		huh.NewGroup(
+			huh.NewConfirm().Title("Save flags?").Value(&m.flagsSave),
+		),
+	)
+}
+
+func (m *usersModel) startSSHKeys() {
+	keys, err := m.app.Users.ListSSHKeys(m.selected.ID)
+	if err != nil {
+		m.err = err
+		return
+	}
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k.AuthorizedKey)
+	}
+
+	m.state = usersStateSSHKeys
+	m.sshKeys = strings.Join(lines, "\n")
+	m.sshKeySave = true
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewText().Title("Authorized keys").
+				Description("One OpenSSH public key per line (ssh-ed25519 AAAA... comment)").
+				Lines(6).Value(&m.sshKeys),
+		),
+		huh.NewGroup(
+			huh.NewConfirm().Title("Save keys?").Value(&m.sshKeySave),
+		),
+	)
+}
+
+func (m *usersModel) startResetTOTP() {
+	m.state = usersStateResetTOTP
+	m.totpReset = false
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().Title("Reset two-factor authentication for " + m.selected.Username + "?").
+				Description("The user logs in with just a password until they enroll again.").
+				Value(&m.totpReset),
+		),
+	)
+}
+
+func (m *usersModel) startAccount() {
+	m.state = usersStateAccount
+	m.stateReason = ""
+	m.stateSave = false
+	options := accountActions(m.selected)
+	m.stateAction = options[0].Value
+	m.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().Title("Account " + m.selected.Username + " is " + accountStatus(m.selected)).
+				Options(options...).Value(&m.stateAction),
+			huh.NewInput().Title("Reason (shown at login when locking)").Value(&m.stateReason),
+			huh.NewConfirm().Title("Apply?").
+				Description("Purging cannot be undone: the account is renamed and its personal data removed.").
+				Value(&m.stateSave),
+		),
+	)
+}
+
+// accountActions lists the state changes open to u.
+func accountActions(u *user.User) []huh.Option[string] {
+	switch u.State {
+	case user.StateLocked, user.StateExpired:
+		return []huh.Option[string]{huh.NewOption("Unlock", "unlock"), huh.NewOption("Delete", "delete")}
+	case user.StateDeleted:
+		if u.Purged() {
+			return []huh.Option[string]{huh.NewOption("Nothing (purged)", "")}
+		}
+		return []huh.Option[string]{huh.NewOption("Restore", "restore"), huh.NewOption("Purge now", "purge")}
+	}
+	return []huh.Option[string]{huh.NewOption("Lock", "lock"), huh.NewOption("Delete", "delete")}
+}
+
+func (m *usersModel) applyAccountAction() error {
+	id := m.selected.ID
+	switch m.stateAction {
+	case "lock":
+		return m.app.Users.Lock(id, strings.TrimSpace(m.stateReason))
+	case "unlock":
+		return m.app.Users.Unlock(id)
+	case "delete":
+		return m.app.Users.Delete(id)
+	case "restore":
+		return m.app.Users.Restore(id)
+	case "purge":
+		return m.app.Users.Purge(id)
+	}
+	return nil
+}
+
+// accountStatus describes where u is in the account lifecycle.
+func accountStatus(u *user.User) string {
+	switch {
+	case u.Purged():
+		return "purged"
+	case u.State == user.StateLocked && u.StateReason != "":
+		return "locked (" + u.StateReason + ")"
+	}
+	return string(u.State)
+}
+
+// twoFactorStatus describes the selected user's TOTP enrollment.
+func (m *usersModel) twoFactorStatus() string {
+	on, err := m.app.Users.TOTPEnabled(m.selected.ID)
+	if err != nil {
+		return "Two-factor: " + err.Error()
+	}
+	if !on {
+		return "Two-factor: off"
+	}
+	left, _ := m.app.Users.BackupCodesLeft(m.selected.ID)
+	return fmt.Sprintf("Two-factor: on (%d backup codes left)", left)
+}
+
+// doorPlaytime lists the selected user's time in each door over the last
+// 30 days.
+func (m *usersModel) doorPlaytime() string {
+	usage, err := m.app.Doors.UserPlaytime(m.selected.ID, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		return "Doors: " + err.Error()
+	}
+	if len(usage) == 0 {
+		return "Doors (30 days): none"
+	}
+	parts := make([]string, 0, len(usage))
+	for _, u := range usage {
+		parts = append(parts, fmt.Sprintf("%s %dm", u.Door, u.Seconds/60))
+	}
+	return "Doors (30 days): " + strings.Join(parts, ", ")
+}
+
 func (m *usersModel) back() {
 	switch m.state {
 	case usersStateList:
@@ -471,25 +692,9 @@ func (m *usersModel) refreshSelected() {
 }
 
 func parseLevelChoice(choice string) (int, error) {
-	s := strings.ToLower(strings.TrimSpace(choice))
-	switch s {
-	case "new":
-		return user.LevelNew, nil
-	case "validated":
-		return user.LevelValidated, nil
-	case "regular":
-		return user.LevelRegular, nil
-	case "trusted":
-		return user.LevelTrusted, nil
-	case "cosysop":
-		return user.LevelCoSysop, nil
-	case "sysop":
-		return user.LevelSysop, nil
-	default:
-		v, err := strconv.Atoi(s)
-		if err != nil {
-			return 0, fmt.Errorf("invalid level")
-		}
-		return v, nil
+	v, err := strconv.Atoi(strings.TrimSpace(choice))
+	if err != nil {
+		return 0, fmt.Errorf("invalid level")
 	}
+	return v, nil
 }
@@ -0,0 +1,328 @@
+// Package announce posts the messages of an announcements area to a
+// Mastodon account or an ActivityPub outbox, so the sysop's news reaches
+// people who never call the board. New messages are picked up by polling;
+// each is posted once, rendered through a template and cut to the
+// server's length limit, and no more than a set number go out per hour.
+package announce
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+// Kinds of server the bridge posts to.
+const (
+	KindMastodon    = "mastodon"    // the Mastodon statuses API
+	KindActivityPub = "activitypub" // a Create activity POSTed to an outbox
+)
+
+// DefaultTemplate is the text/template of a post. It is given a Post.
+const DefaultTemplate = `{{.Subject}}
+
+{{.Body}}{{if .Link}}
+
+{{.Link}}{{end}}`
+
+// Config is where and how the bridge posts.
+type Config struct {
+	Area       int    // message area announced
+	Kind       string // KindMastodon or KindActivityPub
+	Server     string // Mastodon instance, e.g. "https://mastodon.social"
+	Outbox     string // ActivityPub outbox URL
+	Actor      string // ActivityPub actor ID the notes are attributed to
+	Token      string // bearer token
+	Visibility string // Mastodon visibility: public, unlisted, private
+	Template   string // text/template of a post, "" = DefaultTemplate
+	MaxLength  int    // characters per post, default 500
+	MaxPerHour int    // posts per hour, 0 = unlimited
+	Replies    bool   // also announce replies, not only new threads
+	BBS        string // board name, for templates
+	BaseURL    string // public URL of the web viewer, for links; "" = none
+	Timeout    time.Duration
+}
+
+// Post is what the template is given for a message.
+type Post struct {
+	BBS     string
+	Area    string
+	From    string
+	Subject string
+	Body    string // plain text, colours removed, cut to fit
+	Link    string // the message on the web viewer, "" when not shown there
+	Date    time.Time
+}
+
+// Bridge posts new messages in the area.
+type Bridge struct {
+	mu     sync.Mutex // one run at a time
+	db     *sql.DB
+	msgs   *message.Repo
+	cfg    Config
+	tmpl   *template.Template
+	client *http.Client
+}
+
+// New creates a bridge for cfg.
+func New(db *sql.DB, msgs *message.Repo, cfg Config) (*Bridge, error) {
+	if cfg.Kind != KindMastodon && cfg.Kind != KindActivityPub {
+		return nil, fmt.Errorf("announce: unknown kind %q", cfg.Kind)
+	}
+	if cfg.Template == "" {
+		cfg.Template = DefaultTemplate
+	}
+	if cfg.MaxLength <= 0 {
+		cfg.MaxLength = 500
+	}
+	if cfg.Visibility == "" {
+		cfg.Visibility = "public"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	cfg.Server = strings.TrimSuffix(cfg.Server, "/")
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	tmpl, err := template.New("announce").Parse(cfg.Template)
+	if err != nil {
+		return nil, fmt.Errorf("announce template: %w", err)
+	}
+	return &Bridge{
+		db:     db,
+		msgs:   msgs,
+		cfg:    cfg,
+		tmpl:   tmpl,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Run posts the messages written in the area since the last run, oldest
+// first, until the hourly limit is reached. The first run only notes where
+// the area is, so turning the bridge on does not post old news. A failed
+// post stops the run; it is tried again next time.
+func (b *Bridge) Run() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var last int
+	err := b.db.QueryRow(`SELECT last_message_id FROM announce_cursor WHERE area_id = ?`, b.cfg.Area).Scan(&last)
+	if errors.Is(err, sql.ErrNoRows) {
+		if last, err = b.msgs.LastID(b.cfg.Area); err != nil {
+			return 0, err
+		}
+		return 0, b.advance(last)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("announce cursor: %w", err)
+	}
+
+	budget := -1
+	if b.cfg.MaxPerHour > 0 {
+		var recent int
+		if err := b.db.QueryRow(`SELECT COUNT(*) FROM announce_posts WHERE posted_at > ?`,
+			time.Now().Add(-time.Hour)).Scan(&recent); err != nil {
+			return 0, fmt.Errorf("announce rate: %w", err)
+		}
+		if budget = b.cfg.MaxPerHour - recent; budget <= 0 {
+			return 0, nil
+		}
+	}
+
+	msgs, err := b.msgs.MessagesAfter(b.cfg.Area, last)
+	if err != nil {
+		return 0, fmt.Errorf("announce: %w", err)
+	}
+	area, err := b.msgs.GetArea(b.cfg.Area)
+	if err != nil {
+		return 0, fmt.Errorf("announce: %w", err)
+	}
+	posted := 0
+	for _, m := range msgs {
+		if budget >= 0 && posted >= budget {
+			break
+		}
+		if m.ToUserID != nil || (m.ReplyToID != nil && !b.cfg.Replies) {
+			if err := b.advance(m.ID); err != nil {
+				return posted, err
+			}
+			continue
+		}
+		text, err := b.Render(area, m)
+		if err != nil {
+			return posted, err
+		}
+		url, err := b.send(m, text)
+		if err != nil {
+			return posted, fmt.Errorf("announce message %d: %w", m.ID, err)
+		}
+		if _, err := b.db.Exec(`INSERT OR REPLACE INTO announce_posts (message_id, url, posted_at) VALUES (?, ?, ?)`,
+			m.ID, url, time.Now()); err != nil {
+			return posted, fmt.Errorf("announce log: %w", err)
+		}
+		if err := b.advance(m.ID); err != nil {
+			return posted, err
+		}
+		posted++
+	}
+	return posted, nil
+}
+
+// advance records that messages up to id have been handled.
+func (b *Bridge) advance(id int) error {
+	_, err := b.db.Exec(`
+		INSERT INTO announce_cursor (area_id, last_message_id) VALUES (?, ?)
+		ON CONFLICT(area_id) DO UPDATE SET last_message_id = excluded.last_message_id
+	`, b.cfg.Area, id)
+	if err != nil {
+		return fmt.Errorf("announce cursor: %w", err)
+	}
+	return nil
+}
+
+// Render fills in the template for a message. The body is cut, on a word
+// where it can be, so that the whole post fits in MaxLength.
+func (b *Bridge) Render(area *message.Area, m *message.Message) (string, error) {
+	p := Post{
+		BBS:     b.cfg.BBS,
+		Area:    area.Name,
+		From:    m.FromName,
+		Subject: m.Subject,
+		Date:    m.CreatedAt,
+	}
+	// A thread on the web viewer has its first message's ID; replies link
+	// to the area.
+	if b.cfg.BaseURL != "" && area.WebPublic {
+		if m.ReplyToID == nil {
+			p.Link = fmt.Sprintf("%s/forum/%d/%d", b.cfg.BaseURL, area.ID, m.ID)
+		} else {
+			p.Link = fmt.Sprintf("%s/forum/%d", b.cfg.BaseURL, area.ID)
+		}
+	}
+
+	frame, err := b.execute(p)
+	if err != nil {
+		return "", err
+	}
+	room := b.cfg.MaxLength - len([]rune(frame))
+	p.Body = cut(strings.TrimSpace(ansi.PlainText(m.Body, 0)), room)
+	text, err := b.execute(p)
+	if err != nil {
+		return "", err
+	}
+	if r := []rune(text); len(r) > b.cfg.MaxLength {
+		text = string(r[:b.cfg.MaxLength])
+	}
+	return strings.TrimSpace(text), nil
+}
+
+func (b *Bridge) execute(p Post) (string, error) {
+	var buf bytes.Buffer
+	if err := b.tmpl.Execute(&buf, p); err != nil {
+		return "", fmt.Errorf("announce template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// cut shortens s to at most n characters, ending in "…" when it had to.
+func cut(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 1 {
+		return ""
+	}
+	r = r[:n-1]
+	if i := strings.LastIndexAny(string(r), " \n"); i > len(string(r))/2 {
+		r = []rune(string(r)[:i])
+	}
+	return strings.TrimRight(string(r), " \n") + "…"
+}
+
+// send posts text and returns the post's URL, if the server said.
+func (b *Bridge) send(m *message.Message, text string) (string, error) {
+	if b.cfg.Kind == KindActivityPub {
+		return b.sendActivity(m, text)
+	}
+	body, _ := json.Marshal(map[string]string{"status": text, "visibility": b.cfg.Visibility})
+	req, err := http.NewRequest(http.MethodPost, b.cfg.Server+"/api/v1/statuses", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Mastodon drops a repeated request with the same key, so a post whose
+	// answer was lost is not doubled when it is tried again.
+	req.Header.Set("Idempotency-Key", fmt.Sprintf("twilight-bbs-%d-%d", m.AreaID, m.ID))
+	resp, err := b.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var status struct {
+		URL string `json:"url"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&status)
+	return status.URL, nil
+}
+
+// publicAudience addresses an activity to everyone.
+const publicAudience = "https://www.w3.org/ns/activitystreams#Public"
+
+// sendActivity posts a Create activity holding a Note to the outbox, as
+// an ActivityPub client does.
+func (b *Bridge) sendActivity(m *message.Message, text string) (string, error) {
+	content := strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+	activity := map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"type":     "Create",
+		"actor":    b.cfg.Actor,
+		"to":       []string{publicAudience},
+		"object": map[string]any{
+			"type":         "Note",
+			"attributedTo": b.cfg.Actor,
+			"content":      content,
+			"published":    m.CreatedAt.UTC().Format(time.RFC3339),
+			"to":           []string{publicAudience},
+		},
+	}
+	body, _ := json.Marshal(activity)
+	req, err := http.NewRequest(http.MethodPost, b.cfg.Outbox, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`)
+	resp, err := b.do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Location"), nil
+}
+
+// do sends req with the token and turns an error status into an error.
+func (b *Bridge) do(req *http.Request) (*http.Response, error) {
+	if b.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.cfg.Token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,142 @@
+package announce
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+func setup(t *testing.T) (*db.DB, *message.Repo) {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	for _, q := range []string{
+		`INSERT INTO users (id, username, password_hash) VALUES (1, 'sysop', 'x')`,
+		`INSERT INTO message_areas (id, name, web_public) VALUES (50, 'News', 1)`,
+	} {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return database, message.NewRepo(database.DB)
+}
+
+func TestMastodon(t *testing.T) {
+	database, msgs := setup(t)
+	msgs.Post(50, 1, nil, "Old news", "Before the bridge", nil)
+
+	var mu sync.Mutex
+	var statuses []map[string]string
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/statuses" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "nope", http.StatusUnauthorized)
+			return
+		}
+		var s map[string]string
+		json.NewDecoder(r.Body).Decode(&s)
+		mu.Lock()
+		statuses = append(statuses, s)
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"id": "1", "url": "https://example.social/@bbs/1"})
+	}))
+	defer srv.Close()
+
+	b, err := New(database.DB, msgs, Config{
+		Area: 50, Kind: KindMastodon, Server: srv.URL + "/", Token: "secret",
+		Visibility: "unlisted", MaxPerHour: 2, MaxLength: 60, BaseURL: "https://bbs.example.org",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := b.Run(); n != 0 || err != nil {
+		t.Fatalf("first run = %d, %v", n, err)
+	}
+
+	first, _ := msgs.Post(50, 1, nil, "Door night", "\x1b[1;33mLORD\x1b[0m tournament on Friday, with prizes for the top three players", nil)
+	msgs.Post(50, 1, nil, "Re: Door night", "Count me in", &first)
+	to := 1
+	msgs.Post(50, 1, &to, "Private", "Not for the world", nil)
+	msgs.Post(50, 1, nil, "Uptime", "Up 100 days", nil)
+	msgs.Post(50, 1, nil, "Third", "Over the hourly limit", nil)
+
+	n, err := b.Run()
+	if n != 2 || err != nil {
+		t.Fatalf("run = %d, %v", n, err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("posted %d statuses", len(statuses))
+	}
+	got := statuses[0]["status"]
+	if !strings.HasPrefix(got, "Door night\n\nLORD tourn") || !strings.Contains(got, "…\n\nhttps://bbs.example.org/forum/50/") ||
+		len([]rune(got)) > 60 || strings.Contains(got, "\x1b") {
+		t.Errorf("status = %q", got)
+	}
+	if statuses[0]["visibility"] != "unlisted" || statuses[1]["status"] != "Uptime\n\nUp 100 days\n\nhttps://bbs.example.org/forum/50/5" {
+		t.Errorf("statuses = %q", statuses)
+	}
+	if keys[0] == "" || keys[0] == keys[1] {
+		t.Errorf("idempotency keys = %q", keys)
+	}
+
+	// The hour's posts are used up; nothing more goes out.
+	if n, err := b.Run(); n != 0 || err != nil {
+		t.Fatalf("rate limited run = %d, %v", n, err)
+	}
+	var url string
+	database.QueryRow(`SELECT url FROM announce_posts WHERE message_id = ?`, first).Scan(&url)
+	if url != "https://example.social/@bbs/1" {
+		t.Errorf("logged url = %q", url)
+	}
+}
+
+func TestActivityPubFailureRetries(t *testing.T) {
+	database, msgs := setup(t)
+	var fail bool
+	var activity map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&activity)
+		w.Header().Set("Location", "https://ap.example.org/notes/1")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	b, err := New(database.DB, msgs, Config{
+		Area: 50, Kind: KindActivityPub, Outbox: srv.URL + "/outbox", Actor: "https://ap.example.org/users/bbs",
+		Template: "{{.BBS}}: {{.Subject}} <{{.From}}>", BBS: "Twilight",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Run()
+	msgs.Post(50, 1, nil, "Hello", "World", nil)
+
+	fail = true
+	if n, err := b.Run(); n != 0 || err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("failing run = %d, %v", n, err)
+	}
+	fail = false
+	if n, err := b.Run(); n != 1 || err != nil {
+		t.Fatalf("retry = %d, %v", n, err)
+	}
+	note, _ := activity["object"].(map[string]any)
+	if activity["type"] != "Create" || note["type"] != "Note" || note["content"] != "Twilight: Hello &lt;sysop&gt;" ||
+		note["attributedTo"] != "https://ap.example.org/users/bbs" {
+		t.Errorf("activity = %v", activity)
+	}
+}
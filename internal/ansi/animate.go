@@ -0,0 +1,53 @@
+package ansi
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// DefaultAnimateBaud is the line speed Animate plays at when none is given.
+const DefaultAnimateBaud = 9600
+
+// animateTick is how often Animate sends the next piece and checks for a
+// key.
+const animateTick = 20 * time.Millisecond
+
+// Animate plays df at bps the way an ANSImation drew in over a modem, and
+// stops as soon as the caller presses a key. It returns the key and whether
+// one was pressed. The pace is Animate's own, whatever the terminal's baud
+// emulation.
+func Animate(term *terminal.Terminal, df *DisplayFile, bps int) (byte, bool, error) {
+	if bps <= 0 {
+		bps = DefaultAnimateBaud
+	}
+	data := BlankPlaceholders(df.Data)
+	ansiOut := df.IsANSI && term.ANSIEnabled
+	if ansiOut {
+		data = append([]byte(artPreamble(term, df)), artData(term, df)...)
+	} else {
+		data = append(bytes.Join(splitLines(data), []byte("\r\n")), '\r', '\n')
+	}
+
+	chunk := max(1, bps/10*int(animateTick)/int(time.Second))
+	for i := 0; i < len(data); i += chunk {
+		end := min(i+chunk, len(data))
+		if err := term.SendNow(string(data[i:end])); err != nil {
+			return 0, false, fmt.Errorf("animate: %w", err)
+		}
+		key, ok, err := term.PollKey(animateTick)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			if ansiOut {
+				// The art may stop inside a colour change.
+				_ = term.SendNow(terminal.Reset)
+			}
+			return key, true, nil
+		}
+	}
+	return 0, false, nil
+}
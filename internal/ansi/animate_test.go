@@ -0,0 +1,75 @@
+package ansi
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+func TestAnimateStopsOnKey(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	received := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			if _, err := client.Read(buf); err != nil {
+				return
+			}
+			select {
+			case received <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	term := terminal.New(server, 80, 24, true)
+	df := &DisplayFile{IsANSI: true, Data: bytes.Repeat([]byte("*"), 10000)}
+
+	type result struct {
+		key     byte
+		pressed bool
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		key, pressed, err := Animate(term, df, 9600)
+		done <- result{key, pressed, err}
+	}()
+
+	<-received
+	// net.Pipe writes block until read, so press the key from a goroutine.
+	go client.Write([]byte{'x'})
+
+	select {
+	case r := <-done:
+		if r.err != nil || !r.pressed || r.key != 'x' {
+			t.Fatalf("Animate = %q, %v, %v", r.key, r.pressed, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Animate did not stop on a key")
+	}
+}
+
+func TestAnimatePlaysToEnd(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+
+	term := terminal.New(server, 80, 24, false)
+	df := &DisplayFile{Data: []byte("one\ntwo\n")}
+	start := time.Now()
+	if _, pressed, err := Animate(term, df, 115200); err != nil || pressed {
+		t.Fatalf("got %v, %v", pressed, err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("short art took too long")
+	}
+}
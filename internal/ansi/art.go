@@ -0,0 +1,195 @@
+package ansi
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// iCE colors make the blink attribute select a bright background instead.
+// SyncTERM, NetRunner and other BBS clients switch with DEC private mode
+// 33; terminals that do not know it ignore it.
+const (
+	iceOn  = "\x1b[?33h"
+	iceOff = "\x1b[?33l"
+)
+
+// sauceFonts maps SAUCE font names to SyncTERM font numbers. IBM fonts
+// without a code page, or with 437, are the default font 0.
+var sauceFonts = map[string]int{
+	"Amiga Topaz 1":         42,
+	"Amiga Topaz 1+":        40,
+	"Amiga Topaz 2":         42,
+	"Amiga Topaz 2+":        40,
+	"Amiga P0T-NOoDLE":      37,
+	"Amiga MicroKnight":     41,
+	"Amiga MicroKnight+":    39,
+	"Amiga mOsOul":          38,
+	"C64 PETSCII unshifted": 32,
+	"C64 PETSCII shifted":   33,
+	"Atari ATASCII":         36,
+}
+
+// FontNumber returns the SyncTERM font number for the SAUCE font name, and
+// false when the font has no SyncTERM equivalent.
+func (s *SAUCE) FontNumber() (int, bool) {
+	name := strings.TrimSpace(s.TInfoS)
+	if n, ok := sauceFonts[name]; ok {
+		return n, true
+	}
+	if name == "" || strings.HasPrefix(name, "IBM ") {
+		if f := strings.Fields(name); len(f) < 3 || f[len(f)-1] == "437" {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// artPreamble returns the sequences sent before ANSI art: iCE colors on or
+// off as the SAUCE flags say, and for SyncTERM-compatible clients the
+// art's font (the default font when it names none or one they lack).
+//
+// Neither is undone afterwards. These clients apply the mode and the font
+// to the whole screen, so switching back would change art already drawn;
+// the next piece of art sets its own.
+func artPreamble(term *terminal.Terminal, df *DisplayFile) string {
+	var b strings.Builder
+	if df.Sauce != nil && df.Sauce.HasICEColors() {
+		b.WriteString(iceOn)
+	} else {
+		b.WriteString(iceOff)
+	}
+	if term.Probe.CTerm() {
+		font := 0
+		if df.Sauce != nil {
+			if n, ok := df.Sauce.FontNumber(); ok {
+				font = n
+			}
+		}
+		fmt.Fprintf(&b, "\x1b[0;%d D", font)
+	}
+	return b.String()
+}
+
+// artData returns the bytes of ANSI art ready to send to term: placeholders
+// blanked and, when SAUCE says the art is wider than the terminal, every
+// line cropped to the terminal width.
+func artData(term *terminal.Terminal, df *DisplayFile) []byte {
+	data := BlankPlaceholders(df.Data)
+	if df.Sauce != nil && term.Width > 0 && df.Sauce.Width() > term.Width {
+		data = Crop(data, df.Sauce.Width(), term.Width)
+	}
+	return data
+}
+
+// Crop fits ANSI art drawn for a screen artWidth columns wide onto one
+// width columns wide. Text past the right edge is dropped, lines that
+// relied on the wide screen wrapping get an explicit CRLF, and cursor
+// movement is adjusted so the visible part lines up. Escape sequences
+// are kept.
+func Crop(data []byte, artWidth, width int) []byte {
+	if width <= 0 || artWidth <= width {
+		return data
+	}
+	var out bytes.Buffer
+	out.Grow(len(data))
+
+	col, saved := 0, 0 // art column; artWidth means a wrap is pending
+	visible := func(c int) int { return min(c, width-1) }
+	// move sends the horizontal motion that follows the art cursor
+	// from one column to another on the narrow screen.
+	move := func(from, to int) {
+		switch d := visible(to) - visible(from); {
+		case d > 0:
+			fmt.Fprintf(&out, "\x1b[%dC", d)
+		case d < 0:
+			fmt.Fprintf(&out, "\x1b[%dD", -d)
+		}
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == 0x1b && i+1 < len(data) && data[i+1] == '[':
+			end := i + 2
+			for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+				end++
+			}
+			if end == len(data) {
+				out.Write(data[i:])
+				return out.Bytes()
+			}
+			params := string(data[i+2 : end])
+			n := func(idx int) int {
+				f := strings.Split(params, ";")
+				if idx >= len(f) {
+					return 1
+				}
+				v, err := strconv.Atoi(f[idx])
+				if err != nil || v < 1 {
+					return 1
+				}
+				return v
+			}
+			switch data[end] {
+			case 'C':
+				to := min(col+n(0), artWidth-1)
+				move(col, to)
+				col = to
+			case 'D':
+				to := max(min(col, artWidth-1)-n(0), 0)
+				move(col, to)
+				col = to
+			case 'H', 'f':
+				col = min(n(1), artWidth) - 1
+				out.Write(data[i : end+1])
+			case 'G':
+				col = min(n(0), artWidth) - 1
+				out.Write(data[i : end+1])
+			case 's':
+				saved = col
+				out.Write(data[i : end+1])
+			case 'u':
+				col = saved
+				out.Write(data[i : end+1])
+			default:
+				out.Write(data[i : end+1])
+			}
+			i = end
+		case c == 0x1b && i+1 < len(data):
+			switch data[i+1] {
+			case '7':
+				saved = col
+			case '8':
+				col = saved
+			}
+			out.Write(data[i : i+2])
+			i++
+		case c == '\r':
+			col = 0
+			out.WriteByte(c)
+		case c == '\b':
+			col = max(min(col, artWidth-1)-1, 0)
+			out.WriteByte(c)
+		case c == '\t':
+			to := min((col/8+1)*8, artWidth-1)
+			move(col, to)
+			col = to
+		case c < 0x20 || c == 0x7f:
+			out.WriteByte(c)
+		default:
+			if col >= artWidth {
+				out.WriteString("\r\n")
+				col = 0
+			}
+			if col < width {
+				out.WriteByte(c)
+			}
+			col++
+		}
+	}
+	return out.Bytes()
+}
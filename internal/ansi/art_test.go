@@ -0,0 +1,69 @@
+package ansi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+func TestCropWideArt(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"long line", "abcdefgh\r\n", "abcd\r\n"},
+		{"implicit wrap", "abcdefghijkl", "abcd\r\nijkl"},
+		{"escapes kept", "\x1b[1;31mabcdef\x1b[0m\r\n", "\x1b[1;31mabcd\x1b[0m\r\n"},
+		{"moves past edge", "ab\x1b[4Cx\x1b[6Dy", "ab\x1b[1C\x1b[2Dy"},
+		{"wrap after full line", "abcdefgh\r\nz", "abcd\r\nz"},
+	}
+	for _, tt := range tests {
+		got := string(Crop([]byte(tt.in), 8, 4))
+		if got != tt.want {
+			t.Errorf("%s: Crop = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := string(Crop([]byte("abcdefgh"), 8, 80)); got != "abcdefgh" {
+		t.Errorf("narrow art changed: %q", got)
+	}
+}
+
+func TestFontNumber(t *testing.T) {
+	tests := []struct {
+		font string
+		want int
+		ok   bool
+	}{
+		{"", 0, true},
+		{"IBM VGA", 0, true},
+		{"IBM VGA50 437", 0, true},
+		{"IBM VGA 866", 0, false},
+		{"Amiga Topaz 1+", 40, true},
+		{"Some Font", 0, false},
+	}
+	for _, tt := range tests {
+		n, ok := (&SAUCE{TInfoS: tt.font}).FontNumber()
+		if n != tt.want || ok != tt.ok {
+			t.Errorf("FontNumber(%q) = %d, %v; want %d, %v", tt.font, n, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestArtPreamble(t *testing.T) {
+	term := terminal.New(nil, 80, 24, true)
+	ice := &DisplayFile{IsANSI: true, Sauce: &SAUCE{Flags: 1, TInfoS: "Amiga Topaz 1+"}}
+
+	if got := artPreamble(term, ice); got != iceOn {
+		t.Errorf("plain client preamble = %q", got)
+	}
+	if got := artPreamble(term, &DisplayFile{IsANSI: true}); got != iceOff {
+		t.Errorf("no SAUCE preamble = %q", got)
+	}
+
+	term.Probe.Identity = "\x1b[=67;84;101;114;109;1;324c"
+	got := artPreamble(term, ice)
+	if !strings.HasPrefix(got, iceOn) || !strings.HasSuffix(got, "\x1b[0;40 D") {
+		t.Errorf("SyncTERM preamble = %q", got)
+	}
+}
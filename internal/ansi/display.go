@@ -154,7 +154,10 @@ func Display(term *terminal.Terminal, df *DisplayFile) error {
 func displayANSI(term *terminal.Terminal, df *DisplayFile) error {
 	// Send the raw ANSI data - it already contains escape sequences
 	// We send in chunks to allow for network buffering
-	data := BlankPlaceholders(df.Data)
+	if err := term.Send(artPreamble(term, df)); err != nil {
+		return fmt.Errorf("display ANSI: %w", err)
+	}
+	data := artData(term, df)
 	chunkSize := 1024
 
 	for i := 0; i < len(data); i += chunkSize {
@@ -228,16 +231,16 @@ func DisplayWithPaging(term *terminal.Terminal, df *DisplayFile, pageHeight int)
 
 		if lineCount >= pageHeight-1 {
 			if term.ANSIEnabled {
-				term.Send(terminal.FgBrightCyan + " -- More -- " + terminal.Reset)
+				term.SendNow(term.Style(terminal.PromptAttr) + " -- More -- " + terminal.Reset)
 			} else {
-				term.Send(" -- More -- ")
+				term.SendNow(" -- More -- ")
 			}
 			key, err := term.GetKey()
 			if err != nil {
 				return err
 			}
 			// Clear the "More" prompt
-			term.Send("\r" + terminal.ClearLine())
+			term.SendNow("\r" + terminal.ClearLine())
 
 			if key == 'q' || key == 'Q' || key == 27 { // q or ESC
 				return nil
@@ -0,0 +1,278 @@
+package ansi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// DefaultFPS is the frame rate animations play at when neither the caller
+// nor the sidecar sets one.
+const DefaultFPS = 10
+
+// AnimExt is the extension of an animation's sidecar file.
+const AnimExt = ".anim"
+
+// Animation is a multi-frame piece of art. It comes from either a
+// directory of numbered frames (name/01.ans, name/02.ans, ...) or a single
+// file cut into frames where the art homes the cursor or clears the
+// screen. An optional sidecar, name.anim, sets the timing:
+//
+//	fps 8          # frames per second
+//	delay 3 1500   # frame 3 stays for 1.5 seconds
+//	loop 2         # play twice; 0 plays until a key
+//	split clear    # single file: cut at clear screen only, not cursor home
+type Animation struct {
+	Name   string
+	Frames []*DisplayFile
+	FPS    int            // from the sidecar, 0 = not set
+	Delays map[int]int    // frame index (0-based) to milliseconds
+	Loop   int            // times to play, 0 = until a key
+	dir    bool           // frames are separate files drawn from the top left
+	ansi   bool           // frames are ANSI
+	split  *regexp.Regexp // where a single file is cut
+}
+
+// Frame boundaries in single-file animations.
+var (
+	splitHome  = regexp.MustCompile(`\x1b\[(?:1?;1?)?H|\x1b\[2J`)
+	splitClear = regexp.MustCompile(`\x1b\[2J`)
+)
+
+// frameNumber finds the number in a frame's file name.
+var frameNumber = regexp.MustCompile(`\d+`)
+
+// FindAnimation locates an animation by name: a directory of frames,
+// else a single display file. Frames are .ans files, or .asc for callers
+// without ANSI, as Find prefers.
+func (l *Loader) FindAnimation(name string, ansiEnabled bool) (*Animation, error) {
+	safeName, err := sanitizeDisplayName(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range l.baseDirs {
+		path := filepath.Join(dir, safeName)
+		if !isWithinBaseDir(dir, path) {
+			continue
+		}
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			a, err := l.loadFrameDir(path, ansiEnabled)
+			if err != nil {
+				return nil, err
+			}
+			a.Name = safeName
+			return a, a.readSidecar(path + AnimExt)
+		}
+	}
+
+	df, err := l.Find(safeName, ansiEnabled)
+	if err != nil {
+		return nil, err
+	}
+	a := &Animation{Name: safeName, Loop: 1, ansi: df.IsANSI, split: splitHome}
+	sidecar := strings.TrimSuffix(df.Path, filepath.Ext(df.Path)) + AnimExt
+	if err := a.readSidecar(sidecar); err != nil {
+		return nil, err
+	}
+	a.Frames = splitFrames(df, a.split)
+	return a, nil
+}
+
+// loadFrameDir loads the numbered frames in dir, in number order.
+func (l *Loader) loadFrameDir(dir string, ansiEnabled bool) (*Animation, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read animation %s: %w", dir, err)
+	}
+	byExt := map[string][]string{}
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if !e.IsDir() && (ext == ".ans" || ext == ".asc") && frameNumber.MatchString(e.Name()) {
+			byExt[ext] = append(byExt[ext], e.Name())
+		}
+	}
+	ext := ".asc"
+	if ansiEnabled && len(byExt[".ans"]) > 0 || len(byExt[".asc"]) == 0 {
+		ext = ".ans"
+	}
+	names := byExt[ext]
+	if len(names) == 0 {
+		return nil, fmt.Errorf("animation %s has no numbered frames", filepath.Base(dir))
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		a, _ := strconv.Atoi(frameNumber.FindString(names[i]))
+		b, _ := strconv.Atoi(frameNumber.FindString(names[j]))
+		return a < b
+	})
+
+	a := &Animation{Loop: 1, dir: true, ansi: ext == ".ans"}
+	for _, name := range names {
+		df, err := l.Load(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		a.Frames = append(a.Frames, df)
+	}
+	return a, nil
+}
+
+// readSidecar applies the directives in path, if it exists.
+func (a *Animation) readSidecar(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read animation timing: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := a.directive(fields); err != nil {
+			return fmt.Errorf("%s:%d: %w", filepath.Base(path), n, err)
+		}
+	}
+	return sc.Err()
+}
+
+func (a *Animation) directive(fields []string) error {
+	nums := make([]int, len(fields)-1)
+	for i, f := range fields[1:] {
+		v, err := strconv.Atoi(f)
+		if err != nil && fields[0] != "split" {
+			return fmt.Errorf("%s: %q is not a number", fields[0], f)
+		}
+		nums[i] = v
+	}
+	switch fields[0] {
+	case "fps":
+		if len(nums) != 1 || nums[0] <= 0 {
+			return fmt.Errorf("fps needs a positive number")
+		}
+		a.FPS = nums[0]
+	case "delay":
+		if len(nums) != 2 || nums[0] < 1 || nums[1] < 0 {
+			return fmt.Errorf("delay needs a frame number and milliseconds")
+		}
+		if a.Delays == nil {
+			a.Delays = make(map[int]int)
+		}
+		a.Delays[nums[0]-1] = nums[1]
+	case "loop":
+		if len(nums) != 1 || nums[0] < 0 {
+			return fmt.Errorf("loop needs a count, 0 for until a key")
+		}
+		a.Loop = nums[0]
+	case "split":
+		if len(fields) != 2 {
+			return fmt.Errorf("split needs home or clear")
+		}
+		switch fields[1] {
+		case "home":
+			a.split = splitHome
+		case "clear":
+			a.split = splitClear
+		default:
+			return fmt.Errorf("split needs home or clear, not %q", fields[1])
+		}
+	default:
+		return fmt.Errorf("unknown directive %q", fields[0])
+	}
+	return nil
+}
+
+// splitFrames cuts df into frames, each starting at a match of at.
+func splitFrames(df *DisplayFile, at *regexp.Regexp) []*DisplayFile {
+	if !df.IsANSI {
+		return []*DisplayFile{df}
+	}
+	var frames []*DisplayFile
+	start := 0
+	cut := func(end int) {
+		if data := df.Data[start:end]; len(bytes.TrimSpace(data)) > 0 {
+			frames = append(frames, &DisplayFile{Name: df.Name, Path: df.Path, IsANSI: true, Data: data, Sauce: df.Sauce})
+		}
+		start = end
+	}
+	for _, m := range at.FindAllIndex(df.Data, -1) {
+		// A clear right after a home, or the other way round, starts one frame.
+		if m[0] > start && len(bytes.TrimSpace(at.ReplaceAll(df.Data[start:m[0]], nil))) > 0 {
+			cut(m[0])
+		}
+	}
+	cut(len(df.Data))
+	if len(frames) == 0 {
+		return []*DisplayFile{df}
+	}
+	return frames
+}
+
+// delay is how long frame i stays on screen at fps.
+func (a *Animation) delay(i, fps int) time.Duration {
+	if ms, ok := a.Delays[i]; ok {
+		return time.Duration(ms) * time.Millisecond
+	}
+	if fps <= 0 {
+		fps = a.FPS
+	}
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	return time.Second / time.Duration(fps)
+}
+
+// PlayAnimation plays a on term at fps frames per second (0 = the sidecar's
+// rate, else DefaultFPS); frames with a delay in the sidecar keep it. It
+// stops as soon as the caller presses a key and returns the key and
+// whether one was pressed. Callers without ANSI see only the first frame,
+// since nothing can be redrawn in place for them.
+func PlayAnimation(term *terminal.Terminal, a *Animation, fps int) (byte, bool, error) {
+	if len(a.Frames) == 0 {
+		return 0, false, nil
+	}
+	if !a.ansi || !term.ANSIEnabled {
+		if err := Display(term, a.Frames[0]); err != nil {
+			return 0, false, err
+		}
+		return 0, false, nil
+	}
+
+	if err := term.SendNow(artPreamble(term, a.Frames[0])); err != nil {
+		return 0, false, fmt.Errorf("animate: %w", err)
+	}
+	for pass := 0; a.Loop == 0 || pass < a.Loop; pass++ {
+		for i, df := range a.Frames {
+			data := artData(term, df)
+			if a.dir {
+				data = append([]byte("\x1b[H"), data...)
+			}
+			if err := term.SendNow(string(data)); err != nil {
+				return 0, false, fmt.Errorf("animate: %w", err)
+			}
+			key, ok, err := term.PollKey(a.delay(i, fps))
+			if err != nil {
+				return 0, false, err
+			}
+			if ok {
+				_ = term.SendNow(terminal.Reset)
+				return key, true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
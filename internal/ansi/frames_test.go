@@ -0,0 +1,120 @@
+package ansi
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+func TestFindAnimationDir(t *testing.T) {
+	dir := t.TempDir()
+	frames := filepath.Join(dir, "spinner")
+	os.Mkdir(frames, 0o755)
+	for _, name := range []string{"frame10.ans", "frame2.ans", "frame1.ans", "notes.txt"} {
+		os.WriteFile(filepath.Join(frames, name), []byte(strings.TrimSuffix(name, ".ans")), 0o644)
+	}
+	os.WriteFile(filepath.Join(dir, "spinner.anim"), []byte("fps 4 # slow\ndelay 3 1500\nloop 0\n"), 0o644)
+
+	a, err := NewLoader(dir).FindAnimation("spinner", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range a.Frames {
+		got = append(got, string(f.Data))
+	}
+	if strings.Join(got, ",") != "frame1,frame2,frame10" {
+		t.Errorf("frames = %v", got)
+	}
+	if a.Loop != 0 || a.delay(0, 0) != 250*time.Millisecond || a.delay(2, 0) != 1500*time.Millisecond || a.delay(0, 20) != 50*time.Millisecond {
+		t.Errorf("timing: loop %d, delays %v %v %v", a.Loop, a.delay(0, 0), a.delay(2, 0), a.delay(0, 20))
+	}
+}
+
+func TestFindAnimationSplit(t *testing.T) {
+	dir := t.TempDir()
+	art := "\x1b[2J\x1b[HOne\x1b[1;1HTwo\x1b[HThree"
+	os.WriteFile(filepath.Join(dir, "intro.ans"), []byte(art), 0o644)
+	loader := NewLoader(dir)
+
+	a, err := loader.FindAnimation("intro", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Frames) != 3 || string(a.Frames[0].Data) != "\x1b[2J\x1b[HOne" || string(a.Frames[2].Data) != "\x1b[HThree" {
+		t.Errorf("got %d frames", len(a.Frames))
+	}
+	if a.delay(1, 0) != time.Second/DefaultFPS {
+		t.Errorf("default delay = %v", a.delay(1, 0))
+	}
+
+	os.WriteFile(filepath.Join(dir, "intro.anim"), []byte("split clear\n"), 0o644)
+	if a, err = loader.FindAnimation("intro", true); err != nil || len(a.Frames) != 1 {
+		t.Errorf("split clear: %d frames, %v", len(a.Frames), err)
+	}
+	os.WriteFile(filepath.Join(dir, "intro.anim"), []byte("fps 8\nspeed 2\n"), 0o644)
+	if _, err := loader.FindAnimation("intro", true); err == nil || !strings.Contains(err.Error(), "intro.anim:2") {
+		t.Errorf("bad directive: %v", err)
+	}
+}
+
+func TestPlayAnimation(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	out := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := client.Read(buf)
+			if err != nil {
+				return
+			}
+			out <- string(buf[:n])
+		}
+	}()
+	term := terminal.New(server, 80, 24, true)
+	a := &Animation{Loop: 1, ansi: true, Frames: []*DisplayFile{
+		{IsANSI: true, Data: []byte("A")}, {IsANSI: true, Data: []byte("B")},
+	}}
+
+	if _, pressed, err := PlayAnimation(term, a, 50); err != nil || pressed {
+		t.Fatalf("play to end: %v, %v", pressed, err)
+	}
+	var sent strings.Builder
+	for !strings.HasSuffix(sent.String(), "B") {
+		select {
+		case s := <-out:
+			sent.WriteString(s)
+		case <-time.After(time.Second):
+			t.Fatalf("sent %q", sent.String())
+		}
+	}
+	if s := sent.String(); !strings.HasSuffix(s, "AB") {
+		t.Errorf("sent %q", s)
+	}
+
+	a.Loop = 0
+	done := make(chan byte, 1)
+	go func() {
+		key, _, _ := PlayAnimation(term, a, 50)
+		done <- key
+	}()
+	<-out
+	go client.Write([]byte{'q'})
+	select {
+	case key := <-done:
+		if key != 'q' {
+			t.Errorf("stopped by %q", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("endless animation did not stop on a key")
+	}
+	go io.Copy(io.Discard, client)
+}
@@ -0,0 +1,106 @@
+package ansi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+)
+
+// Font is an 8-pixel-wide bitmap font with 256 glyphs in CP437 order, such
+// as a VGA 8x16 ROM font. No font ships with the BBS; sysops supply one.
+type Font struct {
+	Height int    // glyph height in pixels
+	glyphs []byte // Height bytes per glyph, most significant bit leftmost
+}
+
+// LoadFont reads a font file (see ParseFont).
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load font: %w", err)
+	}
+	f, err := ParseFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("load font %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// ParseFont accepts PSF1 and PSF2 console fonts and raw 8-pixel-wide dumps
+// (256 glyphs, height = size/256, e.g. a 4096-byte .f16 file). Only the
+// first 256 glyphs are used.
+func ParseFont(data []byte) (*Font, error) {
+	switch {
+	case len(data) >= 4 && data[0] == 0x36 && data[1] == 0x04:
+		height := int(data[3])
+		return fontFrom(data[4:], height)
+	case len(data) >= 32 && bytes.Equal(data[:4], []byte{0x72, 0xb5, 0x4a, 0x86}):
+		headerSize := binary.LittleEndian.Uint32(data[8:])
+		glyphSize := binary.LittleEndian.Uint32(data[20:])
+		height := binary.LittleEndian.Uint32(data[24:])
+		width := binary.LittleEndian.Uint32(data[28:])
+		if width != 8 || glyphSize != height || int(headerSize) > len(data) {
+			return nil, errors.New("only 8-pixel-wide PSF2 fonts are supported")
+		}
+		return fontFrom(data[headerSize:], int(height))
+	case len(data) > 0 && len(data)%256 == 0:
+		return fontFrom(data, len(data)/256)
+	}
+	return nil, errors.New("unrecognised font format")
+}
+
+func fontFrom(glyphs []byte, height int) (*Font, error) {
+	if height <= 0 || height > 32 || len(glyphs) < 256*height {
+		return nil, errors.New("font is truncated or has a bad glyph height")
+	}
+	return &Font{Height: height, glyphs: glyphs[:256*height]}, nil
+}
+
+// RenderImage plays text (see Emulate) and draws it with font, one 8-pixel
+// cell per character. Characters outside CP437 are drawn as '?'. width 0
+// sizes the image to the longest line.
+func RenderImage(text string, width int, font *Font) (*image.Paletted, error) {
+	if font == nil {
+		return nil, errors.New("render image: no font")
+	}
+	lines := Emulate(text, width).Lines()
+	cols := width
+	if cols <= 0 {
+		for _, line := range lines {
+			cols = max(cols, len(line))
+		}
+	}
+	rows := max(len(lines), 1)
+	cols = max(cols, 1)
+
+	pal := make(color.Palette, len(Palette))
+	for i, c := range Palette {
+		pal[i] = color.RGBA{c[0], c[1], c[2], 0xff}
+	}
+	img := image.NewPaletted(image.Rect(0, 0, cols*8, rows*font.Height), pal)
+
+	for y, line := range lines {
+		for x, c := range line {
+			if x >= cols {
+				break
+			}
+			glyph := font.glyphs[int(EncodeCP437(c.Rune))*font.Height:]
+			for gy := 0; gy < font.Height; gy++ {
+				bits := glyph[gy]
+				off := img.PixOffset(x*8, y*font.Height+gy)
+				for gx := 0; gx < 8; gx++ {
+					if bits&(0x80>>gx) != 0 {
+						img.Pix[off+gx] = c.FG & 15
+					} else {
+						img.Pix[off+gx] = c.BG & 15
+					}
+				}
+			}
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,280 @@
+package ansi
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity ranks lint findings.
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// LintIssue is one problem found in a display file.
+type LintIssue struct {
+	Path     string
+	Line     int // 1-based, 0 when the issue concerns the whole file
+	Severity Severity
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s: %s", i.Path, i.Line, i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Path, i.Severity, i.Message)
+}
+
+// supportedCSI lists the CSI final bytes terminals following the ANSI-BBS
+// standard (bansi.txt) are expected to handle.
+const supportedCSI = "ABCDHfJKmsuhln"
+
+// LintDir checks every .ans/.asc file below dir and reports ANSI files
+// without an .asc fallback for non-ANSI callers.
+func LintDir(dir string) ([]LintIssue, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if !d.IsDir() && (ext == ".ans" || ext == ".asc") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", dir, err)
+	}
+	sort.Strings(paths)
+
+	hasASCII := make(map[string]bool)
+	for _, path := range paths {
+		if strings.EqualFold(filepath.Ext(path), ".asc") {
+			hasASCII[strings.ToLower(strings.TrimSuffix(path, filepath.Ext(path)))] = true
+		}
+	}
+
+	var issues []LintIssue
+	for _, path := range paths {
+		fileIssues, err := LintFile(path)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, fileIssues...)
+
+		base := strings.ToLower(strings.TrimSuffix(path, filepath.Ext(path)))
+		if strings.EqualFold(filepath.Ext(path), ".ans") && !hasASCII[base] {
+			issues = append(issues, LintIssue{Path: path, Severity: SeverityWarning,
+				Message: "no .asc fallback for callers without ANSI"})
+		}
+	}
+	return issues, nil
+}
+
+// LintFile checks a single display file for SAUCE inconsistencies, lines
+// wider than the art's width, unsupported or malformed escape sequences and
+// broken {{PLACEHOLDER}} syntax.
+func LintFile(path string) ([]LintIssue, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	isANSI := strings.EqualFold(filepath.Ext(path), ".ans")
+	sauce, data := ParseSAUCE(raw)
+
+	l := &linter{path: path, data: data}
+	l.checkSAUCE(sauce, isANSI)
+
+	width := 80
+	if sauce != nil {
+		width = sauce.Width()
+	}
+	l.checkContent(width, isANSI)
+	return l.issues, nil
+}
+
+type linter struct {
+	path   string
+	data   []byte
+	issues []LintIssue
+}
+
+func (l *linter) add(offset int, sev Severity, format string, args ...interface{}) {
+	line := 0
+	if offset >= 0 {
+		line = 1 + strings.Count(string(l.data[:offset]), "\n")
+	}
+	l.issues = append(l.issues, LintIssue{Path: l.path, Line: line, Severity: sev, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) checkSAUCE(s *SAUCE, isANSI bool) {
+	if s == nil {
+		return
+	}
+	if s.FileSize != 0 && int(s.FileSize) != len(l.data) {
+		l.add(-1, SeverityWarning, "SAUCE file size %d does not match content size %d", s.FileSize, len(l.data))
+	}
+	if s.DataType != 1 {
+		l.add(-1, SeverityWarning, "SAUCE data type %d is not Character (1)", s.DataType)
+	} else if isANSI && s.FileType != 1 {
+		l.add(-1, SeverityWarning, "SAUCE file type %d is not ANSi (1) for an .ans file", s.FileType)
+	} else if !isANSI && s.FileType != 0 {
+		l.add(-1, SeverityWarning, "SAUCE file type %d is not ASCII (0) for an .asc file", s.FileType)
+	}
+	if s.Comments > 0 && s.CommentLines == nil {
+		l.add(-1, SeverityWarning, "SAUCE declares %d comment lines but no COMNT block was found", s.Comments)
+	}
+}
+
+// checkContent walks the data once, validating escapes and placeholders and
+// tracking the cursor column. Art without any line breaks relies on the
+// terminal's autowrap and is not checked for width.
+func (l *linter) checkContent(width int, isANSI bool) {
+	data := l.data
+	col, lineMax := 1, 0
+	lineStart := 0
+	seen := make(map[string]bool)
+
+	endLine := func() {
+		if lineMax > width {
+			l.add(lineStart, SeverityWarning, "line is %d columns wide, art width is %d", lineMax, width)
+		}
+		col, lineMax = 1, 0
+	}
+	advance := func(n int) {
+		col += n
+		if col-1 > lineMax {
+			lineMax = col - 1
+		}
+	}
+
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b == 0x1b:
+			if !isANSI {
+				l.add(i, SeverityError, "escape sequence in a plain ASCII file")
+			}
+			i += l.checkEscape(i, &col)
+			continue
+		case b == '\n':
+			endLine()
+			lineStart = i + 1
+		case b == '\r':
+			col = 1
+		case b == '{' && i+1 < len(data) && data[i+1] == '{':
+			end := findPlaceholderEnd(data, i+2)
+			if end == -1 {
+				l.add(i, SeverityError, "unterminated placeholder (missing }})")
+				i += 2
+				continue
+			}
+			l.checkPlaceholder(i, string(data[i+2:end]), seen)
+			advance(end + 2 - i)
+			i = end + 2
+			continue
+		case b >= 0x20 && b != 0x7f:
+			advance(1)
+		}
+		i++
+	}
+	if lineStart > 0 {
+		endLine()
+	}
+}
+
+// checkEscape validates the escape sequence at offset i, applies cursor
+// column movement to col, and returns the sequence length.
+func (l *linter) checkEscape(i int, col *int) int {
+	data := l.data
+	if i+1 >= len(data) {
+		l.add(i, SeverityError, "truncated escape sequence at end of file")
+		return 1
+	}
+	if data[i+1] != '[' {
+		l.add(i, SeverityWarning, "unsupported escape sequence ESC %q", data[i+1])
+		return 2
+	}
+	j := i + 2
+	for j < len(data) && data[j] >= 0x20 && data[j] <= 0x3f {
+		j++
+	}
+	if j >= len(data) || data[j] < 0x40 || data[j] > 0x7e {
+		l.add(i, SeverityError, "malformed CSI sequence %q", printable(data[i:min(j+1, len(data))]))
+		return j - i
+	}
+	params, final := string(data[i+2:j]), data[j]
+	if !strings.ContainsRune(supportedCSI, rune(final)) {
+		l.add(i, SeverityWarning, "unsupported CSI sequence %q", printable(data[i:j+1]))
+	}
+
+	count := 1
+	if n, err := strconv.Atoi(params); err == nil && n > 0 {
+		count = n
+	}
+	switch final {
+	case 'H', 'f':
+		*col = 1
+		if parts := strings.Split(params, ";"); len(parts) >= 2 {
+			if c, err := strconv.Atoi(parts[1]); err == nil && c > 0 {
+				*col = c
+			}
+		}
+	case 'C':
+		*col += count
+	case 'D':
+		*col = max(1, *col-count)
+	}
+	return j + 1 - i
+}
+
+func (l *linter) checkPlaceholder(offset int, payload string, seen map[string]bool) {
+	parts := strings.Split(payload, ",")
+	id := strings.TrimSpace(parts[0])
+	if id == "" {
+		l.add(offset, SeverityError, "placeholder {{%s}} has no ID", payload)
+		return
+	}
+	for _, r := range id {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z') {
+			l.add(offset, SeverityError, "placeholder ID %q contains invalid characters", id)
+			return
+		}
+	}
+	if len(parts) > 3 {
+		l.add(offset, SeverityError, "placeholder {{%s}} has too many parameters (want ID[,width[,height]])", payload)
+	}
+	for n, p := range parts[1:] {
+		if v, err := strconv.Atoi(strings.TrimSpace(p)); err != nil || v <= 0 {
+			name := "width"
+			if n == 1 {
+				name = "height"
+			}
+			l.add(offset, SeverityError, "placeholder {{%s}} has invalid %s %q", payload, name, strings.TrimSpace(p))
+		}
+	}
+	if seen[id] {
+		l.add(offset, SeverityWarning, "placeholder ID %q appears more than once; only the first is used", id)
+	}
+	seen[id] = true
+}
+
+func printable(b []byte) string {
+	return strings.ReplaceAll(string(b), "\x1b", "ESC")
+}
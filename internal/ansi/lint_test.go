@@ -0,0 +1,53 @@
+package ansi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("good.ans", "\x1b[1;31mHello {{USER,20}}\r\n\x1b[0mBye\r\n")
+	write("good.asc", "Hello {{USER,20}}\r\nBye\r\n")
+	write("wide.asc", strings.Repeat("x", 81)+"\r\n")
+	write("broken.ans", "{{USER,abc}} {{}} {{PASS\r\n\x1b[8;25;80t\x1b(0")
+	write("plain.asc", "\x1b[31mred\r\n")
+
+	issues, err := LintDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	report := strings.Join(got, "\n")
+
+	for _, want := range []string{
+		"broken.ans:1: error: placeholder {{USER,abc}} has invalid width",
+		"broken.ans:1: error: placeholder {{}} has no ID",
+		"broken.ans:1: error: unterminated placeholder",
+		`broken.ans:2: warning: unsupported CSI sequence "ESC[8;25;80t"`,
+		"broken.ans:2: warning: unsupported escape sequence ESC '('",
+		"broken.ans: warning: no .asc fallback",
+		"wide.asc:1: warning: line is 81 columns wide, art width is 80",
+		"plain.asc:1: error: escape sequence in a plain ASCII file",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("missing %q in report:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "good.") {
+		t.Errorf("clean files should have no issues:\n%s", report)
+	}
+}
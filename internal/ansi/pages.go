@@ -0,0 +1,117 @@
+package ansi
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+var sgrPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Rows returns the number of screen rows a display file occupies, taken
+// from the SAUCE height when present and counted from the data otherwise.
+func (df *DisplayFile) Rows() int {
+	if df.Sauce != nil && df.Sauce.Height() > 0 {
+		return df.Sauce.Height()
+	}
+	return len(splitLines(df.Data))
+}
+
+// SplitPages splits display data into pages of at most pageHeight lines.
+// For ANSI data, each page after the first is prefixed with the last SGR
+// (color) sequence of the previous page so colors carry across the break.
+// Art that relies on cursor positioning across page boundaries will not
+// split cleanly.
+func SplitPages(df *DisplayFile, pageHeight int) [][]byte {
+	if pageHeight <= 0 {
+		pageHeight = 23
+	}
+	lines := splitLines(BlankPlaceholders(df.Data))
+
+	var pages [][]byte
+	var carry []byte
+	for start := 0; start < len(lines); start += pageHeight {
+		end := start + pageHeight
+		if end > len(lines) {
+			end = len(lines)
+		}
+		body := bytes.Join(lines[start:end], []byte("\r\n"))
+
+		page := make([]byte, 0, len(carry)+len(body))
+		page = append(page, carry...)
+		page = append(page, body...)
+		pages = append(pages, page)
+
+		if df.IsANSI {
+			if seqs := sgrPattern.FindAll(body, -1); len(seqs) > 0 {
+				carry = seqs[len(seqs)-1]
+			}
+		}
+	}
+	return pages
+}
+
+// DisplayPaged shows a display file one screen at a time with next/prev
+// navigation when it is taller than the terminal. Shorter files are shown
+// with Display.
+func DisplayPaged(term *terminal.Terminal, df *DisplayFile) error {
+	pageHeight := term.Height - 1
+	if pageHeight <= 0 {
+		pageHeight = 23
+	}
+	if df.Rows() <= pageHeight {
+		return Display(term, df)
+	}
+
+	if df.IsANSI && term.ANSIEnabled {
+		if err := term.Send(artPreamble(term, df)); err != nil {
+			return err
+		}
+		fitted := *df
+		fitted.Data = artData(term, df)
+		df = &fitted
+	}
+	pages := SplitPages(df, pageHeight)
+	page := 0
+	for {
+		term.Cls()
+		if err := term.SendBytes(pages[page]); err != nil {
+			return err
+		}
+		if term.ANSIEnabled {
+			term.Send(terminal.Reset)
+		}
+
+		prompt := "\r\n[N]ext [P]rev [Q]uit"
+		if term.ANSIEnabled {
+			prompt = "\r\n" + term.Style(terminal.PromptAttr) + "[N]ext [P]rev [Q]uit" + terminal.Reset
+		}
+		term.Send(prompt + " " + pageLabel(page+1, len(pages)))
+
+		key, err := term.GetKey()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case 'n', 'N', ' ', '\r':
+			if page == len(pages)-1 {
+				term.Send("\r\n")
+				return nil
+			}
+			page++
+		case 'p', 'P':
+			if page > 0 {
+				page--
+			}
+		case 'q', 'Q', 27:
+			term.Send("\r\n")
+			return nil
+		}
+	}
+}
+
+func pageLabel(page, total int) string {
+	return fmt.Sprintf("(%d/%d)", page, total)
+}
@@ -0,0 +1,121 @@
+package ansi
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FindRandom picks a random display file whose name matches pattern, a
+// glob without extension relative to the loader's directories (for example
+// "login/*"). Files named with a date window suffix, such as
+// "login/xmas@1201-1226.ans", are only eligible between those month/day
+// dates (inclusive, wrapping over New Year). When any dated file is in
+// season, undated files are skipped so seasonal screens take over.
+func (l *Loader) FindRandom(pattern string, ansiEnabled bool) (*DisplayFile, error) {
+	return l.findRandomAt(pattern, ansiEnabled, time.Now())
+}
+
+func (l *Loader) findRandomAt(pattern string, ansiEnabled bool, now time.Time) (*DisplayFile, error) {
+	safePattern, err := sanitizeDisplayName(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	names := l.matchNames(safePattern)
+	var seasonal, regular []string
+	for _, name := range names {
+		start, end, dated := parseDateWindow(name)
+		switch {
+		case !dated:
+			regular = append(regular, name)
+		case inDateWindow(now, start, end):
+			seasonal = append(seasonal, name)
+		}
+	}
+
+	candidates := regular
+	if len(seasonal) > 0 {
+		candidates = seasonal
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no display files match: %s", safePattern)
+	}
+
+	return l.Find(candidates[rand.Intn(len(candidates))], ansiEnabled)
+}
+
+// matchNames returns the sorted, de-duplicated display names (relative,
+// without extension) of .ans/.asc files matching pattern.
+func (l *Loader) matchNames(pattern string) []string {
+	seen := make(map[string]bool)
+	for _, dir := range l.baseDirs {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			continue
+		}
+		for _, path := range matches {
+			ext := strings.ToLower(filepath.Ext(path))
+			if ext != ".ans" && ext != ".asc" {
+				continue
+			}
+			if !isWithinBaseDir(dir, path) {
+				continue
+			}
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				continue
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				continue
+			}
+			seen[strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseDateWindow extracts an "@MMDD-MMDD" suffix from a display name.
+// The returned values are month*100+day.
+func parseDateWindow(name string) (start, end int, ok bool) {
+	base := filepath.Base(name)
+	at := strings.LastIndexByte(base, '@')
+	if at < 0 {
+		return 0, 0, false
+	}
+	parts := strings.Split(base[at+1:], "-")
+	if len(parts) != 2 || len(parts[0]) != 4 || len(parts[1]) != 4 {
+		return 0, 0, false
+	}
+	s, err1 := strconv.Atoi(parts[0])
+	e, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || !validMonthDay(s) || !validMonthDay(e) {
+		return 0, 0, false
+	}
+	return s, e, true
+}
+
+func validMonthDay(md int) bool {
+	month, day := md/100, md%100
+	return month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
+
+func inDateWindow(now time.Time, start, end int) bool {
+	today := int(now.Month())*100 + now.Day()
+	if start <= end {
+		return today >= start && today <= end
+	}
+	// Window wraps over New Year (e.g. 1215-0105).
+	return today >= start || today <= end
+}
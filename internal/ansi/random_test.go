@@ -0,0 +1,61 @@
+package ansi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindRandom_SeasonalOverridesRegular(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "login"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.asc", "b.ans", "xmas@1201-1226.asc", "newyear@1230-0102.asc", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, "login", name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := NewLoader(dir)
+
+	summer := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		df, err := l.findRandomAt("login/*", true, summer)
+		if err != nil {
+			t.Fatalf("findRandomAt: %v", err)
+		}
+		if df.Name != "login/a" && df.Name != "login/b" {
+			t.Fatalf("out of season pick %q", df.Name)
+		}
+	}
+
+	df, err := l.findRandomAt("login/*", true, time.Date(2024, time.December, 24, 0, 0, 0, 0, time.UTC))
+	if err != nil || df.Name != "login/xmas@1201-1226" {
+		t.Fatalf("expected xmas art, got %+v err=%v", df, err)
+	}
+
+	df, err = l.findRandomAt("login/*", true, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || df.Name != "login/newyear@1230-0102" {
+		t.Fatalf("expected wrapping new year art, got %+v err=%v", df, err)
+	}
+
+	if _, err := l.FindRandom("missing/*", true); err == nil {
+		t.Fatalf("expected error for pattern with no matches")
+	}
+}
+
+func TestSplitPages_CarriesColor(t *testing.T) {
+	df := &DisplayFile{
+		IsANSI: true,
+		Data:   []byte("\x1b[1;31mone\r\ntwo\r\n\x1b[32mthree\r\nfour\r\n"),
+	}
+
+	pages := SplitPages(df, 2)
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(pages))
+	}
+	if got, want := string(pages[1]), "\x1b[1;31m\x1b[32mthree\r\nfour"; got != want {
+		t.Fatalf("unexpected second page %q, want %q", got, want)
+	}
+}
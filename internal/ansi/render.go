@@ -0,0 +1,334 @@
+package ansi
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// cp437 maps each CP437 byte to the glyph a DOS screen shows for it.
+var cp437 = terminal.CP437
+
+// DecodeCP437 converts CP437 art to UTF-8. Control bytes the renderer
+// interprets (CR, LF, tab, backspace, ESC and ^Z) are kept as they are.
+func DecodeCP437(data []byte) string {
+	var b strings.Builder
+	b.Grow(len(data))
+	for _, c := range data {
+		switch c {
+		case '\r', '\n', '\t', '\b', 0x1b, 0x1a:
+			b.WriteByte(c)
+		default:
+			b.WriteRune(cp437[c])
+		}
+	}
+	return b.String()
+}
+
+// EncodeCP437 returns the CP437 byte for r, or '?' if it has none.
+func EncodeCP437(r rune) byte {
+	if r >= 0x20 && r < 0x7f {
+		return byte(r)
+	}
+	if b, ok := terminal.CP437Byte(r); ok {
+		return b
+	}
+	return '?'
+}
+
+// Palette is the 16-colour CGA palette used when rendering, normal colours
+// first.
+var Palette = [16][3]uint8{
+	{0x00, 0x00, 0x00}, {0xaa, 0x00, 0x00}, {0x00, 0xaa, 0x00}, {0xaa, 0x55, 0x00},
+	{0x00, 0x00, 0xaa}, {0xaa, 0x00, 0xaa}, {0x00, 0xaa, 0xaa}, {0xaa, 0xaa, 0xaa},
+	{0x55, 0x55, 0x55}, {0xff, 0x55, 0x55}, {0x55, 0xff, 0x55}, {0xff, 0xff, 0x55},
+	{0x55, 0x55, 0xff}, {0xff, 0x55, 0xff}, {0x55, 0xff, 0xff}, {0xff, 0xff, 0xff},
+}
+
+// maxRows bounds how far cursor movement can grow a rendered screen.
+const maxRows = 2000
+
+// Cell is one character position on a rendered screen.
+type Cell struct {
+	Rune   rune
+	FG, BG uint8 // Palette indexes
+}
+
+var blank = Cell{Rune: ' ', FG: 7}
+
+// Screen is the result of playing ANSI text on a virtual terminal.
+type Screen struct {
+	Width int // columns; 0 means lines never wrap
+	Rows  [][]Cell
+
+	row, col           int
+	savedRow, savedCol int
+	fg, bg             uint8
+	bold, reverse      bool
+}
+
+// Emulate plays text on a virtual ANSI.SYS-style terminal width columns
+// wide and returns the resulting screen. text is UTF-8; use DecodeCP437 for
+// art files. LF also returns the cursor to column 1, and ^Z ends the input.
+func Emulate(text string, width int) *Screen {
+	s := &Screen{Width: width, fg: 7}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		switch r {
+		case 0x1a:
+			return s
+		case 0x1b:
+			i = s.escape(text, i)
+		case '\r':
+			s.col = 0
+		case '\n':
+			s.col = 0
+			s.down(1)
+		case '\t':
+			s.col = (s.col/8 + 1) * 8
+		case '\b':
+			s.col = max(s.col-1, 0)
+		case 0x07:
+		default:
+			s.put(r)
+		}
+	}
+	return s
+}
+
+func (s *Screen) down(n int) {
+	s.row = min(s.row+n, maxRows-1)
+}
+
+// cell returns the cell at row, col, growing the screen as needed.
+func (s *Screen) cell(row, col int) *Cell {
+	for len(s.Rows) <= row {
+		s.Rows = append(s.Rows, nil)
+	}
+	for len(s.Rows[row]) <= col {
+		s.Rows[row] = append(s.Rows[row], blank)
+	}
+	return &s.Rows[row][col]
+}
+
+func (s *Screen) put(r rune) {
+	if s.Width > 0 && s.col >= s.Width {
+		s.col = 0
+		s.down(1)
+	}
+	fg, bg := s.fg, s.bg
+	if s.bold {
+		fg += 8
+	}
+	if s.reverse {
+		fg, bg = bg, fg
+	}
+	*s.cell(s.row, s.col) = Cell{Rune: r, FG: fg, BG: bg}
+	s.col++
+}
+
+// escape handles the sequence after an ESC at text[i] and returns the
+// index just past it.
+func (s *Screen) escape(text string, i int) int {
+	if i >= len(text) {
+		return i
+	}
+	if text[i] != '[' {
+		return i + 1 // two-byte sequence, ignored
+	}
+	j := i + 1
+	for j < len(text) && (text[j] < 0x40 || text[j] > 0x7e) {
+		j++
+	}
+	if j >= len(text) {
+		return j
+	}
+	params := text[i+1 : j]
+	if strings.HasPrefix(params, "?") {
+		return j + 1 // private modes, e.g. cursor visibility
+	}
+	var nums []int
+	for _, p := range strings.Split(params, ";") {
+		n, _ := strconv.Atoi(p)
+		nums = append(nums, n)
+	}
+	arg := func(def int) int {
+		if nums[0] <= 0 {
+			return def
+		}
+		return nums[0]
+	}
+
+	switch text[j] {
+	case 'A':
+		s.row = max(s.row-arg(1), 0)
+	case 'B':
+		s.down(arg(1))
+	case 'C':
+		s.col += arg(1)
+		if s.Width > 0 {
+			s.col = min(s.col, s.Width-1)
+		}
+	case 'D':
+		s.col = max(s.col-arg(1), 0)
+	case 'H', 'f':
+		s.row, s.col = 0, 0
+		if nums[0] > 0 {
+			s.row = min(nums[0]-1, maxRows-1)
+		}
+		if len(nums) > 1 && nums[1] > 0 {
+			s.col = nums[1] - 1
+		}
+	case 'J':
+		if nums[0] == 2 {
+			s.Rows = nil
+			s.row, s.col = 0, 0
+		} else if nums[0] == 0 && s.row < len(s.Rows) {
+			s.clearLine()
+			s.Rows = s.Rows[:s.row+1]
+		}
+	case 'K':
+		s.clearLine()
+	case 's':
+		s.savedRow, s.savedCol = s.row, s.col
+	case 'u':
+		s.row, s.col = s.savedRow, s.savedCol
+	case 'm':
+		s.sgr(nums)
+	}
+	return j + 1
+}
+
+// clearLine erases from the cursor to the end of the line.
+func (s *Screen) clearLine() {
+	if s.row < len(s.Rows) && s.col < len(s.Rows[s.row]) {
+		s.Rows[s.row] = s.Rows[s.row][:s.col]
+	}
+}
+
+func (s *Screen) sgr(nums []int) {
+	for k := 0; k < len(nums); k++ {
+		switch n := nums[k]; {
+		case n == 0:
+			s.fg, s.bg, s.bold, s.reverse = 7, 0, false, false
+		case n == 1:
+			s.bold = true
+		case n == 22:
+			s.bold = false
+		case n == 7:
+			s.reverse = true
+		case n == 27:
+			s.reverse = false
+		case n >= 30 && n <= 37:
+			s.fg = uint8(n - 30)
+		case n == 39:
+			s.fg = 7
+		case n >= 40 && n <= 47:
+			s.bg = uint8(n - 40)
+		case n == 49:
+			s.bg = 0
+		case n == 38 || n == 48:
+			// 256-colour and RGB forms are skipped, not approximated.
+			if k+1 < len(nums) && nums[k+1] == 5 {
+				k += 2
+			} else if k+1 < len(nums) && nums[k+1] == 2 {
+				k += 4
+			}
+		}
+	}
+}
+
+// Lines returns the screen's rows with trailing blank cells and trailing
+// empty rows removed.
+func (s *Screen) Lines() [][]Cell {
+	lines := make([][]Cell, len(s.Rows))
+	last := -1
+	for i, row := range s.Rows {
+		n := len(row)
+		for n > 0 && row[n-1].Rune == ' ' && row[n-1].BG == 0 {
+			n--
+		}
+		lines[i] = row[:n]
+		if n > 0 {
+			last = i
+		}
+	}
+	return lines[:last+1]
+}
+
+// PlainText plays text (see Emulate) and returns what it shows without
+// colours, with trailing spaces removed from each line.
+func PlainText(text string, width int) string {
+	var b strings.Builder
+	for i, line := range Emulate(text, width).Lines() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		row := make([]rune, len(line))
+		for j, c := range line {
+			row[j] = c.Rune
+		}
+		b.WriteString(strings.TrimRight(string(row), " "))
+	}
+	return b.String()
+}
+
+// RenderHTML plays text (see Emulate) and returns it as HTML for a <pre>
+// element on a black background with light grey text: special characters
+// are escaped and colours become inline-styled spans.
+func RenderHTML(text string, width int) string {
+	var b strings.Builder
+	for i, line := range Emulate(text, width).Lines() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		for j := 0; j < len(line); {
+			k := j
+			for k < len(line) && line[k].FG == line[j].FG && line[k].BG == line[j].BG {
+				k++
+			}
+			styled := line[j].FG != 7 || line[j].BG != 0
+			if styled {
+				b.WriteString(`<span style="color:` + hexColor(line[j].FG))
+				if line[j].BG != 0 {
+					b.WriteString(";background:" + hexColor(line[j].BG))
+				}
+				b.WriteString(`">`)
+			}
+			for _, c := range line[j:k] {
+				switch c.Rune {
+				case '<':
+					b.WriteString("&lt;")
+				case '>':
+					b.WriteString("&gt;")
+				case '&':
+					b.WriteString("&amp;")
+				case '"':
+					b.WriteString("&#34;")
+				case '\'':
+					b.WriteString("&#39;")
+				default:
+					if c.Rune < 0x20 || c.Rune == 0x7f {
+						b.WriteRune(cp437[0]) // stray control character
+					} else {
+						b.WriteRune(c.Rune)
+					}
+				}
+			}
+			if styled {
+				b.WriteString("</span>")
+			}
+			j = k
+		}
+	}
+	return b.String()
+}
+
+func hexColor(i uint8) string {
+	c := Palette[i&15]
+	const digits = "0123456789abcdef"
+	return string([]byte{'#', digits[c[0]>>4], digits[c[0]&15], digits[c[1]>>4], digits[c[1]&15], digits[c[2]>>4], digits[c[2]&15]})
+}
@@ -0,0 +1,53 @@
+package ansi
+
+import "testing"
+
+func TestRenderHTML(t *testing.T) {
+	got := RenderHTML("\x1b[2J\x1b[1;31mHi\x1b[0m <&>\x1b[2;3HX\x1b[44m \x1b[0m", 80)
+	want := `<span style="color:#ff5555">Hi</span> &lt;&amp;&gt;` + "\n" +
+		`  X<span style="color:#aaaaaa;background:#0000aa"> </span>`
+	if got != want {
+		t.Fatalf("RenderHTML =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	got := PlainText("\x1b[1;31mNews\x1b[0m  \r\n\x1b[44m \x1b[0m\x1b[2;3Hok", 0)
+	if got != "News\n  ok" {
+		t.Fatalf("PlainText = %q", got)
+	}
+}
+
+func TestEmulateWrapAndCP437(t *testing.T) {
+	lines := Emulate(DecodeCP437([]byte{'a', 'b', 'c', 0xdb, 0x1a, 'z'}), 2).Lines()
+	if len(lines) != 2 || string([]rune{lines[1][0].Rune, lines[1][1].Rune}) != "c█" {
+		t.Fatalf("lines = %v", lines)
+	}
+	if EncodeCP437('█') != 0xdb || EncodeCP437('€') != '?' {
+		t.Fatal("EncodeCP437 mismatch")
+	}
+}
+
+func TestRenderImage(t *testing.T) {
+	// PSF1 font, 2 pixels high: glyph 'A' has its top row set.
+	data := append([]byte{0x36, 0x04, 0, 2}, make([]byte, 256*2)...)
+	data[4+'A'*2] = 0xff
+	font, err := ParseFont(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := RenderImage("\x1b[32;44mA", 0, font)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 8 || b.Dy() != 2 {
+		t.Fatalf("bounds = %v", b)
+	}
+	if img.ColorIndexAt(0, 0) != 2 || img.ColorIndexAt(7, 1) != 4 {
+		t.Fatalf("pixels = %d, %d", img.ColorIndexAt(0, 0), img.ColorIndexAt(7, 1))
+	}
+	if _, err := ParseFont([]byte("not a font")); err == nil {
+		t.Fatal("ParseFont accepted garbage")
+	}
+}
@@ -0,0 +1,211 @@
+// Package archive lists and extracts the members of the archive formats
+// found in BBS file areas: ZIP, LHA/LZH and ARJ. Callers can look inside
+// an archive and read a member online without downloading it.
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxExtractSize is the largest member Extract writes out.
+const MaxExtractSize = 8 << 20
+
+var (
+	// ErrFormat is returned for files that are not a supported archive.
+	ErrFormat = errors.New("not a ZIP, LHA or ARJ archive")
+	// ErrMethod is returned when a member uses a compression method that
+	// can be listed but not extracted.
+	ErrMethod = errors.New("unsupported compression method")
+	// ErrNotFound is returned when the archive has no such member.
+	ErrNotFound = errors.New("no such member")
+	// ErrTooLarge is returned for members over MaxExtractSize.
+	ErrTooLarge = errors.New("member too large")
+	// ErrChecksum is returned when an extracted member fails its CRC.
+	ErrChecksum = errors.New("checksum mismatch")
+)
+
+// Format names.
+const (
+	ZIP = "zip"
+	LHA = "lha"
+	ARJ = "arj"
+)
+
+// Member is one entry of an archive.
+type Member struct {
+	Name     string // path inside the archive, "/" separated
+	Size     int64  // uncompressed
+	Packed   int64  // compressed
+	Modified time.Time
+	Method   string // e.g. "deflate", "-lh5-", "arj1"
+	Dir      bool
+}
+
+// reader is one archive format.
+type reader interface {
+	members() []Member
+	// open returns the uncompressed contents of member i.
+	open(i int) (io.Reader, error)
+}
+
+// Detect returns the format of the archive at path.
+func Detect(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 32)
+	n, _ := io.ReadFull(f, head)
+	return detect(head[:n])
+}
+
+func detect(head []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ZIP, nil
+	case len(head) >= 7 && head[2] == '-' && head[3] == 'l' && head[6] == '-':
+		return LHA, nil
+	case bytes.HasPrefix(head, []byte{arjID0, arjID1}):
+		return ARJ, nil
+	}
+	return "", ErrFormat
+}
+
+func open(path string) (reader, *os.File, error) {
+	format, err := Detect(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	var r reader
+	switch format {
+	case ZIP:
+		r, err = newZipReader(f, info.Size())
+	case LHA:
+		r, err = newLHAReader(f, info.Size())
+	case ARJ:
+		r, err = newARJReader(f, info.Size())
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("read %s archive: %w", format, err)
+	}
+	return r, f, nil
+}
+
+// List returns the members of the archive at path.
+func List(path string) ([]Member, error) {
+	r, f, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return r.members(), nil
+}
+
+// Extract writes the member called name to a new directory under dir and
+// returns the file's path. The file keeps only the base of the member's
+// name. The caller removes the directory when done.
+func Extract(archivePath, name, dir string) (string, error) {
+	r, f, err := open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	idx := -1
+	for i, m := range r.members() {
+		if m.Name == name && !m.Dir {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return "", fmt.Errorf("extract %s: %w", name, ErrNotFound)
+	}
+	if r.members()[idx].Size > MaxExtractSize {
+		return "", fmt.Errorf("extract %s: %w", name, ErrTooLarge)
+	}
+	src, err := r.open(idx)
+	if err != nil {
+		return "", fmt.Errorf("extract %s: %w", name, err)
+	}
+
+	tmp, err := os.MkdirTemp(dir, "view-*")
+	if err != nil {
+		return "", fmt.Errorf("extract %s: %w", name, err)
+	}
+	out := filepath.Join(tmp, safeBase(name))
+	w, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("extract %s: %w", name, err)
+	}
+	n, err := io.Copy(w, io.LimitReader(src, MaxExtractSize+1))
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > MaxExtractSize {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("extract %s: %w", name, err)
+	}
+	return out, nil
+}
+
+// safeBase returns a file name for a member that cannot leave the
+// extraction directory.
+func safeBase(name string) string {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if base == "." || base == ".." || base == "/" || base == "" {
+		return "member"
+	}
+	return base
+}
+
+// dosTime converts an MS-DOS date and time, as stored by all three
+// formats, to local time.
+func dosTime(dt uint32) time.Time {
+	d, t := dt>>16, dt&0xffff
+	return time.Date(int(d>>9)+1980, time.Month(d>>5&0xf), int(d&0x1f),
+		int(t>>11), int(t>>5&0x3f), int(t&0x1f)*2, 0, time.Local)
+}
+
+// checkedReader fails at EOF when the data read does not match a CRC.
+type checkedReader struct {
+	r    io.Reader
+	sum  func([]byte)
+	ok   func() bool
+	done bool
+}
+
+func (c *checkedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.sum(p[:n])
+	if err == io.EOF && !c.done {
+		c.done = true
+		if !c.ok() {
+			return n, ErrChecksum
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,269 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bitWriter packs bits most significant first, as the LZH decoder reads
+// them.
+type bitWriter struct {
+	buf  []byte
+	nbit int
+}
+
+func (w *bitWriter) put(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.nbit%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v>>i&1 != 0 {
+			w.buf[len(w.buf)-1] |= 0x80 >> (w.nbit % 8)
+		}
+		w.nbit++
+	}
+}
+
+// lzhABBA is one block coding "ABBA": literals A and B with one-bit codes.
+// pbit is the width of the position code count (4 for -lh5-, 5 for ARJ).
+func lzhABBA(pbit int) []byte {
+	var w bitWriter
+	w.put(4, 16) // block size
+	// Code length codes: symbols 2 and 3 get one-bit codes.
+	w.put(4, 5)
+	w.put(0, 3)
+	w.put(0, 3)
+	w.put(1, 3)
+	w.put(0, 2) // no extra zeros after the third
+	w.put(1, 3)
+	// Literal lengths: 65 zeros, then A and B of length 1.
+	w.put(67, 9)
+	w.put(0, 1)
+	w.put(45, 9) // run of 45+20 zeros
+	w.put(1, 1)
+	w.put(1, 1)
+	// Positions: a single code, 0.
+	w.put(0, pbit)
+	w.put(0, pbit)
+	// A B B A
+	w.put(0b0110, 4)
+	return w.buf
+}
+
+// lzhMatch codes "AAAA" as literal A and a three byte match at distance 1.
+func lzhMatch(pbit int) []byte {
+	var w bitWriter
+	w.put(2, 16)
+	w.put(4, 5)
+	w.put(0, 3)
+	w.put(0, 3)
+	w.put(1, 3)
+	w.put(0, 2)
+	w.put(1, 3)
+	w.put(257, 9)
+	w.put(0, 1)
+	w.put(45, 9) // 65 zeros
+	w.put(1, 1)  // A
+	w.put(0, 1)
+	w.put(170, 9) // 190 zeros
+	w.put(1, 1)   // symbol 256: match of 3
+	w.put(0, pbit)
+	w.put(0, pbit)
+	w.put(0b01, 2)
+	return w.buf
+}
+
+func lhaLevel0(name, method string, data []byte, size int, crc uint16) []byte {
+	var h []byte
+	h = append(h, 0, 0)
+	h = append(h, method...)
+	h = binary.LittleEndian.AppendUint32(h, uint32(len(data)))
+	h = binary.LittleEndian.AppendUint32(h, uint32(size))
+	h = binary.LittleEndian.AppendUint32(h, 0x20a26000) // 1996-05-02
+	h = append(h, 0x20, 0, byte(len(name)))
+	h = append(h, name...)
+	h = binary.LittleEndian.AppendUint16(h, crc)
+	h[0] = byte(len(h) - 2)
+	return append(h, data...)
+}
+
+func lhaLevel2(dir, name, method string, data []byte, size int, crc uint16) []byte {
+	var h []byte
+	h = append(h, 0, 0)
+	h = append(h, method...)
+	h = binary.LittleEndian.AppendUint32(h, uint32(len(data)))
+	h = binary.LittleEndian.AppendUint32(h, uint32(size))
+	h = binary.LittleEndian.AppendUint32(h, 1000000000)
+	h = append(h, 0x20, 2)
+	h = binary.LittleEndian.AppendUint16(h, crc)
+	h = append(h, 'U')
+	h = binary.LittleEndian.AppendUint16(h, uint16(len(name)+3))
+	h = append(h, 0x01)
+	h = append(h, name...)
+	h = binary.LittleEndian.AppendUint16(h, uint16(len(dir)+3))
+	h = append(h, 0x02)
+	h = append(h, strings.ReplaceAll(dir, "/", "\xff")...)
+	h = binary.LittleEndian.AppendUint16(h, 0)
+	binary.LittleEndian.PutUint16(h, uint16(len(h)))
+	return append(h, data...)
+}
+
+func arjHeader(kind, method byte, name string, data []byte, size int, crc uint32) []byte {
+	basic := make([]byte, 30)
+	basic[0] = 30
+	basic[1], basic[2] = 11, 1
+	basic[5], basic[6] = method, kind
+	binary.LittleEndian.PutUint32(basic[8:], 0x20a26000)
+	binary.LittleEndian.PutUint32(basic[12:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(basic[16:], uint32(size))
+	binary.LittleEndian.PutUint32(basic[20:], crc)
+	basic = append(basic, name...)
+	basic = append(basic, 0, 0) // name and comment terminators
+
+	h := []byte{arjID0, arjID1}
+	h = binary.LittleEndian.AppendUint16(h, uint16(len(basic)))
+	h = append(h, basic...)
+	h = binary.LittleEndian.AppendUint32(h, crc32.ChecksumIEEE(basic))
+	h = append(h, 0, 0) // no extended headers
+	return append(h, data...)
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func extract(t *testing.T, archivePath, name string) string {
+	t.Helper()
+	out, err := Extract(archivePath, name, t.TempDir())
+	if err != nil {
+		t.Fatalf("Extract %s: %v", name, err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("DOCS/README.TXT")
+	w.Write([]byte(strings.Repeat("Hello from the BBS!\r\n", 50)))
+	zw.Create("DOCS/")
+	zw.Close()
+	path := writeFile(t, "test.zip", buf.Bytes())
+
+	if f, err := Detect(path); err != nil || f != ZIP {
+		t.Fatalf("Detect = %q, %v", f, err)
+	}
+	list, err := List(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "DOCS/README.TXT" || list[0].Size != 1050 ||
+		list[0].Method != "deflate" || !list[1].Dir {
+		t.Fatalf("List = %+v", list)
+	}
+	if got := extract(t, path, "DOCS/README.TXT"); !strings.HasPrefix(got, "Hello from the BBS!") || len(got) != 1050 {
+		t.Errorf("extracted %q", got)
+	}
+	if _, err := Extract(path, "DOCS/", t.TempDir()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Extract dir: %v, want ErrNotFound", err)
+	}
+}
+
+func TestLHA(t *testing.T) {
+	var arc []byte
+	arc = append(arc, lhaLevel0("STORED.TXT", "-lh0-", []byte("plain"), 5, crc16(0, []byte("plain")))...)
+	arc = append(arc, lhaLevel0("ABBA.TXT", "-lh5-", lzhABBA(4), 4, crc16(0, []byte("ABBA")))...)
+	arc = append(arc, lhaLevel2("SUB/DIR", "AAAA.TXT", "-lh5-", lzhMatch(4), 4, crc16(0, []byte("AAAA")))...)
+	arc = append(arc, lhaLevel0("BAD.TXT", "-lh5-", lzhABBA(4), 4, 0x1234)...)
+	arc = append(arc, lhaLevel0("OLD.TXT", "-lh1-", []byte{0}, 1, 0)...)
+	arc = append(arc, 0)
+	path := writeFile(t, "test.lzh", arc)
+
+	list, err := List(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 5 || list[2].Name != "SUB/DIR/AAAA.TXT" || list[1].Method != "-lh5-" ||
+		list[0].Modified.Year() != 1996 || list[2].Modified.Year() != 2001 {
+		t.Fatalf("List = %+v", list)
+	}
+	for name, want := range map[string]string{"STORED.TXT": "plain", "ABBA.TXT": "ABBA", "SUB/DIR/AAAA.TXT": "AAAA"} {
+		if got := extract(t, path, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := Extract(path, "BAD.TXT", t.TempDir()); !errors.Is(err, ErrChecksum) {
+		t.Errorf("bad CRC: %v, want ErrChecksum", err)
+	}
+	if _, err := Extract(path, "OLD.TXT", t.TempDir()); !errors.Is(err, ErrMethod) {
+		t.Errorf("-lh1-: %v, want ErrMethod", err)
+	}
+}
+
+func TestARJ(t *testing.T) {
+	var arc []byte
+	arc = append(arc, arjHeader(2, 0, "TEST.ARJ", nil, 0, 0)...)
+	arc = append(arc, arjHeader(0, 0, "STORED.TXT", []byte("plain"), 5, crc32.ChecksumIEEE([]byte("plain")))...)
+	arc = append(arc, arjHeader(0, 1, "DOCS\\ABBA.TXT", lzhABBA(5), 4, crc32.ChecksumIEEE([]byte("ABBA")))...)
+	arc = append(arc, arjHeader(0, 3, "AAAA.TXT", lzhMatch(5), 4, crc32.ChecksumIEEE([]byte("AAAA")))...)
+	// Method 4: literal 'Z' (length code 0), then a match of 3 at
+	// distance 1 (length code 1 = "10" + one bit 0, position "0" + 9 bits).
+	var w bitWriter
+	w.put(0, 1)
+	w.put('Z', 8)
+	w.put(0b100, 3)
+	w.put(0, 1)
+	w.put(0, 9)
+	arc = append(arc, arjHeader(0, 4, "FAST.TXT", w.buf, 4, crc32.ChecksumIEEE([]byte("ZZZZ")))...)
+	arc = append(arc, arjID0, arjID1, 0, 0)
+	path := writeFile(t, "test.arj", arc)
+
+	list, err := List(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 4 || list[1].Name != "DOCS/ABBA.TXT" || list[1].Method != "arj1" || list[0].Method != "stored" {
+		t.Fatalf("List = %+v", list)
+	}
+	for name, want := range map[string]string{"STORED.TXT": "plain", "DOCS/ABBA.TXT": "ABBA", "AAAA.TXT": "AAAA", "FAST.TXT": "ZZZZ"} {
+		if got := extract(t, path, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNotAnArchive(t *testing.T) {
+	path := writeFile(t, "readme.txt", []byte("just text"))
+	if _, err := List(path); !errors.Is(err, ErrFormat) {
+		t.Errorf("List: %v, want ErrFormat", err)
+	}
+}
+
+func TestSafeBase(t *testing.T) {
+	for in, want := range map[string]string{
+		"../../etc/passwd": "passwd",
+		"..\\..\\BOOT.INI": "BOOT.INI",
+		"..":               "member",
+		"DIR/":             "DIR",
+	} {
+		if got := safeBase(in); got != want {
+			t.Errorf("safeBase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package archive
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+const (
+	arjID0 = 0x60
+	arjID1 = 0xea
+
+	arjMaxHeader = 2600
+	arjGarbled   = 0x01 // flag: member is encrypted
+	arjDirectory = 3    // file type
+)
+
+var errARJHeader = errors.New("bad ARJ header")
+
+type arjEntry struct {
+	offset int64
+	method byte
+	crc    uint32
+	flags  byte
+}
+
+type arjReader struct {
+	r       io.ReaderAt
+	list    []Member
+	entries []arjEntry
+}
+
+// newARJReader reads the main header and the member headers of an ARJ
+// archive.
+func newARJReader(r io.ReaderAt, size int64) (*arjReader, error) {
+	ar := &arjReader{r: r}
+	// The main header describes the archive itself; skip it.
+	_, off, err := ar.header(0)
+	if err != nil {
+		return nil, err
+	}
+	for off < size {
+		basic, next, err := ar.header(off)
+		if err != nil {
+			return nil, err
+		}
+		if basic == nil {
+			break // end of archive
+		}
+		if len(basic) < 30 || int(basic[0]) > len(basic) {
+			return nil, errARJHeader
+		}
+		first := int(basic[0])
+		packed := int64(binary.LittleEndian.Uint32(basic[12:]))
+		name, _, _ := strings.Cut(string(basic[first:]), "\x00")
+		name = strings.ReplaceAll(name, "\\", "/")
+		dir := basic[6] == arjDirectory
+		if dir && !strings.HasSuffix(name, "/") {
+			name += "/"
+		}
+		ar.list = append(ar.list, Member{
+			Name:     name,
+			Size:     int64(binary.LittleEndian.Uint32(basic[16:])),
+			Packed:   packed,
+			Modified: dosTime(binary.LittleEndian.Uint32(basic[8:])),
+			Method:   arjMethod(basic[5]),
+			Dir:      dir,
+		})
+		ar.entries = append(ar.entries, arjEntry{
+			offset: next,
+			method: basic[5],
+			crc:    binary.LittleEndian.Uint32(basic[20:]),
+			flags:  basic[4],
+		})
+		off = next + packed
+		if off > size {
+			return nil, errARJHeader
+		}
+	}
+	return ar, nil
+}
+
+// header reads the header at off and returns its basic header, nil at
+// the end of the archive, and the offset after it and its extended
+// headers.
+func (ar *arjReader) header(off int64) ([]byte, int64, error) {
+	var head [4]byte
+	if _, err := ar.r.ReadAt(head[:], off); err != nil {
+		return nil, 0, errARJHeader
+	}
+	if head[0] != arjID0 || head[1] != arjID1 {
+		return nil, 0, errARJHeader
+	}
+	n := int64(binary.LittleEndian.Uint16(head[2:]))
+	if n == 0 {
+		return nil, off + 4, nil
+	}
+	if n > arjMaxHeader {
+		return nil, 0, errARJHeader
+	}
+	basic := make([]byte, n+4)
+	if _, err := ar.r.ReadAt(basic, off+4); err != nil {
+		return nil, 0, errARJHeader
+	}
+	if crc32.ChecksumIEEE(basic[:n]) != binary.LittleEndian.Uint32(basic[n:]) {
+		return nil, 0, errARJHeader
+	}
+	off += 4 + n + 4
+	// Extended headers, each followed by a CRC, until a zero size.
+	for {
+		var sz [2]byte
+		if _, err := ar.r.ReadAt(sz[:], off); err != nil {
+			return nil, 0, errARJHeader
+		}
+		off += 2
+		ext := int64(binary.LittleEndian.Uint16(sz[:]))
+		if ext == 0 {
+			break
+		}
+		off += ext + 4
+	}
+	return basic[:n], off, nil
+}
+
+func (ar *arjReader) members() []Member { return ar.list }
+
+func (ar *arjReader) open(i int) (io.Reader, error) {
+	m, e := ar.list[i], ar.entries[i]
+	if e.flags&arjGarbled != 0 {
+		return nil, ErrMethod
+	}
+	src := io.NewSectionReader(ar.r, e.offset, m.Packed)
+	var r io.Reader
+	switch e.method {
+	case 0:
+		r = src
+	case 1, 2, 3:
+		r = newLZHReader(src, m.Size, 16, 5)
+	case 4:
+		r = newARJFastReader(src, m.Size)
+	default:
+		return nil, ErrMethod
+	}
+	crc := crc32.NewIEEE()
+	return &checkedReader{
+		r:   r,
+		sum: func(p []byte) { crc.Write(p) },
+		ok:  func() bool { return crc.Sum32() == e.crc },
+	}, nil
+}
+
+func arjMethod(m byte) string {
+	if m == 0 {
+		return "stored"
+	}
+	return "arj" + string('0'+m)
+}
+
+// arjFastReader decompresses ARJ method 4, an LZ77 with unary-prefixed
+// lengths and positions in place of Huffman codes.
+type arjFastReader struct {
+	br   *bitReader
+	dict []byte
+	pos  int
+	left int64
+	out  []byte
+}
+
+func newARJFastReader(r io.Reader, size int64) *arjFastReader {
+	return &arjFastReader{
+		br:   newBitReader(r),
+		dict: make([]byte, 1<<16),
+		left: size,
+	}
+}
+
+// number reads a value coded as up to stop-start one bits, each
+// doubling the base from 1<<start, then width plain bits.
+func (z *arjFastReader) number(start, stop int) int {
+	plus, pwr, width := 0, 1<<start, start
+	for ; width < stop; width++ {
+		if z.br.bits(1) == 0 {
+			break
+		}
+		plus += pwr
+		pwr <<= 1
+	}
+	return plus + z.br.bits(uint(width))
+}
+
+func (z *arjFastReader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.left <= 0 {
+			return 0, io.EOF
+		}
+		mask := len(z.dict) - 1
+		start := z.pos
+		if c := z.number(0, 7); c == 0 {
+			z.dict[z.pos&mask] = byte(z.br.bits(8))
+			z.pos++
+		} else {
+			n := c - 1 + lzhThreshold
+			from := z.pos - z.number(9, 13) - 1
+			for ; n > 0; n-- {
+				z.dict[z.pos&mask] = z.dict[from&mask]
+				z.pos++
+				from++
+			}
+		}
+		n := int64(z.pos - start)
+		if n > z.left {
+			n = z.left
+		}
+		z.left -= n
+		for i := start; i < start+int(n); i++ {
+			z.out = append(z.out, z.dict[i&mask])
+		}
+	}
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+	return n, nil
+}
@@ -0,0 +1,181 @@
+package archive
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var errLHAHeader = errors.New("bad LHA header")
+
+type lhaEntry struct {
+	offset int64 // of the compressed data
+	crc    uint16
+}
+
+type lhaReader struct {
+	r       io.ReaderAt
+	list    []Member
+	entries []lhaEntry
+}
+
+// newLHAReader reads the headers of an LHA archive, levels 0 to 2.
+func newLHAReader(r io.ReaderAt, size int64) (*lhaReader, error) {
+	lr := &lhaReader{r: r}
+	var off int64
+	for off < size {
+		head := make([]byte, 26)
+		n, _ := r.ReadAt(head, off)
+		if n == 0 || head[0] == 0 {
+			break // end of archive
+		}
+		if n < 22 {
+			return nil, errLHAHeader
+		}
+		m, e, next, err := lr.header(head[:n], off)
+		if err != nil {
+			return nil, err
+		}
+		if next > size {
+			return nil, errLHAHeader
+		}
+		lr.list = append(lr.list, m)
+		lr.entries = append(lr.entries, e)
+		off = next
+	}
+	return lr, nil
+}
+
+// header parses the header at off and returns the offset of the next one.
+func (lr *lhaReader) header(head []byte, off int64) (Member, lhaEntry, int64, error) {
+	var m Member
+	var e lhaEntry
+	level := head[20]
+	m.Method = string(head[2:7])
+	packed := int64(binary.LittleEndian.Uint32(head[7:]))
+	m.Size = int64(binary.LittleEndian.Uint32(head[11:]))
+	stamp := binary.LittleEndian.Uint32(head[15:])
+
+	var dir, name string
+	var hdrLen, extOff int64
+	switch level {
+	case 0, 1:
+		hdrLen = int64(head[0]) + 2
+		buf := make([]byte, hdrLen)
+		if _, err := lr.r.ReadAt(buf, off); err != nil {
+			return m, e, 0, errLHAHeader
+		}
+		nameLen := int(buf[21])
+		if 22+nameLen+2 > len(buf) {
+			return m, e, 0, errLHAHeader
+		}
+		name = string(buf[22 : 22+nameLen])
+		e.crc = binary.LittleEndian.Uint16(buf[22+nameLen:])
+		m.Modified = dosTime(stamp)
+		if level == 1 {
+			extOff = off + hdrLen - 2
+		}
+	case 2:
+		if len(head) < 26 {
+			return m, e, 0, errLHAHeader
+		}
+		hdrLen = int64(binary.LittleEndian.Uint16(head[0:]))
+		e.crc = binary.LittleEndian.Uint16(head[21:])
+		m.Modified = time.Unix(int64(stamp), 0)
+		extOff = off + 24
+	default:
+		return m, e, 0, errLHAHeader
+	}
+
+	// Extended headers: a chain of (size, type, data) after the base
+	// header. In level 1 the packed size counts them too.
+	var extLen int64
+	for extOff > 0 {
+		var sz [2]byte
+		if _, err := lr.r.ReadAt(sz[:], extOff); err != nil {
+			return m, e, 0, errLHAHeader
+		}
+		n := int64(binary.LittleEndian.Uint16(sz[:]))
+		if n == 0 {
+			break
+		}
+		if n < 3 {
+			return m, e, 0, errLHAHeader
+		}
+		ext := make([]byte, n)
+		if _, err := lr.r.ReadAt(ext, extOff+2); err != nil {
+			return m, e, 0, errLHAHeader
+		}
+		switch ext[0] {
+		case 0x01:
+			name = string(ext[1 : n-2])
+		case 0x02:
+			dir = strings.ReplaceAll(string(ext[1:n-2]), "\xff", "/")
+		}
+		extLen += n
+		extOff += n
+	}
+	if level == 1 {
+		packed -= extLen
+		hdrLen += extLen
+	}
+	if packed < 0 {
+		return m, e, 0, errLHAHeader
+	}
+
+	name = strings.ReplaceAll(name, "\\", "/")
+	if dir != "" && !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	m.Name = dir + name
+	m.Packed = packed
+	m.Dir = m.Method == "-lhd-"
+	if m.Dir && !strings.HasSuffix(m.Name, "/") {
+		m.Name += "/"
+	}
+	e.offset = off + hdrLen
+	return m, e, e.offset + packed, nil
+}
+
+func (lr *lhaReader) members() []Member { return lr.list }
+
+func (lr *lhaReader) open(i int) (io.Reader, error) {
+	m, e := lr.list[i], lr.entries[i]
+	src := io.NewSectionReader(lr.r, e.offset, m.Packed)
+	var r io.Reader
+	switch m.Method {
+	case "-lh0-", "-lz4-":
+		r = src
+	case "-lh5-":
+		r = newLZHReader(src, m.Size, 13, 4)
+	case "-lh6-":
+		r = newLZHReader(src, m.Size, 15, 5)
+	case "-lh7-":
+		r = newLZHReader(src, m.Size, 16, 5)
+	default:
+		return nil, ErrMethod
+	}
+	var crc uint16
+	return &checkedReader{
+		r:   r,
+		sum: func(p []byte) { crc = crc16(crc, p) },
+		ok:  func() bool { return crc == e.crc },
+	}, nil
+}
+
+// crc16 updates crc with p using the CRC-16/ARC polynomial LHA uses.
+func crc16(crc uint16, p []byte) uint16 {
+	for _, b := range p {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,368 @@
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// The LZ77 + static Huffman method shared by LHA -lh5- to -lh7- and ARJ
+// methods 1 to 3, after Haruhiko Okumura's ar002.
+
+const (
+	lzhMaxMatch  = 256
+	lzhThreshold = 3
+	lzhNC        = 255 + lzhMaxMatch + 2 - lzhThreshold // literal/length codes
+	lzhNT        = 16 + 3                               // code length codes
+	lzhCBit      = 9
+	lzhTBit      = 5
+)
+
+var errLZHTable = errors.New("corrupt compressed data")
+
+// bitReader reads bits most significant first, past EOF as zeros.
+type bitReader struct {
+	r         io.ByteReader
+	bitbuf    uint32 // 16 bits
+	subbitbuf uint32
+	bitcount  uint
+}
+
+func newBitReader(r io.Reader) *bitReader {
+	br := &bitReader{r: bufio.NewReader(r)}
+	br.fill(16)
+	return br
+}
+
+func (b *bitReader) fill(n uint) {
+	b.bitbuf = b.bitbuf << n & 0xffff
+	for n > b.bitcount {
+		n -= b.bitcount
+		b.bitbuf |= b.subbitbuf << n & 0xffff
+		c, err := b.r.ReadByte()
+		if err != nil {
+			c = 0
+		}
+		b.subbitbuf = uint32(c)
+		b.bitcount = 8
+	}
+	b.bitcount -= n
+	b.bitbuf |= b.subbitbuf >> b.bitcount
+}
+
+func (b *bitReader) bits(n uint) int {
+	if n == 0 {
+		return 0
+	}
+	x := b.bitbuf >> (16 - n)
+	b.fill(n)
+	return int(x)
+}
+
+// huffman decodes one block's code tables.
+type huffman struct {
+	br      *bitReader
+	np      int
+	pbit    uint
+	block   int
+	cLen    [lzhNC]byte
+	ptLen   [lzhNT + 8]byte // NP is at most 18
+	cTable  [4096]uint16
+	ptTable [256]uint16
+	left    [2*lzhNC - 1]uint16
+	right   [2*lzhNC - 1]uint16
+}
+
+// makeTable builds a lookup table of tableBits bits for the code lengths
+// in bitLen, with longer codes continuing in the left and right trees.
+func (h *huffman) makeTable(bitLen []byte, tableBits uint, table []uint16) error {
+	var count, weight [17]int
+	var start [18]int
+	for _, l := range bitLen {
+		if l > 16 {
+			return errLZHTable
+		}
+		count[l]++
+	}
+	for i := 1; i <= 16; i++ {
+		start[i+1] = start[i] + count[i]<<(16-i)
+	}
+	if start[17] != 1<<16 {
+		return errLZHTable
+	}
+	jut := 16 - tableBits
+	for i := uint(1); i <= tableBits; i++ {
+		start[i] >>= jut
+		weight[i] = 1 << (tableBits - i)
+	}
+	for i := tableBits + 1; i <= 16; i++ {
+		weight[i] = 1 << (16 - i)
+	}
+	for i := start[tableBits+1] >> jut; i < 1<<tableBits; i++ {
+		table[i] = 0
+	}
+
+	avail := uint16(len(bitLen))
+	mask := 1 << (15 - tableBits)
+	for ch, l := range bitLen {
+		if l == 0 {
+			continue
+		}
+		next := start[l] + weight[l]
+		if uint(l) <= tableBits {
+			if next > len(table) {
+				return errLZHTable
+			}
+			for i := start[l]; i < next; i++ {
+				table[i] = uint16(ch)
+			}
+		} else {
+			k := start[l]
+			p := &table[k>>jut]
+			for i := uint(l) - tableBits; i > 0; i-- {
+				if *p == 0 {
+					if int(avail) >= len(h.left) {
+						return errLZHTable
+					}
+					h.left[avail], h.right[avail] = 0, 0
+					*p = avail
+					avail++
+				}
+				if k&mask != 0 {
+					p = &h.right[*p]
+				} else {
+					p = &h.left[*p]
+				}
+				k <<= 1
+			}
+			*p = uint16(ch)
+		}
+		start[l] = next
+	}
+	return nil
+}
+
+// walk follows the tree from j while it is a node rather than a symbol.
+func (h *huffman) walk(j uint16, n int, mask uint32) (uint16, error) {
+	for int(j) >= n {
+		if int(j) >= len(h.left) || mask == 0 {
+			return 0, errLZHTable
+		}
+		if h.br.bitbuf&mask != 0 {
+			j = h.right[j]
+		} else {
+			j = h.left[j]
+		}
+		mask >>= 1
+	}
+	return j, nil
+}
+
+func (h *huffman) readPtLen(nn int, nbit uint, special int) error {
+	n := h.br.bits(nbit)
+	if n == 0 {
+		c := h.br.bits(nbit)
+		if c >= nn {
+			return errLZHTable
+		}
+		for i := 0; i < nn; i++ {
+			h.ptLen[i] = 0
+		}
+		for i := range h.ptTable {
+			h.ptTable[i] = uint16(c)
+		}
+		return nil
+	}
+	if n > nn {
+		return errLZHTable
+	}
+	i := 0
+	for i < n {
+		c := int(h.br.bitbuf >> 13)
+		if c == 7 {
+			for mask := uint32(1 << 12); mask&h.br.bitbuf != 0; mask >>= 1 {
+				c++
+			}
+			if c > 16 {
+				return errLZHTable
+			}
+		}
+		if c < 7 {
+			h.br.fill(3)
+		} else {
+			h.br.fill(uint(c - 3))
+		}
+		h.ptLen[i] = byte(c)
+		i++
+		if i == special {
+			for c := h.br.bits(2); c > 0 && i < nn; c-- {
+				h.ptLen[i] = 0
+				i++
+			}
+		}
+	}
+	for ; i < nn; i++ {
+		h.ptLen[i] = 0
+	}
+	return h.makeTable(h.ptLen[:nn], 8, h.ptTable[:])
+}
+
+func (h *huffman) readCLen() error {
+	n := h.br.bits(lzhCBit)
+	if n == 0 {
+		c := h.br.bits(lzhCBit)
+		if c >= lzhNC {
+			return errLZHTable
+		}
+		for i := range h.cLen {
+			h.cLen[i] = 0
+		}
+		for i := range h.cTable {
+			h.cTable[i] = uint16(c)
+		}
+		return nil
+	}
+	if n > lzhNC {
+		return errLZHTable
+	}
+	i := 0
+	for i < n {
+		c, err := h.walk(h.ptTable[h.br.bitbuf>>8], lzhNT, 1<<7)
+		if err != nil {
+			return err
+		}
+		h.br.fill(uint(h.ptLen[c]))
+		if c <= 2 {
+			var run int
+			switch c {
+			case 0:
+				run = 1
+			case 1:
+				run = h.br.bits(4) + 3
+			default:
+				run = h.br.bits(lzhCBit) + 20
+			}
+			for ; run > 0 && i < lzhNC; run-- {
+				h.cLen[i] = 0
+				i++
+			}
+		} else {
+			h.cLen[i] = byte(c - 2)
+			i++
+		}
+	}
+	for ; i < lzhNC; i++ {
+		h.cLen[i] = 0
+	}
+	return h.makeTable(h.cLen[:], 12, h.cTable[:])
+}
+
+func (h *huffman) decodeC() (int, error) {
+	if h.block == 0 {
+		h.block = h.br.bits(16)
+		if err := h.readPtLen(lzhNT, lzhTBit, 3); err != nil {
+			return 0, err
+		}
+		if err := h.readCLen(); err != nil {
+			return 0, err
+		}
+		if err := h.readPtLen(h.np, h.pbit, -1); err != nil {
+			return 0, err
+		}
+	}
+	h.block--
+	j, err := h.walk(h.cTable[h.br.bitbuf>>4], lzhNC, 1<<3)
+	if err != nil {
+		return 0, err
+	}
+	h.br.fill(uint(h.cLen[j]))
+	return int(j), nil
+}
+
+func (h *huffman) decodeP() (int, error) {
+	j, err := h.walk(h.ptTable[h.br.bitbuf>>8], h.np, 1<<7)
+	if err != nil {
+		return 0, err
+	}
+	h.br.fill(uint(h.ptLen[j]))
+	if j != 0 {
+		return 1<<(j-1) + h.br.bits(uint(j-1)), nil
+	}
+	return 0, nil
+}
+
+// lzhReader decompresses size bytes of -lh5- style data.
+type lzhReader struct {
+	h    huffman
+	dict []byte
+	pos  int   // total bytes decoded
+	left int64 // bytes still to return
+	out  []byte
+	err  error
+}
+
+// newLZHReader returns a reader for data compressed with a dictionary of
+// 1<<dicBit bytes and pbit bits for the position code count.
+func newLZHReader(r io.Reader, size int64, dicBit, pbit uint) *lzhReader {
+	z := &lzhReader{dict: make([]byte, 1<<dicBit), left: size}
+	z.h.br = newBitReader(r)
+	z.h.np = int(dicBit) + 1
+	z.h.pbit = pbit
+	return z
+}
+
+func (z *lzhReader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		if z.left <= 0 {
+			return 0, io.EOF
+		}
+		z.step()
+	}
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+	return n, nil
+}
+
+// step decodes one literal or match into out.
+func (z *lzhReader) step() {
+	mask := len(z.dict) - 1
+	c, err := z.h.decodeC()
+	if err != nil {
+		z.err = err
+		return
+	}
+	start := z.pos
+	if c <= 255 {
+		z.dict[z.pos&mask] = byte(c)
+		z.pos++
+	} else {
+		n := c - 256 + lzhThreshold
+		d, err := z.h.decodeP()
+		if err != nil {
+			z.err = err
+			return
+		}
+		if d >= len(z.dict) {
+			z.err = errLZHTable
+			return
+		}
+		from := z.pos - d - 1
+		for ; n > 0; n-- {
+			z.dict[z.pos&mask] = z.dict[from&mask]
+			z.pos++
+			from++
+		}
+	}
+	n := int64(z.pos - start)
+	if n > z.left {
+		n = z.left
+	}
+	z.left -= n
+	z.out = z.out[:0]
+	for i := start; i < start+int(n); i++ {
+		z.out = append(z.out, z.dict[i&mask])
+	}
+}
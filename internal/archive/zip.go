@@ -0,0 +1,71 @@
+package archive
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"strings"
+)
+
+type zipReader struct {
+	z    *zip.Reader
+	list []Member
+}
+
+func newZipReader(r io.ReaderAt, size int64) (*zipReader, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	zr := &zipReader{z: z}
+	for _, f := range z.File {
+		zr.list = append(zr.list, Member{
+			Name:     f.Name,
+			Size:     int64(f.UncompressedSize64),
+			Packed:   int64(f.CompressedSize64),
+			Modified: f.Modified,
+			Method:   zipMethod(f.Method),
+			Dir:      strings.HasSuffix(f.Name, "/"),
+		})
+	}
+	return zr, nil
+}
+
+func (zr *zipReader) members() []Member { return zr.list }
+
+func (zr *zipReader) open(i int) (io.Reader, error) {
+	f := zr.z.File[i]
+	if f.Flags&0x1 != 0 {
+		return nil, ErrMethod // encrypted
+	}
+	rc, err := f.Open()
+	if errors.Is(err, zip.ErrAlgorithm) {
+		return nil, ErrMethod
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The archive file is closed by the caller; rc holds nothing else.
+	return rc, nil
+}
+
+// zipMethod names the ZIP compression methods of the PKZIP era.
+func zipMethod(m uint16) string {
+	switch m {
+	case zip.Store:
+		return "stored"
+	case 1:
+		return "shrunk"
+	case 2, 3, 4, 5:
+		return "reduced"
+	case 6:
+		return "imploded"
+	case zip.Deflate:
+		return "deflate"
+	case 9:
+		return "deflate64"
+	case 12:
+		return "bzip2"
+	}
+	return "unknown"
+}
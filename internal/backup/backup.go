@@ -0,0 +1,394 @@
+// Package backup packs a board's state into one .tar.gz archive and
+// unpacks it again: a snapshot of the database, the config file, and the
+// menu, command, text and door directories. A manifest at the start of the
+// archive records the format version, the database schema version and a
+// SHA-256 checksum of every file, and restoring checks all of them before
+// anything on disk is replaced.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// Format is the archive format version written by this build. Archives
+// with a newer format are refused.
+const Format = 1
+
+const manifestName = "manifest.json"
+
+// Database is the component name of the database snapshot.
+const Database = "database.db"
+
+// Manifest describes an archive.
+type Manifest struct {
+	Format     int         `json:"format"`
+	Created    time.Time   `json:"created"`
+	Host       string      `json:"host"`
+	Schema     int         `json:"schema"` // migrations applied to the database
+	Components []Component `json:"components"`
+	Files      []File      `json:"files"`
+}
+
+// Component is one top-level item in an archive: the database, the config
+// file or an asset directory.
+type Component struct {
+	Name string `json:"name"` // e.g. "menus", "config.yaml"
+	Dir  bool   `json:"dir"`
+}
+
+// File is a regular file in an archive.
+type File struct {
+	Path   string `json:"path"` // slash-separated, starting with the component name
+	Size   int64  `json:"size"`
+	Mode   uint32 `json:"mode"`
+	SHA256 string `json:"sha256"`
+}
+
+// Source is a component to back up and where it lives on disk.
+type Source struct {
+	Name     string
+	Path     string
+	Optional bool // skipped when missing, e.g. an unused door directory
+}
+
+// Create writes an archive of the database and sources to w. The database
+// is snapshotted first, so the BBS may keep running.
+func Create(w io.Writer, database string, sources []Source) (*Manifest, error) {
+	tmp, err := os.MkdirTemp("", "bbs-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	snapshot := filepath.Join(tmp, Database)
+	schema, err := db.Snapshot(database, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	m := &Manifest{Format: Format, Created: time.Now().UTC(), Host: host, Schema: schema}
+	sources = append([]Source{{Name: Database, Path: snapshot}}, sources...)
+	var disk []string // local path of each manifest file
+	for _, src := range sources {
+		st, err := os.Stat(src.Path)
+		if errors.Is(err, fs.ErrNotExist) && src.Optional {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("back up %s: %w", src.Name, err)
+		}
+		m.Components = append(m.Components, Component{Name: src.Name, Dir: st.IsDir()})
+		err = filepath.WalkDir(src.Path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(src.Path, p)
+			if err != nil {
+				return err
+			}
+			f, err := hashFile(p)
+			if err != nil {
+				return err
+			}
+			f.Path = path.Join(src.Name, filepath.ToSlash(rel))
+			m.Files = append(m.Files, f)
+			disk = append(disk, p)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("back up %s: %w", src.Name, err)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	hdr := &tar.Header{Name: manifestName, Mode: 0644, Size: int64(len(data)), ModTime: m.Created}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+	for i, f := range m.Files {
+		if err := addFile(tw, disk[i], f, m.Created); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func hashFile(p string) (File, error) {
+	fh, err := os.Open(p)
+	if err != nil {
+		return File{}, err
+	}
+	defer fh.Close()
+	st, err := fh.Stat()
+	if err != nil {
+		return File{}, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, fh)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Size: n, Mode: uint32(st.Mode().Perm()), SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// addFile writes a file into the archive, failing if it no longer matches
+// what the manifest says, i.e. it changed during the backup.
+func addFile(tw *tar.Writer, p string, f File, modTime time.Time) error {
+	fh, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	hdr := &tar.Header{Name: f.Path, Mode: int64(f.Mode), Size: f.Size, ModTime: modTime}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tw, h), io.LimitReader(fh, f.Size))
+	if err != nil {
+		return err
+	}
+	if n != f.Size || hex.EncodeToString(h.Sum(nil)) != f.SHA256 {
+		return fmt.Errorf("%s changed during the backup; try again", f.Path)
+	}
+	return nil
+}
+
+// Extract unpacks an archive into dir, which must be empty or missing,
+// and checks it: the format and schema versions, every file against the
+// manifest, and the database's integrity. dir then holds one entry per
+// component, ready for Install.
+func Extract(r io.Reader, dir string) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != manifestName {
+		return nil, errors.New("read archive: no manifest; not a BBS backup")
+	}
+	m := &Manifest{}
+	if err := json.NewDecoder(io.LimitReader(tr, 16<<20)).Decode(m); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if m.Format < 1 || m.Format > Format {
+		return nil, fmt.Errorf("archive format %d is not supported (this build reads up to %d)", m.Format, Format)
+	}
+	if m.Schema > db.SchemaVersion() {
+		return nil, fmt.Errorf("database schema %d is newer than this build's %d; upgrade the BBS first", m.Schema, db.SchemaVersion())
+	}
+	components := make(map[string]bool)
+	for _, c := range m.Components {
+		if !validName(c.Name) {
+			return nil, fmt.Errorf("read manifest: bad component %q", c.Name)
+		}
+		components[c.Name] = true
+	}
+	if !components[Database] {
+		return nil, errors.New("read manifest: no database")
+	}
+	want := make(map[string]File, len(m.Files))
+	for _, f := range m.Files {
+		want[f.Path] = f
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		f, ok := want[hdr.Name]
+		if !ok || hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("read archive: %s is not in the manifest", hdr.Name)
+		}
+		delete(want, hdr.Name)
+		if err := extractFile(tr, dir, f); err != nil {
+			return nil, err
+		}
+	}
+	if len(want) > 0 {
+		missing := make([]string, 0, len(want))
+		for p := range want {
+			missing = append(missing, p)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("archive is incomplete: %s missing", strings.Join(missing, ", "))
+	}
+
+	schema, err := db.Check(filepath.Join(dir, Database))
+	if err != nil {
+		return nil, err
+	}
+	if schema != m.Schema {
+		return nil, fmt.Errorf("database schema is %d, manifest says %d", schema, m.Schema)
+	}
+	return m, nil
+}
+
+// validName accepts component names: a single path element.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+func extractFile(r io.Reader, dir string, f File) error {
+	clean := path.Clean(f.Path)
+	if clean != f.Path || path.IsAbs(clean) || strings.HasPrefix(clean, "../") || !validName(strings.Split(clean, "/")[0]) {
+		return fmt.Errorf("read archive: unsafe path %q", f.Path)
+	}
+	dst := filepath.Join(dir, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fs.FileMode(f.Mode)&0777|0600)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), io.LimitReader(r, f.Size+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if n != f.Size || hex.EncodeToString(h.Sum(nil)) != f.SHA256 {
+		return fmt.Errorf("%s is corrupt: checksum does not match the manifest", f.Path)
+	}
+	return nil
+}
+
+// Install moves the components extracted into dir to their places. A
+// component whose target exists is refused unless force is set; then the
+// old one is kept beside it with a ".before-restore" suffix. It returns
+// what it did, one line per component.
+func Install(dir string, m *Manifest, targets map[string]string, force bool) ([]string, error) {
+	var comps []Component
+	for _, c := range m.Components {
+		if _, ok := targets[c.Name]; !ok {
+			return nil, fmt.Errorf("no place to restore %s to", c.Name)
+		}
+		comps = append(comps, c)
+	}
+	if !force {
+		var exist []string
+		for _, c := range comps {
+			if _, err := os.Stat(targets[c.Name]); err == nil {
+				exist = append(exist, targets[c.Name])
+			}
+		}
+		if len(exist) > 0 {
+			return nil, fmt.Errorf("%s already exist(s); use -force to replace", strings.Join(exist, ", "))
+		}
+	}
+
+	var lines []string
+	for _, c := range comps {
+		target := targets[c.Name]
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return lines, err
+		}
+		moved := ""
+		if _, err := os.Stat(target); err == nil {
+			old := target + ".before-restore"
+			if err := os.RemoveAll(old); err != nil {
+				return lines, err
+			}
+			if err := os.Rename(target, old); err != nil {
+				return lines, err
+			}
+			moved = " (previous kept as " + old + ")"
+			if c.Name == Database {
+				// The old database's WAL belongs to it, not the restored one.
+				for _, ext := range []string{"-wal", "-shm"} {
+					os.Rename(target+ext, old+ext)
+				}
+			}
+		}
+		if err := move(filepath.Join(dir, c.Name), target); err != nil {
+			return lines, fmt.Errorf("restore %s: %w", c.Name, err)
+		}
+		lines = append(lines, fmt.Sprintf("%s -> %s%s", c.Name, target, moved))
+	}
+	return lines, nil
+}
+
+// move renames src to dst, copying when they are on different file
+// systems.
+func move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		out := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(out, 0755)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return copyFile(p, out, info.Mode().Perm())
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,159 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// board creates a small board on disk and returns its database path and
+// sources.
+func board(t *testing.T) (string, []Source) {
+	t.Helper()
+	dir := t.TempDir()
+	database := filepath.Join(dir, "data", "bbs.db")
+	os.MkdirAll(filepath.Dir(database), 0755)
+	d, err := db.Open(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO users (username, password_hash) VALUES ('sysop', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	files := map[string]string{
+		"config.yaml":        "bbs:\n  name: Test\n",
+		"menus/main.lua":     "-- main menu\n",
+		"menus/main.ans":     "\x1b[1mMain\x1b[0m",
+		"text/sub/logoff.an": "Bye",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return database, []Source{
+		{Name: "config.yaml", Path: filepath.Join(dir, "config.yaml")},
+		{Name: "menus", Path: filepath.Join(dir, "menus")},
+		{Name: "text", Path: filepath.Join(dir, "text")},
+		{Name: "doors", Path: filepath.Join(dir, "doors"), Optional: true},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	database, sources := board(t)
+	var buf bytes.Buffer
+	m, err := Create(&buf, database, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Schema != db.SchemaVersion() || len(m.Components) != 4 || len(m.Files) != 5 {
+		t.Fatalf("manifest = %+v", m)
+	}
+
+	stage := filepath.Join(t.TempDir(), "stage")
+	if _, err := Extract(bytes.NewReader(buf.Bytes()), stage); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	targets := map[string]string{
+		Database:      filepath.Join(dest, "data", "twilight.db"),
+		"config.yaml": filepath.Join(dest, "config.yaml"),
+		"menus":       filepath.Join(dest, "assets", "menus"),
+		"text":        filepath.Join(dest, "assets", "text"),
+	}
+	os.WriteFile(targets["config.yaml"], []byte("old"), 0644)
+	if _, err := Install(stage, m, targets, false); err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Fatalf("install over existing files: %v", err)
+	}
+	if _, err := Install(stage, m, targets, true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "assets", "text", "sub", "logoff.an")); string(data) != "Bye" {
+		t.Errorf("restored text = %q", data)
+	}
+	if data, _ := os.ReadFile(targets["config.yaml"] + ".before-restore"); string(data) != "old" {
+		t.Errorf("old config = %q", data)
+	}
+	d, err := db.Open(targets[Database])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	var name string
+	if err := d.QueryRow(`SELECT username FROM users`).Scan(&name); err != nil || name != "sysop" {
+		t.Fatalf("restored user = %q, %v", name, err)
+	}
+}
+
+// rewrite returns archive with the contents of one file replaced.
+func rewrite(t *testing.T, archive []byte, name string, edit func([]byte) []byte) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		if hdr.Name == name {
+			data = edit(data)
+			hdr.Size = int64(len(data))
+		}
+		tw.WriteHeader(hdr)
+		tw.Write(data)
+	}
+	tw.Close()
+	gw.Close()
+	return out.Bytes()
+}
+
+func TestExtractRejects(t *testing.T) {
+	database, sources := board(t)
+	var buf bytes.Buffer
+	if _, err := Create(&buf, database, sources); err != nil {
+		t.Fatal(err)
+	}
+	replace := func(old, new string) func([]byte) []byte {
+		return func(b []byte) []byte { return bytes.Replace(b, []byte(old), []byte(new), 1) }
+	}
+	tests := []struct {
+		name    string
+		archive []byte
+		want    string
+	}{
+		{"corrupt file", rewrite(t, buf.Bytes(), "menus/main.lua", replace("main", "evil")), "checksum"},
+		{"newer format", rewrite(t, buf.Bytes(), manifestName, replace(`"format": 1`, `"format": 2`)), "format 2"},
+		{"newer schema", rewrite(t, buf.Bytes(), manifestName, replace(`"schema": `, `"schema": 9`)), "upgrade"},
+		{"traversal", rewrite(t, buf.Bytes(), manifestName, replace(`"path": "menus/main.lua"`, `"path": "menus/../../main.lua"`)), "not in the manifest"},
+		{"not gzip", []byte("hello"), "read archive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Extract(bytes.NewReader(tt.archive), t.TempDir())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
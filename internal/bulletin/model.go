@@ -0,0 +1,22 @@
+package bulletin
+
+import "time"
+
+// Bulletin is a sysop announcement shown to callers. Either Body or Art (a
+// display file name resolved like node:display) carries the content.
+type Bulletin struct {
+	ID          int
+	Title       string
+	Body        string
+	Art         string
+	PublishedAt time.Time  // not shown before this time
+	ExpiresAt   *time.Time // nil = never expires
+}
+
+// Active reports whether the bulletin is published and not expired at now.
+func (b *Bulletin) Active(now time.Time) bool {
+	if now.Before(b.PublishedAt) {
+		return false
+	}
+	return b.ExpiresAt == nil || now.Before(*b.ExpiresAt)
+}
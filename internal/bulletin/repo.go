@@ -0,0 +1,159 @@
+package bulletin
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// Repo handles database operations for bulletins.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo creates a new bulletin repository.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+// List returns all bulletins, including scheduled and expired ones, newest
+// first.
+func (r *Repo) List() ([]*Bulletin, error) {
+	return r.query(`SELECT id, title, body, art, published_at, expires_at FROM bulletins
+		ORDER BY published_at DESC, id DESC`)
+}
+
+// Active returns the bulletins published and not expired at now, newest
+// first.
+func (r *Repo) Active(now time.Time) ([]*Bulletin, error) {
+	ts := formatTime(now)
+	return r.query(`SELECT id, title, body, art, published_at, expires_at FROM bulletins
+		WHERE published_at <= ? AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY published_at DESC, id DESC`, ts, ts)
+}
+
+// Unseen returns the active bulletins a user has not marked seen, oldest
+// first so they read in publication order.
+func (r *Repo) Unseen(userID int, now time.Time) ([]*Bulletin, error) {
+	ts := formatTime(now)
+	return r.query(`SELECT id, title, body, art, published_at, expires_at FROM bulletins b
+		WHERE published_at <= ? AND (expires_at IS NULL OR expires_at > ?)
+		  AND NOT EXISTS (SELECT 1 FROM bulletin_seen s WHERE s.bulletin_id = b.id AND s.user_id = ?)
+		ORDER BY published_at, id`, ts, ts, userID)
+}
+
+// Get returns a single bulletin by ID.
+func (r *Repo) Get(id int) (*Bulletin, error) {
+	list, err := r.query(`SELECT id, title, body, art, published_at, expires_at FROM bulletins WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("bulletin %d not found", id)
+	}
+	return list[0], nil
+}
+
+// GetByTitle returns the newest bulletin with the given title, nil when
+// there is none.
+func (r *Repo) GetByTitle(title string) (*Bulletin, error) {
+	list, err := r.query(`SELECT id, title, body, art, published_at, expires_at FROM bulletins
+		WHERE title = ? ORDER BY id DESC LIMIT 1`, title)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return list[0], nil
+}
+
+// MarkSeen records that a user has read a bulletin.
+func (r *Repo) MarkSeen(userID, bulletinID int) error {
+	_, err := r.db.Exec(`INSERT OR IGNORE INTO bulletin_seen (user_id, bulletin_id) VALUES (?, ?)`,
+		userID, bulletinID)
+	if err != nil {
+		return fmt.Errorf("mark bulletin %d seen: %w", bulletinID, err)
+	}
+	return nil
+}
+
+// Create stores a new bulletin and returns its ID.
+func (r *Repo) Create(b *Bulletin) (int, error) {
+	if b.Title == "" {
+		return 0, fmt.Errorf("create bulletin: title is required")
+	}
+	result, err := r.db.Exec(`
+		INSERT INTO bulletins (title, body, art, published_at, expires_at) VALUES (?, ?, ?, ?, ?)
+	`, b.Title, b.Body, b.Art, formatTime(b.PublishedAt), nullTime(b.ExpiresAt))
+	if err != nil {
+		return 0, fmt.Errorf("create bulletin: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	b.ID = int(id)
+	return b.ID, nil
+}
+
+// Update saves changes to an existing bulletin. Users who already saw it
+// are not shown it again.
+func (r *Repo) Update(b *Bulletin) error {
+	result, err := r.db.Exec(`
+		UPDATE bulletins SET title = ?, body = ?, art = ?, published_at = ?, expires_at = ? WHERE id = ?
+	`, b.Title, b.Body, b.Art, formatTime(b.PublishedAt), nullTime(b.ExpiresAt), b.ID)
+	if err != nil {
+		return fmt.Errorf("update bulletin %d: %w", b.ID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("bulletin %d not found", b.ID)
+	}
+	return nil
+}
+
+// Delete removes a bulletin and its seen records.
+func (r *Repo) Delete(id int) error {
+	if _, err := r.db.Exec(`DELETE FROM bulletin_seen WHERE bulletin_id = ?`, id); err != nil {
+		return fmt.Errorf("delete bulletin %d: %w", id, err)
+	}
+	if _, err := r.db.Exec(`DELETE FROM bulletins WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete bulletin %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *Repo) query(query string, args ...interface{}) ([]*Bulletin, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list bulletins: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*Bulletin
+	for rows.Next() {
+		b := &Bulletin{}
+		var expires sql.NullTime
+		if err := rows.Scan(&b.ID, &b.Title, &b.Body, &b.Art, &b.PublishedAt, &expires); err != nil {
+			return nil, err
+		}
+		if expires.Valid {
+			b.ExpiresAt = &expires.Time
+		}
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(db.SQLiteTime)
+}
+
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
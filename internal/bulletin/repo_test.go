@@ -0,0 +1,58 @@
+package bulletin
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestUnseenHonoursScheduleAndSeen(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepo(database.DB)
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-48*time.Hour), now.Add(48*time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+
+	current, _ := repo.Create(&Bulletin{Title: "current", PublishedAt: past})
+	repo.Create(&Bulletin{Title: "scheduled", PublishedAt: future})
+	repo.Create(&Bulletin{Title: "expired", PublishedAt: past, ExpiresAt: &yesterday})
+	seen, _ := repo.Create(&Bulletin{Title: "seen", PublishedAt: past, Art: "news"})
+
+	if err := repo.MarkSeen(1, seen); err != nil {
+		t.Fatal(err)
+	}
+	list, err := repo.Unseen(1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != current {
+		t.Fatalf("unseen = %+v, want only %d", list, current)
+	}
+
+	active, err := repo.Active(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 2 {
+		t.Fatalf("active = %d bulletins, want 2", len(active))
+	}
+	if b, _ := repo.Get(seen); b.Art != "news" || !b.Active(now) {
+		t.Fatalf("get = %+v", b)
+	}
+	if b, err := repo.GetByTitle("scheduled"); err != nil || b == nil || b.Title != "scheduled" {
+		t.Fatalf("get by title = %+v, %v", b, err)
+	}
+	if b, err := repo.GetByTitle("missing"); b != nil || err != nil {
+		t.Fatalf("get missing title = %+v, %v", b, err)
+	}
+}
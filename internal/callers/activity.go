@@ -0,0 +1,135 @@
+package callers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// Activity counts calls by day of week and hour of day, in local time.
+type Activity struct {
+	Counts [7][24]int // indexed by time.Weekday, then hour
+	Total  int
+	Since  time.Time
+}
+
+// Week lists weekdays in the order reports show them.
+var Week = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday,
+	time.Friday, time.Saturday, time.Sunday}
+
+// Activity aggregates the calls that started at or after since.
+func (r *Repo) Activity(since time.Time) (*Activity, error) {
+	rows, err := r.db.Query(`
+		SELECT connected_at FROM callers WHERE connected_at >= ?
+	`, since.UTC().Format(db.SQLiteTime))
+	if err != nil {
+		return nil, fmt.Errorf("call activity: %w", err)
+	}
+	defer rows.Close()
+
+	a := &Activity{Since: since}
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("call activity: %w", err)
+		}
+		t = t.Local()
+		a.Counts[t.Weekday()][t.Hour()]++
+		a.Total++
+	}
+	return a, rows.Err()
+}
+
+// Hours returns the calls per hour of day over all days.
+func (a *Activity) Hours() [24]int {
+	var hours [24]int
+	for _, day := range a.Counts {
+		for h, n := range day {
+			hours[h] += n
+		}
+	}
+	return hours
+}
+
+// Day returns the calls on a weekday.
+func (a *Activity) Day(d time.Weekday) int {
+	total := 0
+	for _, n := range a.Counts[d] {
+		total += n
+	}
+	return total
+}
+
+// RankedHours returns the hours of day ordered from busiest to quietest;
+// ties go to the earlier hour.
+func (a *Activity) RankedHours() []int {
+	hours := a.Hours()
+	ranked := make([]int, 24)
+	for h := range ranked {
+		ranked[h] = h
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return hours[ranked[i]] > hours[ranked[j]] })
+	return ranked
+}
+
+// heatShades are the cells of the text heatmap, from no calls to busiest.
+const heatShades = " .:-=+*#%@"
+
+// Lines renders a text heatmap (one row per weekday, one column per hour)
+// followed by the peak and quietest hours.
+func (a *Activity) Lines() []string {
+	peak := 0
+	for _, day := range a.Counts {
+		for _, n := range day {
+			peak = max(peak, n)
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("Calls since %s: %d", a.Since.Local().Format("2006-01-02"), a.Total),
+		"",
+		"     0     6     12    18",
+		"     |     |     |     |",
+	}
+	for _, d := range Week {
+		var b strings.Builder
+		b.WriteString(d.String()[:3] + "  ")
+		for _, n := range a.Counts[d] {
+			b.WriteByte(shade(n, peak))
+		}
+		fmt.Fprintf(&b, "  %d", a.Day(d))
+		lines = append(lines, b.String())
+	}
+	lines = append(lines, "", fmt.Sprintf("Scale: '%s' = 0 to %d calls per cell", heatShades, peak))
+	if a.Total == 0 {
+		return lines
+	}
+
+	hours := a.Hours()
+	ranked := a.RankedHours()
+	var busy, quiet []string
+	for _, h := range ranked[:3] {
+		if hours[h] > 0 {
+			busy = append(busy, fmt.Sprintf("%02d:00 (%d)", h, hours[h]))
+		}
+	}
+	for i := len(ranked) - 1; i >= len(ranked)-3; i-- {
+		quiet = append(quiet, fmt.Sprintf("%02d:00 (%d)", ranked[i], hours[ranked[i]]))
+	}
+	return append(lines,
+		"Peak hours:    "+strings.Join(busy, ", "),
+		"Quietest:      "+strings.Join(quiet, ", "))
+}
+
+// shade picks the heatmap character for n calls out of a busiest cell of
+// peak calls. Any call at all shows as at least the lightest mark.
+func shade(n, peak int) byte {
+	if n == 0 || peak == 0 {
+		return heatShades[0]
+	}
+	levels := len(heatShades) - 1
+	return heatShades[(n*levels+peak-1)/peak]
+}
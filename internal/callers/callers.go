@@ -0,0 +1,126 @@
+// Package callers keeps the callers log: one row per finished call with
+// what the caller did, written when the node's session ends.
+package callers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/event"
+)
+
+// Call is one finished session.
+type Call struct {
+	ID             int
+	UserID         int // 0 if the caller never logged in
+	Username       string
+	NodeID         int
+	Remote         string
+	ConnectedAt    time.Time
+	DisconnectedAt time.Time
+	BytesUp        int64
+	BytesDown      int64
+	TermSent       int64 // terminal bytes sent to the caller, transfers excluded
+	TermReceived   int64 // terminal bytes received from the caller
+	Posts          int
+	LastMenu       string
+}
+
+// Duration is how long the call lasted.
+func (c *Call) Duration() time.Duration {
+	return c.DisconnectedAt.Sub(c.ConnectedAt)
+}
+
+// Repo handles database operations for the callers log.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo creates a new callers log repository.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+// Record writes a finished call to the log and adds its time, posts and
+// transfer bytes to the user's lifetime counters.
+func (r *Repo) Record(c *Call) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("record call: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID interface{}
+	if c.UserID > 0 {
+		userID = c.UserID
+	}
+	secs := int64(c.Duration() / time.Second)
+	result, err := tx.Exec(`
+		INSERT INTO callers (user_id, username, node_id, remote, connected_at, disconnected_at,
+		                     seconds, bytes_up, bytes_down, term_sent, term_received, posts, last_menu)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, c.Username, c.NodeID, c.Remote,
+		c.ConnectedAt.UTC().Format(db.SQLiteTime), c.DisconnectedAt.UTC().Format(db.SQLiteTime),
+		secs, c.BytesUp, c.BytesDown, c.TermSent, c.TermReceived, c.Posts, c.LastMenu)
+	if err != nil {
+		return fmt.Errorf("record call: %w", err)
+	}
+
+	if c.UserID > 0 {
+		_, err = tx.Exec(`
+			UPDATE users SET time_used_secs = COALESCE(time_used_secs, 0) + ?,
+			                 total_posts = COALESCE(total_posts, 0) + ?,
+			                 bytes_uploaded = COALESCE(bytes_uploaded, 0) + ?,
+			                 bytes_downloaded = COALESCE(bytes_downloaded, 0) + ?
+			WHERE id = ?
+		`, secs, c.Posts, c.BytesUp, c.BytesDown, c.UserID)
+		if err != nil {
+			return fmt.Errorf("update counters for user %d: %w", c.UserID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record call: %w", err)
+	}
+	id, _ := result.LastInsertId()
+	c.ID = int(id)
+	return nil
+}
+
+// Recent returns the latest calls, newest first.
+func (r *Repo) Recent(limit int) ([]*Call, error) {
+	rows, err := r.db.Query(`
+		SELECT id, COALESCE(user_id, 0), username, node_id, remote, connected_at, disconnected_at,
+		       bytes_up, bytes_down, term_sent, term_received, posts, last_menu
+		FROM callers ORDER BY id DESC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list callers: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []*Call
+	for rows.Next() {
+		c := &Call{}
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Username, &c.NodeID, &c.Remote, &c.ConnectedAt,
+			&c.DisconnectedAt, &c.BytesUp, &c.BytesDown, &c.TermSent, &c.TermReceived, &c.Posts, &c.LastMenu); err != nil {
+			return nil, err
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
+
+// HandleLogoff is an event.Logoff subscriber that records the call.
+func (r *Repo) HandleLogoff(ev event.Event) {
+	c, ok := ev.Data.(*Call)
+	if !ok {
+		return
+	}
+	if err := r.Record(c); err != nil {
+		log.Printf("Node %d: %v", ev.NodeID, err)
+	}
+}
@@ -0,0 +1,86 @@
+package callers
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+func TestLogoffRecordsCallAndCounters(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'sysop', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepo(database.DB)
+	bus := event.NewBus()
+	bus.Subscribe(event.Logoff, repo.HandleLogoff)
+
+	start := time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC)
+	call := func(userID int) *Call {
+		return &Call{UserID: userID, Username: "sysop", NodeID: 2, Remote: "1.2.3.4:5",
+			ConnectedAt: start, DisconnectedAt: start.Add(90 * time.Second),
+			BytesUp: 10, BytesDown: 500, TermSent: 4096, TermReceived: 64, Posts: 2, LastMenu: "file_menu"}
+	}
+	bus.Publish(event.Event{Name: event.Logoff, NodeID: 2, Data: call(1)})
+	bus.Publish(event.Event{Name: event.Logoff, NodeID: 2, Data: call(1)})
+	bus.Publish(event.Event{Name: event.Logoff, NodeID: 3, Data: call(0)}) // never logged in
+
+	calls, err := repo.Recent(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || calls[0].UserID != 0 || calls[1].LastMenu != "file_menu" || calls[1].Duration() != 90*time.Second ||
+		calls[1].TermSent != 4096 || calls[1].TermReceived != 64 {
+		t.Fatalf("calls = %+v", calls)
+	}
+
+	u, err := user.NewRepo(database.DB).GetByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.TimeUsedSecs != 180 || u.TotalPosts != 4 || u.BytesUploaded != 20 || u.BytesDownloaded != 1000 {
+		t.Fatalf("counters = %+v", u)
+	}
+}
+
+func TestActivity(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+
+	// Three calls Tuesday 21:00, one Saturday 09:00, one too old to count.
+	tue := time.Date(2025, 3, 4, 21, 15, 0, 0, time.Local)
+	for _, at := range []time.Time{tue, tue.Add(time.Minute), tue.Add(7 * 24 * time.Hour),
+		time.Date(2025, 3, 8, 9, 0, 0, 0, time.Local), tue.Add(-30 * 24 * time.Hour)} {
+		if err := repo.Record(&Call{NodeID: 1, ConnectedAt: at, DisconnectedAt: at.Add(time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, err := repo.Activity(tue.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Total != 4 || a.Counts[time.Tuesday][21] != 3 || a.Counts[time.Saturday][9] != 1 || a.Day(time.Tuesday) != 3 {
+		t.Fatalf("activity = %+v", a)
+	}
+	if ranked := a.RankedHours(); ranked[0] != 21 || ranked[1] != 9 || ranked[2] != 0 {
+		t.Fatalf("ranked hours = %v", ranked[:3])
+	}
+	lines := a.Lines()
+	if lines[5] != "Tue                       @    3" || lines[13] != "Peak hours:    21:00 (3), 09:00 (1)" || lines[9] != "Sat           -                1" {
+		t.Fatalf("heatmap:\n%s", strings.Join(lines, "\n"))
+	}
+}
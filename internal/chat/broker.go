@@ -3,6 +3,7 @@ package chat
 import (
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 )
 
@@ -28,6 +29,13 @@ type Broker struct {
 	mu          sync.RWMutex
 	subscribers map[int]*Subscriber
 	online      map[int]*OnlineUser
+	notifiers   map[int]func(text string)
+	events      map[string]*Event
+	held        map[int][]string // notices a node's terminal would not take
+	pages       map[int]*Page    // by the paged node
+	private     map[string][2]int
+	nextRoom    int
+	history     *History // room lines kept by Say, nil = none
 }
 
 // NewBroker creates a new chat message broker.
@@ -35,14 +43,20 @@ func NewBroker() *Broker {
 	return &Broker{
 		subscribers: make(map[int]*Subscriber),
 		online:      make(map[int]*OnlineUser),
+		notifiers:   make(map[int]func(text string)),
+		events:      make(map[string]*Event),
+		held:        make(map[int][]string),
+		pages:       make(map[int]*Page),
+		private:     make(map[string][2]int),
 	}
 }
 
 // OnlineUser represents a connected user (regardless of chat participation).
 type OnlineUser struct {
-	NodeID   int
-	UserName string
-	Room     string
+	NodeID       int
+	UserName     string
+	Room         string
+	DoNotDisturb bool // refuses pages
 }
 
 // RegisterOnline marks a node as connected.
@@ -70,11 +84,76 @@ func (b *Broker) UpdateOnlineName(nodeID int, userName string) {
 	u.UserName = userName
 }
 
-// UnregisterOnline removes a node from the online list.
+// UnregisterOnline removes a node from the online list, withdraws the
+// pages to and from it and ends the live events it was hosting.
 func (b *Broker) UnregisterOnline(nodeID int) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
 	delete(b.online, nodeID)
+	delete(b.notifiers, nodeID)
+	delete(b.held, nodeID)
+	b.dropPages(nodeID)
+	for room, pair := range b.private {
+		if pair[0] == nodeID || pair[1] == nodeID {
+			b.closePrivate(room)
+		}
+	}
+	b.mu.Unlock()
+
+	b.closeHosted(nodeID)
+}
+
+// SetNotifier registers the function used to deliver out-of-band notices
+// (e.g. "you have new mail") to a node, whether or not it is in chat.
+func (b *Broker) SetNotifier(nodeID int, fn func(text string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.notifiers[nodeID] = fn
+}
+
+// NotifyUser delivers a notice to every node the named user is logged in
+// on. Returns the number of nodes notified.
+func (b *Broker) NotifyUser(userName, text string) int {
+	b.mu.RLock()
+	var fns []func(text string)
+	for id, u := range b.online {
+		if !strings.EqualFold(u.UserName, userName) {
+			continue
+		}
+		if fn, ok := b.notifiers[id]; ok {
+			fns = append(fns, fn)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, fn := range fns {
+		fn(text)
+	}
+	return len(fns)
+}
+
+// maxHeld is how many notices are held for one node; older ones are
+// dropped first.
+const maxHeld = 20
+
+// Hold keeps a notice for a node whose terminal could not take it, to be
+// shown once the node is responsive again (see TakeHeld).
+func (b *Broker) Hold(nodeID int, text string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	held := append(b.held[nodeID], text)
+	if len(held) > maxHeld {
+		held = held[len(held)-maxHeld:]
+	}
+	b.held[nodeID] = held
+}
+
+// TakeHeld returns the notices held for a node and forgets them.
+func (b *Broker) TakeHeld(nodeID int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	held := b.held[nodeID]
+	delete(b.held, nodeID)
+	return held
 }
 
 // Subscribe registers a node to receive chat messages.
@@ -211,13 +290,16 @@ func (b *Broker) JoinRoom(nodeID int, room string) {
 	}
 }
 
-// LeaveRoom removes a subscriber from their current room.
+// LeaveRoom removes a subscriber from their current room. A private room
+// is closed when the last of the two leaves.
 func (b *Broker) LeaveRoom(nodeID int) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	if sub, ok := b.subscribers[nodeID]; ok {
+		room := sub.Room
 		sub.Room = ""
+		b.closePrivate(room)
 	}
 	if u, ok := b.online[nodeID]; ok {
 		u.Room = ""
@@ -246,9 +328,10 @@ func (b *Broker) ListOnline() []OnlineUser {
 	var users []OnlineUser
 	for _, u := range b.online {
 		users = append(users, OnlineUser{
-			NodeID:   u.NodeID,
-			UserName: u.UserName,
-			Room:     u.Room,
+			NodeID:       u.NodeID,
+			UserName:     u.UserName,
+			Room:         u.Room,
+			DoNotDisturb: u.DoNotDisturb,
 		})
 	}
 	return users
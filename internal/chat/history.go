@@ -0,0 +1,131 @@
+package chat
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// HistoryLine is one chat line kept in a room's history.
+type HistoryLine struct {
+	Room string
+	From string
+	Text string
+	At   time.Time
+}
+
+// History keeps the recent lines of each public room in the database, so
+// callers joining a quiet room can see what was said. Private rooms are
+// never kept.
+type History struct {
+	db     *sql.DB
+	keep   int           // lines kept per room
+	maxAge time.Duration // lines older than this are pruned, 0 = kept
+}
+
+// NewHistory creates a history keeping the last keep lines of each room,
+// none older than maxAge when that is set.
+func NewHistory(db *sql.DB, keep int, maxAge time.Duration) *History {
+	return &History{db: db, keep: keep, maxAge: maxAge}
+}
+
+// Record adds a line to a room's history, dropping the room's oldest
+// lines beyond the limit. Lines for private rooms are ignored.
+func (h *History) Record(room, from, text string) error {
+	if IsPrivateRoom(room) || h.keep <= 0 {
+		return nil
+	}
+	tx, err := h.db.Begin()
+	if err != nil {
+		return fmt.Errorf("record chat line: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO chat_history (room, from_user, text, created_at) VALUES (?, ?, ?, ?)
+	`, room, from, text, time.Now()); err != nil {
+		return fmt.Errorf("record chat line: %w", err)
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM chat_history WHERE room = ? AND id <= (
+			SELECT id FROM chat_history WHERE room = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+		)
+	`, room, room, h.keep); err != nil {
+		return fmt.Errorf("record chat line: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Recent returns up to n of a room's latest lines, oldest first.
+func (h *History) Recent(room string, n int) ([]HistoryLine, error) {
+	n = min(n, h.keep)
+	if n <= 0 || IsPrivateRoom(room) {
+		return nil, nil
+	}
+	rows, err := h.db.Query(`
+		SELECT from_user, text, created_at FROM chat_history
+		WHERE room = ? ORDER BY id DESC LIMIT ?
+	`, room, n)
+	if err != nil {
+		return nil, fmt.Errorf("chat history: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []HistoryLine
+	for rows.Next() {
+		l := HistoryLine{Room: room}
+		if err := rows.Scan(&l.From, &l.Text, &l.At); err != nil {
+			return nil, fmt.Errorf("chat history: %w", err)
+		}
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("chat history: %w", err)
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines, nil
+}
+
+// Prune removes lines older than the history's maximum age and returns
+// how many went.
+func (h *History) Prune() (int, error) {
+	if h.maxAge <= 0 {
+		return 0, nil
+	}
+	res, err := h.db.Exec(`DELETE FROM chat_history WHERE created_at < ?`, time.Now().Add(-h.maxAge))
+	if err != nil {
+		return 0, fmt.Errorf("prune chat history: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// SetHistory makes the broker keep room lines sent with Say in h; nil
+// keeps none.
+func (b *Broker) SetHistory(h *History) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.history = h
+}
+
+// History returns the broker's room history, nil when none is kept.
+func (b *Broker) History() *History {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.history
+}
+
+// Say sends a line a user typed to a room, like SendToRoom, and keeps it
+// in the room's history. Notices such as joins and leaves go through
+// SendToRoom and are not kept.
+func (b *Broker) Say(fromNodeID int, fromUser, room, text string) {
+	b.SendToRoom(fromNodeID, fromUser, room, text)
+	if h := b.History(); h != nil {
+		if err := h.Record(room, fromUser, text); err != nil {
+			log.Printf("chat: %v", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestHistory(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	b := NewBroker()
+	b.Subscribe(1, "Alice")
+	b.JoinRoom(1, "main")
+	b.SetHistory(NewHistory(database.DB, 3, 24*time.Hour))
+
+	for i := 1; i <= 5; i++ {
+		b.Say(1, "Alice", "main", fmt.Sprintf("line %d", i))
+	}
+	b.SendToRoom(1, "Alice", "main", "*** Alice has left ***")
+	b.Say(1, "Alice", "private-1", "just between us")
+
+	lines, err := b.History().Recent("main", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range lines {
+		got = append(got, l.From+": "+l.Text)
+	}
+	if fmt.Sprint(got) != "[Alice: line 3 Alice: line 4 Alice: line 5]" {
+		t.Errorf("history = %q", got)
+	}
+	if lines, _ := b.History().Recent("main", 1); len(lines) != 1 || lines[0].Text != "line 5" {
+		t.Errorf("last line = %+v", lines)
+	}
+	var private int
+	database.QueryRow(`SELECT COUNT(*) FROM chat_history WHERE room LIKE 'private-%'`).Scan(&private)
+	if private != 0 {
+		t.Errorf("%d private lines kept", private)
+	}
+
+	database.Exec(`UPDATE chat_history SET created_at = ? WHERE text = 'line 3'`, time.Now().Add(-48*time.Hour))
+	if n, err := b.History().Prune(); n != 1 || err != nil {
+		t.Errorf("prune = %d, %v", n, err)
+	}
+}
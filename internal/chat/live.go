@@ -0,0 +1,377 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by live events.
+var (
+	ErrEventExists = errors.New("an event with that name is already running")
+	ErrEventOver   = errors.New("the event is over")
+	ErrNotJoined   = errors.New("not in the event")
+	ErrNoQuestion  = errors.New("no question is open")
+	ErrAnswered    = errors.New("already answered")
+)
+
+// Event is a live event such as a trivia night or an auction. A host node
+// runs a script that broadcasts screens to the nodes that joined, asks
+// questions and scores the answers. Broadcasts go through the broker to a
+// room named after the event, so players also show up in the online list.
+type Event struct {
+	Name     string
+	Title    string
+	HostNode int
+	Host     string
+	Started  time.Time
+
+	broker *Broker
+	done   chan struct{}
+	joined chan struct{} // signalled when a player joins
+
+	mu      sync.Mutex
+	closed  bool
+	players map[int]string
+	scores  map[string]int
+	ask     *question
+}
+
+// Player is a node taking part in an event.
+type Player struct {
+	NodeID   int
+	UserName string
+}
+
+// Answer is a player's reply to a question.
+type Answer struct {
+	NodeID   int
+	UserName string
+	Text     string
+	After    time.Duration // since the question was asked
+}
+
+// Score is a player's total.
+type Score struct {
+	UserName string
+	Points   int
+}
+
+type question struct {
+	once     bool
+	opened   time.Time
+	answered map[int]bool
+	answers  chan Answer
+}
+
+// maxPendingAnswers bounds the answers waiting for the host script.
+const maxPendingAnswers = 64
+
+// StartEvent opens an event hosted by hostNode. Names are case-insensitive;
+// an event of the same name left open by the same node is closed first.
+func (b *Broker) StartEvent(hostNode int, host, name, title string) (*Event, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return nil, fmt.Errorf("invalid event name %q", name)
+	}
+	if title == "" {
+		title = name
+	}
+	if old := b.Event(name); old != nil {
+		if old.HostNode != hostNode {
+			return nil, ErrEventExists
+		}
+		old.Close()
+	}
+
+	ev := &Event{
+		Name:     name,
+		Title:    title,
+		HostNode: hostNode,
+		Host:     host,
+		Started:  time.Now(),
+		broker:   b,
+		done:     make(chan struct{}),
+		joined:   make(chan struct{}, 1),
+		players:  make(map[int]string),
+		scores:   make(map[string]int),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.events[name]; ok {
+		return nil, ErrEventExists
+	}
+	b.events[name] = ev
+	return ev, nil
+}
+
+// Event returns the running event with the given name, or nil.
+func (b *Broker) Event(name string) *Event {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.events[strings.ToLower(name)]
+}
+
+// Events returns the running events by name.
+func (b *Broker) Events() []*Event {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	list := make([]*Event, 0, len(b.events))
+	for _, ev := range b.events {
+		list = append(list, ev)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Room is the broker room the players of the event are in.
+func (e *Event) Room() string {
+	return "event:" + e.Name
+}
+
+// Done is closed when the event ends.
+func (e *Event) Done() <-chan struct{} {
+	return e.done
+}
+
+// Close ends the event. Player sessions see Done and return to their menus.
+func (e *Event) Close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	close(e.done)
+	e.mu.Unlock()
+
+	e.broker.mu.Lock()
+	if e.broker.events[e.Name] == e {
+		delete(e.broker.events, e.Name)
+	}
+	e.broker.mu.Unlock()
+}
+
+// Join adds a player. Joining again from the same node is harmless.
+func (e *Event) Join(nodeID int, userName string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return ErrEventOver
+	}
+	e.players[nodeID] = userName
+	select {
+	case e.joined <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Leave removes a player. Their score is kept in case they come back.
+func (e *Event) Leave(nodeID int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.players, nodeID)
+}
+
+// Players returns the players by node number.
+func (e *Event) Players() []Player {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := make([]Player, 0, len(e.players))
+	for id, name := range e.players {
+		list = append(list, Player{NodeID: id, UserName: name})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NodeID < list[j].NodeID })
+	return list
+}
+
+// Wait blocks until at least n players have joined, the timeout passes or
+// the event ends, and returns the number of players.
+func (e *Event) Wait(n int, timeout time.Duration) int {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		e.mu.Lock()
+		count := len(e.players)
+		e.mu.Unlock()
+		if count >= n {
+			return count
+		}
+		select {
+		case <-e.joined:
+		case <-timer.C:
+			return count
+		case <-e.done:
+			return count
+		}
+	}
+}
+
+// Broadcast shows text to every player.
+func (e *Event) Broadcast(text string) {
+	e.broker.SendToRoom(e.HostNode, e.Host, e.Room(), text)
+}
+
+// Announce sends a notice to every online node that has not joined, such as
+// "Trivia starts in 5 minutes". Returns the number of nodes notified.
+func (e *Event) Announce(text string) int {
+	e.mu.Lock()
+	skip := make(map[int]bool, len(e.players)+1)
+	for id := range e.players {
+		skip[id] = true
+	}
+	e.mu.Unlock()
+	skip[e.HostNode] = true
+
+	e.broker.mu.RLock()
+	var fns []func(string)
+	for id, fn := range e.broker.notifiers {
+		if !skip[id] {
+			fns = append(fns, fn)
+		}
+	}
+	e.broker.mu.RUnlock()
+
+	for _, fn := range fns {
+		fn(text)
+	}
+	return len(fns)
+}
+
+// Ask broadcasts text, if not empty, and collects answers until the
+// timeout passes. With once, each player may answer once and Ask returns
+// early when everyone has. each, if not nil, is called on the caller's
+// goroutine as answers arrive; returning true stops the question, e.g. on
+// the first right answer or when an auction is won.
+func (e *Event) Ask(text string, timeout time.Duration, once bool, each func(Answer) bool) ([]Answer, error) {
+	q := &question{
+		once:     once,
+		opened:   time.Now(),
+		answered: make(map[int]bool),
+		answers:  make(chan Answer, maxPendingAnswers),
+	}
+	e.mu.Lock()
+	switch {
+	case e.closed:
+		e.mu.Unlock()
+		return nil, ErrEventOver
+	case e.ask != nil:
+		e.mu.Unlock()
+		return nil, errors.New("a question is already open")
+	}
+	e.ask = q
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.ask = nil
+		e.mu.Unlock()
+	}()
+
+	if text != "" {
+		e.Broadcast(text)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var got []Answer
+	for {
+		select {
+		case a := <-q.answers:
+			got = append(got, a)
+			if each != nil && each(a) {
+				return got, nil
+			}
+			if once && e.allAnswered(got) {
+				return got, nil
+			}
+		case <-timer.C:
+			return got, nil
+		case <-e.done:
+			return got, ErrEventOver
+		}
+	}
+}
+
+// allAnswered reports whether every player has an answer in got.
+func (e *Event) allAnswered(got []Answer) bool {
+	seen := make(map[int]bool, len(got))
+	for _, a := range got {
+		seen[a.NodeID] = true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id := range e.players {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// Answer submits a player's answer to the open question.
+func (e *Event) Answer(nodeID int, text string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return ErrEventOver
+	}
+	userName, ok := e.players[nodeID]
+	if !ok {
+		return ErrNotJoined
+	}
+	q := e.ask
+	if q == nil {
+		return ErrNoQuestion
+	}
+	if q.once && q.answered[nodeID] {
+		return ErrAnswered
+	}
+	a := Answer{NodeID: nodeID, UserName: userName, Text: text, After: time.Since(q.opened)}
+	select {
+	case q.answers <- a:
+		q.answered[nodeID] = true
+		return nil
+	default:
+		return errors.New("too many answers at once, try again")
+	}
+}
+
+// AddScore adds points, which may be negative, to a player's score and
+// returns the new total. Scores are kept by user name.
+func (e *Event) AddScore(userName string, points int) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scores[userName] += points
+	return e.scores[userName]
+}
+
+// Scores returns the scores, highest first.
+func (e *Event) Scores() []Score {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := make([]Score, 0, len(e.scores))
+	for name, points := range e.scores {
+		list = append(list, Score{UserName: name, Points: points})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Points != list[j].Points {
+			return list[i].Points > list[j].Points
+		}
+		return list[i].UserName < list[j].UserName
+	})
+	return list
+}
+
+// closeHosted ends the events hosted by nodeID, when the host disconnects.
+func (b *Broker) closeHosted(nodeID int) {
+	for _, ev := range b.Events() {
+		if ev.HostNode == nodeID {
+			ev.Close()
+		}
+	}
+}
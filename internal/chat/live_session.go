@@ -0,0 +1,147 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/notepid/twilight_bbs/internal/slash"
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// EventSessionConfig configures a player's session in a live event.
+type EventSessionConfig struct {
+	Term     *terminal.Terminal
+	Broker   *Broker
+	Event    *Event
+	NodeID   int
+	UserName string
+	Level    int
+}
+
+// eventCommands are the slash commands of a live event.
+var eventCommands = slash.New(
+	slash.Command{Name: "quit", Aliases: []string{"q"}, Help: "Leave the event"},
+	slash.Command{Name: "who", Help: "List the players"},
+	slash.Command{Name: "scores", Help: "Show the scores"},
+	slash.Command{Name: "help", Aliases: []string{"?"}, Help: "List these commands"},
+)
+
+// RunEventSession joins a live event and shows what the host broadcasts.
+// Lines typed while a question is open are the player's answers. It
+// returns when the player types /quit or the event ends.
+func RunEventSession(cfg EventSessionConfig) error {
+	ev, term := cfg.Event, cfg.Term
+	if ev == nil || term == nil || cfg.Broker == nil {
+		return nil
+	}
+	if err := ev.Join(cfg.NodeID, cfg.UserName); err != nil {
+		return err
+	}
+	sub := cfg.Broker.Subscribe(cfg.NodeID, cfg.UserName)
+	cfg.Broker.JoinRoom(cfg.NodeID, ev.Room())
+
+	_ = term.Cls()
+	_ = term.SendLn("  " + ev.Title)
+	_ = term.SendLn("  Hosted by " + ev.Host + ". Type your answers; /quit leaves, /help for commands")
+	_ = term.SendLn("  ---------------------------------------------")
+	_ = term.SendLn("")
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() { recover() }()
+		for {
+			select {
+			case msg := <-sub.Ch:
+				text := strings.ReplaceAll(msg.Text, "\n", "\r\n")
+				if msg.Room != ev.Room() {
+					text = fmt.Sprintf("<%s> %s", msg.FromUser, text)
+				}
+				_ = term.SendLn("\r" + text)
+			case <-ev.Done():
+				_ = term.SendLn("\r\n  *** The event is over. ***")
+				select {
+				case <-stop:
+				default:
+					// Wake the line read below so the session ends now.
+					term.Inject([]byte{'\r'})
+				}
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		close(stop)
+		wg.Wait()
+		cfg.Broker.LeaveRoom(cfg.NodeID)
+		cfg.Broker.Unsubscribe(cfg.NodeID)
+		ev.Leave(cfg.NodeID)
+	}()
+
+	who := slash.Who{Level: cfg.Level, Node: cfg.NodeID}
+	for {
+		line, err := term.GetLine(200)
+		if err != nil {
+			return nil
+		}
+		select {
+		case <-ev.Done():
+			return nil
+		default:
+		}
+		line = strings.TrimSpace(line)
+
+		call, err := eventCommands.Parse(line, who)
+		if err != nil {
+			_ = term.SendLn(fmt.Sprintf("  %v; /help lists commands", err))
+			continue
+		}
+		if call != nil {
+			switch call.Command.Name {
+			case "quit":
+				return nil
+			case "who":
+				var names []string
+				for _, p := range ev.Players() {
+					names = append(names, p.UserName)
+				}
+				_ = term.SendLn("  Players: " + strings.Join(names, ", "))
+			case "scores":
+				scores := ev.Scores()
+				if len(scores) == 0 {
+					_ = term.SendLn("  No scores yet.")
+				}
+				for i, s := range scores {
+					_ = term.SendLn(fmt.Sprintf("  %2d. %-20s %d", i+1, s.UserName, s.Points))
+				}
+			case "help":
+				for _, h := range eventCommands.Help(who) {
+					_ = term.SendLn("  " + h)
+				}
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		switch err := ev.Answer(cfg.NodeID, line); {
+		case err == nil:
+		case errors.Is(err, ErrNoQuestion):
+			_ = term.SendLn("  No question is open; wait for the host.")
+		case errors.Is(err, ErrAnswered):
+			_ = term.SendLn("  You have already answered this one.")
+		case errors.Is(err, ErrEventOver):
+			return nil
+		default:
+			_ = term.SendLn("  " + err.Error())
+		}
+	}
+}
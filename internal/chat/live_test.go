@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEventAsk(t *testing.T) {
+	b := NewBroker()
+	ev, err := b.StartEvent(1, "Sysop", "Trivia", "Trivia Night")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.StartEvent(2, "Other", "trivia", ""); !errors.Is(err, ErrEventExists) {
+		t.Fatalf("second host: got %v", err)
+	}
+	if b.Event("TRIVIA") != ev {
+		t.Fatal("event not found by name")
+	}
+
+	sub := b.Subscribe(2, "Alice")
+	b.JoinRoom(2, ev.Room())
+	ev.Join(2, "Alice")
+	ev.Join(3, "Bob")
+	if err := ev.Answer(2, "early"); !errors.Is(err, ErrNoQuestion) {
+		t.Fatalf("answer with no question: got %v", err)
+	}
+
+	done := make(chan []Answer)
+	go func() {
+		answers, _ := ev.Ask("2+2?", time.Minute, true, nil)
+		done <- answers
+	}()
+	if msg := <-sub.Ch; msg.Text != "2+2?" || msg.Room != ev.Room() {
+		t.Fatalf("player got %+v", msg)
+	}
+	if err := ev.Answer(2, "4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ev.Answer(2, "5"); !errors.Is(err, ErrAnswered) {
+		t.Fatalf("second answer: got %v", err)
+	}
+	if err := ev.Answer(4, "4"); !errors.Is(err, ErrNotJoined) {
+		t.Fatalf("outsider answer: got %v", err)
+	}
+	if err := ev.Answer(3, "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Everyone answered, so Ask returns without waiting out the minute.
+	select {
+	case answers := <-done:
+		if len(answers) != 2 || answers[0].UserName != "Alice" || answers[1].Text != "3" {
+			t.Fatalf("answers %+v", answers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Ask did not return when all players answered")
+	}
+
+	ev.AddScore("Bob", 1)
+	ev.AddScore("Alice", 3)
+	if s := ev.Scores(); len(s) != 2 || s[0].UserName != "Alice" || s[0].Points != 3 {
+		t.Fatalf("scores %+v", s)
+	}
+}
+
+func TestEventAskStopsEarly(t *testing.T) {
+	b := NewBroker()
+	ev, _ := b.StartEvent(1, "Sysop", "auction", "")
+	ev.Join(2, "Alice")
+
+	go func() {
+		for ev.Answer(2, "100") != nil {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	answers, err := ev.Ask("", time.Minute, false, func(a Answer) bool { return a.Text == "100" })
+	if err != nil || len(answers) != 1 {
+		t.Fatalf("got %v, %v", answers, err)
+	}
+}
+
+func TestEventClosedWithHost(t *testing.T) {
+	b := NewBroker()
+	b.RegisterOnline(1, "Sysop")
+	ev, _ := b.StartEvent(1, "Sysop", "trivia", "")
+	ev.Join(2, "Alice")
+
+	b.UnregisterOnline(1)
+	select {
+	case <-ev.Done():
+	default:
+		t.Fatal("event still open after its host left")
+	}
+	if b.Event("trivia") != nil || len(b.Events()) != 0 {
+		t.Fatal("closed event still listed")
+	}
+	if err := ev.Answer(2, "x"); !errors.Is(err, ErrEventOver) {
+		t.Fatalf("answer after close: got %v", err)
+	}
+	if _, err := ev.Ask("late", time.Second, true, nil); !errors.Is(err, ErrEventOver) {
+		t.Fatalf("ask after close: got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Errors returned by pages.
+var (
+	ErrNotOnline     = errors.New("nobody is logged in on that node")
+	ErrPageSelf      = errors.New("you cannot page yourself")
+	ErrDoNotDisturb  = errors.New("that user does not want to be disturbed")
+	ErrAlreadyPaged  = errors.New("that user is already being paged")
+	ErrNoPage        = errors.New("nobody is paging you")
+	ErrPagerGone     = errors.New("the user who paged you has logged off")
+	ErrPrivateRoom   = errors.New("that room is private")
+	ErrInPrivateChat = errors.New("that user is in a private chat")
+)
+
+// LoggingIn is the name a node has in the online list until its caller
+// logs in.
+const LoggingIn = "(logging in)"
+
+// privatePrefix starts the names of private rooms.
+const privatePrefix = "private-"
+
+// IsPrivateRoom reports whether room is a two-person room made by a page.
+func IsPrivateRoom(room string) bool {
+	return strings.HasPrefix(room, privatePrefix)
+}
+
+// Page is one user's request to chat privately with another.
+type Page struct {
+	FromNode int
+	FromUser string
+	ToNode   int
+	ToUser   string
+	At       time.Time
+
+	// Room is the private room both go to when the page is accepted.
+	Room string
+
+	answer chan bool
+}
+
+// Answered delivers true when the page is accepted and false when it is
+// declined.
+func (p *Page) Answered() <-chan bool {
+	return p.answer
+}
+
+// SetDoNotDisturb sets whether the user on a node refuses pages.
+func (b *Broker) SetDoNotDisturb(nodeID int, on bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if u, ok := b.online[nodeID]; ok {
+		u.DoNotDisturb = on
+	}
+}
+
+// Page asks the user on toNode to chat privately with fromUser. They are
+// told at once, between screens if they are busy; the returned page
+// delivers their answer. A user has one page waiting at a time, and users
+// who set do not disturb are never paged.
+func (b *Broker) Page(fromNode int, fromUser string, toNode int) (*Page, error) {
+	if fromNode == toNode {
+		return nil, ErrPageSelf
+	}
+	b.mu.Lock()
+	u, ok := b.online[toNode]
+	switch {
+	case !ok || u.UserName == LoggingIn:
+		b.mu.Unlock()
+		return nil, ErrNotOnline
+	case u.DoNotDisturb:
+		b.mu.Unlock()
+		return nil, ErrDoNotDisturb
+	case b.pages[toNode] != nil:
+		b.mu.Unlock()
+		return nil, ErrAlreadyPaged
+	case IsPrivateRoom(u.Room):
+		b.mu.Unlock()
+		return nil, ErrInPrivateChat
+	}
+	b.nextRoom++
+	p := &Page{
+		FromNode: fromNode,
+		FromUser: fromUser,
+		ToNode:   toNode,
+		ToUser:   u.UserName,
+		At:       time.Now(),
+		Room:     fmt.Sprintf("%s%d", privatePrefix, b.nextRoom),
+		answer:   make(chan bool, 1),
+	}
+	b.pages[toNode] = p
+	notify := b.notifiers[toNode]
+	b.mu.Unlock()
+
+	if notify != nil {
+		notify(fmt.Sprintf("%s on node %d pages you for a private chat. Type /page to answer.", fromUser, fromNode))
+	}
+	return p, nil
+}
+
+// PageFor returns the page waiting for the user on a node, or nil.
+func (b *Broker) PageFor(nodeID int) *Page {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.pages[nodeID]
+}
+
+// Answer accepts or declines the page waiting for a node. An accepted
+// page opens its private room to the two nodes.
+func (b *Broker) Answer(nodeID int, accept bool) (*Page, error) {
+	b.mu.Lock()
+	p := b.pages[nodeID]
+	if p == nil {
+		b.mu.Unlock()
+		return nil, ErrNoPage
+	}
+	delete(b.pages, nodeID)
+	if _, ok := b.online[p.FromNode]; !ok {
+		b.mu.Unlock()
+		return nil, ErrPagerGone
+	}
+	if accept {
+		b.private[p.Room] = [2]int{p.FromNode, p.ToNode}
+	}
+	b.mu.Unlock()
+
+	p.answer <- accept
+	return p, nil
+}
+
+// CancelPage withdraws a page that has not been answered.
+func (b *Broker) CancelPage(p *Page) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pages[p.ToNode] == p {
+		delete(b.pages, p.ToNode)
+	}
+}
+
+// MayEnter reports whether a node may join room: any node for public
+// rooms, only the two nodes of an accepted page for private ones.
+func (b *Broker) MayEnter(nodeID int, room string) bool {
+	if !IsPrivateRoom(room) {
+		return true
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	pair, ok := b.private[room]
+	return ok && (pair[0] == nodeID || pair[1] == nodeID)
+}
+
+// dropPages forgets the pages to and from a node. b.mu must be held.
+func (b *Broker) dropPages(nodeID int) {
+	delete(b.pages, nodeID)
+	for to, p := range b.pages {
+		if p.FromNode == nodeID {
+			delete(b.pages, to)
+		}
+	}
+}
+
+// closePrivate forgets a private room once nobody is in it. b.mu must be
+// held.
+func (b *Broker) closePrivate(room string) {
+	if !IsPrivateRoom(room) {
+		return
+	}
+	for _, sub := range b.subscribers {
+		if sub.Room == room {
+			return
+		}
+	}
+	delete(b.private, room)
+}
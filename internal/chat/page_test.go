@@ -0,0 +1,110 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	b := NewBroker()
+	b.RegisterOnline(1, "Alice")
+	b.RegisterOnline(2, "Bob")
+	b.RegisterOnline(3, LoggingIn)
+	var notices []string
+	b.SetNotifier(2, func(text string) { notices = append(notices, text) })
+
+	for _, tc := range []struct {
+		to   int
+		want error
+	}{
+		{1, ErrPageSelf},
+		{3, ErrNotOnline},
+		{9, ErrNotOnline},
+	} {
+		if _, err := b.Page(1, "Alice", tc.to); !errors.Is(err, tc.want) {
+			t.Errorf("page node %d: err = %v, want %v", tc.to, err, tc.want)
+		}
+	}
+
+	b.SetDoNotDisturb(2, true)
+	if _, err := b.Page(1, "Alice", 2); !errors.Is(err, ErrDoNotDisturb) {
+		t.Fatalf("page with do not disturb: err = %v", err)
+	}
+	b.SetDoNotDisturb(2, false)
+
+	p, err := b.Page(1, "Alice", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 1 || !strings.Contains(notices[0], "Alice on node 1 pages you") {
+		t.Errorf("notices = %q", notices)
+	}
+	if _, err := b.Page(3, "Carol", 2); !errors.Is(err, ErrAlreadyPaged) {
+		t.Errorf("second page: err = %v", err)
+	}
+	if b.PageFor(2) != p {
+		t.Fatal("page not waiting for node 2")
+	}
+	if b.MayEnter(2, p.Room) {
+		t.Error("private room open before the page was accepted")
+	}
+
+	if _, err := b.Answer(2, true); err != nil {
+		t.Fatal(err)
+	}
+	if !<-p.Answered() {
+		t.Error("page answered false")
+	}
+	for id, want := range map[int]bool{1: true, 2: true, 3: false} {
+		if got := b.MayEnter(id, p.Room); got != want {
+			t.Errorf("MayEnter(%d) = %v, want %v", id, got, want)
+		}
+	}
+	if _, err := b.Answer(2, true); !errors.Is(err, ErrNoPage) {
+		t.Errorf("answer twice: err = %v", err)
+	}
+
+	// The room closes when the last of the two leaves.
+	b.Subscribe(1, "Alice")
+	b.Subscribe(2, "Bob")
+	b.JoinRoom(1, p.Room)
+	b.JoinRoom(2, p.Room)
+	if _, err := b.Page(3, "Carol", 2); !errors.Is(err, ErrInPrivateChat) {
+		t.Errorf("page into private chat: err = %v", err)
+	}
+	b.LeaveRoom(1)
+	if !b.MayEnter(1, p.Room) {
+		t.Error("room closed with one user still in it")
+	}
+	b.LeaveRoom(2)
+	if b.MayEnter(1, p.Room) {
+		t.Error("room still open after both left")
+	}
+}
+
+func TestPageWithdrawn(t *testing.T) {
+	b := NewBroker()
+	b.RegisterOnline(1, "Alice")
+	b.RegisterOnline(2, "Bob")
+
+	p, err := b.Page(1, "Alice", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.CancelPage(p)
+	if b.PageFor(2) != nil {
+		t.Error("cancelled page still waiting")
+	}
+
+	if _, err := b.Page(1, "Alice", 2); err != nil {
+		t.Fatal(err)
+	}
+	b.UnregisterOnline(1)
+	if b.PageFor(2) != nil {
+		t.Error("page from a node that left still waiting")
+	}
+	if _, err := b.Answer(2, true); !errors.Is(err, ErrNoPage) {
+		t.Errorf("answer after pager left: err = %v", err)
+	}
+}
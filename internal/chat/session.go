@@ -2,10 +2,15 @@ package chat
 
 import (
 	"fmt"
+	"log"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/slash"
 	"github.com/notepid/twilight_bbs/internal/terminal"
 )
 
@@ -15,6 +20,7 @@ type RoomSessionConfig struct {
 	Broker   *Broker
 	NodeID   int
 	UserName string
+	Level    int // security level, for room commands with an ACS
 	Room     string
 
 	// Template is an optional display file (e.g. assets/menus/chat_room.asc)
@@ -23,8 +29,74 @@ type RoomSessionConfig struct {
 	// If nil (or if ANSI is disabled), the session falls back to the classic
 	// sequential chat output.
 	Template *ansi.DisplayFile
+
+	// Flood, when set, is asked before each line is sent. A refused line
+	// is dropped and the user is told how long to wait.
+	Flood func() (retry time.Duration, ok bool)
+
+	// History is how many of the room's recent lines are shown on
+	// joining, when the broker keeps history; 0 = none.
+	History int
+}
+
+// defaultHistoryLines is how many lines /history shows without a count.
+const defaultHistoryLines = 20
+
+// recentLines returns up to n of the room's kept lines, formatted for the
+// chat log, or a notice when there are none.
+func (cfg RoomSessionConfig) recentLines(n int) []string {
+	h := cfg.Broker.History()
+	if h == nil {
+		return []string{"*** This room keeps no history ***"}
+	}
+	lines, err := h.Recent(cfg.Room, n)
+	if err != nil {
+		log.Printf("chat: %v", err)
+		return nil
+	}
+	if len(lines) == 0 {
+		return []string{"*** Nothing has been said here lately ***"}
+	}
+	out := make([]string, 0, len(lines)+2)
+	out = append(out, "*** Recent messages ***")
+	for _, l := range lines {
+		out = append(out, fmt.Sprintf("[%s] <%s> %s", l.At.Format("15:04"), l.From, l.Text))
+	}
+	return append(out, "*** End of history ***")
+}
+
+// historyCount is the number of lines asked for with /history.
+func historyCount(call *slash.Call) int {
+	if len(call.Args) > 0 {
+		if n, err := strconv.Atoi(call.Args[0]); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultHistoryLines
+}
+
+// flooded reports whether flood control refuses a line, with the notice
+// to show the user.
+func (cfg RoomSessionConfig) flooded() (string, bool) {
+	if cfg.Flood == nil {
+		return "", false
+	}
+	retry, ok := cfg.Flood()
+	if ok {
+		return "", false
+	}
+	secs := int(math.Ceil(retry.Seconds()))
+	return fmt.Sprintf("*** Slow down, try again in %ds ***", secs), true
 }
 
+// roomCommands are the slash commands of a chat room.
+var roomCommands = slash.New(
+	slash.Command{Name: "quit", Aliases: []string{"q"}, Help: "Leave the room"},
+	slash.Command{Name: "who", Help: "List the users in the room"},
+	slash.Command{Name: "history", Usage: "[lines]", Help: "Show what was said lately"},
+	slash.Command{Name: "help", Aliases: []string{"?"}, Help: "List these commands"},
+)
+
 // RunRoomSession runs a simple interactive chat session against the Broker.
 // It handles subscribing, joining/leaving the room, and displaying incoming/outgoing messages.
 func RunRoomSession(cfg RoomSessionConfig) error {
@@ -67,7 +139,10 @@ func RunRoomSession(cfg RoomSessionConfig) error {
 	_ = cfg.Term.Cls()
 	_ = ansi.Display(cfg.Term, cfg.Template)
 	ui.outputField("ROOM", room)
-	ui.outputField("STATUS", "Type /quit to leave, /who to list users")
+	ui.outputField("STATUS", "Type /quit to leave, /help for commands")
+	if cfg.History > 0 && broker.History() != nil {
+		ui.appendLogLines(cfg.recentLines(cfg.History))
+	}
 	ui.appendSystem(fmt.Sprintf("*** Joined room: %s ***", room))
 
 	done := make(chan struct{})
@@ -96,6 +171,8 @@ func RunRoomSession(cfg RoomSessionConfig) error {
 	}
 	defer cleanup()
 
+	who := slash.Who{Level: cfg.Level, Node: nodeID}
+input:
 	for {
 		line, err := ui.readInputLine()
 		if err != nil {
@@ -103,21 +180,35 @@ func RunRoomSession(cfg RoomSessionConfig) error {
 		}
 
 		line = strings.TrimSpace(line)
-		if line == "/quit" || line == "/q" {
-			broker.SendToRoom(nodeID, userName, room,
-				fmt.Sprintf("*** %s has left ***", userName))
-			break
+		call, err := roomCommands.Parse(line, who)
+		if err != nil {
+			ui.appendSystem(fmt.Sprintf("*** %v; /help lists commands ***", err))
+			continue
 		}
-
-		if line == "/who" {
-			members := broker.RoomMembers(room)
-			ui.appendSystem(fmt.Sprintf("*** Users in room: %s ***", strings.Join(members, ", ")))
+		if call != nil {
+			switch call.Command.Name {
+			case "quit":
+				broker.SendToRoom(nodeID, userName, room,
+					fmt.Sprintf("*** %s has left ***", userName))
+				break input
+			case "who":
+				members := broker.RoomMembers(room)
+				ui.appendSystem(fmt.Sprintf("*** Users in room: %s ***", strings.Join(members, ", ")))
+			case "history":
+				ui.appendLogLines(cfg.recentLines(historyCount(call)))
+			case "help":
+				ui.appendSystem(strings.Join(roomCommands.Help(who), "\n"))
+			}
 			continue
 		}
 
 		if line != "" {
+			if notice, refused := cfg.flooded(); refused {
+				ui.appendSystem(notice)
+				continue
+			}
 			// Send to room.
-			broker.SendToRoom(nodeID, userName, room, line)
+			broker.Say(nodeID, userName, room, line)
 			// Echo locally.
 			ui.appendMessage(userName, line)
 		}
@@ -143,9 +234,14 @@ func runSimpleRoomSession(cfg RoomSessionConfig) error {
 
 	_ = cfg.Term.Cls()
 	_ = cfg.Term.SendLn("  Chat Room: " + room)
-	_ = cfg.Term.SendLn("  Type /quit to leave, /who to see users")
+	_ = cfg.Term.SendLn("  Type /quit to leave, /help for commands")
 	_ = cfg.Term.SendLn("  ---------------------------------------------")
 	_ = cfg.Term.SendLn("")
+	if cfg.History > 0 && broker.History() != nil {
+		for _, l := range cfg.recentLines(cfg.History) {
+			_ = cfg.Term.SendLn(l)
+		}
+	}
 
 	done := make(chan struct{})
 	go func() {
@@ -173,6 +269,8 @@ func runSimpleRoomSession(cfg RoomSessionConfig) error {
 	}
 	defer cleanup()
 
+	who := slash.Who{Level: cfg.Level, Node: nodeID}
+input:
 	for {
 		line, err := cfg.Term.GetLine(200)
 		if err != nil {
@@ -180,20 +278,38 @@ func runSimpleRoomSession(cfg RoomSessionConfig) error {
 		}
 		line = strings.TrimSpace(line)
 
-		if line == "/quit" || line == "/q" {
-			broker.SendToRoom(nodeID, userName, room,
-				fmt.Sprintf("*** %s has left ***", userName))
-			break
+		call, err := roomCommands.Parse(line, who)
+		if err != nil {
+			_ = cfg.Term.SendLn(fmt.Sprintf("  %v; /help lists commands", err))
+			continue
 		}
-
-		if line == "/who" {
-			members := broker.RoomMembers(room)
-			_ = cfg.Term.SendLn("  Users in room: " + fmt.Sprintf("%v", members))
+		if call != nil {
+			switch call.Command.Name {
+			case "quit":
+				broker.SendToRoom(nodeID, userName, room,
+					fmt.Sprintf("*** %s has left ***", userName))
+				break input
+			case "who":
+				members := broker.RoomMembers(room)
+				_ = cfg.Term.SendLn("  Users in room: " + fmt.Sprintf("%v", members))
+			case "history":
+				for _, l := range cfg.recentLines(historyCount(call)) {
+					_ = cfg.Term.SendLn(l)
+				}
+			case "help":
+				for _, h := range roomCommands.Help(who) {
+					_ = cfg.Term.SendLn("  " + h)
+				}
+			}
 			continue
 		}
 
 		if line != "" {
-			broker.SendToRoom(nodeID, userName, room, line)
+			if notice, refused := cfg.flooded(); refused {
+				_ = cfg.Term.SendLn("  " + notice)
+				continue
+			}
+			broker.Say(nodeID, userName, room, line)
 			_ = cfg.Term.SendLn(fmt.Sprintf("<%s> %s", userName, line))
 		}
 	}
@@ -205,6 +321,7 @@ func runSimpleRoomSession(cfg RoomSessionConfig) error {
 
 type templatedRoomUI struct {
 	term   *terminal.Terminal
+	screen *terminal.Screen // the template's fields, redrawn without flicker
 	fields map[string]ansi.Field
 
 	logWidth  int
@@ -214,7 +331,7 @@ type templatedRoomUI struct {
 	logs []string
 
 	// input holds the current user-typed buffer (for redraw during async output).
-	input []byte
+	input []rune
 }
 
 func newTemplatedRoomUI(term *terminal.Terminal, df *ansi.DisplayFile) (*templatedRoomUI, bool) {
@@ -241,8 +358,16 @@ func newTemplatedRoomUI(term *terminal.Terminal, df *ansi.DisplayFile) (*templat
 		return nil, false
 	}
 
+	// The screen buffer spans every field; cells outside them keep the art.
+	rows, cols := 0, 0
+	for _, f := range fields {
+		rows = max(rows, f.Row+max(f.Height, 1)-1)
+		cols = max(cols, f.Col+max(f.MaxLen, 1)-1)
+	}
+
 	return &templatedRoomUI{
 		term:      term,
+		screen:    terminal.NewScreen(term, rows, cols),
 		fields:    fields,
 		logWidth:  logF.MaxLen,
 		logHeight: logF.Height,
@@ -268,23 +393,11 @@ func (ui *templatedRoomUI) outputField(id, text string) {
 		height = 1
 	}
 
-	// Clear rectangle first.
-	for row := 0; row < height; row++ {
-		_ = ui.term.GotoXY(f.Row+row, f.Col)
-		_ = ui.term.Send(strings.Repeat(" ", width))
-	}
-
 	// Print text into the rectangle (no wrapping, clip by width/height).
-	lines := strings.Split(text, "\n")
-	for i := 0; i < height && i < len(lines); i++ {
-		line := strings.TrimRight(lines[i], "\r")
-		r := []rune(line)
-		if len(r) > width {
-			line = string(r[:width])
-		}
-		_ = ui.term.GotoXY(f.Row+i, f.Col)
-		_ = ui.term.Send(line)
-	}
+	_ = ui.screen.Update(func(l *terminal.Locked) {
+		l.PrintBox(f.Row, f.Col, height, width, text, terminal.DefaultAttr)
+		ui.drawInputLocked(l)
+	})
 }
 
 func (ui *templatedRoomUI) appendSystem(text string) {
@@ -310,51 +423,37 @@ func (ui *templatedRoomUI) appendLogLines(lines []string) {
 		}
 	}
 
-	ui.redrawLogLocked()
-	ui.redrawInputLocked()
+	// Only the cells that changed are sent, so the log scrolls without
+	// being cleared first.
+	_ = ui.screen.Update(func(l *terminal.Locked) {
+		logF := ui.fields["CHAT_LOG"]
+		l.PrintBox(logF.Row, logF.Col, ui.logHeight, ui.logWidth, strings.Join(ui.logs, "\n"), terminal.DefaultAttr)
+		ui.drawInputLocked(l)
+	})
 }
 
-func (ui *templatedRoomUI) redrawLogLocked() {
-	logF, ok := ui.fields["CHAT_LOG"]
-	if !ok {
-		return
-	}
-
-	// Clear log rectangle.
-	for row := 0; row < ui.logHeight; row++ {
-		_ = ui.term.GotoXY(logF.Row+row, logF.Col)
-		_ = ui.term.Send(strings.Repeat(" ", ui.logWidth))
-	}
-
-	// Print buffered lines.
-	for i := 0; i < ui.logHeight && i < len(ui.logs); i++ {
-		line := ui.logs[i]
-		r := []rune(line)
-		if len(r) > ui.logWidth {
-			line = string(r[:ui.logWidth])
-		}
-		_ = ui.term.GotoXY(logF.Row+i, logF.Col)
-		_ = ui.term.Send(line)
-	}
-}
-
-func (ui *templatedRoomUI) redrawInputLocked() {
+// drawInputLocked draws the input field and leaves the cursor after the
+// text typed so far. The caller holds ui.mu.
+func (ui *templatedRoomUI) drawInputLocked(l *terminal.Locked) {
 	inputF, ok := ui.fields["INPUT"]
 	if !ok || inputF.MaxLen <= 0 {
 		return
 	}
 
-	_ = ui.term.GotoXY(inputF.Row, inputF.Col)
-	_ = ui.term.Send(strings.Repeat(" ", inputF.MaxLen))
-	_ = ui.term.GotoXY(inputF.Row, inputF.Col)
-	if len(ui.input) > 0 {
-		// Clip to field width.
-		buf := ui.input
-		if len(buf) > inputF.MaxLen {
-			buf = buf[len(buf)-inputF.MaxLen:]
-		}
-		_ = ui.term.Send(string(buf))
+	// Clip to field width.
+	buf := ui.input
+	if len(buf) > inputF.MaxLen {
+		buf = buf[len(buf)-inputF.MaxLen:]
 	}
+	n := l.Print(inputF.Row, inputF.Col, inputF.MaxLen, string(buf), terminal.DefaultAttr)
+	l.SetCursor(inputF.Row, inputF.Col+min(n, inputF.MaxLen-1))
+}
+
+// redrawInput updates the input field after the buffer changed.
+func (ui *templatedRoomUI) redrawInput() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	_ = ui.screen.Update(ui.drawInputLocked)
 }
 
 func (ui *templatedRoomUI) readInputLine() (string, error) {
@@ -365,31 +464,29 @@ func (ui *templatedRoomUI) readInputLine() (string, error) {
 
 	ui.mu.Lock()
 	ui.input = ui.input[:0]
-	_ = ui.term.GotoXY(inputF.Row, inputF.Col)
-	_ = ui.term.Send(strings.Repeat(" ", inputF.MaxLen))
-	_ = ui.term.GotoXY(inputF.Row, inputF.Col)
 	ui.mu.Unlock()
+	ui.redrawInput()
 
 	// Read input in-place (no CRLF emission), while allowing async log redraws.
-	// All terminal writes are synchronized on ui.mu to avoid interleaved output.
-	var buf []byte
+	// All terminal writes go through ui.screen to avoid interleaved output.
+	var buf []rune
 	maxLen := inputF.MaxLen
 	if maxLen <= 0 {
 		maxLen = 200
 	}
 	for {
-		b, err := ui.term.ReadByte()
+		r, _, err := ui.term.ReadChar()
 		if err != nil {
 			return string(buf), err
 		}
 
-		switch b {
+		switch r {
 		case '\r', '\n':
 			// Submit without moving the cursor (the template owns layout).
 			ui.mu.Lock()
 			ui.input = ui.input[:0]
-			ui.redrawInputLocked()
 			ui.mu.Unlock()
+			ui.redrawInput()
 			return string(buf), nil
 		case 8, 127: // backspace or delete
 			if len(buf) > 0 {
@@ -398,21 +495,17 @@ func (ui *templatedRoomUI) readInputLine() (string, error) {
 				if len(ui.input) > 0 {
 					ui.input = ui.input[:len(ui.input)-1]
 				}
-				// Erase the last character visually.
-				_ = ui.term.Send("\b \b")
 				ui.mu.Unlock()
+				ui.redrawInput()
 			}
 		default:
-			if b >= 32 && b < 127 && len(buf) < maxLen {
-				buf = append(buf, b)
+			if r >= 32 && len(buf) < maxLen {
+				buf = append(buf, r)
 				ui.mu.Lock()
-				ui.input = append(ui.input, b)
-				_ = ui.term.Send(string(b))
+				ui.input = append(ui.input, r)
 				ui.mu.Unlock()
+				ui.redrawInput()
 			}
 		}
 	}
 }
-
-// (No separate getLineInPlace helper; the UI synchronizes terminal output to
-// avoid interleaving async chat updates with user-typed echo.)
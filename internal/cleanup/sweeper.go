@@ -0,0 +1,185 @@
+package cleanup
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Policy describes how one temp directory is cleaned. Each direct child of
+// Dir (file or directory) matching Pattern is treated as one entry.
+type Policy struct {
+	Dir      string
+	Pattern  string        // glob for entry names (empty = all entries)
+	MaxAge   time.Duration // entries not modified for this long are removed (0 = no age limit)
+	MaxBytes int64         // oldest entries are removed until the total fits (0 = no size limit)
+}
+
+// Stats reports what a sweep reclaimed.
+type Stats struct {
+	Entries int
+	Bytes   int64
+}
+
+// Sweeper removes stale per-session temp data (door session dirs, upload
+// staging dirs) left behind by crashed or killed sessions.
+type Sweeper struct {
+	policies []Policy
+
+	// InUse reports whether an entry belongs to a live session and must be
+	// kept regardless of policy. It receives the entry's base name.
+	InUse func(name string) bool
+
+	mu     sync.Mutex
+	total  Stats
+	sweeps int
+}
+
+// NewSweeper creates a sweeper for the given policies.
+func NewSweeper(policies ...Policy) *Sweeper {
+	return &Sweeper{policies: policies}
+}
+
+// Purge removes every entry that is not in use, regardless of age or size.
+// It is meant for startup, when no session can own the data yet.
+func (s *Sweeper) Purge() Stats {
+	var stats Stats
+	for _, p := range s.policies {
+		for _, e := range s.entries(p) {
+			stats.add(s.remove(e))
+		}
+	}
+	s.record(stats)
+	return stats
+}
+
+// Sweep applies each policy once.
+func (s *Sweeper) Sweep() Stats {
+	return s.sweepAt(time.Now())
+}
+
+func (s *Sweeper) sweepAt(now time.Time) Stats {
+	var stats Stats
+	for _, p := range s.policies {
+		entries := s.entries(p)
+
+		// Oldest first, so size trimming removes the stalest data.
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].modTime.Before(entries[j].modTime)
+		})
+
+		var kept []entry
+		var keptBytes int64
+		for _, e := range entries {
+			if p.MaxAge > 0 && now.Sub(e.modTime) > p.MaxAge {
+				stats.add(s.remove(e))
+				continue
+			}
+			kept = append(kept, e)
+			keptBytes += e.size
+		}
+
+		for _, e := range kept {
+			if p.MaxBytes <= 0 || keptBytes <= p.MaxBytes {
+				break
+			}
+			removed := s.remove(e)
+			stats.add(removed)
+			keptBytes -= removed.Bytes
+		}
+	}
+	s.record(stats)
+	return stats
+}
+
+// Run sweeps every interval until stop is closed.
+func (s *Sweeper) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if stats := s.Sweep(); stats.Entries > 0 {
+				log.Printf("Cleanup: removed %d stale temp entries (%d bytes)", stats.Entries, stats.Bytes)
+			}
+		}
+	}
+}
+
+// Totals returns the cumulative space reclaimed and the number of sweeps run.
+func (s *Sweeper) Totals() (Stats, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total, s.sweeps
+}
+
+func (s *Sweeper) record(stats Stats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total.add(stats)
+	s.sweeps++
+}
+
+func (st *Stats) add(other Stats) {
+	st.Entries += other.Entries
+	st.Bytes += other.Bytes
+}
+
+type entry struct {
+	path    string
+	name    string
+	size    int64
+	modTime time.Time // newest modification time within the entry
+}
+
+// entries lists the direct children of the policy dir that match its
+// pattern and are not in use.
+func (s *Sweeper) entries(p Policy) []entry {
+	children, err := os.ReadDir(p.Dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Cleanup: read %s: %v", p.Dir, err)
+		}
+		return nil
+	}
+
+	var entries []entry
+	for _, c := range children {
+		if p.Pattern != "" {
+			if ok, _ := filepath.Match(p.Pattern, c.Name()); !ok {
+				continue
+			}
+		}
+		if s.InUse != nil && s.InUse(c.Name()) {
+			continue
+		}
+		e := entry{path: filepath.Join(p.Dir, c.Name()), name: c.Name()}
+		filepath.Walk(e.path, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if !info.IsDir() {
+				e.size += info.Size()
+			}
+			if info.ModTime().After(e.modTime) {
+				e.modTime = info.ModTime()
+			}
+			return nil
+		})
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (s *Sweeper) remove(e entry) Stats {
+	if err := os.RemoveAll(e.path); err != nil {
+		log.Printf("Cleanup: remove %s: %v", e.path, err)
+		return Stats{}
+	}
+	return Stats{Entries: 1, Bytes: e.size}
+}
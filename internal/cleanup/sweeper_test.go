@@ -0,0 +1,73 @@
+package cleanup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeEntry(t *testing.T, dir, name string, size int, mod time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(path, "data")
+	if err := os.WriteFile(file, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{file, path} {
+		if err := os.Chtimes(p, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestSweepAgeSizeAndInUse(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writeEntry(t, dir, "node1", 100, now.Add(-48*time.Hour)) // stale, but in use
+	writeEntry(t, dir, "node2", 100, now.Add(-48*time.Hour)) // stale
+	writeEntry(t, dir, "node3", 300, now.Add(-2*time.Hour))  // oldest of the fresh ones
+	writeEntry(t, dir, "node4", 300, now.Add(-1*time.Hour))
+	writeEntry(t, dir, "keep.txt", 100, now.Add(-48*time.Hour)) // not matched
+
+	s := NewSweeper(Policy{Dir: dir, Pattern: "node*", MaxAge: 24 * time.Hour, MaxBytes: 500})
+	s.InUse = func(name string) bool { return name == "node1" }
+
+	stats := s.sweepAt(now)
+	if stats.Entries != 2 || stats.Bytes != 400 {
+		t.Fatalf("expected 2 entries / 400 bytes reclaimed, got %+v", stats)
+	}
+	for name, want := range map[string]bool{"node1": true, "node2": false, "node3": false, "node4": true, "keep.txt": true} {
+		if got := exists(filepath.Join(dir, name)); got != want {
+			t.Errorf("%s exists=%v, want %v", name, got, want)
+		}
+	}
+
+	total, sweeps := s.Totals()
+	if sweeps != 1 || total != stats {
+		t.Fatalf("unexpected totals %+v after %d sweeps", total, sweeps)
+	}
+}
+
+func TestPurgeRemovesEverythingNotInUse(t *testing.T) {
+	dir := t.TempDir()
+	writeEntry(t, dir, "upload-1", 10, time.Now())
+	writeEntry(t, dir, "upload-2", 10, time.Now())
+
+	s := NewSweeper(Policy{Dir: dir, Pattern: "upload-*", MaxAge: time.Hour})
+	if stats := s.Purge(); stats.Entries != 2 {
+		t.Fatalf("expected 2 entries purged, got %+v", stats)
+	}
+	if stats := s.Purge(); stats.Entries != 0 {
+		t.Fatalf("expected nothing left, got %+v", stats)
+	}
+}
@@ -0,0 +1,44 @@
+// Package conference groups message and file areas into conferences that
+// callers join, as on PCBoard and Wildcat boards. Area lists, new scans and
+// file searches cover the caller's current conference; the main board holds
+// every area not assigned elsewhere and every caller belongs to it.
+package conference
+
+import (
+	"errors"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// Main is the ID of the main board.
+const Main = 1
+
+var (
+	// ErrNotAllowed is returned when a user's level or flags do not admit
+	// them to a conference.
+	ErrNotAllowed = errors.New("you may not join that conference")
+	// ErrMainBoard is returned when leaving or deleting the main board.
+	ErrMainBoard = errors.New("the main board cannot be left or deleted")
+)
+
+// Conference is a group of message and file areas.
+type Conference struct {
+	ID          int
+	Name        string
+	Description string
+	JoinLevel   int    // lowest security level that may join
+	Flags       string // group flags a member must hold, e.g. "A"
+	SortOrder   int
+	MsgAreas    int  // computed: message areas in the conference
+	FileAreas   int  // computed: file areas in the conference
+	Joined      bool // computed per user by List
+}
+
+// Admits reports whether u may join c. Everyone may join the main board and
+// sysops may join any conference.
+func (c *Conference) Admits(u *user.User) bool {
+	if c.ID == Main || u.SecurityLevel >= user.LevelSysop {
+		return true
+	}
+	return u.SecurityLevel >= c.JoinLevel && u.HasFlags(c.Flags)
+}
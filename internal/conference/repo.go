@@ -0,0 +1,279 @@
+package conference
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// Repo handles database operations for conferences and their members.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo creates a conference repository.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+// All returns every conference in menu order, with area counts.
+func (r *Repo) All() ([]*Conference, error) {
+	rows, err := r.db.Query(`
+		SELECT c.id, c.name, c.description, c.join_level, c.flags, c.sort_order,
+		       (SELECT COUNT(*) FROM message_areas WHERE conference_id = c.id),
+		       (SELECT COUNT(*) FROM file_areas WHERE conference_id = c.id)
+		FROM conferences c
+		ORDER BY c.sort_order, c.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list conferences: %w", err)
+	}
+	defer rows.Close()
+
+	var confs []*Conference
+	for rows.Next() {
+		c := &Conference{}
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.JoinLevel, &c.Flags,
+			&c.SortOrder, &c.MsgAreas, &c.FileAreas); err != nil {
+			return nil, fmt.Errorf("list conferences: %w", err)
+		}
+		confs = append(confs, c)
+	}
+	return confs, rows.Err()
+}
+
+// List returns the conferences u may join, with Joined set for those they
+// have.
+func (r *Repo) List(u *user.User) ([]*Conference, error) {
+	all, err := r.All()
+	if err != nil {
+		return nil, err
+	}
+	joined, err := r.memberships(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	var confs []*Conference
+	for _, c := range all {
+		if c.Admits(u) {
+			c.Joined = c.ID == Main || joined[c.ID]
+			confs = append(confs, c)
+		}
+	}
+	return confs, nil
+}
+
+// memberships returns the IDs of the conferences a user has joined.
+func (r *Repo) memberships(userID int) (map[int]bool, error) {
+	rows, err := r.db.Query(`SELECT conference_id FROM conference_members WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list memberships: %w", err)
+	}
+	defer rows.Close()
+	joined := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("list memberships: %w", err)
+		}
+		joined[id] = true
+	}
+	return joined, rows.Err()
+}
+
+// Get returns a conference by ID, with area counts.
+func (r *Repo) Get(id int) (*Conference, error) {
+	c := &Conference{}
+	err := r.db.QueryRow(`
+		SELECT c.id, c.name, c.description, c.join_level, c.flags, c.sort_order,
+		       (SELECT COUNT(*) FROM message_areas WHERE conference_id = c.id),
+		       (SELECT COUNT(*) FROM file_areas WHERE conference_id = c.id)
+		FROM conferences c WHERE c.id = ?
+	`, id).Scan(&c.ID, &c.Name, &c.Description, &c.JoinLevel, &c.Flags,
+		&c.SortOrder, &c.MsgAreas, &c.FileAreas)
+	if err != nil {
+		return nil, fmt.Errorf("get conference %d: %w", id, err)
+	}
+	return c, nil
+}
+
+// Join makes u a member of conference id and their current conference.
+// Joining a conference already joined just switches to it.
+func (r *Repo) Join(u *user.User, id int) (*Conference, error) {
+	c, err := r.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !c.Admits(u) {
+		return nil, ErrNotAllowed
+	}
+	if c.ID != Main {
+		if _, err := r.db.Exec(`
+			INSERT OR IGNORE INTO conference_members (user_id, conference_id) VALUES (?, ?)
+		`, u.ID, c.ID); err != nil {
+			return nil, fmt.Errorf("join conference %d: %w", id, err)
+		}
+	}
+	if err := r.setCurrent(u, c.ID); err != nil {
+		return nil, err
+	}
+	c.Joined = true
+	return c, nil
+}
+
+// Leave ends u's membership of conference id. Leaving the current
+// conference returns them to the main board.
+func (r *Repo) Leave(u *user.User, id int) error {
+	if id == Main {
+		return ErrMainBoard
+	}
+	if _, err := r.db.Exec(`
+		DELETE FROM conference_members WHERE user_id = ? AND conference_id = ?
+	`, u.ID, id); err != nil {
+		return fmt.Errorf("leave conference %d: %w", id, err)
+	}
+	if u.Conference == id {
+		return r.setCurrent(u, Main)
+	}
+	return nil
+}
+
+// Current returns u's current conference. If it is gone, they have left it
+// or they no longer qualify for it, u is moved back to the main board.
+func (r *Repo) Current(u *user.User) (*Conference, error) {
+	if u.Conference != Main {
+		c, err := r.Get(u.Conference)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if c != nil && c.Admits(u) {
+			joined, err := r.memberships(u.ID)
+			if err != nil {
+				return nil, err
+			}
+			if joined[c.ID] {
+				c.Joined = true
+				return c, nil
+			}
+		}
+		if err := r.setCurrent(u, Main); err != nil {
+			return nil, err
+		}
+	}
+	c, err := r.Get(Main)
+	if err != nil {
+		return nil, err
+	}
+	c.Joined = true
+	return c, nil
+}
+
+func (r *Repo) setCurrent(u *user.User, id int) error {
+	if _, err := r.db.Exec(`UPDATE users SET conference_id = ? WHERE id = ?`, id, u.ID); err != nil {
+		return fmt.Errorf("set current conference: %w", err)
+	}
+	u.Conference = id
+	return nil
+}
+
+// Save adds c, or updates it when c.ID is set, and returns its ID.
+func (r *Repo) Save(c *Conference) (int, error) {
+	flags, err := user.NormalizeFlags(c.Flags)
+	if err != nil {
+		return 0, err
+	}
+	if c.Name == "" {
+		return 0, errors.New("save conference: name is required")
+	}
+	if c.ID == 0 {
+		result, err := r.db.Exec(`
+			INSERT INTO conferences (name, description, join_level, flags, sort_order)
+			VALUES (?, ?, ?, ?, ?)
+		`, c.Name, c.Description, c.JoinLevel, flags, c.SortOrder)
+		if err != nil {
+			return 0, fmt.Errorf("add conference: %w", err)
+		}
+		id, err := result.LastInsertId()
+		return int(id), err
+	}
+	result, err := r.db.Exec(`
+		UPDATE conferences SET name = ?, description = ?, join_level = ?, flags = ?, sort_order = ?
+		WHERE id = ?
+	`, c.Name, c.Description, c.JoinLevel, flags, c.SortOrder, c.ID)
+	if err != nil {
+		return 0, fmt.Errorf("update conference %d: %w", c.ID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, fmt.Errorf("update conference %d: %w", c.ID, sql.ErrNoRows)
+	}
+	return c.ID, nil
+}
+
+// Delete removes a conference. Its areas and the users in it move to the
+// main board.
+func (r *Repo) Delete(id int) error {
+	if id == Main {
+		return ErrMainBoard
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("delete conference %d: %w", id, err)
+	}
+	defer tx.Rollback()
+	for _, q := range []string{
+		`UPDATE message_areas SET conference_id = 1 WHERE conference_id = ?`,
+		`UPDATE file_areas SET conference_id = 1 WHERE conference_id = ?`,
+		`UPDATE users SET conference_id = 1 WHERE conference_id = ?`,
+		`DELETE FROM conference_members WHERE conference_id = ?`,
+		`DELETE FROM conferences WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(q, id); err != nil {
+			return fmt.Errorf("delete conference %d: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// AssignMessageArea moves a message area into conference id.
+func (r *Repo) AssignMessageArea(areaID, id int) error {
+	return r.assign("message_areas", areaID, id)
+}
+
+// AssignFileArea moves a file area into conference id.
+func (r *Repo) AssignFileArea(areaID, id int) error {
+	return r.assign("file_areas", areaID, id)
+}
+
+func (r *Repo) assign(table string, areaID, id int) error {
+	if _, err := r.Get(id); err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`UPDATE `+table+` SET conference_id = ? WHERE id = ?`, id, areaID)
+	if err != nil {
+		return fmt.Errorf("assign area %d: %w", areaID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("assign area %d: %w", areaID, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// MessageAreas returns the conference of every message area, by area ID.
+func (r *Repo) MessageAreas() (map[int]int, error) {
+	rows, err := r.db.Query(`SELECT id, conference_id FROM message_areas`)
+	if err != nil {
+		return nil, fmt.Errorf("list area conferences: %w", err)
+	}
+	defer rows.Close()
+	confs := make(map[int]int)
+	for rows.Next() {
+		var area, conf int
+		if err := rows.Scan(&area, &conf); err != nil {
+			return nil, fmt.Errorf("list area conferences: %w", err)
+		}
+		confs[area] = conf
+	}
+	return confs, rows.Err()
+}
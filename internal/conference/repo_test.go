@@ -0,0 +1,130 @@
+package conference
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+func openRepo(t *testing.T) (*Repo, *db.DB) {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	return NewRepo(database.DB), database
+}
+
+func TestJoinLeaveAndCurrent(t *testing.T) {
+	r, _ := openRepo(t)
+	amiga, err := r.Save(&Conference{Name: "Amiga", JoinLevel: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ham, err := r.Save(&Conference{Name: "Ham Radio", JoinLevel: 10, Flags: "h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &user.User{ID: 1, SecurityLevel: 20, Conference: Main}
+
+	confs, err := r.List(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(confs) != 2 || confs[0].ID != Main || !confs[0].Joined || confs[1].ID != amiga || confs[1].Joined {
+		t.Fatalf("List = %+v %+v", confs[0], confs[len(confs)-1])
+	}
+	if _, err := r.Join(u, ham); !errors.Is(err, ErrNotAllowed) {
+		t.Fatalf("join without flag: %v", err)
+	}
+	if _, err := r.Join(u, amiga); err != nil || u.Conference != amiga {
+		t.Fatalf("join: %v, current %d", err, u.Conference)
+	}
+	if c, err := r.Current(u); err != nil || c.ID != amiga {
+		t.Fatalf("Current = %+v, %v", c, err)
+	}
+
+	// A user who drops below the join level is sent back to the main board.
+	u.SecurityLevel = 10
+	if c, err := r.Current(u); err != nil || c.ID != Main || u.Conference != Main {
+		t.Fatalf("Current after demotion = %+v, %v", c, err)
+	}
+	u.SecurityLevel = 20
+	if _, err := r.Join(u, amiga); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Leave(u, amiga); err != nil || u.Conference != Main {
+		t.Fatalf("leave: %v, current %d", err, u.Conference)
+	}
+	if err := r.Leave(u, Main); !errors.Is(err, ErrMainBoard) {
+		t.Fatalf("leave main: %v", err)
+	}
+	u.Conference = amiga // left, so no longer valid
+	if c, err := r.Current(u); err != nil || c.ID != Main {
+		t.Fatalf("Current after leaving = %+v, %v", c, err)
+	}
+}
+
+func TestAreasFollowConference(t *testing.T) {
+	r, database := openRepo(t)
+	amiga, err := r.Save(&Conference{Name: "Amiga"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`
+		INSERT INTO message_areas (id, name, read_level) VALUES (11, 'Amiga Chat', 10), (12, 'Workbench', 10)
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`INSERT INTO file_areas (id, name, disk_path) VALUES (11, 'Amiga Files', '/tmp/a')`); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{11, 12} {
+		if err := r.AssignMessageArea(id, amiga); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.AssignFileArea(11, amiga); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AssignFileArea(99, amiga); err == nil {
+		t.Fatal("assigned a missing area")
+	}
+
+	msgs := message.NewRepo(database.DB)
+	all, _ := msgs.ListAreas(10)
+	main, _ := msgs.InConferences(Main).ListAreas(10)
+	conf, _ := msgs.InConferences(amiga).ListAreas(10)
+	if len(conf) != 2 || conf[0].Conference != amiga || len(main)+len(conf) != len(all) {
+		t.Fatalf("areas: %d in conference, %d on main board, %d in all", len(conf), len(main), len(all))
+	}
+	files, _ := filearea.NewRepo(database.DB).InConferences(amiga).ListAreas(10)
+	if len(files) != 1 || files[0].Name != "Amiga Files" {
+		t.Fatalf("file areas = %v", files)
+	}
+	c, err := r.Get(amiga)
+	if err != nil || c.MsgAreas != 2 || c.FileAreas != 1 {
+		t.Fatalf("Get = %+v, %v", c, err)
+	}
+
+	// Deleting the conference returns its areas to the main board.
+	if err := r.Delete(amiga); err != nil {
+		t.Fatal(err)
+	}
+	main, _ = msgs.InConferences(Main).ListAreas(10)
+	if len(main) != len(all) {
+		t.Fatalf("main board has %d of %d areas after delete", len(main), len(all))
+	}
+	if err := r.Delete(Main); !errors.Is(err, ErrMainBoard) {
+		t.Fatalf("delete main: %v", err)
+	}
+}
@@ -2,29 +2,99 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/mail"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds the BBS configuration (excluding BBS identity settings which are in the database).
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Paths    PathsConfig    `yaml:"paths"`
-	Doors    DoorsConfig    `yaml:"doors"`
-	Transfer TransferConfig `yaml:"transfer"`
+	Server      ServerConfig      `yaml:"server"`
+	Listeners   []ListenerConfig  `yaml:"listeners"`
+	Paths       PathsConfig       `yaml:"paths"`
+	Doors       DoorsConfig       `yaml:"doors"`
+	Transfer    TransferConfig    `yaml:"transfer"`
+	Nodes       []NodeConfig      `yaml:"nodes"`
+	Cleanup     CleanupConfig     `yaml:"cleanup"`
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+	Gopher      GopherConfig      `yaml:"gopher"`
+	Feeds       FeedsConfig       `yaml:"feeds"`
+	Flood       FloodConfig       `yaml:"flood"`
+	Passwords   PasswordsConfig   `yaml:"passwords"`
+	TwoFactor   TwoFactorConfig   `yaml:"two_factor"`
+	Scripting   ScriptingConfig   `yaml:"scripting"`
+	Greetings   GreetingsConfig   `yaml:"greetings"`
+	Onboarding  OnboardingConfig  `yaml:"onboarding"`
+	NNTP        NNTPConfig        `yaml:"nntp"`
+	Email       EmailConfig       `yaml:"email"`
+	SMTP        SMTPConfig        `yaml:"smtp"`
+	Shutdown    ShutdownConfig    `yaml:"shutdown"`
+	WaitingRoom WaitingRoomConfig `yaml:"waiting_room"`
+	Dashboard   DashboardConfig   `yaml:"dashboard"`
+	Recording   RecordingConfig   `yaml:"recording"`
+	Chat        ChatConfig        `yaml:"chat"`
+	PublicStats PublicStatsConfig `yaml:"public_stats"`
+	Announce    AnnounceConfig    `yaml:"announce"`
+	FileStats   FileStatsConfig   `yaml:"file_stats"`
 }
 
-// ServerConfig holds network listener settings.
+// ServerConfig holds network listener settings. TelnetPort and SSHPort are
+// only used when no listeners are configured.
 type ServerConfig struct {
-	TelnetPort int `yaml:"telnet_port"`
-	SSHPort    int `yaml:"ssh_port"`
-	HealthPort int `yaml:"health_port"`
+	TelnetPort    int    `yaml:"telnet_port"`
+	SSHPort       int    `yaml:"ssh_port"`
+	HealthPort    int    `yaml:"health_port"`
+	ControlSocket string `yaml:"control_socket"` // Unix socket for bbsctl and bbs-admin -remote, "" = off
+
+	SSH SSHServerConfig `yaml:"ssh"`
+}
+
+// SSHServerConfig holds the algorithms SSH listeners offer.
+type SSHServerConfig struct {
+	LegacyCiphers bool     `yaml:"legacy_ciphers"` // also offer SHA-1, CBC and group1 algorithms for old clients such as SyncTERM
+	KeyExchanges  []string `yaml:"key_exchanges"`  // replace the built-in lists when set
+	Ciphers       []string `yaml:"ciphers"`
+	MACs          []string `yaml:"macs"`
+}
+
+// Listener types.
+const (
+	ListenerTelnet = "telnet"
+	ListenerSSH    = "ssh"
+)
+
+// ListenerConfig describes one network listener.
+type ListenerConfig struct {
+	Type        string `yaml:"type"` // "telnet" or "ssh"
+	Bind        string `yaml:"bind"` // interface address, empty = all
+	Port        int    `yaml:"port"`
+	TLSCert     string `yaml:"tls_cert"`     // telnet only: serve telnet over TLS
+	TLSKey      string `yaml:"tls_key"`      // telnet only
+	Compress    bool   `yaml:"compress"`     // telnet only: offer MCCP2 compression to clients that support it
+	MaxPerIP    int    `yaml:"max_per_ip"`   // concurrent sessions per remote IP, 0 = unlimited
+	IdleTimeout int    `yaml:"idle_timeout"` // minutes without input before disconnect, 0 = never
+
+	// Connection rate limits; zero uses the built-in default.
+	RateBurst  int     `yaml:"rate_burst"`  // connections per IP before backoff starts
+	RateMax    int     `yaml:"rate_max"`    // connections per IP within rate_window before refusing, -1 = no limit
+	RateWindow int     `yaml:"rate_window"` // seconds a caller's attempts are remembered
+	AcceptRate float64 `yaml:"accept_rate"` // new connections per second from all callers, 0 = unlimited
+}
+
+// Addr returns the host:port the listener binds to.
+func (lc ListenerConfig) Addr() string {
+	return net.JoinHostPort(lc.Bind, strconv.Itoa(lc.Port))
 }
 
 // PathsConfig holds filesystem paths for assets and data.
 type PathsConfig struct {
 	Menus    string `yaml:"menus"`
+	Commands string `yaml:"commands"` // global Lua commands
 	Text     string `yaml:"text"`
 	Doors    string `yaml:"doors"`
 	Data     string `yaml:"data"`
@@ -33,13 +103,344 @@ type PathsConfig struct {
 
 // DoorsConfig holds DOS door integration settings.
 type DoorsConfig struct {
-	DosemuPath string `yaml:"dosemu_path"`
-	DriveC     string `yaml:"drive_c"`
+	DosemuPath  string `yaml:"dosemu_path"`
+	DriveC      string `yaml:"drive_c"`
+	DropFileTTL int    `yaml:"drop_file_ttl"` // seconds after door start before the drop file is scrubbed, 0 = at exit
+	// TranslateOutput converts the CP437 doors send for callers who chose
+	// UTF-8 or Latin-1. Off by default.
+	TranslateOutput bool          `yaml:"translate_output"`
+	Sandbox         SandboxConfig `yaml:"sandbox"`
+}
+
+// SandboxConfig holds the default isolation for door processes. Doors can
+// override network, overlay and the limits in their Lua config.
+type SandboxConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	UID        int    `yaml:"uid"` // run doors as this user, 0 = the BBS user
+	GID        int    `yaml:"gid"`
+	Network    bool   `yaml:"network"`     // allow network access
+	Overlay    bool   `yaml:"overlay"`     // per-node writable overlay of drive C
+	MemoryMB   int    `yaml:"memory_mb"`   // 0 = unlimited
+	CPUPercent int    `yaml:"cpu_percent"` // of one CPU, 0 = unlimited
+	MaxPids    int    `yaml:"max_pids"`    // 0 = unlimited
+	Cgroup     string `yaml:"cgroup"`      // cgroup v2 directory for door cgroups, empty = no limits
 }
 
 // TransferConfig holds file transfer protocol settings.
 type TransferConfig struct {
-	SexyzPath string `yaml:"sexyz_path"`
+	SexyzPath   string `yaml:"sexyz_path"`
+	SFTP        bool   `yaml:"sftp"`         // offer the sftp subsystem on SSH listeners
+	SFTPUploads bool   `yaml:"sftp_uploads"` // allow SFTP uploads into areas the user may upload to
+
+	// Download ratio: KB downloaded per KB uploaded, 0 = no ratio
+	Ratio            int   `yaml:"ratio"`
+	RatioFreeKB      int64 `yaml:"ratio_free_kb"`      // KB anyone may download before the ratio applies
+	RatioExemptLevel int   `yaml:"ratio_exempt_level"` // security level that ignores the ratio, 0 = none
+
+	// External protocols offered besides ZMODEM
+	Protocols []ProtocolConfig `yaml:"protocols"`
+
+	// One-time download links served over HTTP on the health server
+	WebLinks       bool   `yaml:"web_links"`
+	WebLinkMinutes int    `yaml:"web_link_minutes"` // how long a link stays valid
+	WebBaseURL     string `yaml:"web_base_url"`     // public URL of the health server, for links
+}
+
+// ProtocolConfig defines an external transfer program. Send and receive are
+// argument lists in which "{files}", "{dir}" and "{telnet}" are expanded
+// (see transfer.Protocol).
+type ProtocolConfig struct {
+	Key        string   `yaml:"key"`
+	Name       string   `yaml:"name"`
+	Command    string   `yaml:"command"`
+	Send       []string `yaml:"send"`
+	Receive    []string `yaml:"receive"`
+	Env        []string `yaml:"env"`
+	TelnetFlag string   `yaml:"telnet_flag"`
+}
+
+// CleanupConfig holds the temp directory cleanup policy (door session dirs,
+// drop file dirs and upload staging).
+type CleanupConfig struct {
+	Interval  int `yaml:"interval"`    // minutes between sweeps, 0 = startup only
+	MaxAge    int `yaml:"max_age"`     // minutes before an idle entry is removed
+	MaxSizeMB int `yaml:"max_size_mb"` // per-directory cap, 0 = unlimited
+}
+
+// MaintenanceConfig holds the nightly maintenance schedule.
+type MaintenanceConfig struct {
+	Time            string `yaml:"time"`             // local time of day, "HH:MM"
+	PurgeMessages   bool   `yaml:"purge_messages"`   // apply message area retention limits
+	ArchiveMessages bool   `yaml:"archive_messages"` // keep purged messages in message_archive
+
+	ExpireInactiveDays int `yaml:"expire_inactive_days"` // expire accounts with no call for this long, 0 = never
+	ExpireExemptLevel  int `yaml:"expire_exempt_level"`  // accounts at this security level or above never expire
+	PurgeDeletedDays   int `yaml:"purge_deleted_days"`   // anonymize deleted accounts after this long, 0 = never
+
+	Analyze           bool `yaml:"analyze"`            // refresh query planner statistics
+	Vacuum            bool `yaml:"vacuum"`             // rebuild the database file, giving free space back
+	IntegrityCheck    bool `yaml:"integrity_check"`    // check the database and log any problems
+	CheckpointMinutes int  `yaml:"checkpoint_minutes"` // truncate the write-ahead log this often, 0 = leave it to SQLite
+}
+
+// GopherConfig holds the read-only gopher front-end settings.
+type GopherConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Bind         string `yaml:"bind"` // interface address, empty = all
+	Port         int    `yaml:"port"`
+	Hostname     string `yaml:"hostname"`      // host name put in menu links
+	MessageAreas []int  `yaml:"message_areas"` // message areas to publish, none by default
+}
+
+// Addr returns the host:port the gopher server binds to.
+func (gc GopherConfig) Addr() string {
+	return net.JoinHostPort(gc.Bind, strconv.Itoa(gc.Port))
+}
+
+// FeedsConfig selects the message areas published as RSS and Atom feeds
+// on the health server.
+type FeedsConfig struct {
+	Areas   []int  `yaml:"areas"`    // message areas to publish, none by default
+	Items   int    `yaml:"items"`    // newest messages per feed
+	BaseURL string `yaml:"base_url"` // public URL of the health server, for links
+}
+
+// FloodConfig limits how often each user may post, chat and add files.
+// A zero limit is off.
+type FloodConfig struct {
+	PostsPerHour int `yaml:"posts_per_hour"` // public posts and private mail
+	ChatPer10s   int `yaml:"chat_per_10s"`   // chat lines, to a node, a room or everyone
+	FilesPerDay  int `yaml:"files_per_day"`  // file entries added, per 24 hours
+	ExemptLevel  int `yaml:"exempt_level"`   // security level that is never limited, 0 = none
+}
+
+// PasswordsConfig holds the policy for new and changed passwords.
+type PasswordsConfig struct {
+	MinLength  int  `yaml:"min_length"`
+	MinClasses int  `yaml:"min_classes"` // of lowercase, uppercase, digits and symbols
+	BanCommon  bool `yaml:"ban_common"`  // refuse common passwords and the username
+	BcryptCost int  `yaml:"bcrypt_cost"` // older hashes are upgraded at login
+}
+
+// TwoFactorConfig holds the TOTP two-factor authentication policy.
+type TwoFactorConfig struct {
+	RequireLevel int `yaml:"require_level"` // users at or above this level must enroll, 0 = optional
+}
+
+// ScriptingConfig holds the per-session limits and VM mode of Lua menu
+// scripts.
+type ScriptingConfig struct {
+	RegistrySize  int  `yaml:"registry_size"`   // Lua value stack slots
+	CallStackSize int  `yaml:"call_stack_size"` // nested Lua function calls
+	StoreKeys     int  `yaml:"store_keys"`      // bbs.store keys per user
+	SharedVM      bool `yaml:"shared_vm"`       // one VM per session, menus loaded as modules
+}
+
+// Minimum scripting limits; below these the standard libraries and menu
+// APIs do not load.
+const (
+	minRegistrySize  = 1024
+	minCallStackSize = 16
+)
+
+// GreetingsConfig holds the greetings picked for callers after login.
+type GreetingsConfig struct {
+	AtLogin     bool              `yaml:"at_login"`     // show the greeting when a caller logs in
+	AbsenceDays int               `yaml:"absence_days"` // days away before the return greeting, 0 = never
+	Periods     []GreetingPeriod  `yaml:"periods"`
+	Holidays    []GreetingHoliday `yaml:"holidays"`
+	Birthday    GreetingMessage   `yaml:"birthday"`
+	FirstCall   GreetingMessage   `yaml:"first_call"`
+	Return      GreetingMessage   `yaml:"return"`
+}
+
+// GreetingMessage is a greeting text and optional art file.
+type GreetingMessage struct {
+	Text string `yaml:"text"`
+	Art  string `yaml:"art"`
+}
+
+// GreetingPeriod is a time-of-day greeting.
+type GreetingPeriod struct {
+	From            string `yaml:"from"` // "HH:MM", until the next period
+	GreetingMessage `yaml:",inline"`
+}
+
+// GreetingHoliday is a greeting for a date or date range.
+type GreetingHoliday struct {
+	Date            string `yaml:"date"`  // "MM-DD"
+	Until           string `yaml:"until"` // "MM-DD", optional end of a range
+	GreetingMessage `yaml:",inline"`
+}
+
+// OnboardingConfig holds the guided tour of the board for new callers.
+type OnboardingConfig struct {
+	FirstCall bool       `yaml:"first_call"` // start the tour after login on a caller's first call
+	Steps     []TourStep `yaml:"steps"`
+}
+
+// TourStep is one stop of the tour.
+type TourStep struct {
+	Title string `yaml:"title"`
+	Art   string `yaml:"art"` // display file shown first, e.g. the menu's own art
+	Text  string `yaml:"text"`
+}
+
+// NNTPConfig holds the gateway between message areas and newsgroups.
+type NNTPConfig struct {
+	Enabled      bool        `yaml:"enabled"`
+	Server       string      `yaml:"server"` // "host:port"
+	TLS          bool        `yaml:"tls"`
+	Username     string      `yaml:"username"` // empty = no login
+	Password     string      `yaml:"password"`
+	Interval     int         `yaml:"interval"`     // minutes between syncs
+	Domain       string      `yaml:"domain"`       // for Message-IDs and poster addresses, e.g. "bbs.example.org"
+	Organization string      `yaml:"organization"` // Organization header, empty = the BBS name
+	User         string      `yaml:"user"`         // local account that owns imported articles, created locked if missing
+	MaxArticles  int         `yaml:"max_articles"` // articles fetched per group and sync
+	Backfill     int         `yaml:"backfill"`     // articles fetched on a group's first sync
+	Groups       []NNTPGroup `yaml:"groups"`
+}
+
+// NNTPGroup maps a message area to a newsgroup.
+type NNTPGroup struct {
+	Area      int    `yaml:"area"` // message area ID
+	Newsgroup string `yaml:"newsgroup"`
+	Post      bool   `yaml:"post"` // post the area's local messages to the group
+}
+
+// EmailConfig holds the gateway that imports mail from an IMAP mailbox as
+// private mail and feedback posts.
+type EmailConfig struct {
+	Enabled         bool              `yaml:"enabled"`
+	Server          string            `yaml:"server"` // IMAP "host:port"
+	TLS             bool              `yaml:"tls"`
+	Username        string            `yaml:"username"`
+	Password        string            `yaml:"password"`
+	Mailbox         string            `yaml:"mailbox"`
+	Interval        int               `yaml:"interval"`          // minutes between polls
+	Address         string            `yaml:"address"`           // the gateway's own address, e.g. "bbs@example.org"
+	User            string            `yaml:"user"`              // local account mail arrives from, created locked if missing
+	Users           map[string]string `yaml:"users"`             // address local part → user name
+	MapUsernames    bool              `yaml:"map_usernames"`     // deliver name@domain to the user of that name
+	FeedbackArea    int               `yaml:"feedback_area"`     // message area for mail to no user, 0 = skip it
+	QuarantineArea  int               `yaml:"quarantine_area"`   // file area for attachments, 0 = drop them
+	MaxAttachmentKB int               `yaml:"max_attachment_kb"` // larger attachments are dropped
+	MaxPerSender    int               `yaml:"max_per_sender"`    // mails imported per sender per day, 0 = unlimited
+	MaxMessages     int               `yaml:"max_messages"`      // mails handled per poll
+	Delete          bool              `yaml:"delete"`            // delete handled mail instead of marking it read
+}
+
+// SMTPConfig holds the outgoing mail server for password reset codes,
+// new-mail notices and sysop alerts.
+type SMTPConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Server       string `yaml:"server"` // SMTP "host:port"
+	TLS          bool   `yaml:"tls"`    // TLS from the start (port 465); otherwise STARTTLS when offered
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	From         string `yaml:"from"`          // sender address, e.g. "bbs@example.org"
+	SysopAddress string `yaml:"sysop_address"` // where sysop alerts go, "" = no alerts
+	Templates    string `yaml:"templates"`     // directory of <kind>.txt templates replacing the built-in ones
+	Retries      int    `yaml:"retries"`       // delivery attempts before a mail is dropped
+	ResetMinutes int    `yaml:"reset_minutes"` // how long a password reset code is valid
+	DiskWarnMB   int    `yaml:"disk_warn_mb"`  // alert the sysop when free space drops below this, 0 = never
+	ScriptAlerts int    `yaml:"script_alerts"` // mailer.notify_sysop calls allowed per call, 0 = not available
+}
+
+// ShutdownConfig holds how the BBS drains its nodes on SIGTERM or SIGINT.
+type ShutdownConfig struct {
+	Countdown int `yaml:"countdown"` // seconds of warnings before callers in the menus are disconnected
+	Grace     int `yaml:"grace"`     // further seconds for callers in doors and transfers to finish
+}
+
+// FileStatsConfig holds the download statistics and the top downloads
+// bulletin.
+type FileStatsConfig struct {
+	Bulletin bool   `yaml:"bulletin"`  // keep a top downloads bulletin up to date
+	Top      int    `yaml:"top"`       // files listed in the bulletin
+	Period   string `yaml:"period"`    // day, week, month, year or all
+	Level    int    `yaml:"level"`     // list files in areas open to this security level
+	Title    string `yaml:"title"`     // bulletin title, "" = "Top 10 downloads this month"
+	Template string `yaml:"template"`  // text/template file for the body, "" = built-in
+	KeepDays int    `yaml:"keep_days"` // downloads logged this long count toward periods
+}
+
+// AnnounceConfig holds the bridge that posts an announcements area to a
+// Mastodon account or an ActivityPub outbox.
+type AnnounceConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Area       int    `yaml:"area"`         // message area announced
+	Kind       string `yaml:"kind"`         // mastodon or activitypub
+	Server     string `yaml:"server"`       // Mastodon instance URL
+	Outbox     string `yaml:"outbox"`       // ActivityPub outbox URL
+	Actor      string `yaml:"actor"`        // ActivityPub actor ID
+	Token      string `yaml:"token"`        // access token
+	Visibility string `yaml:"visibility"`   // Mastodon visibility: public, unlisted or private
+	Template   string `yaml:"template"`     // text/template file for a post, "" = built-in
+	MaxLength  int    `yaml:"max_length"`   // characters per post
+	MaxPerHour int    `yaml:"max_per_hour"` // posts per hour, 0 = unlimited
+	Replies    bool   `yaml:"replies"`      // also post replies, not only new threads
+	Interval   int    `yaml:"interval"`     // minutes between checks for new messages
+	BaseURL    string `yaml:"base_url"`     // public URL of the health server, for links
+}
+
+// WaitingRoomConfig holds the queue callers may wait in for a node when
+// every node is busy.
+type WaitingRoomConfig struct {
+	Size    int `yaml:"size"`     // callers that may wait at once, 0 = turn callers away
+	MaxWait int `yaml:"max_wait"` // minutes a caller waits before giving up, 0 = no limit
+	Update  int `yaml:"update"`   // seconds between telling callers their place in line
+}
+
+// DashboardConfig holds the sysop's web dashboard.
+type DashboardConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Bind     string `yaml:"bind"` // interface address, 127.0.0.1 by default
+	Port     int    `yaml:"port"`
+	MinLevel int    `yaml:"min_level"` // security level that may log in
+	Session  int    `yaml:"session"`   // minutes a login lasts
+}
+
+// Addr returns the host:port the dashboard binds to.
+func (dc DashboardConfig) Addr() string {
+	return net.JoinHostPort(dc.Bind, strconv.Itoa(dc.Port))
+}
+
+// PublicStatsConfig holds the unauthenticated status endpoints on the
+// health server.
+type PublicStatsConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Fields  []string `yaml:"fields"` // figures to publish, empty = users, calls_today, online
+}
+
+// RecordingConfig holds session recording: what each node sends its
+// caller, kept for playback.
+type RecordingConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Dir       string `yaml:"dir"`         // where recordings go, data/recordings by default
+	Keep      int    `yaml:"keep"`        // newest recordings kept, 0 = all
+	MaxSizeKB int    `yaml:"max_size_kb"` // per recording, 0 = unlimited
+
+	// MaxAgeDays removes recordings this many days after they end, so
+	// sessions cannot be searched for longer; 0 = kept until Keep prunes.
+	MaxAgeDays int `yaml:"max_age_days"`
+}
+
+// ChatConfig holds chat room settings.
+type ChatConfig struct {
+	HistoryLines int `yaml:"history_lines"` // lines kept per public room, 0 = no history
+	HistoryShow  int `yaml:"history_show"`  // recent lines shown on joining a room
+	HistoryDays  int `yaml:"history_days"`  // lines older than this are removed, 0 = kept
+}
+
+// NodeConfig holds per-node overrides. Nodes without an entry use defaults.
+type NodeConfig struct {
+	ID        int    `yaml:"id"`
+	Name      string `yaml:"name"`
+	MinLevel  int    `yaml:"min_level"`  // minimum security level allowed to log in
+	TimeLimit int    `yaml:"time_limit"` // minutes per call after login, 0 = unlimited
+	Reserved  bool   `yaml:"reserved"`   // keep free for the sysop (implies min_level 100)
 }
 
 // Load reads and parses a YAML config file.
@@ -51,23 +452,159 @@ func Load(path string) (*Config, error) {
 
 	cfg := &Config{
 		Server: ServerConfig{
-			TelnetPort: 2323,
-			SSHPort:    2222,
-			HealthPort: 2223,
+			TelnetPort:    2323,
+			SSHPort:       2222,
+			HealthPort:    2223,
+			ControlSocket: "./data/control.sock",
+			SSH:           SSHServerConfig{LegacyCiphers: true},
 		},
 		Paths: PathsConfig{
 			Menus:    "./assets/menus",
+			Commands: "./assets/commands",
 			Text:     "./assets/text",
 			Doors:    "./assets/doors",
 			Data:     "./data",
 			Database: "./data/twilight.db",
 		},
 		Doors: DoorsConfig{
-			DosemuPath: "/usr/bin/dosemu",
-			DriveC:     "./doors/drive_c",
+			DosemuPath:  "/usr/bin/dosemu",
+			DriveC:      "./doors/drive_c",
+			DropFileTTL: 30,
+			Sandbox: SandboxConfig{
+				MemoryMB:   256,
+				CPUPercent: 100,
+				MaxPids:    64,
+				Cgroup:     "/sys/fs/cgroup/twilight_bbs",
+			},
 		},
 		Transfer: TransferConfig{
-			SexyzPath: "/usr/local/bin/sexyz",
+			SexyzPath:      "/usr/local/bin/sexyz",
+			WebLinkMinutes: 15,
+		},
+		Cleanup: CleanupConfig{
+			Interval:  60,
+			MaxAge:    24 * 60,
+			MaxSizeMB: 1024,
+		},
+		Maintenance: MaintenanceConfig{
+			Time:              "04:00",
+			PurgeMessages:     true,
+			ArchiveMessages:   true,
+			ExpireExemptLevel: 90,
+			Analyze:           true,
+			CheckpointMinutes: 15,
+		},
+		Gopher: GopherConfig{
+			Port:     7070,
+			Hostname: "localhost",
+		},
+		Feeds: FeedsConfig{
+			Items: 20,
+		},
+		Flood: FloodConfig{
+			PostsPerHour: 30,
+			ChatPer10s:   10,
+			FilesPerDay:  100,
+			ExemptLevel:  90,
+		},
+		Passwords: PasswordsConfig{
+			MinLength:  6,
+			BanCommon:  true,
+			BcryptCost: 12,
+		},
+		Scripting: ScriptingConfig{
+			RegistrySize:  64 * 1024,
+			CallStackSize: 200,
+			StoreKeys:     256,
+		},
+		NNTP: NNTPConfig{
+			Interval:    15,
+			User:        "Usenet",
+			MaxArticles: 200,
+			Backfill:    50,
+		},
+		SMTP: SMTPConfig{
+			Retries:      8,
+			ResetMinutes: 15,
+			DiskWarnMB:   500,
+		},
+		Email: EmailConfig{
+			TLS:             true,
+			Mailbox:         "INBOX",
+			Interval:        5,
+			User:            "Email",
+			MaxAttachmentKB: 1024,
+			MaxPerSender:    10,
+			MaxMessages:     50,
+		},
+		Shutdown: ShutdownConfig{
+			Countdown: 60,
+			Grace:     120,
+		},
+		FileStats: FileStatsConfig{
+			Top:      10,
+			Period:   "month",
+			Level:    10,
+			KeepDays: 400,
+		},
+		Announce: AnnounceConfig{
+			Kind:       "mastodon",
+			Visibility: "public",
+			MaxLength:  500,
+			MaxPerHour: 4,
+			Interval:   5,
+		},
+		WaitingRoom: WaitingRoomConfig{
+			MaxWait: 5,
+			Update:  30,
+		},
+		Dashboard: DashboardConfig{
+			Bind:     "127.0.0.1",
+			Port:     2224,
+			MinLevel: 100,
+			Session:  60,
+		},
+		Recording: RecordingConfig{
+			Keep:      100,
+			MaxSizeKB: 10 * 1024,
+		},
+		Chat: ChatConfig{
+			HistoryLines: 100,
+			HistoryShow:  10,
+			HistoryDays:  30,
+		},
+		Greetings: GreetingsConfig{
+			AtLogin:     true,
+			AbsenceDays: 30,
+			Periods: []GreetingPeriod{
+				{From: "05:00", GreetingMessage: GreetingMessage{Text: "Good morning, {{USER}}!"}},
+				{From: "12:00", GreetingMessage: GreetingMessage{Text: "Good afternoon, {{USER}}!"}},
+				{From: "18:00", GreetingMessage: GreetingMessage{Text: "Good evening, {{USER}}!"}},
+				{From: "23:00", GreetingMessage: GreetingMessage{Text: "Burning the midnight oil, {{USER}}?"}},
+			},
+			Holidays: []GreetingHoliday{
+				{Date: "01-01", GreetingMessage: GreetingMessage{Text: "Happy New Year, {{USER}}!"}},
+				{Date: "10-31", GreetingMessage: GreetingMessage{Text: "Happy Halloween, {{USER}}!"}},
+				{Date: "12-24", Until: "12-26", GreetingMessage: GreetingMessage{Text: "Merry Christmas, {{USER}}!"}},
+			},
+			Birthday:  GreetingMessage{Text: "Happy birthday, {{USER}}!"},
+			FirstCall: GreetingMessage{Text: "Welcome to the board, {{USER}}! This is your first call."},
+			Return:    GreetingMessage{Text: "Welcome back, {{USER}}! It has been {{DAYS}} days."},
+		},
+		Onboarding: OnboardingConfig{
+			FirstCall: true,
+			Steps: []TourStep{
+				{Title: "Message Bases", Art: "message_menu",
+					Text: "Press M at the main menu to read and post public messages. New messages are scanned for you by area."},
+				{Title: "File Areas", Art: "file_menu",
+					Text: "Press F to browse, search and download files. You can look inside archives before downloading them."},
+				{Title: "Doors", Art: "door_menu",
+					Text: "Press D for doors: games and programs that run right here on the board."},
+				{Title: "Chat", Art: "chat_room",
+					Text: "Press C to chat with other callers online now, and W to see who is on."},
+				{Title: "Settings",
+					Text: "Press S for your account settings, such as two-factor authentication. You can take this tour again from there."},
+			},
 		},
 	}
 
@@ -75,5 +612,264 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("parse config %s: %w", path, err)
 	}
 
+	if len(cfg.Listeners) == 0 {
+		cfg.Listeners = []ListenerConfig{
+			{Type: ListenerTelnet, Port: cfg.Server.TelnetPort},
+			{Type: ListenerSSH, Port: cfg.Server.SSHPort},
+		}
+	}
+	addrs := make(map[string]bool)
+	for _, lc := range cfg.Listeners {
+		if lc.Type != ListenerTelnet && lc.Type != ListenerSSH {
+			return nil, fmt.Errorf("parse config %s: listener type must be %q or %q, got %q", path, ListenerTelnet, ListenerSSH, lc.Type)
+		}
+		if lc.Port <= 0 || lc.Port > 65535 {
+			return nil, fmt.Errorf("parse config %s: invalid %s listener port %d", path, lc.Type, lc.Port)
+		}
+		if (lc.TLSCert == "") != (lc.TLSKey == "") {
+			return nil, fmt.Errorf("parse config %s: listener %s needs both tls_cert and tls_key", path, lc.Addr())
+		}
+		if lc.TLSCert != "" && lc.Type != ListenerTelnet {
+			return nil, fmt.Errorf("parse config %s: tls_cert is only supported for telnet listeners", path)
+		}
+		if lc.Compress && lc.Type != ListenerTelnet {
+			return nil, fmt.Errorf("parse config %s: compress is only supported for telnet listeners", path)
+		}
+		if lc.RateBurst < 0 || lc.RateMax < -1 || lc.RateWindow < 0 || lc.AcceptRate < 0 {
+			return nil, fmt.Errorf("parse config %s: listener %s has a negative rate limit", path, lc.Addr())
+		}
+		if addrs[lc.Addr()] {
+			return nil, fmt.Errorf("parse config %s: duplicate listener address %s", path, lc.Addr())
+		}
+		addrs[lc.Addr()] = true
+	}
+
+	if _, err := time.Parse("15:04", cfg.Maintenance.Time); err != nil {
+		return nil, fmt.Errorf("parse config %s: maintenance time must be HH:MM, got %q", path, cfg.Maintenance.Time)
+	}
+	if cfg.Maintenance.ExpireInactiveDays < 0 || cfg.Maintenance.PurgeDeletedDays < 0 {
+		return nil, fmt.Errorf("parse config %s: maintenance expire_inactive_days and purge_deleted_days cannot be negative", path)
+	}
+	if cfg.Maintenance.CheckpointMinutes < 0 {
+		return nil, fmt.Errorf("parse config %s: maintenance checkpoint_minutes cannot be negative", path)
+	}
+
+	if cfg.Gopher.Enabled {
+		if cfg.Gopher.Port <= 0 || cfg.Gopher.Port > 65535 {
+			return nil, fmt.Errorf("parse config %s: invalid gopher port %d", path, cfg.Gopher.Port)
+		}
+		if addrs[cfg.Gopher.Addr()] {
+			return nil, fmt.Errorf("parse config %s: gopher address %s is used by a listener", path, cfg.Gopher.Addr())
+		}
+	}
+
+	if d := cfg.Dashboard; d.Enabled {
+		if d.Port <= 0 || d.Port > 65535 {
+			return nil, fmt.Errorf("parse config %s: invalid dashboard port %d", path, d.Port)
+		}
+		if addrs[d.Addr()] || d.Port == cfg.Server.HealthPort {
+			return nil, fmt.Errorf("parse config %s: dashboard port %d is already in use", path, d.Port)
+		}
+		if d.MinLevel < 1 || d.Session < 1 {
+			return nil, fmt.Errorf("parse config %s: dashboard min_level and session must be positive", path)
+		}
+	}
+
+	if cfg.Transfer.WebLinks && cfg.Transfer.WebLinkMinutes <= 0 {
+		return nil, fmt.Errorf("parse config %s: transfer web_link_minutes must be positive, got %d", path, cfg.Transfer.WebLinkMinutes)
+	}
+	if cfg.Feeds.Items < 0 {
+		return nil, fmt.Errorf("parse config %s: feeds items must not be negative, got %d", path, cfg.Feeds.Items)
+	}
+
+	if f := cfg.Flood; f.PostsPerHour < 0 || f.ChatPer10s < 0 || f.FilesPerDay < 0 || f.ExemptLevel < 0 {
+		return nil, fmt.Errorf("parse config %s: flood limits must not be negative", path)
+	}
+
+	if cfg.Doors.DropFileTTL < 0 {
+		return nil, fmt.Errorf("parse config %s: doors drop_file_ttl must not be negative, got %d", path, cfg.Doors.DropFileTTL)
+	}
+
+	if sb := cfg.Doors.Sandbox; sb.UID < 0 || sb.GID < 0 || sb.MemoryMB < 0 || sb.CPUPercent < 0 || sb.MaxPids < 0 {
+		return nil, fmt.Errorf("parse config %s: doors sandbox values must not be negative", path)
+	}
+
+	if p := cfg.Passwords; p.MinLength < 1 || p.MinLength > 128 {
+		return nil, fmt.Errorf("parse config %s: passwords min_length must be 1-128, got %d", path, p.MinLength)
+	}
+	if p := cfg.Passwords; p.MinClasses < 0 || p.MinClasses > 4 {
+		return nil, fmt.Errorf("parse config %s: passwords min_classes must be 0-4, got %d", path, p.MinClasses)
+	}
+	if p := cfg.Passwords; p.BcryptCost < 10 || p.BcryptCost > 16 {
+		return nil, fmt.Errorf("parse config %s: passwords bcrypt_cost must be 10-16, got %d", path, p.BcryptCost)
+	}
+
+	if cfg.TwoFactor.RequireLevel < 0 {
+		return nil, fmt.Errorf("parse config %s: two_factor require_level must not be negative, got %d", path, cfg.TwoFactor.RequireLevel)
+	}
+
+	if s := cfg.Scripting; s.RegistrySize < minRegistrySize {
+		return nil, fmt.Errorf("parse config %s: scripting registry_size must be at least %d, got %d", path, minRegistrySize, s.RegistrySize)
+	}
+	if s := cfg.Scripting; s.CallStackSize < minCallStackSize {
+		return nil, fmt.Errorf("parse config %s: scripting call_stack_size must be at least %d, got %d", path, minCallStackSize, s.CallStackSize)
+	}
+	if cfg.Scripting.StoreKeys < 1 {
+		return nil, fmt.Errorf("parse config %s: scripting store_keys must be positive, got %d", path, cfg.Scripting.StoreKeys)
+	}
+
+	for _, gp := range cfg.Greetings.Periods {
+		if _, err := time.Parse("15:04", gp.From); err != nil {
+			return nil, fmt.Errorf("parse config %s: greeting period from must be HH:MM, got %q", path, gp.From)
+		}
+	}
+	for _, gh := range cfg.Greetings.Holidays {
+		if _, err := time.Parse("01-02", gh.Date); err != nil {
+			return nil, fmt.Errorf("parse config %s: greeting holiday date must be MM-DD, got %q", path, gh.Date)
+		}
+		if _, err := time.Parse("01-02", gh.Until); err != nil && gh.Until != "" {
+			return nil, fmt.Errorf("parse config %s: greeting holiday until must be MM-DD, got %q", path, gh.Until)
+		}
+	}
+	if cfg.Greetings.AbsenceDays < 0 {
+		return nil, fmt.Errorf("parse config %s: greetings absence_days must not be negative, got %d", path, cfg.Greetings.AbsenceDays)
+	}
+
+	for i, st := range cfg.Onboarding.Steps {
+		if st.Title == "" {
+			return nil, fmt.Errorf("parse config %s: onboarding step %d needs a title", path, i+1)
+		}
+		if st.Art == "" && st.Text == "" {
+			return nil, fmt.Errorf("parse config %s: onboarding step %q needs art or text", path, st.Title)
+		}
+	}
+
+	if n := cfg.NNTP; n.Enabled {
+		if n.Server == "" {
+			return nil, fmt.Errorf("parse config %s: nntp server is required", path)
+		}
+		if n.Domain == "" {
+			return nil, fmt.Errorf("parse config %s: nntp domain is required", path)
+		}
+		if n.User == "" {
+			return nil, fmt.Errorf("parse config %s: nntp user is required", path)
+		}
+		if n.Interval <= 0 {
+			return nil, fmt.Errorf("parse config %s: nntp interval must be positive, got %d", path, n.Interval)
+		}
+		if n.MaxArticles <= 0 || n.Backfill < 0 {
+			return nil, fmt.Errorf("parse config %s: nntp max_articles must be positive and backfill not negative", path)
+		}
+		for _, g := range n.Groups {
+			if g.Area <= 0 || g.Newsgroup == "" {
+				return nil, fmt.Errorf("parse config %s: nntp groups need an area and a newsgroup", path)
+			}
+		}
+	}
+
+	if f := cfg.FileStats; f.Top < 0 || f.KeepDays < 0 {
+		return nil, fmt.Errorf("parse config %s: file_stats top and keep_days must not be negative", path)
+	}
+	switch cfg.FileStats.Period {
+	case "day", "week", "month", "year", "all":
+	default:
+		return nil, fmt.Errorf("parse config %s: file_stats period must be day, week, month, year or all", path)
+	}
+
+	if a := cfg.Announce; a.Enabled {
+		switch {
+		case a.Kind != "mastodon" && a.Kind != "activitypub":
+			return nil, fmt.Errorf("parse config %s: announce kind must be mastodon or activitypub, got %q", path, a.Kind)
+		case a.Area <= 0:
+			return nil, fmt.Errorf("parse config %s: announce area must be set", path)
+		case a.Kind == "mastodon" && (a.Server == "" || a.Token == ""):
+			return nil, fmt.Errorf("parse config %s: announce server and token must be set for mastodon", path)
+		case a.Kind == "activitypub" && a.Outbox == "":
+			return nil, fmt.Errorf("parse config %s: announce outbox must be set for activitypub", path)
+		case a.MaxLength <= 0 || a.Interval <= 0:
+			return nil, fmt.Errorf("parse config %s: announce max_length and interval must be positive", path)
+		case a.MaxPerHour < 0:
+			return nil, fmt.Errorf("parse config %s: announce max_per_hour must not be negative, got %d", path, a.MaxPerHour)
+		}
+	}
+
+	if e := cfg.Email; e.Enabled {
+		if e.Server == "" || e.Address == "" || e.User == "" {
+			return nil, fmt.Errorf("parse config %s: email server, address and user are required", path)
+		}
+		if _, err := mail.ParseAddress(e.Address); err != nil {
+			return nil, fmt.Errorf("parse config %s: email address %q: %w", path, e.Address, err)
+		}
+		if e.Interval <= 0 {
+			return nil, fmt.Errorf("parse config %s: email interval must be positive, got %d", path, e.Interval)
+		}
+		if e.FeedbackArea < 0 || e.QuarantineArea < 0 || e.MaxAttachmentKB < 0 || e.MaxPerSender < 0 || e.MaxMessages < 0 {
+			return nil, fmt.Errorf("parse config %s: email areas and limits must not be negative", path)
+		}
+	}
+
+	if m := cfg.SMTP; m.Enabled {
+		if m.Server == "" || m.From == "" {
+			return nil, fmt.Errorf("parse config %s: smtp server and from are required", path)
+		}
+		for _, addr := range []string{m.From, m.SysopAddress} {
+			if _, err := mail.ParseAddress(addr); err != nil && addr != "" {
+				return nil, fmt.Errorf("parse config %s: smtp address %q: %w", path, addr, err)
+			}
+		}
+		if m.Retries <= 0 || m.ResetMinutes <= 0 {
+			return nil, fmt.Errorf("parse config %s: smtp retries and reset_minutes must be positive", path)
+		}
+		if m.DiskWarnMB < 0 || m.ScriptAlerts < 0 {
+			return nil, fmt.Errorf("parse config %s: smtp disk_warn_mb and script_alerts must not be negative", path)
+		}
+	}
+
+	if r := cfg.Recording; r.Keep < 0 || r.MaxSizeKB < 0 || r.MaxAgeDays < 0 {
+		return nil, fmt.Errorf("parse config %s: recording keep, max_size_kb and max_age_days must not be negative", path)
+	}
+
+	if c := cfg.Chat; c.HistoryLines < 0 || c.HistoryShow < 0 || c.HistoryDays < 0 {
+		return nil, fmt.Errorf("parse config %s: chat history_lines, history_show and history_days must not be negative", path)
+	}
+
+	if s := cfg.Shutdown; s.Countdown < 0 || s.Grace < 0 {
+		return nil, fmt.Errorf("parse config %s: shutdown countdown and grace must not be negative", path)
+	}
+
+	if w := cfg.WaitingRoom; w.Size < 0 || w.MaxWait < 0 || w.Update <= 0 {
+		return nil, fmt.Errorf("parse config %s: waiting_room size and max_wait must not be negative, and update must be positive", path)
+	}
+
+	keys := map[string]bool{"Z": true} // ZMODEM through SEXYZ
+	for _, pc := range cfg.Transfer.Protocols {
+		if pc.Key == "" || pc.Name == "" || pc.Command == "" {
+			return nil, fmt.Errorf("parse config %s: transfer protocols need a key, name and command", path)
+		}
+		if len(pc.Send) == 0 && len(pc.Receive) == 0 {
+			return nil, fmt.Errorf("parse config %s: transfer protocol %s needs send or receive arguments", path, pc.Name)
+		}
+		if keys[strings.ToUpper(pc.Key)] {
+			return nil, fmt.Errorf("parse config %s: transfer protocol key %q is already used", path, pc.Key)
+		}
+		keys[strings.ToUpper(pc.Key)] = true
+		for _, kv := range pc.Env {
+			if !strings.Contains(kv, "=") {
+				return nil, fmt.Errorf("parse config %s: transfer protocol %s env must be NAME=value, got %q", path, pc.Name, kv)
+			}
+		}
+	}
+
+	seen := make(map[int]bool)
+	for _, n := range cfg.Nodes {
+		if n.ID <= 0 {
+			return nil, fmt.Errorf("parse config %s: node id must be positive, got %d", path, n.ID)
+		}
+		if seen[n.ID] {
+			return nil, fmt.Errorf("parse config %s: duplicate node id %d", path, n.ID)
+		}
+		seen[n.ID] = true
+	}
+
 	return cfg, nil
 }
@@ -0,0 +1,137 @@
+package control
+
+import (
+	"fmt"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"path/filepath"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// Client talks to a running BBS over its control socket.
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Dial connects to the control socket at path.
+func Dial(path string) (*Client, error) {
+	c, err := jsonrpc.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("connect to BBS at %s: %w", path, err)
+	}
+	return &Client{rpc: c}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+func (c *Client) call(method string, args, reply interface{}) error {
+	return c.rpc.Call(serviceName+"."+method, args, reply)
+}
+
+// Nodes lists the connected nodes by number.
+func (c *Client) Nodes() ([]Node, error) {
+	var nodes []Node
+	err := c.call("Nodes", Empty{}, &nodes)
+	return nodes, err
+}
+
+// Kick disconnects a node, showing it msg first when msg is not empty.
+func (c *Client) Kick(nodeID int, msg string) error {
+	return c.call("Kick", KickArgs{Node: nodeID, Message: msg}, &Empty{})
+}
+
+// Broadcast shows msg on every node not busy in a door or transfer and
+// returns how many it reached.
+func (c *Client) Broadcast(msg string) (int, error) {
+	var n int
+	err := c.call("Broadcast", BroadcastArgs{Message: msg}, &n)
+	return n, err
+}
+
+// ReloadMenus rescans the menu directory and returns the number of menus.
+// Callers pick up changed menus the next time they enter them.
+func (c *Client) ReloadMenus() (int, error) {
+	var n int
+	err := c.call("ReloadMenus", Empty{}, &n)
+	return n, err
+}
+
+// ReloadConfig re-reads the config file and applies what can change live.
+func (c *Client) ReloadConfig() (*Reload, error) {
+	var r Reload
+	if err := c.call("ReloadConfig", Empty{}, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Shutdown stops the BBS. With a drain time it first stops taking calls
+// and counts down for drain, warning callers with msg, then gives callers
+// in doors and transfers the configured grace period to finish. It
+// returns once the BBS has accepted the request.
+func (c *Client) Shutdown(drain time.Duration, msg string) error {
+	return c.call("Shutdown", ShutdownArgs{Drain: int(drain / time.Second), Message: msg}, &Empty{})
+}
+
+// NNTPSync runs the newsgroup gateway now and returns its report.
+func (c *Client) NNTPSync() ([]string, error) {
+	var lines []string
+	if err := c.call("NNTPSync", Empty{}, &lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// Stats returns a snapshot of the running board.
+func (c *Client) Stats() (*Stats, error) {
+	var st Stats
+	if err := c.call("Stats", Empty{}, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Database returns the database's schema version and size.
+func (c *Client) Database() (*db.Info, error) {
+	var info db.Info
+	if err := c.call("Database", Empty{}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// CheckDatabase runs SQLite's integrity check on the live database and
+// returns the problems found, none when it is sound.
+func (c *Client) CheckDatabase() ([]string, error) {
+	var problems []string
+	if err := c.call("CheckDatabase", Empty{}, &problems); err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// BackupDatabase has the BBS write a consistent copy of its database to
+// path, which must not exist, while it keeps running.
+func (c *Client) BackupDatabase(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	return c.call("BackupDatabase", BackupArgs{Path: abs}, &Empty{})
+}
+
+// VacuumDatabase rebuilds the database file, refreshes the planner
+// statistics and truncates the write-ahead log, returning the new size.
+// Callers' writes wait while it runs.
+func (c *Client) VacuumDatabase() (*db.Info, error) {
+	var info db.Info
+	if err := c.call("VacuumDatabase", Empty{}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,343 @@
+// Package control is the local control interface of a running BBS: a
+// JSON-RPC service on a Unix socket. bbsctl and bbs-admin -remote use it
+// to list and kick nodes, broadcast, reload menus and config, read today's
+// statistics, sync newsgroups, check and back up the database and shut the
+// board down, without touching the database file or restarting.
+package control
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/node"
+	"github.com/notepid/twilight_bbs/internal/stats"
+)
+
+// serviceName prefixes the JSON-RPC methods, e.g. "BBS.Nodes".
+const serviceName = "BBS"
+
+// socketMode keeps the socket to the BBS's own user (and root).
+const socketMode = 0600
+
+// Node is a connected node.
+type Node struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	User     string    `json:"user"`
+	Remote   string    `json:"remote"`
+	Menu     string    `json:"menu"`
+	Since    time.Time `json:"since"`
+	Busy     string    `json:"busy,omitempty"` // "door" or "transfer"
+	Sent     int64     `json:"sent"`           // terminal bytes sent, transfers excluded
+	Received int64     `json:"received"`       // terminal bytes received
+}
+
+// Stats is a snapshot of the running board.
+type Stats struct {
+	Started    time.Time      `json:"started"`
+	Nodes      int            `json:"nodes"`
+	MaxNodes   int            `json:"max_nodes"`
+	Draining   bool           `json:"draining"`
+	Date       string         `json:"date"`
+	Today      map[string]int `json:"today"`      // by stats metric
+	AreaPosts  map[int]int    `json:"area_posts"` // today's posts by message area ID
+	TopCallers []stats.Caller `json:"top_callers"`
+}
+
+// Reload reports what a config reload changed.
+type Reload struct {
+	Applied []string `json:"applied"` // sections now in effect
+	Restart []string `json:"restart"` // changed sections that need a restart
+}
+
+// KickArgs are the arguments of BBS.Kick.
+type KickArgs struct {
+	Node    int    `json:"node"`
+	Message string `json:"message"`
+}
+
+// BroadcastArgs are the arguments of BBS.Broadcast.
+type BroadcastArgs struct {
+	Message string `json:"message"`
+}
+
+// ShutdownArgs are the arguments of BBS.Shutdown.
+type ShutdownArgs struct {
+	Drain   int    `json:"drain"` // countdown in seconds before callers are disconnected, 0 = now
+	Message string `json:"message"`
+}
+
+// BackupArgs are the arguments of BBS.BackupDatabase.
+type BackupArgs struct {
+	Path string `json:"path"` // absolute path of the copy, which must not exist
+}
+
+// Empty is the argument or reply of methods that have none.
+type Empty struct{}
+
+// topCallers is how many callers Stats includes.
+const topCallers = 10
+
+// Server serves the control interface. Reloads and shutdown are done by
+// the BBS through the callbacks.
+type Server struct {
+	Nodes    *node.Manager
+	Stats    *stats.Repo
+	DB       *db.DB // database maintenance, nil = not offered
+	MaxNodes int
+	Started  time.Time
+
+	ReloadMenus  func() (int, error) // returns the number of menus found
+	ReloadConfig func() (*Reload, error)
+	Shutdown     func(drain time.Duration, msg string)
+	Draining     func() bool
+	NNTPSync     func() ([]string, error) // runs the newsgroup gateway, returns its report
+}
+
+// Listen creates the control socket at path. A socket left behind by a BBS
+// that is no longer running is replaced; one still answering is an error.
+func Listen(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("control socket %s: another BBS is running", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("control socket %s: %w", path, err)
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("control socket %s: %w", path, err)
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("control socket %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Serve answers requests on l until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(serviceName, &service{s}); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// service holds the RPC methods.
+type service struct {
+	s *Server
+}
+
+// ListNodes returns the connected nodes by number.
+func (s *Server) ListNodes() []Node {
+	info := s.Nodes.ListInfo()
+	sort.Slice(info, func(i, j int) bool { return info[i].ID < info[j].ID })
+	nodes := make([]Node, 0, len(info))
+	for _, n := range info {
+		nodes = append(nodes, Node{ID: n.ID, Name: n.Name, User: n.UserName, Remote: n.Remote, Menu: n.Menu, Since: n.Since, Busy: n.Busy,
+			Sent: n.Sent, Received: n.Received})
+	}
+	return nodes
+}
+
+// Kick disconnects a node, showing msg first if it is not empty.
+func (s *Server) Kick(node int, msg string) error {
+	return s.Nodes.Kick(node, msg)
+}
+
+// Broadcast sends msg to every node and returns how many got it.
+func (s *Server) Broadcast(msg string) (int, error) {
+	if msg == "" {
+		return 0, errors.New("empty message")
+	}
+	return s.Nodes.Broadcast(msg), nil
+}
+
+// Status returns a snapshot of the board and today's statistics.
+func (s *Server) Status() (*Stats, error) {
+	st := &Stats{
+		Started:  s.Started,
+		Nodes:    s.Nodes.Count(),
+		MaxNodes: s.MaxNodes,
+	}
+	if s.Draining != nil {
+		st.Draining = s.Draining()
+	}
+	if s.Stats != nil {
+		d, err := s.Stats.Day(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		st.Date, st.Today, st.AreaPosts = d.Date, d.Totals, d.AreaPosts
+		if st.TopCallers, err = s.Stats.TopCallers(topCallers); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func (v *service) Nodes(_ Empty, reply *[]Node) error {
+	*reply = v.s.ListNodes()
+	return nil
+}
+
+func (v *service) Kick(args KickArgs, _ *Empty) error {
+	log.Printf("Control: kick node %d", args.Node)
+	return v.s.Kick(args.Node, args.Message)
+}
+
+func (v *service) Broadcast(args BroadcastArgs, reply *int) error {
+	n, err := v.s.Broadcast(args.Message)
+	if err != nil {
+		return err
+	}
+	log.Printf("Control: broadcast %q", args.Message)
+	*reply = n
+	return nil
+}
+
+func (v *service) ReloadMenus(_ Empty, reply *int) error {
+	if v.s.ReloadMenus == nil {
+		return errors.New("menu reload not supported")
+	}
+	n, err := v.s.ReloadMenus()
+	*reply = n
+	return err
+}
+
+func (v *service) ReloadConfig(_ Empty, reply *Reload) error {
+	if v.s.ReloadConfig == nil {
+		return errors.New("config reload not supported")
+	}
+	r, err := v.s.ReloadConfig()
+	if err != nil {
+		return err
+	}
+	*reply = *r
+	return nil
+}
+
+func (v *service) Shutdown(args ShutdownArgs, _ *Empty) error {
+	if v.s.Shutdown == nil {
+		return errors.New("shutdown not supported")
+	}
+	if args.Drain < 0 {
+		return errors.New("drain must not be negative")
+	}
+	v.s.Shutdown(time.Duration(args.Drain)*time.Second, args.Message)
+	return nil
+}
+
+func (v *service) NNTPSync(_ Empty, reply *[]string) error {
+	if v.s.NNTPSync == nil {
+		return errors.New("NNTP gateway not enabled")
+	}
+	log.Printf("Control: NNTP sync")
+	lines, err := v.s.NNTPSync()
+	if err != nil {
+		return err
+	}
+	*reply = lines
+	return nil
+}
+
+func (v *service) Stats(_ Empty, reply *Stats) error {
+	st, err := v.s.Status()
+	if err != nil {
+		return err
+	}
+	*reply = *st
+	return nil
+}
+
+func (v *service) database() (*db.DB, error) {
+	if v.s.DB == nil {
+		return nil, errors.New("database maintenance not supported")
+	}
+	return v.s.DB, nil
+}
+
+func (v *service) Database(_ Empty, reply *db.Info) error {
+	d, err := v.database()
+	if err != nil {
+		return err
+	}
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+	*reply = *info
+	return nil
+}
+
+func (v *service) CheckDatabase(_ Empty, reply *[]string) error {
+	d, err := v.database()
+	if err != nil {
+		return err
+	}
+	log.Printf("Control: database integrity check")
+	problems, err := d.Integrity()
+	if err != nil {
+		return err
+	}
+	// A null result reads as a malformed reply on the client.
+	*reply = append([]string{}, problems...)
+	return nil
+}
+
+func (v *service) BackupDatabase(args BackupArgs, _ *Empty) error {
+	d, err := v.database()
+	if err != nil {
+		return err
+	}
+	// The BBS's working directory is not the caller's.
+	if !filepath.IsAbs(args.Path) {
+		return fmt.Errorf("backup path %q is not absolute", args.Path)
+	}
+	log.Printf("Control: database backup to %s", args.Path)
+	return d.Backup(args.Path)
+}
+
+func (v *service) VacuumDatabase(_ Empty, reply *db.Info) error {
+	d, err := v.database()
+	if err != nil {
+		return err
+	}
+	log.Printf("Control: database vacuum")
+	if err := d.Vacuum(); err != nil {
+		return err
+	}
+	if err := d.Analyze(); err != nil {
+		return err
+	}
+	if _, err := d.Checkpoint(); err != nil {
+		return err
+	}
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+	*reply = *info
+	return nil
+}
@@ -0,0 +1,112 @@
+package control
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/node"
+	"github.com/notepid/twilight_bbs/internal/stats"
+)
+
+func TestClientServer(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	statsRepo := stats.NewRepo(database.DB)
+	if err := statsRepo.Add(time.Now(), stats.Calls, 0, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	var shutdown time.Duration
+	srv := &Server{
+		Nodes:        node.NewManager(4, "Test BBS", "Sysop"),
+		Stats:        statsRepo,
+		DB:           database,
+		MaxNodes:     4,
+		ReloadMenus:  func() (int, error) { return 12, nil },
+		ReloadConfig: func() (*Reload, error) { return &Reload{Applied: []string{"nodes"}, Restart: []string{"paths"}}, nil },
+		Shutdown:     func(drain time.Duration, msg string) { shutdown = drain },
+	}
+	path := filepath.Join(t.TempDir(), "control.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.Serve(l)
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if nodes, err := c.Nodes(); err != nil || len(nodes) != 0 {
+		t.Errorf("Nodes() = %v, %v; want none", nodes, err)
+	}
+	if err := c.Kick(2, "bye"); err == nil || !strings.Contains(err.Error(), "node 2 not found") {
+		t.Errorf("Kick(2) error = %v, want node not found", err)
+	}
+	if _, err := c.Broadcast(""); err == nil {
+		t.Error("Broadcast(\"\") succeeded, want error")
+	}
+	if n, err := c.ReloadMenus(); err != nil || n != 12 {
+		t.Errorf("ReloadMenus() = %d, %v; want 12", n, err)
+	}
+	r, err := c.ReloadConfig()
+	if err != nil || len(r.Restart) != 1 || r.Restart[0] != "paths" {
+		t.Errorf("ReloadConfig() = %+v, %v", r, err)
+	}
+	if _, err := c.NNTPSync(); err == nil {
+		t.Error("NNTPSync() without a gateway succeeded, want error")
+	}
+	st, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.MaxNodes != 4 || st.Today[stats.Calls] != 3 {
+		t.Errorf("Stats() = %+v, want max_nodes 4 and 3 calls", st)
+	}
+	if problems, err := c.CheckDatabase(); err != nil || len(problems) != 0 {
+		t.Errorf("CheckDatabase() = %v, %v; want no problems", problems, err)
+	}
+	backupPath := filepath.Join(t.TempDir(), "copy.db")
+	if err := c.BackupDatabase(backupPath); err != nil {
+		t.Errorf("BackupDatabase() = %v", err)
+	} else if schema, err := db.Check(backupPath); err != nil || schema != db.SchemaVersion() {
+		t.Errorf("backup schema = %d, %v", schema, err)
+	}
+	if info, err := c.VacuumDatabase(); err != nil || info.Schema != db.SchemaVersion() {
+		t.Errorf("VacuumDatabase() = %+v, %v", info, err)
+	}
+	if err := c.Shutdown(90*time.Second, ""); err != nil || shutdown != 90*time.Second {
+		t.Errorf("Shutdown() = %v, drain %v; want 90s", err, shutdown)
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Listen(path); err == nil || !strings.Contains(err.Error(), "another BBS is running") {
+		t.Errorf("second Listen error = %v, want another BBS is running", err)
+	}
+
+	// A crashed BBS leaves the socket file behind with nobody listening.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	l, err = Listen(path)
+	if err != nil {
+		t.Fatalf("Listen over stale socket: %v", err)
+	}
+	l.Close()
+}
@@ -0,0 +1,343 @@
+// Package dashboard is the sysop's web dashboard: who is on, recent
+// callers, today's statistics and the latest log lines, with the
+// broadcast and kick actions of the control interface. Sysops log in with
+// their BBS account; pages are plain server-side HTML with no scripts,
+// and /api/status gives the same figures as JSON.
+package dashboard
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/callers"
+	"github.com/notepid/twilight_bbs/internal/control"
+	"github.com/notepid/twilight_bbs/internal/stats"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// cookieName is the session cookie.
+const cookieName = "bbs_dashboard"
+
+// recentCalls is how many finished calls the dashboard lists.
+const recentCalls = 15
+
+// failDelay slows down password guessing.
+var failDelay = time.Second
+
+// Handler serves the dashboard.
+type Handler struct {
+	Name     string // board name shown in page titles
+	Control  *control.Server
+	Callers  *callers.Repo
+	Users    *user.Repo
+	Logs     *LogBuffer    // nil = no log panel
+	MinLevel int           // security level that may log in
+	Session  time.Duration // how long a login lasts
+
+	mux      *http.ServeMux
+	mu       sync.Mutex
+	sessions map[string]*session // by cookie value
+}
+
+// session is a logged-in sysop.
+type session struct {
+	user    string
+	token   string // must come back with every action, against CSRF
+	expires time.Time
+}
+
+// New returns the dashboard handler.
+func New(name string, ctl *control.Server, calls *callers.Repo, users *user.Repo, logs *LogBuffer, minLevel int, ttl time.Duration) *Handler {
+	h := &Handler{
+		Name:     name,
+		Control:  ctl,
+		Callers:  calls,
+		Users:    users,
+		Logs:     logs,
+		MinLevel: minLevel,
+		Session:  ttl,
+		mux:      http.NewServeMux(),
+		sessions: make(map[string]*session),
+	}
+	h.mux.HandleFunc("GET /{$}", h.auth(h.index))
+	h.mux.HandleFunc("GET /api/status", h.auth(h.status))
+	h.mux.HandleFunc("POST /broadcast", h.auth(h.action(h.broadcast)))
+	h.mux.HandleFunc("POST /kick", h.auth(h.action(h.kick)))
+	h.mux.HandleFunc("POST /logout", h.auth(h.action(h.logout)))
+	h.mux.HandleFunc("GET /login", h.loginForm)
+	h.mux.HandleFunc("POST /login", h.login)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Cache-Control", "no-store")
+	h.mux.ServeHTTP(w, r)
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// current returns the session of r, if it has a live one. Expired
+// sessions are dropped on the way, and so is r's when its sysop has since
+// been locked out or demoted below MinLevel.
+func (h *Handler) current(r *http.Request) *session {
+	h.mu.Lock()
+	now := time.Now()
+	for id, s := range h.sessions {
+		if now.After(s.expires) {
+			delete(h.sessions, id)
+		}
+	}
+	var s *session
+	c, err := r.Cookie(cookieName)
+	if err == nil {
+		s = h.sessions[c.Value]
+	}
+	h.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+
+	if why := h.recheck(s.user); why != "" {
+		log.Printf("Security: dashboard session of %s ended: %s", s.user, why)
+		h.mu.Lock()
+		delete(h.sessions, c.Value)
+		h.mu.Unlock()
+		return nil
+	}
+	return s
+}
+
+// recheck returns why a logged-in sysop may no longer use the dashboard,
+// or "" when they still may.
+func (h *Handler) recheck(name string) string {
+	u, err := h.Users.GetByUsername(name)
+	if err != nil {
+		return err.Error()
+	}
+	if err := u.LoginError(); err != nil {
+		return err.Error()
+	}
+	if u.SecurityLevel < h.MinLevel {
+		return "security level too low"
+	}
+	return ""
+}
+
+type sessionHandler func(w http.ResponseWriter, r *http.Request, s *session)
+
+// auth sends callers without a session to the login page.
+func (h *Handler) auth(next sessionHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := h.current(r)
+		if s == nil {
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				http.Error(w, "not logged in", http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		next(w, r, s)
+	}
+}
+
+// action checks the form token of a POST and then runs next, which
+// returns the note shown on the dashboard afterwards.
+func (h *Handler) action(next func(r *http.Request, s *session) string) sessionHandler {
+	return func(w http.ResponseWriter, r *http.Request, s *session) {
+		if subtle.ConstantTimeCompare([]byte(r.PostFormValue("token")), []byte(s.token)) != 1 {
+			http.Error(w, "bad form token; reload the page", http.StatusForbidden)
+			return
+		}
+		note := next(r, s)
+		if r.URL.Path == "/logout" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, "/?note="+url.QueryEscape(note), http.StatusSeeOther)
+	}
+}
+
+func (h *Handler) loginForm(w http.ResponseWriter, r *http.Request) {
+	h.render(w, loginPage, map[string]any{"Name": h.Name})
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	name := r.PostFormValue("username")
+	if err := h.check(name, r.PostFormValue("password"), r.PostFormValue("code")); err != "" {
+		log.Printf("Security: dashboard login as %q from %s refused: %s", name, r.RemoteAddr, err)
+		time.Sleep(failDelay)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		h.render(w, loginPage, map[string]any{"Name": h.Name, "Error": "Login failed.", "Username": name})
+		return
+	}
+	id := newToken()
+	h.mu.Lock()
+	h.sessions[id] = &session{user: name, token: newToken(), expires: time.Now().Add(h.Session)}
+	h.mu.Unlock()
+	log.Printf("Dashboard: %s logged in from %s", name, r.RemoteAddr)
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// check returns why a login fails, or "" when it may go ahead. As at the
+// BBS, only active accounts get in, accounts with two-factor
+// authentication on need a code as well, and accounts the policy requires
+// it of must have enrolled first.
+func (h *Handler) check(name, password, code string) string {
+	u, err := h.Users.GetByUsername(name)
+	if err != nil || !user.CheckPassword(password, u.PasswordHash) {
+		return "bad user name or password"
+	}
+	if err := u.LoginError(); err != nil {
+		return err.Error()
+	}
+	if u.SecurityLevel < h.MinLevel {
+		return "security level too low"
+	}
+	on, err := h.Users.TOTPEnabled(u.ID)
+	if err != nil {
+		return err.Error()
+	}
+	switch {
+	case on:
+		if ok, err := h.Users.VerifyTOTP(u.ID, code); err != nil || !ok {
+			return "bad two-factor code"
+		}
+	case h.Users.TOTPRequired(u):
+		return "two-factor authentication required; enroll at the BBS first"
+	}
+	return ""
+}
+
+func (h *Handler) logout(r *http.Request, s *session) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, other := range h.sessions {
+		if other == s {
+			delete(h.sessions, id)
+		}
+	}
+	return ""
+}
+
+func (h *Handler) broadcast(r *http.Request, s *session) string {
+	msg := strings.TrimSpace(r.PostFormValue("message"))
+	n, err := h.Control.Broadcast(msg)
+	if err != nil {
+		return "Broadcast failed: " + err.Error()
+	}
+	log.Printf("Dashboard: %s broadcast %q", s.user, msg)
+	return "Sent to " + strconv.Itoa(n) + " node(s)."
+}
+
+func (h *Handler) kick(r *http.Request, s *session) string {
+	id, err := strconv.Atoi(r.PostFormValue("node"))
+	if err != nil {
+		return "No node given."
+	}
+	if err := h.Control.Kick(id, strings.TrimSpace(r.PostFormValue("message"))); err != nil {
+		return "Kick failed: " + err.Error()
+	}
+	log.Printf("Dashboard: %s kicked node %d", s.user, id)
+	return "Node " + strconv.Itoa(id) + " disconnected."
+}
+
+// Status is what /api/status returns and the dashboard page shows.
+type Status struct {
+	Board   *control.Stats `json:"board"`
+	Nodes   []control.Node `json:"nodes"`
+	Callers []Call         `json:"callers"`
+	Log     []string       `json:"log"`
+}
+
+// Call is a finished call from the callers log.
+type Call struct {
+	User      string        `json:"user"` // "" if the caller never logged in
+	Node      int           `json:"node"`
+	Remote    string        `json:"remote"`
+	Connected time.Time     `json:"connected"`
+	Duration  time.Duration `json:"duration"` // nanoseconds
+	Posts     int           `json:"posts"`
+	LastMenu  string        `json:"last_menu"`
+}
+
+func (h *Handler) snapshot() (*Status, error) {
+	board, err := h.Control.Status()
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{Board: board, Nodes: h.Control.ListNodes()}
+	if h.Callers != nil {
+		calls, err := h.Callers.Recent(recentCalls)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range calls {
+			st.Callers = append(st.Callers, Call{User: c.Username, Node: c.NodeID, Remote: c.Remote,
+				Connected: c.ConnectedAt, Duration: c.Duration().Round(time.Second), Posts: c.Posts, LastMenu: c.LastMenu})
+		}
+	}
+	if h.Logs != nil {
+		st.Log = h.Logs.Lines()
+	}
+	return st, nil
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request, s *session) {
+	st, err := h.snapshot()
+	if err != nil {
+		log.Printf("Dashboard: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+func (h *Handler) index(w http.ResponseWriter, r *http.Request, s *session) {
+	st, err := h.snapshot()
+	if err != nil {
+		log.Printf("Dashboard: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.render(w, indexPage, map[string]any{
+		"Name":    h.Name,
+		"User":    s.user,
+		"Token":   s.token,
+		"Note":    r.URL.Query().Get("note"),
+		"Status":  st,
+		"Metrics": stats.Metrics,
+	})
+}
+
+func (h *Handler) render(w http.ResponseWriter, t *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		log.Printf("Dashboard: %v", err)
+	}
+}
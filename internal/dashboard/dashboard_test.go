@@ -0,0 +1,211 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/callers"
+	"github.com/notepid/twilight_bbs/internal/control"
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/node"
+	"github.com/notepid/twilight_bbs/internal/stats"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+func newDashboard(t *testing.T) (*httptest.Server, *user.Repo) {
+	t.Helper()
+	failDelay = 0
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	users := user.NewRepo(database.DB)
+	for name, level := range map[string]int{"sysop": user.LevelSysop, "alice": user.LevelRegular} {
+		u, err := users.Create(name, "secret99", "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		users.UpdateSecurityLevel(u.ID, level)
+	}
+	ctl := &control.Server{
+		Nodes:    node.NewManager(4, "Test BBS", "Sysop"),
+		Stats:    stats.NewRepo(database.DB),
+		MaxNodes: 4,
+		Started:  time.Now(),
+	}
+	logs := NewLogBuffer(10)
+	log.SetOutput(io.MultiWriter(io.Discard, logs))
+	t.Cleanup(func() { log.SetOutput(io.Discard) })
+	log.Print("hello from the log")
+
+	srv := httptest.NewServer(New("Test BBS", ctl, callers.NewRepo(database.DB), users, logs, user.LevelSysop, time.Hour))
+	t.Cleanup(srv.Close)
+	return srv, users
+}
+
+func client() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Jar: jar}
+}
+
+// body returns the body of a response, or the error in its place.
+func body(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return string(b)
+}
+
+func TestLoginRequired(t *testing.T) {
+	srv, _ := newDashboard(t)
+	c := client()
+
+	resp, err := c.Get(srv.URL + "/api/status")
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status without login = %v, %v", resp.Status, err)
+	}
+	if page := body(c.Get(srv.URL + "/")); !strings.Contains(page, "Sysop login") {
+		t.Fatalf("dashboard without login shows %q", page)
+	}
+	for _, creds := range []url.Values{
+		{"username": {"sysop"}, "password": {"wrong"}},
+		{"username": {"alice"}, "password": {"secret99"}}, // not a sysop
+	} {
+		resp, err := c.PostForm(srv.URL+"/login", creds)
+		if page := body(resp, err); resp.StatusCode != http.StatusForbidden || !strings.Contains(page, "Login failed") {
+			t.Errorf("login %v = %s", creds, resp.Status)
+		}
+	}
+}
+
+func TestLoginRefusedLikeTheBBS(t *testing.T) {
+	srv, users := newDashboard(t)
+	sysop, err := users.GetByUsername("sysop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := url.Values{"username": {"sysop"}, "password": {"secret99"}}
+	refused := func(why string) {
+		t.Helper()
+		resp, err := client().PostForm(srv.URL+"/login", creds)
+		if page := body(resp, err); resp.StatusCode != http.StatusForbidden || !strings.Contains(page, "Login failed") {
+			t.Errorf("login %s = %s", why, resp.Status)
+		}
+	}
+
+	if err := users.Lock(sysop.ID, "on holiday"); err != nil {
+		t.Fatal(err)
+	}
+	refused("to a locked account")
+	users.Unlock(sysop.ID)
+
+	users.SetTOTPPolicy(user.TOTPPolicy{Issuer: "Test BBS", RequireLevel: user.LevelSysop})
+	refused("without the required two-factor enrollment")
+
+	users.SetTOTPPolicy(user.TOTPPolicy{Issuer: "Test BBS"})
+	if page := body(client().PostForm(srv.URL+"/login", creds)); !strings.Contains(page, "Logged in as sysop") {
+		t.Errorf("login after unlock: %q", page)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	srv, _ := newDashboard(t)
+	c := client()
+
+	page := body(c.PostForm(srv.URL+"/login", url.Values{"username": {"sysop"}, "password": {"secret99"}}))
+	for _, want := range []string{"Logged in as sysop", "No one is on.", "hello from the log", "0/4 nodes"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("dashboard lacks %q", want)
+		}
+	}
+
+	var st Status
+	if err := json.Unmarshal([]byte(body(c.Get(srv.URL+"/api/status"))), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Board.MaxNodes != 4 || len(st.Log) == 0 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// Actions need the form token of the session.
+	resp, err := c.PostForm(srv.URL+"/broadcast", url.Values{"message": {"hi"}})
+	if body(resp, err); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("broadcast without token = %s", resp.Status)
+	}
+	token := regexp.MustCompile(`name="token" value="([0-9a-f]+)"`).FindStringSubmatch(page)
+	if token == nil {
+		t.Fatal("no form token on the page")
+	}
+	page = body(c.PostForm(srv.URL+"/kick", url.Values{"token": {token[1]}, "node": {"3"}}))
+	if !strings.Contains(page, "Kick failed: node 3 not found") {
+		t.Errorf("kick of an empty node: %q", page)
+	}
+	page = body(c.PostForm(srv.URL+"/broadcast", url.Values{"token": {token[1]}, "message": {"hi"}}))
+	if !strings.Contains(page, "Sent to 0 node(s).") {
+		t.Errorf("broadcast: %q", page)
+	}
+
+	body(c.PostForm(srv.URL+"/logout", url.Values{"token": {token[1]}}))
+	if resp, err := c.Get(srv.URL + "/api/status"); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status after logout = %v, %v", resp.Status, err)
+	}
+}
+
+func TestSessionEndsWhenSysopLosesAccess(t *testing.T) {
+	srv, users := newDashboard(t)
+	sysop, err := users.GetByUsername("sysop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := url.Values{"username": {"sysop"}, "password": {"secret99"}}
+	loggedOut := func(c *http.Client) bool {
+		resp, err := c.Get(srv.URL + "/api/status")
+		body(resp, err)
+		return err == nil && resp.StatusCode == http.StatusUnauthorized
+	}
+
+	c := client()
+	body(c.PostForm(srv.URL+"/login", creds))
+	users.Lock(sysop.ID, "compromised")
+	if !loggedOut(c) {
+		t.Error("session survived locking the account")
+	}
+	users.Unlock(sysop.ID)
+	if !loggedOut(c) {
+		t.Error("session came back after unlocking")
+	}
+
+	c = client()
+	body(c.PostForm(srv.URL+"/login", creds))
+	users.UpdateSecurityLevel(sysop.ID, user.LevelRegular)
+	if !loggedOut(c) {
+		t.Error("session survived demotion")
+	}
+}
+
+func TestExpiredSessionsPruned(t *testing.T) {
+	h := &Handler{sessions: map[string]*session{
+		"old":   {user: "a", expires: time.Now().Add(-time.Minute)},
+		"older": {user: "b", expires: time.Now().Add(-time.Hour)},
+		"live":  {user: "c", expires: time.Now().Add(time.Hour)},
+	}}
+	if s := h.current(httptest.NewRequest("GET", "/", nil)); s != nil {
+		t.Fatalf("current without a cookie = %+v", s)
+	}
+	if len(h.sessions) != 1 || h.sessions["live"] == nil {
+		t.Errorf("sessions after pruning = %v", h.sessions)
+	}
+}
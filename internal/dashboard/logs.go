@@ -0,0 +1,49 @@
+package dashboard
+
+import (
+	"strings"
+	"sync"
+)
+
+// LogBuffer keeps the last lines written to it, for the dashboard's log
+// panel. Add it to the log output with io.MultiWriter.
+type LogBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	max     int
+	partial strings.Builder // a line not yet ended
+}
+
+// NewLogBuffer returns a buffer of the last n lines.
+func NewLogBuffer(n int) *LogBuffer {
+	return &LogBuffer{max: max(n, 1)}
+}
+
+// Write implements io.Writer.
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := string(p)
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			b.partial.WriteString(s)
+			break
+		}
+		b.partial.WriteString(s[:i])
+		b.lines = append(b.lines, b.partial.String())
+		b.partial.Reset()
+		s = s[i+1:]
+	}
+	if len(b.lines) > b.max {
+		b.lines = append(b.lines[:0], b.lines[len(b.lines)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+// Lines returns the buffered lines, oldest first.
+func (b *LogBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.lines...)
+}
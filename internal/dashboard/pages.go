@@ -0,0 +1,77 @@
+package dashboard
+
+import (
+	"html/template"
+	"time"
+)
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+	"ago": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String()
+	},
+	"kb": func(n int64) int64 { return (n + 1023) / 1024 },
+}
+
+const layout = `<!DOCTYPE html>
+<html><head><meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Name}} - Sysop</title>
+<style>
+body{background:#000;color:#aaa;font-family:monospace;max-width:72em;margin:1em auto;padding:0 1em}
+a{color:#5ff}h1,h2{color:#fff;font-size:1.2em}
+table{border-collapse:collapse;width:100%}td,th{text-align:left;padding:.2em .5em}
+th{color:#ff5;border-bottom:1px solid #555}
+input,button{background:#111;color:#fff;border:1px solid #555;font-family:monospace}
+.note{color:#5f5}.err{color:#f55}form.inline{display:inline}
+pre{white-space:pre-wrap;margin:.5em 0;color:#888}
+</style></head><body>
+{{block "content" .}}{{end}}
+</body></html>
+`
+
+func page(content string) *template.Template {
+	return template.Must(template.Must(template.New("layout").Funcs(funcs).Parse(layout)).Parse(content))
+}
+
+var loginPage = page(`{{define "content"}}<h1>{{.Name}} - Sysop login</h1>
+{{with .Error}}<p class="err">{{.}}</p>{{end}}
+<form method="post" action="/login"><table>
+<tr><td>User name</td><td><input name="username" value="{{.Username}}" autofocus></td></tr>
+<tr><td>Password</td><td><input name="password" type="password"></td></tr>
+<tr><td>Two-factor code</td><td><input name="code" autocomplete="one-time-code"> (if enabled)</td></tr>
+<tr><td></td><td><button>Log in</button></td></tr>
+</table></form>
+{{end}}`)
+
+var indexPage = page(`{{define "content"}}{{$token := .Token}}{{$st := .Status}}
+<h1>{{.Name}} - Sysop</h1>
+<p>Logged in as {{.User}} &middot; <a href="/">Refresh</a> &middot; <a href="/api/status">JSON</a> &middot;
+<form class="inline" method="post" action="/logout"><input type="hidden" name="token" value="{{$token}}"><button>Log out</button></form></p>
+{{with .Note}}<p class="note">{{.}}</p>{{end}}
+<p>Up since {{date $st.Board.Started}} &middot; {{$st.Board.Nodes}}/{{$st.Board.MaxNodes}} nodes in use{{if $st.Board.Draining}} &middot; <span class="err">draining for shutdown</span>{{end}}</p>
+
+<h2>Nodes</h2>
+{{if $st.Nodes}}<table><tr><th>Node</th><th>User</th><th>From</th><th>Menu</th><th>On for</th><th>Sent/received KB</th><th></th></tr>
+{{range $st.Nodes}}<tr><td>{{.ID}}{{with .Name}} {{.}}{{end}}</td><td>{{.User}}</td><td>{{.Remote}}</td>
+<td>{{.Menu}}{{with .Busy}} ({{.}}){{end}}</td><td>{{ago .Since}}</td><td>{{kb .Sent}}/{{kb .Received}}</td>
+<td><form class="inline" method="post" action="/kick"><input type="hidden" name="token" value="{{$token}}">
+<input type="hidden" name="node" value="{{.ID}}"><input name="message" placeholder="message (optional)">
+<button>Kick</button></form></td></tr>
+{{end}}</table>{{else}}<p>No one is on.</p>{{end}}
+<form method="post" action="/broadcast"><input type="hidden" name="token" value="{{$token}}">
+<input name="message" size="60" placeholder="message to every node"> <button>Broadcast</button></form>
+
+<h2>Today{{with $st.Board.Date}} ({{.}}){{end}}</h2>
+<table>{{range .Metrics}}<tr><td>{{.}}</td><td>{{index $st.Board.Today .}}</td></tr>{{end}}</table>
+
+<h2>Recent callers</h2>
+{{if $st.Callers}}<table><tr><th>User</th><th>Node</th><th>From</th><th>On</th><th>For</th><th>Posts</th><th>Last menu</th></tr>
+{{range $st.Callers}}<tr><td>{{or .User "(no login)"}}</td><td>{{.Node}}</td><td>{{.Remote}}</td>
+<td>{{date .Connected}}</td><td>{{.Duration}}</td><td>{{.Posts}}</td><td>{{.LastMenu}}</td></tr>
+{{end}}</table>{{else}}<p>No calls yet.</p>{{end}}
+
+{{if $st.Log}}<h2>Log</h2>
+<pre>{{range $st.Log}}{{.}}
+{{end}}</pre>{{end}}
+{{end}}`)
@@ -8,6 +8,10 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// SQLiteTime is the layout SQLite uses for CURRENT_TIMESTAMP (UTC). Times
+// written in it compare correctly as text against stored timestamps.
+const SQLiteTime = "2006-01-02 15:04:05"
+
 // DB wraps a SQLite database connection.
 type DB struct {
 	*sql.DB
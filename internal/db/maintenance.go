@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	sqlite "modernc.org/sqlite"
+)
+
+// Info describes the open database.
+type Info struct {
+	Schema    int   `json:"schema"`     // migrations applied
+	PageSize  int64 `json:"page_size"`  // bytes per page
+	Pages     int64 `json:"pages"`      // pages in the database file
+	FreePages int64 `json:"free_pages"` // unused pages VACUUM would give back
+}
+
+// Size returns the size of the database file in bytes.
+func (i *Info) Size() int64 {
+	return i.PageSize * i.Pages
+}
+
+// Info returns the database's schema version and page counts.
+func (db *DB) Info() (*Info, error) {
+	info := &Info{}
+	for _, p := range []struct {
+		pragma string
+		dst    *int64
+	}{
+		{"page_size", &info.PageSize},
+		{"page_count", &info.Pages},
+		{"freelist_count", &info.FreePages},
+	} {
+		if err := db.QueryRow(`PRAGMA ` + p.pragma).Scan(p.dst); err != nil {
+			return nil, fmt.Errorf("read %s: %w", p.pragma, err)
+		}
+	}
+	schema, err := schemaOf(db.DB)
+	if err != nil {
+		return nil, err
+	}
+	info.Schema = schema
+	return info, nil
+}
+
+// Backup writes a consistent copy of the open database to path with
+// SQLite's online backup API, while the BBS keeps using it. path must not
+// exist; the copy is written beside it and renamed into place, so a failed
+// backup leaves nothing behind.
+func (db *DB) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup database: %s already exists", path)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".backup-*.db")
+	if err != nil {
+		return fmt.Errorf("backup database: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return fmt.Errorf("backup database: %w", err)
+	}
+	defer conn.Close()
+	err = conn.Raw(func(dc any) error {
+		src, ok := dc.(interface {
+			NewBackup(string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("driver does not support online backup")
+		}
+		b, err := src.NewBackup(tmp.Name())
+		if err != nil {
+			return err
+		}
+		// One step copies every page under a single read transaction. In
+		// WAL mode that does not hold up writers.
+		if _, err := b.Step(-1); err != nil {
+			b.Finish()
+			return err
+		}
+		return b.Finish()
+	})
+	if err != nil {
+		return fmt.Errorf("backup database: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("backup database: %w", err)
+	}
+	return nil
+}
+
+// Integrity runs SQLite's integrity check and returns the problems it
+// reports, none when the database is sound.
+func (db *DB) Integrity() ([]string, error) {
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("check database: %w", err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("check database: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("check database: %w", err)
+	}
+	return problems, nil
+}
+
+// Vacuum rebuilds the database file, giving free pages back to the
+// filesystem. Writers wait while it runs.
+func (db *DB) Vacuum() error {
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("vacuum database: %w", err)
+	}
+	return nil
+}
+
+// Analyze refreshes the statistics the query planner uses.
+func (db *DB) Analyze() error {
+	if _, err := db.Exec(`ANALYZE`); err != nil {
+		return fmt.Errorf("analyze database: %w", err)
+	}
+	return nil
+}
+
+// Checkpoint copies the write-ahead log into the database file and
+// truncates it, returning how many WAL pages it wrote back. Pages a reader
+// still needs stay in the log until the next checkpoint.
+func (db *DB) Checkpoint() (int, error) {
+	var busy, logPages, done sql.NullInt64
+	if err := db.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logPages, &done); err != nil {
+		return 0, fmt.Errorf("checkpoint database: %w", err)
+	}
+	return int(done.Int64), nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMaintenance(t *testing.T) {
+	dir := t.TempDir()
+	database, err := Open(filepath.Join(dir, "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	info, err := database.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Schema != SchemaVersion() || info.Size() == 0 {
+		t.Fatalf("Info = %+v", info)
+	}
+	if problems, err := database.Integrity(); err != nil || len(problems) > 0 {
+		t.Fatalf("Integrity = %v, %v", problems, err)
+	}
+	if err := database.Analyze(); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Vacuum(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	copyPath := filepath.Join(dir, "copy.db")
+	if err := database.Backup(copyPath); err != nil {
+		t.Fatal(err)
+	}
+	if schema, err := Check(copyPath); err != nil || schema != SchemaVersion() {
+		t.Fatalf("Check(copy) = %d, %v", schema, err)
+	}
+	if err := database.Backup(copyPath); err == nil {
+		t.Fatal("Backup overwrote an existing file")
+	}
+}
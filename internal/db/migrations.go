@@ -130,4 +130,407 @@ var migrations = []migration{
 				(1, 'Twilight BBS', 'Sysop', 32)
 		`,
 	},
+	{
+		name: "add users last_node",
+		sql: `
+			ALTER TABLE users ADD COLUMN last_node INTEGER DEFAULT 0
+		`,
+	},
+	{
+		name: "create private mail table",
+		sql: `
+			CREATE TABLE IF NOT EXISTS private_mail (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				from_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+				to_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+				subject TEXT NOT NULL,
+				body TEXT NOT NULL,
+				read_at DATETIME,
+				deleted_by_recipient BOOLEAN DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_private_mail_to ON private_mail(to_user_id, id);
+			CREATE INDEX IF NOT EXISTS idx_private_mail_from ON private_mail(from_user_id, id);
+		`,
+	},
+	{
+		name: "add message area retention",
+		sql: `
+			ALTER TABLE message_areas ADD COLUMN max_messages INTEGER DEFAULT 0;
+			ALTER TABLE message_areas ADD COLUMN max_age_days INTEGER DEFAULT 0
+		`,
+	},
+	{
+		name: "create message archive table",
+		sql: `
+			CREATE TABLE IF NOT EXISTS message_archive (
+				id INTEGER PRIMARY KEY,
+				area_id INTEGER NOT NULL,
+				from_user_id INTEGER NOT NULL,
+				to_user_id INTEGER,
+				subject TEXT NOT NULL,
+				body TEXT NOT NULL,
+				reply_to_id INTEGER,
+				created_at DATETIME,
+				archived_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_message_archive_area ON message_archive(area_id, id);
+		`,
+	},
+	{
+		name: "create bulletins tables",
+		sql: `
+			CREATE TABLE IF NOT EXISTS bulletins (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				title TEXT NOT NULL,
+				body TEXT NOT NULL DEFAULT '',
+				art TEXT NOT NULL DEFAULT '',
+				published_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				expires_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS bulletin_seen (
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				bulletin_id INTEGER NOT NULL REFERENCES bulletins(id) ON DELETE CASCADE,
+				seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (user_id, bulletin_id)
+			);
+		`,
+	},
+	{
+		name: "create callers log",
+		sql: `
+			CREATE TABLE IF NOT EXISTS callers (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				username TEXT NOT NULL DEFAULT '',
+				node_id INTEGER NOT NULL,
+				remote TEXT NOT NULL DEFAULT '',
+				connected_at DATETIME NOT NULL,
+				disconnected_at DATETIME NOT NULL,
+				seconds INTEGER NOT NULL DEFAULT 0,
+				bytes_up INTEGER NOT NULL DEFAULT 0,
+				bytes_down INTEGER NOT NULL DEFAULT 0,
+				posts INTEGER NOT NULL DEFAULT 0,
+				last_menu TEXT NOT NULL DEFAULT ''
+			);
+			CREATE INDEX IF NOT EXISTS idx_callers_disconnected ON callers(disconnected_at);
+			ALTER TABLE users ADD COLUMN time_used_secs INTEGER DEFAULT 0;
+			ALTER TABLE users ADD COLUMN total_posts INTEGER DEFAULT 0;
+			ALTER TABLE users ADD COLUMN bytes_uploaded INTEGER DEFAULT 0;
+			ALTER TABLE users ADD COLUMN bytes_downloaded INTEGER DEFAULT 0;
+		`,
+	},
+	{
+		name: "create user ssh keys",
+		sql: `
+			CREATE TABLE IF NOT EXISTS user_ssh_keys (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				fingerprint TEXT NOT NULL UNIQUE,
+				authorized_key TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_user_ssh_keys_user ON user_ssh_keys(user_id);
+		`,
+	},
+	{
+		name: "create user settings",
+		sql: `
+			CREATE TABLE IF NOT EXISTS user_settings (
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				key TEXT NOT NULL,
+				kind TEXT NOT NULL DEFAULT 'string',
+				value TEXT NOT NULL DEFAULT '',
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (user_id, key)
+			);
+		`,
+	},
+	{
+		name: "add message area web_public",
+		sql: `
+			ALTER TABLE message_areas ADD COLUMN web_public INTEGER DEFAULT 0;
+		`,
+	},
+	{
+		name: "create user totp",
+		sql: `
+			CREATE TABLE IF NOT EXISTS user_totp (
+				user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+				secret TEXT NOT NULL,
+				enabled INTEGER NOT NULL DEFAULT 0,
+				last_step INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS user_backup_codes (
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				code_hash TEXT NOT NULL,
+				used_at DATETIME,
+				PRIMARY KEY (user_id, code_hash)
+			);
+		`,
+	},
+	{
+		name: "create daily stats",
+		sql: `
+			CREATE TABLE IF NOT EXISTS daily_stats (
+				day TEXT NOT NULL,
+				metric TEXT NOT NULL,
+				subject INTEGER NOT NULL DEFAULT 0,
+				value INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (day, metric, subject)
+			);
+		`,
+	},
+	{
+		name: "add users birthday",
+		sql: `
+			ALTER TABLE users ADD COLUMN birthday TEXT NOT NULL DEFAULT ''
+		`,
+	},
+	{
+		name: "create nntp gateway tables",
+		sql: `
+			ALTER TABLE messages ADD COLUMN from_net TEXT NOT NULL DEFAULT '';
+			ALTER TABLE message_archive ADD COLUMN from_net TEXT NOT NULL DEFAULT '';
+			CREATE TABLE IF NOT EXISTS nntp_articles (
+				message_id TEXT PRIMARY KEY,
+				msg_id INTEGER REFERENCES messages(id) ON DELETE SET NULL,
+				area_id INTEGER NOT NULL,
+				outbound BOOLEAN NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_nntp_articles_msg ON nntp_articles(msg_id);
+			CREATE TABLE IF NOT EXISTS nntp_groups (
+				area_id INTEGER NOT NULL,
+				newsgroup TEXT NOT NULL,
+				last_article INTEGER NOT NULL DEFAULT 0,
+				last_exported INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (area_id, newsgroup)
+			);
+		`,
+	},
+	{
+		name: "add users baud rate",
+		sql: `
+			ALTER TABLE users ADD COLUMN baud_rate INTEGER NOT NULL DEFAULT 0
+		`,
+	},
+	{
+		name: "add users flags",
+		sql: `
+			ALTER TABLE users ADD COLUMN flags TEXT NOT NULL DEFAULT ''
+		`,
+	},
+	{
+		name: "create door sessions",
+		sql: `
+			CREATE TABLE IF NOT EXISTS door_sessions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				door TEXT NOT NULL,
+				user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				username TEXT NOT NULL DEFAULT '',
+				node_id INTEGER NOT NULL DEFAULT 0,
+				started_at DATETIME NOT NULL,
+				ended_at DATETIME NOT NULL,
+				seconds INTEGER NOT NULL DEFAULT 0,
+				exit_status TEXT NOT NULL DEFAULT ''
+			);
+			CREATE INDEX IF NOT EXISTS idx_door_sessions_started ON door_sessions(started_at);
+			CREATE INDEX IF NOT EXISTS idx_door_sessions_user ON door_sessions(user_id, door);
+		`,
+	},
+	{
+		name: "add callers terminal traffic",
+		sql: `
+			ALTER TABLE callers ADD COLUMN term_sent INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE callers ADD COLUMN term_received INTEGER NOT NULL DEFAULT 0;
+		`,
+	},
+	{
+		name: "create file downloads log",
+		sql: `
+			CREATE TABLE IF NOT EXISTS file_downloads (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				file_id INTEGER NOT NULL REFERENCES file_entries(id) ON DELETE CASCADE,
+				downloaded_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_file_downloads_at ON file_downloads(downloaded_at);
+		`,
+	},
+	{
+		name: "create user profiles",
+		sql: `
+			CREATE TABLE IF NOT EXISTS user_profiles (
+				user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+				bio TEXT NOT NULL DEFAULT '',
+				signature TEXT NOT NULL DEFAULT '',
+				avatar TEXT NOT NULL DEFAULT '',
+				hide_real_name INTEGER NOT NULL DEFAULT 0,
+				hide_location INTEGER NOT NULL DEFAULT 0,
+				hide_last_seen INTEGER NOT NULL DEFAULT 0,
+				hide_stats INTEGER NOT NULL DEFAULT 0
+			);
+		`,
+	},
+	{
+		name: "create announcement bridge",
+		sql: `
+			CREATE TABLE IF NOT EXISTS announce_cursor (
+				area_id INTEGER PRIMARY KEY,
+				last_message_id INTEGER NOT NULL
+			);
+			CREATE TABLE IF NOT EXISTS announce_posts (
+				message_id INTEGER PRIMARY KEY,
+				url TEXT NOT NULL DEFAULT '',
+				posted_at DATETIME NOT NULL
+			);
+		`,
+	},
+	{
+		name: "create email gateway table",
+		sql: `
+			CREATE TABLE IF NOT EXISTS mail_imports (
+				message_id TEXT PRIMARY KEY,
+				sender TEXT NOT NULL,
+				imported_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_mail_imports_sender ON mail_imports(sender, imported_at);
+		`,
+	},
+	{
+		name: "add users charset",
+		sql: `
+			ALTER TABLE users ADD COLUMN charset TEXT NOT NULL DEFAULT 'utf8'
+		`,
+	},
+	{
+		name: "create security levels",
+		sql: `
+			CREATE TABLE IF NOT EXISTS security_levels (
+				level INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				color INTEGER NOT NULL DEFAULT 7,
+				time_limit INTEGER NOT NULL DEFAULT 0,
+				ratio INTEGER NOT NULL DEFAULT 0,
+				flags TEXT NOT NULL DEFAULT ''
+			);
+			INSERT OR IGNORE INTO security_levels (level, name, color, ratio) VALUES
+				(10, 'New', 7, 0),
+				(20, 'Validated', 15, 0),
+				(30, 'Regular', 14, 0),
+				(50, 'Trusted', 10, 0),
+				(90, 'CoSysop', 11, -1),
+				(100, 'Sysop', 12, -1);
+		`,
+	},
+	{
+		name: "add users account state",
+		sql: `
+			ALTER TABLE users ADD COLUMN state TEXT NOT NULL DEFAULT 'active';
+			ALTER TABLE users ADD COLUMN state_reason TEXT NOT NULL DEFAULT '';
+			ALTER TABLE users ADD COLUMN state_changed_at DATETIME;
+		`,
+	},
+	{
+		name: "add users do not disturb",
+		sql: `
+			ALTER TABLE users ADD COLUMN do_not_disturb INTEGER NOT NULL DEFAULT 0
+		`,
+	},
+	{
+		name: "add users file scan",
+		sql: `
+			ALTER TABLE users ADD COLUMN file_scan_at DATETIME
+		`,
+	},
+	{
+		name: "add message read pointer source",
+		sql: `
+			ALTER TABLE message_read ADD COLUMN updated_at DATETIME;
+			ALTER TABLE message_read ADD COLUMN source TEXT NOT NULL DEFAULT 'online';
+		`,
+	},
+	{
+		name: "add users palette",
+		sql: `
+			ALTER TABLE users ADD COLUMN palette TEXT NOT NULL DEFAULT 'auto'
+		`,
+	},
+	{
+		name: "create conferences",
+		sql: `
+			CREATE TABLE IF NOT EXISTS conferences (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE,
+				description TEXT NOT NULL DEFAULT '',
+				join_level INTEGER NOT NULL DEFAULT 10,
+				flags TEXT NOT NULL DEFAULT '',
+				sort_order INTEGER NOT NULL DEFAULT 0
+			);
+			INSERT OR IGNORE INTO conferences (id, name, description, join_level) VALUES
+				(1, 'Main Board', 'Open to every caller', 0);
+			CREATE TABLE IF NOT EXISTS conference_members (
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+				conference_id INTEGER NOT NULL REFERENCES conferences(id) ON DELETE CASCADE,
+				joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (user_id, conference_id)
+			);
+			ALTER TABLE message_areas ADD COLUMN conference_id INTEGER NOT NULL DEFAULT 1;
+			ALTER TABLE file_areas ADD COLUMN conference_id INTEGER NOT NULL DEFAULT 1;
+			ALTER TABLE users ADD COLUMN conference_id INTEGER NOT NULL DEFAULT 1;
+		`,
+	},
+	{
+		name: "add users colors",
+		sql: `
+			ALTER TABLE users ADD COLUMN colors TEXT NOT NULL DEFAULT 'auto'
+		`,
+	},
+	{
+		name: "create mail queue",
+		sql: `
+			CREATE TABLE IF NOT EXISTS mail_queue (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				to_addr TEXT NOT NULL,
+				subject TEXT NOT NULL,
+				body TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				last_error TEXT NOT NULL DEFAULT '',
+				next_try_at DATETIME NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS password_resets (
+				user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+				code_hash TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL,
+				expires_at DATETIME NOT NULL
+			);
+			ALTER TABLE users ADD COLUMN mail_notify INTEGER NOT NULL DEFAULT 0;
+		`,
+	},
+	{
+		name: "create chat history",
+		sql: `
+			CREATE TABLE IF NOT EXISTS chat_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				room TEXT NOT NULL,
+				from_user TEXT NOT NULL,
+				text TEXT NOT NULL,
+				created_at DATETIME NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_chat_history_room ON chat_history(room, id);
+			CREATE INDEX IF NOT EXISTS idx_chat_history_created ON chat_history(created_at);
+		`,
+	},
+	{
+		// A charset the caller never chose was stored as utf8, which made
+		// door output look chosen; "" now means unset.
+		name: "unset default users charset",
+		sql: `
+			UPDATE users SET charset = '' WHERE charset = 'utf8'
+		`,
+	},
 }
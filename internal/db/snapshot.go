@@ -0,0 +1,57 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// SchemaVersion is the number of migrations this build knows; a database
+// opened by it ends up at this version.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// Snapshot writes a consistent copy of the database at path to dst, which
+// must not exist yet, and returns the copy's schema version. It is safe
+// while the BBS has the database open.
+func Snapshot(path, dst string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("snapshot database: %w", err)
+	}
+	sqlDB, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, fmt.Errorf("open database %s: %w", path, err)
+	}
+	defer sqlDB.Close()
+	if _, err := sqlDB.Exec(`VACUUM INTO ?`, dst); err != nil {
+		return 0, fmt.Errorf("snapshot database: %w", err)
+	}
+	return schemaOf(sqlDB)
+}
+
+// Check runs SQLite's integrity check on the database at path and returns
+// its schema version.
+func Check(path string) (int, error) {
+	sqlDB, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, fmt.Errorf("open database %s: %w", path, err)
+	}
+	defer sqlDB.Close()
+	var result string
+	if err := sqlDB.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("check database: %w", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("check database: %s", result)
+	}
+	return schemaOf(sqlDB)
+}
+
+func schemaOf(sqlDB *sql.DB) (int, error) {
+	var version int
+	if err := sqlDB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
@@ -1,6 +1,14 @@
 package door
 
-import "github.com/notepid/twilight_bbs/internal/user"
+import (
+	"errors"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// ErrTimedOut is returned by Launch when a door runs past its time.
+var ErrTimedOut = errors.New("door timed out")
 
 // Config holds configuration for a single door.
 type Config struct {
@@ -8,12 +16,17 @@ type Config struct {
 	Name          string
 	Description   string
 	Command       string // DOS executable path (relative to drive C)
-	DropFileType  string // "DOOR.SYS" or "DORINFO1.DEF"
+	DropFileType  string // one of DropFileTypes, default "DOOR.SYS"
 	SecurityLevel int
 	// MultiUser controls whether multiple users may run this door concurrently.
 	// If false, the BBS will deny launching the door while it is already in use.
 	// Defaults to true when omitted in Lua config tables.
 	MultiUser bool
+	// Sandbox overrides the launcher's sandbox settings for this door.
+	Sandbox SandboxOptions
+	// DailyMinutes limits how long each user may play the door per day;
+	// 0 means no limit.
+	DailyMinutes int
 }
 
 // Session holds the context for a door session.
@@ -22,6 +35,8 @@ type Session struct {
 	User          *user.User
 	NodeID        int
 	TimeLeftMins  int
+	MaxRuntime    time.Duration // stops the door early when shorter than Launcher.Timeout
+	ExitStatus    string        // set by Launch when dosemu2 exits abnormally
 	ComPort       int // emulated COM port (1 for DOSEMU doors, 0 for local)
 	BaudRate      int
 	DropFilePath  string
@@ -29,4 +44,8 @@ type Session struct {
 	DriveCPath    string
 	TermWidth     int // terminal width (columns), 0 defaults to 80
 	TermHeight    int // terminal height (rows), 0 defaults to 25
+
+	// WatchSize follows the caller's window size while the door runs
+	// (see terminal.SizeProvider); nil keeps the starting size.
+	WatchSize func(fn func(width, height int)) (cancel func())
 }
@@ -15,6 +15,21 @@ type Launcher struct {
 	TempDir    string
 	Timeout    time.Duration // max door runtime; 0 defaults to 60 minutes
 
+	// DropFileTTL is how long after start the drop file is scrubbed;
+	// 0 keeps it until the door exits.
+	DropFileTTL time.Duration
+
+	// TranslateOutput converts door output from CP437 for callers whose
+	// terminal uses another charset; CP437 callers get it unchanged.
+	TranslateOutput bool
+
+	// Sandbox, when set, isolates every door (see Sandbox); doors may
+	// override parts of it. CgroupRoot is the cgroup v2 directory per-launch
+	// cgroups are created under, and OverlayDir holds per-node overlays.
+	Sandbox    *Sandbox
+	CgroupRoot string
+	OverlayDir string
+
 	mu    sync.Mutex
 	inUse map[string]int
 }
@@ -122,7 +137,7 @@ func validateDoorCommand(cmd string) error {
 // expandDoorCommand replaces placeholders in a door command string.
 //
 //	{NODE} → node number
-//	{DROP} → DOS path to the drop file directory (e.g. C:\BBS\DROP\NODE1)
+//	{DROP} → DOS path to the drop file directory (e.g. C:\NODES\N1XK4Q9Z)
 func expandDoorCommand(command string, nodeID int, dosDropDir string) string {
 	r := strings.NewReplacer(
 		"{NODE}", fmt.Sprintf("%d", nodeID),
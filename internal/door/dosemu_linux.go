@@ -71,8 +71,11 @@ func (l *Launcher) Launch(session *Session, stdin io.Reader, stdout io.Writer) e
 
 	// --- Session directory (holds .dosemu/dosemurc) ----------------------
 	tempDir := resolvePath(l.TempDir)
-	sessionDir := filepath.Join(tempDir, fmt.Sprintf("node%d", session.NodeID))
-	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return fmt.Errorf("create session dir: %w", err)
+	}
+	sessionDir, err := os.MkdirTemp(tempDir, fmt.Sprintf("node%d-", session.NodeID))
+	if err != nil {
 		return fmt.Errorf("create session dir: %w", err)
 	}
 	defer os.RemoveAll(sessionDir)
@@ -80,20 +83,40 @@ func (l *Launcher) Launch(session *Session, stdin io.Reader, stdout io.Writer) e
 	// Resolve drive C path
 	driveC := resolvePath(l.DriveCPath)
 
+	// --- Sandbox: the door sees a private overlay of drive C -------------
+	var sandbox Sandbox
+	if l.Sandbox != nil {
+		sandbox = l.Sandbox.With(session.DoorConfig.Sandbox)
+	}
+	if l.Sandbox != nil && sandbox.Overlay {
+		nodeDir := filepath.Join(resolvePath(l.OverlayDir), fmt.Sprintf("node%d", session.NodeID))
+		merged := filepath.Join(nodeDir, "drive_c")
+		unmount, err := mountOverlay(driveC, nodeDir, merged)
+		if err != nil {
+			return err
+		}
+		defer unmount()
+		driveC = merged
+	}
+
 	// DOSEMU's 'virtual' COM driver maps DOS COM1 to stdio.
 	session.ComPort = 1
 
-	// --- Write drop file into a DOS-visible directory on drive C ---------
-	dropDir := filepath.Join(driveC, "NODES", fmt.Sprintf("TEMP%d", session.NodeID))
+	// --- Write drop file into a fresh DOS-visible directory on drive C ----
+	dropDir, err := MakeDropDir(filepath.Join(driveC, "NODES"), session.NodeID)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dropDir)
 	dropPath, err := WriteDropFile(dropDir, session)
 	if err != nil {
 		return fmt.Errorf("write drop file: %w", err)
 	}
 	session.DropFilePath = dropPath
-	defer os.RemoveAll(dropDir)
+	defer ScrubDropFile(dropPath)
 
 	// --- Generate wrapper batch file ------------------------------------
-	dosDropDir := fmt.Sprintf(`C:\NODES\TEMP%d`, session.NodeID)
+	dosDropDir := `C:\NODES\` + filepath.Base(dropDir)
 	doorCmd := expandDoorCommand(session.DoorConfig.Command, session.NodeID, dosDropDir)
 
 	wrapperBat := filepath.Join(dropDir, "RUN.BAT")
@@ -106,14 +129,14 @@ func (l *Launcher) Launch(session *Session, stdin io.Reader, stdout io.Writer) e
 		"",
 	}
 	batContent := strings.Join(batLines, "\r\n")
-	if err := os.WriteFile(wrapperBat, []byte(batContent), 0644); err != nil {
+	if err := os.WriteFile(wrapperBat, []byte(batContent), dropFileMode); err != nil {
 		return fmt.Errorf("write wrapper bat: %w", err)
 	}
-	finalDoorCmd := fmt.Sprintf(`C:\NODES\TEMP%d\RUN.BAT`, session.NodeID)
+	finalDoorCmd := dosDropDir + `\RUN.BAT`
 
 	// --- Generate dosemurc ----------------------------------------------
 	dosemuLocalDir := filepath.Join(sessionDir, ".dosemu")
-	if err := os.MkdirAll(dosemuLocalDir, 0755); err != nil {
+	if err := os.MkdirAll(dosemuLocalDir, 0700); err != nil {
 		return fmt.Errorf("create dosemu local dir: %w", err)
 	}
 	dosemurcPath := filepath.Join(dosemuLocalDir, "dosemurc")
@@ -134,7 +157,7 @@ func (l *Launcher) Launch(session *Session, stdin io.Reader, stdout io.Writer) e
 		`$_lpt1 = ""`,
 		"",
 	}, "\n")
-	if err := os.WriteFile(dosemurcPath, []byte(dosemurc), 0644); err != nil {
+	if err := os.WriteFile(dosemurcPath, []byte(dosemurc), 0600); err != nil {
 		return fmt.Errorf("write dosemu rc: %w", err)
 	}
 
@@ -162,6 +185,9 @@ func (l *Launcher) Launch(session *Session, stdin io.Reader, stdout io.Writer) e
 	if timeout == 0 {
 		timeout = 60 * time.Minute
 	}
+	if session.MaxRuntime > 0 && session.MaxRuntime < timeout {
+		timeout = session.MaxRuntime
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
@@ -183,6 +209,17 @@ func (l *Launcher) Launch(session *Session, stdin io.Reader, stdout io.Writer) e
 		fmt.Sprintf("PATH=%s", os.Getenv("PATH")),
 	}
 
+	if l.Sandbox != nil {
+		if err := sandbox.chownTree(sessionDir, dropDir); err != nil {
+			return err
+		}
+		release, err := sandbox.apply(cmd, l.CgroupRoot, filepath.Base(sessionDir))
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
 	winSize := &pty.Winsize{
 		Rows: termH,
 		Cols: termW,
@@ -197,10 +234,34 @@ func (l *Launcher) Launch(session *Session, stdin io.Reader, stdout io.Writer) e
 
 	ptmx, err := pty.StartWithSize(cmd, winSize)
 	if err != nil {
+		if l.Sandbox != nil {
+			return fmt.Errorf("pty start dosemu in sandbox (namespaces may be unavailable here): %w", err)
+		}
 		return fmt.Errorf("pty start dosemu: %w", err)
 	}
 	defer ptmx.Close()
 
+	// Pass the caller's window changes on to the door.
+	if session.WatchSize != nil {
+		defer session.WatchSize(func(width, height int) {
+			ws := &pty.Winsize{Rows: uint16(height), Cols: uint16(width)}
+			if err := pty.Setsize(ptmx, ws); err != nil {
+				log.Printf("[door] Node %d: resize pty: %v", session.NodeID, err)
+			}
+		})()
+	}
+
+	// Doors read the drop file at startup; don't leave it on the shared
+	// drive for the rest of the session.
+	if l.DropFileTTL > 0 {
+		scrub := time.AfterFunc(l.DropFileTTL, func() {
+			if err := ScrubDropFile(dropPath); err != nil {
+				log.Printf("[door] Node %d: scrub drop file: %v", session.NodeID, err)
+			}
+		})
+		defer scrub.Stop()
+	}
+
 	// --- Bridge I/O: BBS terminal <-> PTY master ------------------------
 	//
 	// Two goroutines bridge the data:
@@ -279,11 +340,12 @@ func (l *Launcher) Launch(session *Session, stdin io.Reader, stdout io.Writer) e
 
 	if waitErr != nil && ctx.Err() == context.DeadlineExceeded {
 		log.Printf("[door] Node %d: door timed out after %v", session.NodeID, timeout)
-		return fmt.Errorf("door timed out after %v", timeout)
+		return fmt.Errorf("%w after %v", ErrTimedOut, timeout)
 	}
 
 	if waitErr != nil {
 		log.Printf("[door] Node %d: dosemu2 exited with: %v", session.NodeID, waitErr)
+		session.ExitStatus = waitErr.Error()
 	}
 
 	log.Printf("[door] Node %d: door session ended", session.NodeID)
@@ -1,18 +1,53 @@
 package door
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// Drop files carry the caller's name, location and security level, so they
+// are written owner-only into a fresh, unguessable directory per launch and
+// scrubbed once the door has had time to read them. Passwords and other
+// credentials are never written.
+const (
+	dropDirMode  = 0700
+	dropFileMode = 0600
 )
 
+// Names written where a format asks for the board and its sysop.
+const (
+	bbsName   = "Twilight BBS"
+	sysopName = "Sysop"
+)
+
+// DropFileTypes are the drop file formats WriteDropFile can write.
+var DropFileTypes = []string{"DOOR.SYS", "DORINFO1.DEF", "CHAIN.TXT", "DOORFILE.SR", "SFDOORS.DAT", "PCBOARD.SYS"}
+
+// ValidDropFileType reports whether name is one of DropFileTypes, in any
+// case.
+func ValidDropFileType(name string) bool {
+	for _, t := range DropFileTypes {
+		if strings.EqualFold(name, t) {
+			return true
+		}
+	}
+	return false
+}
+
 // WriteDoorSys generates a DOOR.SYS drop file.
 // DOOR.SYS is the most widely supported drop file format.
 func WriteDoorSys(dir string, s *Session) (string, error) {
-	path := filepath.Join(dir, "DOOR.SYS")
-
 	u := s.User
 	now := time.Now()
 
@@ -27,8 +62,8 @@ func WriteDoorSys(dir string, s *Session) (string, error) {
 		"Y",                                         // 7: printer toggle
 		"Y",                                         // 8: page bell
 		"Y",                                         // 9: caller alarm
-		u.Username,                                  // 10: user name
-		u.Location,                                  // 11: calling from
+		dropField(u.Username),                       // 10: user name
+		dropField(u.Location),                       // 11: calling from
 		"",                                          // 12: home phone
 		"",                                          // 13: work phone
 		"",                                          // 14: password (never sent)
@@ -64,43 +99,26 @@ func WriteDoorSys(dir string, s *Session) (string, error) {
 	}
 
 	content := strings.Join(lines, "\r\n") + "\r\n"
-
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("create drop file dir: %w", err)
-	}
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-		return "", fmt.Errorf("write DOOR.SYS: %w", err)
-	}
-
-	return path, nil
+	return writeDropFile(dir, "DOOR.SYS", content)
 }
 
 // WriteDorInfo generates a DORINFO1.DEF drop file.
 func WriteDorInfo(dir string, s *Session) (string, error) {
 	filename := fmt.Sprintf("DORINFO%d.DEF", s.NodeID)
-	path := filepath.Join(dir, filename)
 
 	u := s.User
-	parts := strings.SplitN(u.RealName, " ", 2)
-	firstName := u.Username
-	lastName := ""
-	if len(parts) >= 1 && parts[0] != "" {
-		firstName = parts[0]
-	}
-	if len(parts) >= 2 {
-		lastName = parts[1]
-	}
+	firstName, lastName := userNames(u)
 
 	lines := []string{
-		"Twilight BBS",                         // 1: BBS name
-		"Sysop",                                // 2: sysop first name
+		bbsName,                                // 1: BBS name
+		sysopName,                              // 2: sysop first name
 		"",                                     // 3: sysop last name
 		fmt.Sprintf("COM%d", s.ComPort),       // 4: COM port
 		fmt.Sprintf("%d BAUD,N,8,1", s.BaudRate), // 5: baud rate
 		"0",                                    // 6: network type
 		firstName,                              // 7: user first name
 		lastName,                               // 8: user last name
-		u.Location,                             // 9: user location
+		dropField(u.Location),                  // 9: user location
 		"1",                                    // 10: ANSI mode (0=no, 1=yes)
 		fmt.Sprintf("%d", u.SecurityLevel),    // 11: security level
 		fmt.Sprintf("%d", s.TimeLeftMins),     // 12: minutes remaining
@@ -108,27 +126,219 @@ func WriteDorInfo(dir string, s *Session) (string, error) {
 	}
 
 	content := strings.Join(lines, "\r\n") + "\r\n"
-
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("create drop file dir: %w", err)
-	}
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-		return "", fmt.Errorf("write %s: %w", filename, err)
-	}
-
-	return path, nil
+	return writeDropFile(dir, filename, content)
 }
 
 // WriteDropFile writes the appropriate drop file based on the session config.
 func WriteDropFile(dir string, s *Session) (string, error) {
-	switch s.DoorConfig.DropFileType {
+	switch strings.ToUpper(s.DoorConfig.DropFileType) {
 	case "DORINFO1.DEF":
 		return WriteDorInfo(dir, s)
+	case "CHAIN.TXT":
+		return WriteChainTxt(dir, s)
+	case "DOORFILE.SR":
+		return WriteDoorFileSR(dir, s)
+	case "SFDOORS.DAT":
+		return WriteSFDoors(dir, s)
+	case "PCBOARD.SYS":
+		return WritePCBoardSys(dir, s)
 	default:
 		return WriteDoorSys(dir, s)
 	}
 }
 
+// WriteChainTxt generates a WWIV CHAIN.TXT drop file.
+func WriteChainTxt(dir string, s *Session) (string, error) {
+	u := s.User
+	now := time.Now()
+	lastCall := now
+	if u.PreviousCallAt != nil {
+		lastCall = *u.PreviousCallAt
+	}
+	sysop, coSysop := "0", "0"
+	if u.SecurityLevel >= user.LevelSysop {
+		sysop = "1"
+	}
+	if u.SecurityLevel >= user.LevelCoSysop {
+		coSysop = "1"
+	}
+
+	lines := []string{
+		fmt.Sprintf("%d", u.ID),            // 1: user number
+		dropField(u.Username),              // 2: alias
+		realName(u),                        // 3: real name
+		"",                                 // 4: callsign
+		"0",                                // 5: age (unknown)
+		"M",                                // 6: sex (unknown)
+		"0.00",                             // 7: gold
+		lastCall.Format("01/02/06"),        // 8: last logon date
+		fmt.Sprintf("%d", screenWidth(s)),  // 9: screen width
+		fmt.Sprintf("%d", screenHeight(s)), // 10: screen height
+		fmt.Sprintf("%d", u.SecurityLevel), // 11: security level
+		coSysop,                            // 12: co-sysop
+		sysop,                              // 13: sysop
+		"1",                                // 14: ANSI
+		"1",                                // 15: remote
+		fmt.Sprintf("%.2f", float64(s.TimeLeftMins*60)), // 16: seconds remaining
+		`C:\`,                         // 17: gfiles path
+		`C:\`,                         // 18: data path
+		now.Format("010206") + ".LOG", // 19: system log name
+		fmt.Sprintf("%d", s.BaudRate), // 20: baud rate
+		fmt.Sprintf("%d", s.ComPort),  // 21: COM port
+		bbsName,                       // 22: system name
+		sysopName,                     // 23: sysop name
+		fmt.Sprintf("%d", secondsSinceMidnight(now)), // 24: logon time, seconds since midnight
+		"0",                                     // 25: seconds used
+		fmt.Sprintf("%d", u.BytesUploaded/1024), // 26: uploaded K
+		"0",                                     // 27: uploads
+		fmt.Sprintf("%d", u.BytesDownloaded/1024), // 28: downloaded K
+		"0",                           // 29: downloads
+		"8N1",                         // 30: parity
+		fmt.Sprintf("%d", s.BaudRate), // 31: COM port speed
+		fmt.Sprintf("%d", s.NodeID),   // 32: node number
+	}
+
+	content := strings.Join(lines, "\r\n") + "\r\n"
+	return writeDropFile(dir, "CHAIN.TXT", content)
+}
+
+// WriteDoorFileSR generates a Solar Realms DOORFILE.SR drop file.
+func WriteDoorFileSR(dir string, s *Session) (string, error) {
+	u := s.User
+
+	lines := []string{
+		dropField(u.Username),              // 1: name or handle
+		"1",                                // 2: ANSI
+		"1",                                // 3: IBM graphics
+		fmt.Sprintf("%d", screenHeight(s)), // 4: screen length
+		fmt.Sprintf("%d", s.BaudRate),      // 5: baud rate, 0 = local
+		fmt.Sprintf("%d", s.ComPort),       // 6: COM port, 0 = local
+		fmt.Sprintf("%d", s.TimeLeftMins),  // 7: minutes remaining
+		realName(u),                        // 8: real name
+	}
+
+	content := strings.Join(lines, "\r\n") + "\r\n"
+	return writeDropFile(dir, "DOORFILE.SR", content)
+}
+
+// WriteSFDoors generates a Spitfire SFDOORS.DAT drop file.
+func WriteSFDoors(dir string, s *Session) (string, error) {
+	u := s.User
+	now := time.Now()
+	firstName, _ := userNames(u)
+
+	lines := []string{
+		fmt.Sprintf("%d", u.ID),           // 1: user number
+		dropField(u.Username),             // 2: user name
+		"",                                // 3: password (never sent)
+		firstName,                         // 4: first name
+		fmt.Sprintf("%d", s.BaudRate),     // 5: baud rate
+		fmt.Sprintf("%d", s.ComPort),      // 6: COM port
+		fmt.Sprintf("%d", s.TimeLeftMins), // 7: minutes remaining
+		fmt.Sprintf("%d", secondsSinceMidnight(now)), // 8: seconds since midnight
+		`C:\`,                              // 9: Spitfire directory
+		"TRUE",                             // 10: ANSI
+		fmt.Sprintf("%d", u.SecurityLevel), // 11: security level
+		"0",                                // 12: uploads
+		"0",                                // 13: downloads
+		fmt.Sprintf("%d", s.TimeLeftMins),  // 14: minutes allowed today
+		fmt.Sprintf("%d", secondsSinceMidnight(now)), // 15: logon time, seconds since midnight
+		"0",                         // 16: extra time
+		"FALSE",                     // 17: sysop next
+		"FALSE",                     // 18: from front end
+		"TRUE",                      // 19: multinode
+		fmt.Sprintf("%d", s.NodeID), // 20: node number
+		"TRUE",                      // 21: use FOSSIL
+	}
+
+	content := strings.Join(lines, "\r\n") + "\r\n"
+	return writeDropFile(dir, "SFDOORS.DAT", content)
+}
+
+// pcboardSysSize is the length of a PCBoard 14 PCBOARD.SYS record.
+const pcboardSysSize = 128
+
+// WritePCBoardSys generates a PCBoard 14 PCBOARD.SYS drop file, a
+// fixed-layout binary record.
+func WritePCBoardSys(dir string, s *Session) (string, error) {
+	u := s.User
+	now := time.Now()
+	firstName, _ := userNames(u)
+	// Speeds are five characters wide, which was enough in PCBoard's day.
+	speed := fmt.Sprintf("%-5d", min(s.BaudRate, 38400))
+	le := binary.LittleEndian
+
+	b := bytes.Repeat([]byte{' '}, pcboardSysSize)
+	put := func(off int, v string) { copy(b[off:], v) }
+	putPadded := func(off, n int, v string) { copy(b[off:off+n], fmt.Sprintf("%-*.*s", n, n, v)) }
+
+	put(0, "-1")                                               // display on
+	put(2, " 0")                                               // printer off
+	put(4, " 0")                                               // page bell off
+	put(6, " 0")                                               // caller alarm off
+	put(8, " ")                                                // sysop flag
+	put(9, "-1")                                               // error corrected
+	put(11, "Y")                                               // graphics mode
+	put(12, "U")                                               // node chat unavailable
+	put(13, speed)                                             // DTE speed
+	put(18, speed)                                             // connect speed
+	le.PutUint16(b[23:], uint16(u.ID))                         // user record number
+	putPadded(25, 15, firstName)                               // first name
+	putPadded(40, 12, "")                                      // password (never sent)
+	le.PutUint16(b[52:], uint16(secondsSinceMidnight(now)/60)) // logon time, minutes since midnight
+	le.PutUint16(b[54:], 0)                                    // minutes used today, negated
+	put(56, now.Format("15:04"))                               // logon time
+	le.PutUint16(b[61:], uint16(s.TimeLeftMins))               // minutes allowed
+	le.PutUint16(b[63:], 0)                                    // download K allowed
+	b[65] = 0                                                  // conference
+	copy(b[66:76], make([]byte, 10))                           // conferences joined and scanned
+	le.PutUint16(b[76:], 0)                                    // conference add time
+	le.PutUint16(b[78:], 0)                                    // credit minutes
+	put(80, "    ")                                            // language extension
+	putPadded(84, 25, realName(u))                             // full name
+	le.PutUint16(b[109:], uint16(s.TimeLeftMins))              // minutes remaining
+	b[111] = byte(s.NodeID)                                    // node number
+	put(112, "00:00")                                          // event time
+	put(117, " 0")                                             // event inactive
+	put(119, "  ")                                             // reserved
+	copy(b[121:125], make([]byte, 4))                          // memorized message number
+	b[125] = byte('0' + s.ComPort%10)                          // COM port
+	b[126] = ' '                                               // reserved
+	b[127] = '1'                                               // ANSI
+
+	return writeDropFile(dir, "PCBOARD.SYS", string(b))
+}
+
+// userNames returns the caller's first and last name from the real name,
+// falling back to the user name.
+func userNames(u *user.User) (first, last string) {
+	first, last, _ = strings.Cut(strings.TrimSpace(dropField(u.RealName)), " ")
+	if first == "" {
+		first = dropField(u.Username)
+	}
+	return first, strings.TrimSpace(last)
+}
+
+// realName returns the caller's real name, or the user name when not given.
+func realName(u *user.User) string {
+	if name := strings.TrimSpace(dropField(u.RealName)); name != "" {
+		return name
+	}
+	return dropField(u.Username)
+}
+
+func secondsSinceMidnight(t time.Time) int {
+	return t.Hour()*3600 + t.Minute()*60 + t.Second()
+}
+
+// screenWidth returns the session's terminal width, defaulting to 80.
+func screenWidth(s *Session) int {
+	if s.TermWidth > 0 {
+		return s.TermWidth
+	}
+	return 80
+}
+
 // screenHeight returns the session's terminal height, defaulting to 25.
 func screenHeight(s *Session) int {
 	if s.TermHeight > 0 {
@@ -136,3 +346,98 @@ func screenHeight(s *Session) int {
 	}
 	return 25
 }
+
+// dropDirChars are used for the random part of drop directory names, which
+// must stay valid 8.3 DOS names.
+const dropDirChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// MakeDropDir creates a new drop file directory for a node under
+// nodesDir (drive C's NODES directory). The name is "N<node>X" padded to
+// eight characters with random letters and digits, e.g. "N1XK4Q9Z", so
+// the higher the node number the shorter the random part.
+func MakeDropDir(nodesDir string, nodeID int) (string, error) {
+	prefix := fmt.Sprintf("N%dX", nodeID)
+	n := 8 - len(prefix)
+	if n < 1 {
+		return "", fmt.Errorf("create drop file dir: node %d does not fit an 8.3 name", nodeID)
+	}
+	if err := os.MkdirAll(nodesDir, 0755); err != nil {
+		return "", fmt.Errorf("create drop file dir: %w", err)
+	}
+	for range 10 {
+		b := make([]byte, n)
+		rand.Read(b)
+		for i := range b {
+			b[i] = dropDirChars[int(b[i])%len(dropDirChars)]
+		}
+		dir := filepath.Join(nodesDir, prefix+string(b))
+		err := os.Mkdir(dir, dropDirMode)
+		if err == nil {
+			return dir, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("create drop file dir: %w", err)
+		}
+	}
+	return "", errors.New("create drop file dir: no free name")
+}
+
+// NodeOfTempDir returns the node a door temp entry belongs to, from its
+// name: "N<node>X..." under drive C's NODES, "node<node>-..." under the
+// door temp directory, or the older fixed "TEMP<node>" and "node<node>".
+func NodeOfTempDir(name string) (int, bool) {
+	var digits string
+	switch {
+	case strings.HasPrefix(name, "node"):
+		digits, _, _ = strings.Cut(name[len("node"):], "-")
+	case strings.HasPrefix(name, "TEMP"):
+		digits = name[len("TEMP"):]
+	case strings.HasPrefix(name, "N"):
+		digits, _, _ = strings.Cut(name[len("N"):], "X")
+	}
+	id, err := strconv.Atoi(digits)
+	return id, err == nil
+}
+
+// ScrubDropFile overwrites a drop file with zeros and removes it. Doors
+// read their drop file at startup, so it is not left on the shared drive
+// for the whole session.
+func ScrubDropFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if fi, err := f.Stat(); err == nil {
+		f.Write(make([]byte, fi.Size()))
+		f.Sync()
+	}
+	f.Close()
+	return os.Remove(path)
+}
+
+// dropField makes a caller-supplied value safe for one drop file line.
+// A control character could start a new line and shift every later field,
+// such as the security level.
+func dropField(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// writeDropFile writes content to dir/name, owner-only.
+func writeDropFile(dir, name, content string) (string, error) {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(dir, dropDirMode); err != nil {
+		return "", fmt.Errorf("create drop file dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), dropFileMode); err != nil {
+		return "", fmt.Errorf("write %s: %w", name, err)
+	}
+	return path, nil
+}
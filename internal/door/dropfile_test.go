@@ -0,0 +1,174 @@
+package door
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+func TestDropFileHardening(t *testing.T) {
+	nodes := filepath.Join(t.TempDir(), "NODES")
+	dir, err := MakeDropDir(nodes, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Base(dir)
+	if len(name) != 8 || !strings.HasPrefix(name, "N3X") || strings.ToUpper(name) != name {
+		t.Errorf("drop dir name %q is not an 8.3 N3X... name", name)
+	}
+	if id, ok := NodeOfTempDir(name); !ok || id != 3 {
+		t.Errorf("NodeOfTempDir(%q) = %d, %v", name, id, ok)
+	}
+	if other, _ := MakeDropDir(nodes, 3); other == dir {
+		t.Error("drop dir reused")
+	}
+	for _, node := range []int{100, 12345} {
+		dir, err := MakeDropDir(nodes, node)
+		if name := filepath.Base(dir); err != nil || len(name) != 8 {
+			t.Errorf("node %d: drop dir %q, %v", node, name, err)
+		} else if id, ok := NodeOfTempDir(name); !ok || id != node {
+			t.Errorf("NodeOfTempDir(%q) = %d, %v", name, id, ok)
+		}
+	}
+	if _, err := MakeDropDir(nodes, 1234567); err == nil {
+		t.Error("drop dir made for a node too high for 8.3")
+	}
+
+	s := &Session{
+		DoorConfig: &Config{DropFileType: "DOOR.SYS"},
+		User: &user.User{ID: 7, Username: "mallory", Location: "Oslo\r\n255",
+			PasswordHash: "$2a$12$secrethashsecrethashsecrethash", SecurityLevel: 10},
+		NodeID: 3,
+	}
+	path, err := WriteDropFile(dir, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("drop file mode %v", fi.Mode().Perm())
+	}
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(string(data), "\r\n")
+	if lines[10] != "Oslo255" || lines[14] != "10" {
+		t.Errorf("location %q, level %q: injected line shifted fields", lines[10], lines[14])
+	}
+	if strings.Contains(string(data), "secrethash") {
+		t.Error("password hash written to drop file")
+	}
+
+	if err := ScrubDropFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("drop file still present: %v", err)
+	}
+	if err := ScrubDropFile(path); err != nil {
+		t.Errorf("scrubbing a removed file: %v", err)
+	}
+
+	for name, want := range map[string]int{"node12-4711": 12, "node2": 2, "TEMP5": 5} {
+		if id, ok := NodeOfTempDir(name); !ok || id != want {
+			t.Errorf("NodeOfTempDir(%q) = %d, %v", name, id, ok)
+		}
+	}
+	if _, ok := NodeOfTempDir("README"); ok {
+		t.Error("NodeOfTempDir matched an unrelated name")
+	}
+}
+
+func TestDropFileFormats(t *testing.T) {
+	dir := t.TempDir()
+	s := &Session{
+		DoorConfig: &Config{},
+		User: &user.User{ID: 7, Username: "nightowl", RealName: "Ada Lovelace", Location: "London",
+			SecurityLevel: 90, BytesUploaded: 4096},
+		NodeID:       3,
+		TimeLeftMins: 45,
+		ComPort:      1,
+		BaudRate:     115200,
+		TermWidth:    100,
+	}
+	lines := func(dropType string) []string {
+		t.Helper()
+		s.DoorConfig.DropFileType = dropType
+		path, err := WriteDropFile(dir, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		return strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n")
+	}
+
+	chain := lines("chain.txt")
+	if len(chain) != 32 {
+		t.Fatalf("CHAIN.TXT has %d lines, want 32", len(chain))
+	}
+	for i, want := range map[int]string{1: "7", 2: "nightowl", 3: "Ada Lovelace", 9: "100", 10: "25", 11: "90",
+		12: "1", 13: "0", 16: "2700.00", 20: "115200", 21: "1", 26: "4", 32: "3"} {
+		if chain[i-1] != want {
+			t.Errorf("CHAIN.TXT line %d = %q, want %q", i, chain[i-1], want)
+		}
+	}
+
+	sr := lines("DOORFILE.SR")
+	if want := []string{"nightowl", "1", "1", "25", "115200", "1", "45", "Ada Lovelace"}; strings.Join(sr, "|") != strings.Join(want, "|") {
+		t.Errorf("DOORFILE.SR = %q, want %q", sr, want)
+	}
+
+	sf := lines("SFDOORS.DAT")
+	if len(sf) != 21 {
+		t.Fatalf("SFDOORS.DAT has %d lines, want 21", len(sf))
+	}
+	for i, want := range map[int]string{1: "7", 2: "nightowl", 3: "", 4: "Ada", 5: "115200", 6: "1", 7: "45",
+		10: "TRUE", 11: "90", 20: "3"} {
+		if sf[i-1] != want {
+			t.Errorf("SFDOORS.DAT line %d = %q, want %q", i, sf[i-1], want)
+		}
+	}
+
+	s.DoorConfig.DropFileType = "PCBOARD.SYS"
+	path, err := WriteDropFile(dir, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcb, _ := os.ReadFile(path)
+	if len(pcb) != 128 {
+		t.Fatalf("PCBOARD.SYS is %d bytes, want 128", len(pcb))
+	}
+	for _, f := range []struct {
+		off  int
+		want string
+	}{
+		{0, "-1"}, {11, "Y"}, {13, "38400"}, {18, "38400"}, {25, "Ada            "},
+		{40, "            "}, {84, "Ada Lovelace             "}, {125, "1"},
+	} {
+		if got := string(pcb[f.off : f.off+len(f.want)]); got != f.want {
+			t.Errorf("PCBOARD.SYS at %d = %q, want %q", f.off, got, f.want)
+		}
+	}
+	if id := int(pcb[23]) | int(pcb[24])<<8; id != 7 {
+		t.Errorf("PCBOARD.SYS user record %d, want 7", id)
+	}
+	if mins := int(pcb[109]) | int(pcb[110])<<8; mins != 45 || pcb[111] != 3 {
+		t.Errorf("PCBOARD.SYS minutes left %d, node %d", mins, pcb[111])
+	}
+
+	if dor := lines("DORINFO1.DEF"); dor[6] != "Ada" || dor[7] != "Lovelace" {
+		t.Errorf("DORINFO names %q %q", dor[6], dor[7])
+	}
+	for _, name := range []string{"pcboard.sys", "DOOR.SYS", "Chain.Txt"} {
+		if !ValidDropFileType(name) {
+			t.Errorf("ValidDropFileType(%q) = false", name)
+		}
+	}
+	if ValidDropFileType("EXITINFO.BBS") {
+		t.Error("ValidDropFileType(EXITINFO.BBS) = true")
+	}
+}
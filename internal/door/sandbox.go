@@ -0,0 +1,47 @@
+package door
+
+// Sandbox restricts what a door process can reach. It is applied when the
+// launcher has one configured; each door can override parts of it.
+type Sandbox struct {
+	UID, GID   int  // run the door as this user and group when > 0 (the BBS must run as root)
+	Network    bool // allow network access; otherwise the door gets an empty network namespace
+	Overlay    bool // give each node a private writable overlay of drive C (needs root)
+	MemoryMB   int  // cgroup memory limit, 0 = unlimited
+	CPUPercent int  // cgroup CPU limit in percent of one CPU, 0 = unlimited
+	MaxPids    int  // cgroup process limit, 0 = unlimited
+}
+
+// SandboxOptions are per-door overrides of the launcher's sandbox; nil
+// fields keep the launcher default.
+type SandboxOptions struct {
+	Network    *bool
+	Overlay    *bool
+	MemoryMB   *int
+	CPUPercent *int
+	MaxPids    *int
+}
+
+// With returns s with the overrides in o applied.
+func (s Sandbox) With(o SandboxOptions) Sandbox {
+	if o.Network != nil {
+		s.Network = *o.Network
+	}
+	if o.Overlay != nil {
+		s.Overlay = *o.Overlay
+	}
+	if o.MemoryMB != nil {
+		s.MemoryMB = *o.MemoryMB
+	}
+	if o.CPUPercent != nil {
+		s.CPUPercent = *o.CPUPercent
+	}
+	if o.MaxPids != nil {
+		s.MaxPids = *o.MaxPids
+	}
+	return s
+}
+
+// limited reports whether any cgroup limit is set.
+func (s Sandbox) limited() bool {
+	return s.MemoryMB > 0 || s.CPUPercent > 0 || s.MaxPids > 0
+}
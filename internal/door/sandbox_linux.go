@@ -0,0 +1,145 @@
+//go:build linux
+
+package door
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// cgroupControllers are enabled for the sandbox's cgroup children.
+const cgroupControllers = "+memory +cpu +pids"
+
+// cpuPeriod is the cgroup cpu.max period in microseconds.
+const cpuPeriod = 100000
+
+// apply sets up cmd to run inside the sandbox: credentials, namespaces and
+// a per-launch cgroup under cgroupRoot. Limits are skipped with a log line
+// when cgroups are not available. The returned cleanup must be called
+// after the process has exited.
+func (s Sandbox) apply(cmd *exec.Cmd, cgroupRoot, name string) (func(), error) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	root := os.Geteuid() == 0
+
+	if s.UID > 0 {
+		if !root {
+			return nil, errors.New("door sandbox: uid needs the BBS to run as root")
+		}
+		attr.Credential = &syscall.Credential{Uid: uint32(s.UID), Gid: uint32(s.GID), Groups: []uint32{}}
+	}
+	if !s.Network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+		if !root {
+			// Unprivileged: a user namespace mapping only our own ids
+			// lets us create the network namespace.
+			attr.Cloneflags |= syscall.CLONE_NEWUSER
+			attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+			attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+		}
+	}
+
+	if !s.limited() || cgroupRoot == "" {
+		return func() {}, nil
+	}
+	dir, err := s.makeCgroup(cgroupRoot, name)
+	if err != nil {
+		log.Printf("[door] cgroup limits unavailable, running without: %v", err)
+		return func() {}, nil
+	}
+	fd, err := syscall.Open(dir, syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		os.Remove(dir)
+		log.Printf("[door] cgroup limits unavailable, running without: %v", err)
+		return func() {}, nil
+	}
+	attr.UseCgroupFD = true
+	attr.CgroupFD = fd
+	return func() {
+		syscall.Close(fd)
+		if err := os.Remove(dir); err != nil {
+			log.Printf("[door] remove cgroup %s: %v", dir, err)
+		}
+	}, nil
+}
+
+// makeCgroup creates cgroupRoot/name with the sandbox's limits.
+func (s Sandbox) makeCgroup(cgroupRoot, name string) (string, error) {
+	if err := os.MkdirAll(cgroupRoot, 0755); err != nil {
+		return "", err
+	}
+	// Best effort: the controllers may already be enabled, or be managed
+	// by whoever delegated cgroupRoot to us.
+	os.WriteFile(filepath.Join(cgroupRoot, "cgroup.subtree_control"), []byte(cgroupControllers), 0)
+
+	dir := filepath.Join(cgroupRoot, name)
+	if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", err
+	}
+	limits := map[string]string{}
+	if s.MemoryMB > 0 {
+		limits["memory.max"] = strconv.Itoa(s.MemoryMB << 20)
+	}
+	if s.CPUPercent > 0 {
+		limits["cpu.max"] = fmt.Sprintf("%d %d", s.CPUPercent*cpuPeriod/100, cpuPeriod)
+	}
+	if s.MaxPids > 0 {
+		limits["pids.max"] = strconv.Itoa(s.MaxPids)
+	}
+	for file, v := range limits {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(v), 0); err != nil {
+			os.Remove(dir)
+			return "", fmt.Errorf("set %s: %w", file, err)
+		}
+	}
+	return dir, nil
+}
+
+// mountOverlay mounts a writable overlay of driveC at merged, keeping the
+// node's changes in upperDir. The returned cleanup unmounts it.
+func mountOverlay(driveC, upperDir, merged string) (func(), error) {
+	upper := filepath.Join(upperDir, "upper")
+	work := filepath.Join(upperDir, "work")
+	for _, d := range []string{upper, work, merged} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("door overlay: %w", err)
+		}
+	}
+	opts := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", driveC, upper, work)
+	if err := syscall.Mount("overlay", merged, "overlay", 0, opts); err != nil {
+		return nil, fmt.Errorf("door overlay (needs root): %w", err)
+	}
+	return func() {
+		if err := syscall.Unmount(merged, syscall.MNT_DETACH); err != nil {
+			log.Printf("[door] unmount overlay %s: %v", merged, err)
+		}
+	}, nil
+}
+
+// chownTree hands the session's files to the sandbox user so the door can
+// read its drop file and dosemu its config.
+func (s Sandbox) chownTree(paths ...string) error {
+	if s.UID <= 0 {
+		return nil
+	}
+	for _, p := range paths {
+		err := filepath.Walk(p, func(path string, _ os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Lchown(path, s.UID, s.GID)
+		})
+		if err != nil {
+			return fmt.Errorf("door sandbox: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package door
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSandbox(t *testing.T) {
+	base := Sandbox{MemoryMB: 256, CPUPercent: 50, MaxPids: 32}
+	on, limit := true, 0
+	s := base.With(SandboxOptions{Network: &on, MaxPids: &limit})
+	if !s.Network || s.MaxPids != 0 || s.MemoryMB != 256 {
+		t.Errorf("With = %+v", s)
+	}
+
+	cmd := exec.Command("true")
+	release, err := base.apply(cmd, "", "node1-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if cmd.SysProcAttr.Cloneflags&syscall.CLONE_NEWNET == 0 || cmd.SysProcAttr.UseCgroupFD {
+		t.Errorf("attrs = %+v", cmd.SysProcAttr)
+	}
+
+	// A plain directory stands in for cgroupfs to check the limit files.
+	root := t.TempDir()
+	dir, err := base.makeCgroup(root, "node1-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{"memory.max": "268435456", "cpu.max": "50000 100000", "pids.max": "32"} {
+		if got, _ := os.ReadFile(filepath.Join(dir, file)); string(got) != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+}
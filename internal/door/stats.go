@@ -0,0 +1,123 @@
+package door
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// Play is one door launch, recorded when the door exits.
+type Play struct {
+	ID         int
+	Door       string
+	UserID     int
+	Username   string
+	NodeID     int
+	StartedAt  time.Time
+	EndedAt    time.Time
+	ExitStatus string // "ok", "timeout" or the launch error
+}
+
+// Duration is how long the door ran.
+func (p *Play) Duration() time.Duration {
+	return p.EndedAt.Sub(p.StartedAt)
+}
+
+// Usage sums the launches of one door.
+type Usage struct {
+	Door     string
+	Launches int
+	Players  int // distinct users
+	Seconds  int64
+}
+
+// StatsRepo records door launches and answers usage queries.
+type StatsRepo struct {
+	db *sql.DB
+}
+
+// NewStatsRepo creates a new door statistics repository.
+func NewStatsRepo(db *sql.DB) *StatsRepo {
+	return &StatsRepo{db: db}
+}
+
+// Record writes a finished door launch.
+func (r *StatsRepo) Record(p *Play) error {
+	var userID interface{}
+	if p.UserID > 0 {
+		userID = p.UserID
+	}
+	result, err := r.db.Exec(`
+		INSERT INTO door_sessions (door, user_id, username, node_id, started_at, ended_at, seconds, exit_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.Door, userID, p.Username, p.NodeID,
+		p.StartedAt.UTC().Format(db.SQLiteTime), p.EndedAt.UTC().Format(db.SQLiteTime),
+		int64(p.Duration()/time.Second), p.ExitStatus)
+	if err != nil {
+		return fmt.Errorf("record door session: %w", err)
+	}
+	id, _ := result.LastInsertId()
+	p.ID = int(id)
+	return nil
+}
+
+// TopDoors returns the most played doors since the given time, by launches
+// and then by time played.
+func (r *StatsRepo) TopDoors(since time.Time, limit int) ([]Usage, error) {
+	return r.usage(`
+		SELECT door, COUNT(*), COUNT(DISTINCT user_id), COALESCE(SUM(seconds), 0)
+		FROM door_sessions WHERE started_at >= ?
+		GROUP BY door COLLATE NOCASE ORDER BY COUNT(*) DESC, SUM(seconds) DESC LIMIT ?
+	`, since.UTC().Format(db.SQLiteTime), limit)
+}
+
+// UserPlaytime returns one user's time in each door since the given time,
+// longest first.
+func (r *StatsRepo) UserPlaytime(userID int, since time.Time) ([]Usage, error) {
+	return r.usage(`
+		SELECT door, COUNT(*), 1, COALESCE(SUM(seconds), 0)
+		FROM door_sessions WHERE user_id = ? AND started_at >= ?
+		GROUP BY door COLLATE NOCASE ORDER BY SUM(seconds) DESC
+	`, userID, since.UTC().Format(db.SQLiteTime))
+}
+
+// TimeInDoor returns how long a user has played a door since the given
+// time.
+func (r *StatsRepo) TimeInDoor(userID int, door string, since time.Time) (time.Duration, error) {
+	var secs int64
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(seconds), 0) FROM door_sessions
+		WHERE user_id = ? AND door = ? COLLATE NOCASE AND started_at >= ?
+	`, userID, door, since.UTC().Format(db.SQLiteTime)).Scan(&secs)
+	if err != nil {
+		return 0, fmt.Errorf("time in door: %w", err)
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+func (r *StatsRepo) usage(query string, args ...interface{}) ([]Usage, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("door usage: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Usage
+	for rows.Next() {
+		var u Usage
+		if err := rows.Scan(&u.Door, &u.Launches, &u.Players, &u.Seconds); err != nil {
+			return nil, fmt.Errorf("door usage: %w", err)
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// StartOfDay returns local midnight on t's day, where daily door allowances
+// reset.
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
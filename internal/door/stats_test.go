@@ -0,0 +1,59 @@
+package door
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestStatsRepo(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x'), (2, 'bob', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewStatsRepo(database.DB)
+
+	now := time.Now()
+	play := func(door string, userID int, mins int, ago time.Duration) {
+		start := now.Add(-ago)
+		if err := repo.Record(&Play{Door: door, UserID: userID, NodeID: 1, StartedAt: start,
+			EndedAt: start.Add(time.Duration(mins) * time.Minute), ExitStatus: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	play("LORD", 1, 10, time.Minute)
+	play("lord", 1, 5, 2*time.Minute)
+	play("LORD", 2, 20, 3*time.Minute)
+	play("TradeWars", 1, 30, 4*time.Minute)
+	play("TradeWars", 1, 30, 48*time.Hour)
+
+	top, err := repo.TopDoors(now.Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].Launches != 3 || top[0].Players != 2 || top[0].Seconds != 35*60 {
+		t.Fatalf("top = %+v", top)
+	}
+
+	mine, err := repo.UserPlaytime(1, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mine) != 2 || mine[0].Door != "TradeWars" || mine[1].Seconds != 15*60 {
+		t.Fatalf("playtime = %+v", mine)
+	}
+
+	used, err := repo.TimeInDoor(1, "Lord", now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 15*time.Minute {
+		t.Fatalf("time in door = %v", used)
+	}
+}
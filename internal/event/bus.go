@@ -0,0 +1,94 @@
+// Package event is a small in-process publish/subscribe bus for things that
+// happen on a node, so features can react without the node knowing them.
+package event
+
+import (
+	"log"
+	"sync"
+)
+
+// Event names published by the BBS.
+const (
+	// Logoff is published once when a node's session ends for any reason,
+	// including a dropped carrier. Data is a *callers.Call.
+	Logoff = "logoff"
+
+	// Connect is published when a node starts. Data is the number of nodes
+	// now connected (int).
+	Connect = "connect"
+
+	// Login is published when a caller logs in. Data is a *user.User.
+	Login = "login"
+
+	// NewUser is published when an account is registered. Data is a
+	// *user.User.
+	NewUser = "new_user"
+
+	// Post is published for each message posted. Data is the area ID (int).
+	Post = "post"
+
+	// Upload and Download are published after file transfers. Data is the
+	// number of files (int).
+	Upload   = "upload"
+	Download = "download"
+
+	// DoorLaunch is published when a door starts. Data is the door name.
+	DoorLaunch = "door_launch"
+
+	// Flood is published when flood control refuses a user a post, chat
+	// line or file entry. Data is a *flood.Violation.
+	Flood = "flood"
+)
+
+// Event is one occurrence. Data depends on Name.
+type Event struct {
+	Name   string
+	NodeID int
+	Data   interface{}
+}
+
+// Handler reacts to an event.
+type Handler func(Event)
+
+// Bus dispatches events to subscribers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+// NewBus creates an empty bus.
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe registers h for events named name.
+func (b *Bus) Subscribe(name string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[name] = append(b.handlers[name], h)
+}
+
+// Publish runs the subscribers of ev in order on the calling goroutine, so
+// they have finished when Publish returns. A panicking handler is logged
+// and does not stop the others. Publishing on a nil Bus does nothing.
+func (b *Bus) Publish(ev Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	handlers := append([]Handler(nil), b.handlers[ev.Name]...)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		b.run(h, ev)
+	}
+}
+
+func (b *Bus) run(h Handler, ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Node %d: %s handler panic: %v", ev.NodeID, ev.Name, r)
+		}
+	}()
+	h(ev)
+}
@@ -0,0 +1,20 @@
+package event
+
+import "testing"
+
+func TestPublishRunsAllHandlers(t *testing.T) {
+	b := NewBus()
+	var got []int
+	b.Subscribe(Logoff, func(ev Event) { got = append(got, ev.NodeID) })
+	b.Subscribe(Logoff, func(Event) { panic("boom") })
+	b.Subscribe(Logoff, func(ev Event) { got = append(got, ev.NodeID*10) })
+	b.Subscribe("other", func(Event) { t.Fatal("wrong event delivered") })
+
+	b.Publish(Event{Name: Logoff, NodeID: 3})
+	if len(got) != 2 || got[0] != 3 || got[1] != 30 {
+		t.Fatalf("handlers ran %v", got)
+	}
+
+	var nilBus *Bus
+	nilBus.Publish(Event{Name: Logoff})
+}
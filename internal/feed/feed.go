@@ -0,0 +1,297 @@
+// Package feed publishes read-only RSS and Atom feeds of selected message
+// areas. Only public messages are included. Feeds are built once and kept
+// until a post in the area replaces them, or until a request finds the
+// area has newer messages, as after an archive import by bbs-admin, which
+// runs outside the BBS and cannot publish event.Post.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+// DefaultItems is how many messages a feed holds when Items is not set.
+const DefaultItems = 20
+
+// Handler serves /feeds/<area>.xml (RSS 2.0) and /feeds/<area>.atom
+// (Atom) for the configured areas.
+type Handler struct {
+	Name     string // board name, used in feed titles
+	BaseURL  string // public URL of the health server, for links
+	Messages *message.Repo
+	Areas    []int // areas to publish; others are never served
+	Items    int   // messages per feed
+
+	mu    sync.Mutex
+	feeds map[int]*built
+}
+
+// built is an area's feeds in both formats.
+type built struct {
+	rss, atom []byte
+	lastID    int // newest message in the area when built
+}
+
+// New returns a feed handler. Mount it at "/feeds/" and subscribe HandlePost
+// to event.Post.
+func New(name, baseURL string, messages *message.Repo, areas []int, items int) *Handler {
+	if items <= 0 {
+		items = DefaultItems
+	}
+	return &Handler{
+		Name:     name,
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		Messages: messages,
+		Areas:    areas,
+		Items:    items,
+		feeds:    make(map[int]*built),
+	}
+}
+
+// HandlePost rebuilds the feeds of the area a message was posted in.
+func (h *Handler) HandlePost(ev event.Event) {
+	areaID, ok := ev.Data.(int)
+	if !ok || !slices.Contains(h.Areas, areaID) {
+		return
+	}
+	if _, err := h.rebuild(areaID); err != nil {
+		log.Printf("Feed: area %d: %v", areaID, err)
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/feeds/")
+	base, format, _ := strings.Cut(name, ".")
+	areaID, err := strconv.Atoi(base)
+	if err != nil || (format != "xml" && format != "atom") || !slices.Contains(h.Areas, areaID) {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.mu.Lock()
+	f := h.feeds[areaID]
+	h.mu.Unlock()
+	if f != nil {
+		if last, err := h.Messages.LastID(areaID); err == nil && last != f.lastID {
+			f = nil
+		}
+	}
+	if f == nil {
+		if f, err = h.rebuild(areaID); err != nil {
+			log.Printf("Feed: area %d: %v", areaID, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if format == "atom" {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		w.Write(f.atom)
+	} else {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		w.Write(f.rss)
+	}
+}
+
+// rebuild loads an area's newest public messages and replaces its feeds.
+func (h *Handler) rebuild(areaID int) (*built, error) {
+	a, err := h.Messages.GetArea(areaID)
+	if err != nil {
+		return nil, err
+	}
+	lastID, err := h.Messages.LastID(areaID)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := h.Messages.ListPublic(areaID, h.Items)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(msgs) // newest first
+
+	f := &built{lastID: lastID}
+	if f.rss, err = h.rss(a, msgs); err != nil {
+		return nil, err
+	}
+	if f.atom, err = h.atom(a, msgs); err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	h.feeds[areaID] = f
+	h.mu.Unlock()
+	return f, nil
+}
+
+// link returns the message's place in its thread on the web viewer when
+// the area is shown there, and the board's front page otherwise.
+func (h *Handler) link(a *message.Area, m *message.Message) string {
+	if !a.WebPublic {
+		return h.BaseURL + "/"
+	}
+	if m == nil {
+		return fmt.Sprintf("%s/forum/%d", h.BaseURL, a.ID)
+	}
+	return fmt.Sprintf("%s/forum/%d/%d#m%d", h.BaseURL, a.ID, h.root(m), m.ID)
+}
+
+// maxDepth bounds the walk to a thread's root.
+const maxDepth = 100
+
+// root returns the ID of the oldest public ancestor of m still stored,
+// which the web viewer uses as the thread ID.
+func (h *Handler) root(m *message.Message) int {
+	id := m.ID
+	for i := 0; m.ReplyToID != nil && i < maxDepth; i++ {
+		parent, err := h.Messages.GetMessage(*m.ReplyToID)
+		if err != nil || parent.AreaID != m.AreaID || parent.ToUserID != nil {
+			break
+		}
+		m, id = parent, parent.ID
+	}
+	return id
+}
+
+// id returns a stable identifier for a message, or for the area itself.
+func (h *Handler) id(a *message.Area, m *message.Message) string {
+	if m == nil {
+		return fmt.Sprintf("%s/feeds/%d", h.BaseURL, a.ID)
+	}
+	return fmt.Sprintf("%s/feeds/%d/%d", h.BaseURL, a.ID, m.ID)
+}
+
+// body renders a message as HTML, colours and all.
+func body(m *message.Message) string {
+	return "<pre>" + ansi.RenderHTML(m.Body, 0) + "</pre>"
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Updated     string    `xml:"lastBuildDate,omitempty"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Author      string  `xml:"author"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	Value     string `xml:",chardata"`
+	Permalink bool   `xml:"isPermaLink,attr"`
+}
+
+func (h *Handler) rss(a *message.Area, msgs []*message.Message) ([]byte, error) {
+	ch := rssChannel{
+		Title:       h.Name + ": " + a.Name,
+		Link:        h.link(a, nil),
+		Description: a.Description,
+	}
+	if len(msgs) > 0 {
+		ch.Updated = msgs[0].CreatedAt.UTC().Format(time.RFC1123Z)
+	}
+	for _, m := range msgs {
+		ch.Items = append(ch.Items, rssItem{
+			Title:       m.Subject,
+			Link:        h.link(a, m),
+			GUID:        rssGUID{Value: h.id(a, m)},
+			Author:      m.FromName,
+			PubDate:     m.CreatedAt.UTC().Format(time.RFC1123Z),
+			Description: body(m),
+		})
+	}
+	return encode(rssDoc{Version: "2.0", Channel: ch})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (h *Handler) atom(a *message.Area, msgs []*message.Message) ([]byte, error) {
+	feed := atomFeed{
+		Title:   h.Name + ": " + a.Name,
+		ID:      h.id(a, nil),
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: h.link(a, nil)},
+	}
+	if len(msgs) > 0 {
+		feed.Updated = msgs[0].CreatedAt.UTC().Format(time.RFC3339)
+	}
+	for _, m := range msgs {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   m.Subject,
+			ID:      h.id(a, m),
+			Updated: m.CreatedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: h.link(a, m)},
+			Author:  atomAuthor{Name: m.FromName},
+			Content: atomContent{Type: "html", Value: body(m)},
+		})
+	}
+	return encode(feed)
+}
+
+func encode(doc any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("encode feed: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,76 @@
+package feed
+
+import (
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+func get(t *testing.T, h *Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func TestFeeds(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x'), (2, 'bob', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	messages := message.NewRepo(database.DB)
+	if err := messages.SetWebPublic(1, true); err != nil {
+		t.Fatal(err)
+	}
+	root, _ := messages.Post(1, 1, nil, "Meeting <Friday>", "see you \x1b[1;31mthere\x1b[0m", nil)
+	bob := 2
+	messages.Post(1, 1, &bob, "Secret", "for bob only", nil)
+	messages.Post(2, 1, nil, "Sysop news", "not published", nil)
+
+	h := New("Test BBS", "https://bbs.example.org/", messages, []int{1}, 10)
+
+	code, body := get(t, h, "/feeds/1.xml")
+	if code != 200 || !strings.Contains(body, "<title>Meeting &lt;Friday&gt;</title>") || strings.Contains(body, "Secret") {
+		t.Fatalf("rss = %d %q", code, body)
+	}
+	code, body = get(t, h, "/feeds/1.atom")
+	if code != 200 || !strings.Contains(body, `<feed xmlns="http://www.w3.org/2005/Atom">`) ||
+		!strings.Contains(body, `href="https://bbs.example.org/forum/1/`+strconv.Itoa(root)+`#m`+strconv.Itoa(root)+`"`) {
+		t.Fatalf("atom = %d %q", code, body)
+	}
+
+	// A post event rebuilds the area's feeds.
+	reply, _ := messages.Post(1, 2, nil, "Re: Meeting", "count me in", &root)
+	h.HandlePost(event.Event{Name: event.Post, Data: 1})
+	_, body = get(t, h, "/feeds/1.atom")
+	if !strings.Contains(body, "Re: Meeting") || !strings.Contains(body, "/forum/1/"+strconv.Itoa(root)+"#m"+strconv.Itoa(reply)) {
+		t.Fatalf("atom after post = %q", body)
+	}
+
+	// Messages stored without an event, as by an archive import in
+	// another process, show once a request finds them.
+	if _, err := messages.ImportArchive(1, 1, []*message.Archived{{From: "carol", Subject: "From the archive", Body: "old news"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := get(t, h, "/feeds/1.xml"); !strings.Contains(body, "From the archive") {
+		t.Fatalf("rss after import = %q", body)
+	}
+
+	for _, path := range []string{"/feeds/2.xml", "/feeds/1.json", "/feeds/x.xml"} {
+		if code, _ := get(t, h, path); code != 404 {
+			t.Errorf("%s: status %d", path, code)
+		}
+	}
+}
@@ -11,6 +11,7 @@ type Area struct {
 	DownloadLevel int
 	UploadLevel   int
 	SortOrder     int
+	Conference    int // conference the area belongs to
 	FileCount     int // computed field
 }
 
@@ -18,6 +19,7 @@ type Area struct {
 type Entry struct {
 	ID            int
 	AreaID        int
+	AreaName      string // joined by TopDownloads and ListFilesSince
 	Filename      string
 	Description   string
 	SizeBytes     int64
@@ -0,0 +1,61 @@
+package filearea
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// ListFilesSince returns up to limit files uploaded after since to areas
+// the user may download from, grouped by area in area order and oldest
+// first within an area. A limit of 0 or less means no limit.
+func (r *Repo) ListFilesSince(since time.Time, userLevel, limit int) ([]*Entry, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	inConfs, args := r.inConferences(since.UTC().Format(db.SQLiteTime), userLevel)
+	rows, err := r.db.Query(`
+		SELECT f.id, f.area_id, a.name, f.filename, f.description, f.size_bytes,
+		       f.uploader_id, COALESCE(u.username, 'Unknown') as uploader_name,
+		       f.download_count, f.uploaded_at
+		FROM file_entries f
+		LEFT JOIN users u ON u.id = f.uploader_id
+		JOIN file_areas a ON a.id = f.area_id
+		WHERE f.uploaded_at > ? AND a.download_level <= ? AND `+inConfs+`
+		ORDER BY a.sort_order, a.name, f.uploaded_at, f.id
+		LIMIT ?
+	`, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("list new files: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		e := &Entry{}
+		if err := rows.Scan(&e.ID, &e.AreaID, &e.AreaName, &e.Filename, &e.Description,
+			&e.SizeBytes, &e.UploaderID, &e.UploaderName,
+			&e.DownloadCount, &e.UploadedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// CountFilesSince returns how many files were uploaded after since to
+// areas the user may download from, and how many areas they are in.
+func (r *Repo) CountFilesSince(since time.Time, userLevel int) (files, areas int, err error) {
+	inConfs, args := r.inConferences(since.UTC().Format(db.SQLiteTime), userLevel)
+	err = r.db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT f.area_id)
+		FROM file_entries f
+		JOIN file_areas a ON a.id = f.area_id
+		WHERE f.uploaded_at > ? AND a.download_level <= ? AND `+inConfs+`
+	`, args...).Scan(&files, &areas)
+	if err != nil {
+		return 0, 0, fmt.Errorf("count new files: %w", err)
+	}
+	return files, areas, nil
+}
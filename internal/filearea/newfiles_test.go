@@ -0,0 +1,65 @@
+package filearea
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestListFilesSince(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	for _, q := range []string{
+		`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')`,
+		`INSERT INTO file_areas (id, name, disk_path, download_level, sort_order) VALUES
+			(11, 'Utilities', '/tmp/u', 10, 2), (12, 'Games', '/tmp/g', 10, 1), (13, 'Sysop', '/tmp/s', 100, 0)`,
+		`INSERT INTO file_entries (area_id, filename, size_bytes, uploader_id, uploaded_at) VALUES
+			(11, 'OLD.ZIP', 1, 1, '2026-01-01 10:00:00'),
+			(11, 'PKZ204.EXE', 1, 1, '2026-03-02 10:00:00'),
+			(12, 'DOOM.ZIP', 1, 1, '2026-03-03 10:00:00'),
+			(12, 'LORD.ZIP', 1, 1, '2026-03-01 12:00:00'),
+			(13, 'SECRET.TXT', 1, 1, '2026-03-04 10:00:00')`,
+	} {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := NewRepo(database.DB)
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	files, err := repo.ListFilesSince(since, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range files {
+		got = append(got, e.AreaName+"/"+e.Filename)
+	}
+	want := []string{"Games/LORD.ZIP", "Games/DOOM.ZIP", "Utilities/PKZ204.EXE"}
+	if len(got) != len(want) {
+		t.Fatalf("new files = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("new files = %v, want %v", got, want)
+		}
+	}
+
+	if files, _ := repo.ListFilesSince(since, 10, 2); len(files) != 2 {
+		t.Errorf("limit 2 gave %d files", len(files))
+	}
+	if n, areas, err := repo.CountFilesSince(since, 10); err != nil || n != 3 || areas != 2 {
+		t.Errorf("count = %d files in %d areas, %v", n, areas, err)
+	}
+	if n, areas, _ := repo.CountFilesSince(since, 100); n != 4 || areas != 3 {
+		t.Errorf("sysop count = %d files in %d areas", n, areas)
+	}
+	if n, _, _ := repo.CountFilesSince(time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC), 10); n != 0 {
+		t.Errorf("%d files newer than the newest", n)
+	}
+}
@@ -0,0 +1,26 @@
+package filearea
+
+import "fmt"
+
+// Ratio is the upload/download ratio policy. Callers may download PerUpload
+// bytes for every byte they have uploaded, plus FreeKB up front.
+type Ratio struct {
+	PerUpload   int   // 0 turns ratios off
+	FreeKB      int64 // downloads allowed before any upload
+	ExemptLevel int   // users at or above this level are exempt; 0 = none
+}
+
+// Check returns an error when downloading extra more bytes would take a
+// caller at level, with the given lifetime totals, past the ratio.
+func (r Ratio) Check(level int, uploaded, downloaded, extra int64) error {
+	if r.PerUpload <= 0 || (r.ExemptLevel > 0 && level >= r.ExemptLevel) {
+		return nil
+	}
+	allowed := r.FreeKB*1024 + uploaded*int64(r.PerUpload)
+	if downloaded+extra <= allowed {
+		return nil
+	}
+	over := downloaded + extra - allowed
+	need := (over + int64(r.PerUpload) - 1) / int64(r.PerUpload)
+	return fmt.Errorf("download ratio is 1:%d; upload %d KB more first", r.PerUpload, (need+1023)/1024)
+}
@@ -0,0 +1,26 @@
+package filearea
+
+import "testing"
+
+func TestRatioCheck(t *testing.T) {
+	r := Ratio{PerUpload: 5, FreeKB: 100, ExemptLevel: 90}
+	cases := []struct {
+		level                     int
+		uploaded, downloaded, add int64
+		ok                        bool
+	}{
+		{10, 0, 0, 100 * 1024, true},
+		{10, 0, 0, 100*1024 + 1, false},
+		{10, 1024, 100 * 1024, 5 * 1024, true},
+		{10, 1024, 100 * 1024, 5*1024 + 1, false},
+		{90, 0, 1 << 30, 1 << 30, true},
+	}
+	for i, c := range cases {
+		if err := r.Check(c.level, c.uploaded, c.downloaded, c.add); (err == nil) != c.ok {
+			t.Errorf("case %d: err = %v", i, err)
+		}
+	}
+	if err := (Ratio{}).Check(10, 0, 1<<40, 1<<40); err != nil {
+		t.Errorf("ratio off: %v", err)
+	}
+}
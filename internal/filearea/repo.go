@@ -8,7 +8,8 @@ import (
 
 // Repo handles database operations for file areas and entries.
 type Repo struct {
-	db *sql.DB
+	db    *sql.DB
+	confs []int // area lists cover only these conferences; none = all
 }
 
 // NewRepo creates a new file area repository.
@@ -16,15 +17,35 @@ func NewRepo(db *sql.DB) *Repo {
 	return &Repo{db: db}
 }
 
+// InConferences returns a view of the repository whose area lists, new
+// file scans and searches cover only the areas in the given conferences;
+// with none it covers them all. Areas and files are still reachable by ID.
+func (r *Repo) InConferences(ids ...int) *Repo {
+	return &Repo{db: r.db, confs: ids}
+}
+
+// inConferences returns the SQL condition that limits a.conference_id to
+// the view's conferences, with its arguments appended to args.
+func (r *Repo) inConferences(args ...any) (string, []any) {
+	if len(r.confs) == 0 {
+		return "1", args
+	}
+	for _, id := range r.confs {
+		args = append(args, id)
+	}
+	return "a.conference_id IN (?" + strings.Repeat(", ?", len(r.confs)-1) + ")", args
+}
+
 // ListAreas returns all file areas the user has access to download from.
 func (r *Repo) ListAreas(userLevel int) ([]*Area, error) {
+	inConfs, args := r.inConferences(userLevel)
 	rows, err := r.db.Query(`
 		SELECT a.id, a.name, a.description, a.disk_path, a.download_level, a.upload_level, a.sort_order,
-		       COALESCE((SELECT COUNT(*) FROM file_entries WHERE area_id = a.id), 0) as file_count
+		       a.conference_id, COALESCE((SELECT COUNT(*) FROM file_entries WHERE area_id = a.id), 0) as file_count
 		FROM file_areas a
-		WHERE a.download_level <= ?
+		WHERE a.download_level <= ? AND `+inConfs+`
 		ORDER BY a.sort_order, a.name
-	`, userLevel)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list file areas: %w", err)
 	}
@@ -34,7 +55,7 @@ func (r *Repo) ListAreas(userLevel int) ([]*Area, error) {
 	for rows.Next() {
 		a := &Area{}
 		if err := rows.Scan(&a.ID, &a.Name, &a.Description, &a.DiskPath,
-			&a.DownloadLevel, &a.UploadLevel, &a.SortOrder, &a.FileCount); err != nil {
+			&a.DownloadLevel, &a.UploadLevel, &a.SortOrder, &a.Conference, &a.FileCount); err != nil {
 			return nil, err
 		}
 		areas = append(areas, a)
@@ -46,10 +67,10 @@ func (r *Repo) ListAreas(userLevel int) ([]*Area, error) {
 func (r *Repo) GetArea(id int) (*Area, error) {
 	a := &Area{}
 	err := r.db.QueryRow(`
-		SELECT id, name, description, disk_path, download_level, upload_level, sort_order
+		SELECT id, name, description, disk_path, download_level, upload_level, sort_order, conference_id
 		FROM file_areas WHERE id = ?
 	`, id).Scan(&a.ID, &a.Name, &a.Description, &a.DiskPath,
-		&a.DownloadLevel, &a.UploadLevel, &a.SortOrder)
+		&a.DownloadLevel, &a.UploadLevel, &a.SortOrder, &a.Conference)
 	if err != nil {
 		return nil, fmt.Errorf("get area %d: %w", id, err)
 	}
@@ -105,9 +126,11 @@ func (r *Repo) GetFile(id int) (*Entry, error) {
 	return e, nil
 }
 
-// FindByName searches for files by name pattern across all areas.
+// FindByName searches for files by name pattern across all areas, or the
+// areas of some conferences for a view from InConferences.
 func (r *Repo) FindByName(pattern string, userLevel int) ([]*Entry, error) {
 	pattern = "%" + escapeLike(pattern) + "%"
+	inConfs, args := r.inConferences(pattern, pattern, userLevel)
 	rows, err := r.db.Query(`
 		SELECT f.id, f.area_id, f.filename, f.description, f.size_bytes,
 		       f.uploader_id, COALESCE(u.username, 'Unknown') as uploader_name,
@@ -116,10 +139,10 @@ func (r *Repo) FindByName(pattern string, userLevel int) ([]*Entry, error) {
 		LEFT JOIN users u ON u.id = f.uploader_id
 		JOIN file_areas a ON a.id = f.area_id
 		WHERE (f.filename LIKE ? ESCAPE '\\' OR f.description LIKE ? ESCAPE '\\')
-		  AND a.download_level <= ?
+		  AND a.download_level <= ? AND `+inConfs+`
 		ORDER BY f.filename
 		LIMIT 50
-	`, pattern, pattern, userLevel)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("search files: %w", err)
 	}
@@ -151,12 +174,23 @@ func (r *Repo) AddEntry(areaID int, filename, description string, sizeBytes int6
 	return int(id), err
 }
 
-// IncrementDownload increments the download count for a file.
+// IncrementDownload increments the download count for a file and logs
+// the download for the period statistics (see TopDownloads).
 func (r *Repo) IncrementDownload(fileID int) error {
-	_, err := r.db.Exec(`
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`
 		UPDATE file_entries SET download_count = download_count + 1 WHERE id = ?
-	`, fileID)
-	return err
+	`, fileID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO file_downloads (file_id) VALUES (?)`, fileID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func escapeLike(s string) string {
@@ -166,3 +200,23 @@ func escapeLike(s string) string {
 	s = strings.ReplaceAll(s, "_", "\\_")
 	return s
 }
+
+// GetFileByName returns the entry named filename in an area.
+func (r *Repo) GetFileByName(areaID int, filename string) (*Entry, error) {
+	e := &Entry{}
+	err := r.db.QueryRow(`
+		SELECT f.id, f.area_id, f.filename, f.description, f.size_bytes,
+		       f.uploader_id, COALESCE(u.username, 'Unknown') as uploader_name,
+		       f.download_count, f.uploaded_at
+		FROM file_entries f
+		LEFT JOIN users u ON u.id = f.uploader_id
+		WHERE f.area_id = ? AND f.filename = ?
+		ORDER BY f.id LIMIT 1
+	`, areaID, filename).Scan(&e.ID, &e.AreaID, &e.Filename, &e.Description,
+		&e.SizeBytes, &e.UploaderID, &e.UploaderName,
+		&e.DownloadCount, &e.UploadedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get file %q in area %d: %w", filename, areaID, err)
+	}
+	return e, nil
+}
@@ -0,0 +1,178 @@
+package filearea
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// Period is a span of time for download statistics, counted in calendar
+// terms: today, this week (from Monday), this month or this year.
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+	PeriodYear  Period = "year"
+	PeriodAll   Period = "all" // every download since the file was added
+)
+
+// ParsePeriod returns the period named s; "" is a month.
+func ParsePeriod(s string) (Period, bool) {
+	switch p := Period(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PeriodMonth, true
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodYear, PeriodAll:
+		return p, true
+	}
+	return "", false
+}
+
+// Start returns when the period containing now began, zero for PeriodAll.
+func (p Period) Start(now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch p {
+	case PeriodDay:
+		return day
+	case PeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case PeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	case PeriodYear:
+		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Time{}
+}
+
+// String returns the period as callers read it: "this month".
+func (p Period) String() string {
+	switch p {
+	case PeriodDay:
+		return "today"
+	case PeriodWeek:
+		return "this week"
+	case PeriodMonth:
+		return "this month"
+	case PeriodYear:
+		return "this year"
+	}
+	return "of all time"
+}
+
+// TopFile is a file with how often it was downloaded in a period.
+type TopFile struct {
+	*Entry
+	Downloads int
+}
+
+// TopDownloads returns up to n of the files downloaded most in the period
+// containing now, in areas the user may download from, most downloaded
+// first. PeriodAll ranks by the files' download counts; the others count
+// the download log.
+func (r *Repo) TopDownloads(n int, p Period, now time.Time, userLevel int) ([]*TopFile, error) {
+	var query string
+	var args []any
+	if p == PeriodAll {
+		var inConfs string
+		inConfs, args = r.inConferences(userLevel)
+		query = `
+			SELECT f.id, f.area_id, a.name, f.filename, f.description, f.size_bytes,
+			       f.uploader_id, COALESCE(u.username, 'Unknown') as uploader_name,
+			       f.download_count, f.uploaded_at, f.download_count
+			FROM file_entries f
+			LEFT JOIN users u ON u.id = f.uploader_id
+			JOIN file_areas a ON a.id = f.area_id
+			WHERE f.download_count > 0 AND a.download_level <= ? AND ` + inConfs + `
+			ORDER BY f.download_count DESC, f.filename
+			LIMIT ?`
+	} else {
+		var inConfs string
+		inConfs, args = r.inConferences(p.Start(now).UTC().Format(db.SQLiteTime), userLevel)
+		query = `
+			SELECT f.id, f.area_id, a.name, f.filename, f.description, f.size_bytes,
+			       f.uploader_id, COALESCE(u.username, 'Unknown') as uploader_name,
+			       f.download_count, f.uploaded_at, COUNT(*) AS downloads
+			FROM file_downloads d
+			JOIN file_entries f ON f.id = d.file_id
+			LEFT JOIN users u ON u.id = f.uploader_id
+			JOIN file_areas a ON a.id = f.area_id
+			WHERE d.downloaded_at >= ? AND a.download_level <= ? AND ` + inConfs + `
+			GROUP BY f.id
+			ORDER BY downloads DESC, f.filename
+			LIMIT ?`
+	}
+	rows, err := r.db.Query(query, append(args, n)...)
+	if err != nil {
+		return nil, fmt.Errorf("top downloads: %w", err)
+	}
+	defer rows.Close()
+
+	var top []*TopFile
+	for rows.Next() {
+		t := &TopFile{Entry: &Entry{}}
+		if err := rows.Scan(&t.ID, &t.AreaID, &t.AreaName, &t.Filename, &t.Description,
+			&t.SizeBytes, &t.UploaderID, &t.UploaderName,
+			&t.DownloadCount, &t.UploadedAt, &t.Downloads); err != nil {
+			return nil, fmt.Errorf("top downloads: %w", err)
+		}
+		top = append(top, t)
+	}
+	return top, rows.Err()
+}
+
+// PruneDownloads removes download log entries older than before and
+// returns how many went. The files' download counts are kept.
+func (r *Repo) PruneDownloads(before time.Time) (int, error) {
+	res, err := r.db.Exec(`DELETE FROM file_downloads WHERE downloaded_at < ?`,
+		before.UTC().Format(db.SQLiteTime))
+	if err != nil {
+		return 0, fmt.Errorf("prune download log: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// DefaultTopTemplate is the text/template of the top downloads display.
+// It is given a TopReport.
+const DefaultTopTemplate = `{{range $i, $f := .Files}}{{printf "%2d" (inc $i)}}. {{printf "%-14s" $f.Filename}} {{printf "%4d" $f.Downloads}}x  {{$f.AreaName}}
+{{else}}No downloads {{.Period}} yet.
+{{end}}
+Updated {{.Generated.Format "2006-01-02 15:04"}}
+`
+
+// TopReport is what the top downloads template is given.
+type TopReport struct {
+	Title     string // "Top 10 downloads this month"
+	Period    Period
+	Files     []*TopFile
+	Generated time.Time
+}
+
+// TopTitle returns the usual title of a top n display.
+func TopTitle(n int, p Period) string {
+	return fmt.Sprintf("Top %d downloads %s", n, p)
+}
+
+// RenderTop fills in a top downloads template (DefaultTopTemplate when
+// text is "").
+func RenderTop(text string, report TopReport) (string, error) {
+	if text == "" {
+		text = DefaultTopTemplate
+	}
+	tmpl, err := template.New("top").Funcs(template.FuncMap{
+		"inc": func(i int) int { return i + 1 },
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("top downloads template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return "", fmt.Errorf("top downloads template: %w", err)
+	}
+	return buf.String(), nil
+}
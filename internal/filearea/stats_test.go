@@ -0,0 +1,106 @@
+package filearea
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC) // a Saturday
+	for p, want := range map[Period]string{
+		PeriodDay:   "2026-10-17",
+		PeriodWeek:  "2026-10-12",
+		PeriodMonth: "2026-10-01",
+		PeriodYear:  "2026-01-01",
+	} {
+		if got := p.Start(now).Format("2006-01-02 15:04"); got != want+" 00:00" {
+			t.Errorf("%s starts %s, want %s", p, got, want)
+		}
+	}
+	if !PeriodAll.Start(now).IsZero() {
+		t.Error("all has a start")
+	}
+	if p, ok := ParsePeriod(" Week"); !ok || p != PeriodWeek {
+		t.Errorf("ParsePeriod(week) = %q, %v", p, ok)
+	}
+	if _, ok := ParsePeriod("fortnight"); ok {
+		t.Error("fortnight parsed")
+	}
+}
+
+func TestTopDownloads(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	for _, q := range []string{
+		`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')`,
+		`INSERT INTO file_areas (id, name, disk_path, download_level) VALUES
+			(11, 'Utilities', '/tmp/u', 10), (13, 'Sysop', '/tmp/s', 100)`,
+		`INSERT INTO file_entries (id, area_id, filename, size_bytes, uploader_id, download_count) VALUES
+			(1, 11, 'PKZ204.EXE', 1, 1, 50), (2, 11, 'DOOM.ZIP', 1, 1, 3), (3, 13, 'SECRET.TXT', 1, 1, 9)`,
+		`INSERT INTO file_downloads (file_id, downloaded_at) VALUES
+			(1, '2026-09-30 10:00:00'),
+			(2, '2026-10-02 10:00:00'), (2, '2026-10-03 10:00:00'),
+			(1, '2026-10-05 10:00:00'),
+			(3, '2026-10-06 10:00:00'), (3, '2026-10-06 11:00:00'), (3, '2026-10-06 12:00:00')`,
+	} {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := NewRepo(database.DB)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	names := func(top []*TopFile) string {
+		var s []string
+		for _, f := range top {
+			s = append(s, f.Filename+"="+strings.Repeat("*", f.Downloads))
+		}
+		return strings.Join(s, " ")
+	}
+
+	top, err := repo.TopDownloads(10, PeriodMonth, now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(top); got != "DOOM.ZIP=** PKZ204.EXE=*" {
+		t.Errorf("month = %s", got)
+	}
+	if top[0].AreaName != "Utilities" {
+		t.Errorf("area = %q", top[0].AreaName)
+	}
+	if top, _ := repo.TopDownloads(1, PeriodMonth, now, 100); names(top) != "SECRET.TXT=***" {
+		t.Errorf("sysop month = %s", names(top))
+	}
+	if top, _ := repo.TopDownloads(1, PeriodAll, now, 10); len(top) != 1 || top[0].Downloads != 50 {
+		t.Errorf("all = %s", names(top))
+	}
+
+	if err := repo.IncrementDownload(1); err != nil {
+		t.Fatal(err)
+	}
+	if top, _ := repo.TopDownloads(1, PeriodDay, time.Now(), 10); names(top) != "PKZ204.EXE=*" {
+		t.Errorf("today = %s", names(top))
+	}
+
+	if n, err := repo.PruneDownloads(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)); n != 1 || err != nil {
+		t.Errorf("prune = %d, %v", n, err)
+	}
+
+	out, err := RenderTop("", TopReport{Title: TopTitle(10, PeriodMonth), Period: PeriodMonth, Files: top, Generated: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, " 1. DOOM.ZIP") || !strings.Contains(out, "Updated 2026-10-17 12:00") {
+		t.Errorf("rendered:\n%s", out)
+	}
+	if out, _ := RenderTop("", TopReport{Period: PeriodWeek}); !strings.HasPrefix(out, "No downloads this week yet.") {
+		t.Errorf("empty:\n%s", out)
+	}
+}
@@ -0,0 +1,104 @@
+// Package flood limits how often each user may post, chat and upload. The
+// limits are shared by all nodes, so a user cannot get around them by
+// logging in twice.
+package flood
+
+import (
+	"sync"
+	"time"
+)
+
+// Actions limited by the scripting APIs.
+const (
+	Post = "post" // msg.post and msg.send_private
+	Chat = "chat" // chat.send, chat.broadcast and chat.send_room
+	File = "file" // files.add_entry
+)
+
+// Rule allows at most Max actions in any Window. A zero Max is no limit.
+type Rule struct {
+	Max    int
+	Window time.Duration
+}
+
+// Violation is the Data of an event.Flood: a user was refused an action.
+type Violation struct {
+	Action   string
+	UserID   int
+	Username string
+	Retry    time.Duration // until the action is allowed again
+}
+
+// Limiter tracks recent actions per user against the rules.
+type Limiter struct {
+	mu          sync.Mutex
+	rules       map[string]Rule
+	exemptLevel int
+	hits        map[key][]time.Time
+	now         func() time.Time
+}
+
+type key struct {
+	action string
+	userID int
+}
+
+// New returns a limiter. Users at exemptLevel or above are never limited;
+// 0 exempts no one.
+func New(rules map[string]Rule, exemptLevel int) *Limiter {
+	return &Limiter{
+		rules:       rules,
+		exemptLevel: exemptLevel,
+		hits:        make(map[key][]time.Time),
+		now:         time.Now,
+	}
+}
+
+// SetRules replaces the rules, for a config reload. Actions already
+// counted still count against the new rules.
+func (l *Limiter) SetRules(rules map[string]Rule, exemptLevel int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules = rules
+	l.exemptLevel = exemptLevel
+}
+
+// Allow records an action by a user and reports whether it is within the
+// limits. When it is not, nothing is recorded and retry is how long until
+// the oldest counted action leaves the window.
+func (l *Limiter) Allow(action string, userID, level int) (retry time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rule := l.rules[action]
+	if rule.Max <= 0 || rule.Window <= 0 || (l.exemptLevel > 0 && level >= l.exemptLevel) {
+		return 0, true
+	}
+
+	now := l.now()
+	k := key{action, userID}
+	hits := l.hits[k]
+	cutoff := now.Add(-rule.Window)
+	for len(hits) > 0 && !hits[0].After(cutoff) {
+		hits = hits[1:]
+	}
+	if len(hits) >= rule.Max {
+		l.hits[k] = hits
+		return hits[len(hits)-rule.Max].Sub(cutoff), false
+	}
+	l.hits[k] = append(hits, now)
+	return 0, true
+}
+
+// Prune forgets actions older than their rule's window, so users who have
+// gone quiet do not hold memory.
+func (l *Limiter) Prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for k, hits := range l.hits {
+		rule := l.rules[k.action]
+		if len(hits) == 0 || rule.Max <= 0 || !hits[len(hits)-1].After(now.Add(-rule.Window)) {
+			delete(l.hits, k)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package flood
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(map[string]Rule{
+		Chat: {Max: 3, Window: 10 * time.Second},
+		Post: {Max: 0, Window: time.Hour},
+	}, 90)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, ok := l.Allow(Chat, 1, 10); !ok {
+			t.Fatalf("message %d refused", i+1)
+		}
+		now = now.Add(time.Second)
+	}
+	retry, ok := l.Allow(Chat, 1, 10)
+	if ok || retry != 7*time.Second {
+		t.Fatalf("fourth message = %v, %v", retry, ok)
+	}
+	if _, ok := l.Allow(Chat, 2, 10); !ok {
+		t.Fatal("other user refused")
+	}
+	if _, ok := l.Allow(Chat, 1, 90); !ok {
+		t.Fatal("sysop refused")
+	}
+	if _, ok := l.Allow(Post, 1, 10); !ok {
+		t.Fatal("unlimited action refused")
+	}
+
+	now = now.Add(7 * time.Second)
+	if _, ok := l.Allow(Chat, 1, 10); !ok {
+		t.Fatal("refused after the window moved on")
+	}
+
+	now = now.Add(time.Minute)
+	l.Prune()
+	if len(l.hits) != 0 {
+		t.Fatalf("prune kept %v", l.hits)
+	}
+}
@@ -0,0 +1,169 @@
+// Package forum is a read-only web viewer for message areas marked
+// web_public. Pages are plain server-side HTML with no scripts; only public
+// messages are ever shown.
+package forum
+
+import (
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+// maxMessages caps the newest public messages loaded per area.
+const maxMessages = 500
+
+// Handler serves the viewer under /forum/.
+type Handler struct {
+	Name     string // board name shown in page titles
+	Messages *message.Repo
+
+	mux *http.ServeMux
+}
+
+// New returns a handler for the viewer. Mount it at "/forum/".
+func New(name string, messages *message.Repo) *Handler {
+	h := &Handler{Name: name, Messages: messages, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /forum/{$}", h.areas)
+	h.mux.HandleFunc("GET /forum/{area}", h.threads)
+	h.mux.HandleFunc("GET /forum/{area}/{thread}", h.thread)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// area returns a web_public area by its path value.
+func (h *Handler) area(r *http.Request) (*message.Area, bool) {
+	id, err := strconv.Atoi(r.PathValue("area"))
+	if err != nil {
+		return nil, false
+	}
+	a, err := h.Messages.GetArea(id)
+	if err != nil || !a.WebPublic {
+		return nil, false
+	}
+	return a, true
+}
+
+func (h *Handler) areas(w http.ResponseWriter, r *http.Request) {
+	all, err := h.Messages.ListAreas(math.MaxInt32)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	var areas []*message.Area
+	for _, a := range all {
+		if a.WebPublic {
+			areas = append(areas, a)
+		}
+	}
+	h.render(w, areasPage, map[string]any{"Name": h.Name, "Areas": areas})
+}
+
+// thread is a root message and the replies below it.
+type thread struct {
+	Root     *message.Message
+	Messages []post
+	Last     time.Time
+}
+
+// post is a message placed in its thread.
+type post struct {
+	*message.Message
+	Depth int
+	Body  template.HTML
+}
+
+// buildThreads groups messages by the oldest ancestor still present, newest
+// activity first. Replies whose parent has been purged start a new thread.
+func buildThreads(msgs []*message.Message) []*thread {
+	byID := make(map[int]*message.Message, len(msgs))
+	for _, m := range msgs {
+		byID[m.ID] = m
+	}
+	depth := func(m *message.Message) (*message.Message, int) {
+		d := 0
+		for m.ReplyToID != nil {
+			parent, ok := byID[*m.ReplyToID]
+			if !ok || d > len(msgs) {
+				break
+			}
+			m = parent
+			d++
+		}
+		return m, d
+	}
+
+	index := make(map[int]*thread)
+	var threads []*thread
+	for _, m := range msgs {
+		root, d := depth(m)
+		t, ok := index[root.ID]
+		if !ok {
+			t = &thread{Root: root}
+			index[root.ID] = t
+			threads = append(threads, t)
+		}
+		t.Messages = append(t.Messages, post{Message: m, Depth: d, Body: template.HTML(ansi.RenderHTML(m.Body, 0))})
+		if m.CreatedAt.After(t.Last) {
+			t.Last = m.CreatedAt
+		}
+	}
+	sort.SliceStable(threads, func(i, j int) bool { return threads[i].Last.After(threads[j].Last) })
+	return threads
+}
+
+func (h *Handler) threads(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.area(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	msgs, err := h.Messages.ListPublic(a.ID, maxMessages)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	h.render(w, threadsPage, map[string]any{"Name": h.Name, "Area": a, "Threads": buildThreads(msgs)})
+}
+
+func (h *Handler) thread(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.area(r)
+	id, err := strconv.Atoi(r.PathValue("thread"))
+	if !ok || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	msgs, err := h.Messages.ListPublic(a.ID, maxMessages)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	for _, t := range buildThreads(msgs) {
+		if t.Root.ID == id {
+			h.render(w, threadPage, map[string]any{"Name": h.Name, "Area": a, "Thread": t})
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (h *Handler) render(w http.ResponseWriter, page *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, data); err != nil {
+		log.Printf("Forum: %v", err)
+	}
+}
+
+func (h *Handler) fail(w http.ResponseWriter, err error) {
+	log.Printf("Forum: %v", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
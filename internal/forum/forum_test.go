@@ -0,0 +1,61 @@
+package forum
+
+import (
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+func get(t *testing.T, h *Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func TestForum(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x'), (2, 'bob', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	messages := message.NewRepo(database.DB)
+	if err := messages.SetWebPublic(1, true); err != nil {
+		t.Fatal(err)
+	}
+	root, _ := messages.Post(1, 1, nil, "Hello web", "plain \x1b[1;31mred<b>\x1b[0m text", nil)
+	reply, _ := messages.Post(1, 2, nil, "Re: Hello web", "a reply", &root)
+	bob := 2
+	messages.Post(1, 1, &bob, "Secret", "for bob only", nil)
+	hidden, _ := messages.Post(2, 1, nil, "Sysop news", "not published", nil)
+
+	h := New("Test BBS", messages)
+
+	if code, body := get(t, h, "/forum/"); code != 200 || !strings.Contains(body, `href="/forum/1"`) || strings.Contains(body, `href="/forum/2"`) {
+		t.Fatalf("areas = %d %q", code, body)
+	}
+	code, body := get(t, h, "/forum/1")
+	if code != 200 || !strings.Contains(body, "Hello web") || strings.Contains(body, "Secret") || strings.Contains(body, "Re: Hello web") {
+		t.Fatalf("threads = %d %q", code, body)
+	}
+	code, body = get(t, h, "/forum/1/"+strconv.Itoa(root))
+	if code != 200 || !strings.Contains(body, `<span style="color:#ff5555">red&lt;b&gt;</span>`) ||
+		!strings.Contains(body, `id="m`+strconv.Itoa(reply)+`"`) {
+		t.Fatalf("thread = %d %q", code, body)
+	}
+	for _, path := range []string{"/forum/2", "/forum/1/" + strconv.Itoa(reply), "/forum/2/" + strconv.Itoa(hidden), "/forum/x"} {
+		if code, _ := get(t, h, path); code != 404 {
+			t.Errorf("%s: status %d", path, code)
+		}
+	}
+}
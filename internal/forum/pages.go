@@ -0,0 +1,57 @@
+package forum
+
+import (
+	"html/template"
+	"time"
+)
+
+var funcs = template.FuncMap{
+	"date":   func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+	"indent": func(depth int) int { return min(depth, 8) * 2 },
+	"count":  func(ps []post) int { return len(ps) - 1 },
+}
+
+const layout = `<!DOCTYPE html>
+<html><head><meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{block "title" .}}{{.Name}}{{end}}</title>
+<style>
+body{background:#000;color:#aaa;font-family:monospace;max-width:60em;margin:1em auto;padding:0 1em}
+a{color:#5ff}h1,h2{color:#fff;font-size:1.2em}
+table{border-collapse:collapse;width:100%}td,th{text-align:left;padding:.2em .5em}
+th{color:#ff5;border-bottom:1px solid #555}
+.msg{border-top:1px solid #555;padding:.5em 0}.hdr{color:#5f5}
+pre{white-space:pre-wrap;margin:.5em 0}
+</style></head><body>
+<p><a href="/forum/">{{.Name}}</a></p>
+{{block "content" .}}{{end}}
+</body></html>
+`
+
+func page(content string) *template.Template {
+	return template.Must(template.Must(template.New("layout").Funcs(funcs).Parse(layout)).Parse(content))
+}
+
+var areasPage = page(`{{define "content"}}<h1>Message areas</h1>
+{{if .Areas}}<table><tr><th>Area</th><th>Description</th></tr>
+{{range .Areas}}<tr><td><a href="/forum/{{.ID}}">{{.Name}}</a></td><td>{{.Description}}</td></tr>
+{{end}}</table>{{else}}<p>No areas are published.</p>{{end}}
+{{end}}`)
+
+var threadsPage = page(`{{define "title"}}{{.Area.Name}} - {{.Name}}{{end}}
+{{define "content"}}<h1>{{.Area.Name}}</h1>
+{{if .Threads}}<table><tr><th>Subject</th><th>From</th><th>Replies</th><th>Last post</th></tr>
+{{range .Threads}}<tr><td><a href="/forum/{{$.Area.ID}}/{{.Root.ID}}">{{.Root.Subject}}</a></td>
+<td>{{.Root.FromName}}</td><td>{{count .Messages}}</td><td>{{date .Last}}</td></tr>
+{{end}}</table>{{else}}<p>No messages.</p>{{end}}
+{{end}}`)
+
+var threadPage = page(`{{define "title"}}{{.Thread.Root.Subject}} - {{.Name}}{{end}}
+{{define "content"}}<p><a href="/forum/{{.Area.ID}}">{{.Area.Name}}</a></p>
+<h1>{{.Thread.Root.Subject}}</h1>
+{{range .Thread.Messages}}<div class="msg" id="m{{.ID}}" style="margin-left:{{indent .Depth}}em">
+<div class="hdr">#{{.ID}} {{.FromName}} &middot; {{date .CreatedAt}}{{if .ReplyToID}} &middot; <a href="#m{{.ReplyToID}}">in reply to #{{.ReplyToID}}</a>{{end}}</div>
+{{if ne .Subject $.Thread.Root.Subject}}<div>{{.Subject}}</div>{{end}}
+<pre>{{.Body}}</pre></div>
+{{end}}
+{{end}}`)
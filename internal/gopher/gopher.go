@@ -0,0 +1,316 @@
+// Package gopher serves a read-only gopher hole (RFC 1436) with the BBS's
+// bulletins, text files and selected public message areas, straight from
+// the same repositories the BBS uses.
+package gopher
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/bulletin"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+	maxSelector    = 255
+	maxMessages    = 100 // newest messages listed per area
+)
+
+// Server answers gopher requests.
+type Server struct {
+	Name      string // shown at the top of the root menu
+	Host      string // host name advertised in menu links
+	Port      int    // port advertised in menu links
+	Bulletins *bulletin.Repo
+	Messages  *message.Repo
+	Art       *ansi.Loader // resolves art-only bulletins (ASC variant)
+	TextDir   string       // .txt and .asc files listed under /text
+	Areas     []int        // message areas to publish; others are never served
+}
+
+// ListenAndServe accepts gopher connections on addr.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", addr, err)
+	}
+	defer ln.Close()
+
+	log.Printf("Gopher server listening on %s", addr)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("Gopher accept error: %v", err)
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle reads one selector, writes the reply and closes the connection.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	line, err := bufio.NewReaderSize(io.LimitReader(conn, maxSelector+2), maxSelector+2).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	selector := strings.TrimRight(line, "\r\n")
+	if i := strings.IndexByte(selector, '\t'); i >= 0 {
+		selector = selector[:i] // gopher+ and search terms are not supported
+	}
+
+	w := bufio.NewWriter(conn)
+	s.serve(w, selector)
+	w.Flush()
+}
+
+// serve writes the reply for selector.
+func (s *Server) serve(w *bufio.Writer, selector string) {
+	parts := strings.Split(strings.Trim(selector, "/"), "/")
+	switch {
+	case selector == "" || selector == "/":
+		s.root(w)
+	case parts[0] == "bulletins" && len(parts) == 1:
+		s.bulletinMenu(w)
+	case parts[0] == "bulletins" && len(parts) == 2:
+		s.bulletin(w, parts[1])
+	case parts[0] == "text" && len(parts) == 1:
+		s.textMenu(w)
+	case parts[0] == "text" && len(parts) == 2:
+		s.textFile(w, parts[1])
+	case parts[0] == "areas" && len(parts) == 1:
+		s.areaMenu(w)
+	case parts[0] == "areas" && len(parts) == 2:
+		s.messageMenu(w, parts[1])
+	case parts[0] == "areas" && len(parts) == 3:
+		s.message(w, parts[1], parts[2])
+	default:
+		s.notFound(w)
+	}
+}
+
+// item writes a menu line.
+func (s *Server) item(w io.Writer, typ byte, display, selector string) {
+	fmt.Fprintf(w, "%c%s\t%s\t%s\t%d\r\n", typ, clean(display), selector, s.Host, s.Port)
+}
+
+// info writes a non-selectable menu line.
+func (s *Server) info(w io.Writer, text string) {
+	fmt.Fprintf(w, "i%s\tfake\t(NULL)\t0\r\n", clean(text))
+}
+
+func (s *Server) notFound(w *bufio.Writer) {
+	s.item(w, '3', "Not found", "")
+	w.WriteString(".\r\n")
+}
+
+func (s *Server) root(w *bufio.Writer) {
+	s.info(w, s.Name)
+	s.info(w, "")
+	s.item(w, '1', "Bulletins", "/bulletins")
+	s.item(w, '1', "Text files", "/text")
+	if len(s.Areas) > 0 {
+		s.item(w, '1', "Message areas", "/areas")
+	}
+	w.WriteString(".\r\n")
+}
+
+func (s *Server) bulletinMenu(w *bufio.Writer) {
+	list, err := s.Bulletins.Active(time.Now())
+	if err != nil {
+		log.Printf("Gopher: %v", err)
+	}
+	if len(list) == 0 {
+		s.info(w, "No bulletins.")
+	}
+	for _, b := range list {
+		s.item(w, '0', fmt.Sprintf("%s  %s", b.PublishedAt.Local().Format("2006-01-02"), b.Title),
+			"/bulletins/"+strconv.Itoa(b.ID))
+	}
+	w.WriteString(".\r\n")
+}
+
+func (s *Server) bulletin(w *bufio.Writer, idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		s.notFound(w)
+		return
+	}
+	b, err := s.Bulletins.Get(id)
+	if err != nil || !b.Active(time.Now()) {
+		s.notFound(w)
+		return
+	}
+	body := b.Body
+	if body == "" && b.Art != "" && s.Art != nil {
+		if df, err := s.Art.Find(b.Art, false); err == nil && !df.IsANSI {
+			body = string(ansi.BlankPlaceholders(df.Data))
+		}
+	}
+	if body == "" {
+		body = "(This bulletin is only available on the BBS.)"
+	}
+	writeText(w, fmt.Sprintf("%s\n%s\n\n%s", b.Title, b.PublishedAt.Local().Format("2006-01-02 15:04"), body))
+}
+
+// textFiles returns the publishable files in TextDir, sorted by name.
+func (s *Server) textFiles() []string {
+	entries, err := os.ReadDir(s.TextDir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.Type().IsRegular() && (ext == ".txt" || ext == ".asc") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) textMenu(w *bufio.Writer) {
+	names := s.textFiles()
+	if len(names) == 0 {
+		s.info(w, "No text files.")
+	}
+	for _, name := range names {
+		s.item(w, '0', name, "/text/"+name)
+	}
+	w.WriteString(".\r\n")
+}
+
+func (s *Server) textFile(w *bufio.Writer, name string) {
+	// Only names from the listing are served, so no path can escape TextDir.
+	for _, n := range s.textFiles() {
+		if n != name {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.TextDir, n))
+		if err != nil {
+			break
+		}
+		_, data = ansi.ParseSAUCE(data)
+		writeText(w, string(ansi.BlankPlaceholders(data)))
+		return
+	}
+	s.notFound(w)
+}
+
+// area returns a published area.
+func (s *Server) area(idStr string) (*message.Area, bool) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, false
+	}
+	for _, allowed := range s.Areas {
+		if allowed == id {
+			a, err := s.Messages.GetArea(id)
+			return a, err == nil
+		}
+	}
+	return nil, false
+}
+
+func (s *Server) areaMenu(w *bufio.Writer) {
+	for _, id := range s.Areas {
+		a, err := s.Messages.GetArea(id)
+		if err != nil {
+			continue
+		}
+		display := a.Name
+		if a.Description != "" {
+			display += " - " + a.Description
+		}
+		s.item(w, '1', display, "/areas/"+strconv.Itoa(a.ID))
+	}
+	w.WriteString(".\r\n")
+}
+
+func (s *Server) messageMenu(w *bufio.Writer, areaStr string) {
+	a, ok := s.area(areaStr)
+	if !ok {
+		s.notFound(w)
+		return
+	}
+	offset := s.Messages.CountMessages(a.ID) - maxMessages
+	if offset < 0 {
+		offset = 0
+	}
+	msgs, err := s.Messages.ListMessages(a.ID, offset, maxMessages)
+	if err != nil {
+		log.Printf("Gopher: %v", err)
+	}
+
+	s.info(w, a.Name)
+	s.info(w, "")
+	shown := 0
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := msgs[i]
+		if m.ToUserID != nil {
+			continue
+		}
+		s.item(w, '0', fmt.Sprintf("%s  %-16s %s", m.CreatedAt.Local().Format("2006-01-02"), m.FromName, m.Subject),
+			fmt.Sprintf("/areas/%d/%d", a.ID, m.ID))
+		shown++
+	}
+	if shown == 0 {
+		s.info(w, "No messages.")
+	}
+	w.WriteString(".\r\n")
+}
+
+func (s *Server) message(w *bufio.Writer, areaStr, idStr string) {
+	a, ok := s.area(areaStr)
+	id, err := strconv.Atoi(idStr)
+	if !ok || err != nil {
+		s.notFound(w)
+		return
+	}
+	m, err := s.Messages.GetMessage(id)
+	if err != nil || m.AreaID != a.ID || m.ToUserID != nil {
+		s.notFound(w)
+		return
+	}
+	writeText(w, fmt.Sprintf("Area: %s\nFrom: %s\nDate: %s\nSubject: %s\n\n%s",
+		a.Name, m.FromName, m.CreatedAt.Local().Format("2006-01-02 15:04"), m.Subject, m.Body))
+}
+
+// writeText writes a type 0 document with CRLF line endings, dot-stuffed
+// and terminated with a lone ".".
+func writeText(w *bufio.Writer, text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, ".") {
+			w.WriteString(".")
+		}
+		w.WriteString(line)
+		w.WriteString("\r\n")
+	}
+	w.WriteString(".\r\n")
+}
+
+// clean strips characters that would break a menu line.
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, s)
+}
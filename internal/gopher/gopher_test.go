@@ -0,0 +1,84 @@
+package gopher
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/bulletin"
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+func fetch(t *testing.T, s *Server, selector string) string {
+	t.Helper()
+	srv, cli := net.Pipe()
+	go s.handle(srv)
+	if _, err := io.WriteString(cli, selector+"\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestGopherHole(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x'), (2, 'bob', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	messages := message.NewRepo(database.DB)
+	public, _ := messages.Post(1, 1, nil, "Hello gopher", "first line\n.dotted", nil)
+	bob := 2
+	private, _ := messages.Post(1, 1, &bob, "Secret", "for bob only", nil)
+	hidden, _ := messages.Post(2, 1, nil, "Sysop news", "not published", nil)
+
+	bulletins := bulletin.NewRepo(database.DB)
+	bulletins.Create(&bulletin.Bulletin{Title: "Welcome", Body: "Be nice.", PublishedAt: time.Now().Add(-time.Hour)})
+
+	textDir := t.TempDir()
+	os.WriteFile(filepath.Join(textDir, "rules.txt"), []byte("No flaming.\n"), 0644)
+	os.WriteFile(filepath.Join(textDir, "logo.ans"), []byte("\x1b[1mart"), 0644)
+
+	s := &Server{Name: "Test BBS", Host: "bbs.example", Port: 70, Bulletins: bulletins,
+		Messages: messages, TextDir: textDir, Areas: []int{1}}
+
+	root := fetch(t, s, "")
+	if !strings.Contains(root, "1Message areas\t/areas\tbbs.example\t70\r\n") || !strings.HasSuffix(root, ".\r\n") {
+		t.Fatalf("root menu = %q", root)
+	}
+	if got := fetch(t, s, "/bulletins/1"); !strings.Contains(got, "Be nice.") {
+		t.Fatalf("bulletin = %q", got)
+	}
+	if got := fetch(t, s, "/text"); !strings.Contains(got, "rules.txt") || strings.Contains(got, "logo.ans") {
+		t.Fatalf("text menu = %q", got)
+	}
+	if got := fetch(t, s, "/text/../../etc/passwd"); !strings.HasPrefix(got, "3") {
+		t.Fatalf("traversal = %q", got)
+	}
+
+	listing := fetch(t, s, "/areas/1")
+	if !strings.Contains(listing, "Hello gopher") || strings.Contains(listing, "Secret") {
+		t.Fatalf("area listing = %q", listing)
+	}
+	msg := fetch(t, s, "/areas/1/"+strconv.Itoa(public))
+	if !strings.Contains(msg, "From: alice") || !strings.Contains(msg, "\r\n..dotted\r\n") {
+		t.Fatalf("message = %q", msg)
+	}
+	for _, sel := range []string{"/areas/1/" + strconv.Itoa(private), "/areas/2", "/areas/2/" + strconv.Itoa(hidden), "/areas/1/" + strconv.Itoa(hidden)} {
+		if got := fetch(t, s, sel); !strings.HasPrefix(got, "3") {
+			t.Errorf("%s served: %q", sel, got)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package greeting picks the welcome shown to a caller after login from
+// the time of day, holidays and the caller's status: birthday, first call
+// or returning after a long absence.
+package greeting
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// Greeting kinds, in the order they take precedence.
+const (
+	KindBirthday  = "birthday"
+	KindFirstCall = "first_call"
+	KindHoliday   = "holiday"
+	KindReturn    = "return"
+	KindTimeOfDay = "time_of_day"
+)
+
+// Message is a greeting text and optional art. Text may use {{USER}} for
+// the caller's name and {{DAYS}} for the days since their last call. Art
+// is a display file name or pattern, picked like node:display_random.
+type Message struct {
+	Text string
+	Art  string
+}
+
+func (m Message) empty() bool {
+	return m.Text == "" && m.Art == ""
+}
+
+// Period is a time-of-day greeting from From ("HH:MM") until the next
+// period starts.
+type Period struct {
+	From string
+	Message
+}
+
+// Holiday is a greeting for Date ("MM-DD"), or from Date through Until
+// when Until is set. Ranges may wrap over New Year.
+type Holiday struct {
+	Date  string
+	Until string
+	Message
+}
+
+// Set is the configured greetings. Empty messages are skipped.
+type Set struct {
+	Periods     []Period
+	Holidays    []Holiday
+	Birthday    Message
+	FirstCall   Message
+	Return      Message
+	AbsenceDays int // days away before Return applies, 0 = never
+}
+
+// Greeting is the welcome picked for a caller.
+type Greeting struct {
+	Kind string
+	Text string // placeholders filled in
+	Art  string
+}
+
+type period struct {
+	from int // minutes after midnight
+	msg  Message
+}
+
+type holiday struct {
+	from, until int // month*100 + day
+	msg         Message
+}
+
+// Service picks greetings from a Set.
+type Service struct {
+	set      Set
+	periods  []period // by start time
+	holidays []holiday
+}
+
+// New checks set and returns a Service for it.
+func New(set Set) (*Service, error) {
+	s := &Service{set: set}
+	for _, p := range set.Periods {
+		t, err := time.Parse("15:04", p.From)
+		if err != nil {
+			return nil, fmt.Errorf("greeting period from must be HH:MM, got %q", p.From)
+		}
+		s.periods = append(s.periods, period{from: t.Hour()*60 + t.Minute(), msg: p.Message})
+	}
+	sort.SliceStable(s.periods, func(i, j int) bool { return s.periods[i].from < s.periods[j].from })
+
+	for _, h := range set.Holidays {
+		from, err := monthDay(h.Date)
+		if err != nil {
+			return nil, err
+		}
+		until := from
+		if h.Until != "" {
+			if until, err = monthDay(h.Until); err != nil {
+				return nil, err
+			}
+		}
+		s.holidays = append(s.holidays, holiday{from: from, until: until, msg: h.Message})
+	}
+	if set.AbsenceDays < 0 {
+		return nil, fmt.Errorf("greeting absence days must not be negative, got %d", set.AbsenceDays)
+	}
+	return s, nil
+}
+
+func monthDay(s string) (int, error) {
+	t, err := time.Parse("01-02", s)
+	if err != nil {
+		return 0, fmt.Errorf("greeting date must be MM-DD, got %q", s)
+	}
+	return int(t.Month())*100 + t.Day(), nil
+}
+
+// Pick returns the greeting for u at now, or nil if none applies. u may
+// be nil before login; then only holidays and the time of day apply.
+func (s *Service) Pick(u *user.User, now time.Time) *Greeting {
+	now = now.Local()
+	today := int(now.Month())*100 + now.Day()
+	days := 0
+	if u != nil && u.PreviousCallAt != nil {
+		days = int(now.Sub(*u.PreviousCallAt).Hours() / 24)
+	}
+
+	var kind string
+	var msg Message
+	switch {
+	case u != nil && u.Birthday == now.Format("01-02") && !s.set.Birthday.empty():
+		kind, msg = KindBirthday, s.set.Birthday
+	case u != nil && u.TotalCalls <= 1 && !s.set.FirstCall.empty():
+		kind, msg = KindFirstCall, s.set.FirstCall
+	default:
+		if h, ok := s.holiday(today); ok {
+			kind, msg = KindHoliday, h
+		} else if u != nil && u.PreviousCallAt != nil && s.set.AbsenceDays > 0 &&
+			days >= s.set.AbsenceDays && !s.set.Return.empty() {
+			kind, msg = KindReturn, s.set.Return
+		} else if p, ok := s.period(now.Hour()*60 + now.Minute()); ok {
+			kind, msg = KindTimeOfDay, p
+		} else {
+			return nil
+		}
+	}
+
+	name := ""
+	if u != nil {
+		name = u.Username
+	}
+	r := strings.NewReplacer("{{USER}}", name, "{{DAYS}}", strconv.Itoa(days))
+	return &Greeting{Kind: kind, Text: r.Replace(msg.Text), Art: msg.Art}
+}
+
+func (s *Service) holiday(today int) (Message, bool) {
+	for _, h := range s.holidays {
+		in := today >= h.from && today <= h.until
+		if h.from > h.until {
+			in = today >= h.from || today <= h.until
+		}
+		if in && !h.msg.empty() {
+			return h.msg, true
+		}
+	}
+	return Message{}, false
+}
+
+// period returns the message of the last period starting at or before
+// minute, wrapping to the day's last period before the first one starts.
+func (s *Service) period(minute int) (Message, bool) {
+	if len(s.periods) == 0 {
+		return Message{}, false
+	}
+	p := s.periods[len(s.periods)-1]
+	for _, cand := range s.periods {
+		if cand.from > minute {
+			break
+		}
+		p = cand
+	}
+	return p.msg, !p.msg.empty()
+}
@@ -0,0 +1,89 @@
+package greeting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+func testSet() Set {
+	return Set{
+		Periods: []Period{
+			{From: "18:00", Message: Message{Text: "Evening, {{USER}}"}},
+			{From: "06:00", Message: Message{Text: "Morning, {{USER}}"}},
+		},
+		Holidays: []Holiday{
+			{Date: "12-24", Until: "12-26", Message: Message{Text: "Merry Christmas"}},
+			{Date: "12-31", Until: "01-01", Message: Message{Art: "greet/newyear*"}},
+		},
+		Birthday:    Message{Text: "Happy birthday, {{USER}}"},
+		FirstCall:   Message{Text: "First call"},
+		Return:      Message{Text: "Back after {{DAYS}} days"},
+		AbsenceDays: 30,
+	}
+}
+
+func at(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestPick(t *testing.T) {
+	svc, err := New(testSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent := at("2026-03-09 12:00")
+	longAgo := at("2026-01-01 12:00")
+
+	tests := []struct {
+		name string
+		u    *user.User
+		now  string
+		kind string
+		text string
+	}{
+		{"morning", &user.User{Username: "alice", TotalCalls: 5, PreviousCallAt: &recent}, "2026-03-10 09:00", KindTimeOfDay, "Morning, alice"},
+		{"evening", &user.User{Username: "alice", TotalCalls: 5, PreviousCallAt: &recent}, "2026-03-10 19:30", KindTimeOfDay, "Evening, alice"},
+		{"night wraps to evening", &user.User{Username: "alice", TotalCalls: 5, PreviousCallAt: &recent}, "2026-03-10 02:00", KindTimeOfDay, "Evening, alice"},
+		{"anonymous", nil, "2026-03-10 09:00", KindTimeOfDay, "Morning, "},
+		{"holiday", &user.User{Username: "alice", TotalCalls: 5, PreviousCallAt: &recent}, "2026-12-25 09:00", KindHoliday, "Merry Christmas"},
+		{"holiday over new year", &user.User{Username: "alice", TotalCalls: 5}, "2027-01-01 09:00", KindHoliday, ""},
+		{"return", &user.User{Username: "alice", TotalCalls: 5, PreviousCallAt: &longAgo}, "2026-03-10 12:00", KindReturn, "Back after 68 days"},
+		{"first call", &user.User{Username: "bob", TotalCalls: 1}, "2026-12-25 09:00", KindFirstCall, "First call"},
+		{"birthday beats first call", &user.User{Username: "bob", TotalCalls: 1, Birthday: "12-25"}, "2026-12-25 09:00", KindBirthday, "Happy birthday, bob"},
+	}
+	for _, tt := range tests {
+		g := svc.Pick(tt.u, at(tt.now))
+		if g == nil || g.Kind != tt.kind || g.Text != tt.text {
+			t.Errorf("%s: got %+v, want %s %q", tt.name, g, tt.kind, tt.text)
+		}
+	}
+}
+
+func TestPickNothingConfigured(t *testing.T) {
+	svc, err := New(Set{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := svc.Pick(&user.User{Username: "alice", TotalCalls: 1}, time.Now()); g != nil {
+		t.Errorf("got %+v from an empty set", g)
+	}
+}
+
+func TestNewRejectsBadTimes(t *testing.T) {
+	for _, set := range []Set{
+		{Periods: []Period{{From: "25:00"}}},
+		{Holidays: []Holiday{{Date: "12/25"}}},
+		{Holidays: []Holiday{{Date: "12-24", Until: "13-01"}}},
+		{AbsenceDays: -1},
+	} {
+		if _, err := New(set); err == nil {
+			t.Errorf("New(%+v) succeeded", set)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package health serves the board's liveness and readiness checks on the
+// health port, as JSON detail an orchestrator or a sysop can read:
+// /healthz answers whether the process works at all, /readyz whether it
+// should be sent new callers.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/schedule"
+)
+
+// Listener is one of the board's listeners, for the status.
+type Listener struct {
+	Type      string
+	Addr      string
+	Listening func() bool
+}
+
+// Checker gathers the status. Fields left nil are not reported.
+type Checker struct {
+	DB        *sql.DB
+	Nodes     func() int // callers online
+	Capacity  int        // most nodes at once, 0 = no limit
+	Listeners []Listener
+	Scheduler *schedule.Scheduler
+	Doors     func() bool // whether DOS doors can run
+	Draining  func() bool // a shutdown is under way
+	Started   time.Time
+
+	// Details adds text lines such as the startup checks when
+	// ?verbose is asked for.
+	Details func() []string
+}
+
+// pingTimeout bounds the database check, so a locked database shows up
+// as a failure rather than a hung probe.
+const pingTimeout = 2 * time.Second
+
+// Status values: ok, degraded (working, but a job is failing) and fail.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+)
+
+// Status is the JSON body of both endpoints.
+type Status struct {
+	Status        string           `json:"status"`
+	Ready         bool             `json:"ready"`
+	Reason        string           `json:"reason,omitempty"` // why not ready
+	Draining      bool             `json:"draining"`
+	Started       time.Time        `json:"started"`
+	UptimeSeconds int64            `json:"uptime_seconds"`
+	Database      *DatabaseStatus  `json:"database,omitempty"`
+	Nodes         *NodeStatus      `json:"nodes,omitempty"`
+	Listeners     []ListenerStatus `json:"listeners,omitempty"`
+	Jobs          []JobStatus      `json:"jobs,omitempty"`
+	Doors         *DoorStatus      `json:"doors,omitempty"`
+	Details       []string         `json:"details,omitempty"`
+}
+
+// DatabaseStatus is the result of pinging the database.
+type DatabaseStatus struct {
+	OK        bool    `json:"ok"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// NodeStatus is how full the board is.
+type NodeStatus struct {
+	Online   int `json:"online"`
+	Capacity int `json:"capacity"` // 0 = no limit
+}
+
+// ListenerStatus is whether one listener is accepting calls.
+type ListenerStatus struct {
+	Type      string `json:"type"`
+	Addr      string `json:"addr"`
+	Listening bool   `json:"listening"`
+}
+
+// JobStatus is a scheduled job's last run, if it has run since startup,
+// and its next.
+type JobStatus struct {
+	Name     string     `json:"name"`
+	LastRun  *time.Time `json:"last_run,omitempty"`
+	OK       bool       `json:"ok"`
+	Error    string     `json:"error,omitempty"`
+	Duration float64    `json:"duration_seconds,omitempty"`
+	Next     time.Time  `json:"next"`
+}
+
+// DoorStatus is whether doors can be launched.
+type DoorStatus struct {
+	Available bool `json:"available"`
+}
+
+// Check gathers the status now. The board fails when the database does
+// not answer or no listener is up, and is degraded while a scheduled job's
+// last run failed. It is ready for callers when it works, is not draining
+// and has a free node.
+func (c *Checker) Check(ctx context.Context) Status {
+	now := time.Now()
+	s := Status{Status: StatusOK, Started: c.Started, UptimeSeconds: int64(now.Sub(c.Started).Seconds())}
+
+	if c.DB != nil {
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		start := time.Now()
+		err := c.DB.PingContext(ctx)
+		cancel()
+		s.Database = &DatabaseStatus{OK: err == nil, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+		if err != nil {
+			s.Database.Error = err.Error()
+			s.fail("database unavailable")
+		}
+	}
+
+	if len(c.Listeners) > 0 {
+		up := 0
+		for _, l := range c.Listeners {
+			ls := ListenerStatus{Type: l.Type, Addr: l.Addr, Listening: l.Listening()}
+			if ls.Listening {
+				up++
+			}
+			s.Listeners = append(s.Listeners, ls)
+		}
+		if up == 0 {
+			s.fail("no listener is accepting calls")
+		}
+	}
+
+	if c.Scheduler != nil {
+		for _, j := range c.Scheduler.Jobs() {
+			js := JobStatus{Name: j.Name, OK: true, Next: j.Next(now)}
+			if r, ok := c.Scheduler.LastRun(j.Name); ok {
+				js.LastRun = &r.Started
+				js.Duration = r.Duration.Seconds()
+				if r.Err != nil {
+					js.OK, js.Error = false, r.Err.Error()
+					if s.Status == StatusOK {
+						s.Status = StatusDegraded
+					}
+				}
+			}
+			s.Jobs = append(s.Jobs, js)
+		}
+	}
+
+	if c.Doors != nil {
+		s.Doors = &DoorStatus{Available: c.Doors()}
+	}
+
+	s.Ready = s.Status != StatusFail
+	if c.Draining != nil && c.Draining() {
+		s.Draining = true
+		s.notReady("draining for shutdown")
+	}
+	if c.Nodes != nil {
+		s.Nodes = &NodeStatus{Online: c.Nodes(), Capacity: c.Capacity}
+		if c.Capacity > 0 && s.Nodes.Online >= c.Capacity {
+			s.notReady("all nodes are in use")
+		}
+	}
+	return s
+}
+
+// fail marks the board failed, and so not ready, for reason.
+func (s *Status) fail(reason string) {
+	s.Status = StatusFail
+	s.notReady(reason)
+}
+
+// notReady records the first reason the board is not ready.
+func (s *Status) notReady(reason string) {
+	s.Ready = false
+	if s.Reason == "" {
+		s.Reason = reason
+	}
+}
+
+// ServeHealth is the liveness check: 200 unless the board fails, so an
+// orchestrator restarts it only when it is broken, not while it drains.
+func (c *Checker) ServeHealth(w http.ResponseWriter, r *http.Request) {
+	s := c.check(r)
+	code := http.StatusOK
+	if s.Status == StatusFail {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, s)
+}
+
+// ServeReady is the readiness check: 200 only while the board should get
+// new callers.
+func (c *Checker) ServeReady(w http.ResponseWriter, r *http.Request) {
+	s := c.check(r)
+	code := http.StatusOK
+	if !s.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, s)
+}
+
+func (c *Checker) check(r *http.Request) Status {
+	s := c.Check(r.Context())
+	if c.Details != nil && r.URL.Query().Has("verbose") {
+		s.Details = c.Details()
+	}
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, code int, s Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(s)
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestCheck(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	var listening, draining bool
+	online := 0
+	c := &Checker{
+		DB:        database.DB,
+		Nodes:     func() int { return online },
+		Capacity:  2,
+		Listeners: []Listener{{Type: "telnet", Addr: ":2323", Listening: func() bool { return listening }}},
+		Draining:  func() bool { return draining },
+		Started:   time.Now(),
+		Details:   func() []string { return []string{"doors: ok"} },
+	}
+
+	get := func(path string) (int, Status) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if strings.HasPrefix(path, "/readyz") {
+			c.ServeReady(rec, req)
+		} else {
+			c.ServeHealth(rec, req)
+		}
+		var s Status
+		if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return rec.Code, s
+	}
+
+	tests := []struct {
+		name           string
+		listening      bool
+		draining       bool
+		online         int
+		health, ready  int
+		status, reason string
+	}{
+		{"no listener", false, false, 0, 503, 503, StatusFail, "no listener is accepting calls"},
+		{"ok", true, false, 1, 200, 200, StatusOK, ""},
+		{"draining", true, true, 1, 200, 503, StatusOK, "draining for shutdown"},
+		{"full", true, false, 2, 200, 503, StatusOK, "all nodes are in use"},
+	}
+	for _, tt := range tests {
+		listening, draining, online = tt.listening, tt.draining, tt.online
+		code, s := get("/healthz")
+		if code != tt.health || s.Status != tt.status {
+			t.Errorf("%s: /healthz = %d %s, want %d %s", tt.name, code, s.Status, tt.health, tt.status)
+		}
+		code, s = get("/readyz")
+		if code != tt.ready || s.Reason != tt.reason {
+			t.Errorf("%s: /readyz = %d %q, want %d %q", tt.name, code, s.Reason, tt.ready, tt.reason)
+		}
+		if s.Database == nil || !s.Database.OK || s.Draining != tt.draining {
+			t.Errorf("%s: status = %+v", tt.name, s)
+		}
+	}
+
+	if _, s := get("/healthz"); s.Details != nil {
+		t.Errorf("details without verbose: %q", s.Details)
+	}
+	if _, s := get("/healthz?verbose"); len(s.Details) != 1 {
+		t.Errorf("verbose details = %q", s.Details)
+	}
+
+	database.Close()
+	if code, s := get("/healthz"); code != 503 || s.Database.OK {
+		t.Errorf("closed database: %d %+v", code, s.Database)
+	}
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// diskWarnEvery is how often one disk is reported while it stays low.
+const diskWarnEvery = 24 * time.Hour
+
+// DiskWatch alerts the sysop when a disk runs low on space.
+type DiskWatch struct {
+	Mailer  *Mailer
+	Paths   []string // directories whose disks are watched
+	MinFree int64    // bytes
+
+	mu     sync.Mutex
+	warned map[string]time.Time // by path
+}
+
+// Check alerts the sysop for each path with less than MinFree bytes free,
+// at most once a day per path.
+func (w *DiskWatch) Check() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.warned == nil {
+		w.warned = make(map[string]time.Time)
+	}
+	for _, path := range w.Paths {
+		free, err := diskFree(path)
+		if err != nil {
+			return fmt.Errorf("disk space of %s: %w", path, err)
+		}
+		if free >= w.MinFree {
+			delete(w.warned, path)
+			continue
+		}
+		if time.Since(w.warned[path]) < diskWarnEvery {
+			continue
+		}
+		if err := w.Mailer.NotifySysop("disk_space", map[string]any{"Path": path, "FreeMB": free >> 20}); err != nil {
+			return err
+		}
+		w.warned[path] = time.Now()
+	}
+	return nil
+}
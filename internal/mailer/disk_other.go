@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package mailer
+
+import "errors"
+
+// diskFree is not implemented on this platform.
+func diskFree(path string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
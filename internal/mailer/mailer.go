@@ -0,0 +1,215 @@
+// Package mailer sends the board's own email: password reset codes,
+// new-mail notices users opt in to, and alerts for the sysop. Mail is
+// queued in the database and sent by Flush, so a mail server that is down
+// or slow never holds up a caller; failed deliveries are retried with a
+// growing delay until the retries run out.
+package mailer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is the outgoing mail server and what is sent through it.
+type Config struct {
+	Server   string // "host:port"
+	TLS      bool   // connect with TLS (port 465); otherwise STARTTLS when offered
+	Username string // "" = no authentication
+	Password string
+	From     string // sender address
+	Sysop    string // where sysop alerts go, "" = nowhere
+	BBSName  string // for templates and the From name
+	Timeout  time.Duration
+
+	// Templates is a directory whose <kind>.txt files replace the
+	// built-in templates; "" = built-ins only.
+	Templates string
+	Retries   int // delivery attempts before a mail is dropped
+}
+
+// Mailer queues and sends mail.
+type Mailer struct {
+	db        *sql.DB
+	cfg       Config
+	templates *templates
+	flushMu   sync.Mutex // one Flush at a time
+
+	// send delivers one message and kick starts a flush in the
+	// background; replaced in tests.
+	send func(to string, msg []byte) error
+	kick func()
+}
+
+// ErrNoSysop is returned for sysop alerts when no sysop address is set.
+var ErrNoSysop = errors.New("no sysop address configured")
+
+// New creates a mailer for cfg, loading the templates.
+func New(db *sql.DB, cfg Config) (*Mailer, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = 8
+	}
+	t, err := loadTemplates(cfg.Templates)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mailer{db: db, cfg: cfg, templates: t}
+	m.send = func(to string, msg []byte) error { return deliver(cfg, to, msg) }
+	m.kick = func() {
+		go func() {
+			if _, err := m.Flush(); err != nil {
+				log.Printf("Mail: %v", err)
+			}
+		}()
+	}
+	return m, nil
+}
+
+// Queue stores a mail for delivery and starts a flush, so mail goes out
+// right away while the server is reachable.
+func (m *Mailer) Queue(to, subject, body string) error {
+	to = strings.TrimSpace(to)
+	if !validAddress(to) {
+		return fmt.Errorf("queue mail: invalid address %q", to)
+	}
+	_, err := m.db.Exec(`
+		INSERT INTO mail_queue (to_addr, subject, body, next_try_at) VALUES (?, ?, ?, ?)
+	`, to, subject, body, time.Now())
+	if err != nil {
+		return fmt.Errorf("queue mail: %w", err)
+	}
+	m.kick()
+	return nil
+}
+
+// Notify renders the template kind with data and queues the result for
+// to. The template also gets .BBS, the board's name.
+func (m *Mailer) Notify(to, kind string, data map[string]any) error {
+	subject, body, err := m.templates.render(kind, m.cfg.BBSName, data)
+	if err != nil {
+		return err
+	}
+	return m.Queue(to, subject, body)
+}
+
+// NotifySysop is Notify to the sysop address.
+func (m *Mailer) NotifySysop(kind string, data map[string]any) error {
+	if m.cfg.Sysop == "" {
+		return ErrNoSysop
+	}
+	return m.Notify(m.cfg.Sysop, kind, data)
+}
+
+// CanAlertSysop reports whether sysop alerts have somewhere to go.
+func (m *Mailer) CanAlertSysop() bool {
+	return m.cfg.Sysop != ""
+}
+
+// maxBackoff caps the delay between attempts at one mail.
+const maxBackoff = 6 * time.Hour
+
+// backoff is the delay after the nth failed attempt: one minute, doubling.
+func backoff(n int) time.Duration {
+	if n > 16 {
+		return maxBackoff
+	}
+	return min(time.Minute<<(n-1), maxBackoff)
+}
+
+// queued is a mail waiting in the queue.
+type queued struct {
+	id                int
+	to, subject, body string
+	attempts          int
+}
+
+// Flush sends the queued mail that is due and returns how many were sent.
+// A failed mail is tried again later; after Config.Retries attempts it is
+// dropped and logged. A flush already running makes this one a no-op.
+func (m *Mailer) Flush() (int, error) {
+	if !m.flushMu.TryLock() {
+		return 0, nil
+	}
+	defer m.flushMu.Unlock()
+
+	rows, err := m.db.Query(`
+		SELECT id, to_addr, subject, body, attempts FROM mail_queue
+		WHERE next_try_at <= ? ORDER BY id
+	`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("flush mail: %w", err)
+	}
+	var due []queued
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.id, &q.to, &q.subject, &q.body, &q.attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("flush mail: %w", err)
+		}
+		due = append(due, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("flush mail: %w", err)
+	}
+
+	sent := 0
+	for _, q := range due {
+		msg := compose(m.cfg, q.to, q.subject, q.body, time.Now())
+		if sendErr := m.send(q.to, msg); sendErr != nil {
+			if err := m.failed(q, sendErr); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if _, err := m.db.Exec(`DELETE FROM mail_queue WHERE id = ?`, q.id); err != nil {
+			return sent, fmt.Errorf("flush mail: %w", err)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// failed records a failed attempt at q, dropping it when out of retries.
+func (m *Mailer) failed(q queued, sendErr error) error {
+	attempts := q.attempts + 1
+	if attempts >= m.cfg.Retries {
+		log.Printf("Mail: giving up on %q to %s after %d attempts: %v", q.subject, q.to, attempts, sendErr)
+		_, err := m.db.Exec(`DELETE FROM mail_queue WHERE id = ?`, q.id)
+		if err != nil {
+			return fmt.Errorf("flush mail: %w", err)
+		}
+		return nil
+	}
+	log.Printf("Mail: %q to %s failed, retrying: %v", q.subject, q.to, sendErr)
+	_, err := m.db.Exec(`
+		UPDATE mail_queue SET attempts = ?, last_error = ?, next_try_at = ? WHERE id = ?
+	`, attempts, sendErr.Error(), time.Now().Add(backoff(attempts)), q.id)
+	if err != nil {
+		return fmt.Errorf("flush mail: %w", err)
+	}
+	return nil
+}
+
+// Pending returns how many mails are waiting in the queue.
+func (m *Mailer) Pending() (int, error) {
+	var n int
+	if err := m.db.QueryRow(`SELECT COUNT(*) FROM mail_queue`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count queued mail: %w", err)
+	}
+	return n, nil
+}
+
+// validAddress is a loose check for one plain address, enough to keep
+// header injection and obvious typos out of the queue.
+func validAddress(addr string) bool {
+	at := strings.LastIndex(addr, "@")
+	return at > 0 && at < len(addr)-1 && !strings.ContainsAny(addr, " \t\r\n<>,;\"")
+}
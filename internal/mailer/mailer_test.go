@@ -0,0 +1,170 @@
+package mailer
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func newTestMailer(t *testing.T, cfg Config) (*Mailer, *db.DB) {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	m, err := New(database.DB, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.kick = func() {}
+	return m, database
+}
+
+func TestQueueRetries(t *testing.T) {
+	m, database := newTestMailer(t, Config{From: "bbs@example.org", Sysop: "sysop@example.org", BBSName: "Twilight", Retries: 2})
+	var sent []string
+	fail := true
+	m.send = func(to string, msg []byte) error {
+		if fail {
+			return errors.New("connection refused")
+		}
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	if err := m.Queue("bob@example.org\r\nBcc: x@y", "hi", "body"); err == nil {
+		t.Error("address with a header in it queued")
+	}
+	if err := m.NotifySysop("new_user", map[string]any{"User": "alice", "Level": 10}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.Flush(); n != 0 || err != nil {
+		t.Fatalf("flush with the server down = %d, %v", n, err)
+	}
+	// The retry waits; make it due.
+	database.Exec(`UPDATE mail_queue SET next_try_at = ?`, time.Now().Add(-time.Second))
+	fail = false
+	if n, err := m.Flush(); n != 1 || err != nil {
+		t.Fatalf("flush = %d, %v", n, err)
+	}
+	if !strings.Contains(sent[0], "Subject: New user on Twilight: alice") || !strings.Contains(sent[0], "at level 10") {
+		t.Errorf("sent:\n%s", sent[0])
+	}
+
+	fail = true
+	m.Queue("bob@example.org", "hi", "body")
+	m.Flush()
+	database.Exec(`UPDATE mail_queue SET next_try_at = ?`, time.Now().Add(-time.Second))
+	m.Flush()
+	if n, _ := m.Pending(); n != 0 {
+		t.Errorf("%d mails left after the retries ran out", n)
+	}
+	if backoff(1) != time.Minute || backoff(3) != 4*time.Minute || backoff(40) != maxBackoff {
+		t.Error("backoff does not double up to the cap")
+	}
+}
+
+func TestTemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "reset_code.txt"), []byte("Subject: Code {{.Code}}\n\nUse {{.Code}} on {{.BBS}}.\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "new_mail.txt"), []byte("no subject here\n"), 0644)
+	m, _ := newTestMailer(t, Config{Templates: dir, BBSName: "Twilight"})
+
+	subject, body, err := m.templates.render("reset_code", "Twilight", map[string]any{"Code": "abcd-efgh"})
+	if err != nil || subject != "Code abcd-efgh" || body != "Use abcd-efgh on Twilight.\n" {
+		t.Errorf("render = %q, %q, %v", subject, body, err)
+	}
+	if _, _, err := m.templates.render("new_mail", "Twilight", nil); err == nil {
+		t.Error("template without a subject rendered")
+	}
+	if _, _, err := m.templates.render("nonesuch", "Twilight", nil); err == nil {
+		t.Error("unknown template rendered")
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 test ESMTP")
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 test")
+			case "DATA":
+				reply("354 go on")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					lines = append(lines, strings.TrimRight(l, "\r\n"))
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				got <- lines
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	cfg := Config{Server: ln.Addr().String(), From: "bbs@example.org", BBSName: "Twilight", Timeout: 5 * time.Second}
+	msg := compose(cfg, "bob@example.org", "Grüße", "line one\nline two", time.Now())
+	if err := deliver(cfg, "bob@example.org", msg); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Join(<-got, "\n")
+	for _, want := range []string{"MAIL FROM:<bbs@example.org>", "RCPT TO:<bob@example.org>",
+		"Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=", "From: Twilight <bbs@example.org>", "line one\nline two"} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("session lacks %q:\n%s", want, lines)
+		}
+	}
+}
+
+func TestDiskWatch(t *testing.T) {
+	m, _ := newTestMailer(t, Config{From: "bbs@example.org", Sysop: "sysop@example.org"})
+	w := &DiskWatch{Mailer: m, Paths: []string{t.TempDir()}, MinFree: 1 << 62}
+	for range 2 {
+		if err := w.Check(); errors.Is(err, errors.ErrUnsupported) {
+			t.Skip(err)
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := m.Pending(); n != 1 {
+		t.Errorf("%d alerts queued, want one a day", n)
+	}
+}
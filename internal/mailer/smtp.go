@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// deliver sends msg to one recipient through the server in cfg.
+func deliver(cfg Config, to string, msg []byte) error {
+	host, _, err := net.SplitHostPort(cfg.Server)
+	if err != nil {
+		return fmt.Errorf("smtp server %q: %w", cfg.Server, err)
+	}
+	d := &net.Dialer{Timeout: cfg.Timeout}
+	var conn net.Conn
+	if cfg.TLS {
+		conn, err = tls.DialWithDialer(d, "tcp", cfg.Server, &tls.Config{ServerName: host})
+	} else {
+		conn, err = d.Dial("tcp", cfg.Server)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", cfg.Server, err)
+	}
+	conn.SetDeadline(time.Now().Add(cfg.Timeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connect to %s: %w", cfg.Server, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && !cfg.TLS {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		// PlainAuth refuses to send the password without TLS unless the
+		// server is local.
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(cfg.From); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(to); err != nil {
+		return fmt.Errorf("smtp RCPT TO %s: %w", to, err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return c.Quit()
+}
+
+// compose builds a plain-text UTF-8 message.
+func compose(cfg Config, to, subject, body string, now time.Time) []byte {
+	var b bytes.Buffer
+	from := cfg.From
+	if cfg.BBSName != "" {
+		from = mime.QEncoding.Encode("utf-8", cfg.BBSName) + " <" + cfg.From + ">"
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(cfg.From, "@"); at >= 0 {
+		domain = cfg.From[at+1:]
+	}
+	id := make([]byte, 12)
+	rand.Read(id)
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", oneLine(subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	b.WriteString("Auto-Submitted: auto-generated\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")))
+	qp.Close()
+	return b.Bytes()
+}
+
+// oneLine keeps a header value on one line.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
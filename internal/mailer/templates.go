@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// builtins are the templates used unless the templates directory has a
+// file of the same kind. Each is a Subject line, a blank line and the
+// body.
+var builtins = map[string]string{
+	"reset_code": `Subject: Your {{.BBS}} password reset code
+
+Hello {{.User}},
+
+Someone, hopefully you, asked to reset your password on {{.BBS}}.
+Your reset code is:
+
+    {{.Code}}
+
+It is valid for {{.Minutes}} minutes. If you did not ask for it, ignore
+this mail; your password stays as it is.
+`,
+	"new_mail": `Subject: New private mail on {{.BBS}} from {{.From}}
+
+Hello {{.User}},
+
+{{.From}} sent you private mail on {{.BBS}}:
+
+    {{.Subject}}
+
+Call in to read it. You can turn these notices off in your account
+settings.
+`,
+	"new_user": `Subject: New user on {{.BBS}}: {{.User}}
+
+{{.User}}{{if .Location}} from {{.Location}}{{end}} registered at level {{.Level}}.
+Check the account in bbs-admin and raise the level to validate it.
+`,
+	"disk_space": `Subject: {{.BBS}} is running out of disk space
+
+Only {{.FreeMB}} MB is free on the disk holding {{.Path}}.
+`,
+	"sysop": `Subject: {{.Subject}}
+
+{{.Body}}
+{{if .User}}
+-- sent by a script for {{.User}}
+{{end}}`,
+}
+
+// templates holds the parsed templates by kind.
+type templates struct {
+	byKind map[string]*template.Template
+}
+
+// loadTemplates parses the built-ins and any overrides in dir.
+func loadTemplates(dir string) (*templates, error) {
+	t := &templates{byKind: make(map[string]*template.Template)}
+	for kind, text := range builtins {
+		if dir != "" {
+			data, err := os.ReadFile(filepath.Join(dir, kind+".txt"))
+			if err == nil {
+				text = string(data)
+			} else if !os.IsNotExist(err) {
+				return nil, fmt.Errorf("mail template %s: %w", kind, err)
+			}
+		}
+		tmpl, err := template.New(kind).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("mail template %s: %w", kind, err)
+		}
+		t.byKind[kind] = tmpl
+	}
+	return t, nil
+}
+
+// render fills in template kind and splits off its subject.
+func (t *templates) render(kind, bbs string, data map[string]any) (subject, body string, err error) {
+	tmpl, ok := t.byKind[kind]
+	if !ok {
+		return "", "", fmt.Errorf("no mail template %q", kind)
+	}
+	vars := map[string]any{"BBS": bbs}
+	for k, v := range data {
+		vars[k] = v
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", "", fmt.Errorf("mail template %s: %w", kind, err)
+	}
+	head, body, _ := strings.Cut(strings.ReplaceAll(b.String(), "\r\n", "\n"), "\n\n")
+	for _, line := range strings.Split(head, "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "subject") {
+			subject = strings.TrimSpace(value)
+		}
+	}
+	if subject == "" {
+		return "", "", fmt.Errorf("mail template %s: no Subject line", kind)
+	}
+	return subject, body, nil
+}
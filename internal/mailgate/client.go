@@ -0,0 +1,204 @@
+package mailgate
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client is a minimal IMAP4rev1 client (RFC 3501): enough to log in, find
+// unseen messages in a mailbox, fetch them and flag them.
+type Client struct {
+	netConn net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	tag     int
+}
+
+// response is an untagged response line with any literals it carried.
+type response struct {
+	line     string
+	literals [][]byte
+}
+
+// maxLiteral bounds a single literal, i.e. one message, read from the
+// server.
+const maxLiteral = 64 << 20
+
+var literalRe = regexp.MustCompile(`\{(\d+)\}$`)
+
+// Dial connects to addr ("host:port"), over TLS when useTLS is set, and
+// logs in.
+func Dial(addr string, useTLS bool, username, password string, timeout time.Duration) (*Client, error) {
+	d := &net.Dialer{Timeout: timeout}
+	var nc net.Conn
+	var err error
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		nc, err = tls.DialWithDialer(d, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		nc, err = d.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	c := &Client{netConn: nc, r: bufio.NewReader(nc), timeout: timeout}
+	c.deadline()
+
+	greeting, err := c.readLine()
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		nc.Close()
+		return nil, fmt.Errorf("connect to %s: %s", addr, greeting)
+	}
+	if _, err := c.cmd("LOGIN %s %s", quote(username), quote(password)); err != nil {
+		c.netConn.Close()
+		return nil, fmt.Errorf("login as %s: %w", username, err)
+	}
+	return c, nil
+}
+
+func (c *Client) deadline() {
+	if c.timeout > 0 {
+		c.netConn.SetDeadline(time.Now().Add(c.timeout))
+	}
+}
+
+// quote makes s an IMAP quoted string.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func (c *Client) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// cmd sends a tagged command and reads responses up to its completion. It
+// returns the untagged responses, or an error unless the command
+// completed OK.
+func (c *Client) cmd(format string, args ...any) ([]response, error) {
+	c.deadline()
+	c.tag++
+	tag := fmt.Sprintf("a%d", c.tag)
+	if _, err := fmt.Fprintf(c.netConn, tag+" "+format+"\r\n", args...); err != nil {
+		return nil, err
+	}
+
+	var resps []response
+	for {
+		c.deadline()
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if rest, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return nil, errors.New(rest)
+			}
+			return resps, nil
+		}
+		resp := response{line: line}
+		for {
+			m := literalRe.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			n, _ := strconv.Atoi(m[1])
+			if n > maxLiteral {
+				return nil, fmt.Errorf("literal of %d bytes is too large", n)
+			}
+			lit := make([]byte, n)
+			if _, err := io.ReadFull(c.r, lit); err != nil {
+				return nil, err
+			}
+			resp.literals = append(resp.literals, lit)
+			if line, err = c.readLine(); err != nil {
+				return nil, err
+			}
+			resp.line += line
+		}
+		resps = append(resps, resp)
+	}
+}
+
+// Select opens a mailbox.
+func (c *Client) Select(mailbox string) error {
+	if _, err := c.cmd("SELECT %s", quote(mailbox)); err != nil {
+		return fmt.Errorf("select %s: %w", mailbox, err)
+	}
+	return nil
+}
+
+// Unseen returns the UIDs of the messages not yet flagged \Seen, lowest
+// first.
+func (c *Client) Unseen() ([]uint32, error) {
+	resps, err := c.cmd("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	var uids []uint32
+	for _, r := range resps {
+		rest, ok := strings.CutPrefix(r.line, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, f := range strings.Fields(rest) {
+			if v, err := strconv.ParseUint(f, 10, 32); err == nil {
+				uids = append(uids, uint32(v))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// Fetch returns a whole message, headers and body, without marking it
+// \Seen.
+func (c *Client) Fetch(uid uint32) ([]byte, error) {
+	resps, err := c.cmd("UID FETCH %d BODY.PEEK[]", uid)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %d: %w", uid, err)
+	}
+	for _, r := range resps {
+		if strings.Contains(r.line, "FETCH") && len(r.literals) > 0 {
+			return r.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("fetch %d: no such message", uid)
+}
+
+// Flag adds flags (e.g. `\Seen`) to a message.
+func (c *Client) Flag(uid uint32, flags ...string) error {
+	if _, err := c.cmd("UID STORE %d +FLAGS.SILENT (%s)", uid, strings.Join(flags, " ")); err != nil {
+		return fmt.Errorf("flag %d: %w", uid, err)
+	}
+	return nil
+}
+
+// Expunge removes the messages flagged \Deleted.
+func (c *Client) Expunge() error {
+	if _, err := c.cmd("EXPUNGE"); err != nil {
+		return fmt.Errorf("expunge: %w", err)
+	}
+	return nil
+}
+
+// Close logs out and closes the connection.
+func (c *Client) Close() error {
+	c.cmd("LOGOUT")
+	return c.netConn.Close()
+}
@@ -0,0 +1,375 @@
+// Package mailgate makes the board reachable by email. A mailbox is
+// polled over IMAP; mail addressed to a user becomes private mail from the
+// gateway account, other mail is posted in a feedback area, and
+// attachments are put in a quarantine file area for the sysop to check.
+// Automatic replies, bounces, list mail and mail the gateway has seen
+// before are skipped, so the gateway cannot take part in a mail loop.
+package mailgate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// Config is the gateway's mailbox and delivery rules.
+type Config struct {
+	Server   string // "host:port"
+	TLS      bool
+	Username string
+	Password string
+	Mailbox  string // default "INBOX"
+	Timeout  time.Duration
+
+	// Address is the gateway's own address. Mail to it goes to the
+	// feedback area, mail to address+name to the user name, and mail
+	// from it or looping through it is skipped.
+	Address        string
+	UserID         int               // local account mail is delivered from
+	Users          map[string]string // address local part (lower case) → user name
+	MapUsernames   bool              // also deliver name@domain to the user name
+	FeedbackArea   int               // message area for mail to no user, 0 = skip it
+	QuarantineArea int               // file area for attachments, 0 = drop them
+	MaxAttachment  int64             // bytes; larger attachments are dropped
+	MaxPerSender   int               // mails imported per sender per day, 0 = unlimited
+	MaxMessages    int               // mails handled per sync
+	Delete         bool              // delete handled mail instead of marking it read
+}
+
+// Report is the result of a sync.
+type Report struct {
+	Delivered   int // private mails stored, one per recipient
+	Posted      int // messages posted in the feedback area
+	Quarantined int // attachments stored
+	Skipped     int
+}
+
+// Lines formats the report for logs.
+func (r *Report) Lines() []string {
+	return []string{fmt.Sprintf("delivered %d private mail(s), posted %d, quarantined %d attachment(s), skipped %d",
+		r.Delivered, r.Posted, r.Quarantined, r.Skipped)}
+}
+
+// Gateway imports mail from the mailbox.
+type Gateway struct {
+	mu    sync.Mutex // one sync at a time
+	db    *sql.DB
+	msgs  *message.Repo
+	users *user.Repo
+	files *filearea.Repo
+	cfg   Config
+
+	Events *event.Bus // receives event.Post for mail posted to the feedback area
+
+	// Notify tells a user who is online about new private mail; nil
+	// skips it.
+	Notify func(username, text string)
+
+	// dial connects to the server; replaced in tests.
+	dial func() (*Client, error)
+}
+
+// NewGateway creates a gateway for cfg.
+func NewGateway(db *sql.DB, msgs *message.Repo, users *user.Repo, files *filearea.Repo, cfg Config) *Gateway {
+	if cfg.Mailbox == "" {
+		cfg.Mailbox = "INBOX"
+	}
+	if cfg.MaxMessages <= 0 {
+		cfg.MaxMessages = 50
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	cfg.Address = strings.ToLower(cfg.Address)
+	g := &Gateway{db: db, msgs: msgs, users: users, files: files, cfg: cfg}
+	g.dial = func() (*Client, error) {
+		return Dial(cfg.Server, cfg.TLS, cfg.Username, cfg.Password, cfg.Timeout)
+	}
+	return g
+}
+
+// Sync handles the mailbox's unseen mail. Each mail is marked read (or
+// deleted) once handled, whether it was imported or skipped.
+func (g *Gateway) Sync() (*Report, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, err := g.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	if err := c.Select(g.cfg.Mailbox); err != nil {
+		return nil, err
+	}
+	uids, err := c.Unseen()
+	if err != nil {
+		return nil, err
+	}
+	if len(uids) > g.cfg.MaxMessages {
+		uids = uids[:g.cfg.MaxMessages]
+	}
+
+	report := &Report{}
+	flags := []string{`\Seen`}
+	if g.cfg.Delete {
+		flags = append(flags, `\Deleted`)
+	}
+	for _, uid := range uids {
+		raw, err := c.Fetch(uid)
+		if err != nil {
+			return report, err
+		}
+		if err := g.handle(raw, report); err != nil {
+			return report, err
+		}
+		if err := c.Flag(uid, flags...); err != nil {
+			return report, err
+		}
+	}
+	if g.cfg.Delete && len(uids) > 0 {
+		if err := c.Expunge(); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// handle imports one mail. Only database and file errors are returned;
+// mail that cannot be imported is logged and skipped.
+func (g *Gateway) handle(raw []byte, report *Report) error {
+	m, err := ParseMail(raw)
+	if err != nil {
+		log.Printf("Email: %v", err)
+		report.Skipped++
+		return nil
+	}
+	reason, err := g.refuse(m)
+	if err != nil {
+		return err
+	}
+	recipients := g.recipients(m)
+	if reason == "" && len(recipients) == 0 && g.cfg.FeedbackArea == 0 {
+		reason = "no recipient"
+	}
+	if reason != "" {
+		log.Printf("Email: skipped %s from %s: %s", m.MessageID, m.FromAddr, reason)
+		report.Skipped++
+		return nil
+	}
+
+	// Record the mail first: if delivery fails half way, it is not
+	// delivered twice on the next sync.
+	if _, err := g.db.Exec(`INSERT INTO mail_imports (message_id, sender) VALUES (?, ?)`, m.MessageID, m.FromAddr); err != nil {
+		return fmt.Errorf("record %s: %w", m.MessageID, err)
+	}
+
+	notes, err := g.quarantine(m, report)
+	if err != nil {
+		return err
+	}
+	body := m.Body
+	if len(notes) > 0 {
+		body += "\n\n" + strings.Join(notes, "\n")
+	}
+
+	if len(recipients) == 0 {
+		_, err := g.msgs.Import(g.cfg.FeedbackArea, g.cfg.UserID, m.FromName,
+			m.Subject, "From: "+m.FromAddr+"\n\n"+body, nil, m.Date)
+		if err != nil {
+			return err
+		}
+		g.Events.Publish(event.Event{Name: event.Post, Data: g.cfg.FeedbackArea})
+		report.Posted++
+		return nil
+	}
+	header := fmt.Sprintf("From: %s <%s>\nDate: %s\n\n", m.FromName, m.FromAddr, m.Date.Format("2006-01-02 15:04"))
+	for _, u := range recipients {
+		if _, err := g.msgs.SendPrivate(g.cfg.UserID, u.ID, m.Subject, header+body); err != nil {
+			return err
+		}
+		report.Delivered++
+		if g.Notify != nil {
+			g.Notify(u.Username, fmt.Sprintf("You have new email from %s: %s", m.FromName, m.Subject))
+		}
+	}
+	return nil
+}
+
+// refuse returns why a mail must not be imported, or "".
+func (g *Gateway) refuse(m *Mail) (string, error) {
+	h := m.Header
+	switch {
+	case m.FromAddr == "":
+		return "no sender", nil
+	case g.cfg.Address != "" && m.FromAddr == g.cfg.Address:
+		return "sent by the gateway", nil
+	case g.cfg.Address != "" && strings.Contains(strings.ToLower(h.Get("X-Loop")), g.cfg.Address):
+		return "mail loop", nil
+	case h.Get("Auto-Submitted") != "" && !strings.EqualFold(strings.TrimSpace(h.Get("Auto-Submitted")), "no"):
+		return "automatic reply", nil
+	case strings.TrimSpace(h.Get("Return-Path")) == "<>", isDaemon(m.FromAddr):
+		return "bounce", nil
+	case h.Get("List-Id") != "":
+		return "mailing list", nil
+	}
+	switch strings.ToLower(strings.TrimSpace(h.Get("Precedence"))) {
+	case "bulk", "junk", "list":
+		return "bulk mail", nil
+	}
+
+	var n int
+	if err := g.db.QueryRow(`SELECT COUNT(*) FROM mail_imports WHERE message_id = ?`, m.MessageID).Scan(&n); err != nil {
+		return "", fmt.Errorf("look up %s: %w", m.MessageID, err)
+	}
+	if n > 0 {
+		return "already imported", nil
+	}
+	if g.cfg.MaxPerSender > 0 {
+		err := g.db.QueryRow(`
+			SELECT COUNT(*) FROM mail_imports WHERE sender = ? AND imported_at > datetime('now', '-1 day')
+		`, m.FromAddr).Scan(&n)
+		if err != nil {
+			return "", fmt.Errorf("count mail from %s: %w", m.FromAddr, err)
+		}
+		if n >= g.cfg.MaxPerSender {
+			return "too many mails from sender today", nil
+		}
+	}
+	return "", nil
+}
+
+// isDaemon reports whether addr belongs to mail software rather than a
+// person.
+func isDaemon(addr string) bool {
+	local, _, _ := strings.Cut(addr, "@")
+	switch local {
+	case "mailer-daemon", "postmaster", "noreply", "no-reply", "do-not-reply":
+		return true
+	}
+	return false
+}
+
+// recipients returns the users a mail is addressed to. Mail to the
+// gateway address itself, or to no known user, has none.
+func (g *Gateway) recipients(m *Mail) []*user.User {
+	ownLocal, ownDomain, _ := strings.Cut(g.cfg.Address, "@")
+	var out []*user.User
+	seen := make(map[int]bool)
+	for _, addr := range m.Recipients {
+		local, domain, _ := strings.Cut(addr, "@")
+		if g.cfg.Address != "" {
+			if domain != ownDomain {
+				continue // someone else on Cc
+			}
+			if base, tag, ok := strings.Cut(local, "+"); ok && base == ownLocal {
+				local = tag
+			} else if local == ownLocal {
+				continue
+			}
+		}
+		if u := g.lookup(local); u != nil && !seen[u.ID] && u.ID != g.cfg.UserID {
+			seen[u.ID] = true
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// lookup maps an address local part to a user, through the Users map or,
+// with MapUsernames, by name with '.' or '_' standing for spaces.
+func (g *Gateway) lookup(local string) *user.User {
+	names := []string{}
+	if name, ok := g.cfg.Users[local]; ok {
+		names = append(names, name)
+	} else if g.cfg.MapUsernames {
+		names = append(names, local, strings.ReplaceAll(local, ".", " "), strings.ReplaceAll(local, "_", " "))
+	}
+	for _, name := range names {
+		if u, err := g.users.GetByUsername(name); err == nil {
+			return u
+		}
+	}
+	return nil
+}
+
+// quarantine stores a mail's attachments in the quarantine area and
+// returns a note for each, to append to the message.
+func (g *Gateway) quarantine(m *Mail, report *Report) ([]string, error) {
+	if len(m.Attachments) == 0 {
+		return nil, nil
+	}
+	var notes []string
+	var area *filearea.Area
+	if g.cfg.QuarantineArea > 0 {
+		a, err := g.files.GetArea(g.cfg.QuarantineArea)
+		if err != nil {
+			return nil, err
+		}
+		area = a
+	}
+	for _, att := range m.Attachments {
+		switch {
+		case area == nil:
+			notes = append(notes, fmt.Sprintf("[Attachment %s dropped]", safeName(att.Name)))
+		case g.cfg.MaxAttachment > 0 && int64(len(att.Data)) > g.cfg.MaxAttachment:
+			notes = append(notes, fmt.Sprintf("[Attachment %s dropped: %d bytes is too large]", safeName(att.Name), len(att.Data)))
+		default:
+			name, err := g.store(area, m, att)
+			if err != nil {
+				return nil, err
+			}
+			notes = append(notes, fmt.Sprintf("[Attachment %s held for the sysop to check]", name))
+			report.Quarantined++
+		}
+	}
+	return notes, nil
+}
+
+// store writes an attachment into the area under a name not yet taken and
+// catalogs it.
+func (g *Gateway) store(area *filearea.Area, m *Mail, att Attachment) (string, error) {
+	if err := os.MkdirAll(area.DiskPath, 0755); err != nil {
+		return "", err
+	}
+	base := safeName(att.Name)
+	for i := 0; ; i++ {
+		name := base
+		if i > 0 {
+			name = fmt.Sprintf("%d-%s", i, base)
+		}
+		if _, err := g.files.GetFileByName(area.ID, name); err == nil {
+			continue
+		}
+		f, err := os.OpenFile(filepath.Join(area.DiskPath, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(att.Data); err != nil {
+			f.Close()
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+		desc := clip(fmt.Sprintf("Email from %s: %s", m.FromAddr, m.Subject), 255)
+		if _, err := g.files.AddEntry(area.ID, name, desc, int64(len(att.Data)), g.cfg.UserID); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+}
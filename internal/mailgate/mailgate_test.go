@@ -0,0 +1,239 @@
+package mailgate
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// fakeServer is a one-mailbox IMAP server.
+type fakeServer struct {
+	mu    sync.Mutex
+	mails []string
+	flags map[int]string // by UID (index+1)
+	l     net.Listener
+}
+
+func newFakeServer(t *testing.T, mails ...string) *fakeServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{mails: mails, flags: make(map[int]string), l: l}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	fmt.Fprintf(c, "* OK fake IMAP ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue
+		}
+		tag, cmd := f[0], strings.ToUpper(strings.Join(f[1:min(3, len(f))], " "))
+		s.mu.Lock()
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN"):
+			if f[3] != `"secret"` {
+				fmt.Fprintf(c, "%s NO bad password\r\n", tag)
+				break
+			}
+			fmt.Fprintf(c, "%s OK logged in\r\n", tag)
+		case strings.HasPrefix(cmd, "SELECT"):
+			fmt.Fprintf(c, "* %d EXISTS\r\n* OK [UIDVALIDITY 1] ok\r\n%s OK [READ-WRITE] done\r\n", len(s.mails), tag)
+		case cmd == "UID SEARCH":
+			var uids []string
+			for i := range s.mails {
+				if !strings.Contains(s.flags[i+1], `\Seen`) {
+					uids = append(uids, strconv.Itoa(i+1))
+				}
+			}
+			fmt.Fprintf(c, "* SEARCH %s\r\n%s OK done\r\n", strings.Join(uids, " "), tag)
+		case cmd == "UID FETCH":
+			uid, _ := strconv.Atoi(f[3])
+			m := strings.ReplaceAll(s.mails[uid-1], "\n", "\r\n")
+			fmt.Fprintf(c, "* %d FETCH (UID %d BODY[] {%d}\r\n%s)\r\n%s OK done\r\n", uid, uid, len(m), m, tag)
+		case cmd == "UID STORE":
+			uid, _ := strconv.Atoi(f[3])
+			s.flags[uid] += strings.Join(f[5:], " ")
+			fmt.Fprintf(c, "%s OK done\r\n", tag)
+		case cmd == "LOGOUT":
+			fmt.Fprintf(c, "* BYE\r\n%s OK bye\r\n", tag)
+			s.mu.Unlock()
+			return
+		default:
+			fmt.Fprintf(c, "%s BAD what?\r\n", tag)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func email(id, from, to, extra, body string) string {
+	return fmt.Sprintf("From: %s\nTo: %s\nSubject: Hello\nDate: Mon, 02 Jun 2025 10:00:00 +0000\nMessage-ID: %s\n%s\n%s\n", from, to, id, extra, body)
+}
+
+func TestParseMail(t *testing.T) {
+	raw := "From: =?ISO-8859-1?Q?J=F6rg?= <JM@Example.org>\r\n" +
+		"To: bbs+alice@bbs.example, other@example.org\r\n" +
+		"Subject: Files\r\n" +
+		"Message-ID: <m1@example.org>\r\n" +
+		"Content-Type: multipart/mixed; boundary=XX\r\n" +
+		"\r\n" +
+		"--XX\r\n" +
+		"Content-Type: multipart/alternative; boundary=YY\r\n" +
+		"\r\n" +
+		"--YY\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Hi \x1b[31mthere\r\n" +
+		"--YY\r\n" +
+		"Content-Type: text/html\r\n" +
+		"\r\n" +
+		"<p>Hi there</p>\r\n" +
+		"--YY--\r\n" +
+		"--XX\r\n" +
+		"Content-Type: application/octet-stream\r\n" +
+		"Content-Disposition: attachment; filename=\"../evil name.zip\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"UEsD\r\nBA==\r\n" +
+		"--XX--\r\n"
+	m, err := ParseMail([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FromName != "Jörg" || m.FromAddr != "jm@example.org" || m.Subject != "Files" {
+		t.Errorf("headers = %q %q %q", m.FromName, m.FromAddr, m.Subject)
+	}
+	if m.Body != "Hi [31mthere" {
+		t.Errorf("body = %q", m.Body)
+	}
+	if len(m.Recipients) != 2 || m.Recipients[0] != "bbs+alice@bbs.example" {
+		t.Errorf("recipients = %q", m.Recipients)
+	}
+	if len(m.Attachments) != 1 || string(m.Attachments[0].Data) != "PK\x03\x04" || safeName(m.Attachments[0].Name) != "evil_name.zip" {
+		t.Errorf("attachments = %+v", m.Attachments)
+	}
+
+	if got := htmlToText("<html><head><title>x</title></head><body><p>One &amp; two</p><p>three<br>four</p></body></html>"); got != "One & two\nthree\nfour" {
+		t.Errorf("htmlToText = %q", got)
+	}
+}
+
+func TestGatewaySync(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	quarantine := filepath.Join(t.TempDir(), "quarantine")
+	for _, q := range []string{
+		`INSERT INTO users (id, username, password_hash) VALUES (1, 'sysop', 'x'), (2, 'Email', '!'), (3, 'Night Owl', 'x')`,
+		`INSERT INTO message_areas (id, name) VALUES (50, 'Feedback')`,
+		`INSERT INTO file_areas (id, name, disk_path, download_level) VALUES (9, 'Quarantine', '` + quarantine + `', 100)`,
+	} {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := message.NewRepo(database.DB)
+	files := filearea.NewRepo(database.DB)
+
+	attached := "From: Carol <carol@example.org>\nTo: sysop@bbs.example\nSubject: Logo\nMessage-ID: <att@example.org>\n" +
+		"Content-Type: multipart/mixed; boundary=B\n\n--B\nContent-Type: text/plain\n\nSee attached.\n" +
+		"--B\nContent-Type: image/png\nContent-Disposition: attachment; filename=logo.png\n\npng data\n--B--\n"
+	srv := newFakeServer(t,
+		email("<a@example.org>", "Alice <alice@example.org>", "bbs+night.owl@bbs.example", "", "Hi owl"),
+		email("<b@example.org>", "Bob <bob@example.org>", "bbs@bbs.example", "", "Nice board"),
+		email("<c@example.org>", "Vacation <alice@example.org>", "bbs@bbs.example", "Auto-Submitted: auto-replied\n", "Away"),
+		email("<d@example.org>", "MAILER-DAEMON@example.org", "bbs@bbs.example", "", "Undeliverable"),
+		email("<e@example.org>", "Loop <loop@example.org>", "bbs@bbs.example", "X-Loop: bbs@bbs.example\n", "again"),
+		email("<a@example.org>", "Alice <alice@example.org>", "bbs+night.owl@bbs.example", "", "Hi owl"),
+		attached,
+	)
+	gw := NewGateway(database.DB, msgs, user.NewRepo(database.DB), files, Config{
+		Server:         srv.l.Addr().String(),
+		Username:       "gate",
+		Password:       "secret",
+		Timeout:        5 * time.Second,
+		Address:        "BBS@bbs.example",
+		UserID:         2,
+		Users:          map[string]string{"sysop": "sysop"},
+		MapUsernames:   true,
+		FeedbackArea:   50,
+		QuarantineArea: 9,
+		MaxPerSender:   5,
+	})
+	var notified []string
+	gw.Notify = func(username, text string) { notified = append(notified, username+": "+text) }
+	var posts []any
+	gw.Events = event.NewBus()
+	gw.Events.Subscribe(event.Post, func(ev event.Event) { posts = append(posts, ev.Data) })
+
+	report, err := gw.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Delivered != 2 || report.Posted != 1 || report.Quarantined != 1 || report.Skipped != 4 {
+		t.Fatalf("report = %+v", report)
+	}
+	inbox, _ := msgs.Inbox(3)
+	if len(inbox) != 1 || len(notified) != 2 || !strings.HasPrefix(notified[0], "Night Owl: You have new email from Alice") {
+		t.Fatalf("inbox = %+v, notified %q", inbox, notified)
+	}
+	if len(posts) != 1 || posts[0] != 50 {
+		t.Errorf("post events = %v", posts)
+	}
+	if n := msgs.CountMessages(50); n != 1 {
+		t.Errorf("feedback area has %d messages, want 1", n)
+	}
+	sysopMail, _ := msgs.Inbox(1)
+	if len(sysopMail) != 1 {
+		t.Fatalf("sysop inbox = %+v", sysopMail)
+	}
+	if m, err := msgs.ReadPrivate(sysopMail[0].ID, 1); err != nil || !strings.Contains(m.Body, "[Attachment logo.png held for the sysop to check]") {
+		t.Fatalf("sysop mail = %+v, %v", m, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(quarantine, "logo.png")); err != nil || string(data) != "png data" {
+		t.Fatalf("quarantined file = %q, %v", data, err)
+	}
+	if e, err := files.GetFileByName(9, "logo.png"); err != nil || e.UploaderID != 2 {
+		t.Fatalf("catalog entry = %+v, %v", e, err)
+	}
+
+	// Everything was marked read; a second sync finds nothing.
+	report, err = gw.Sync()
+	if err != nil || *report != (Report{}) {
+		t.Fatalf("second sync = %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,294 @@
+package mailgate
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// Limits on what is imported, in runes.
+const (
+	maxAuthor  = 60
+	maxSubject = 120
+	maxDepth   = 5 // nested multiparts
+)
+
+// Mail is an inbound email.
+type Mail struct {
+	MessageID   string
+	FromName    string // display name, or the address when there is none
+	FromAddr    string // lower case
+	Subject     string
+	Date        time.Time
+	Recipients  []string // To, Cc, Delivered-To and X-Original-To, lower case
+	Body        string   // UTF-8, "\n" line endings, no escape sequences
+	Attachments []Attachment
+	Header      mail.Header
+}
+
+// Attachment is a file attached to a mail.
+type Attachment struct {
+	Name string // as given by the sender; not safe as a path
+	Data []byte
+}
+
+var headerDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, r io.Reader) (io.Reader, error) {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(terminal.DecodeText(b, charset)), nil
+	},
+}
+
+// ParseMail parses a raw message as returned by Client.Fetch.
+func ParseMail(raw []byte) (*Mail, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse mail: %w", err)
+	}
+	h := m.Header
+	ml := &Mail{Header: h, MessageID: strings.TrimSpace(h.Get("Message-ID"))}
+
+	if from, err := mail.ParseAddress(h.Get("From")); err == nil {
+		ml.FromAddr = strings.ToLower(from.Address)
+		ml.FromName = from.Name
+	}
+	ml.FromName = strings.Trim(oneLine(ml.FromName), `"`)
+	if ml.FromName == "" {
+		ml.FromName = ml.FromAddr
+	}
+	if ml.FromName == "" {
+		ml.FromName = "Unknown"
+	}
+	ml.FromName = clip(ml.FromName, maxAuthor)
+
+	ml.Subject = h.Get("Subject")
+	if s, err := headerDecoder.DecodeHeader(ml.Subject); err == nil {
+		ml.Subject = s
+	}
+	ml.Subject = clip(oneLine(ml.Subject), maxSubject)
+	if ml.Subject == "" {
+		ml.Subject = "(no subject)"
+	}
+	if ml.Date, err = h.Date(); err != nil {
+		ml.Date = time.Now()
+	}
+	for _, key := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		for _, v := range h[textproto.CanonicalMIMEHeaderKey(key)] {
+			addrs, err := mail.ParseAddressList(v)
+			if err != nil {
+				continue
+			}
+			for _, a := range addrs {
+				ml.Recipients = append(ml.Recipients, strings.ToLower(a.Address))
+			}
+		}
+	}
+	if ml.MessageID == "" {
+		// Without a Message-ID, duplicates are recognised by sender and date.
+		ml.MessageID = fmt.Sprintf("<%s.%d@no-message-id>", ml.FromAddr, ml.Date.Unix())
+	}
+
+	p := &parts{}
+	if err := p.walk(textproto.MIMEHeader(h), m.Body, 0); err != nil {
+		return nil, fmt.Errorf("parse mail: %w", err)
+	}
+	body := strings.Join(p.plain, "\n\n")
+	if body == "" && p.html != "" {
+		body = htmlToText(p.html)
+	}
+	ml.Body = cleanText(body)
+	ml.Attachments = p.attachments
+	return ml, nil
+}
+
+// parts collects the text and attachments of a MIME tree.
+type parts struct {
+	plain       []string
+	html        string
+	attachments []Attachment
+}
+
+func (p *parts) walk(h textproto.MIMEHeader, body io.Reader, depth int) error {
+	ctype, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		ctype, params = "text/plain", map[string]string{}
+	}
+	disp, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	name := dparams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if name != "" {
+		if s, err := headerDecoder.DecodeHeader(name); err == nil {
+			name = s
+		}
+	}
+
+	if strings.HasPrefix(ctype, "multipart/") && depth < maxDepth {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := p.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decode(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+	attached := disp == "attachment" || name != ""
+	switch {
+	case ctype == "text/plain" && !attached:
+		p.plain = append(p.plain, terminal.DecodeText(data, params["charset"]))
+	case ctype == "text/html" && !attached:
+		if p.html == "" {
+			p.html = terminal.DecodeText(data, params["charset"])
+		}
+	default:
+		if name == "" {
+			name = fmt.Sprintf("attachment%d%s", len(p.attachments)+1, extension(ctype))
+		}
+		p.attachments = append(p.attachments, Attachment{Name: name, Data: data})
+	}
+	return nil
+}
+
+// decode undoes a Content-Transfer-Encoding. multipart.Reader has already
+// decoded quoted-printable parts and removed their header.
+func decode(cte string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(cte)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &skipSpace{r: r})
+	}
+	return r
+}
+
+// skipSpace drops the line breaks base64 bodies are wrapped with.
+type skipSpace struct{ r io.Reader }
+
+func (s *skipSpace) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	j := 0
+	for _, c := range p[:n] {
+		if c != '\r' && c != '\n' && c != ' ' && c != '\t' {
+			p[j] = c
+			j++
+		}
+	}
+	return j, err
+}
+
+func extension(ctype string) string {
+	switch ctype {
+	case "message/rfc822":
+		return ".eml"
+	case "application/pdf":
+		return ".pdf"
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "application/zip":
+		return ".zip"
+	}
+	return ".bin"
+}
+
+var (
+	htmlDropRe  = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)>`)
+	htmlBreakRe = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6])\b[^>]*>`)
+	htmlTagRe   = regexp.MustCompile(`<[^>]*>`)
+	blankRunRe  = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText reduces an HTML-only mail to its text.
+func htmlToText(s string) string {
+	s = htmlDropRe.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "\r\n", " ")
+	s = strings.ReplaceAll(s, "\n", " ")
+	s = htmlBreakRe.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(htmlTagRe.ReplaceAllString(s, ""))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Trim(blankRunRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"), "\n")
+}
+
+// cleanText normalises line endings and drops control characters, so a
+// mail cannot carry ANSI sequences onto callers' screens.
+func cleanText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' || r >= ' ' && r != 0x7f {
+			return r
+		}
+		return -1
+	}, s)
+	return strings.Trim(s, "\n")
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func clip(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// safeName turns an attachment name into a file name that is safe in a
+// file area: no directories, and only letters, digits, '-', '_' and '.'.
+func safeName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	s := strings.TrimLeft(b.String(), ".")
+	if len(s) > 64 {
+		ext := path.Ext(s)
+		if len(ext) > 10 {
+			ext = ""
+		}
+		s = s[:64-len(ext)] + ext
+	}
+	if s == "" {
+		s = "attachment"
+	}
+	return s
+}
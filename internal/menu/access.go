@@ -0,0 +1,178 @@
+package menu
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"gopkg.in/yaml.v3"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// Access lists the conditions a caller must meet to enter a menu. It comes
+// from a sidecar file next to the menu (main_menu.yaml) or from a meta
+// table on the menu script; the zero value lets everyone in.
+type Access struct {
+	Level     int    `yaml:"-"`     // minimum security level
+	LevelName string `yaml:"-"`     // or a name from the level table, e.g. "Trusted"
+	Flags     string `yaml:"flags"` // every one of these group flags, e.g. "AD"
+	Hours     string `yaml:"hours"` // open hours "HH:MM-HH:MM", may span midnight
+	ANSI      bool   `yaml:"ansi"`  // caller must have ANSI enabled
+
+	from, until int // Hours as minutes past midnight
+}
+
+// Levels looks up the sysop's level table; *user.Repo implements it.
+type Levels interface {
+	LevelName(level int) string
+	LevelByName(name string) (user.Level, bool)
+}
+
+// UnmarshalYAML reads an access file, whose level is a number or the
+// name of a level.
+func (a *Access) UnmarshalYAML(n *yaml.Node) error {
+	type plain Access
+	if err := n.Decode((*plain)(a)); err != nil {
+		return err
+	}
+	var raw struct {
+		Level string `yaml:"level"`
+	}
+	if err := n.Decode(&raw); err != nil {
+		return err
+	}
+	a.setLevel(raw.Level)
+	return nil
+}
+
+// setLevel sets Level, or LevelName when s is not a number.
+func (a *Access) setLevel(s string) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		a.Level = n
+	} else {
+		a.LevelName = s
+	}
+}
+
+// LoadAccess reads an access sidecar file.
+func LoadAccess(path string) (*Access, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read access file: %w", err)
+	}
+	var a Access
+	if err := yaml.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("parse access file %s: %w", path, err)
+	}
+	if err := a.validate(); err != nil {
+		return nil, fmt.Errorf("access file %s: %w", path, err)
+	}
+	return &a, nil
+}
+
+// accessFromLua builds Access from a script's menu.meta table.
+func accessFromLua(tbl *lua.LTable) (*Access, error) {
+	a := &Access{
+		Flags: lua.LVAsString(tbl.RawGetString("flags")),
+		Hours: lua.LVAsString(tbl.RawGetString("hours")),
+		ANSI:  lua.LVAsBool(tbl.RawGetString("ansi")),
+	}
+	switch v := tbl.RawGetString("level").(type) {
+	case lua.LNumber:
+		a.Level = int(v)
+	case lua.LString:
+		a.setLevel(string(v))
+	}
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// validate normalizes Flags and parses Hours.
+func (a *Access) validate() error {
+	flags, err := user.NormalizeFlags(a.Flags)
+	if err != nil {
+		return err
+	}
+	a.Flags = flags
+
+	if a.Hours == "" {
+		return nil
+	}
+	from, until, ok := strings.Cut(a.Hours, "-")
+	if !ok {
+		return fmt.Errorf("hours must be HH:MM-HH:MM, got %q", a.Hours)
+	}
+	if a.from, err = parseClock(from); err != nil {
+		return fmt.Errorf("hours: %w", err)
+	}
+	if a.until, err = parseClock(until); err != nil {
+		return fmt.Errorf("hours: %w", err)
+	}
+	return nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Check returns why u may not enter, or "" when they may. u is nil before
+// login. levels names the required level in the reason and resolves
+// LevelName; with nil levels, an access by level name is closed.
+func (a *Access) Check(u *user.User, ansiEnabled bool, now time.Time, levels Levels) string {
+	level := 0
+	if u != nil {
+		level = u.SecurityLevel
+	}
+	required := a.Level
+	if a.LevelName != "" {
+		var l user.Level
+		ok := false
+		if levels != nil {
+			l, ok = levels.LevelByName(a.LevelName)
+		}
+		if !ok {
+			return fmt.Sprintf("this area needs level %s, which is not in the level table", a.LevelName)
+		}
+		required = l.Value
+	}
+	if level < required {
+		if levels != nil {
+			return fmt.Sprintf("this area needs security level %d (%s)", required, levels.LevelName(required))
+		}
+		return fmt.Sprintf("this area needs security level %d", required)
+	}
+	if a.Flags != "" && (u == nil || !u.HasFlags(a.Flags)) {
+		if len(a.Flags) == 1 {
+			return fmt.Sprintf("this area needs flag %s", a.Flags)
+		}
+		return fmt.Sprintf("this area needs flags %s", a.Flags)
+	}
+	if a.ANSI && !ansiEnabled {
+		return "this area needs an ANSI terminal"
+	}
+	if a.Hours != "" && !a.open(now) {
+		return fmt.Sprintf("this area is only open %02d:%02d-%02d:%02d",
+			a.from/60, a.from%60, a.until/60, a.until%60)
+	}
+	return ""
+}
+
+// open reports whether now falls within Hours.
+func (a *Access) open(now time.Time) bool {
+	m := now.Hour()*60 + now.Minute()
+	if a.from <= a.until {
+		return m >= a.from && m < a.until
+	}
+	return m >= a.from || m < a.until
+}
@@ -0,0 +1,108 @@
+package menu
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+func TestAccessCheck(t *testing.T) {
+	a := &Access{Level: 50, Flags: "da", Hours: "22:00-06:00", ANSI: true}
+	if err := a.validate(); err != nil {
+		t.Fatal(err)
+	}
+	night := time.Date(2026, 1, 1, 23, 30, 0, 0, time.Local)
+	day := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	ok := &user.User{SecurityLevel: 50, Flags: "ABD"}
+
+	cases := []struct {
+		u      *user.User
+		ansi   bool
+		now    time.Time
+		reason string
+	}{
+		{ok, true, night, ""},
+		{ok, true, night.Add(6 * time.Hour), ""},
+		{nil, true, night, "security level 50"},
+		{&user.User{SecurityLevel: 90, Flags: "A"}, true, night, "flags AD"},
+		{ok, false, night, "ANSI"},
+		{ok, true, day, "open 22:00-06:00"},
+	}
+	for i, c := range cases {
+		got := a.Check(c.u, c.ansi, c.now, nil)
+		if (c.reason == "") != (got == "") || !strings.Contains(got, c.reason) {
+			t.Errorf("case %d: Check = %q, want %q", i, got, c.reason)
+		}
+	}
+}
+
+// fakeLevels is a level table of New (10) and Trusted (50).
+type fakeLevels struct{}
+
+func (fakeLevels) LevelName(level int) string {
+	if level >= 50 {
+		return "Trusted"
+	}
+	return "New"
+}
+
+func (fakeLevels) LevelByName(name string) (user.Level, bool) {
+	switch strings.ToLower(name) {
+	case "new":
+		return user.Level{Value: 10, Name: "New"}, true
+	case "trusted":
+		return user.Level{Value: 50, Name: "Trusted"}, true
+	}
+	return user.Level{}, false
+}
+
+func TestAccessLevelName(t *testing.T) {
+	u := &user.User{SecurityLevel: 30}
+	if got := (&Access{Level: 50}).Check(u, true, time.Now(), fakeLevels{}); got != "this area needs security level 50 (Trusted)" {
+		t.Errorf("by value: %q", got)
+	}
+	if got := (&Access{LevelName: "trusted"}).Check(u, true, time.Now(), fakeLevels{}); !strings.Contains(got, "50 (Trusted)") {
+		t.Errorf("by name: %q", got)
+	}
+	if got := (&Access{LevelName: "New"}).Check(u, true, time.Now(), fakeLevels{}); got != "" {
+		t.Errorf("by name, high enough: %q", got)
+	}
+	for _, levels := range []Levels{fakeLevels{}, nil} {
+		if got := (&Access{LevelName: "Elite"}).Check(u, true, time.Now(), levels); got == "" {
+			t.Errorf("unknown level name let a caller in")
+		}
+	}
+}
+
+func TestScanAccessFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("vault.lua", "return {}")
+	write("vault.yaml", "level: 100\nflags: s\n")
+	write("lounge.lua", "return {}")
+	write("lounge.yaml", "level: Trusted\n")
+	write("broken.lua", "return {}")
+	write("broken.yaml", "hours: sometimes\n")
+
+	reg := NewRegistry(dir)
+	if err := reg.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if a := reg.Get("vault").Access; a == nil || a.Level != 100 || a.Flags != "S" {
+		t.Errorf("vault access = %+v", a)
+	}
+	if a := reg.Get("lounge").Access; a == nil || a.Level != 0 || a.LevelName != "Trusted" {
+		t.Errorf("lounge access = %+v", a)
+	}
+	if m := reg.Get("broken"); m.AccessErr == nil || m.Access != nil {
+		t.Errorf("broken access file accepted: %+v", m.Access)
+	}
+}
@@ -0,0 +1,178 @@
+package menu
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/notepid/twilight_bbs/internal/scripting"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Command is a sysop-defined command available from every menu once a
+// caller is logged in. Each is a Lua script in the commands directory that
+// returns a table with a run function:
+//
+//	return {
+//	    key = "^T",              -- optional hotkey
+//	    description = "Show the time",
+//	    level = 0,               -- minimum security level
+//	    run = function(node, args) ... end,
+//	}
+//
+// The file name is the slash command: time.lua is "/time".
+type Command struct {
+	Name        string
+	Key         byte // hotkey, 0 = slash command only
+	Description string
+	Level       int
+	ScriptPath  string
+}
+
+// Commands holds the discovered global commands.
+type Commands struct {
+	mu   sync.RWMutex
+	cmds map[string]*Command
+	dir  string
+}
+
+// NewCommands creates a command set that scans dir.
+func NewCommands(dir string) *Commands {
+	return &Commands{cmds: make(map[string]*Command), dir: dir}
+}
+
+// Scan discovers the commands in the directory. Scripts that fail to load
+// or declare a bad key are logged and skipped; a missing directory means
+// no commands.
+func (c *Commands) Scan() error {
+	cmds := make(map[string]*Command)
+	entries, err := os.ReadDir(c.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("scan command dir %s: %w", c.dir, err)
+	}
+	keys := make(map[byte]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.ToLower(filepath.Ext(name)) != ".lua" {
+			continue
+		}
+		cmd, err := loadCommand(filepath.Join(c.dir, name))
+		if err != nil {
+			log.Printf("Command %s skipped: %v", name, err)
+			continue
+		}
+		if cmd.Key != 0 {
+			if other, ok := keys[cmd.Key]; ok {
+				log.Printf("Command %s skipped: key %s is taken by %s", name, KeyName(cmd.Key), other)
+				continue
+			}
+			keys[cmd.Key] = cmd.Name
+		}
+		cmds[cmd.Name] = cmd
+	}
+
+	c.mu.Lock()
+	c.cmds = cmds
+	c.mu.Unlock()
+	log.Printf("Loaded %d commands from %s", len(cmds), c.dir)
+	return nil
+}
+
+// loadCommand reads a command's settings by running its script in a
+// scratch VM.
+func loadCommand(path string) (*Command, error) {
+	vm := scripting.NewVM(scripting.Limits{})
+	defer vm.Close()
+	if err := vm.LoadScript(path); err != nil {
+		return nil, err
+	}
+	tbl, ok := vm.L.Get(-1).(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("script does not return a table")
+	}
+	if _, ok := tbl.RawGetString("run").(*lua.LFunction); !ok {
+		return nil, fmt.Errorf("no run function")
+	}
+
+	base := filepath.Base(path)
+	cmd := &Command{
+		Name:       strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base))),
+		ScriptPath: path,
+	}
+	if s, ok := tbl.RawGetString("description").(lua.LString); ok {
+		cmd.Description = string(s)
+	}
+	if n, ok := tbl.RawGetString("level").(lua.LNumber); ok {
+		cmd.Level = int(n)
+	}
+	if s, ok := tbl.RawGetString("key").(lua.LString); ok {
+		key, err := ParseKey(string(s))
+		if err != nil {
+			return nil, err
+		}
+		cmd.Key = key
+	}
+	return cmd, nil
+}
+
+// ParseKey parses a command hotkey: a single printable character, or "^A"
+// to "^Z" for a control key. Letters and digits are refused, as menus use
+// them for their own options.
+func ParseKey(s string) (byte, error) {
+	switch {
+	case len(s) == 2 && s[0] == '^' && s[1] >= 'A' && s[1] <= 'Z':
+		return s[1] - 'A' + 1, nil
+	case len(s) == 1 && s[0] > ' ' && s[0] < 0x7f && s[0] != '/':
+		c := s[0]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			return 0, fmt.Errorf("key %q is a menu key; use a symbol or ^A-^Z", s)
+		}
+		return c, nil
+	}
+	return 0, fmt.Errorf("invalid key %q (want a symbol other than / or ^A-^Z)", s)
+}
+
+// KeyName formats a hotkey the way ParseKey reads it.
+func KeyName(key byte) string {
+	if key >= 1 && key <= 26 {
+		return "^" + string(rune('A'+key-1))
+	}
+	return string(rune(key))
+}
+
+// Get returns the command with the given name, or nil.
+func (c *Commands) Get(name string) *Command {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cmds[strings.ToLower(name)]
+}
+
+// ForKey returns the command bound to key, or nil.
+func (c *Commands) ForKey(key byte) *Command {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, cmd := range c.cmds {
+		if cmd.Key == key {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// Available returns the commands a user at level may run, by name.
+func (c *Commands) Available(level int) []*Command {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var list []*Command
+	for _, cmd := range c.cmds {
+		if level >= cmd.Level {
+			list = append(list, cmd)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
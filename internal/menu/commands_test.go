@@ -0,0 +1,73 @@
+package menu
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommandsScan(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"time.lua":   `return { key = "^T", description = "Show the time", run = function(node, args) end }`,
+		"Wall.lua":   `return { key = "$", level = 50, run = function(node, args) end }`,
+		"clash.lua":  `return { key = "$", run = function(node, args) end }`,
+		"letter.lua": `return { key = "x", run = function(node, args) end }`,
+		"norun.lua":  `return { description = "nothing to run" }`,
+		"broken.lua": `return {`,
+		"notes.txt":  `not a command`,
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewCommands(dir)
+	if err := c.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := c.Get("TIME"); cmd == nil || cmd.Key != 20 || cmd.Description != "Show the time" {
+		t.Errorf("Get(TIME) = %+v", cmd)
+	}
+	// Files are read in name order, so Wall.lua keeps the key.
+	if cmd := c.ForKey('$'); cmd == nil || cmd.Name != "wall" || cmd.Level != 50 {
+		t.Errorf("ForKey($) = %+v", cmd)
+	}
+	for _, name := range []string{"clash", "letter", "norun", "broken", "notes"} {
+		if c.Get(name) != nil {
+			t.Errorf("%s loaded", name)
+		}
+	}
+	if got := c.Available(0); len(got) != 1 || got[0].Name != "time" {
+		t.Errorf("Available(0) = %+v, want time", got)
+	}
+	if got := c.Available(100); len(got) != 2 {
+		t.Errorf("Available(100) = %+v, want time and wall", got)
+	}
+
+	if err := NewCommands(filepath.Join(dir, "missing")).Scan(); err != nil {
+		t.Errorf("missing dir: %v", err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for s, want := range map[string]byte{"^A": 1, "^Z": 26, "$": '$', "~": '~'} {
+		if got, err := ParseKey(s); err != nil || got != want {
+			t.Errorf("ParseKey(%q) = %d, %v; want %d", s, got, err, want)
+		}
+		if got, _ := ParseKey(s); KeyName(got) != s {
+			t.Errorf("KeyName(%d) = %q, want %q", got, KeyName(got), s)
+		}
+	}
+	for _, bad := range []string{"", "a", "Q", "5", "/", " ", "^a", "$$"} {
+		if _, err := ParseKey(bad); err == nil {
+			t.Errorf("ParseKey(%q) succeeded", bad)
+		}
+	}
+}
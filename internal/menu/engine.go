@@ -10,14 +10,27 @@ import (
 	"database/sql"
 
 	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/bulletin"
+	"github.com/notepid/twilight_bbs/internal/callers"
 	"github.com/notepid/twilight_bbs/internal/chat"
+	"github.com/notepid/twilight_bbs/internal/conference"
 	"github.com/notepid/twilight_bbs/internal/door"
+	"github.com/notepid/twilight_bbs/internal/event"
 	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/flood"
+	"github.com/notepid/twilight_bbs/internal/greeting"
+	"github.com/notepid/twilight_bbs/internal/mailer"
 	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/msgreader"
+	"github.com/notepid/twilight_bbs/internal/picker"
+	"github.com/notepid/twilight_bbs/internal/recording"
 	"github.com/notepid/twilight_bbs/internal/scripting"
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/stats"
 	"github.com/notepid/twilight_bbs/internal/terminal"
 	"github.com/notepid/twilight_bbs/internal/transfer"
 	"github.com/notepid/twilight_bbs/internal/user"
+	"github.com/notepid/twilight_bbs/internal/weblink"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -32,13 +45,39 @@ type Services struct {
 	UserRepo        *user.Repo
 	MessageRepo     *message.Repo
 	FileRepo        *filearea.Repo
+	BulletinRepo    *bulletin.Repo
+	Session         *session.Session // shared login state; created if nil
 	ChatBroker      *chat.Broker
 	DoorLauncher    *door.Launcher
 	TransferConfig  *transfer.Config
+	ArchiveDir      string // where archive members are extracted for viewing
+	Ratio           filearea.Ratio
 	DB              *sql.DB
+	Events          *event.Bus     // logins, posts, transfers and door launches are published here
+	Flood           *flood.Limiter // shared post, chat and file limits, nil = none
+	ScriptLimits    scripting.Limits
+	Greetings       *greeting.Service // nil = no greetings
+	GreetAtLogin    bool              // show the greeting as part of login
+	Tour            *scripting.Tour   // nil = no onboarding tour
+	Commands        *Commands         // sysop-defined global commands, nil = none
+	Recordings      *recording.Store  // session recordings for playback, nil = off
+	WebLinks        *weblink.Store    // one-time HTTP download links, nil = off
+	SharedVM        bool              // one Lua VM for the whole session, menus loaded as modules
+	Mailer          *mailer.Mailer    // outgoing mail, nil = none
+	ResetCodeTTL    time.Duration     // how long a mailed password reset code is valid
+	ScriptAlerts    int               // mailer.notify_sysop calls per call, 0 = not available
+	ChatHistory     int               // recent room lines shown on joining, 0 = none
 	NodeID          int
 	PreAuthUsername string
 	PreAuthPassword string
+
+	// Per-node overrides from the nodes config section.
+	NodeName  string
+	MinLevel  int           // users below this level are refused at login
+	TimeLimit time.Duration // per-call limit after login, 0 = unlimited
+
+	// SpyNode attaches this session to another node's terminal (sysop only).
+	SpyNode func(targetID int, takeover bool) error
 }
 
 // Engine manages the menu system for a single node/session.
@@ -52,35 +91,85 @@ type Engine struct {
 	userAPI     *scripting.UserAPI
 	msgAPI      *scripting.MessageAPI
 	fileAPI     *scripting.FileAPI
+	bulletinAPI *scripting.BulletinAPI
+	storeAPI    *scripting.StoreAPI
+	levelsAPI   *scripting.LevelsAPI
+	statsAPI    *scripting.StatsAPI
+	confAPI     *scripting.ConferenceAPI
+	greetingAPI *scripting.GreetingAPI
+	tourAPI     *scripting.TourAPI
+	recAPI      *scripting.RecordingsAPI
+	slashAPI    *scripting.SlashAPI
 	chatAPI     *scripting.ChatAPI
+	liveAPI     *scripting.LiveAPI
 	doorAPI     *scripting.DoorAPI
 	transferAPI *scripting.TransferAPI
+	mailerAPI   *scripting.MailerAPI
 	nodeUD      *lua.LUserData
 
-	// Current user
-	currentUser *user.User
+	// Who is logged in, shared with the Lua APIs and the node
+	session *session.Session
+
+	// Conferences the caller joins; nil without a database
+	conferences *conference.Repo
 
 	// Fields indexed from the most recently displayed ANSI/ASCII art.
 	currentFields map[string]ansi.Field
 
 	// Current state
-	currentMenu string
-	menuStack   []string
-	running     bool
+	currentMenu  string
+	previousMenu string // menu left by the last goto, for access denials
+	menuStack    []frame
+	running      bool
+
+	// Gosub handoff: arguments of the current menu and the values the last
+	// gosub menu returned (cleared by the next navigation).
+	currentArgs  interface{}
+	returnValues interface{}
+	returnedFrom string
 
 	// Navigation signals
-	nextMenu   string
-	gosubMenu  string
-	returnMenu bool
-	disconnect bool
+	nextMenu     string
+	gosubMenu    string
+	gosubArgs    interface{}
+	returnMenu   bool
+	returnResult interface{}
+	disconnect   bool
+	rerun        bool // redraw the current menu, e.g. after a global command
 
 	// Persistent menu state
 	menuState map[string]map[string]interface{}
+
+	// waitingInput is set while inputLoop waits for a key or line, the only
+	// time on_resize may run; resizes at other times are delivered when the
+	// loop next waits.
+	waitingInput  bool
+	resizePending bool
+
+	// shutdownPending is set when a shutdown warning arrives while the
+	// menu is busy; on_shutdown runs when the input loop next waits.
+	shutdownPending bool
+
+	// typed is false while the input loop reads keys a macro typed, so
+	// they do not set off macros themselves.
+	typed bool
+
+	// Per-call time limit timers, started at login
+	timeLimitTimers []*time.Timer
+}
+
+// frame is a caller suspended by gosub_menu.
+type frame struct {
+	menu string
+	args interface{}
 }
 
 // NewEngine creates a new menu engine for a session.
 func NewEngine(registry *Registry, loader *ansi.Loader, term *terminal.Terminal, svc *Services) *Engine {
-	vm := scripting.NewVM()
+	vm := scripting.NewVM(svc.ScriptLimits)
+	if svc.SharedVM {
+		vm.UseModules()
+	}
 	nodeAPI := scripting.NewNodeAPI(term)
 
 	e := &Engine{
@@ -93,13 +182,24 @@ func NewEngine(registry *Registry, loader *ansi.Loader, term *terminal.Terminal,
 		running:   true,
 		menuState: make(map[string]map[string]interface{}),
 	}
+	if svc != nil && svc.Session != nil {
+		e.session = svc.Session
+	} else {
+		e.session = session.New()
+	}
 
 	// Wire navigation callbacks
 	nodeAPI.OnGotoMenu = e.handleGotoMenu
 	nodeAPI.OnGosubMenu = e.handleGosubMenu
 	nodeAPI.OnReturnMenu = e.handleReturnMenu
+	nodeAPI.OnGetArgs = func() interface{} { return e.currentArgs }
+	nodeAPI.OnGetReturn = func() (interface{}, string) { return e.returnValues, e.returnedFrom }
 	nodeAPI.OnDisconnect = e.handleDisconnect
 	nodeAPI.OnDisplay = e.handleDisplay
+	nodeAPI.OnDisplayRandom = e.handleDisplayRandom
+	nodeAPI.OnDisplayPaged = e.handleDisplayPaged
+	nodeAPI.OnAnimate = e.handleAnimate
+	nodeAPI.OnDisplayAnimation = e.handleDisplayAnimation
 
 	// Wire state callbacks
 	nodeAPI.OnSetMenuState = e.SetMenuState
@@ -110,41 +210,129 @@ func NewEngine(registry *Registry, loader *ansi.Loader, term *terminal.Terminal,
 	nodeAPI.OnGetPreAuthUsername = e.PreAuthUsername
 	nodeAPI.OnGetPreAuthPassword = e.PreAuthPassword
 
+	// Wire sysop callbacks
+	nodeAPI.OnSpy = e.handleSpy
+	nodeAPI.OnShutdownIn = e.session.ShutdownIn
+
+	// Pickers run on this session's terminal
+	nodeAPI.Pick = e.pick
+	nodeAPI.OnMacro = e.runMacro
+
+	// Window size changes reach the menu's on_resize handler
+	term.OnResize = e.handleResize
+
+	// Shutdown warnings reach the menu's on_shutdown handler
+	term.OnWake = e.handleWake
+
 	// Register the node API in the Lua VM
 	e.nodeUD = nodeAPI.Register(vm.L)
 
 	// Register user API if repo is available
 	if svc != nil && svc.UserRepo != nil {
-		e.userAPI = scripting.NewUserAPI(svc.UserRepo)
+		e.userAPI = scripting.NewUserAPI(svc.UserRepo, e.session)
 		e.userAPI.OnLogin = e.handleUserLogin
+		e.userAPI.PreAuth = func() (string, string) {
+			return e.PreAuthUsername(), e.PreAuthPassword()
+		}
+		e.userAPI.Pick = e.pick
+		e.userAPI.Publish = e.publish
+		if svc.Mailer != nil {
+			e.userAPI.SendResetCode = e.sendResetCode
+		}
 		e.userAPI.Register(vm.L)
+
+		e.storeAPI = scripting.NewStoreAPI(svc.UserRepo, e.session)
+		e.storeAPI.Register(vm.L)
+
+		e.levelsAPI = scripting.NewLevelsAPI(svc.UserRepo)
+		e.levelsAPI.Register(vm.L)
 	}
 
 	// Register message API if repo is available
 	if svc != nil && svc.MessageRepo != nil {
-		e.msgAPI = scripting.NewMessageAPI(svc.MessageRepo, func() *user.User {
-			return e.currentUser
-		})
+		e.msgAPI = scripting.NewMessageAPI(svc.MessageRepo, e.session)
+		if svc.UserRepo != nil {
+			e.msgAPI.LookupUser = svc.UserRepo.GetByUsername
+		}
+		e.msgAPI.OnPrivateMail = e.handlePrivateMail
+		e.msgAPI.Pick = e.pick
+		e.msgAPI.ReadLoop = e.readLoop
+		e.msgAPI.Publish = e.publish
+		e.msgAPI.Flood = e.floodCheck
 		e.msgAPI.Register(vm.L)
 	}
 
 	// Register file API if repo is available
 	if svc != nil && svc.FileRepo != nil {
-		e.fileAPI = scripting.NewFileAPI(svc.FileRepo, func() *user.User {
-			return e.currentUser
-		})
+		e.fileAPI = scripting.NewFileAPI(svc.FileRepo, e.session)
+		e.fileAPI.Pick = e.pick
+		e.fileAPI.ExtractDir = svc.ArchiveDir
+		e.fileAPI.Ratio = svc.Ratio
+		e.fileAPI.Links = svc.WebLinks
+		e.fileAPI.Flood = e.floodCheck
+		e.fileAPI.SaveScan = e.saveFileScan
 		e.fileAPI.Register(vm.L)
 	}
 
+	// Register bulletin API if repo is available
+	if svc != nil && svc.BulletinRepo != nil {
+		e.bulletinAPI = scripting.NewBulletinAPI(svc.BulletinRepo, e.session)
+		e.bulletinAPI.Register(vm.L)
+	}
+
+	// Register stats API if the database is available
+	if svc != nil && svc.DB != nil {
+		e.statsAPI = scripting.NewStatsAPI(callers.NewRepo(svc.DB), stats.NewRepo(svc.DB))
+		e.statsAPI.Register(vm.L)
+
+		e.conferences = conference.NewRepo(svc.DB)
+		e.confAPI = scripting.NewConferenceAPI(e.conferences, e.session)
+		e.confAPI.Register(vm.L)
+		e.statsAPI.AreaConferences = e.conferences.MessageAreas
+		if e.fileAPI != nil {
+			e.fileAPI.Joined = e.confAPI.Joined
+		}
+	}
+
+	// Register greeting API if greetings are configured
+	if svc != nil && svc.Greetings != nil {
+		e.greetingAPI = scripting.NewGreetingAPI(svc.Greetings, e.session)
+		e.greetingAPI.Register(vm.L)
+	}
+
+	// Register tour API if the onboarding tour is configured
+	if svc != nil && svc.Tour != nil {
+		e.tourAPI = scripting.NewTourAPI(svc.Tour, e.session)
+		e.tourAPI.Register(vm.L)
+	}
+
+	// Register recordings API if sessions are recorded
+	if svc != nil && svc.Recordings != nil {
+		e.recAPI = scripting.NewRecordingsAPI(svc.Recordings, term, e.session)
+		e.recAPI.Register(vm.L)
+	}
+
+	// Register the slash-command parser
+	nodeID := 0
+	if svc != nil {
+		nodeID = svc.NodeID
+	}
+	e.slashAPI = scripting.NewSlashAPI(e.session, nodeID)
+	e.slashAPI.Register(vm.L)
+
 	// Register chat API if broker is available
 	if svc != nil && svc.ChatBroker != nil {
 		e.chatAPI = scripting.NewChatAPI(svc.ChatBroker, term, svc.NodeID, func() string {
-			if e.currentUser != nil {
-				return e.currentUser.Username
+			if u := e.session.User(); u != nil {
+				return u.Username
 			}
 			return fmt.Sprintf("Node %d", svc.NodeID)
 		})
+		e.chatAPI.Flood = e.floodCheck
+		e.chatAPI.DoNotDisturb = e.setDoNotDisturb
 		e.chatAPI.Register(vm.L)
+		e.liveAPI = scripting.NewLiveAPI(svc.ChatBroker, term, e.session, svc.NodeID)
+		e.liveAPI.Register(vm.L)
 
 		// Wire inter-node callbacks
 		nodeAPI.OnShowOnline = e.handleShowOnline
@@ -153,24 +341,35 @@ func NewEngine(registry *Registry, loader *ansi.Loader, term *terminal.Terminal,
 
 	// Register door API if launcher is available
 	if svc != nil && svc.DoorLauncher != nil {
-		e.doorAPI = scripting.NewDoorAPI(svc.DoorLauncher, func() *user.User {
-			return e.currentUser
-		}, func() (int, int) {
-			return term.Width, term.Height
-		}, svc.NodeID, term, term)
-		e.doorAPI.Register(vm.L)
-
-		nodeAPI.OnLaunchDoor = func(name string) error {
-			e.term.SendLn(fmt.Sprintf("\r\n  Launching door: %s...", name))
-			// The door API handles the actual launch through Lua
-			return nil
+		e.doorAPI = scripting.NewDoorAPI(svc.DoorLauncher, e.session, term, svc.NodeID, term, term)
+		e.doorAPI.Publish = e.publish
+		if svc.DB != nil {
+			e.doorAPI.Stats = door.NewStatsRepo(svc.DB)
 		}
+		e.doorAPI.Suspend = e.suspend
+		e.doorAPI.Register(vm.L)
+		nodeAPI.OnLaunchDoor = e.doorAPI.Launch
 	}
 
 	// Register transfer API if config is available
 	if svc != nil && svc.TransferConfig != nil {
-		e.transferAPI = scripting.NewTransferAPI(svc.TransferConfig, term.EnterBinaryMode, svc.NodeID)
+		e.transferAPI = scripting.NewTransferAPI(svc.TransferConfig, term.EnterBinaryMode, svc.NodeID, e.session)
+		e.transferAPI.Publish = e.publish
+		e.transferAPI.Suspend = e.suspend
+		e.transferAPI.Pick = e.pick
 		e.transferAPI.Register(vm.L)
+		if e.fileAPI != nil {
+			e.fileAPI.Send = e.transferAPI.SendFiles
+		}
+	}
+
+	// Scripts may alert the sysop when the board has somewhere to send it
+	if svc != nil && svc.Mailer != nil && svc.Mailer.CanAlertSysop() && svc.ScriptAlerts > 0 {
+		m := svc.Mailer
+		e.mailerAPI = scripting.NewMailerAPI(e.session, svc.ScriptAlerts, func(subject, body, username string) error {
+			return m.NotifySysop("sysop", map[string]any{"Subject": subject, "Body": body, "User": username})
+		})
+		e.mailerAPI.Register(vm.L)
 	}
 
 	return e
@@ -178,12 +377,18 @@ func NewEngine(registry *Registry, loader *ansi.Loader, term *terminal.Terminal,
 
 // Close shuts down the menu engine.
 func (e *Engine) Close() {
+	for _, t := range e.timeLimitTimers {
+		t.Stop()
+	}
+	if e.fileAPI != nil {
+		e.fileAPI.Cleanup()
+	}
 	e.vm.Close()
 }
 
 // CurrentUser returns the currently logged-in user, or nil.
 func (e *Engine) CurrentUser() *user.User {
-	return e.currentUser
+	return e.session.User()
 }
 
 // PreAuthUsername returns the pre-authenticated username (from SSH), if any.
@@ -226,31 +431,96 @@ func (e *Engine) Run(startMenu string) error {
 		if e.disconnect {
 			return nil
 		}
+		e.returnValues, e.returnedFrom = nil, ""
+		// Navigation supersedes a pending redraw.
 		if e.nextMenu != "" {
+			e.previousMenu = e.currentMenu
 			e.currentMenu = e.nextMenu
+			e.currentArgs = nil
 			e.nextMenu = ""
+			e.rerun = false
 			continue
 		}
 		if e.gosubMenu != "" {
-			e.menuStack = append(e.menuStack, e.currentMenu)
+			e.menuStack = append(e.menuStack, frame{menu: e.currentMenu, args: e.currentArgs})
 			e.currentMenu = e.gosubMenu
-			e.gosubMenu = ""
+			e.currentArgs = e.gosubArgs
+			e.gosubMenu, e.gosubArgs = "", nil
+			e.rerun = false
 			continue
 		}
 		if e.returnMenu {
-			e.returnMenu = false
+			e.returnMenu, e.rerun = false, false
 			if len(e.menuStack) > 0 {
-				e.currentMenu = e.menuStack[len(e.menuStack)-1]
+				caller := e.menuStack[len(e.menuStack)-1]
 				e.menuStack = e.menuStack[:len(e.menuStack)-1]
+				e.returnValues, e.returnedFrom = e.returnResult, e.currentMenu
+				e.currentMenu, e.currentArgs = caller.menu, caller.args
+				e.returnResult = nil
 				continue
 			}
 			return nil
 		}
+		if e.rerun {
+			e.rerun = false
+			continue
+		}
 	}
 
 	return nil
 }
 
+// showHeld shows the notices the node manager could not deliver while the
+// connection was stalled, now that it is taking output again.
+func (e *Engine) showHeld() {
+	if e.services == nil || e.services.ChatBroker == nil {
+		return
+	}
+	held := e.services.ChatBroker.TakeHeld(e.services.NodeID)
+	if len(held) == 0 {
+		return
+	}
+	e.term.SendLn("")
+	for _, text := range held {
+		e.term.SendLn("*** " + text)
+	}
+	e.term.Pause()
+}
+
+// levels returns the level table for access checks, nil without a
+// user repository.
+func (e *Engine) levels() Levels {
+	if e.services.UserRepo == nil {
+		return nil
+	}
+	return e.services.UserRepo
+}
+
+// denyMenu tells the caller why they may not enter name and sends them
+// back where they came from.
+func (e *Engine) denyMenu(name, reason string) error {
+	log.Printf("Menu %s denied: %s", name, reason)
+	if df, err := e.loader.Find(accessDeniedScreen, e.term.ANSIEnabled); err == nil {
+		if err := ansi.Display(e.term, df); err != nil {
+			return fmt.Errorf("display %s: %w", accessDeniedScreen, err)
+		}
+	}
+	e.term.SendLn(fmt.Sprintf("\r\n  Sorry, %s.", reason))
+	e.term.Pause()
+
+	switch {
+	case len(e.menuStack) > 0:
+		e.returnMenu = true
+	case e.previousMenu != "" && e.previousMenu != name:
+		e.nextMenu = e.previousMenu
+	case name != "main_menu":
+		e.nextMenu = "main_menu"
+	default:
+		return fmt.Errorf("menu %s: %s", name, reason)
+	}
+	return nil
+}
+
 // CurrentMenuName returns the name of the current menu.
 func (e *Engine) CurrentMenuName() string {
 	return e.currentMenu
@@ -258,6 +528,9 @@ func (e *Engine) CurrentMenuName() string {
 
 // runMenu loads and runs a single menu.
 func (e *Engine) runMenu(name string) error {
+	// The new menu is drawn at the current size.
+	e.resizePending = false
+
 	m := e.registry.Get(name)
 	if m == nil {
 		log.Printf("Menu not found: %s", name)
@@ -265,36 +538,28 @@ func (e *Engine) runMenu(name string) error {
 		e.term.Pause()
 		return ErrMenuNotFound
 	}
+	if m.AccessErr != nil {
+		return e.denyMenu(name, "this area is closed")
+	}
+	if m.Access != nil {
+		if reason := m.Access.Check(e.session.User(), e.term.ANSIEnabled, time.Now(), e.levels()); reason != "" {
+			return e.denyMenu(name, reason)
+		}
+	}
+	e.session.SetMenu(name)
+	e.showHeld()
 
 	// Load and run the Lua script
 	if m.HasScript() {
-		// Create a fresh VM for each menu to avoid state leakage
-		oldVM := e.vm
-		e.vm = scripting.NewVM()
-		e.nodeUD = e.nodeAPI.Register(e.vm.L)
-		e.nodeAPI.CurrentMenuName = name
-
-		// Re-register APIs in the new VM
-		if e.userAPI != nil {
-			e.userAPI.Register(e.vm.L)
-		}
-		if e.msgAPI != nil {
-			e.msgAPI.Register(e.vm.L)
-		}
-		if e.fileAPI != nil {
-			e.fileAPI.Register(e.vm.L)
+		// Without a shared VM each menu gets a fresh one, so no state
+		// leaks from menu to menu.
+		if !e.services.SharedVM {
+			oldVM := e.vm
+			e.vm = scripting.NewVM(e.services.ScriptLimits)
+			e.registerAPIs()
+			oldVM.Close()
 		}
-		if e.chatAPI != nil {
-			e.chatAPI.Register(e.vm.L)
-		}
-		if e.doorAPI != nil {
-			e.doorAPI.Register(e.vm.L)
-		}
-		if e.transferAPI != nil {
-			e.transferAPI.Register(e.vm.L)
-		}
-
-		oldVM.Close()
+		e.nodeAPI.CurrentMenuName = name
 
 		if err := e.vm.LoadScript(m.ScriptPath); err != nil {
 			log.Printf("Script error in %s: %v", m.ScriptPath, err)
@@ -304,6 +569,19 @@ func (e *Engine) runMenu(name string) error {
 			// Maybe return nil to abort this menu but not the session?
 			// For now let's continue to display part
 		}
+
+		// Without an access file the script may declare menu.meta.
+		if meta, ok := e.vm.MenuField("meta").(*lua.LTable); ok && m.AccessPath == "" {
+			reason := "this area is closed"
+			if acc, err := accessFromLua(meta); err != nil {
+				log.Printf("Menu %s stays closed: meta: %v", name, err)
+			} else {
+				reason = acc.Check(e.session.User(), e.term.ANSIEnabled, time.Now(), e.levels())
+			}
+			if reason != "" {
+				return e.denyMenu(name, reason)
+			}
+		}
 	} else {
 		// Even if no script, we might want to close old VM?
 		// Actually the existing code didn't close old VM if !m.HasScript(), which might be a bug or intentional to keep previous state?
@@ -362,6 +640,61 @@ func (e *Engine) runMenu(name string) error {
 	return nil
 }
 
+// registerAPIs installs the node and service APIs in a new VM.
+func (e *Engine) registerAPIs() {
+	L := e.vm.L
+	e.nodeUD = e.nodeAPI.Register(L)
+	if e.userAPI != nil {
+		e.userAPI.Register(L)
+	}
+	if e.msgAPI != nil {
+		e.msgAPI.Register(L)
+	}
+	if e.fileAPI != nil {
+		e.fileAPI.Register(L)
+	}
+	if e.bulletinAPI != nil {
+		e.bulletinAPI.Register(L)
+	}
+	if e.storeAPI != nil {
+		e.storeAPI.Register(L)
+	}
+	if e.levelsAPI != nil {
+		e.levelsAPI.Register(L)
+	}
+	if e.statsAPI != nil {
+		e.statsAPI.Register(L)
+	}
+	if e.confAPI != nil {
+		e.confAPI.Register(L)
+	}
+	if e.greetingAPI != nil {
+		e.greetingAPI.Register(L)
+	}
+	if e.tourAPI != nil {
+		e.tourAPI.Register(L)
+	}
+	if e.recAPI != nil {
+		e.recAPI.Register(L)
+	}
+	e.slashAPI.Register(L)
+	if e.chatAPI != nil {
+		e.chatAPI.Register(L)
+	}
+	if e.liveAPI != nil {
+		e.liveAPI.Register(L)
+	}
+	if e.doorAPI != nil {
+		e.doorAPI.Register(L)
+	}
+	if e.transferAPI != nil {
+		e.transferAPI.Register(L)
+	}
+	if e.mailerAPI != nil {
+		e.mailerAPI.Register(L)
+	}
+}
+
 // inputLoop reads input and dispatches to Lua handlers.
 func (e *Engine) inputLoop(menuName string) error {
 	hasOnKey := e.vm.HasMenuHandler("on_key")
@@ -372,24 +705,55 @@ func (e *Engine) inputLoop(menuName string) error {
 	}
 
 	for e.running && !e.hasNavigationPending() {
+		if e.resizePending {
+			e.resizePending = false
+			e.callResize()
+			if e.hasNavigationPending() {
+				break
+			}
+		}
+		if e.shutdownPending {
+			e.shutdownPending = false
+			e.callShutdown()
+			if e.hasNavigationPending() {
+				break
+			}
+		}
+		e.typed = !e.term.HasInjected()
 		if hasOnKey {
+			e.waitingInput = true
 			key, err := e.term.GetKey()
+			e.waitingInput = false
 			if err != nil {
 				return ErrDisconnect
 			}
 
+			if taken, err := e.globalKey(key); err != nil {
+				return err
+			} else if taken {
+				continue
+			}
+
 			keyStr := string(key)
 			if err := e.vm.CallMenuHandler("on_key", e.nodeUD, lua.LString(keyStr)); err != nil {
 				scripting.LogError(menuName+".on_key", err)
 			}
 		} else if hasOnInput {
 			e.term.Send("> ")
+			e.waitingInput = true
 			line, err := e.term.GetLine(80)
+			e.waitingInput = false
 			if err != nil {
 				return ErrDisconnect
 			}
 
 			line = strings.TrimSpace(line)
+			if e.runMacro(line) {
+				continue
+			}
+			if strings.HasPrefix(line, "/") && e.slashCommand(line[1:], false) {
+				continue
+			}
 			if line != "" {
 				if err := e.vm.CallMenuHandler("on_input", e.nodeUD, lua.LString(line)); err != nil {
 					scripting.LogError(menuName+".on_input", err)
@@ -401,9 +765,224 @@ func (e *Engine) inputLoop(menuName string) error {
 	return nil
 }
 
+// globalKey runs the global command bound to key, reads a slash command
+// line when key is "/", or asks for a macro on macroKey. Slash lines that
+// are not global commands go to the menu's on_input, if it has one. It
+// reports whether the key was taken; menus see only the keys that were
+// not. Commands and macros need a logged-in caller.
+func (e *Engine) globalKey(key byte) (bool, error) {
+	u := e.session.User()
+	if u == nil {
+		return false, nil
+	}
+	var cmds *Commands
+	if e.services != nil {
+		cmds = e.services.Commands
+	}
+	if key == '/' {
+		hasOnInput := e.vm.HasMenuHandler("on_input")
+		if !hasOnInput && (cmds == nil || len(cmds.Available(u.SecurityLevel)) == 0) {
+			return false, nil
+		}
+		e.term.Send("\r\n/")
+		line, err := e.term.GetLine(60)
+		if err != nil {
+			return true, ErrDisconnect
+		}
+		if !e.slashCommand(line, !hasOnInput) && strings.TrimSpace(line) != "" {
+			if err := e.vm.CallMenuHandler("on_input", e.nodeUD, lua.LString("/"+strings.TrimSpace(line))); err != nil {
+				scripting.LogError(e.currentMenu+".on_input", err)
+			}
+		}
+		return true, nil
+	}
+	if cmds != nil {
+		if cmd := cmds.ForKey(key); cmd != nil && u.SecurityLevel >= cmd.Level {
+			e.runCommand(cmd, "")
+			return true, nil
+		}
+	}
+	if key == macroKey && e.services != nil && e.services.UserRepo != nil {
+		return true, e.macroPrompt()
+	}
+	return false, nil
+}
+
+// macroKey opens the macro prompt at menus that take single keys.
+const macroKey = 0x0b // Ctrl-K
+
+// macroPrompt asks for one of the caller's macros and runs it, redrawing
+// the menu first so the macro's keys reach the menu as it stands.
+func (e *Engine) macroPrompt() error {
+	e.term.Send("\r\nMacro: ")
+	name, err := e.term.GetLine(user.MaxMacroNameLen)
+	if err != nil {
+		return ErrDisconnect
+	}
+	if name = strings.TrimSpace(name); name != "" && !e.runMacro(name) {
+		e.term.SendLn(fmt.Sprintf("  No macro named %s. Set them up under account settings.", strings.ToUpper(name)))
+		e.term.Pause()
+	}
+	e.rerun = true
+	return nil
+}
+
+// runMacro types the keys of the caller's macro named input for the menu
+// to read, reporting whether there was one. Keys a macro typed do not run
+// further macros.
+func (e *Engine) runMacro(input string) bool {
+	u := e.session.User()
+	if u == nil || !e.typed || e.services == nil || e.services.UserRepo == nil {
+		return false
+	}
+	m, err := e.services.UserRepo.GetMacro(u.ID, input)
+	if err != nil {
+		log.Printf("Node %d: macro %q: %v", e.services.NodeID, input, err)
+		return false
+	}
+	if m == nil {
+		return false
+	}
+	e.term.Inject(m.Input())
+	return true
+}
+
+// slashCommand runs "name args" as a global command. "?" lists the
+// commands. Unknown names are left to the menu's on_input, or reported
+// when the menu has no use for them (always).
+func (e *Engine) slashCommand(line string, always bool) bool {
+	u := e.session.User()
+	if u == nil {
+		return false
+	}
+	var cmds *Commands
+	if e.services != nil {
+		cmds = e.services.Commands
+	}
+	name, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if cmds != nil {
+		if cmd := cmds.Get(name); cmd != nil && u.SecurityLevel >= cmd.Level {
+			e.runCommand(cmd, strings.TrimSpace(args))
+			return true
+		}
+	}
+	switch {
+	case name == "?" && cmds != nil:
+		// The menu lists its own commands after these.
+		e.listCommands(u.SecurityLevel)
+		if !always {
+			return false
+		}
+	case name != "" && always:
+		e.term.SendLn(fmt.Sprintf("\r\n  Unknown command /%s. Type /? for a list.", name))
+		e.term.Pause()
+	case !always:
+		return false
+	}
+	e.rerun = true
+	return true
+}
+
+// runCommand runs a global command in the current menu's VM, then redraws
+// the menu unless the command went somewhere else.
+func (e *Engine) runCommand(cmd *Command, args string) {
+	if err := e.vm.RunCommand(cmd.ScriptPath, e.nodeUD, lua.LString(args)); err != nil {
+		scripting.LogError("command "+cmd.Name, err)
+	}
+	if !e.hasNavigationPending() {
+		e.rerun = true
+	}
+}
+
+func (e *Engine) listCommands(level int) {
+	e.term.SendLn("\r\n  Commands:")
+	for _, cmd := range e.services.Commands.Available(level) {
+		key := ""
+		if cmd.Key != 0 {
+			key = "(" + KeyName(cmd.Key) + ")"
+		}
+		e.term.SendLn(fmt.Sprintf("  /%-12s %-5s %s", cmd.Name, key, cmd.Description))
+	}
+	e.term.Pause()
+}
+
+// pick shows a picker on the session terminal.
+func (e *Engine) pick(title string, items []picker.Item) (picker.Item, bool, error) {
+	return picker.Run(e.term, title, items)
+}
+
+func (e *Engine) readLoop(areaID, startID int, reply func(m *message.Message) error) (msgreader.Result, error) {
+	var tmpl *ansi.DisplayFile
+	if e.loader != nil {
+		if df, err := e.loader.Find(readerTemplate, e.term.ANSIEnabled); err == nil {
+			tmpl = df
+		}
+	}
+	userID := 0
+	if u := e.session.User(); u != nil {
+		userID = u.ID
+	}
+	return msgreader.Run(msgreader.Config{
+		Term:     e.term,
+		Messages: e.services.MessageRepo,
+		UserID:   userID,
+		AreaID:   areaID,
+		StartID:  startID,
+		Template: tmpl,
+		Reply:    reply,
+	})
+}
+
+// handleResize runs on the session goroutine from within a terminal read.
+func (e *Engine) handleResize(width, height int) {
+	if !e.waitingInput {
+		e.resizePending = true
+		return
+	}
+	e.callResize()
+}
+
+// callResize calls the current menu's on_resize handler, if any.
+func (e *Engine) callResize() {
+	if !e.vm.HasMenuHandler("on_resize") {
+		return
+	}
+	err := e.vm.CallMenuHandler("on_resize", e.nodeUD,
+		lua.LNumber(e.term.Width), lua.LNumber(e.term.Height))
+	if err != nil {
+		scripting.LogError(e.currentMenu+".on_resize", err)
+	}
+}
+
+// handleWake runs on the session goroutine from within a terminal read.
+// The only wake-up so far is a shutdown warning.
+func (e *Engine) handleWake() {
+	if _, ok := e.session.ShutdownIn(); !ok {
+		return
+	}
+	if !e.waitingInput {
+		e.shutdownPending = true
+		return
+	}
+	e.callShutdown()
+}
+
+// callShutdown calls the current menu's on_shutdown handler, if any, with
+// the seconds left before the board goes down.
+func (e *Engine) callShutdown() {
+	left, ok := e.session.ShutdownIn()
+	if !ok || !e.vm.HasMenuHandler("on_shutdown") {
+		return
+	}
+	err := e.vm.CallMenuHandler("on_shutdown", e.nodeUD, lua.LNumber(int(left.Round(time.Second)/time.Second)))
+	if err != nil {
+		scripting.LogError(e.currentMenu+".on_shutdown", err)
+	}
+}
+
 // hasNavigationPending checks if a navigation signal has been set.
 func (e *Engine) hasNavigationPending() bool {
-	return e.nextMenu != "" || e.gosubMenu != "" || e.returnMenu || e.disconnect
+	return e.nextMenu != "" || e.gosubMenu != "" || e.returnMenu || e.disconnect || e.rerun
 }
 
 // SetMenuState stores a value in the persistent state for a menu.
@@ -430,13 +1009,15 @@ func (e *Engine) handleGotoMenu(name string) error {
 	return nil
 }
 
-func (e *Engine) handleGosubMenu(name string) error {
+func (e *Engine) handleGosubMenu(name string, args interface{}) error {
 	e.gosubMenu = name
+	e.gosubArgs = args
 	return nil
 }
 
-func (e *Engine) handleReturnMenu() error {
+func (e *Engine) handleReturnMenu(values interface{}) error {
 	e.returnMenu = true
+	e.returnResult = values
 	return nil
 }
 
@@ -457,6 +1038,55 @@ func (e *Engine) handleDisplay(name string) error {
 	return nil
 }
 
+func (e *Engine) handleDisplayRandom(pattern string) error {
+	df, err := e.loader.FindRandom(pattern, e.term.ANSIEnabled)
+	if err != nil {
+		return err
+	}
+	if err := ansi.Display(e.term, df); err != nil {
+		return err
+	}
+	e.indexFields(df)
+	return nil
+}
+
+func (e *Engine) handleDisplayPaged(name string) error {
+	df, err := e.loader.Find(name, e.term.ANSIEnabled)
+	if err != nil {
+		return err
+	}
+	// Paged art scrolls past any placeholders, so fields are not indexed.
+	e.currentFields = nil
+	return ansi.DisplayPaged(e.term, df)
+}
+
+func (e *Engine) handleAnimate(name string, bps int) (byte, bool, error) {
+	find := e.loader.Find
+	if strings.ContainsAny(name, "*?[") {
+		find = e.loader.FindRandom
+	}
+	df, err := find(name, e.term.ANSIEnabled)
+	if err != nil {
+		return 0, false, err
+	}
+	key, pressed, err := ansi.Animate(e.term, df, bps)
+	if err != nil {
+		return 0, false, err
+	}
+	e.indexFields(df)
+	return key, pressed, nil
+}
+
+func (e *Engine) handleDisplayAnimation(name string, fps int) (byte, bool, error) {
+	a, err := e.loader.FindAnimation(name, e.term.ANSIEnabled)
+	if err != nil {
+		return 0, false, err
+	}
+	// Placeholders only make sense on a still screen.
+	e.currentFields = nil
+	return ansi.PlayAnimation(e.term, a, fps)
+}
+
 func (e *Engine) indexFields(df *ansi.DisplayFile) {
 	if df == nil {
 		e.currentFields = nil
@@ -507,7 +1137,7 @@ func (e *Engine) overlayValuePlaceholders() {
 		_ = e.term.Send(value)
 	}
 
-	u := e.currentUser
+	u := e.session.User()
 	if u != nil {
 		printAt("USERNAME", u.Username)
 		printAt("NAME", u.Username) // alias
@@ -517,8 +1147,28 @@ func (e *Engine) overlayValuePlaceholders() {
 
 		printAt("LEVEL", fmt.Sprintf("%d", u.SecurityLevel))
 		printAt("SECURITY_LEVEL", fmt.Sprintf("%d", u.SecurityLevel))
+		if f, ok := e.currentFields["LEVEL_NAME"]; ok && e.services.UserRepo != nil {
+			// The name is drawn in the level's color from the level table.
+			l, _ := e.services.UserRepo.LevelOf(u.SecurityLevel)
+			name := l.Name
+			if name == "" {
+				name = fmt.Sprintf("%d", u.SecurityLevel)
+			}
+			if f.MaxLen > 0 {
+				name = padOrTrim(name, f.MaxLen)
+			}
+			if e.term.ANSIEnabled {
+				name = levelColor(l.Color) + name + terminal.Reset
+			}
+			_ = e.term.GotoXY(f.Row, f.Col)
+			_ = e.term.Send(name)
+		}
 		printAt("CALLS", fmt.Sprintf("%d", u.TotalCalls))
 		printAt("TOTAL_CALLS", fmt.Sprintf("%d", u.TotalCalls))
+		printAt("POSTS", fmt.Sprintf("%d", u.TotalPosts))
+		printAt("TIME_USED", fmt.Sprintf("%d", u.TimeUsedSecs/60))
+		printAt("UPLOADED", formatKB(u.BytesUploaded))
+		printAt("DOWNLOADED", formatKB(u.BytesDownloaded))
 
 		if u.LastCallAt != nil {
 			printAt("LAST_ON", u.LastCallAt.Format("2006-01-02 15:04"))
@@ -530,6 +1180,7 @@ func (e *Engine) overlayValuePlaceholders() {
 	}
 
 	printAt("NODE_ID", fmt.Sprintf("%d", e.services.NodeID))
+	printAt("NODE_NAME", e.services.NodeName)
 	printAt("NOW", time.Now().Format("2006-01-02 15:04"))
 
 	// Dynamic door usage placeholders:
@@ -546,6 +1197,11 @@ func (e *Engine) overlayValuePlaceholders() {
 	}
 }
 
+// formatKB renders a byte count in whole kilobytes.
+func formatKB(n int64) string {
+	return fmt.Sprintf("%dk", (n+1023)/1024)
+}
+
 func padOrTrim(s string, width int) string {
 	if width <= 0 {
 		return s
@@ -561,15 +1217,201 @@ func padOrTrim(s string, width int) string {
 }
 
 func (e *Engine) handleUserLogin(u *user.User) {
-	e.currentUser = u
+	if e.services != nil && u.SecurityLevel < e.services.MinLevel {
+		name := e.services.NodeName
+		if name == "" {
+			name = fmt.Sprintf("Node %d", e.services.NodeID)
+		}
+		log.Printf("Node %d: refused %s (level %d < %d)", e.services.NodeID, u.Username, u.SecurityLevel, e.services.MinLevel)
+		// Restricted nodes are only handed to anonymous callers once every
+		// public node is busy, so this is the "full for you" case.
+		e.term.SendLn(fmt.Sprintf("\r\n  Sorry, all public nodes are busy and %s is reserved. Please try again later.", name))
+		e.session.SetUser(nil)
+		e.handleDisconnect()
+		return
+	}
+
+	e.publish(event.Login, u)
+
 	// Update terminal ANSI setting based on user preference
 	e.term.ANSIEnabled = u.ANSIEnabled
+	if err := e.term.SetBaud(u.BaudRate); err != nil {
+		log.Printf("Baud rate for %s: %v", u.Username, err)
+	}
+	// "" is a caller who never chose; the terminal keeps its default.
+	if cs, ok := terminal.ParseCharset(u.Charset); ok && u.Charset != "" {
+		e.term.SetCharset(cs)
+	}
+	if p, ok := terminal.ParsePalette(u.Palette); ok {
+		e.term.SetPalette(p)
+	}
+	if d, ok := terminal.ParseColorDepth(u.Colors); ok {
+		e.term.SetColorDepth(d)
+	}
+	if e.conferences != nil {
+		// Back to the main board if the last conference is closed to them
+		if _, err := e.conferences.Current(u); err != nil {
+			log.Printf("Node %d: conference of %s: %v", e.services.NodeID, u.Username, err)
+		}
+	}
+	e.greet(u)
+	if e.services != nil && e.services.UserRepo != nil {
+		if err := e.services.UserRepo.UpdateLastNode(u.ID, e.services.NodeID); err != nil {
+			log.Printf("Node %d: failed to record last node for %s: %v", e.services.NodeID, u.Username, err)
+		}
+	}
 	if e.services != nil && e.services.ChatBroker != nil {
 		e.services.ChatBroker.UpdateOnlineName(e.services.NodeID, u.Username)
+		e.services.ChatBroker.SetDoNotDisturb(e.services.NodeID, u.DoNotDisturb)
+	}
+	if e.services != nil && e.services.MessageRepo != nil {
+		if n := e.services.MessageRepo.UnreadMailCount(u.ID); n > 0 {
+			e.term.SendLn(fmt.Sprintf("\r\n  You have new mail! (%d unread)", n))
+		}
+	}
+	limit := time.Duration(0)
+	if e.services != nil {
+		limit = e.services.TimeLimit
+	}
+	if e.services != nil && e.services.UserRepo != nil {
+		if l, err := e.services.UserRepo.LevelOf(u.SecurityLevel); err != nil {
+			log.Printf("Node %d: level of %s: %v", e.services.NodeID, u.Username, err)
+		} else {
+			if lt := time.Duration(l.TimeLimit) * time.Minute; lt > 0 && (limit == 0 || lt < limit) {
+				limit = lt
+			}
+			if e.fileAPI != nil {
+				e.fileAPI.Ratio = levelRatio(e.services.Ratio, l)
+			}
+		}
+	}
+	if limit > 0 {
+		e.startTimeLimit(limit)
+	}
+}
+
+// levelRatio returns the board's download ratio as it applies to users of
+// level l: exempt at -1, or 1:l.Ratio when it sets its own.
+func levelRatio(board filearea.Ratio, l user.Level) filearea.Ratio {
+	switch {
+	case l.Ratio < 0:
+		board.PerUpload = 0
+	case l.Ratio > 0:
+		board.PerUpload = l.Ratio
+	}
+	return board
+}
+
+// levelColor returns the SGR sequence for a level table color: 0-7, or
+// 8-15 for the bright versions.
+func levelColor(c int) string {
+	return fmt.Sprintf("\033[%d;%dm", c/8%2, 30+c%8)
+}
+
+// greet shows the caller's greeting, art first, when greetings are shown
+// at login.
+func (e *Engine) greet(u *user.User) {
+	if e.services == nil || e.services.Greetings == nil || !e.services.GreetAtLogin {
+		return
+	}
+	g := e.services.Greetings.Pick(u, time.Now())
+	if g == nil {
+		return
+	}
+	if g.Art != "" {
+		if df, err := e.loader.FindRandom(g.Art, e.term.ANSIEnabled); err != nil {
+			log.Printf("Node %d: greeting art: %v", e.services.NodeID, err)
+		} else if err := ansi.Display(e.term, df); err != nil {
+			log.Printf("Node %d: greeting art: %v", e.services.NodeID, err)
+		}
+	}
+	if g.Text != "" {
+		e.term.SendLn("\r\n  " + g.Text)
 	}
 }
 
-func (e *Engine) handleShowOnline() error {
+// publish announces an event for this node on the services' bus.
+func (e *Engine) publish(name string, data interface{}) {
+	if e.services == nil {
+		return
+	}
+	e.services.Events.Publish(event.Event{Name: name, NodeID: e.services.NodeID, Data: data})
+}
+
+// floodCheck checks an action by the logged-in user against flood
+// control. Refusals are published as event.Flood.
+func (e *Engine) floodCheck(action string) (time.Duration, bool) {
+	u := e.session.User()
+	if e.services == nil || e.services.Flood == nil || u == nil {
+		return 0, true
+	}
+	retry, ok := e.services.Flood.Allow(action, u.ID, u.SecurityLevel)
+	if !ok {
+		e.publish(event.Flood, &flood.Violation{Action: action, UserID: u.ID, Username: u.Username, Retry: retry})
+	}
+	return retry, ok
+}
+
+// handlePrivateMail notifies the recipient live on any node they are
+// logged in on.
+func (e *Engine) handlePrivateMail(from, to *user.User, subject string) {
+	if e.services == nil {
+		return
+	}
+	if e.services.ChatBroker != nil {
+		e.services.ChatBroker.NotifyUser(to.Username,
+			fmt.Sprintf("You have new mail from %s: %s", from.Username, subject))
+	}
+	if m := e.services.Mailer; m != nil && to.MailNotify && to.Email != "" {
+		err := m.Notify(to.Email, "new_mail", map[string]any{
+			"User": to.Username, "From": from.Username, "Subject": subject,
+		})
+		if err != nil {
+			log.Printf("Node %d: mail notice to %s: %v", e.services.NodeID, to.Username, err)
+		}
+	}
+}
+
+// sendResetCode mails u a password reset code.
+func (e *Engine) sendResetCode(u *user.User) error {
+	ttl := e.services.ResetCodeTTL
+	code, err := e.services.UserRepo.CreateResetCode(u, ttl)
+	if err != nil {
+		return err
+	}
+	err = e.services.Mailer.Notify(u.Email, "reset_code", map[string]any{
+		"User": u.Username, "Code": code, "Minutes": int(ttl.Minutes()),
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Node %d: password reset code mailed to %s", e.services.NodeID, u.Username)
+	return nil
+}
+
+// startTimeLimit arms the per-call time limit for this node. The user gets
+// a one-minute warning (for limits longer than two minutes) and is then
+// disconnected by closing the terminal, which unblocks any pending read.
+func (e *Engine) startTimeLimit(limit time.Duration) {
+	for _, t := range e.timeLimitTimers {
+		t.Stop()
+	}
+	e.timeLimitTimers = nil
+	e.session.SetDeadline(time.Now().Add(limit))
+
+	if limit > 2*time.Minute {
+		e.timeLimitTimers = append(e.timeLimitTimers, time.AfterFunc(limit-time.Minute, func() {
+			e.term.SendLn("\r\n*** One minute remaining on this node.")
+		}))
+	}
+	e.timeLimitTimers = append(e.timeLimitTimers, time.AfterFunc(limit, func() {
+		log.Printf("Node %d: time limit reached", e.services.NodeID)
+		e.term.SendLn("\r\n*** Your time on this node has expired. Goodbye!")
+		e.term.Close()
+	}))
+}
+
+func (e *Engine) handleShowOnline(pause bool) error {
 	if e.services == nil || e.services.ChatBroker == nil {
 		e.term.SendLn("\r\n  Who's online not available.")
 		return nil
@@ -581,36 +1423,90 @@ func (e *Engine) handleShowOnline() error {
 	e.term.SendLn("  ----  -----------------  --------")
 	for _, u := range users {
 		status := "Online"
-		if u.Room != "" {
+		switch {
+		case chat.IsPrivateRoom(u.Room):
+			status = "Private chat"
+		case u.Room != "":
 			status = "Chat: " + u.Room
 		}
+		if u.DoNotDisturb {
+			status += " (do not disturb)"
+		}
 		e.term.SendLn(fmt.Sprintf("  %-4d  %-17s  %s", u.NodeID, u.UserName, status))
 	}
 	if len(users) == 0 {
 		e.term.SendLn("  No users online.")
 	}
 	e.term.SendLn("")
-	e.term.Pause()
+	if pause {
+		e.term.Pause()
+	}
 	return nil
 }
 
-func (e *Engine) handleEnterChat() error {
+// setDoNotDisturb saves whether the user refuses pages and tells the
+// broker.
+func (e *Engine) setDoNotDisturb(on bool) error {
+	u := e.session.User()
+	if u == nil {
+		return fmt.Errorf("not logged in")
+	}
+	if e.services.UserRepo != nil {
+		if err := e.services.UserRepo.SetDoNotDisturb(u.ID, on); err != nil {
+			return err
+		}
+	}
+	u.DoNotDisturb = on
+	e.services.ChatBroker.SetDoNotDisturb(e.services.NodeID, on)
+	return nil
+}
+
+// saveFileScan records when the user last scanned for new files.
+func (e *Engine) saveFileScan(at time.Time) error {
+	u := e.session.User()
+	if u == nil {
+		return fmt.Errorf("not logged in")
+	}
+	if e.services.UserRepo != nil {
+		if err := e.services.UserRepo.SetFileScan(u.ID, at); err != nil {
+			return err
+		}
+	}
+	u.FileScanAt = &at
+	return nil
+}
+
+func (e *Engine) handleSpy(targetID int, takeover bool) error {
+	if e.session.Level() < user.LevelSysop {
+		return fmt.Errorf("sysop level required")
+	}
+	if e.services == nil || e.services.SpyNode == nil {
+		return fmt.Errorf("node spy not available")
+	}
+	return e.services.SpyNode(targetID, takeover)
+}
+
+func (e *Engine) handleEnterChat(room string) error {
 	if e.services == nil || e.services.ChatBroker == nil {
 		e.term.SendLn("\r\n  Chat not available.")
 		return nil
 	}
-
-	userName := "Unknown"
-	if e.currentUser != nil {
-		userName = e.currentUser.Username
+	if room == "" {
+		room = "main"
+	}
+	if !e.services.ChatBroker.MayEnter(e.services.NodeID, room) {
+		return chat.ErrPrivateRoom
 	}
-	room := "main"
 
+	userName, level := "Unknown", 0
+	if u := e.session.User(); u != nil {
+		userName, level = u.Username, u.SecurityLevel
+	}
 	// Optional chat UI template (ASCII/ANSI art with placeholders).
 	// If missing, the chat session will fall back to its classic output.
 	var tmpl *ansi.DisplayFile
 	if e.loader != nil {
-		if df, err := e.loader.Find("chat_room", e.term.ANSIEnabled); err == nil {
+		if df, err := e.loader.Find(chatRoomTemplate, e.term.ANSIEnabled); err == nil {
 			tmpl = df
 		}
 	}
@@ -620,8 +1516,13 @@ func (e *Engine) handleEnterChat() error {
 		Broker:   e.services.ChatBroker,
 		NodeID:   e.services.NodeID,
 		UserName: userName,
+		Level:    level,
 		Room:     room,
 		Template: tmpl,
+		Flood: func() (time.Duration, bool) {
+			return e.floodCheck(flood.Chat)
+		},
+		History: e.services.ChatHistory,
 	}); err != nil {
 		return err
 	}
@@ -0,0 +1,257 @@
+package menu
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+)
+
+// Reference kinds found in menu scripts.
+const (
+	RefGoto          = "goto_menu"
+	RefGosub         = "gosub_menu"
+	RefDisplay       = "display"
+	RefDisplayPaged  = "display_paged"
+	RefDisplayRandom = "display_random"
+	RefAnimate       = "animate"
+	RefAnimation     = "display_animation"
+)
+
+// DefaultStartMenus are the menus a new session can begin in (see node.Run).
+var DefaultStartMenus = []string{"welcome", "welcome_ssh"}
+
+// chatRoomTemplate is the optional chat UI art the engine displays itself.
+const chatRoomTemplate = "chat_room"
+
+// readerTemplate is the optional message reader art (msg.read_loop).
+const readerTemplate = "message_read"
+
+// accessDeniedScreen is the optional art shown when a caller may not enter a
+// menu (see Access).
+const accessDeniedScreen = "access_denied"
+
+// engineDisplays lists display files used by Go code rather than scripts.
+var engineDisplays = []string{chatRoomTemplate, readerTemplate, accessDeniedScreen}
+
+// Ref is a reference from a menu script to another menu or display file.
+// Target is empty when the argument is not a string literal.
+type Ref struct {
+	From   string
+	Line   int
+	Kind   string
+	Target string
+}
+
+// Problem is a broken reference or an unreachable menu.
+type Problem struct {
+	Menu    string
+	Line    int // 0 when the problem concerns the whole menu
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.Menu, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Menu, p.Message)
+}
+
+// Graph is the static navigation graph of all menu scripts.
+type Graph struct {
+	Menus []string // sorted registry names
+	Refs  []Ref
+}
+
+var (
+	refPattern     = regexp.MustCompile(`:\s*(goto_menu|gosub_menu|display_paged|display_random|display_animation|display|animate)\s*\(\s*(?:"([^"]*)"|'([^']*)'|([^)\s]))`)
+	commentPattern = regexp.MustCompile(`--.*$`)
+)
+
+// BuildGraph scans the Lua script of every registered menu for
+// node:goto_menu/gosub_menu/display/display_paged/display_random/animate/display_animation
+// calls.
+func BuildGraph(reg *Registry) (*Graph, error) {
+	g := &Graph{Menus: reg.List()}
+	sort.Strings(g.Menus)
+
+	for _, name := range g.Menus {
+		m := reg.Get(name)
+		if !m.HasScript() {
+			continue
+		}
+		refs, err := scanScript(name, m.ScriptPath)
+		if err != nil {
+			return nil, err
+		}
+		g.Refs = append(g.Refs, refs...)
+	}
+	return g, nil
+}
+
+func scanScript(name, path string) ([]Ref, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read menu script %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var refs []Ref
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		// Drop trailing comments unless "--" may be inside a string.
+		if !strings.ContainsAny(text, `"'`) || strings.HasPrefix(strings.TrimSpace(text), "--") {
+			text = commentPattern.ReplaceAllString(text, "")
+		}
+		for _, m := range refPattern.FindAllStringSubmatch(text, -1) {
+			ref := Ref{From: name, Line: line, Kind: m[1]}
+			switch {
+			case m[4] != "":
+				// Not a string literal: target is only known at runtime.
+			case m[2] != "":
+				ref.Target = m[2]
+			default:
+				ref.Target = m[3]
+			}
+			refs = append(refs, ref)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read menu script %s: %w", path, err)
+	}
+	return refs, nil
+}
+
+// Check reports references to menus or display files that do not exist and
+// menus that cannot be reached from any of the start menus. Display targets
+// are resolved with loader the same way the engine does.
+func (g *Graph) Check(reg *Registry, loader *ansi.Loader, startMenus ...string) []Problem {
+	var problems []Problem
+	displayed := make(map[string]bool)
+	for _, name := range engineDisplays {
+		displayed[name] = true
+	}
+
+	for _, ref := range g.Refs {
+		if ref.Target == "" {
+			continue
+		}
+		switch ref.Kind {
+		case RefGoto, RefGosub:
+			if reg.Get(ref.Target) == nil {
+				problems = append(problems, Problem{Menu: ref.From, Line: ref.Line,
+					Message: fmt.Sprintf("%s target %q does not exist", ref.Kind, ref.Target)})
+			}
+		case RefDisplay, RefDisplayPaged:
+			displayed[ref.Target] = true
+			if _, err := loader.Find(ref.Target, true); err != nil {
+				problems = append(problems, Problem{Menu: ref.From, Line: ref.Line,
+					Message: fmt.Sprintf("%s file %q not found", ref.Kind, ref.Target)})
+			}
+		case RefAnimate:
+			find := loader.Find
+			if strings.ContainsAny(ref.Target, "*?[") {
+				find = loader.FindRandom
+			} else {
+				displayed[ref.Target] = true
+			}
+			if _, err := find(ref.Target, true); err != nil {
+				problems = append(problems, Problem{Menu: ref.From, Line: ref.Line,
+					Message: fmt.Sprintf("%s file %q not found", ref.Kind, ref.Target)})
+			}
+		case RefAnimation:
+			displayed[ref.Target] = true
+			if _, err := loader.FindAnimation(ref.Target, true); err != nil {
+				problems = append(problems, Problem{Menu: ref.From, Line: ref.Line,
+					Message: fmt.Sprintf("%s %q: %v", ref.Kind, ref.Target, err)})
+			}
+		case RefDisplayRandom:
+			pattern := ref.Target
+			if !strings.ContainsAny(pattern, "*?[") {
+				pattern += "/*"
+			}
+			if _, err := loader.FindRandom(pattern, true); err != nil {
+				problems = append(problems, Problem{Menu: ref.From, Line: ref.Line,
+					Message: fmt.Sprintf("%s pattern %q matches no files", ref.Kind, ref.Target)})
+			}
+		}
+	}
+
+	for _, name := range g.Menus {
+		if m := reg.Get(name); m.AccessErr != nil {
+			problems = append(problems, Problem{Menu: name, Message: m.AccessErr.Error()})
+		}
+	}
+
+	reachable := g.Reachable(startMenus...)
+	for _, name := range g.Menus {
+		if !reachable[name] && !displayed[name] {
+			problems = append(problems, Problem{Menu: name, Message: "not reachable from " + strings.Join(startMenus, ", ")})
+		}
+	}
+	return problems
+}
+
+// Reachable returns the set of menus reachable from the start menus through
+// goto_menu/gosub_menu calls with literal targets.
+func (g *Graph) Reachable(startMenus ...string) map[string]bool {
+	edges := make(map[string][]string)
+	for _, ref := range g.Refs {
+		if ref.Target != "" && (ref.Kind == RefGoto || ref.Kind == RefGosub) {
+			edges[ref.From] = append(edges[ref.From], ref.Target)
+		}
+	}
+
+	seen := make(map[string]bool)
+	queue := append([]string(nil), startMenus...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		queue = append(queue, edges[name]...)
+	}
+	return seen
+}
+
+// Dynamic returns references whose target is not a string literal and so
+// cannot be checked statically.
+func (g *Graph) Dynamic() []Ref {
+	var refs []Ref
+	for _, ref := range g.Refs {
+		if ref.Target == "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// DOT renders the navigation graph in Graphviz format. gosub edges are
+// dashed; display references are omitted.
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph menus {\n")
+	seen := make(map[string]bool)
+	for _, ref := range g.Refs {
+		if ref.Target == "" || (ref.Kind != RefGoto && ref.Kind != RefGosub) {
+			continue
+		}
+		edge := fmt.Sprintf("  %q -> %q", ref.From, ref.Target)
+		if ref.Kind == RefGosub {
+			edge += " [style=dashed]"
+		}
+		if !seen[edge] {
+			seen[edge] = true
+			b.WriteString(edge + ";\n")
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
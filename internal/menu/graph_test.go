@@ -0,0 +1,69 @@
+package menu
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+)
+
+func TestGraphCheck(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"welcome.lua": `local menu = {}
+function menu.on_enter(node)
+    node:display("banner") -- shown first
+    node:goto_menu("main_menu")
+end
+return menu`,
+		"banner.asc": "hi",
+		"main_menu.lua": `local menu = {}
+function menu.on_key(node, key)
+    if key == "X" then node:goto_menu("nowhere") end
+    if key == "H" then node:display_paged('missing_help') end
+    if key == "A" then node:display_animation("spinner", 8) end
+    node:gosub_menu(next_menu)
+end
+return menu`,
+		"orphan.lua": `return {}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg := NewRegistry(dir)
+	if err := reg.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	g, err := BuildGraph(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range g.Check(reg, ansi.NewLoader(dir), "welcome") {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`main_menu:3: goto_menu target "nowhere" does not exist`,
+		`main_menu:4: display_paged file "missing_help" not found`,
+		`main_menu:5: display_animation "spinner": display file not found: spinner`,
+		`orphan: not reachable from welcome`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if dyn := g.Dynamic(); len(dyn) != 1 || dyn[0].From != "main_menu" || dyn[0].Kind != RefGosub {
+		t.Fatalf("expected one dynamic gosub reference, got %+v", dyn)
+	}
+}
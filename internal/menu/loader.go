@@ -25,8 +25,8 @@ func NewRegistry(dirs ...string) *Registry {
 }
 
 // Scan discovers all menu files in the configured directories.
-// Files sharing a base name (e.g., main_menu.ans, main_menu.asc, main_menu.lua)
-// are grouped into a single Menu entry.
+// Files sharing a base name (e.g., main_menu.ans, main_menu.asc, main_menu.lua,
+// main_menu.yaml) are grouped into a single Menu entry.
 func (r *Registry) Scan() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -67,10 +67,21 @@ func (r *Registry) Scan() error {
 				m.ASCPath = fullPath
 			case ".lua":
 				m.ScriptPath = fullPath
+			case ".yaml", ".yml":
+				m.AccessPath = fullPath
 			}
 		}
 	}
 
+	for _, m := range r.menus {
+		if m.AccessPath == "" {
+			continue
+		}
+		if m.Access, m.AccessErr = LoadAccess(m.AccessPath); m.AccessErr != nil {
+			log.Printf("Menu %s stays closed: %v", m.Name, m.AccessErr)
+		}
+	}
+
 	log.Printf("Loaded %d menus", len(r.menus))
 	for name, m := range r.menus {
 		parts := []string{}
@@ -83,6 +94,9 @@ func (r *Registry) Scan() error {
 		if m.HasScript() {
 			parts = append(parts, "LUA")
 		}
+		if m.AccessPath != "" {
+			parts = append(parts, "YAML")
+		}
 		log.Printf("  Menu: %s [%s]", name, strings.Join(parts, "+"))
 	}
 
@@ -6,6 +6,13 @@ type Menu struct {
 	ANSPath    string // path to .ans file (may be empty)
 	ASCPath    string // path to .asc file (may be empty)
 	ScriptPath string // path to .lua file (may be empty)
+	AccessPath string // path to .yaml access file (may be empty)
+
+	// Access is parsed from AccessPath by Registry.Scan; nil when there is
+	// no access file. AccessErr is set when the file could not be read,
+	// and the engine then keeps the menu closed.
+	Access    *Access
+	AccessErr error
 }
 
 // HasANS returns true if an ANSI display file exists for this menu.
@@ -0,0 +1,37 @@
+package menu
+
+import "github.com/notepid/twilight_bbs/internal/terminal"
+
+// suspend hands the terminal to a door or file transfer. Until the returned
+// resume runs, nothing else writes to the connection: the time limit timers
+// are stopped, and notices and broadcasts are held by the session. Resizes
+// queue as they do whenever the engine is not waiting for input.
+//
+// resume re-arms the time limit from the session deadline, resets the
+// screen, shows the held notices and has the current menu redrawn once the
+// script's handler returns, unless the script goes elsewhere.
+func (e *Engine) suspend(activity string) (resume func()) {
+	e.session.SetBusy(activity)
+	timed := len(e.timeLimitTimers) > 0
+	for _, t := range e.timeLimitTimers {
+		t.Stop()
+	}
+	e.timeLimitTimers = nil
+
+	return func() {
+		e.session.SetBusy("")
+		if timed {
+			// A deadline that passed meanwhile expires at once.
+			left, _ := e.session.TimeLeft()
+			e.startTimeLimit(left)
+		}
+		if e.term.ANSIEnabled {
+			e.term.Send(terminal.Reset + terminal.ShowCursor())
+		}
+		e.term.Cls()
+		for _, text := range e.session.TakeNotices() {
+			e.term.SendLn("*** " + text)
+		}
+		e.rerun = true
+	}
+}
@@ -0,0 +1,321 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// Archive formats for ExportArchive and ReadArchive.
+const (
+	FormatJSON = "json"
+	FormatMbox = "mbox"
+)
+
+// Archived is a message in a portable archive. Authors and recipients are
+// names, and replies point at the ID of another message in the same
+// archive, so an area can move between boards.
+type Archived struct {
+	ID      string    `json:"id"`
+	ReplyTo string    `json:"reply_to,omitempty"`
+	From    string    `json:"from"`
+	To      string    `json:"to,omitempty"` // empty for public messages
+	Subject string    `json:"subject"`
+	Date    time.Time `json:"date"`
+	Body    string    `json:"body"`
+}
+
+// jsonArchive is the layout of a JSON archive file.
+type jsonArchive struct {
+	Area     string      `json:"area"`
+	Exported time.Time   `json:"exported"`
+	Messages []*Archived `json:"messages"`
+}
+
+// mboxDomain is the right-hand side of exported Message-IDs and addresses.
+const mboxDomain = "twilight-bbs"
+
+// ToArchive converts messages for export.
+func ToArchive(msgs []*Message) []*Archived {
+	out := make([]*Archived, 0, len(msgs))
+	for _, m := range msgs {
+		a := &Archived{
+			ID:      strconv.Itoa(m.ID),
+			From:    m.FromName,
+			Subject: m.Subject,
+			Date:    m.CreatedAt,
+			Body:    m.Body,
+		}
+		if m.ToUserID != nil {
+			a.To = m.ToName
+		}
+		if m.ReplyToID != nil {
+			a.ReplyTo = strconv.Itoa(*m.ReplyToID)
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// ExportArchive writes messages from the named area in format.
+func ExportArchive(w io.Writer, format, area string, msgs []*Archived) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(jsonArchive{Area: area, Exported: time.Now().UTC(), Messages: msgs})
+	case FormatMbox:
+		return writeMbox(w, msgs)
+	}
+	return fmt.Errorf("unknown archive format %q", format)
+}
+
+// ReadArchive reads messages written by ExportArchive. mbox files from mail
+// clients and other boards work too; their Message-ID, In-Reply-To and
+// References headers link the replies.
+func ReadArchive(r io.Reader, format string) ([]*Archived, error) {
+	switch format {
+	case FormatJSON:
+		var a jsonArchive
+		if err := json.NewDecoder(r).Decode(&a); err != nil {
+			return nil, fmt.Errorf("read json archive: %w", err)
+		}
+		return a.Messages, nil
+	case FormatMbox:
+		return readMbox(r)
+	}
+	return nil, fmt.Errorf("unknown archive format %q", format)
+}
+
+// fromLine matches body lines that mboxrd quotes with a leading ">".
+var fromLine = regexp.MustCompile(`^>*From `)
+
+func writeMbox(w io.Writer, msgs []*Archived) error {
+	bw := bufio.NewWriter(w)
+	for _, a := range msgs {
+		fmt.Fprintf(bw, "From %s %s\n", mboxDomain, a.Date.UTC().Format(time.ANSIC))
+		fmt.Fprintf(bw, "Message-ID: %s\n", mboxID(a.ID))
+		if a.ReplyTo != "" {
+			fmt.Fprintf(bw, "In-Reply-To: %s\n", mboxID(a.ReplyTo))
+		}
+		fmt.Fprintf(bw, "Date: %s\n", a.Date.Format(time.RFC1123Z))
+		fmt.Fprintf(bw, "From: %s\n", mboxAddress(a.From))
+		if a.To != "" {
+			fmt.Fprintf(bw, "To: %s\n", mboxAddress(a.To))
+		}
+		fmt.Fprintf(bw, "Subject: %s\n", mime.QEncoding.Encode("utf-8", a.Subject))
+		fmt.Fprintf(bw, "Content-Type: text/plain; charset=utf-8\n\n")
+
+		body := strings.ReplaceAll(a.Body, "\r\n", "\n")
+		for _, line := range strings.SplitAfter(body, "\n") {
+			if fromLine.MatchString(line) {
+				bw.WriteString(">")
+			}
+			bw.WriteString(line)
+		}
+		if !strings.HasSuffix(body, "\n") {
+			bw.WriteString("\n")
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+func mboxID(id string) string {
+	return "<" + id + "@" + mboxDomain + ">"
+}
+
+// mboxAddress turns a user name into an address mail clients accept.
+func mboxAddress(name string) string {
+	local := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, name)
+	addr := mail.Address{Name: name, Address: local + "@" + mboxDomain}
+	return addr.String()
+}
+
+func readMbox(r io.Reader) ([]*Archived, error) {
+	var out []*Archived
+	var cur *bytes.Buffer
+	flush := func() error {
+		if cur == nil {
+			return nil
+		}
+		a, err := parseMboxMessage(cur.Bytes())
+		if err != nil {
+			return fmt.Errorf("read mbox message %d: %w", len(out)+1, err)
+		}
+		out = append(out, a)
+		return nil
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	blank := true
+	for sc.Scan() {
+		line := sc.Text()
+		if blank && strings.HasPrefix(line, "From ") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			cur = &bytes.Buffer{}
+			continue
+		}
+		blank = line == ""
+		if cur == nil {
+			return nil, fmt.Errorf("read mbox: file does not start with a From line")
+		}
+		if strings.HasPrefix(line, ">") && fromLine.MatchString(line) {
+			line = line[1:]
+		}
+		cur.WriteString(line)
+		cur.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read mbox: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func parseMboxMessage(raw []byte) (*Archived, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	h := msg.Header
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(h.Get("Subject"))
+	if err != nil {
+		subject = h.Get("Subject")
+	}
+
+	a := &Archived{
+		ID:      strings.TrimSpace(h.Get("Message-ID")),
+		From:    mboxName(h.Get("From")),
+		To:      mboxName(h.Get("To")),
+		Subject: subject,
+		// Drop the blank line that separates messages.
+		Body: strings.TrimSuffix(strings.TrimSuffix(string(body), "\n"), "\n"),
+	}
+	if date, err := h.Date(); err == nil {
+		a.Date = date
+	}
+	a.ReplyTo = strings.TrimSpace(h.Get("In-Reply-To"))
+	if a.ReplyTo == "" {
+		if refs := strings.Fields(h.Get("References")); len(refs) > 0 {
+			a.ReplyTo = refs[len(refs)-1]
+		}
+	}
+	return a, nil
+}
+
+// mboxName returns the display name of an address header, or its local
+// part when there is no name.
+func mboxName(header string) string {
+	if header == "" {
+		return ""
+	}
+	addr, err := mail.ParseAddress(header)
+	if err != nil {
+		return strings.TrimSpace(header)
+	}
+	if addr.Name != "" {
+		return addr.Name
+	}
+	local, _, _ := strings.Cut(addr.Address, "@")
+	return local
+}
+
+// ImportArchive stores archived messages in an area in archive order and
+// returns how many it stored. Authors and recipients with a local account
+// are linked to it; other authors are shown by name under ownerID, and
+// messages to unknown recipients go to ownerID so nothing private becomes
+// public. Replies are linked when the message they answer is in the same
+// archive. Nothing is stored if any message fails.
+func (r *Repo) ImportArchive(areaID, ownerID int, msgs []*Archived) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("import archive: %w", err)
+	}
+	defer tx.Rollback()
+
+	lookup := func(name string) (int, bool) {
+		var id int
+		err := tx.QueryRow(`SELECT id FROM users WHERE username = ? COLLATE NOCASE`, name).Scan(&id)
+		return id, err == nil
+	}
+
+	ids := make(map[string]int)
+	replies := make(map[int]string)
+	for _, a := range msgs {
+		fromID, fromName := ownerID, a.From
+		if id, ok := lookup(a.From); ok {
+			fromID, fromName = id, ""
+		}
+		var toID *int
+		if a.To != "" {
+			id, ok := lookup(a.To)
+			if !ok {
+				id = ownerID
+			}
+			toID = &id
+		}
+		date := a.Date
+		if date.IsZero() {
+			date = time.Now()
+		}
+		result, err := tx.Exec(`
+			INSERT INTO messages (area_id, from_user_id, from_net, to_user_id, subject, body, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, areaID, fromID, fromName, toID, a.Subject, a.Body, date.UTC().Format(db.SQLiteTime))
+		if err != nil {
+			return 0, fmt.Errorf("import archive: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("import archive: %w", err)
+		}
+		if a.ID != "" {
+			ids[a.ID] = int(id)
+		}
+		if a.ReplyTo != "" {
+			replies[int(id)] = a.ReplyTo
+		}
+	}
+
+	for id, parent := range replies {
+		if parentID, ok := ids[parent]; ok {
+			if _, err := tx.Exec(`UPDATE messages SET reply_to_id = ? WHERE id = ?`, parentID, id); err != nil {
+				return 0, fmt.Errorf("import archive: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("import archive: %w", err)
+	}
+	return len(msgs), nil
+}
@@ -0,0 +1,109 @@
+package message
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatMbox} {
+		t.Run(format, func(t *testing.T) {
+			database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close()
+			if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'sysop', 'x'), (2, 'alice', 'x')`); err != nil {
+				t.Fatal(err)
+			}
+			repo := NewRepo(database.DB)
+
+			root, _ := repo.Post(1, 2, nil, "Hello", "First line\nFrom here on\n>From quoted", nil)
+			if _, err := repo.Post(1, 1, nil, "Re: Hello", "A reply", &root); err != nil {
+				t.Fatal(err)
+			}
+			to := 2
+			if _, err := repo.Import(1, 1, "Zed Outsider", "Private", "For alice only", nil, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.Post(1, 1, &to, "Psst", "Secret", nil); err != nil {
+				t.Fatal(err)
+			}
+
+			msgs, err := repo.MessagesAfter(1, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := ExportArchive(&buf, format, "General", ToArchive(msgs)); err != nil {
+				t.Fatal(err)
+			}
+			archived, err := ReadArchive(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, err := repo.ImportArchive(3, 1, archived); err != nil || n != 4 {
+				t.Fatalf("ImportArchive = %d, %v", n, err)
+			}
+
+			got, err := repo.MessagesAfter(3, 0)
+			if err != nil || len(got) != 4 {
+				t.Fatalf("imported %d messages, %v", len(got), err)
+			}
+			if got[0].FromName != "alice" || got[0].FromUserID != 2 || got[0].Body != msgs[0].Body {
+				t.Errorf("root = %+v", got[0])
+			}
+			if got[1].ReplyToID == nil || *got[1].ReplyToID != got[0].ID {
+				t.Errorf("reply not linked: %+v", got[1].ReplyToID)
+			}
+			if got[2].FromName != "Zed Outsider" || got[2].FromUserID != 1 {
+				t.Errorf("outside author = %q (user %d)", got[2].FromName, got[2].FromUserID)
+			}
+			if got[3].ToUserID == nil || *got[3].ToUserID != 2 {
+				t.Errorf("private message lost its recipient")
+			}
+			if !got[0].CreatedAt.Equal(msgs[0].CreatedAt) {
+				t.Errorf("date = %v, want %v", got[0].CreatedAt, msgs[0].CreatedAt)
+			}
+		})
+	}
+}
+
+func TestReadMboxFromOtherSoftware(t *testing.T) {
+	mbox := strings.Join([]string{
+		"From someone@example.org Mon Jan  2 15:04:05 2006",
+		"Message-ID: <a@example.org>",
+		"From: Bob <bob@example.org>",
+		"Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=",
+		"Date: Mon, 02 Jan 2006 15:04:05 +0000",
+		"",
+		"Body",
+		"",
+		"From someone@example.org Mon Jan  2 16:04:05 2006",
+		"Message-ID: <b@example.org>",
+		"References: <x@example.org> <a@example.org>",
+		"From: carol@example.org",
+		"Subject: Re",
+		"",
+		"Answer",
+		"",
+	}, "\n")
+	msgs, err := ReadArchive(strings.NewReader(mbox), FormatMbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("read %d messages", len(msgs))
+	}
+	if msgs[0].From != "Bob" || msgs[0].Subject != "Grüße" || msgs[0].Body != "Body" {
+		t.Errorf("first = %+v", msgs[0])
+	}
+	if msgs[1].From != "carol" || msgs[1].ReplyTo != "<a@example.org>" {
+		t.Errorf("second = %+v", msgs[1])
+	}
+}
@@ -0,0 +1,118 @@
+package message
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SendPrivate stores a private message from one user to another.
+func (r *Repo) SendPrivate(fromUserID, toUserID int, subject, body string) (int, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO private_mail (from_user_id, to_user_id, subject, body)
+		VALUES (?, ?, ?, ?)
+	`, fromUserID, toUserID, subject, body)
+	if err != nil {
+		return 0, fmt.Errorf("send private mail: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Inbox returns mail addressed to a user that they have not deleted,
+// newest first.
+func (r *Repo) Inbox(userID int) ([]*Mail, error) {
+	return r.listMail(`WHERE p.to_user_id = ? AND p.deleted_by_recipient = 0`, userID)
+}
+
+// Outbox returns mail sent by a user, newest first. Mail the recipient has
+// deleted is still listed so the sender keeps a record of it.
+func (r *Repo) Outbox(userID int) ([]*Mail, error) {
+	return r.listMail(`WHERE p.from_user_id = ?`, userID)
+}
+
+// UnreadMailCount returns the number of unread, undeleted mails for a user.
+func (r *Repo) UnreadMailCount(userID int) int {
+	var count int
+	r.db.QueryRow(`
+		SELECT COUNT(*) FROM private_mail
+		WHERE to_user_id = ? AND read_at IS NULL AND deleted_by_recipient = 0
+	`, userID).Scan(&count)
+	return count
+}
+
+// ReadPrivate returns a mail visible to userID (as sender or recipient).
+// When the recipient reads it for the first time it is marked read.
+func (r *Repo) ReadPrivate(id, userID int) (*Mail, error) {
+	mails, err := r.listMail(`WHERE p.id = ? AND (p.from_user_id = ? OR (p.to_user_id = ? AND p.deleted_by_recipient = 0))`,
+		id, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(mails) == 0 {
+		return nil, fmt.Errorf("mail %d not found", id)
+	}
+	m := mails[0]
+
+	if m.ToUserID == userID && m.ReadAt == nil {
+		now := time.Now()
+		if _, err := r.db.Exec(`UPDATE private_mail SET read_at = ? WHERE id = ?`, now, m.ID); err != nil {
+			return nil, fmt.Errorf("mark mail %d read: %w", m.ID, err)
+		}
+		m.ReadAt = &now
+	}
+	return m, nil
+}
+
+// DeletePrivate hides a mail from its recipient's inbox. Only the
+// recipient may delete a mail.
+func (r *Repo) DeletePrivate(id, userID int) error {
+	result, err := r.db.Exec(`
+		UPDATE private_mail SET deleted_by_recipient = 1
+		WHERE id = ? AND to_user_id = ? AND deleted_by_recipient = 0
+	`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete mail %d: %w", id, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("mail %d not found", id)
+	}
+	return nil
+}
+
+// listMail runs a mail query with the given WHERE clause.
+func (r *Repo) listMail(where string, args ...interface{}) ([]*Mail, error) {
+	rows, err := r.db.Query(`
+		SELECT p.id, p.from_user_id, COALESCE(uf.username, 'Unknown'),
+		       p.to_user_id, COALESCE(ut.username, 'Unknown'),
+		       p.subject, p.body, p.read_at, p.created_at
+		FROM private_mail p
+		LEFT JOIN users uf ON uf.id = p.from_user_id
+		LEFT JOIN users ut ON ut.id = p.to_user_id
+		`+where+`
+		ORDER BY p.id DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list mail: %w", err)
+	}
+	defer rows.Close()
+
+	var mails []*Mail
+	for rows.Next() {
+		m := &Mail{}
+		var readAt sql.NullTime
+		if err := rows.Scan(&m.ID, &m.FromUserID, &m.FromName, &m.ToUserID, &m.ToName,
+			&m.Subject, &m.Body, &readAt, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if readAt.Valid {
+			m.ReadAt = &readAt.Time
+		}
+		mails = append(mails, m)
+	}
+	return mails, rows.Err()
+}
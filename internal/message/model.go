@@ -10,8 +10,14 @@ type Area struct {
 	ReadLevel   int
 	WriteLevel  int
 	SortOrder   int
-	TotalMsgs   int // computed field
-	NewMsgs     int // computed per-user
+	Conference  int  // conference the area belongs to
+	MaxMessages int  // retention: keep at most this many messages, 0 = unlimited
+	MaxAgeDays  int  // retention: expire messages older than this, 0 = never
+	WebPublic   bool // public messages are shown on the web viewer
+	TotalMsgs   int  // computed field
+	NewMsgs     int  // computed per-user
+	LastRead    int  // computed per-user: the user's last-read pointer
+	LastID      int  // computed: the newest message, set by NewScan
 }
 
 // Message represents a single message in an area.
@@ -27,3 +33,16 @@ type Message struct {
 	ReplyToID  *int
 	CreatedAt  time.Time
 }
+
+// Mail represents a private user-to-user message.
+type Mail struct {
+	ID         int
+	FromUserID int
+	FromName   string // joined from users table
+	ToUserID   int
+	ToName     string // joined from users table
+	Subject    string
+	Body       string
+	ReadAt     *time.Time // nil = unread
+	CreatedAt  time.Time
+}
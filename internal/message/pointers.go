@@ -0,0 +1,106 @@
+package message
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// Every way of reading messages moves the same last-read pointers, one
+// message_read row per user and area, through updatePointers. Pointers
+// only move forward, so readers running side by side (the built-in
+// reader, newscan catch-up, the shell, offline packets) never undo each
+// other's progress. The one way back is SetPointer, which records a reset.
+//
+// Offline packets carry the pointers as they were when the packet was
+// read, which can be long after it was exported. They move a pointer
+// forward like any reader, except in an area the user reset after the
+// export: the reset is newer than anything the packet saw, so it stands.
+
+// Where a pointer change came from, kept in message_read.source.
+const (
+	PointerOnline  = "online"  // read on the board, shell or scripts
+	PointerOffline = "offline" // from an offline reply packet
+	PointerReset   = "reset"   // set explicitly, possibly backwards
+)
+
+// MarkRead moves the user's pointer in an area forward to messageID; it
+// is left alone when it is already past it.
+func (r *Repo) MarkRead(userID, areaID, messageID int) error {
+	_, err := r.updatePointers(userID, PointerOnline, time.Now(), map[int]int{areaID: messageID})
+	return err
+}
+
+// ApplyOfflinePointers moves the user's pointers forward to those of an
+// offline packet exported at exportedAt, in one transaction. Areas reset
+// since the export keep their pointer. It returns how many pointers
+// moved.
+func (r *Repo) ApplyOfflinePointers(userID int, exportedAt time.Time, pointers map[int]int) (int, error) {
+	return r.updatePointers(userID, PointerOffline, exportedAt, pointers)
+}
+
+// SetPointer sets the user's pointer in an area to messageID, backwards
+// too, e.g. to read an area again.
+func (r *Repo) SetPointer(userID, areaID, messageID int) error {
+	_, err := r.db.Exec(`
+		INSERT INTO message_read (user_id, area_id, last_read_id, updated_at, source) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, area_id) DO UPDATE SET
+			last_read_id = excluded.last_read_id, updated_at = excluded.updated_at, source = excluded.source
+	`, userID, areaID, max(messageID, 0), time.Now().UTC().Format(db.SQLiteTime), PointerReset)
+	if err != nil {
+		return fmt.Errorf("set read pointer: %w", err)
+	}
+	return nil
+}
+
+// LastRead returns the user's pointer in an area, 0 when they have read
+// nothing there.
+func (r *Repo) LastRead(userID, areaID int) (int, error) {
+	var id int
+	err := r.db.QueryRow(`
+		SELECT last_read_id FROM message_read WHERE user_id = ? AND area_id = ?
+	`, userID, areaID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get read pointer: %w", err)
+	}
+	return id, nil
+}
+
+// updatePointers moves pointers forward in one transaction, as source
+// saw them at at, and returns how many moved. Offline updates skip areas
+// reset at or after at.
+func (r *Repo) updatePointers(userID int, source string, at time.Time, pointers map[int]int) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("update read pointers: %w", err)
+	}
+	defer tx.Rollback()
+
+	stamp := at.UTC().Format(db.SQLiteTime)
+	moved := 0
+	for areaID, id := range pointers {
+		res, err := tx.Exec(`
+			INSERT INTO message_read (user_id, area_id, last_read_id, updated_at, source) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(user_id, area_id) DO UPDATE SET
+				last_read_id = excluded.last_read_id, updated_at = excluded.updated_at, source = excluded.source
+			WHERE excluded.last_read_id > message_read.last_read_id
+			  AND NOT (excluded.source = 'offline' AND message_read.source = 'reset'
+			           AND message_read.updated_at >= excluded.updated_at)
+		`, userID, areaID, id, stamp, source)
+		if err != nil {
+			return 0, fmt.Errorf("update read pointer in area %d: %w", areaID, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			moved++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("update read pointers: %w", err)
+	}
+	return moved, nil
+}
@@ -0,0 +1,75 @@
+package message
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestReadPointers(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	for _, q := range []string{
+		`DELETE FROM message_areas`,
+		`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')`,
+		`INSERT INTO message_areas (id, name, read_level) VALUES (10, 'General', 10), (11, 'Local', 10), (12, 'Games', 10)`,
+	} {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := NewRepo(database.DB)
+	pointer := func(area int) int {
+		t.Helper()
+		id, err := repo.LastRead(1, area)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	if pointer(10) != 0 {
+		t.Fatal("pointer set before reading")
+	}
+	repo.MarkRead(1, 10, 50)
+	repo.MarkRead(1, 10, 40) // an older message read later
+	if got := pointer(10); got != 50 {
+		t.Fatalf("pointer moved back to %d", got)
+	}
+
+	// A packet exported before online reading moves only what it read
+	// further.
+	exported := time.Now().Add(-time.Hour)
+	repo.MarkRead(1, 11, 30)
+	moved, err := repo.ApplyOfflinePointers(1, exported, map[int]int{10: 45, 11: 35, 12: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 2 || pointer(10) != 50 || pointer(11) != 35 || pointer(12) != 5 {
+		t.Errorf("moved %d; pointers %d, %d, %d", moved, pointer(10), pointer(11), pointer(12))
+	}
+
+	// A reset made after the export stands against the packet; one made
+	// before it does not.
+	if err := repo.SetPointer(1, 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	if pointer(10) != 0 {
+		t.Fatal("reset did not move the pointer back")
+	}
+	if moved, _ := repo.ApplyOfflinePointers(1, exported, map[int]int{10: 60}); moved != 0 || pointer(10) != 0 {
+		t.Errorf("stale packet overrode a reset: pointer %d", pointer(10))
+	}
+	if moved, _ := repo.ApplyOfflinePointers(1, time.Now().Add(time.Hour), map[int]int{10: 60}); moved != 1 || pointer(10) != 60 {
+		t.Errorf("packet exported after the reset: pointer %d", pointer(10))
+	}
+	repo.MarkRead(1, 10, 70)
+	if pointer(10) != 70 {
+		t.Errorf("online reading after a reset: pointer %d", pointer(10))
+	}
+}
@@ -3,11 +3,16 @@ package message
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
 )
 
 // Repo handles database operations for messages and areas.
 type Repo struct {
-	db *sql.DB
+	db    *sql.DB
+	confs []int // area lists cover only these conferences; none = all
 }
 
 // NewRepo creates a new message repository.
@@ -15,15 +20,36 @@ func NewRepo(db *sql.DB) *Repo {
 	return &Repo{db: db}
 }
 
+// InConferences returns a view of the repository whose area lists and new
+// scans cover only the areas in the given conferences; with none it covers
+// them all. Areas and messages are still reachable by ID.
+func (r *Repo) InConferences(ids ...int) *Repo {
+	return &Repo{db: r.db, confs: ids}
+}
+
+// inConferences returns the SQL condition that limits a.conference_id to
+// the view's conferences, with its arguments appended to args.
+func (r *Repo) inConferences(args ...any) (string, []any) {
+	if len(r.confs) == 0 {
+		return "1", args
+	}
+	for _, id := range r.confs {
+		args = append(args, id)
+	}
+	return "a.conference_id IN (?" + strings.Repeat(", ?", len(r.confs)-1) + ")", args
+}
+
 // ListAreas returns all message areas the user has access to read.
 func (r *Repo) ListAreas(userLevel int) ([]*Area, error) {
+	inConfs, args := r.inConferences(userLevel)
 	rows, err := r.db.Query(`
-		SELECT a.id, a.name, a.description, a.read_level, a.write_level, a.sort_order,
+		SELECT a.id, a.name, a.description, a.read_level, a.write_level, a.sort_order, a.conference_id,
+		       COALESCE(a.max_messages, 0), COALESCE(a.max_age_days, 0), COALESCE(a.web_public, 0),
 		       COALESCE((SELECT COUNT(*) FROM messages WHERE area_id = a.id), 0) as total
 		FROM message_areas a
-		WHERE a.read_level <= ?
+		WHERE a.read_level <= ? AND `+inConfs+`
 		ORDER BY a.sort_order, a.name
-	`, userLevel)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list areas: %w", err)
 	}
@@ -33,7 +59,7 @@ func (r *Repo) ListAreas(userLevel int) ([]*Area, error) {
 	for rows.Next() {
 		a := &Area{}
 		if err := rows.Scan(&a.ID, &a.Name, &a.Description, &a.ReadLevel,
-			&a.WriteLevel, &a.SortOrder, &a.TotalMsgs); err != nil {
+			&a.WriteLevel, &a.SortOrder, &a.Conference, &a.MaxMessages, &a.MaxAgeDays, &a.WebPublic, &a.TotalMsgs); err != nil {
 			return nil, err
 		}
 		areas = append(areas, a)
@@ -49,11 +75,7 @@ func (r *Repo) ListAreasWithNew(userID, userLevel int) ([]*Area, error) {
 	}
 
 	for _, a := range areas {
-		var lastRead int
-		r.db.QueryRow(`
-			SELECT COALESCE(last_read_id, 0) FROM message_read
-			WHERE user_id = ? AND area_id = ?
-		`, userID, a.ID).Scan(&lastRead)
+		lastRead, _ := r.LastRead(userID, a.ID)
 
 		var newCount int
 		r.db.QueryRow(`
@@ -66,24 +88,127 @@ func (r *Repo) ListAreasWithNew(userID, userLevel int) ([]*Area, error) {
 	return areas, nil
 }
 
+// NewScan returns the areas the user can read that have messages after
+// their last-read pointer, in menu order, with NewMsgs, LastRead and
+// LastID set. Private messages to other users are not counted. One query
+// covers every area, so a global new-scan costs the same however many
+// areas the board has.
+func (r *Repo) NewScan(userID, userLevel int) ([]*Area, error) {
+	inConfs, args := r.inConferences(userID, userID, userID, userLevel)
+	rows, err := r.db.Query(`
+		SELECT a.id, a.name, a.description, a.read_level, a.write_level, a.sort_order, a.conference_id,
+		       COALESCE(rd.last_read_id, 0), COUNT(m.id), MAX(m.id)
+		FROM message_areas a
+		LEFT JOIN message_read rd ON rd.area_id = a.id AND rd.user_id = ?
+		JOIN messages m ON m.area_id = a.id AND m.id > COALESCE(rd.last_read_id, 0)
+		     AND (m.to_user_id IS NULL OR m.to_user_id = ? OR m.from_user_id = ?)
+		WHERE a.read_level <= ? AND `+inConfs+`
+		GROUP BY a.id
+		ORDER BY a.sort_order, a.name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("new scan: %w", err)
+	}
+	defer rows.Close()
+
+	var areas []*Area
+	for rows.Next() {
+		a := &Area{}
+		if err := rows.Scan(&a.ID, &a.Name, &a.Description, &a.ReadLevel, &a.WriteLevel,
+			&a.SortOrder, &a.Conference, &a.LastRead, &a.NewMsgs, &a.LastID); err != nil {
+			return nil, fmt.Errorf("new scan: %w", err)
+		}
+		areas = append(areas, a)
+	}
+	return areas, rows.Err()
+}
+
 // GetArea returns a single area by ID.
 func (r *Repo) GetArea(id int) (*Area, error) {
 	a := &Area{}
 	err := r.db.QueryRow(`
-		SELECT id, name, description, read_level, write_level, sort_order
+		SELECT id, name, description, read_level, write_level, sort_order, conference_id,
+		       COALESCE(max_messages, 0), COALESCE(max_age_days, 0), COALESCE(web_public, 0)
 		FROM message_areas WHERE id = ?
-	`, id).Scan(&a.ID, &a.Name, &a.Description, &a.ReadLevel, &a.WriteLevel, &a.SortOrder)
+	`, id).Scan(&a.ID, &a.Name, &a.Description, &a.ReadLevel, &a.WriteLevel, &a.SortOrder,
+		&a.Conference, &a.MaxMessages, &a.MaxAgeDays, &a.WebPublic)
 	if err != nil {
 		return nil, fmt.Errorf("get area %d: %w", id, err)
 	}
 	return a, nil
 }
 
+// SetRetention updates an area's retention limits. Zero disables a limit.
+func (r *Repo) SetRetention(areaID, maxMessages, maxAgeDays int) error {
+	if maxMessages < 0 || maxAgeDays < 0 {
+		return fmt.Errorf("set retention for area %d: limits must not be negative", areaID)
+	}
+	result, err := r.db.Exec(`
+		UPDATE message_areas SET max_messages = ?, max_age_days = ? WHERE id = ?
+	`, maxMessages, maxAgeDays, areaID)
+	if err != nil {
+		return fmt.Errorf("set retention for area %d: %w", areaID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("area %d not found", areaID)
+	}
+	return nil
+}
+
+// SetWebPublic marks an area as shown (or hidden) on the web viewer.
+func (r *Repo) SetWebPublic(areaID int, public bool) error {
+	result, err := r.db.Exec(`UPDATE message_areas SET web_public = ? WHERE id = ?`, public, areaID)
+	if err != nil {
+		return fmt.Errorf("set web_public for area %d: %w", areaID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("area %d not found", areaID)
+	}
+	return nil
+}
+
+// ListPublic returns the newest public messages in an area, with bodies,
+// oldest first. Private mail is never included.
+func (r *Repo) ListPublic(areaID, limit int) ([]*Message, error) {
+	rows, err := r.db.Query(`
+		SELECT * FROM (
+			SELECT m.id, m.area_id, m.from_user_id,
+			       COALESCE(NULLIF(m.from_net, ''), uf.username, 'Unknown') as from_name,
+			       m.subject, m.body, m.reply_to_id, m.created_at
+			FROM messages m
+			LEFT JOIN users uf ON uf.id = m.from_user_id
+			WHERE m.area_id = ? AND m.to_user_id IS NULL
+			ORDER BY m.id DESC
+			LIMIT ?
+		) ORDER BY id ASC
+	`, areaID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list public messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		msg := &Message{}
+		var replyToID sql.NullInt64
+		if err := rows.Scan(&msg.ID, &msg.AreaID, &msg.FromUserID, &msg.FromName,
+			&msg.Subject, &msg.Body, &replyToID, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		if replyToID.Valid {
+			id := int(replyToID.Int64)
+			msg.ReplyToID = &id
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
 // ListMessages returns messages in an area, paginated.
 func (r *Repo) ListMessages(areaID, offset, limit int) ([]*Message, error) {
 	rows, err := r.db.Query(`
 		SELECT m.id, m.area_id, m.from_user_id, 
-		       COALESCE(uf.username, 'Unknown') as from_name,
+		       COALESCE(NULLIF(m.from_net, ''), uf.username, 'Unknown') as from_name,
 		       m.to_user_id,
 		       COALESCE(ut.username, '') as to_name,
 		       m.subject, m.created_at
@@ -129,7 +254,7 @@ func (r *Repo) GetMessage(id int) (*Message, error) {
 
 	err := r.db.QueryRow(`
 		SELECT m.id, m.area_id, m.from_user_id,
-		       COALESCE(uf.username, 'Unknown') as from_name,
+		       COALESCE(NULLIF(m.from_net, ''), uf.username, 'Unknown') as from_name,
 		       m.to_user_id,
 		       COALESCE(ut.username, '') as to_name,
 		       m.subject, m.body, m.reply_to_id, m.created_at
@@ -175,23 +300,47 @@ func (r *Repo) Post(areaID, fromUserID int, toUserID *int, subject, body string,
 	return int(id), nil
 }
 
-// MarkRead updates the last-read pointer for a user in an area.
-func (r *Repo) MarkRead(userID, areaID, messageID int) error {
-	_, err := r.db.Exec(`
-		INSERT INTO message_read (user_id, area_id, last_read_id) VALUES (?, ?, ?)
-		ON CONFLICT(user_id, area_id) DO UPDATE SET last_read_id = MAX(last_read_id, excluded.last_read_id)
-	`, userID, areaID, messageID)
-	return err
+// Import stores a message written outside the board, such as a newsgroup
+// article. fromUserID is the local account that owns it; fromName is the
+// author shown to readers instead of that account's name.
+func (r *Repo) Import(areaID, fromUserID int, fromName, subject, body string, replyToID *int, createdAt time.Time) (int, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO messages (area_id, from_user_id, from_net, subject, body, reply_to_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, areaID, fromUserID, fromName, subject, body, replyToID, createdAt.UTC().Format(db.SQLiteTime))
+	if err != nil {
+		return 0, fmt.Errorf("import message: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// MessagesAfter returns the messages in an area after afterID, oldest
+// first, with bodies.
+func (r *Repo) MessagesAfter(areaID, afterID int) ([]*Message, error) {
+	return r.getMessagesAfter(areaID, afterID)
+}
+
+// LastID returns the highest message ID in an area, 0 when it is empty.
+func (r *Repo) LastID(areaID int) (int, error) {
+	var id int
+	err := r.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM messages WHERE area_id = ?`, areaID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("last message id: %w", err)
+	}
+	return id, nil
 }
 
 // GetNewMessages returns unread messages in an area for a user.
 func (r *Repo) GetNewMessages(userID, areaID int) ([]*Message, error) {
-	var lastRead int
-	r.db.QueryRow(`
-		SELECT COALESCE(last_read_id, 0) FROM message_read
-		WHERE user_id = ? AND area_id = ?
-	`, userID, areaID).Scan(&lastRead)
-
+	lastRead, err := r.LastRead(userID, areaID)
+	if err != nil {
+		return nil, err
+	}
 	return r.getMessagesAfter(areaID, lastRead)
 }
 
@@ -199,10 +348,10 @@ func (r *Repo) GetNewMessages(userID, areaID int) ([]*Message, error) {
 func (r *Repo) getMessagesAfter(areaID, afterID int) ([]*Message, error) {
 	rows, err := r.db.Query(`
 		SELECT m.id, m.area_id, m.from_user_id,
-		       COALESCE(uf.username, 'Unknown') as from_name,
+		       COALESCE(NULLIF(m.from_net, ''), uf.username, 'Unknown') as from_name,
 		       m.to_user_id,
 		       COALESCE(ut.username, '') as to_name,
-		       m.subject, m.body, m.created_at
+		       m.subject, m.body, m.reply_to_id, m.created_at
 		FROM messages m
 		LEFT JOIN users uf ON uf.id = m.from_user_id
 		LEFT JOIN users ut ON ut.id = m.to_user_id
@@ -219,10 +368,15 @@ func (r *Repo) getMessagesAfter(areaID, afterID int) ([]*Message, error) {
 		msg := &Message{}
 		var toUserID sql.NullInt64
 		var toName sql.NullString
+		var replyToID sql.NullInt64
 		if err := rows.Scan(&msg.ID, &msg.AreaID, &msg.FromUserID, &msg.FromName,
-			&toUserID, &toName, &msg.Subject, &msg.Body, &msg.CreatedAt); err != nil {
+			&toUserID, &toName, &msg.Subject, &msg.Body, &replyToID, &msg.CreatedAt); err != nil {
 			return nil, err
 		}
+		if replyToID.Valid {
+			id := int(replyToID.Int64)
+			msg.ReplyToID = &id
+		}
 		if toUserID.Valid {
 			id := int(toUserID.Int64)
 			msg.ToUserID = &id
@@ -0,0 +1,61 @@
+package message
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestNewScan(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	for _, q := range []string{
+		`DELETE FROM message_areas`,
+		`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x'), (2, 'bob', 'x'), (3, 'carol', 'x')`,
+		`INSERT INTO message_areas (id, name, read_level, sort_order) VALUES
+			(10, 'General', 10, 2), (11, 'Local', 10, 1), (12, 'Sysop', 100, 3), (13, 'Quiet', 10, 4)`,
+	} {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := NewRepo(database.DB)
+	post := func(area, from int, to *int) int {
+		t.Helper()
+		id, err := repo.Post(area, from, to, "subj", "body", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	carol := 3
+	read := post(10, 2, nil)
+	post(10, 2, nil)
+	last := post(10, 2, nil)
+	post(10, 2, &carol) // private between others: not alice's news
+	post(11, 2, nil)
+	post(12, 2, nil) // above alice's level
+	quiet := post(13, 2, nil)
+	repo.MarkRead(1, 10, read)
+	repo.MarkRead(1, 13, quiet)
+
+	areas, err := repo.NewScan(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(areas) != 2 || areas[0].Name != "Local" || areas[1].Name != "General" {
+		t.Fatalf("areas = %+v", areas)
+	}
+	if g := areas[1]; g.NewMsgs != 2 || g.LastRead != read || g.LastID != last {
+		t.Errorf("General = %+v", g)
+	}
+
+	repo.MarkRead(1, 10, last)
+	if areas, _ = repo.NewScan(1, 10); len(areas) != 1 || areas[0].Name != "Local" {
+		t.Errorf("after catching up, areas = %+v", areas)
+	}
+}
@@ -0,0 +1,204 @@
+package message
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+// PurgeOptions controls a retention run.
+type PurgeOptions struct {
+	Archive bool      // copy messages to message_archive before deleting
+	DryRun  bool      // report what would be removed without changing anything
+	Now     time.Time // reference time for max_age_days, zero = time.Now()
+}
+
+// AreaPurge lists the messages removed from one area.
+type AreaPurge struct {
+	AreaID   int
+	AreaName string
+	Expired  int   // older than max_age_days
+	Excess   int   // beyond max_messages (and not already expired)
+	IDs      []int // oldest first
+}
+
+// PurgeReport is the result of a retention run.
+type PurgeReport struct {
+	Areas    []AreaPurge // only areas with something to remove
+	Total    int
+	Archived bool
+	DryRun   bool
+}
+
+// Lines formats the report for logs and the admin console.
+func (p *PurgeReport) Lines() []string {
+	verb := "removed"
+	switch {
+	case p.DryRun:
+		verb = "would remove"
+	case p.Archived:
+		verb = "archived"
+	}
+	var lines []string
+	for _, a := range p.Areas {
+		lines = append(lines, fmt.Sprintf("%s: %s %d message(s) (%d expired, %d over limit)",
+			a.AreaName, verb, len(a.IDs), a.Expired, a.Excess))
+	}
+	lines = append(lines, fmt.Sprintf("total: %s %d message(s)", verb, p.Total))
+	return lines
+}
+
+// PurgeExpired applies each area's max_messages and max_age_days limits.
+//
+// Replies to a removed message are re-attached to that message's own parent
+// (or become thread roots), so the surviving part of a thread stays linked.
+// All changes happen in one transaction.
+func (r *Repo) PurgeExpired(opts PurgeOptions) (*PurgeReport, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	report := &PurgeReport{Archived: opts.Archive && !opts.DryRun, DryRun: opts.DryRun}
+
+	areas, err := r.retentionAreas()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range areas {
+		ap, err := r.expiredInArea(a, now)
+		if err != nil {
+			return nil, err
+		}
+		if len(ap.IDs) > 0 {
+			report.Areas = append(report.Areas, ap)
+			report.Total += len(ap.IDs)
+		}
+	}
+	if opts.DryRun || report.Total == 0 {
+		return report, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("purge messages: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, ap := range report.Areas {
+		for _, id := range ap.IDs {
+			if err := removeMessage(tx, id, opts.Archive); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("purge messages: %w", err)
+	}
+	return report, nil
+}
+
+// retentionAreas returns the areas that have at least one limit set.
+func (r *Repo) retentionAreas() ([]*Area, error) {
+	rows, err := r.db.Query(`
+		SELECT id, name, COALESCE(max_messages, 0), COALESCE(max_age_days, 0)
+		FROM message_areas
+		WHERE COALESCE(max_messages, 0) > 0 OR COALESCE(max_age_days, 0) > 0
+		ORDER BY sort_order, name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list retention areas: %w", err)
+	}
+	defer rows.Close()
+
+	var areas []*Area
+	for rows.Next() {
+		a := &Area{}
+		if err := rows.Scan(&a.ID, &a.Name, &a.MaxMessages, &a.MaxAgeDays); err != nil {
+			return nil, err
+		}
+		areas = append(areas, a)
+	}
+	return areas, rows.Err()
+}
+
+func (r *Repo) expiredInArea(a *Area, now time.Time) (AreaPurge, error) {
+	ap := AreaPurge{AreaID: a.ID, AreaName: a.Name}
+	selected := make(map[int]bool)
+
+	if a.MaxAgeDays > 0 {
+		cutoff := now.UTC().AddDate(0, 0, -a.MaxAgeDays).Format(db.SQLiteTime)
+		ids, err := r.queryIDs(`SELECT id FROM messages WHERE area_id = ? AND created_at < ?`, a.ID, cutoff)
+		if err != nil {
+			return ap, fmt.Errorf("find expired messages in area %d: %w", a.ID, err)
+		}
+		for _, id := range ids {
+			selected[id] = true
+		}
+		ap.Expired = len(ids)
+	}
+	if a.MaxMessages > 0 {
+		ids, err := r.queryIDs(`SELECT id FROM messages WHERE area_id = ? ORDER BY id DESC LIMIT -1 OFFSET ?`,
+			a.ID, a.MaxMessages)
+		if err != nil {
+			return ap, fmt.Errorf("find excess messages in area %d: %w", a.ID, err)
+		}
+		for _, id := range ids {
+			if !selected[id] {
+				selected[id] = true
+				ap.Excess++
+			}
+		}
+	}
+
+	for id := range selected {
+		ap.IDs = append(ap.IDs, id)
+	}
+	sort.Ints(ap.IDs)
+	return ap, nil
+}
+
+func (r *Repo) queryIDs(query string, args ...interface{}) ([]int, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// removeMessage re-parents replies, optionally archives, then deletes a message.
+func removeMessage(tx *sql.Tx, id int, archive bool) error {
+	var parent sql.NullInt64
+	if err := tx.QueryRow(`SELECT reply_to_id FROM messages WHERE id = ?`, id).Scan(&parent); err != nil {
+		return fmt.Errorf("purge message %d: %w", id, err)
+	}
+	if _, err := tx.Exec(`UPDATE messages SET reply_to_id = ? WHERE reply_to_id = ?`, parent, id); err != nil {
+		return fmt.Errorf("re-link replies to message %d: %w", id, err)
+	}
+	if archive {
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO message_archive
+				(id, area_id, from_user_id, from_net, to_user_id, subject, body, reply_to_id, created_at)
+			SELECT id, area_id, from_user_id, from_net, to_user_id, subject, body, reply_to_id, created_at
+			FROM messages WHERE id = ?
+		`, id); err != nil {
+			return fmt.Errorf("archive message %d: %w", id, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete message %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package message
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestPurgeExpiredKeepsReplyChains(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'sysop', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepo(database.DB)
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	post := func(age time.Duration, replyTo *int) int {
+		t.Helper()
+		id, err := repo.Post(1, 1, nil, "subj", "body", replyTo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.Exec(`UPDATE messages SET created_at = ? WHERE id = ?`,
+			now.Add(-age).Format(db.SQLiteTime), id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	day := 24 * time.Hour
+	root := post(40*day, nil)
+	reply := post(35*day, &root)
+	answer := post(2*day, &reply)
+	post(1*day, nil)
+	post(0, nil)
+
+	if err := repo.SetRetention(1, 2, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := repo.PurgeExpired(PurgeOptions{DryRun: true, Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 3 || len(report.Areas) != 1 || report.Areas[0].Expired != 2 || report.Areas[0].Excess != 1 {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	if n := repo.CountMessages(1); n != 5 {
+		t.Fatalf("dry run removed messages: %d left", n)
+	}
+
+	if _, err := repo.PurgeExpired(PurgeOptions{Archive: true, Now: now}); err != nil {
+		t.Fatal(err)
+	}
+	if n := repo.CountMessages(1); n != 2 {
+		t.Fatalf("messages left = %d, want 2", n)
+	}
+	var archived int
+	database.QueryRow(`SELECT COUNT(*) FROM message_archive`).Scan(&archived)
+	if archived != 3 {
+		t.Fatalf("archived = %d, want 3", archived)
+	}
+	if _, err := repo.GetMessage(answer); err == nil {
+		t.Fatalf("message %d over the limit was kept", answer)
+	}
+}
+
+func TestPurgeExpiredRelinksReplies(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'sysop', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepo(database.DB)
+
+	root, _ := repo.Post(1, 1, nil, "root", "body", nil)
+	reply, _ := repo.Post(1, 1, nil, "re", "body", &root)
+	if _, err := database.Exec(`UPDATE messages SET created_at = '2000-01-01 00:00:00' WHERE id = ?`, root); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetRetention(1, 0, 30); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.PurgeExpired(PurgeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := repo.GetMessage(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ReplyToID != nil {
+		t.Fatalf("reply still points at purged message %d", *m.ReplyToID)
+	}
+}
@@ -0,0 +1,362 @@
+// Package msgreader is the built-in message reader: it shows one message
+// at a time from an area, pages long bodies and moves between messages,
+// so menu scripts do not have to reimplement reading by hand.
+package msgreader
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// maxMessages caps the messages loaded for one reading session.
+const maxMessages = 5000
+
+// Actions reported in Result.
+const (
+	ActionQuit  = "quit"
+	ActionReply = "reply"
+)
+
+// Config configures a reading session.
+type Config struct {
+	Term     *terminal.Terminal
+	Messages *message.Repo
+	UserID   int
+	AreaID   int
+
+	// StartID is the message to open first. Zero starts at the first
+	// unread message, or the first message when all have been read.
+	StartID int
+
+	// Template is an optional display file (message_read) with FROM, TO,
+	// SUBJECT, DATE, BODY and STATUS placeholders; AREA and NUMBER are
+	// filled in when present. Without it, or without ANSI, the message is
+	// printed sequentially.
+	Template *ansi.DisplayFile
+
+	// Reply is called when the user presses R. The message is shown again
+	// afterwards. When nil, R ends the session with ActionReply.
+	Reply func(m *message.Message) error
+}
+
+// Result is how a reading session ended.
+type Result struct {
+	Action  string
+	Message *message.Message // last message shown, nil if the area was empty
+}
+
+// Key commands.
+const (
+	cmdNone = iota
+	cmdNext
+	cmdPrev
+	cmdReply
+	cmdAgain
+	cmdQuit
+)
+
+// model holds the reader position independently of the terminal.
+type model struct {
+	msgs   []*message.Message
+	index  int
+	page   int
+	pages  int // pages of the current message body
+	status string
+}
+
+// handle applies a command. Next pages through the body before moving on.
+func (m *model) handle(cmd int) {
+	m.status = ""
+	switch cmd {
+	case cmdNext:
+		switch {
+		case m.page < m.pages-1:
+			m.page++
+		case m.index < len(m.msgs)-1:
+			m.index++
+			m.page = 0
+		default:
+			m.status = "No more messages."
+		}
+	case cmdPrev:
+		if m.index > 0 {
+			m.index--
+			m.page = 0
+		} else {
+			m.status = "This is the first message."
+		}
+	case cmdAgain:
+		m.page = 0
+	}
+}
+
+func command(b byte) int {
+	switch b {
+	case 'n', 'N', ' ', '\r', '\n':
+		return cmdNext
+	case 'p', 'P':
+		return cmdPrev
+	case 'r', 'R':
+		return cmdReply
+	case 'a', 'A':
+		return cmdAgain
+	case 'q', 'Q', 0x1b, 3:
+		return cmdQuit
+	}
+	return cmdNone
+}
+
+// visible reports whether the user may see m: public, or to or from them.
+func visible(m *message.Message, userID int) bool {
+	return m.ToUserID == nil || *m.ToUserID == userID || m.FromUserID == userID
+}
+
+// Run reads the area until the user quits. Each message shown is marked
+// read.
+func Run(cfg Config) (Result, error) {
+	headers, err := cfg.Messages.ListMessages(cfg.AreaID, 0, maxMessages)
+	if err != nil {
+		return Result{Action: ActionQuit}, err
+	}
+	m := &model{}
+	for _, h := range headers {
+		if visible(h, cfg.UserID) {
+			m.msgs = append(m.msgs, h)
+		}
+	}
+	if len(m.msgs) == 0 {
+		cfg.Term.SendLn("\r\n  No messages in this area.")
+		return Result{Action: ActionQuit}, nil
+	}
+	m.index = startIndex(cfg, m.msgs)
+
+	ui := newView(cfg.Term, cfg.Template)
+	if a, err := cfg.Messages.GetArea(cfg.AreaID); err == nil {
+		ui.area = a.Name
+	}
+	shownID := 0
+	var cur *message.Message
+	for {
+		if h := m.msgs[m.index]; cur == nil || cur.ID != h.ID {
+			full, err := cfg.Messages.GetMessage(h.ID)
+			if err != nil {
+				return Result{Action: ActionQuit, Message: cur}, err
+			}
+			cur = full
+			m.pages = len(ui.paginate(cur.Body))
+			cfg.Messages.MarkRead(cfg.UserID, cur.AreaID, cur.ID)
+		}
+		if err := ui.show(cur, m, shownID != cur.ID); err != nil {
+			return Result{Action: ActionQuit, Message: cur}, err
+		}
+		shownID = cur.ID
+
+		b, err := cfg.Term.GetKey()
+		if err != nil {
+			return Result{Action: ActionQuit, Message: cur}, err
+		}
+		switch cmd := command(b); cmd {
+		case cmdQuit:
+			ui.finish()
+			return Result{Action: ActionQuit, Message: cur}, nil
+		case cmdReply:
+			if cfg.Reply == nil {
+				ui.finish()
+				return Result{Action: ActionReply, Message: cur}, nil
+			}
+			if err := cfg.Reply(cur); err != nil {
+				return Result{Action: ActionQuit, Message: cur}, err
+			}
+			shownID = 0 // redraw everything
+		case cmdAgain:
+			m.handle(cmd)
+			shownID = 0
+		default:
+			m.handle(cmd)
+		}
+	}
+}
+
+// startIndex finds the message to open first.
+func startIndex(cfg Config, msgs []*message.Message) int {
+	if cfg.StartID > 0 {
+		for i, msg := range msgs {
+			if msg.ID == cfg.StartID {
+				return i
+			}
+		}
+	}
+	unread, err := cfg.Messages.GetNewMessages(cfg.UserID, cfg.AreaID)
+	if err != nil {
+		return 0
+	}
+	for _, u := range unread {
+		for i, msg := range msgs {
+			if msg.ID == u.ID {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// view draws messages either into a template or sequentially.
+type view struct {
+	term   *terminal.Terminal
+	tmpl   *ansi.DisplayFile
+	fields map[string]ansi.Field
+	area   string
+}
+
+func newView(term *terminal.Terminal, tmpl *ansi.DisplayFile) *view {
+	v := &view{term: term}
+	if tmpl == nil || !term.ANSIEnabled {
+		return v
+	}
+	width := term.Width
+	if tmpl.Sauce != nil && tmpl.Sauce.TInfo1 > 0 {
+		width = int(tmpl.Sauce.TInfo1)
+	}
+	if width <= 0 {
+		width = 80
+	}
+	fields := ansi.IndexFields(tmpl, width)
+	if f, ok := fields["BODY"]; ok && f.MaxLen > 0 && f.Height > 0 {
+		v.tmpl, v.fields = tmpl, fields
+	}
+	return v
+}
+
+// bodySize is the width and height available for the body.
+func (v *view) bodySize() (int, int) {
+	if v.tmpl != nil {
+		f := v.fields["BODY"]
+		return f.MaxLen, f.Height
+	}
+	return max(v.term.Width-3, 20), max(v.term.Height-8, 5)
+}
+
+// paginate wraps body to the body width and splits it into pages.
+func (v *view) paginate(body string) [][]string {
+	width, height := v.bodySize()
+	lines := wrap(body, width)
+	var pages [][]string
+	for len(lines) > height {
+		pages = append(pages, lines[:height])
+		lines = lines[height:]
+	}
+	return append(pages, lines)
+}
+
+func (v *view) prompt(m *model) string {
+	s := fmt.Sprintf("Msg %d/%d", m.index+1, len(m.msgs))
+	if m.pages > 1 {
+		s += fmt.Sprintf(" Page %d/%d", m.page+1, m.pages)
+	}
+	s += "  [N]ext [P]rev [R]eply [A]gain [Q]uit"
+	if m.status != "" {
+		s = m.status + "  " + s
+	}
+	return s
+}
+
+// show draws the current page. full redraws the header as well.
+func (v *view) show(msg *message.Message, m *model, full bool) error {
+	page := v.paginate(msg.Body)[m.page]
+	to := msg.ToName
+	if to == "" {
+		to = "All"
+	}
+
+	if v.tmpl == nil {
+		v.term.Cls()
+		v.term.SendLn(fmt.Sprintf("  From:    %s", msg.FromName))
+		v.term.SendLn(fmt.Sprintf("  To:      %s", to))
+		v.term.SendLn(fmt.Sprintf("  Subject: %s", msg.Subject))
+		v.term.SendLn(fmt.Sprintf("  Date:    %s", msg.CreatedAt.Format("2006-01-02 15:04")))
+		v.term.SendLn("  " + strings.Repeat("-", 50))
+		for _, line := range page {
+			v.term.SendLn("  " + line)
+		}
+		v.term.SendLn("")
+		return v.term.Send("  " + v.prompt(m) + " ")
+	}
+
+	if full {
+		v.term.Cls()
+		if err := ansi.Display(v.term, v.tmpl); err != nil {
+			return err
+		}
+		v.field("AREA", v.area)
+		v.field("FROM", msg.FromName)
+		v.field("TO", to)
+		v.field("SUBJECT", msg.Subject)
+		v.field("DATE", msg.CreatedAt.Format("2006-01-02 15:04"))
+		v.field("NUMBER", fmt.Sprintf("%d of %d", m.index+1, len(m.msgs)))
+	}
+	v.field("BODY", strings.Join(page, "\n"))
+	v.field("STATUS", v.prompt(m))
+	return nil
+}
+
+// field writes text into a placeholder rectangle, clipped to its size.
+func (v *view) field(id, text string) {
+	f, ok := v.fields[id]
+	if !ok || f.Row <= 0 || f.Col <= 0 {
+		return
+	}
+	width, height := max(f.MaxLen, 1), max(f.Height, 1)
+	lines := strings.Split(text, "\n")
+	for row := 0; row < height; row++ {
+		line := ""
+		if row < len(lines) {
+			line = lines[row]
+		}
+		r := []rune(line)
+		if len(r) > width {
+			r = r[:width]
+		}
+		v.term.GotoXY(f.Row+row, f.Col)
+		v.term.Send(string(r) + strings.Repeat(" ", width-len(r)))
+	}
+	if id == "STATUS" {
+		v.term.GotoXY(f.Row, f.Col+min(len([]rune(text)), width-1))
+	}
+}
+
+// finish leaves the cursor below the message.
+func (v *view) finish() {
+	if v.tmpl != nil {
+		if f, ok := v.fields["STATUS"]; ok {
+			v.term.GotoXY(f.Row+1, 1)
+		}
+	}
+	v.term.SendLn("")
+}
+
+// wrap splits text into lines of at most width runes, breaking at spaces
+// where possible.
+func wrap(text string, width int) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var out []string
+	for _, para := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		r := []rune(strings.TrimRight(para, "\r"))
+		for len(r) > width {
+			cut := width
+			for i := width; i > width/2; i-- {
+				if r[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			out = append(out, strings.TrimRight(string(r[:cut]), " "))
+			r = []rune(strings.TrimLeft(string(r[cut:]), " "))
+		}
+		out = append(out, string(r))
+	}
+	return out
+}
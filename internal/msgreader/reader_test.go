@@ -0,0 +1,47 @@
+package msgreader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+func TestModelNavigation(t *testing.T) {
+	m := &model{msgs: []*message.Message{{ID: 1}, {ID: 2}}, pages: 2}
+
+	m.handle(cmdPrev)
+	if m.index != 0 || m.status == "" {
+		t.Fatalf("prev at start: index %d status %q", m.index, m.status)
+	}
+	m.handle(cmdNext)
+	if m.index != 0 || m.page != 1 {
+		t.Fatalf("next should page first: index %d page %d", m.index, m.page)
+	}
+	m.handle(cmdNext)
+	if m.index != 1 || m.page != 0 {
+		t.Fatalf("next after last page: index %d page %d", m.index, m.page)
+	}
+	m.pages = 1
+	m.handle(cmdNext)
+	if m.index != 1 || m.status != "No more messages." {
+		t.Fatalf("next at end: index %d status %q", m.index, m.status)
+	}
+	m.handle(cmdPrev)
+	if m.index != 0 || m.status != "" {
+		t.Fatalf("prev: index %d status %q", m.index, m.status)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	got := wrap("the quick brown fox jumps\r\n\r\nover", 10)
+	want := []string{"the quick", "brown fox", "jumps", "", "over"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("wrap = %q", got)
+	}
+	for _, line := range wrap(strings.Repeat("x", 25), 10) {
+		if len(line) > 10 {
+			t.Fatalf("long word not split: %q", line)
+		}
+	}
+}
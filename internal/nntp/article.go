@@ -0,0 +1,196 @@
+package nntp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// Limits on what is imported, in runes.
+const (
+	maxAuthor  = 60
+	maxSubject = 120
+)
+
+// Article is a newsgroup article as stored on the board.
+type Article struct {
+	MessageID  string // with angle brackets
+	From       string // author's name, or address when the name is empty
+	Subject    string
+	Date       time.Time
+	References []string // oldest first
+	Body       string   // UTF-8, "\n" line endings
+}
+
+// Parent returns the Message-ID the article replies to, or "".
+func (a *Article) Parent() string {
+	if len(a.References) == 0 {
+		return ""
+	}
+	return a.References[len(a.References)-1]
+}
+
+var msgIDRe = regexp.MustCompile(`<[^<>\s]+>`)
+
+var headerDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, r io.Reader) (io.Reader, error) {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(terminal.DecodeText(b, charset)), nil
+	},
+}
+
+// ParseArticle parses a raw article as returned by Client.Article.
+func ParseArticle(raw []byte) (*Article, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse article: %w", err)
+	}
+	h := m.Header
+	a := &Article{MessageID: msgIDRe.FindString(h.Get("Message-ID"))}
+	if a.MessageID == "" {
+		return nil, fmt.Errorf("parse article: no Message-ID")
+	}
+
+	a.From = author(h.Get("From"))
+	a.Subject = h.Get("Subject")
+	if s, err := headerDecoder.DecodeHeader(a.Subject); err == nil {
+		a.Subject = s
+	}
+	a.Subject = clip(strings.Join(strings.Fields(a.Subject), " "), maxSubject)
+	if a.Subject == "" {
+		a.Subject = "(no subject)"
+	}
+	if a.Date, err = h.Date(); err != nil {
+		a.Date = time.Now()
+	}
+	a.References = msgIDRe.FindAllString(h.Get("References"), -1)
+	if len(a.References) == 0 {
+		a.References = msgIDRe.FindAllString(h.Get("In-Reply-To"), -1)
+	}
+
+	body, err := io.ReadAll(m.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parse article: %w", err)
+	}
+	charset := ""
+	if _, params, err := mime.ParseMediaType(h.Get("Content-Type")); err == nil {
+		charset = params["charset"]
+	}
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "quoted-printable":
+		if b, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body))); err == nil {
+			body = b
+		}
+	case "base64":
+		if b, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(body))); err == nil {
+			body = b
+		}
+	}
+	text := strings.ReplaceAll(terminal.DecodeText(body, charset), "\r\n", "\n")
+	a.Body = strings.TrimRight(text, "\n")
+	return a, nil
+}
+
+// author maps a From header to the name shown on the board: the display
+// name when there is one, else the address.
+func author(from string) string {
+	name := strings.TrimSpace(from)
+	if addr, err := mail.ParseAddress(from); err == nil {
+		name = addr.Name
+		if name == "" {
+			name = addr.Address
+		}
+	} else if s, err := headerDecoder.DecodeHeader(from); err == nil {
+		name = s
+	}
+	name = strings.Trim(strings.Join(strings.Fields(name), " "), `"`)
+	if name == "" {
+		name = "Unknown"
+	}
+	return clip(name, maxAuthor)
+}
+
+func clip(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// Outgoing is a local message to post.
+type Outgoing struct {
+	MessageID    string
+	Newsgroup    string
+	FromName     string
+	FromAddr     string
+	Organization string
+	Subject      string
+	Date         time.Time
+	References   []string
+	Body         string
+}
+
+// Format renders the article for Client.Post.
+func (o *Outgoing) Format() []byte {
+	var b bytes.Buffer
+	from := mail.Address{Name: o.FromName, Address: o.FromAddr}
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "Newsgroups: %s\r\n", o.Newsgroup)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", oneLine(o.Subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", o.Date.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: %s\r\n", o.MessageID)
+	if len(o.References) > 0 {
+		fmt.Fprintf(&b, "References: %s\r\n", strings.Join(o.References, " "))
+	}
+	if o.Organization != "" {
+		fmt.Fprintf(&b, "Organization: %s\r\n", mime.QEncoding.Encode("utf-8", oneLine(o.Organization)))
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	body := strings.ReplaceAll(o.Body, "\r\n", "\n")
+	for _, line := range strings.Split(body, "\n") {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// localPart turns a user name into the local part of a poster address.
+func localPart(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('.')
+		}
+	}
+	s := strings.Trim(b.String(), ".")
+	for strings.Contains(s, "..") {
+		s = strings.ReplaceAll(s, "..", ".")
+	}
+	if s == "" {
+		s = "user"
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package nntp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoArticle is returned for article numbers the server does not have,
+// e.g. cancelled or expired articles.
+var ErrNoArticle = errors.New("no such article")
+
+// Client is a minimal NNTP reader and poster (RFC 3977).
+type Client struct {
+	conn    *textproto.Conn
+	netConn net.Conn
+	timeout time.Duration
+	posting bool // the server allows posting
+}
+
+// Dial connects to addr ("host:port"), over TLS when useTLS is set, and
+// logs in when username is not empty.
+func Dial(addr string, useTLS bool, username, password string, timeout time.Duration) (*Client, error) {
+	d := &net.Dialer{Timeout: timeout}
+	var nc net.Conn
+	var err error
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		nc, err = tls.DialWithDialer(d, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		nc, err = d.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	c := &Client{conn: textproto.NewConn(nc), netConn: nc, timeout: timeout}
+	c.deadline()
+
+	code, _, err := c.conn.ReadCodeLine(20)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	c.posting = code == 200
+
+	if username != "" {
+		if err := c.auth(username, password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	// Servers that are also transit peers only serve readers after
+	// MODE READER. Servers that do not know it are already in reader mode.
+	if code, _, err := c.cmd(0, "MODE READER"); err == nil {
+		c.posting = code == 200
+	}
+	return c, nil
+}
+
+func (c *Client) deadline() {
+	if c.timeout > 0 {
+		c.netConn.SetDeadline(time.Now().Add(c.timeout))
+	}
+}
+
+// cmd sends a command and reads the response line, which must have the
+// code expectCode (0 for any 2xx).
+func (c *Client) cmd(expectCode int, format string, args ...any) (int, string, error) {
+	c.deadline()
+	if _, err := c.conn.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	want := expectCode
+	if want == 0 {
+		want = 2
+	}
+	return c.conn.ReadCodeLine(want)
+}
+
+func (c *Client) auth(username, password string) error {
+	code, msg, err := c.cmd(0, "AUTHINFO USER %s", username)
+	if code == 381 {
+		code, msg, err = c.cmd(281, "AUTHINFO PASS %s", password)
+	}
+	if err != nil {
+		return fmt.Errorf("login as %s: %d %s", username, code, msg)
+	}
+	return nil
+}
+
+// Group selects a newsgroup and returns its lowest and highest article
+// numbers. An empty group has high < low.
+func (c *Client) Group(name string) (low, high int, err error) {
+	_, msg, err := c.cmd(211, "GROUP %s", name)
+	if err != nil {
+		return 0, 0, fmt.Errorf("group %s: %w", name, err)
+	}
+	// 211 count low high name
+	f := strings.Fields(msg)
+	if len(f) < 3 {
+		return 0, 0, fmt.Errorf("group %s: bad response %q", name, msg)
+	}
+	low, err1 := strconv.Atoi(f[1])
+	high, err2 := strconv.Atoi(f[2])
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("group %s: bad response %q", name, msg)
+	}
+	return low, high, nil
+}
+
+// Article returns article number n of the current group, headers and
+// body, with line endings as "\n".
+func (c *Client) Article(n int) ([]byte, error) {
+	code, msg, err := c.cmd(220, "ARTICLE %d", n)
+	if code == 423 || code == 430 {
+		return nil, ErrNoArticle
+	}
+	if err != nil {
+		return nil, fmt.Errorf("article %d: %d %s", n, code, msg)
+	}
+	c.deadline()
+	data, err := io.ReadAll(c.conn.DotReader())
+	if err != nil {
+		return nil, fmt.Errorf("article %d: %w", n, err)
+	}
+	return data, nil
+}
+
+// Post sends an article, headers and body separated by an empty line.
+func (c *Client) Post(article []byte) error {
+	if !c.posting {
+		return errors.New("post: server does not allow posting")
+	}
+	code, msg, err := c.cmd(340, "POST")
+	if err != nil {
+		return fmt.Errorf("post: %d %s", code, msg)
+	}
+	c.deadline()
+	w := c.conn.DotWriter()
+	if _, err := w.Write(article); err != nil {
+		w.Close()
+		return fmt.Errorf("post: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+	code, msg, err = c.conn.ReadCodeLine(240)
+	if err != nil {
+		return fmt.Errorf("post: %d %s", code, msg)
+	}
+	return nil
+}
+
+// Close says goodbye and closes the connection.
+func (c *Client) Close() error {
+	c.cmd(205, "QUIT")
+	return c.conn.Close()
+}
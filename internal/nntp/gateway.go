@@ -0,0 +1,315 @@
+// Package nntp links message areas to Usenet newsgroups: new articles on
+// a news server are fetched into their areas and public messages written
+// on the board are posted to the groups. Message-IDs are tracked so no
+// article is imported twice or echoed back.
+package nntp
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+// Group maps a message area to a newsgroup.
+type Group struct {
+	AreaID    int
+	Newsgroup string
+	Post      bool // also post the area's local messages to the group
+}
+
+// Config is the gateway's server and groups.
+type Config struct {
+	Server       string // "host:port"
+	TLS          bool
+	Username     string
+	Password     string
+	Domain       string // for Message-IDs and poster addresses
+	Organization string
+	UserID       int // local account that owns imported articles
+	MaxArticles  int // articles fetched per group and sync
+	Backfill     int // articles fetched on a group's first sync
+	Timeout      time.Duration
+	Groups       []Group
+}
+
+// GroupReport is what one sync did for a group.
+type GroupReport struct {
+	Newsgroup  string
+	Imported   int
+	Duplicates int // already on the board, including our own posts
+	Posted     int
+	Err        error
+}
+
+// Report is the result of a sync.
+type Report struct {
+	Groups []GroupReport
+}
+
+// Lines formats the report for logs and bbsctl.
+func (r *Report) Lines() []string {
+	var lines []string
+	for _, g := range r.Groups {
+		line := fmt.Sprintf("%s: imported %d, posted %d, %d duplicate(s)", g.Newsgroup, g.Imported, g.Posted, g.Duplicates)
+		if g.Err != nil {
+			line += ", error: " + g.Err.Error()
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Gateway syncs message areas with newsgroups.
+type Gateway struct {
+	mu   sync.Mutex // one sync at a time
+	db   *sql.DB
+	msgs *message.Repo
+	cfg  Config
+
+	Events *event.Bus // receives event.Post for articles imported
+
+	// dial connects to the server; replaced in tests.
+	dial func() (*Client, error)
+}
+
+// NewGateway creates a gateway for cfg.
+func NewGateway(db *sql.DB, msgs *message.Repo, cfg Config) *Gateway {
+	if cfg.MaxArticles <= 0 {
+		cfg.MaxArticles = 200
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	g := &Gateway{db: db, msgs: msgs, cfg: cfg}
+	g.dial = func() (*Client, error) {
+		return Dial(cfg.Server, cfg.TLS, cfg.Username, cfg.Password, cfg.Timeout)
+	}
+	return g
+}
+
+// Sync fetches new articles for every group and posts new local messages.
+// Errors in one group are reported and do not stop the others; the error
+// returned is for failing to reach the server at all.
+func (g *Gateway) Sync() (*Report, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, err := g.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	report := &Report{}
+	for _, grp := range g.cfg.Groups {
+		gr := GroupReport{Newsgroup: grp.Newsgroup}
+		gr.Err = g.syncGroup(c, grp, &gr)
+		if gr.Err != nil {
+			log.Printf("NNTP: %s: %v", grp.Newsgroup, gr.Err)
+		}
+		report.Groups = append(report.Groups, gr)
+	}
+	return report, nil
+}
+
+func (g *Gateway) syncGroup(c *Client, grp Group, gr *GroupReport) error {
+	st, err := g.state(grp)
+	if err != nil {
+		return err
+	}
+	if err := g.fetch(c, grp, st, gr); err != nil {
+		return err
+	}
+	if grp.Post {
+		return g.post(c, grp, st, gr)
+	}
+	return nil
+}
+
+// groupState is a group's row in nntp_groups.
+type groupState struct {
+	lastArticle  int // highest article number fetched
+	lastExported int // highest local message ID considered for posting
+}
+
+// state loads a group's progress. A group seen for the first time starts
+// after the area's current messages, so the back catalogue is not posted.
+func (g *Gateway) state(grp Group) (*groupState, error) {
+	st := &groupState{}
+	err := g.db.QueryRow(`
+		SELECT last_article, last_exported FROM nntp_groups WHERE area_id = ? AND newsgroup = ?
+	`, grp.AreaID, grp.Newsgroup).Scan(&st.lastArticle, &st.lastExported)
+	if errors.Is(err, sql.ErrNoRows) {
+		if st.lastExported, err = g.msgs.LastID(grp.AreaID); err != nil {
+			return nil, err
+		}
+		_, err = g.db.Exec(`
+			INSERT INTO nntp_groups (area_id, newsgroup, last_article, last_exported) VALUES (?, ?, 0, ?)
+		`, grp.AreaID, grp.Newsgroup, st.lastExported)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load group state: %w", err)
+	}
+	return st, nil
+}
+
+func (g *Gateway) saveState(grp Group, st *groupState) error {
+	_, err := g.db.Exec(`
+		UPDATE nntp_groups SET last_article = ?, last_exported = ? WHERE area_id = ? AND newsgroup = ?
+	`, st.lastArticle, st.lastExported, grp.AreaID, grp.Newsgroup)
+	if err != nil {
+		return fmt.Errorf("save group state: %w", err)
+	}
+	return nil
+}
+
+// fetch imports the group's new articles.
+func (g *Gateway) fetch(c *Client, grp Group, st *groupState, gr *GroupReport) error {
+	low, high, err := c.Group(grp.Newsgroup)
+	if err != nil {
+		return err
+	}
+	if high < low {
+		return nil // empty group
+	}
+	first := st.lastArticle + 1
+	if st.lastArticle == 0 || st.lastArticle > high {
+		// First sync, or the server was renumbered: start near the end.
+		first = high - g.cfg.Backfill + 1
+	}
+	first = max(first, low)
+	last := min(high, first+g.cfg.MaxArticles-1)
+	if first > last {
+		st.lastArticle = high
+		return g.saveState(grp, st)
+	}
+
+	for n := first; n <= last; n++ {
+		raw, err := c.Article(n)
+		if errors.Is(err, ErrNoArticle) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		a, err := ParseArticle(raw)
+		if err != nil {
+			log.Printf("NNTP: %s article %d: %v", grp.Newsgroup, n, err)
+			continue
+		}
+		imported, err := g.importArticle(grp, a)
+		if err != nil {
+			return err
+		}
+		if imported {
+			gr.Imported++
+		} else {
+			gr.Duplicates++
+		}
+		st.lastArticle = n
+		if err := g.saveState(grp, st); err != nil {
+			return err
+		}
+	}
+	st.lastArticle = last
+	return g.saveState(grp, st)
+}
+
+// importArticle stores a into the group's area unless its Message-ID is
+// already known. Crossposts land in the first area that fetches them.
+func (g *Gateway) importArticle(grp Group, a *Article) (bool, error) {
+	if known, err := g.known(a.MessageID); err != nil || known {
+		return false, err
+	}
+	var replyTo *int
+	if parent := a.Parent(); parent != "" {
+		var id sql.NullInt64
+		g.db.QueryRow(`
+			SELECT msg_id FROM nntp_articles WHERE message_id = ? AND area_id = ?
+		`, parent, grp.AreaID).Scan(&id)
+		if id.Valid {
+			v := int(id.Int64)
+			replyTo = &v
+		}
+	}
+	id, err := g.msgs.Import(grp.AreaID, g.cfg.UserID, a.From, a.Subject, a.Body, replyTo, a.Date)
+	if err != nil {
+		return false, err
+	}
+	if err := g.track(a.MessageID, id, grp.AreaID, false); err != nil {
+		return false, err
+	}
+	g.Events.Publish(event.Event{Name: event.Post, Data: grp.AreaID})
+	return true, nil
+}
+
+func (g *Gateway) known(messageID string) (bool, error) {
+	var n int
+	err := g.db.QueryRow(`SELECT COUNT(*) FROM nntp_articles WHERE message_id = ?`, messageID).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("look up %s: %w", messageID, err)
+	}
+	return n > 0, nil
+}
+
+func (g *Gateway) track(messageID string, msgID, areaID int, outbound bool) error {
+	_, err := g.db.Exec(`
+		INSERT INTO nntp_articles (message_id, msg_id, area_id, outbound) VALUES (?, ?, ?, ?)
+	`, messageID, msgID, areaID, outbound)
+	if err != nil {
+		return fmt.Errorf("track %s: %w", messageID, err)
+	}
+	return nil
+}
+
+// post sends the area's new public messages written on the board.
+func (g *Gateway) post(c *Client, grp Group, st *groupState, gr *GroupReport) error {
+	msgs, err := g.msgs.MessagesAfter(grp.AreaID, st.lastExported)
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		var tracked int
+		g.db.QueryRow(`SELECT COUNT(*) FROM nntp_articles WHERE msg_id = ?`, m.ID).Scan(&tracked)
+		if m.ToUserID == nil && tracked == 0 {
+			if err := g.postMessage(c, grp, m); err != nil {
+				return err
+			}
+			gr.Posted++
+		}
+		st.lastExported = m.ID
+		if err := g.saveState(grp, st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Gateway) postMessage(c *Client, grp Group, m *message.Message) error {
+	out := &Outgoing{
+		MessageID:    fmt.Sprintf("<%d.%d.%d@%s>", m.ID, grp.AreaID, m.CreatedAt.Unix(), g.cfg.Domain),
+		Newsgroup:    grp.Newsgroup,
+		FromName:     m.FromName,
+		FromAddr:     localPart(m.FromName) + "@" + g.cfg.Domain,
+		Organization: g.cfg.Organization,
+		Subject:      m.Subject,
+		Date:         m.CreatedAt,
+		Body:         m.Body,
+	}
+	if m.ReplyToID != nil {
+		var parent string
+		g.db.QueryRow(`SELECT message_id FROM nntp_articles WHERE msg_id = ?`, *m.ReplyToID).Scan(&parent)
+		if parent != "" {
+			out.References = []string{parent}
+		}
+	}
+	if err := c.Post(out.Format()); err != nil {
+		return fmt.Errorf("message %d: %w", m.ID, err)
+	}
+	return g.track(out.MessageID, m.ID, grp.AreaID, true)
+}
@@ -0,0 +1,260 @@
+package nntp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/message"
+)
+
+// fakeServer is a one-group news server. Posted articles are appended to
+// the group.
+type fakeServer struct {
+	mu       sync.Mutex
+	group    string
+	articles []string
+	posted   []string
+	l        net.Listener
+}
+
+func newFakeServer(t *testing.T, group string, articles ...string) *fakeServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{group: group, articles: articles, l: l}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeServer) serve(nc net.Conn) {
+	defer nc.Close()
+	c := textproto.NewConn(nc)
+	c.PrintfLine("200 fake news server ready")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		s.mu.Lock()
+		switch strings.ToUpper(f[0]) {
+		case "MODE":
+			c.PrintfLine("200 posting allowed")
+		case "GROUP":
+			if len(f) < 2 || f[1] != s.group {
+				c.PrintfLine("411 no such group")
+			} else {
+				c.PrintfLine("211 %d 1 %d %s", len(s.articles), len(s.articles), s.group)
+			}
+		case "ARTICLE":
+			n, _ := strconv.Atoi(f[1])
+			if n < 1 || n > len(s.articles) || s.articles[n-1] == "" {
+				c.PrintfLine("423 no such article")
+				break
+			}
+			c.PrintfLine("220 %d article", n)
+			w := c.DotWriter()
+			io.WriteString(w, s.articles[n-1])
+			w.Close()
+		case "POST":
+			c.PrintfLine("340 send article")
+			s.mu.Unlock()
+			data, err := io.ReadAll(c.DotReader())
+			s.mu.Lock()
+			if err != nil {
+				s.mu.Unlock()
+				return
+			}
+			s.posted = append(s.posted, string(data))
+			s.articles = append(s.articles, string(data))
+			c.PrintfLine("240 article posted")
+		case "QUIT":
+			c.PrintfLine("205 bye")
+			s.mu.Unlock()
+			return
+		default:
+			c.PrintfLine("500 what?")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func article(id, from, subject, refs, body string) string {
+	a := fmt.Sprintf("From: %s\nNewsgroups: alt.bbs\nSubject: %s\nDate: Mon, 02 Jun 2025 10:00:00 +0000\nMessage-ID: %s\n", from, subject, id)
+	if refs != "" {
+		a += "References: " + refs + "\n"
+	}
+	return a + "\n" + body + "\n"
+}
+
+func TestParseArticle(t *testing.T) {
+	raw := "From: =?ISO-8859-1?Q?J=F6rg_M=FCller?= <jm@example.org>\r\n" +
+		"Subject: =?UTF-8?Q?Gr=C3=BC=C3=9Fe?= aus\r\n  Berlin\r\n" +
+		"Message-ID: <abc@example.org>\r\n" +
+		"References: <a@x> <b@x>\r\n" +
+		"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Sch=F6ne Gr=FC=DFe\r\n\r\n"
+	a, err := ParseArticle([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.From != "Jörg Müller" || a.Subject != "Grüße aus Berlin" || a.MessageID != "<abc@example.org>" {
+		t.Errorf("headers = %q, %q, %q", a.From, a.Subject, a.MessageID)
+	}
+	if a.Parent() != "<b@x>" || a.Body != "Schöne Grüße" {
+		t.Errorf("parent %q, body %q", a.Parent(), a.Body)
+	}
+	if a.Date.IsZero() {
+		t.Error("no date")
+	}
+
+	for from, want := range map[string]string{
+		"jm@example.org":                 "jm@example.org",
+		"jm@example.org (Jörg)":          "Jörg",
+		`"Smith, John" <js@example.org>`: "Smith, John",
+		"":                               "Unknown",
+	} {
+		if got := author(from); got != want {
+			t.Errorf("author(%q) = %q, want %q", from, got, want)
+		}
+	}
+
+	if _, err := ParseArticle([]byte("Subject: x\n\nbody\n")); err == nil {
+		t.Error("article without Message-ID parsed")
+	}
+}
+
+func TestOutgoingFormat(t *testing.T) {
+	o := &Outgoing{
+		MessageID:  "<1.2.3@bbs.example>",
+		Newsgroup:  "alt.bbs",
+		FromName:   "Night Owl",
+		FromAddr:   localPart("Night Owl") + "@bbs.example",
+		Subject:    "Grüße",
+		Date:       time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC),
+		References: []string{"<p@x>"},
+		Body:       "line one\nline two",
+	}
+	got := string(o.Format())
+	for _, want := range []string{
+		"From: \"Night Owl\" <night.owl@bbs.example>\r\n",
+		"Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n",
+		"References: <p@x>\r\n",
+		"\r\n\r\nline one\r\nline two\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("article lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestGatewaySync(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	for _, q := range []string{
+		`INSERT INTO users (id, username, password_hash) VALUES (1, 'sysop', 'x'), (2, 'Usenet', '!')`,
+		`INSERT INTO message_areas (id, name) VALUES (100, 'Usenet: alt.bbs')`,
+	} {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := message.NewRepo(database.DB)
+	// Written before the gateway was set up: never posted.
+	if _, err := msgs.Post(100, 1, nil, "old", "old news", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newFakeServer(t, "alt.bbs",
+		article("<root@example.org>", "Alice <alice@example.org>", "Hello BBS", "", "Anyone out there?"),
+		"", // cancelled
+		article("<reply@example.org>", "bob@example.org (Bob)", "Re: Hello BBS", "<root@example.org>", "Yes!"),
+	)
+	gw := NewGateway(database.DB, msgs, Config{
+		Server:   srv.l.Addr().String(),
+		Domain:   "bbs.example",
+		UserID:   2,
+		Backfill: 10,
+		Timeout:  5 * time.Second,
+		Groups:   []Group{{AreaID: 100, Newsgroup: "alt.bbs", Post: true}},
+	})
+	var posts []any
+	gw.Events = event.NewBus()
+	gw.Events.Subscribe(event.Post, func(ev event.Event) { posts = append(posts, ev.Data) })
+
+	report, err := gw.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := report.Groups[0]; g.Err != nil || g.Imported != 2 || g.Posted != 0 {
+		t.Fatalf("first sync = %+v", g)
+	}
+	if len(posts) != 2 || posts[0] != 100 {
+		t.Errorf("post events = %v", posts)
+	}
+	list, err := msgs.MessagesAfter(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].FromName != "Alice" || list[1].FromName != "Bob" ||
+		list[1].ReplyToID == nil || *list[1].ReplyToID != list[0].ID {
+		t.Fatalf("imported = %+v %+v", list[0], list[1])
+	}
+
+	// A local reply goes out with References, and is not imported back.
+	reply := list[1].ID
+	if _, err := msgs.Post(100, 1, nil, "Re: Hello BBS", "Welcome, both of you.", &reply); err != nil {
+		t.Fatal(err)
+	}
+	report, err = gw.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := report.Groups[0]; g.Err != nil || g.Imported != 0 || g.Posted != 1 {
+		t.Fatalf("second sync = %+v", g)
+	}
+	if len(srv.posted) != 1 || !strings.Contains(srv.posted[0], "References: <reply@example.org>") ||
+		!strings.Contains(srv.posted[0], "From: \"sysop\" <sysop@bbs.example>") {
+		t.Fatalf("posted = %q", srv.posted)
+	}
+
+	report, err = gw.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := report.Groups[0]; g.Err != nil || g.Imported != 0 || g.Duplicates != 1 || g.Posted != 0 {
+		t.Fatalf("third sync = %+v", g)
+	}
+	if n := msgs.CountMessages(100); n != 4 {
+		t.Errorf("area has %d messages, want 4", n)
+	}
+}
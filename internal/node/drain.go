@@ -0,0 +1,148 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// drainWarnings are the times left at which callers are warned again
+// during a shutdown countdown.
+var drainWarnings = []time.Duration{
+	10 * time.Minute, 5 * time.Minute, 2 * time.Minute, time.Minute,
+	30 * time.Second, 10 * time.Second,
+}
+
+// drainPoll is how often a drain checks on the nodes.
+var drainPoll = time.Second
+
+// hookWait is how long callers' on_shutdown handlers get, once the
+// countdown is over, before the menus are disconnected.
+var hookWait = time.Second
+
+// Drain empties the nodes for a shutdown, first sending away anyone in
+// the waiting room. Callers are warned with msg (or a standard warning
+// when msg is empty) and again at intervals during countdown; each warning also runs the current menu's on_shutdown
+// handler, so scripts can save what the caller was doing. When the
+// countdown runs out, the handlers run once more and callers in the menus
+// are disconnected; callers busy in a door or transfer get up to grace
+// more to finish and are disconnected as soon as they are done. Drain
+// returns when every node is gone, time is up or ctx is done, leaving any
+// nodes still online to the caller.
+func (m *Manager) Drain(ctx context.Context, countdown, grace time.Duration, msg string) {
+	m.closeQueue()
+	if msg == "" {
+		msg = fmt.Sprintf("The board is going down in %s. Please finish up and log off.", countdownText(countdown))
+	}
+	end := time.Now().Add(countdown)
+	if countdown > 0 {
+		m.warn(end, countdown, msg)
+	}
+
+	tick := time.NewTicker(drainPoll)
+	defer tick.Stop()
+
+	warnings := drainWarnings
+	for m.Count() > 0 {
+		left := time.Until(end)
+		if left <= 0 {
+			break
+		}
+		next := -1
+		for i, w := range warnings {
+			if w < countdown && left <= w {
+				next = i
+			}
+		}
+		if next >= 0 {
+			m.warn(end, warnings[next], fmt.Sprintf("The board is going down in %s.", countdownText(warnings[next])))
+			warnings = warnings[next+1:]
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+
+	if countdown > 0 && m.Count() > 0 {
+		m.warn(end, 0, "")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(hookWait):
+		}
+	}
+
+	deadline := time.Now().Add(grace)
+	gone := make(map[int]bool)
+	for m.Count() > 0 {
+		for _, n := range m.List() {
+			if gone[n.ID] || n.Session.Busy() != "" {
+				continue
+			}
+			n.Term.SendTimeout("\r\n*** System is shutting down NOW. Goodbye!\r\n", m.WriteTimeout)
+			n.Disconnect()
+			gone[n.ID] = true
+		}
+		if !time.Now().Before(deadline) {
+			busy := 0
+			for _, n := range m.List() {
+				if !gone[n.ID] {
+					busy++
+				}
+			}
+			if busy > 0 {
+				log.Printf("Drain: grace period over with %d node(s) still busy", busy)
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// warn shows every caller in the menus the shutdown banner with msg and
+// wakes their session to run on_shutdown. Callers in a door or transfer
+// get msg as a held notice instead. left is the time the banner shows; an
+// empty msg only wakes the sessions.
+func (m *Manager) warn(end time.Time, left time.Duration, msg string) {
+	m.each(func(n *Node) {
+		n.Session.SetShutdown(end)
+		if msg != "" && !n.Session.Notify(msg) {
+			m.send(n, shutdownBanner(left, msg), msg)
+		}
+		n.Term.Wake()
+	})
+}
+
+// shutdownBanner is the standard countdown warning, framed so it stands
+// out from whatever the menu has on screen.
+func shutdownBanner(left time.Duration, msg string) string {
+	rule := "  +" + strings.Repeat("-", 44) + "+"
+	title := fmt.Sprintf("SYSTEM SHUTDOWN IN %s", strings.ToUpper(countdownText(left)))
+	return "\r\n" + rule + "\r\n" +
+		fmt.Sprintf("  |  %-41s |", title) + "\r\n" +
+		rule + "\r\n" +
+		"  " + msg + "\r\n"
+}
+
+// countdownText spells out a countdown for callers, e.g. "5 minutes".
+func countdownText(d time.Duration) string {
+	switch {
+	case d >= time.Minute && d%time.Minute == 0:
+		if d == time.Minute {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	case d == time.Second:
+		return "1 second"
+	default:
+		return fmt.Sprintf("%d seconds", d/time.Second)
+	}
+}
@@ -0,0 +1,101 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// fakeConn records what a node is sent and whether it was hung up.
+type fakeConn struct {
+	mu     sync.Mutex
+	out    bytes.Buffer
+	closed bool
+}
+
+func (c *fakeConn) Read(p []byte) (int, error) { select {} }
+
+func (c *fakeConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out.Write(p)
+}
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) state() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out.String(), c.closed
+}
+
+func TestDrain(t *testing.T) {
+	drainPoll, hookWait = 10*time.Millisecond, 10*time.Millisecond
+	defer func() { drainPoll, hookWait = time.Second, time.Second }()
+
+	mgr := NewManager(2, "TestBBS", "Sysop")
+	idleConn, busyConn := &fakeConn{}, &fakeConn{}
+	idle := NewNode(1, terminal.New(idleConn, 80, 24, false), "idle")
+	busy := NewNode(2, terminal.New(busyConn, 80, 24, false), "busy")
+	busy.Session.SetBusy(session.BusyDoor)
+	mgr.Add(idle)
+	mgr.Add(busy)
+
+	start := time.Now()
+	mgr.Drain(context.Background(), 50*time.Millisecond, 100*time.Millisecond, "")
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("drain returned after %v, want countdown + grace", d)
+	}
+
+	out, closed := idleConn.state()
+	if !closed || !strings.Contains(out, "SYSTEM SHUTDOWN IN 0 SECONDS") || !strings.Contains(out, "going down") || !strings.Contains(out, "Goodbye") {
+		t.Errorf("idle node: closed %v, output %q", closed, out)
+	}
+	if _, ok := idle.Session.ShutdownIn(); !ok {
+		t.Error("idle node's session was not told about the shutdown")
+	}
+	out, closed = busyConn.state()
+	if closed || out != "" {
+		t.Errorf("busy node: closed %v, output %q", closed, out)
+	}
+	if notices := busy.Session.TakeNotices(); len(notices) == 0 || !strings.Contains(notices[0], "going down") {
+		t.Errorf("busy node notices = %q", notices)
+	}
+}
+
+func TestDrainCanceled(t *testing.T) {
+	mgr := NewManager(1, "TestBBS", "Sysop")
+	mgr.Add(NewNode(1, terminal.New(&fakeConn{}, 80, 24, false), "idle"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	mgr.Drain(ctx, time.Hour, time.Hour, "")
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("canceled drain took %v", d)
+	}
+}
+
+func TestCountdownText(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		10 * time.Minute: "10 minutes",
+		time.Minute:      "1 minute",
+		90 * time.Second: "90 seconds",
+		time.Second:      "1 second",
+	} {
+		if got := countdownText(d); got != want {
+			t.Errorf("countdownText(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
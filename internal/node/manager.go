@@ -1,46 +1,189 @@
 package node
 
 import (
+	"errors"
 	"fmt"
+	"iter"
+	"log"
+	"slices"
 	"sync"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
 )
 
+// Settings holds per-node overrides. The zero value means an ordinary,
+// unrestricted node.
+type Settings struct {
+	Name      string
+	MinLevel  int           // minimum security level allowed to log in
+	TimeLimit time.Duration // per-call limit after login, 0 = unlimited
+}
+
+// Restricted reports whether the node limits who may use it.
+func (s Settings) Restricted() bool {
+	return s.MinLevel > 0
+}
+
 // Manager tracks all active nodes and enforces the max-nodes limit.
 type Manager struct {
 	mu       sync.RWMutex
 	nodes    map[int]*Node
+	settings map[int]Settings
 	maxNodes int
 	BBSName  string
 	SysopName string
+
+	// WriteTimeout bounds each write of a notice to a node, so one stalled
+	// client cannot hold up the rest.
+	WriteTimeout time.Duration
+
+	// The waiting room (see Enqueue): callers waiting for a node, and the
+	// nodes granted to them that they have not yet added.
+	queue     []*Ticket
+	queueSize int
+	held      map[int]bool
 }
 
+// DefaultWriteTimeout is the WriteTimeout of a new Manager.
+const DefaultWriteTimeout = 5 * time.Second
+
 // NewManager creates a new node manager.
 func NewManager(maxNodes int, bbsName, sysopName string) *Manager {
 	return &Manager{
 		nodes:     make(map[int]*Node),
+		settings:  make(map[int]Settings),
+		held:      make(map[int]bool),
 		maxNodes:  maxNodes,
 		BBSName:   bbsName,
 		SysopName: sysopName,
+
+		WriteTimeout: DefaultWriteTimeout,
+	}
+}
+
+// SetSettings installs overrides for a node number.
+func (m *Manager) SetSettings(id int, s Settings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings[id] = s
+}
+
+// ReplaceSettings installs a new set of overrides, clearing those of node
+// numbers not in settings. Connected nodes keep the settings they started
+// with.
+func (m *Manager) ReplaceSettings(settings map[int]Settings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings = make(map[int]Settings, len(settings))
+	for id, s := range settings {
+		m.settings[id] = s
 	}
 }
 
+// Settings returns the overrides for a node number (zero value if none).
+func (m *Manager) Settings(id int) Settings {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.settings[id]
+}
+
 // Acquire allocates a node ID if capacity allows.
 // Returns the node ID and true, or 0 and false if full.
 func (m *Manager) Acquire() (int, bool) {
+	return m.AcquirePreferred(0)
+}
+
+// AcquirePreferred allocates a node ID for a caller whose identity is not
+// yet known, returning preferred if it is in range and free. See AcquireFor.
+func (m *Manager) AcquirePreferred(preferred int) (int, bool) {
+	id, err := m.AcquireFor(preferred, LevelUnknown)
+	return id, err == nil
+}
+
+// LevelUnknown is passed to AcquireFor when the caller has not logged in yet.
+const LevelUnknown = -1
+
+// ErrAllNodesBusy is returned by AcquireFor when every node is in use.
+var ErrAllNodesBusy = errors.New("all nodes are busy")
+
+// ErrNodesReserved is returned by AcquireFor when the only free nodes are
+// restricted to a higher security level than the caller's.
+var ErrNodesReserved = errors.New("remaining nodes are reserved")
+
+// AcquireFor allocates a node ID for a caller with the given security level.
+// The preferred ID is returned if it is free and usable (0 means no
+// preference). Otherwise the lowest free unrestricted node is used, then the
+// lowest free restricted node the caller may use.
+//
+// A caller of LevelUnknown (e.g. telnet, before login) is never placed on
+// a restricted node, so an anonymous caller cannot sit at the login prompt
+// of the node kept free for the sysop.
+func (m *Manager) AcquireFor(preferred, level int) (int, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.acquire(preferred, level)
+}
+
+// acquire is AcquireFor with m.mu held. Nodes granted to the waiting room
+// count as in use.
+func (m *Manager) acquire(preferred, level int) (int, error) {
+	if m.inUse()+len(m.held) >= m.maxNodes {
+		return 0, ErrAllNodesBusy
+	}
+
+	usable := func(id int) bool {
+		if _, exists := m.nodes[id]; exists || m.held[id] {
+			return false
+		}
+		if level == LevelUnknown {
+			return !m.settings[id].Restricted()
+		}
+		return m.settings[id].MinLevel <= level
+	}
 
-	if len(m.nodes) >= m.maxNodes {
-		return 0, false
+	if preferred >= 1 && preferred <= m.maxNodes && usable(preferred) {
+		return preferred, nil
 	}
 
-	for id := 1; id <= m.maxNodes; id++ {
-		if _, exists := m.nodes[id]; !exists {
-			return id, true
+	for _, restricted := range []bool{false, true} {
+		for id := 1; id <= m.maxNodes; id++ {
+			if m.settings[id].Restricted() != restricted {
+				continue
+			}
+			if usable(id) {
+				return id, nil
+			}
 		}
 	}
 
-	return 0, false
+	// Nodes are free, but none the caller may use.
+	return 0, ErrNodesReserved
+}
+
+// inUse counts the connected nodes within the node limit, leaving out
+// local console nodes. m.mu must be held.
+func (m *Manager) inUse() int {
+	n := 0
+	for id := range m.nodes {
+		if id <= m.maxNodes {
+			n++
+		}
+	}
+	return n
+}
+
+// AcquireLocal allocates a node ID for the sysop's local console. It is
+// numbered after the last regular node and does not count against the
+// limit, so the console works even when the board is full.
+func (m *Manager) AcquireLocal() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.maxNodes + 1
+	for m.nodes[id] != nil {
+		id++
+	}
+	return id
 }
 
 // Add registers a node with the manager.
@@ -48,13 +191,16 @@ func (m *Manager) Add(n *Node) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.nodes[n.ID] = n
+	delete(m.held, n.ID)
 }
 
-// Remove removes a node from the manager.
+// Remove removes a node from the manager, passing it on to the waiting
+// room if anyone is waiting.
 func (m *Manager) Remove(id int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	delete(m.nodes, id)
+	m.serveQueue()
 }
 
 // Get returns a node by ID, or nil if not found.
@@ -82,50 +228,121 @@ func (m *Manager) List() []*Node {
 	return nodes
 }
 
+// All iterates over a snapshot of the active nodes in node order. The
+// manager is not locked while the loop body runs, so it may block on a
+// node or call back into the manager.
+func (m *Manager) All() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		nodes := m.List()
+		slices.SortFunc(nodes, func(a, b *Node) int { return a.ID - b.ID })
+		for _, n := range nodes {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
 // NodeInfo holds summary information about a connected node.
 type NodeInfo struct {
 	ID       int
+	Name     string
 	UserName string
 	Remote   string
 	Menu     string
+	Since    time.Time // when the caller connected
+	Busy     string    // session.BusyDoor, session.BusyTransfer or ""
+	Sent     int64     // terminal bytes sent, transfers excluded
+	Received int64     // terminal bytes received
 }
 
 // ListInfo returns summary info for all active nodes.
 func (m *Manager) ListInfo() []NodeInfo {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	info := make([]NodeInfo, 0, len(m.nodes))
-	for _, n := range m.nodes {
-		name := n.UserName
-		if name == "" {
-			name = "(logging in)"
-		}
+	var info []NodeInfo
+	for n := range m.All() {
+		sent, received := n.Term.Traffic()
 		info = append(info, NodeInfo{
 			ID:       n.ID,
-			UserName: name,
+			Name:     m.Settings(n.ID).Name,
+			UserName: displayName(n),
 			Remote:   n.Remote,
 			Menu:     n.CurrentMenu,
+			Since:    n.ConnectAt,
+			Busy:     n.Session.Busy(),
+			Sent:     sent,
+			Received: received,
 		})
 	}
 	return info
 }
 
-// Broadcast sends a message to all connected nodes.
-func (m *Manager) Broadcast(msg string) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	for _, n := range m.nodes {
-		n.Term.SendLn(fmt.Sprintf("\r\n*** %s", msg))
+// Broadcast sends a message to all connected nodes and returns how many it
+// reached. Callers busy in a door or transfer get it when they are back,
+// as do callers whose connection does not take it in time.
+func (m *Manager) Broadcast(msg string) int {
+	return m.each(func(n *Node) { m.notify(n, msg) })
+}
+
+// each calls fn for every active node, on a goroutine per node so slow
+// ones are waited for together, and returns the number of nodes.
+func (m *Manager) each(fn func(n *Node)) int {
+	var wg sync.WaitGroup
+	count := 0
+	for n := range m.All() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(n)
+		}()
+		count++
 	}
+	wg.Wait()
+	return count
 }
 
 // SendTo sends a message to a specific node.
 func (m *Manager) SendTo(nodeID int, msg string) error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	n, ok := m.nodes[nodeID]
-	if !ok {
+	n := m.Get(nodeID)
+	if n == nil {
+		return fmt.Errorf("node %d not found", nodeID)
+	}
+	m.notify(n, msg)
+	return nil
+}
+
+// Kick disconnects a node, showing it msg first when msg is not empty.
+func (m *Manager) Kick(nodeID int, msg string) error {
+	n := m.Get(nodeID)
+	if n == nil {
 		return fmt.Errorf("node %d not found", nodeID)
 	}
-	return n.Term.SendLn(fmt.Sprintf("\r\n*** %s", msg))
+	if msg != "" {
+		n.Term.SendTimeout(fmt.Sprintf("\r\n*** %s\r\n", msg), m.WriteTimeout)
+	}
+	n.Disconnect()
+	return nil
+}
+
+// notify shows a notice on a node. It is held in the session while the
+// caller is busy, and in the chat broker when the terminal does not take
+// it within WriteTimeout.
+func (m *Manager) notify(n *Node, msg string) {
+	if n.Session.Notify(msg) {
+		return
+	}
+	m.send(n, fmt.Sprintf("\r\n*** %s\r\n", msg), msg)
+}
+
+// send writes text to a node within WriteTimeout. When it was not written
+// at all, notice is held in the chat broker for the node's next menu; a
+// write that timed out may still arrive, so it is not held twice.
+func (m *Manager) send(n *Node, text, notice string) {
+	err := n.Term.SendTimeout(text, m.WriteTimeout)
+	if err == nil {
+		return
+	}
+	log.Printf("Node %d: notice not delivered: %v", n.ID, err)
+	if errors.Is(err, terminal.ErrWriteSkipped) && n.ChatBroker != nil {
+		n.ChatBroker.Hold(n.ID, notice)
+	}
 }
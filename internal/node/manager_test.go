@@ -1,6 +1,14 @@
 package node
 
-import "testing"
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/chat"
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
 
 func TestManagerAcquireLowestAvailable(t *testing.T) {
 	mgr := NewManager(3, "TestBBS", "Sysop")
@@ -52,3 +60,165 @@ func TestManagerAcquireCapacityAndReuse(t *testing.T) {
 		t.Fatalf("expected reused id=1 ok=true, got id=%d ok=%v", id4, ok)
 	}
 }
+
+func TestManagerAcquirePreferred(t *testing.T) {
+	mgr := NewManager(3, "TestBBS", "Sysop")
+
+	id, ok := mgr.AcquirePreferred(3)
+	if !ok || id != 3 {
+		t.Fatalf("expected preferred id=3 ok=true, got id=%d ok=%v", id, ok)
+	}
+	mgr.Add(&Node{ID: id})
+
+	// Preferred node is taken: fall back to lowest free.
+	id, ok = mgr.AcquirePreferred(3)
+	if !ok || id != 1 {
+		t.Fatalf("expected fallback id=1 ok=true, got id=%d ok=%v", id, ok)
+	}
+	mgr.Add(&Node{ID: id})
+
+	// Out-of-range preference is ignored.
+	id, ok = mgr.AcquirePreferred(9)
+	if !ok || id != 2 {
+		t.Fatalf("expected fallback id=2 ok=true, got id=%d ok=%v", id, ok)
+	}
+	mgr.Add(&Node{ID: id})
+
+	id, ok = mgr.AcquirePreferred(1)
+	if ok || id != 0 {
+		t.Fatalf("expected id=0 ok=false when full, got id=%d ok=%v", id, ok)
+	}
+}
+
+func TestManagerAcquireSkipsRestrictedNodesFirst(t *testing.T) {
+	mgr := NewManager(3, "TestBBS", "Sysop")
+	mgr.SetSettings(1, Settings{Name: "Sysop Console", MinLevel: 100})
+
+	id, ok := mgr.Acquire()
+	if !ok || id != 2 {
+		t.Fatalf("expected unrestricted id=2 ok=true, got id=%d ok=%v", id, ok)
+	}
+	mgr.Add(&Node{ID: id})
+
+	id, ok = mgr.Acquire()
+	if !ok || id != 3 {
+		t.Fatalf("expected unrestricted id=3 ok=true, got id=%d ok=%v", id, ok)
+	}
+	mgr.Add(&Node{ID: id})
+
+	// Callers not yet logged in never get the restricted node.
+	if id, err := mgr.AcquireFor(0, LevelUnknown); err != ErrNodesReserved {
+		t.Fatalf("expected ErrNodesReserved, got id=%d err=%v", id, err)
+	}
+}
+
+func TestManagerAcquireForReservedNode(t *testing.T) {
+	mgr := NewManager(2, "TestBBS", "Sysop")
+	mgr.SetSettings(2, Settings{Name: "Sysop", MinLevel: 100})
+
+	id, err := mgr.AcquireFor(0, 10)
+	if err != nil || id != 1 {
+		t.Fatalf("expected id=1, got id=%d err=%v", id, err)
+	}
+	mgr.Add(&Node{ID: id})
+
+	// Only the reserved node is left: regular users are turned away...
+	if id, err := mgr.AcquireFor(0, 10); err != ErrNodesReserved {
+		t.Fatalf("expected ErrNodesReserved, got id=%d err=%v", id, err)
+	}
+
+	// ...and so are callers not yet logged in...
+	if id, err := mgr.AcquireFor(0, LevelUnknown); err != ErrNodesReserved {
+		t.Fatalf("expected ErrNodesReserved, got id=%d err=%v", id, err)
+	}
+
+	// ...and the sysop gets in.
+	id, err = mgr.AcquireFor(0, 100)
+	if err != nil || id != 2 {
+		t.Fatalf("expected sysop id=2, got id=%d err=%v", id, err)
+	}
+	mgr.Add(&Node{ID: id})
+
+	if id, err := mgr.AcquireFor(0, 100); err != ErrAllNodesBusy {
+		t.Fatalf("expected ErrAllNodesBusy, got id=%d err=%v", id, err)
+	}
+}
+
+// stalledConn is a connection whose client stopped reading: writes block
+// until release is closed.
+type stalledConn struct {
+	fakeConn
+	release chan struct{}
+}
+
+func (c *stalledConn) Write(p []byte) (int, error) {
+	<-c.release
+	return c.fakeConn.Write(p)
+}
+
+func TestBroadcastStalledNode(t *testing.T) {
+	mgr := NewManager(2, "TestBBS", "Sysop")
+	mgr.WriteTimeout = 20 * time.Millisecond
+	broker := chat.NewBroker()
+	okConn, stalled := &fakeConn{}, &stalledConn{release: make(chan struct{})}
+	defer close(stalled.release)
+	for _, n := range []*Node{
+		NewNode(1, terminal.New(okConn, 80, 24, false), "ok"),
+		NewNode(2, terminal.New(stalled, 80, 24, false), "stalled"),
+	} {
+		n.ChatBroker = broker
+		mgr.Add(n)
+	}
+
+	start := time.Now()
+	if n := mgr.Broadcast("hello"); n != 2 {
+		t.Errorf("Broadcast reached %d nodes, want 2", n)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Broadcast took %v behind a stalled node", d)
+	}
+	mgr.Broadcast("again")
+	if out, _ := okConn.state(); !strings.Contains(out, "*** hello") || !strings.Contains(out, "*** again") {
+		t.Errorf("responsive node got %q", out)
+	}
+	// "hello" is still on its way once the client reads again; only the
+	// notice that was never written is held.
+	if held := broker.TakeHeld(2); !slices.Equal(held, []string{"again"}) {
+		t.Errorf("held for stalled node = %q", held)
+	}
+	if held := broker.TakeHeld(1); held != nil {
+		t.Errorf("held for responsive node = %q", held)
+	}
+
+	var ids []int
+	for n := range mgr.All() {
+		ids = append(ids, n.ID)
+	}
+	if !slices.Equal(ids, []int{1, 2}) {
+		t.Errorf("All = %v", ids)
+	}
+}
+
+func TestManagerAcquireLocalWhenFull(t *testing.T) {
+	mgr := NewManager(1, "TestBBS", "Sysop")
+
+	id, ok := mgr.Acquire()
+	if !ok {
+		t.Fatal("expected a node")
+	}
+	mgr.Add(&Node{ID: id})
+
+	local := mgr.AcquireLocal()
+	if local != 2 {
+		t.Fatalf("local console node = %d, want 2", local)
+	}
+	mgr.Add(&Node{ID: local})
+	if mgr.Count() != 2 {
+		t.Fatalf("count = %d, want 2", mgr.Count())
+	}
+
+	mgr.Remove(id)
+	if id, ok := mgr.Acquire(); !ok || id != 1 {
+		t.Fatalf("with the console on, Acquire = %d, %v; want 1, true", id, ok)
+	}
+}
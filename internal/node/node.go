@@ -7,14 +7,24 @@ import (
 	"database/sql"
 
 	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/bulletin"
+	"github.com/notepid/twilight_bbs/internal/callers"
 	"github.com/notepid/twilight_bbs/internal/chat"
 	"github.com/notepid/twilight_bbs/internal/door"
+	"github.com/notepid/twilight_bbs/internal/event"
 	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/flood"
+	"github.com/notepid/twilight_bbs/internal/greeting"
+	"github.com/notepid/twilight_bbs/internal/mailer"
 	"github.com/notepid/twilight_bbs/internal/menu"
 	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/recording"
+	"github.com/notepid/twilight_bbs/internal/scripting"
+	"github.com/notepid/twilight_bbs/internal/session"
 	"github.com/notepid/twilight_bbs/internal/terminal"
 	"github.com/notepid/twilight_bbs/internal/transfer"
 	"github.com/notepid/twilight_bbs/internal/user"
+	"github.com/notepid/twilight_bbs/internal/weblink"
 )
 
 // Node represents a single BBS connection (one user session).
@@ -28,9 +38,8 @@ type Node struct {
 	CurrentMenu string
 	MenuStack   []string
 
-	// Will be set after login
-	UserID   int
-	UserName string
+	// Session is the caller's login state, shared with the menu engine
+	Session *session.Session
 
 	// Pre-authenticated credentials (from SSH)
 	PreAuthUsername string
@@ -42,10 +51,27 @@ type Node struct {
 	UserRepo       *user.Repo
 	MessageRepo    *message.Repo
 	FileRepo       *filearea.Repo
+	BulletinRepo   *bulletin.Repo
 	ChatBroker     *chat.Broker
 	DoorLauncher   *door.Launcher
 	TransferConfig *transfer.Config
+	ArchiveDir     string
+	Ratio          filearea.Ratio
 	DB             *sql.DB
+	Events         *event.Bus // receives the session's events, event.Logoff when it ends
+	Flood          *flood.Limiter
+	ScriptLimits   scripting.Limits
+	Greetings      *greeting.Service
+	GreetAtLogin   bool
+	Tour           *scripting.Tour
+	Commands       *menu.Commands
+	Recordings     *recording.Store // records the call when set
+	WebLinks       *weblink.Store   // one-time HTTP download links, nil = off
+	SharedVM       bool
+	Mailer         *mailer.Mailer
+	ResetCodeTTL   time.Duration
+	ScriptAlerts   int
+	ChatHistory    int // recent room lines shown on joining
 
 	// Shutdown signal
 	done chan struct{}
@@ -58,6 +84,7 @@ func NewNode(id int, term *terminal.Terminal, remoteAddr string) *Node {
 		Term:      term,
 		ConnectAt: time.Now(),
 		Remote:    remoteAddr,
+		Session:   session.New(),
 		done:      make(chan struct{}),
 	}
 }
@@ -72,28 +99,59 @@ func (n *Node) Run(mgr *Manager) {
 			n.ChatBroker.Unsubscribe(n.ID)
 			n.ChatBroker.UnregisterOnline(n.ID)
 		}
+		n.Events.Publish(event.Event{Name: event.Logoff, NodeID: n.ID, Data: n.call(time.Now())})
 		n.Term.Close()
 		mgr.Remove(n.ID)
 		log.Printf("Node %d disconnected (%s)", n.ID, n.Remote)
 	}()
 
 	log.Printf("Node %d connected from %s", n.ID, n.Remote)
+	defer n.record()()
+	n.Events.Publish(event.Event{Name: event.Connect, NodeID: n.ID, Data: mgr.Count()})
 	if n.ChatBroker != nil {
-		n.ChatBroker.RegisterOnline(n.ID, "(logging in)")
+		n.ChatBroker.RegisterOnline(n.ID, chat.LoggingIn)
+		// Pages and mail notices come from the sender's goroutine, so they
+		// go out like broadcasts: a stalled terminal must not hold it up.
+		n.ChatBroker.SetNotifier(n.ID, func(text string) { mgr.notify(n, text) })
 	}
 
 	if n.MenuRegistry != nil && n.ANSILoader != nil {
+		settings := mgr.Settings(n.ID)
 		svc := &menu.Services{
 			UserRepo:        n.UserRepo,
 			MessageRepo:     n.MessageRepo,
 			FileRepo:        n.FileRepo,
+			BulletinRepo:    n.BulletinRepo,
+			Session:         n.Session,
 			ChatBroker:      n.ChatBroker,
 			DoorLauncher:    n.DoorLauncher,
 			TransferConfig:  n.TransferConfig,
+			ArchiveDir:      n.ArchiveDir,
+			Ratio:           n.Ratio,
 			DB:              n.DB,
+			Events:          n.Events,
+			Flood:           n.Flood,
+			ScriptLimits:    n.ScriptLimits,
+			Greetings:       n.Greetings,
+			GreetAtLogin:    n.GreetAtLogin,
+			Tour:            n.Tour,
+			Commands:        n.Commands,
+			Recordings:      n.Recordings,
+			WebLinks:        n.WebLinks,
+			SharedVM:        n.SharedVM,
+			Mailer:          n.Mailer,
+			ResetCodeTTL:    n.ResetCodeTTL,
+			ScriptAlerts:    n.ScriptAlerts,
+			ChatHistory:     n.ChatHistory,
 			NodeID:          n.ID,
 			PreAuthUsername: n.PreAuthUsername,
 			PreAuthPassword: n.PreAuthPassword,
+			NodeName:        settings.Name,
+			MinLevel:        settings.MinLevel,
+			TimeLimit:       settings.TimeLimit,
+			SpyNode: func(targetID int, takeover bool) error {
+				return mgr.Spy(n.Term, n.ID, targetID, takeover)
+			},
 		}
 
 		engine := menu.NewEngine(n.MenuRegistry, n.ANSILoader, n.Term, svc)
@@ -110,12 +168,6 @@ func (n *Node) Run(mgr *Manager) {
 		if err := engine.Run(startMenu); err != nil {
 			log.Printf("Node %d menu engine error: %v", n.ID, err)
 		}
-
-		// Update node info from engine
-		if u := engine.CurrentUser(); u != nil {
-			n.UserID = u.ID
-			n.UserName = u.Username
-		}
 	} else {
 		n.Term.SendLn("Welcome to Twilight BBS!")
 		n.Term.SendLn("Menu system not configured. Type Q to quit.")
@@ -123,6 +175,55 @@ func (n *Node) Run(mgr *Manager) {
 	}
 }
 
+// record starts recording the call when Recordings is set and returns the
+// function that finishes the recording.
+func (n *Node) record() (stop func()) {
+	if n.Recordings == nil {
+		return func() {}
+	}
+	width, height := n.Term.Size()
+	rec, err := n.Recordings.Start(n.ID, width, height)
+	if err != nil {
+		log.Printf("Node %d: %v", n.ID, err)
+		return func() {}
+	}
+	untap := n.Term.AddTap(&terminal.Tap{Output: rec})
+	unwatch := n.Term.WatchSize(rec.Resize)
+	return func() {
+		untap()
+		unwatch()
+		if u := n.Session.User(); u != nil {
+			rec.SetUser(u.Username)
+		}
+		if err := rec.Close(); err != nil {
+			log.Printf("Node %d: %v", n.ID, err)
+		}
+	}
+}
+
+// call summarises the session for the callers log.
+func (n *Node) call(end time.Time) *callers.Call {
+	st := n.Session.Stats()
+	sent, received := n.Term.Traffic()
+	c := &callers.Call{
+		NodeID:         n.ID,
+		Remote:         n.Remote,
+		ConnectedAt:    n.ConnectAt,
+		DisconnectedAt: end,
+		BytesUp:        st.BytesUp,
+		BytesDown:      st.BytesDown,
+		TermSent:       sent,
+		TermReceived:   received,
+		Posts:          st.Posts,
+		LastMenu:       st.LastMenu,
+	}
+	if u := n.Session.User(); u != nil {
+		c.UserID = u.ID
+		c.Username = u.Username
+	}
+	return c
+}
+
 func (n *Node) simpleLoop() error {
 	for {
 		key, err := n.Term.GetKey()
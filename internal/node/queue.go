@@ -0,0 +1,197 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// Waiting room errors.
+var (
+	// ErrQueueFull is returned by Enqueue when the waiting room has no
+	// room, or the caller's address already has a place in it.
+	ErrQueueFull = errors.New("waiting room is full")
+	// ErrQueueClosed is returned by Wait when the board shuts down.
+	ErrQueueClosed = errors.New("waiting room closed")
+	// ErrWaitTimeout is returned by Wait when no node came free in time.
+	ErrWaitTimeout = errors.New("no node came free in time")
+	// ErrWaitAbandoned is returned by Wait when the caller chose to leave.
+	ErrWaitAbandoned = errors.New("caller left the waiting room")
+)
+
+// waitPoll is how often Wait checks for a key while the caller waits.
+var waitPoll = time.Second
+
+// Ticket is a caller's place in the waiting room for a free node. The
+// node it is given is held for it until Add, so no caller arriving later
+// can take it.
+type Ticket struct {
+	m       *Manager
+	host    string
+	pref    int
+	level   int
+	granted chan int
+}
+
+// SetQueueSize sets how many callers may wait for a node when every node
+// is busy; 0 turns the waiting room off.
+func (m *Manager) SetQueueSize(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queueSize = n
+}
+
+// Enqueue gives a caller turned away with ErrAllNodesBusy a place in the
+// waiting room. Callers get nodes in the order they queued, as nodes are
+// removed; a caller that cannot use the freed node (it is reserved) is
+// passed over for the next. Each remote address may hold one place, so
+// one caller cannot fill the queue. The ticket must be cancelled if the
+// caller gives up.
+func (m *Manager) Enqueue(remote string, preferred, level int) (*Ticket, error) {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) >= m.queueSize {
+		return nil, ErrQueueFull
+	}
+	for _, t := range m.queue {
+		if t.host == host {
+			return nil, ErrQueueFull
+		}
+	}
+	t := &Ticket{m: m, host: host, pref: preferred, level: level, granted: make(chan int, 1)}
+	m.queue = append(m.queue, t)
+	m.serveQueue()
+	return t, nil
+}
+
+// Wait keeps the caller company on term until a node is granted,
+// telling them their place in line every interval, and returns the node
+// ID. The caller may leave with Q or Escape. If maxWait (0 = no limit)
+// passes, the caller leaves, hangs up or the board shuts down first, the
+// ticket is cancelled and an error returned.
+func (t *Ticket) Wait(term *terminal.Terminal, maxWait, every time.Duration) (int, error) {
+	deadline := time.Now().Add(maxWait)
+	var next time.Time
+	for {
+		select {
+		case id, ok := <-t.granted:
+			if !ok {
+				return 0, ErrQueueClosed
+			}
+			return id, nil
+		default:
+		}
+
+		now := time.Now()
+		if maxWait > 0 && !now.Before(deadline) {
+			t.Cancel()
+			return 0, ErrWaitTimeout
+		}
+		if !now.Before(next) {
+			if pos := t.Position(); pos > 0 {
+				msg := fmt.Sprintf("All nodes are busy. You are number %d in line", pos)
+				if left := deadline.Sub(now); maxWait > 0 {
+					if left >= time.Minute {
+						left = left.Round(time.Minute)
+					}
+					msg += fmt.Sprintf(" (waiting up to %s more)", countdownText(left.Round(time.Second)))
+				}
+				if err := term.SendLn(msg + ". Press Q to leave."); err != nil {
+					t.Cancel()
+					return 0, err
+				}
+			}
+			next = now.Add(every)
+		}
+
+		key, ok, err := term.PollKey(waitPoll)
+		if err != nil {
+			t.Cancel()
+			return 0, err
+		}
+		if ok && (key == 'q' || key == 'Q' || key == 0x1b) {
+			t.Cancel()
+			return 0, ErrWaitAbandoned
+		}
+	}
+}
+
+// Position is the caller's place in line, 1 for next; 0 once a node has
+// been granted or the ticket cancelled.
+func (t *Ticket) Position() int {
+	t.m.mu.RLock()
+	defer t.m.mu.RUnlock()
+	for i, q := range t.m.queue {
+		if q == t {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Cancel gives up the ticket's place, or the node it was granted if the
+// caller leaves before taking it.
+func (t *Ticket) Cancel() {
+	m := t.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, q := range m.queue {
+		if q == t {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			m.serveQueue()
+			return
+		}
+	}
+	select {
+	case id := <-t.granted:
+		delete(m.held, id)
+		m.serveQueue()
+	default:
+	}
+}
+
+// Waiting returns how many callers are in the waiting room.
+func (m *Manager) Waiting() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.queue)
+}
+
+// closeQueue turns the waiting room off and sends everyone in it away,
+// for a shutdown.
+func (m *Manager) closeQueue() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queueSize = 0
+	for _, t := range m.queue {
+		close(t.granted)
+	}
+	m.queue = nil
+}
+
+// serveQueue grants free nodes to waiting callers in order. m.mu must be
+// held.
+func (m *Manager) serveQueue() {
+	for i := 0; i < len(m.queue); {
+		t := m.queue[i]
+		id, err := m.acquire(t.pref, t.level)
+		if errors.Is(err, ErrAllNodesBusy) {
+			return
+		}
+		if err != nil {
+			i++
+			continue
+		}
+		m.held[id] = true
+		m.queue = append(m.queue[:i], m.queue[i+1:]...)
+		t.granted <- id
+	}
+}
@@ -0,0 +1,127 @@
+package node
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+func TestQueueOrder(t *testing.T) {
+	mgr := NewManager(2, "TestBBS", "Sysop")
+	mgr.SetQueueSize(2)
+	mgr.SetSettings(2, Settings{MinLevel: 100})
+	mgr.Add(&Node{ID: 1})
+	mgr.Add(&Node{ID: 2})
+
+	first, err := mgr.Enqueue("10.0.0.1:1000", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := mgr.Enqueue("10.0.0.2:1000", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Enqueue("10.0.0.1:2000", 0, 10); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("third caller: err = %v, want ErrQueueFull", err)
+	}
+	if p1, p2 := first.Position(), second.Position(); p1 != 1 || p2 != 2 {
+		t.Errorf("positions = %d, %d", p1, p2)
+	}
+
+	// The reserved node goes to the sysop behind, not the caller in front.
+	mgr.Remove(2)
+	select {
+	case id := <-second.granted:
+		if id != 2 {
+			t.Errorf("second got node %d", id)
+		}
+	default:
+		t.Fatal("second caller was not given the reserved node")
+	}
+	if first.Position() != 1 {
+		t.Errorf("first caller lost their place")
+	}
+	if _, err := mgr.AcquireFor(0, 100); !errors.Is(err, ErrAllNodesBusy) {
+		t.Errorf("newcomer took a held node: %v", err)
+	}
+	mgr.Add(&Node{ID: 2})
+
+	// A granted node the caller walks away from goes to the next in line.
+	mgr.Remove(1)
+	first.Cancel()
+	if id, err := mgr.AcquireFor(0, 10); err != nil || id != 1 {
+		t.Errorf("after cancel: id=%d err=%v", id, err)
+	}
+	if mgr.Waiting() != 0 {
+		t.Errorf("Waiting = %d", mgr.Waiting())
+	}
+}
+
+func TestQueueOnePlacePerAddress(t *testing.T) {
+	mgr := NewManager(1, "TestBBS", "Sysop")
+	mgr.SetQueueSize(5)
+	mgr.Add(&Node{ID: 1})
+
+	if _, err := mgr.Enqueue("10.0.0.1:1000", 0, LevelUnknown); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Enqueue("10.0.0.1:1001", 0, LevelUnknown); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("second place for one address: err = %v", err)
+	}
+	if _, err := mgr.Enqueue("10.0.0.2:1000", 0, LevelUnknown); err != nil {
+		t.Errorf("other address: %v", err)
+	}
+}
+
+func TestTicketWait(t *testing.T) {
+	waitPoll = 5 * time.Millisecond
+	defer func() { waitPoll = time.Second }()
+
+	mgr := NewManager(1, "TestBBS", "Sysop")
+	mgr.SetQueueSize(3)
+	mgr.Add(&Node{ID: 1})
+
+	conn := &fakeConn{}
+	ticket, err := mgr.Enqueue("10.0.0.1:1000", 0, LevelUnknown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		mgr.Remove(1)
+	}()
+	id, err := ticket.Wait(terminal.New(conn, 80, 24, false), time.Minute, time.Hour)
+	if err != nil || id != 1 {
+		t.Fatalf("Wait = %d, %v", id, err)
+	}
+	if out, _ := conn.state(); !strings.Contains(out, "You are number 1 in line (waiting up to 1 minute more)") {
+		t.Errorf("caller was shown %q", out)
+	}
+	mgr.Add(&Node{ID: 1})
+
+	ticket, err = mgr.Enqueue("10.0.0.1:1000", 0, LevelUnknown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ticket.Wait(terminal.New(&fakeConn{}, 80, 24, false), 20*time.Millisecond, time.Hour); !errors.Is(err, ErrWaitTimeout) {
+		t.Errorf("timed out wait: err = %v", err)
+	}
+	if mgr.Waiting() != 0 {
+		t.Errorf("timed out caller still waiting")
+	}
+
+	ticket, err = mgr.Enqueue("10.0.0.1:1000", 0, LevelUnknown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.closeQueue()
+	if _, err := ticket.Wait(terminal.New(&fakeConn{}, 80, 24, false), 0, time.Hour); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("closed queue: err = %v", err)
+	}
+	if _, err := mgr.Enqueue("10.0.0.1:1000", 0, LevelUnknown); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("enqueue after close: err = %v", err)
+	}
+}
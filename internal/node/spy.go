@@ -0,0 +1,90 @@
+package node
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/chat"
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// Spy control keys, read from the watcher's terminal.
+const (
+	SpyDetachKey   byte = 0x1d // Ctrl-]
+	SpyTakeoverKey byte = 0x14 // Ctrl-T
+)
+
+// spyPoll is how often a spy session checks that the target is still
+// connected while the watcher types nothing.
+var spyPoll = time.Second
+
+// Spy attaches the watcher's terminal to another node's session. Everything
+// the target node sends or echoes is mirrored to the watcher until the
+// watcher presses SpyDetachKey. In takeover mode the watcher's keystrokes
+// are injected into the target's input; SpyTakeoverKey toggles takeover.
+// The session ends by itself when the target disconnects.
+func (m *Manager) Spy(watcher *terminal.Terminal, watcherID, targetID int, takeover bool) error {
+	if watcherID == targetID {
+		return fmt.Errorf("cannot spy on your own node")
+	}
+	target := m.Get(targetID)
+	if target == nil {
+		return fmt.Errorf("node %d not found", targetID)
+	}
+
+	watcher.SendLn(fmt.Sprintf("\r\n*** Spying on node %d (%s). Ctrl-] detaches, Ctrl-T toggles takeover.",
+		targetID, displayName(target)))
+	log.Printf("Node %d: spying on node %d (takeover=%v)", watcherID, targetID, takeover)
+
+	// The watcher's terminal is written from the target's write path, so
+	// it is buffered: a slow watcher loses output instead of stalling the
+	// caller being watched.
+	out := terminal.NewTapBuffer(watcher)
+	remove := target.Term.AddTap(&terminal.Tap{Output: out})
+	defer func() {
+		remove()
+		out.Close()
+		log.Printf("Node %d: stopped spying on node %d", watcherID, targetID)
+	}()
+
+	for {
+		key, ok, err := watcher.PollKey(spyPoll)
+		if err != nil {
+			return err
+		}
+		if m.Get(targetID) != target {
+			watcher.SendLn("\r\n*** Node has disconnected.")
+			return nil
+		}
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case SpyDetachKey:
+			watcher.SendLn("\r\n*** Detached.")
+			return nil
+		case SpyTakeoverKey:
+			takeover = !takeover
+			if takeover {
+				watcher.SendLn("\r\n*** Takeover ON: your keys go to the remote node.")
+			} else {
+				watcher.SendLn("\r\n*** Takeover OFF: watching only.")
+			}
+			continue
+		}
+
+		if takeover {
+			target.Term.Inject([]byte{key})
+		}
+	}
+}
+
+func displayName(n *Node) string {
+	u := n.Session.User()
+	if u == nil {
+		return chat.LoggingIn
+	}
+	return u.Username
+}
@@ -0,0 +1,73 @@
+package node
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+func TestSpyEndsWhenTargetLeaves(t *testing.T) {
+	spyPoll = 10 * time.Millisecond
+	defer func() { spyPoll = time.Second }()
+
+	mgr := NewManager(2, "TestBBS", "Sysop")
+	mgr.Add(NewNode(2, terminal.New(&fakeConn{}, 80, 24, false), "target"))
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+	watcher := terminal.New(server, 80, 24, false)
+
+	done := make(chan error, 1)
+	go func() { done <- mgr.Spy(watcher, 1, 2, false) }()
+
+	time.Sleep(30 * time.Millisecond)
+	mgr.Remove(2)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Spy: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("spy session outlived its target")
+	}
+}
+
+func TestSpyStalledWatcher(t *testing.T) {
+	mgr := NewManager(2, "TestBBS", "Sysop")
+	conn := &fakeConn{}
+	target := NewNode(2, terminal.New(conn, 80, 24, false), "target")
+	mgr.Add(target)
+
+	// The watcher's client never reads after the banner.
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	banner := make([]byte, 1024)
+	go client.Read(banner)
+	watcher := terminal.New(server, 80, 24, false)
+	spying := make(chan error, 1)
+	go func() { spying <- mgr.Spy(watcher, 1, 2, false) }()
+	time.Sleep(30 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			target.Term.Send("line of output\r\n")
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("target stalled behind the watcher")
+	}
+
+	mgr.Remove(2)
+	client.Close()
+	<-spying
+}
@@ -0,0 +1,239 @@
+package picker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// Item is one choice in a picker.
+type Item struct {
+	ID     int
+	Label  string
+	Detail string // optional second column, also searched
+}
+
+// Keys the picker understands, beyond printable search characters.
+const (
+	keyNone = iota
+	keyUp
+	keyDown
+	keyPrevPage
+	keyNextPage
+	keySelect
+	keyCancel
+	keyBackspace
+	keyChar
+)
+
+// model holds the picker state independently of the terminal.
+type model struct {
+	items    []Item
+	query    string
+	matches  []Item
+	page     int
+	cursor   int // index into the current page
+	pageSize int
+}
+
+func newModel(items []Item, pageSize int) *model {
+	if pageSize < 1 {
+		pageSize = 1
+	}
+	m := &model{items: items, pageSize: pageSize}
+	m.filter()
+	return m
+}
+
+// filter recomputes matches for the query (case-insensitive substring of
+// label or detail) and resets paging.
+func (m *model) filter() {
+	q := strings.ToLower(m.query)
+	m.matches = m.matches[:0]
+	for _, it := range m.items {
+		if q == "" || strings.Contains(strings.ToLower(it.Label), q) || strings.Contains(strings.ToLower(it.Detail), q) {
+			m.matches = append(m.matches, it)
+		}
+	}
+	m.page, m.cursor = 0, 0
+}
+
+func (m *model) pages() int {
+	if len(m.matches) == 0 {
+		return 1
+	}
+	return (len(m.matches) + m.pageSize - 1) / m.pageSize
+}
+
+// visible returns the matches on the current page.
+func (m *model) visible() []Item {
+	start := m.page * m.pageSize
+	end := min(start+m.pageSize, len(m.matches))
+	if start >= end {
+		return nil
+	}
+	return m.matches[start:end]
+}
+
+// handle applies a key and reports whether the picker is finished and, if
+// so, whether an item was chosen.
+func (m *model) handle(key int, ch byte) (done bool, chosen *Item) {
+	switch key {
+	case keyUp:
+		if m.cursor > 0 {
+			m.cursor--
+		} else if m.page > 0 {
+			m.page--
+			m.cursor = m.pageSize - 1
+		}
+	case keyDown:
+		if m.cursor < len(m.visible())-1 {
+			m.cursor++
+		} else if m.page < m.pages()-1 {
+			m.page++
+			m.cursor = 0
+		}
+	case keyPrevPage:
+		if m.page > 0 {
+			m.page--
+			m.cursor = 0
+		}
+	case keyNextPage:
+		if m.page < m.pages()-1 {
+			m.page++
+			m.cursor = 0
+		}
+	case keyBackspace:
+		if m.query != "" {
+			m.query = m.query[:len(m.query)-1]
+			m.filter()
+		}
+	case keyChar:
+		m.query += string(ch)
+		m.filter()
+	case keySelect:
+		if vis := m.visible(); len(vis) > 0 {
+			it := vis[m.cursor]
+			return true, &it
+		}
+	case keyCancel:
+		return true, nil
+	}
+	return false, nil
+}
+
+// Run shows a full-screen picker with search-as-you-type and paging and
+// returns the chosen item. ok is false when the caller cancels.
+//
+// Typing filters the list; Up/Down (or Ctrl-P/Ctrl-N, Tab) move the
+// selection, Left/Right (or PgUp/PgDn) change page, Enter picks and Esc or
+// Ctrl-C cancels.
+func Run(term *terminal.Terminal, title string, items []Item) (item Item, ok bool, err error) {
+	m := newModel(items, term.Height-6)
+	for {
+		if err := render(term, title, m); err != nil {
+			return Item{}, false, err
+		}
+		key, ch, err := readKey(term)
+		if err != nil {
+			return Item{}, false, err
+		}
+		if done, chosen := m.handle(key, ch); done {
+			if term.ANSIEnabled {
+				term.Send(terminal.Reset + terminal.ClearScreen())
+			} else {
+				term.SendLn("")
+			}
+			if chosen == nil {
+				return Item{}, false, nil
+			}
+			return *chosen, true, nil
+		}
+	}
+}
+
+func render(term *terminal.Terminal, title string, m *model) error {
+	var b strings.Builder
+	width := max(term.Width-1, 20)
+	if term.ANSIEnabled {
+		b.WriteString(terminal.ClearScreen())
+		b.WriteString(term.Style(terminal.Attr{FG: 7, BG: 4, Bold: true}))
+		b.WriteString(pad(" "+title, width))
+		b.WriteString(terminal.Reset + "\r\n")
+	} else {
+		b.WriteString("\r\n" + title + "\r\n")
+	}
+	b.WriteString(fmt.Sprintf("Search: %s\r\n\r\n", m.query))
+
+	vis := m.visible()
+	if len(vis) == 0 {
+		b.WriteString("  (no matches)\r\n")
+	}
+	for i, it := range vis {
+		line := it.Label
+		if it.Detail != "" {
+			line = fmt.Sprintf("%-24s %s", it.Label, it.Detail)
+		}
+		line = pad(line, width-2)
+		switch {
+		case i == m.cursor && term.ANSIEnabled:
+			b.WriteString("  " + terminal.Reverse + line + terminal.Reset + "\r\n")
+		case i == m.cursor:
+			b.WriteString("> " + line + "\r\n")
+		default:
+			b.WriteString("  " + line + "\r\n")
+		}
+	}
+	b.WriteString(fmt.Sprintf("\r\nPage %d/%d, %d match(es). Enter=select Esc=cancel Up/Down Left/Right", m.page+1, m.pages(), len(m.matches)))
+	if !term.ANSIEnabled {
+		b.WriteString(" (Tab/^P/^N)")
+	}
+	b.WriteString("\r\n")
+	return term.Send(b.String())
+}
+
+func pad(s string, width int) string {
+	if len(s) > width {
+		return s[:width]
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
+
+// readKey reads one keypress and maps it to a picker key.
+func readKey(term *terminal.Terminal) (int, byte, error) {
+	key, r, err := term.ReadKey()
+	if err != nil {
+		return keyNone, 0, err
+	}
+	switch key {
+	case terminal.KeyEnter:
+		return keySelect, 0, nil
+	case terminal.KeyEsc:
+		return keyCancel, 0, nil
+	case terminal.KeyBackspace:
+		return keyBackspace, 0, nil
+	case terminal.KeyUp:
+		return keyUp, 0, nil
+	case terminal.KeyDown, terminal.KeyTab:
+		return keyDown, 0, nil
+	case terminal.KeyLeft, terminal.KeyPgUp:
+		return keyPrevPage, 0, nil
+	case terminal.KeyRight, terminal.KeyPgDn:
+		return keyNextPage, 0, nil
+	case terminal.KeyCtrl:
+		switch r {
+		case 3: // Ctrl-C
+			return keyCancel, 0, nil
+		case 14: // Ctrl-N
+			return keyDown, 0, nil
+		case 16: // Ctrl-P
+			return keyUp, 0, nil
+		}
+	case terminal.KeyChar:
+		if r < 0x7f {
+			return keyChar, byte(r), nil
+		}
+	}
+	return keyNone, 0, nil
+}
@@ -0,0 +1,69 @@
+package picker
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+func TestModelFilterAndPaging(t *testing.T) {
+	items := []Item{
+		{ID: 1, Label: "alice", Detail: "Oslo"},
+		{ID: 2, Label: "bob", Detail: "Bergen"},
+		{ID: 3, Label: "bobby", Detail: "Oslo"},
+		{ID: 4, Label: "carol"},
+	}
+	m := newModel(items, 2)
+	if m.pages() != 2 {
+		t.Fatalf("pages = %d, want 2", m.pages())
+	}
+
+	m.handle(keyDown, 0)
+	m.handle(keyDown, 0) // wraps onto page 2
+	if m.page != 1 || m.cursor != 0 {
+		t.Fatalf("page/cursor = %d/%d, want 1/0", m.page, m.cursor)
+	}
+
+	for _, c := range []byte("OSL") {
+		m.handle(keyChar, c)
+	}
+	if len(m.matches) != 2 || m.page != 0 {
+		t.Fatalf("matches for %q = %v", m.query, m.matches)
+	}
+	m.handle(keyDown, 0)
+	if done, it := m.handle(keySelect, 0); !done || it == nil || it.ID != 3 {
+		t.Fatalf("select = %v, %v", done, it)
+	}
+}
+
+func TestRunSearchAndSelect(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+
+	term := terminal.New(server, 80, 24, false)
+	go client.Write([]byte("car\r"))
+
+	it, ok, err := Run(term, "Pick", []Item{{ID: 1, Label: "alice"}, {ID: 4, Label: "carol"}})
+	if err != nil || !ok || it.ID != 4 {
+		t.Fatalf("Run = %v, %v, %v", it, ok, err)
+	}
+}
+
+func TestRunCursorKeys(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+
+	term := terminal.New(server, 80, 24, true)
+	go client.Write([]byte("\x1b[B\x1bOA\x1b[B\r"))
+
+	it, ok, err := Run(term, "Pick", []Item{{ID: 1, Label: "alice"}, {ID: 4, Label: "carol"}})
+	if err != nil || !ok || it.ID != 4 {
+		t.Fatalf("Run = %v, %v, %v", it, ok, err)
+	}
+}
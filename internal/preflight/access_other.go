@@ -0,0 +1,9 @@
+//go:build !unix
+
+package preflight
+
+// writable cannot be checked without writing on this platform; the BBS
+// reports the problem when it first writes there.
+func writable(dir string) error {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build unix
+
+package preflight
+
+import "syscall"
+
+// writable reports whether the BBS user may create files in dir, without
+// creating one.
+func writable(dir string) error {
+	const wOK = 0x2 // W_OK
+	return syscall.Access(dir, wOK)
+}
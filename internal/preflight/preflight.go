@@ -0,0 +1,176 @@
+package preflight
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Features that depend on external binaries or on-disk layout.
+const (
+	FeatureDoors     = "doors"
+	FeatureTransfers = "transfers"
+)
+
+// Options describes what to verify.
+type Options struct {
+	DosemuPath string
+	SexyzPath  string
+	DriveC     string
+	DoorsTmp   string // per-node dosemu session dirs
+	UploadTmp  string // upload staging dirs
+}
+
+// Check is the result of a single verification.
+type Check struct {
+	Name    string
+	Feature string // feature that will fail while this check fails
+	OK      bool
+	Detail  string // what was found
+	Fix     string // what the sysop should do when the check fails
+}
+
+// Report holds the results of all checks.
+type Report struct {
+	Checks []Check
+}
+
+// Run verifies the door and transfer prerequisites. It never fails and
+// changes nothing on disk; problems are recorded in the report, and the
+// features they affect keep failing until the sysop fixes them.
+func Run(opts Options) *Report {
+	r := &Report{}
+
+	if path, err := exec.LookPath(opts.DosemuPath); err != nil {
+		r.add(Check{Name: "dosemu2", Feature: FeatureDoors,
+			Detail: fmt.Sprintf("not found at %s", opts.DosemuPath),
+			Fix:    "install dosemu2 or set doors.dosemu_path"})
+	} else {
+		r.add(Check{Name: "dosemu2", Feature: FeatureDoors, OK: true, Detail: path})
+	}
+
+	if info, err := os.Stat(opts.DriveC); err != nil || !info.IsDir() {
+		r.add(Check{Name: "drive C", Feature: FeatureDoors,
+			Detail: fmt.Sprintf("%s is not a directory", opts.DriveC),
+			Fix:    "create the directory or set doors.drive_c"})
+	} else {
+		r.add(Check{Name: "drive C", Feature: FeatureDoors, OK: true, Detail: opts.DriveC})
+	}
+
+	bnu := filepath.Join(opts.DriveC, "BNU", "BNU.COM")
+	if info, err := os.Stat(bnu); err != nil || info.IsDir() {
+		r.add(Check{Name: "BNU FOSSIL driver", Feature: FeatureDoors,
+			Detail: fmt.Sprintf("%s missing", bnu),
+			Fix:    `copy BNU.COM to C:\BNU\ (see doors/README.md)`})
+	} else {
+		r.add(Check{Name: "BNU FOSSIL driver", Feature: FeatureDoors, OK: true, Detail: bnu})
+	}
+
+	r.add(writableCheck("drop file dir", FeatureDoors, filepath.Join(opts.DriveC, "NODES")))
+	r.add(writableCheck("door temp dir", FeatureDoors, opts.DoorsTmp))
+
+	if info, err := os.Stat(opts.SexyzPath); err != nil || info.IsDir() || info.Size() == 0 {
+		r.add(Check{Name: "SEXYZ", Feature: FeatureTransfers,
+			Detail: fmt.Sprintf("not found at %s", opts.SexyzPath),
+			Fix:    "install SEXYZ or set transfer.sexyz_path"})
+	} else if info.Mode()&0111 == 0 {
+		r.add(Check{Name: "SEXYZ", Feature: FeatureTransfers,
+			Detail: fmt.Sprintf("%s is not executable", opts.SexyzPath),
+			Fix:    fmt.Sprintf("chmod +x %s", opts.SexyzPath)})
+	} else {
+		r.add(Check{Name: "SEXYZ", Feature: FeatureTransfers, OK: true, Detail: opts.SexyzPath})
+	}
+
+	r.add(writableCheck("upload staging dir", FeatureTransfers, opts.UploadTmp))
+
+	return r
+}
+
+// writableCheck verifies the BBS can write in dir. The BBS creates its
+// directories when it first needs them, so a missing dir passes when the
+// nearest directory above it that exists is writable.
+func writableCheck(name, feature, dir string) Check {
+	c := Check{Name: name, Feature: feature, Detail: dir}
+	at := filepath.Clean(dir)
+	for {
+		info, err := os.Stat(at)
+		if err == nil {
+			if !info.IsDir() {
+				c.Detail = fmt.Sprintf("%s is not a directory", at)
+				c.Fix = "remove it or choose another path"
+				return c
+			}
+			break
+		}
+		parent := filepath.Dir(at)
+		if !os.IsNotExist(err) || parent == at {
+			c.Detail = fmt.Sprintf("cannot check %s: %v", dir, err)
+			c.Fix = "fix permissions for the BBS user"
+			return c
+		}
+		at = parent
+	}
+	if err := writable(at); err != nil {
+		c.Detail = fmt.Sprintf("%s is not writable: %v", at, err)
+		c.Fix = "fix permissions for the BBS user"
+		return c
+	}
+	if at != filepath.Clean(dir) {
+		c.Detail = fmt.Sprintf("%s (created on first use)", dir)
+	}
+	c.OK = true
+	return c
+}
+
+func (r *Report) add(c Check) {
+	r.Checks = append(r.Checks, c)
+}
+
+// Available reports whether every check for a feature passed.
+func (r *Report) Available(feature string) bool {
+	for _, c := range r.Checks {
+		if c.Feature == feature && !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// OK reports whether every check passed.
+func (r *Report) OK() bool {
+	for _, c := range r.Checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// Lines formats the report as human-readable lines: one per check, with a
+// fix hint for failures, followed by a per-feature summary. Nothing is
+// switched off by a failure, so the summary warns rather than disables.
+func (r *Report) Lines() []string {
+	var lines []string
+	for _, c := range r.Checks {
+		if c.OK {
+			lines = append(lines, fmt.Sprintf("[ OK ] %s: %s", c.Name, c.Detail))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("[FAIL] %s: %s -> %s", c.Name, c.Detail, c.Fix))
+	}
+	for _, feature := range []string{FeatureDoors, FeatureTransfers} {
+		state := "ready"
+		if !r.Available(feature) {
+			state = "WARNING: will fail until the problems above are fixed"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", feature, state))
+	}
+	return lines
+}
+
+// String returns the report as newline-separated lines.
+func (r *Report) String() string {
+	return strings.Join(r.Lines(), "\n")
+}
@@ -0,0 +1,68 @@
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunReportsMissingPieces(t *testing.T) {
+	root := t.TempDir()
+	driveC := filepath.Join(root, "drive_c")
+	if err := os.MkdirAll(filepath.Join(driveC, "BNU"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(driveC, "BNU", "BNU.COM"), []byte{0xc3}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sexyz := filepath.Join(root, "sexyz")
+	if err := os.WriteFile(sexyz, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := Run(Options{
+		DosemuPath: filepath.Join(root, "no-dosemu"),
+		SexyzPath:  sexyz,
+		DriveC:     driveC,
+		DoorsTmp:   filepath.Join(root, "doors_tmp"),
+		UploadTmp:  filepath.Join(root, "upload_tmp"),
+	})
+
+	if r.Available(FeatureDoors) {
+		t.Errorf("doors should be disabled without dosemu2")
+	}
+	if r.Available(FeatureTransfers) {
+		t.Errorf("transfers should be disabled with a non-executable SEXYZ")
+	}
+	if _, err := os.Stat(filepath.Join(driveC, "NODES")); !os.IsNotExist(err) {
+		t.Errorf("preflight created the drop file dir: %v", err)
+	}
+
+	out := r.String()
+	for _, want := range []string{"[FAIL] dosemu2", "[ OK ] BNU FOSSIL driver", "chmod +x " + sexyz, "doors: WARNING",
+		"NODES (created on first use)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+
+	if err := os.Chmod(sexyz, 0755); err != nil {
+		t.Fatal(err)
+	}
+	r = Run(Options{DosemuPath: "/nonexistent", SexyzPath: sexyz, DriveC: driveC,
+		DoorsTmp: filepath.Join(root, "doors_tmp"), UploadTmp: filepath.Join(root, "upload_tmp")})
+	if !r.Available(FeatureTransfers) {
+		t.Errorf("transfers should be enabled:\n%s", r)
+	}
+}
+
+func TestWritableCheckNotADirectory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "doors_tmp")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if c := writableCheck("door temp dir", FeatureDoors, filepath.Join(file, "node1")); c.OK {
+		t.Errorf("check passed below a file: %+v", c)
+	}
+}
@@ -0,0 +1,109 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PlayOptions controls how a recording is played back.
+type PlayOptions struct {
+	Speed   float64       // 2 plays twice as fast; 0 means 1
+	MaxIdle time.Duration // longest pause between events, 0 = as recorded
+
+	// Wait pauses between events and reports whether to go on, so the
+	// viewer can stop playback with a key. Nil sleeps.
+	Wait func(d time.Duration) bool
+}
+
+// Play writes a recording to w with its original timing, scaled by
+// opts.Speed. It reports whether the recording played to the end rather
+// than being stopped by opts.Wait.
+func Play(w io.Writer, r io.Reader, opts PlayOptions) (bool, error) {
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+	wait := opts.Wait
+	if wait == nil {
+		wait = func(d time.Duration) bool { time.Sleep(d); return true }
+	}
+
+	last := 0.0
+	return readEvents(r, func(at float64, _ string, out []byte) (bool, error) {
+		delay := time.Duration((at - last) / opts.Speed * float64(time.Second))
+		if opts.MaxIdle > 0 && delay > opts.MaxIdle {
+			delay = opts.MaxIdle
+		}
+		last = at
+		if delay > 0 && !wait(delay) {
+			return false, nil
+		}
+		if out != nil {
+			if _, err := w.Write(out); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+}
+
+// readEvents reads an asciicast v2 recording and calls fn with the time and
+// code of each event and, for output, its bytes ("b" events decoded);
+// other events have nil out. fn returns false to stop early, which
+// readEvents reports by returning false.
+func readEvents(r io.Reader, fn func(at float64, code string, out []byte) (bool, error)) (bool, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	if !sc.Scan() {
+		return false, fmt.Errorf("read recording: %w", scanErr(sc))
+	}
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil || header.Version != 2 {
+		return false, fmt.Errorf("not an asciicast v2 recording")
+	}
+
+	for sc.Scan() {
+		var ev []json.RawMessage
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil || len(ev) != 3 {
+			return false, fmt.Errorf("bad recording event %q", sc.Text())
+		}
+		var at float64
+		var code, data string
+		if json.Unmarshal(ev[0], &at) != nil || json.Unmarshal(ev[1], &code) != nil || json.Unmarshal(ev[2], &data) != nil {
+			return false, fmt.Errorf("bad recording event %q", sc.Text())
+		}
+
+		var out []byte
+		switch code {
+		case "o":
+			out = []byte(data)
+		case "b":
+			b, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return false, fmt.Errorf("bad recording event %q", sc.Text())
+			}
+			out = b
+		}
+		if ok, err := fn(at, code, out); !ok || err != nil {
+			return false, err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return false, fmt.Errorf("read recording: %w", err)
+	}
+	return true, nil
+}
+
+// scanErr is the error that ended a scan, io.ErrUnexpectedEOF when the
+// input was empty.
+func scanErr(sc *bufio.Scanner) error {
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
@@ -0,0 +1,344 @@
+// Package recording keeps what nodes send to their callers, with timing,
+// and plays it back. Recordings use the asciicast v2 format, so
+// `asciinema play` can show them too.
+//
+// A recording is a JSON header line followed by one JSON array per event:
+// [seconds, "o", text] for output, [seconds, "r", "WxH"] for a window
+// size change and [seconds, "b", base64] for output that is not UTF-8,
+// such as CP437 art. Other players skip the "b" events.
+package recording
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Ext is the file extension of recordings.
+const Ext = ".cast"
+
+// timeLayout is the start time in a recording's file name.
+const timeLayout = "20060102-150405.000"
+
+// Store is the directory recordings are kept in. Only the newest Keep
+// recordings, none older than MaxAge, are kept.
+type Store struct {
+	Dir     string
+	Keep    int           // recordings kept, 0 = all
+	MaxSize int64         // bytes per recording, after which it stops; 0 = unlimited
+	MaxAge  time.Duration // recordings are removed this long after they end, 0 = never
+
+	mu     sync.Mutex
+	active map[string]bool // recordings still being written
+}
+
+// NewStore creates a store for recordings in dir.
+func NewStore(dir string, keep int, maxSize int64) *Store {
+	return &Store{Dir: dir, Keep: keep, MaxSize: maxSize}
+}
+
+// Start begins recording a call on node, whose window is width by height.
+func (s *Store) Start(node, width, height int) (*Recorder, error) {
+	if err := os.MkdirAll(s.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("recording dir: %w", err)
+	}
+	start := time.Now()
+	base := fmt.Sprintf("node%d-%s", node, start.Format(timeLayout))
+	path := filepath.Join(s.Dir, base+Ext)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("start recording: %w", err)
+	}
+	s.setActive(base+Ext, true)
+	r := &Recorder{
+		store: s,
+		f:     f,
+		w:     bufio.NewWriter(f),
+		base:  base,
+		start: start,
+	}
+	header, _ := json.Marshal(map[string]any{
+		"version":   2,
+		"width":     width,
+		"height":    height,
+		"timestamp": start.Unix(),
+		"title":     fmt.Sprintf("Node %d", node),
+	})
+	r.line(header)
+	return r, nil
+}
+
+// Info describes a recording in the store.
+type Info struct {
+	Name    string // file name, for Open
+	Node    int
+	User    string // "" when the caller did not log in
+	Started time.Time
+	Ended   time.Time // when the file was last written
+	Size    int64
+}
+
+// setActive records whether a recording is still being written.
+func (s *Store) setActive(name string, active bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active == nil {
+		s.active = make(map[string]bool)
+	}
+	if active {
+		s.active[name] = true
+	} else {
+		delete(s.active, name)
+	}
+}
+
+func (s *Store) isActive(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active[name]
+}
+
+// List returns the finished recordings in the store, newest first. Those
+// past MaxAge are left out even before Prune removes them.
+func (s *Store) List() ([]Info, error) {
+	list, err := s.list()
+	if err != nil || s.MaxAge <= 0 {
+		return list, err
+	}
+	cutoff := time.Now().Add(-s.MaxAge)
+	return slices.DeleteFunc(list, func(info Info) bool { return info.Ended.Before(cutoff) }), nil
+}
+
+// list returns every finished recording, newest first.
+func (s *Store) list() ([]Info, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list recordings: %w", err)
+	}
+	var list []Info
+	for _, e := range entries {
+		info, ok := parseName(e.Name())
+		if !ok || e.IsDir() || s.isActive(e.Name()) {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			info.Ended = fi.ModTime()
+			info.Size = fi.Size()
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Started.Equal(list[j].Started) {
+			return list[i].Started.After(list[j].Started)
+		}
+		return list[i].Node > list[j].Node
+	})
+	return list, nil
+}
+
+// Open opens a recording by the name List gave it.
+func (s *Store) Open(name string) (*os.File, error) {
+	if _, ok := parseName(name); !ok || filepath.Base(name) != name {
+		return nil, fmt.Errorf("no recording %q", name)
+	}
+	f, err := os.Open(filepath.Join(s.Dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+	return f, nil
+}
+
+// Prune removes all but the newest Keep recordings, and those older than
+// MaxAge. It runs whenever a recording ends.
+func (s *Store) Prune() {
+	if s.Keep <= 0 && s.MaxAge <= 0 {
+		return
+	}
+	all, err := s.list()
+	if err != nil {
+		return
+	}
+	kept, err := s.List()
+	if err != nil {
+		return
+	}
+	if s.Keep > 0 && len(kept) > s.Keep {
+		kept = kept[:s.Keep]
+	}
+	keep := make(map[string]bool, len(kept))
+	for _, info := range kept {
+		keep[info.Name] = true
+	}
+	for _, info := range all {
+		if keep[info.Name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.Dir, info.Name)); err != nil {
+			log.Printf("Recording: %v", err)
+		}
+	}
+}
+
+// parseName reads node<N>-<start>[-<user>].cast.
+func parseName(name string) (Info, bool) {
+	rest, ok := strings.CutSuffix(name, Ext)
+	if !ok || !strings.HasPrefix(rest, "node") {
+		return Info{}, false
+	}
+	parts := strings.SplitN(rest[len("node"):], "-", 4)
+	if len(parts) < 3 {
+		return Info{}, false
+	}
+	node, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Info{}, false
+	}
+	started, err := time.ParseInLocation(timeLayout, parts[1]+"-"+parts[2], time.Local)
+	if err != nil {
+		return Info{}, false
+	}
+	info := Info{Name: name, Node: node, Started: started}
+	if len(parts) == 4 {
+		info.User = parts[3]
+	}
+	return info, true
+}
+
+// Recorder writes one call's output to a recording. Use it as the Output
+// of a terminal.Tap.
+type Recorder struct {
+	store *Store
+	base  string // file name without the extension
+	start time.Time
+
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	size    int64
+	partial []byte // start of a UTF-8 sequence the next write completes
+	user    string
+	stopped bool
+}
+
+// Write records output sent to the caller. It never fails, so recording
+// cannot break the call; once MaxSize is reached the rest is dropped.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return len(p), nil
+	}
+	data := append(r.partial, p...)
+	r.partial = nil
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	if utf8.Valid(data[:cut]) {
+		r.partial = append([]byte(nil), data[cut:]...)
+		if cut > 0 {
+			r.event("o", string(data[:cut]))
+		}
+	} else {
+		r.event("b", base64.StdEncoding.EncodeToString(data))
+	}
+	return len(p), nil
+}
+
+// Resize records a change of the caller's window size.
+func (r *Recorder) Resize(width, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stopped {
+		r.event("r", fmt.Sprintf("%dx%d", width, height))
+	}
+}
+
+// SetUser records who the caller logged in as, for the file name.
+func (r *Recorder) SetUser(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.user = name
+}
+
+// Close finishes the recording, names it after the user and prunes old
+// recordings.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	if len(r.partial) > 0 && !r.stopped {
+		r.event("b", base64.StdEncoding.EncodeToString(r.partial))
+	}
+	err := r.w.Flush()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	path := r.f.Name()
+	r.f = nil
+	r.stopped = true
+
+	if user := fileSafe(r.user); user != "" {
+		named := filepath.Join(r.store.Dir, r.base+"-"+user+Ext)
+		if rerr := os.Rename(path, named); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	r.store.setActive(r.base+Ext, false)
+	r.store.Prune()
+	if err != nil {
+		return fmt.Errorf("close recording: %w", err)
+	}
+	return nil
+}
+
+// event writes one event line. The caller holds mu.
+func (r *Recorder) event(code, data string) {
+	secs := time.Since(r.start).Seconds()
+	line, _ := json.Marshal([]any{json.Number(strconv.FormatFloat(secs, 'f', 6, 64)), code, data})
+	r.line(line)
+}
+
+// line writes a line, stopping the recording at MaxSize.
+func (r *Recorder) line(b []byte) {
+	if r.store.MaxSize > 0 && r.size+int64(len(b))+1 > r.store.MaxSize {
+		r.stopped = true
+		log.Printf("Recording %s stopped at %d bytes", r.base, r.size)
+		return
+	}
+	r.w.Write(b)
+	r.w.WriteByte('\n')
+	r.size += int64(len(b)) + 1
+}
+
+// fileSafe keeps the letters, digits, '.' and '_' of a user name.
+func fileSafe(name string) string {
+	return strings.Map(func(c rune) rune {
+		if c < utf8.RuneSelf && (c == '_' || c == '.' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return c
+		}
+		return -1
+	}, name)
+}
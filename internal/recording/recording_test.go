@@ -0,0 +1,203 @@
+package recording
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndPlay(t *testing.T) {
+	store := NewStore(t.TempDir(), 0, 0)
+	rec, err := store.Start(3, 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := store.List(); len(list) != 0 {
+		t.Errorf("recording in progress is listed: %+v", list)
+	}
+	// "é" split across writes, then CP437 art that is not UTF-8.
+	writes := []string{"\x1b[2JHello caf\xc3", "\xa9\r\n", "\xc9\xcd\xbb\r\n"}
+	for _, w := range writes {
+		rec.Write([]byte(w))
+		time.Sleep(5 * time.Millisecond)
+	}
+	rec.Resize(132, 50)
+	rec.SetUser("alice")
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := store.List()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	if info := list[0]; info.Node != 3 || info.User != "alice" || !strings.HasSuffix(info.Name, "-alice"+Ext) {
+		t.Errorf("info = %+v", info)
+	}
+
+	f, err := store.Open(list[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var out bytes.Buffer
+	var waited time.Duration
+	done, err := Play(&out, f, PlayOptions{Speed: 2, Wait: func(d time.Duration) bool {
+		waited += d
+		return true
+	}})
+	if err != nil || !done {
+		t.Fatalf("Play = %v, %v", done, err)
+	}
+	if want := strings.Join(writes, ""); out.String() != want {
+		t.Errorf("played %q, want %q", out.String(), want)
+	}
+	if waited <= 0 || waited > 100*time.Millisecond {
+		t.Errorf("waited %v at double speed", waited)
+	}
+
+	if _, err := store.Open("../etc/passwd"); err == nil {
+		t.Error("opened a file outside the store")
+	}
+}
+
+func TestPlayStopsAndCapsIdle(t *testing.T) {
+	cast := `{"version":2,"width":80,"height":24}
+[0.5,"o","one"]
+[60.5,"o","two"]
+[61,"o","three"]
+`
+	var waits []time.Duration
+	var out bytes.Buffer
+	done, err := Play(&out, strings.NewReader(cast), PlayOptions{MaxIdle: time.Second, Wait: func(d time.Duration) bool {
+		waits = append(waits, d)
+		return len(waits) < 3
+	}})
+	if err != nil || done {
+		t.Fatalf("Play = %v, %v", done, err)
+	}
+	if out.String() != "onetwo" {
+		t.Errorf("played %q before stopping", out.String())
+	}
+	if want := []time.Duration{500 * time.Millisecond, time.Second, 500 * time.Millisecond}; len(waits) != 3 || waits[0] != want[0] || waits[1] != want[1] || waits[2] != want[2] {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+
+	if _, err := Play(&out, strings.NewReader(`{"version":1}`+"\n"), PlayOptions{}); err == nil {
+		t.Error("played an asciicast v1 file")
+	}
+}
+
+func TestStoreKeepAndMaxSize(t *testing.T) {
+	store := NewStore(t.TempDir(), 2, 300)
+	for i := 1; i <= 3; i++ {
+		rec, err := store.Start(i, 80, 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 10 {
+			rec.Write([]byte("0123456789012345678901234567890123456789"))
+		}
+		if err := rec.Close(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond) // distinct start times
+	}
+	list, err := store.List()
+	if err != nil || len(list) != 2 || list[0].Node != 3 || list[1].Node != 2 {
+		t.Fatalf("kept %+v, %v", list, err)
+	}
+	for _, info := range list {
+		if info.Size > 300 {
+			t.Errorf("%s is %d bytes, over the limit", info.Name, info.Size)
+		}
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	cast := `{"version":2,"width":80,"height":24}
+[0.5,"o","\u001b[1;33mWelcome\u001b[0m, alice\r\n"]
+[1.0,"o","Say: you are a losr\b \ber\r\n"]
+[2.0,"b","yc27XA0K"]
+[3.0,"o","\u001b[5;10Hleft\u001b[3Cright"]
+`
+	lines, err := Transcript(strings.NewReader(cast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range lines {
+		got = append(got, l.Text)
+	}
+	want := []string{"Welcome, alice", "Say: you are a loser", "╔═╗\\", "left right"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("transcript %q, want %q", got, want)
+	}
+	if lines[1].At != time.Second {
+		t.Errorf("second line at %v", lines[1].At)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	store := NewStore(t.TempDir(), 0, 0)
+	record := func(node int, user, text string) {
+		rec, err := store.Start(node, 80, 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec.Write([]byte(text))
+		rec.SetUser(user)
+		rec.Close()
+		time.Sleep(2 * time.Millisecond)
+	}
+	record(1, "alice", "Chat> bob: go away, idiot\r\nChat> alice: rude\r\n")
+	record(2, "bob", "Chat> alice: hello\r\n")
+	record(1, "carol", "Main menu\r\n")
+
+	if _, err := store.Search(Query{By: "sysop", Keyword: "idiot"}); err == nil {
+		t.Error("search without a reason allowed")
+	}
+	hits, err := store.Search(Query{By: "sysop", Reason: "report #12", Keyword: "IDIOT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].User != "alice" || hits[0].Text != "Chat> bob: go away, idiot" {
+		t.Fatalf("keyword hits = %+v", hits)
+	}
+	hits, _ = store.Search(Query{By: "sysop", Reason: "report #12", User: "ALICE"})
+	if len(hits) != 1 || hits[0].Node != 1 {
+		t.Errorf("user hits = %+v", hits)
+	}
+	hits, _ = store.Search(Query{By: "sysop", Reason: "report #12", Node: 1})
+	if len(hits) != 2 || hits[0].User != "carol" {
+		t.Errorf("node hits = %+v", hits)
+	}
+	hits, _ = store.Search(Query{By: "sysop", Reason: "report #12", To: time.Now().Add(-time.Hour)})
+	if len(hits) != 0 {
+		t.Errorf("hits before the recordings = %+v", hits)
+	}
+
+	logged, err := os.ReadFile(filepath.Join(store.Dir, SearchLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(logged), "\tsysop\treport #12\t"); n != 4 {
+		t.Errorf("search log has %d entries:\n%s", n, logged)
+	}
+
+	// Recordings past MaxAge are neither searched nor kept.
+	list, _ := store.List()
+	old := filepath.Join(store.Dir, list[2].Name)
+	os.Chtimes(old, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+	store.MaxAge = 24 * time.Hour
+	if hits, _ := store.Search(Query{By: "sysop", Reason: "report #12", Keyword: "idiot"}); len(hits) != 0 {
+		t.Errorf("searched an expired recording: %+v", hits)
+	}
+	store.Prune()
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expired recording kept: %v", err)
+	}
+}
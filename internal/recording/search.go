@@ -0,0 +1,247 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// SearchLog is the file in the store's directory every search is logged
+// to, so searches can be reviewed.
+const SearchLog = "searches.log"
+
+// defaultLimit is how many hits Search returns when the query sets none.
+const defaultLimit = 100
+
+// Query selects recordings to search. Recordings are picked by who was
+// on, which node and when from their names; Keyword then looks inside.
+type Query struct {
+	User    string    // user name, any case; "" = any
+	Node    int       // 0 = any
+	From    time.Time // recordings still running at or after this; zero = any
+	To      time.Time // recordings started before this; zero = any
+	Keyword string    // text the caller was shown, any case; "" = every recording
+	Limit   int       // hits returned, 0 = 100
+
+	// Searches look into other people's sessions, so each one says who
+	// ran it and why, and is logged.
+	By     string
+	Reason string
+}
+
+func (q Query) String() string {
+	var parts []string
+	if q.User != "" {
+		parts = append(parts, "user="+q.User)
+	}
+	if q.Node != 0 {
+		parts = append(parts, fmt.Sprintf("node=%d", q.Node))
+	}
+	if !q.From.IsZero() {
+		parts = append(parts, "from="+q.From.Format(time.DateTime))
+	}
+	if !q.To.IsZero() {
+		parts = append(parts, "to="+q.To.Format(time.DateTime))
+	}
+	if q.Keyword != "" {
+		parts = append(parts, fmt.Sprintf("keyword=%q", q.Keyword))
+	}
+	if len(parts) == 0 {
+		return "everything"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Hit is a recording that matched a search.
+type Hit struct {
+	Info
+	At   time.Duration // where the keyword was shown; 0 without a keyword
+	Text string        // the line it was on
+}
+
+// Search finds recordings matching q, newest first, and logs the search.
+func (s *Store) Search(q Query) ([]Hit, error) {
+	if strings.TrimSpace(q.By) == "" || strings.TrimSpace(q.Reason) == "" {
+		return nil, errors.New("searching recordings needs who is searching and why")
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultLimit
+	}
+	if err := s.logSearch(q); err != nil {
+		return nil, err
+	}
+
+	list, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	keyword := strings.ToLower(q.Keyword)
+	var hits []Hit
+	for _, info := range list {
+		if len(hits) >= q.Limit {
+			break
+		}
+		if q.User != "" && !strings.EqualFold(info.User, fileSafe(q.User)) ||
+			q.Node != 0 && info.Node != q.Node ||
+			!q.From.IsZero() && info.Ended.Before(q.From) ||
+			!q.To.IsZero() && !info.Started.Before(q.To) {
+			continue
+		}
+		if keyword == "" {
+			hits = append(hits, Hit{Info: info})
+			continue
+		}
+		lines, err := s.transcript(info.Name)
+		if err != nil {
+			log.Printf("Recording search: %s: %v", info.Name, err)
+			continue
+		}
+		for _, l := range lines {
+			if strings.Contains(strings.ToLower(l.Text), keyword) {
+				hits = append(hits, Hit{Info: info, At: l.At, Text: l.Text})
+				if len(hits) >= q.Limit {
+					break
+				}
+			}
+		}
+	}
+	return hits, nil
+}
+
+// logSearch records a search in the log file and the server log.
+func (s *Store) logSearch(q Query) error {
+	log.Printf("Recording search by %s (%s): %s", q.By, q.Reason, q)
+	if err := os.MkdirAll(s.Dir, 0o750); err != nil {
+		return fmt.Errorf("search log: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(s.Dir, SearchLog), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("search log: %w", err)
+	}
+	defer f.Close()
+	clean := func(s string) string { return strings.Join(strings.Fields(s), " ") }
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\t%s\n", time.Now().Format(time.RFC3339), clean(q.By), clean(q.Reason), q)
+	if err != nil {
+		return fmt.Errorf("search log: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) transcript(name string) ([]Line, error) {
+	f, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Transcript(f)
+}
+
+// Line is a line of text a caller was shown.
+type Line struct {
+	At   time.Duration // when the line began, from the start of the call
+	Text string
+}
+
+// Transcript returns the text of a recording a line at a time, without
+// escape sequences. Cursor positioning ends a line, so art and full-screen
+// displays come out roughly as they looked.
+func Transcript(r io.Reader) ([]Line, error) {
+	t := &transcriber{}
+	_, err := readEvents(r, func(at float64, code string, out []byte) (bool, error) {
+		t.at = time.Duration(at * float64(time.Second))
+		switch code {
+		case "o":
+			for _, c := range string(out) {
+				t.put(c)
+			}
+		case "b":
+			for _, b := range out {
+				if b < utf8.RuneSelf {
+					t.put(rune(b))
+				} else {
+					t.put(terminal.CP437[b])
+				}
+			}
+		}
+		return true, nil
+	})
+	t.endLine()
+	return t.lines, err
+}
+
+// transcriber turns terminal output into lines of text.
+type transcriber struct {
+	at    time.Duration
+	lines []Line
+	cur   []rune
+	start time.Duration
+	state int // 0 text, 1 after ESC, 2 in CSI, 3 in OSC
+}
+
+func (t *transcriber) put(c rune) {
+	switch t.state {
+	case 1:
+		switch c {
+		case '[':
+			t.state = 2
+		case ']':
+			t.state = 3
+		default:
+			t.state = 0
+		}
+		return
+	case 2:
+		if c >= 0x40 && c <= 0x7e {
+			t.state = 0
+			switch c {
+			case 'H', 'f', 'J', 'A', 'B':
+				t.endLine()
+			case 'C':
+				t.text(' ')
+			}
+		}
+		return
+	case 3:
+		if c == 7 || c == 0x1b {
+			t.state = 0
+		}
+		return
+	}
+
+	switch {
+	case c == 0x1b:
+		t.state = 1
+	case c == '\r' || c == '\n':
+		t.endLine()
+	case c == '\b':
+		if len(t.cur) > 0 {
+			t.cur = t.cur[:len(t.cur)-1]
+		}
+	case c == '\t':
+		t.text(' ')
+	case c >= ' ':
+		t.text(c)
+	}
+}
+
+func (t *transcriber) text(c rune) {
+	if len(t.cur) == 0 {
+		t.start = t.at
+	}
+	t.cur = append(t.cur, c)
+}
+
+func (t *transcriber) endLine() {
+	if text := strings.TrimSpace(string(t.cur)); text != "" {
+		t.lines = append(t.lines, Line{At: t.start, Text: text})
+	}
+	t.cur = t.cur[:0]
+}
@@ -0,0 +1,154 @@
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job is a task run once a day at a fixed local time, or at a fixed
+// interval when Every is set.
+type Job struct {
+	Name   string
+	Hour   int
+	Minute int
+	Every  time.Duration
+	Run    func() error
+}
+
+// Scheduler runs maintenance jobs such as the nightly message purge.
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs []Job
+	runs map[string]Run // last run of each job, by name
+}
+
+// Run is how a job last went.
+type Run struct {
+	Started  time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// New creates an empty scheduler.
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Daily registers fn to run every day at hour:minute local time. Jobs
+// scheduled for the same minute run in registration order.
+func (s *Scheduler) Daily(name string, hour, minute int, fn func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, Job{Name: name, Hour: hour, Minute: minute, Run: fn})
+}
+
+// Every registers fn to run at every multiple of d since the zero time,
+// e.g. on the quarter hour for 15 minutes.
+func (s *Scheduler) Every(name string, d time.Duration, fn func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, Job{Name: name, Every: d, Run: fn})
+}
+
+// Jobs returns the registered jobs.
+func (s *Scheduler) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Job(nil), s.jobs...)
+}
+
+// Run waits for each job's next start time and runs it until stop is closed.
+// Errors are logged; a failing job is retried at its next start time.
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	for {
+		now := time.Now()
+		jobs := s.Jobs()
+		if len(jobs) == 0 {
+			return
+		}
+		next := jobs[0].Next(now)
+		for _, j := range jobs[1:] {
+			if t := j.Next(now); t.Before(next) {
+				next = t
+			}
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		for _, j := range jobs {
+			if j.Next(now).Equal(next) {
+				s.runJob(j)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) runJob(j Job) {
+	start := time.Now()
+	err := j.Run()
+	s.mu.Lock()
+	if s.runs == nil {
+		s.runs = make(map[string]Run)
+	}
+	s.runs[j.Name] = Run{Started: start, Duration: time.Since(start), Err: err}
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("Schedule: %s failed: %v", j.Name, err)
+		return
+	}
+	log.Printf("Schedule: %s finished in %s", j.Name, time.Since(start).Round(time.Millisecond))
+}
+
+// LastRun returns how a job last went, false when it has not run since
+// startup.
+func (s *Scheduler) LastRun(name string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.runs[name]
+	return r, ok
+}
+
+// Next returns the first time after now at which the job is due.
+func (j Job) Next(now time.Time) time.Time {
+	if j.Every > 0 {
+		return now.Truncate(j.Every).Add(j.Every)
+	}
+	t := time.Date(now.Year(), now.Month(), now.Day(), j.Hour, j.Minute, 0, 0, now.Location())
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// ParseClock parses a "HH:MM" time of day.
+func ParseClock(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time of day %q (want HH:MM)", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// Describe lists the jobs and their next start times, soonest first.
+func (s *Scheduler) Describe(now time.Time) []string {
+	jobs := s.Jobs()
+	sort.SliceStable(jobs, func(a, b int) bool { return jobs[a].Next(now).Before(jobs[b].Next(now)) })
+	lines := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		when := fmt.Sprintf("daily at %02d:%02d", j.Hour, j.Minute)
+		if j.Every > 0 {
+			when = "every " + j.Every.String()
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s, next %s", j.Name, when, j.Next(now).Format("2006-01-02 15:04")))
+	}
+	return lines
+}
@@ -0,0 +1,67 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobNext(t *testing.T) {
+	j := Job{Hour: 3, Minute: 30}
+	loc := time.UTC
+
+	cases := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2025, 1, 1, 1, 0, 0, 0, loc), time.Date(2025, 1, 1, 3, 30, 0, 0, loc)},
+		{time.Date(2025, 1, 1, 3, 30, 0, 0, loc), time.Date(2025, 1, 2, 3, 30, 0, 0, loc)},
+		{time.Date(2025, 12, 31, 23, 0, 0, 0, loc), time.Date(2026, 1, 1, 3, 30, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		if got := j.Next(c.now); !got.Equal(c.want) {
+			t.Errorf("Next(%s) = %s, want %s", c.now, got, c.want)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	if h, m, err := ParseClock("04:15"); err != nil || h != 4 || m != 15 {
+		t.Fatalf("ParseClock(04:15) = %d, %d, %v", h, m, err)
+	}
+	for _, bad := range []string{"", "25:00", "4pm", "12:60"} {
+		if _, _, err := ParseClock(bad); err == nil {
+			t.Errorf("ParseClock(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestJobNextEvery(t *testing.T) {
+	j := Job{Every: 15 * time.Minute}
+	loc := time.UTC
+
+	cases := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2025, 1, 1, 1, 0, 0, 0, loc), time.Date(2025, 1, 1, 1, 15, 0, 0, loc)},
+		{time.Date(2025, 1, 1, 1, 7, 30, 0, loc), time.Date(2025, 1, 1, 1, 15, 0, 0, loc)},
+		{time.Date(2025, 1, 1, 23, 59, 0, 0, loc), time.Date(2025, 1, 2, 0, 0, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		if got := j.Next(c.now); !got.Equal(c.want) {
+			t.Errorf("Next(%s) = %s, want %s", c.now, got, c.want)
+		}
+	}
+}
+
+func TestLastRun(t *testing.T) {
+	s := New()
+	fail := errors.New("disk full")
+	s.Every("flaky", time.Minute, func() error { return fail })
+	if _, ok := s.LastRun("flaky"); ok {
+		t.Fatal("job ran before it was started")
+	}
+	s.runJob(s.Jobs()[0])
+	if r, ok := s.LastRun("flaky"); !ok || r.Err != fail || r.Started.IsZero() {
+		t.Errorf("last run = %+v, %v", r, ok)
+	}
+}
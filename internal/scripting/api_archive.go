@@ -0,0 +1,129 @@
+package scripting
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/notepid/twilight_bbs/internal/archive"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// archivePath returns the disk path of a file entry the caller may
+// download, for looking inside it.
+func (api *FileAPI) archivePath(fileID int) (string, error) {
+	u := api.session.User()
+	if u == nil {
+		return "", errors.New("not logged in")
+	}
+	e, err := api.repo.GetFile(fileID)
+	if err != nil {
+		return "", errors.New("file not found")
+	}
+	a, err := api.repo.GetArea(e.AreaID)
+	if err != nil {
+		return "", errors.New("file not found")
+	}
+	if u.SecurityLevel < a.DownloadLevel {
+		return "", errors.New("access denied")
+	}
+	return filepath.Join(a.DiskPath, e.Filename), nil
+}
+
+// luaViewArchive lists the members of an archive in a file area:
+// files.view_archive(file_id) -> members or nil, err.
+func (api *FileAPI) luaViewArchive(L *lua.LState) int {
+	path, err := api.archivePath(L.CheckInt(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	members, err := archive.List(path)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(archiveError(err)))
+		return 2
+	}
+
+	tbl := L.NewTable()
+	for i, m := range members {
+		t := L.NewTable()
+		t.RawSetString("name", lua.LString(m.Name))
+		t.RawSetString("size", lua.LNumber(m.Size))
+		t.RawSetString("size_str", lua.LString(formatSize(m.Size)))
+		t.RawSetString("packed", lua.LNumber(m.Packed))
+		t.RawSetString("method", lua.LString(m.Method))
+		t.RawSetString("dir", lua.LBool(m.Dir))
+		if !m.Modified.IsZero() {
+			t.RawSetString("date", lua.LString(m.Modified.Format("2006-01-02 15:04")))
+		}
+		tbl.RawSetInt(i+1, t)
+	}
+	L.Push(tbl)
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaExtractMember extracts one member of an archive to a temp file for
+// reading online: files.extract_member(file_id, name) -> path or nil, err.
+// The previous extraction is removed.
+func (api *FileAPI) luaExtractMember(L *lua.LState) int {
+	fileID := L.CheckInt(1)
+	name := L.CheckString(2)
+	if api.ExtractDir == "" {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("archive viewing is not available"))
+		return 2
+	}
+	path, err := api.archivePath(fileID)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if err := os.MkdirAll(api.ExtractDir, 0755); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	api.Cleanup()
+	out, err := archive.Extract(path, name, api.ExtractDir)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(archiveError(err)))
+		return 2
+	}
+	api.extracted = filepath.Dir(out)
+	L.Push(lua.LString(out))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// Cleanup removes the last member extracted by files.extract_member.
+func (api *FileAPI) Cleanup() {
+	if api.extracted != "" {
+		os.RemoveAll(api.extracted)
+		api.extracted = ""
+	}
+}
+
+// archiveError turns an archive error into a message for callers.
+func archiveError(err error) string {
+	switch {
+	case errors.Is(err, archive.ErrFormat):
+		return "not an archive"
+	case errors.Is(err, archive.ErrMethod):
+		return "compression method not supported"
+	case errors.Is(err, archive.ErrNotFound):
+		return "no such file in archive"
+	case errors.Is(err, archive.ErrTooLarge):
+		return "file too large to view"
+	case errors.Is(err, archive.ErrChecksum):
+		return "archive is damaged"
+	case errors.Is(err, os.ErrNotExist):
+		return "file is missing from disk"
+	}
+	return "cannot read archive"
+}
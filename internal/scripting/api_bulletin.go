@@ -0,0 +1,90 @@
+package scripting
+
+import (
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/bulletin"
+	"github.com/notepid/twilight_bbs/internal/session"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// BulletinAPI exposes sysop bulletins to Lua.
+type BulletinAPI struct {
+	repo    *bulletin.Repo
+	session *session.Session
+}
+
+// NewBulletinAPI creates a Lua bulletin API.
+func NewBulletinAPI(repo *bulletin.Repo, sess *session.Session) *BulletinAPI {
+	return &BulletinAPI{repo: repo, session: sess}
+}
+
+// Register installs bulletin functions in the Lua state.
+func (api *BulletinAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+
+	mod.RawSetString("list", L.NewFunction(api.luaList))
+	mod.RawSetString("unseen", L.NewFunction(api.luaUnseen))
+	mod.RawSetString("mark_seen", L.NewFunction(api.luaMarkSeen))
+
+	L.SetGlobal("bulletins", mod)
+}
+
+func (api *BulletinAPI) luaList(L *lua.LState) int {
+	list, err := api.repo.Active(time.Now())
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(bulletinsToTable(L, list))
+	L.Push(lua.LNil)
+	return 2
+}
+
+func (api *BulletinAPI) luaUnseen(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	list, err := api.repo.Unseen(u.ID, time.Now())
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(bulletinsToTable(L, list))
+	L.Push(lua.LNil)
+	return 2
+}
+
+func (api *BulletinAPI) luaMarkSeen(L *lua.LState) int {
+	id := L.CheckInt(1)
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	if err := api.repo.MarkSeen(u.ID, id); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+func bulletinsToTable(L *lua.LState, list []*bulletin.Bulletin) *lua.LTable {
+	tbl := L.NewTable()
+	for i, b := range list {
+		t := L.NewTable()
+		t.RawSetString("id", lua.LNumber(b.ID))
+		t.RawSetString("title", lua.LString(b.Title))
+		t.RawSetString("body", lua.LString(b.Body))
+		t.RawSetString("art", lua.LString(b.Art))
+		t.RawSetString("date", lua.LString(b.PublishedAt.Local().Format("2006-01-02")))
+		tbl.RawSetInt(i+1, t)
+	}
+	return tbl
+}
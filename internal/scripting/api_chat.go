@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/notepid/twilight_bbs/internal/chat"
+	"github.com/notepid/twilight_bbs/internal/flood"
 	"github.com/notepid/twilight_bbs/internal/terminal"
 	lua "github.com/yuin/gopher-lua"
 )
@@ -14,6 +15,12 @@ type ChatAPI struct {
 	term     *terminal.Terminal
 	nodeID   int
 	userName func() string
+
+	// Flood limits how often the user may send chat lines
+	Flood FloodFunc
+
+	// DoNotDisturb saves and applies the user's do-not-disturb setting
+	DoNotDisturb func(on bool) error
 }
 
 // NewChatAPI creates a Lua chat API.
@@ -37,6 +44,11 @@ func (api *ChatAPI) Register(L *lua.LState) {
 	mod.RawSetString("leave_room", L.NewFunction(api.luaLeaveRoom))
 	mod.RawSetString("room_members", L.NewFunction(api.luaRoomMembers))
 	mod.RawSetString("send_room", L.NewFunction(api.luaSendRoom))
+	mod.RawSetString("history", L.NewFunction(api.luaHistory))
+	mod.RawSetString("page", L.NewFunction(api.luaPage))
+	mod.RawSetString("paged", L.NewFunction(api.luaPaged))
+	mod.RawSetString("answer", L.NewFunction(api.luaAnswer))
+	mod.RawSetString("set_dnd", L.NewFunction(api.luaSetDND))
 
 	L.SetGlobal("chat", mod)
 }
@@ -52,6 +64,12 @@ func (api *ChatAPI) luaSend(L *lua.LState) int {
 		return 1
 	}
 
+	if msg, retry, refused := flooded(api.Flood, flood.Chat, "chat messages"); refused {
+		L.Push(msg)
+		L.Push(retry)
+		return 2
+	}
+
 	err := api.broker.SendTo(api.nodeID, api.userName(), toNodeID, text)
 	if err != nil {
 		L.Push(lua.LString(err.Error()))
@@ -71,6 +89,12 @@ func (api *ChatAPI) luaBroadcast(L *lua.LState) int {
 		return 1
 	}
 	
+	if msg, retry, refused := flooded(api.Flood, flood.Chat, "chat messages"); refused {
+		L.Push(msg)
+		L.Push(retry)
+		return 2
+	}
+
 	api.broker.Broadcast(api.nodeID, api.userName(), text)
 	return 0
 }
@@ -83,6 +107,8 @@ func (api *ChatAPI) luaOnline(L *lua.LState) int {
 		ut.RawSetString("node_id", lua.LNumber(u.NodeID))
 		ut.RawSetString("name", lua.LString(u.UserName))
 		ut.RawSetString("room", lua.LString(u.Room))
+		ut.RawSetString("dnd", lua.LBool(u.DoNotDisturb))
+		ut.RawSetString("you", lua.LBool(u.NodeID == api.nodeID))
 		tbl.RawSetInt(i+1, ut)
 	}
 	L.Push(tbl)
@@ -91,6 +117,10 @@ func (api *ChatAPI) luaOnline(L *lua.LState) int {
 
 func (api *ChatAPI) luaEnterRoom(L *lua.LState) int {
 	room := L.CheckString(1)
+	if !api.broker.MayEnter(api.nodeID, room) {
+		L.Push(lua.LString(chat.ErrPrivateRoom.Error()))
+		return 1
+	}
 	api.broker.JoinRoom(api.nodeID, room)
 
 	// Announce entry
@@ -130,6 +160,42 @@ func (api *ChatAPI) luaRoomMembers(L *lua.LState) int {
 func (api *ChatAPI) luaSendRoom(L *lua.LState) int {
 	room := L.CheckString(1)
 	text := L.CheckString(2)
-	api.broker.SendToRoom(api.nodeID, api.userName(), room, text)
+	if msg, retry, refused := flooded(api.Flood, flood.Chat, "chat messages"); refused {
+		L.Push(msg)
+		L.Push(retry)
+		return 2
+	}
+	api.broker.Say(api.nodeID, api.userName(), room, text)
 	return 0
 }
+
+// luaHistory handles: chat.history(room[, n]) → {{from, text, at}, ...},
+// err. It returns up to n (default 20) of the room's latest kept lines,
+// oldest first; at is a Unix time.
+func (api *ChatAPI) luaHistory(L *lua.LState) int {
+	room := L.CheckString(1)
+	n := L.OptInt(2, 20)
+	tbl := L.NewTable()
+	h := api.broker.History()
+	if h == nil {
+		L.Push(tbl)
+		L.Push(lua.LNil)
+		return 2
+	}
+	lines, err := h.Recent(room, n)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	for i, l := range lines {
+		row := L.NewTable()
+		row.RawSetString("from", lua.LString(l.From))
+		row.RawSetString("text", lua.LString(l.Text))
+		row.RawSetString("at", lua.LNumber(l.At.Unix()))
+		tbl.RawSetInt(i+1, row)
+	}
+	L.Push(tbl)
+	L.Push(lua.LNil)
+	return 2
+}
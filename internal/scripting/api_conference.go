@@ -0,0 +1,140 @@
+package scripting
+
+import (
+	"github.com/notepid/twilight_bbs/internal/conference"
+	"github.com/notepid/twilight_bbs/internal/session"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// ConferenceAPI exposes conferences to Lua: the caller lists, joins and
+// leaves them, and msg and files follow the current one.
+type ConferenceAPI struct {
+	repo    *conference.Repo
+	session *session.Session
+}
+
+// NewConferenceAPI creates a Lua conference API.
+func NewConferenceAPI(repo *conference.Repo, sess *session.Session) *ConferenceAPI {
+	return &ConferenceAPI{repo: repo, session: sess}
+}
+
+// Register installs the conf module in the Lua state.
+func (api *ConferenceAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+	mod.RawSetString("list", L.NewFunction(api.luaList))
+	mod.RawSetString("current", L.NewFunction(api.luaCurrent))
+	mod.RawSetString("join", L.NewFunction(api.luaJoin))
+	mod.RawSetString("leave", L.NewFunction(api.luaLeave))
+	L.SetGlobal("conf", mod)
+}
+
+// Joined returns the IDs of the conferences the caller belongs to.
+func (api *ConferenceAPI) Joined() ([]int, error) {
+	u := api.session.User()
+	if u == nil {
+		return []int{conference.Main}, nil
+	}
+	confs, err := api.repo.List(u)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, c := range confs {
+		if c.Joined {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids, nil
+}
+
+// luaList handles: conf.list() → table|nil, err. It lists the conferences
+// the caller may join.
+func (api *ConferenceAPI) luaList(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	confs, err := api.repo.List(u)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	tbl := L.NewTable()
+	for _, c := range confs {
+		t := confToTable(L, c)
+		t.RawSetString("current", lua.LBool(c.ID == u.Conference))
+		tbl.Append(t)
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// luaCurrent handles: conf.current() → table|nil, err
+func (api *ConferenceAPI) luaCurrent(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	c, err := api.repo.Current(u)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(confToTable(L, c))
+	return 1
+}
+
+// luaJoin handles: conf.join(id) → table|nil, err. The conference becomes
+// the caller's current one.
+func (api *ConferenceAPI) luaJoin(L *lua.LState) int {
+	id := L.CheckInt(1)
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	c, err := api.repo.Join(u, id)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(confToTable(L, c))
+	return 1
+}
+
+// luaLeave handles: conf.leave(id) → err
+func (api *ConferenceAPI) luaLeave(L *lua.LState) int {
+	id := L.CheckInt(1)
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	if err := api.repo.Leave(u, id); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+func confToTable(L *lua.LState, c *conference.Conference) *lua.LTable {
+	tbl := L.NewTable()
+	tbl.RawSetString("id", lua.LNumber(c.ID))
+	tbl.RawSetString("name", lua.LString(c.Name))
+	tbl.RawSetString("description", lua.LString(c.Description))
+	tbl.RawSetString("join_level", lua.LNumber(c.JoinLevel))
+	tbl.RawSetString("flags", lua.LString(c.Flags))
+	tbl.RawSetString("message_areas", lua.LNumber(c.MsgAreas))
+	tbl.RawSetString("file_areas", lua.LNumber(c.FileAreas))
+	tbl.RawSetString("joined", lua.LBool(c.Joined))
+	return tbl
+}
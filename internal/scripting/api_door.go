@@ -1,35 +1,55 @@
 package scripting
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/notepid/twilight_bbs/internal/door"
-	"github.com/notepid/twilight_bbs/internal/user"
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/terminal"
 	lua "github.com/yuin/gopher-lua"
 )
 
 // DoorAPI exposes door launching functions to Lua.
 type DoorAPI struct {
-	launcher    *door.Launcher
-	currentUser func() *user.User
-	termSize    func() (width, height int)
-	nodeID      int
-	stdin       io.Reader
-	stdout      io.Writer
+	launcher *door.Launcher
+	session  *session.Session
+	size     terminal.SizeProvider
+	nodeID   int
+	stdin    io.Reader
+	stdout   io.Writer
+
+	// Publish announces door launches on the event bus
+	Publish PublishFunc
+
+	// Stats records launches and enforces daily allowances; nil = neither
+	Stats *door.StatsRepo
+
+	// Suspend pauses the engine while the door has the terminal
+	Suspend SuspendFunc
 }
 
+// defaultDoorMinutes is the time left written to drop files when neither
+// the call nor the door has a limit.
+const defaultDoorMinutes = 60
+
+// defaultTopDays is the window door.top and door.playtime cover by default.
+const defaultTopDays = 30
+
 // NewDoorAPI creates a Lua door API.
-func NewDoorAPI(launcher *door.Launcher, currentUser func() *user.User, termSize func() (int, int), nodeID int, stdin io.Reader, stdout io.Writer) *DoorAPI {
+func NewDoorAPI(launcher *door.Launcher, sess *session.Session, size terminal.SizeProvider, nodeID int, stdin io.Reader, stdout io.Writer) *DoorAPI {
 	return &DoorAPI{
-		launcher:    launcher,
-		currentUser: currentUser,
-		termSize:    termSize,
-		nodeID:      nodeID,
-		stdin:       stdin,
-		stdout:      stdout,
+		launcher: launcher,
+		session:  sess,
+		size:     size,
+		nodeID:   nodeID,
+		stdin:    stdin,
+		stdout:   stdout,
 	}
 }
 
@@ -39,58 +59,108 @@ func (api *DoorAPI) Register(L *lua.LState) {
 
 	mod.RawSetString("launch", L.NewFunction(api.luaLaunch))
 	mod.RawSetString("available", L.NewFunction(api.luaAvailable))
+	mod.RawSetString("top", L.NewFunction(api.luaTop))
+	mod.RawSetString("playtime", L.NewFunction(api.luaPlaytime))
+	mod.RawSetString("time_left", L.NewFunction(api.luaTimeLeft))
 
 	L.SetGlobal("door", mod)
 }
 
+// luaLaunch handles: door.launch(cfgTable) → err
 func (api *DoorAPI) luaLaunch(L *lua.LState) int {
-	u := api.currentUser()
-	if u == nil {
-		L.Push(lua.LString("not logged in"))
+	cfg, err := parseDoorConfigFromLua(L.CheckTable(1))
+	if err == nil {
+		err = api.Launch(cfg)
+	}
+	if err != nil {
+		L.Push(lua.LString(err.Error()))
 		return 1
 	}
+	L.Push(lua.LNil)
+	return 1
+}
 
-	if !api.launcher.Available() {
-		L.Push(lua.LString("dosemu2 is not installed"))
-		return 1
+// Launch runs a door for the logged-in caller, with the engine suspended
+// until it exits. door.launch and node:launch_door both end up here.
+func (api *DoorAPI) Launch(cfg door.Config) error {
+	u := api.session.User()
+	if u == nil {
+		return errors.New("not logged in")
 	}
 
-	// door.launch(cfgTable)
-	cfgTable := L.CheckTable(1)
-	cfg, err := parseDoorConfigFromLua(cfgTable)
-	if err != nil {
-		L.Push(lua.LString(err.Error()))
-		return 1
+	if !api.launcher.Available() {
+		return errors.New("dosemu2 is not installed")
 	}
 
 	if u.SecurityLevel < cfg.SecurityLevel {
-		L.Push(lua.LString("insufficient security level"))
-		return 1
+		return errors.New("insufficient security level")
 	}
 
-	termW, termH := api.termSize()
+	timeLeft := defaultDoorMinutes * time.Minute
+	if left, ok := api.session.TimeLeft(); ok {
+		timeLeft = left
+	}
+	var maxRuntime time.Duration
+	if allowance, ok, err := api.allowanceLeft(u.ID, cfg.Name, cfg.DailyMinutes); err != nil {
+		log.Printf("Node %d: door allowance for %s: %v", api.nodeID, cfg.Name, err)
+	} else if ok {
+		if allowance <= 0 {
+			return fmt.Errorf("your time in %s is used up for today", cfg.Name)
+		}
+		maxRuntime = allowance
+		timeLeft = min(timeLeft, allowance)
+	}
+
+	defer suspend(api.Suspend, api.session, session.BusyDoor)()
+
+	termW, termH := api.size.Size()
 	session := &door.Session{
 		DoorConfig:   &cfg,
 		User:         u,
 		NodeID:       api.nodeID,
-		TimeLeftMins: 60,
+		TimeLeftMins: max(int(timeLeft/time.Minute), 1),
+		MaxRuntime:   maxRuntime,
 		ComPort:      1,
 		BaudRate:     115200,
 		DosemuPath:   api.launcher.DosemuPath,
 		DriveCPath:   api.launcher.DriveCPath,
 		TermWidth:    termW,
 		TermHeight:   termH,
+		WatchSize:    api.size.WatchSize,
 	}
 
 	log.Printf("Node %d launching door: %s", api.nodeID, cfg.Name)
+	if api.Publish != nil {
+		api.Publish(event.DoorLaunch, cfg.Name)
+	}
 
-	if err := api.launcher.Launch(session, api.stdin, api.stdout); err != nil {
-		L.Push(lua.LString(fmt.Sprintf("door error: %v", err)))
-		return 1
+	// Doors run at full speed even when the caller emulates a modem.
+	if t, ok := api.stdout.(interface{ SuspendBaud() func() }); ok {
+		defer t.SuspendBaud()()
 	}
 
-	L.Push(lua.LNil)
-	return 1
+	stdout := api.stdout
+	if api.launcher.TranslateOutput {
+		var flush func() error
+		stdout, flush = terminal.DoorWriter(api.stdout)
+		defer flush()
+	}
+
+	started := time.Now()
+	err := api.launcher.Launch(session, api.stdin, stdout)
+	api.record(&door.Play{
+		Door:       cfg.Name,
+		UserID:     u.ID,
+		Username:   u.Username,
+		NodeID:     api.nodeID,
+		StartedAt:  started,
+		EndedAt:    time.Now(),
+		ExitStatus: exitStatus(session, err),
+	})
+	if err != nil {
+		return fmt.Errorf("door error: %v", err)
+	}
+	return nil
 }
 
 func (api *DoorAPI) luaAvailable(L *lua.LState) int {
@@ -98,6 +168,118 @@ func (api *DoorAPI) luaAvailable(L *lua.LState) int {
 	return 1
 }
 
+// allowanceLeft returns what is left of a user's daily time in a door and
+// true, or false when the door has no allowance or nothing is recorded.
+func (api *DoorAPI) allowanceLeft(userID int, name string, dailyMinutes int) (time.Duration, bool, error) {
+	if dailyMinutes <= 0 || api.Stats == nil {
+		return 0, false, nil
+	}
+	used, err := api.Stats.TimeInDoor(userID, name, door.StartOfDay(time.Now()))
+	if err != nil {
+		return 0, false, err
+	}
+	return time.Duration(dailyMinutes)*time.Minute - used, true, nil
+}
+
+func (api *DoorAPI) record(p *door.Play) {
+	if api.Stats == nil {
+		return
+	}
+	if err := api.Stats.Record(p); err != nil {
+		log.Printf("Node %d: %v", api.nodeID, err)
+	}
+}
+
+// exitStatus describes how a launch ended for the door_sessions log.
+func exitStatus(s *door.Session, err error) string {
+	switch {
+	case errors.Is(err, door.ErrTimedOut):
+		return "timeout"
+	case err != nil:
+		return err.Error()
+	case s.ExitStatus != "":
+		return s.ExitStatus
+	}
+	return "ok"
+}
+
+// luaTop handles: door.top([days[, limit]]) → list, err. Each entry has
+// name, launches, players and minutes.
+func (api *DoorAPI) luaTop(L *lua.LState) int {
+	if api.Stats == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("door statistics not available"))
+		return 2
+	}
+	days := L.OptInt(1, defaultTopDays)
+	limit := L.OptInt(2, 10)
+	usage, err := api.Stats.TopDoors(time.Now().AddDate(0, 0, -days), limit)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(usageToTable(L, usage))
+	return 1
+}
+
+// luaPlaytime handles: door.playtime([days]) → list, err for the current
+// user. Each entry has name, launches and minutes.
+func (api *DoorAPI) luaPlaytime(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	if api.Stats == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("door statistics not available"))
+		return 2
+	}
+	days := L.OptInt(1, defaultTopDays)
+	usage, err := api.Stats.UserPlaytime(u.ID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(usageToTable(L, usage))
+	return 1
+}
+
+// luaTimeLeft handles: door.time_left(cfg) → minutes, or nil when the door
+// in cfg has no daily_minutes allowance.
+func (api *DoorAPI) luaTimeLeft(L *lua.LState) int {
+	u := api.session.User()
+	cfg := L.CheckTable(1)
+	daily, _ := cfg.RawGetString("daily_minutes").(lua.LNumber)
+	if u == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	left, ok, err := api.allowanceLeft(u.ID, lua.LVAsString(cfg.RawGetString("name")), int(daily))
+	if err != nil || !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LNumber(max(int(left/time.Minute), 0)))
+	return 1
+}
+
+func usageToTable(L *lua.LState, usage []door.Usage) *lua.LTable {
+	tbl := L.NewTable()
+	for _, u := range usage {
+		row := L.NewTable()
+		row.RawSetString("name", lua.LString(u.Door))
+		row.RawSetString("launches", lua.LNumber(u.Launches))
+		row.RawSetString("players", lua.LNumber(u.Players))
+		row.RawSetString("minutes", lua.LNumber(u.Seconds/60))
+		tbl.Append(row)
+	}
+	return tbl
+}
+
 func parseDoorConfigFromLua(t *lua.LTable) (door.Config, error) {
 	// Supported fields:
 	// - name (string, required)
@@ -106,6 +288,9 @@ func parseDoorConfigFromLua(t *lua.LTable) (door.Config, error) {
 	// - drop_file_type (string, optional; default DOOR.SYS)
 	// - security_level (number, optional; default 10)
 	// - multiuser (bool, optional; default true)
+	// - network, overlay (bool, optional), memory_mb, cpu_percent,
+	//   max_pids (number, optional): sandbox overrides
+	// - daily_minutes (number, optional): per-user daily time in the door
 	getString := func(key string) string {
 		v := t.RawGetString(key)
 		if s, ok := v.(lua.LString); ok {
@@ -140,10 +325,13 @@ func parseDoorConfigFromLua(t *lua.LTable) (door.Config, error) {
 
 	desc := strings.TrimSpace(getString("description"))
 
-	drop := strings.TrimSpace(getString("drop_file_type"))
+	drop := strings.ToUpper(strings.TrimSpace(getString("drop_file_type")))
 	if drop == "" {
 		drop = "DOOR.SYS"
 	}
+	if !door.ValidDropFileType(drop) {
+		return door.Config{}, fmt.Errorf("door '%s' has unknown drop_file_type %q (want one of %s)", name, drop, strings.Join(door.DropFileTypes, ", "))
+	}
 
 	secLevel := 10
 	if n, ok := getNumber("security_level"); ok {
@@ -161,6 +349,29 @@ func parseDoorConfigFromLua(t *lua.LTable) (door.Config, error) {
 		multiUser = b
 	}
 
+	var sandbox door.SandboxOptions
+	if b, ok := getBool("network"); ok {
+		sandbox.Network = &b
+	}
+	if b, ok := getBool("overlay"); ok {
+		sandbox.Overlay = &b
+	}
+	for key, dst := range map[string]**int{
+		"memory_mb":   &sandbox.MemoryMB,
+		"cpu_percent": &sandbox.CPUPercent,
+		"max_pids":    &sandbox.MaxPids,
+	} {
+		if n, ok := getNumber(key); ok {
+			v := max(int(n), 0)
+			*dst = &v
+		}
+	}
+
+	dailyMinutes := 0
+	if n, ok := getNumber("daily_minutes"); ok {
+		dailyMinutes = max(int(n), 0)
+	}
+
 	return door.Config{
 		ID:            0,
 		Name:          name,
@@ -169,5 +380,7 @@ func parseDoorConfigFromLua(t *lua.LTable) (door.Config, error) {
 		DropFileType:  drop,
 		SecurityLevel: secLevel,
 		MultiUser:     multiUser,
+		Sandbox:       sandbox,
+		DailyMinutes:  dailyMinutes,
 	}, nil
 }
@@ -2,21 +2,67 @@ package scripting
 
 import (
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/flood"
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/transfer"
 	"github.com/notepid/twilight_bbs/internal/user"
+	"github.com/notepid/twilight_bbs/internal/weblink"
 	lua "github.com/yuin/gopher-lua"
 )
 
 // FileAPI exposes file area functions to Lua.
 type FileAPI struct {
-	repo        *filearea.Repo
-	currentUser func() *user.User
+	repo    *filearea.Repo
+	session *session.Session
+
+	// Pick shows the area picker (files.pick_area)
+	Pick PickFunc
+
+	// ExtractDir is where files.extract_member puts archive members;
+	// empty disables it.
+	ExtractDir string
+	extracted  string // dir of the last extracted member
+
+	// Send transfers files in one batch (files.download_tagged); nil
+	// when transfers are not available.
+	Send func(paths []string) (*transfer.Result, error)
+
+	// Ratio is checked against the whole batch before it is sent
+	Ratio filearea.Ratio
+
+	// Flood limits how many file entries the user may add
+	Flood FloodFunc
+
+	// SaveScan records when the user last scanned for new files
+	// (files.mark_scanned); nil when it cannot be saved.
+	SaveScan func(at time.Time) error
+	scanAt   time.Time // when files.new_since_last_call last ran
+
+	// Joined returns the IDs of the conferences the caller belongs to,
+	// which files.new_since_last_call covers; nil covers every area.
+	Joined func() ([]int, error)
+
+	// Links issues one-time HTTP download links (files.web_link); nil
+	// when they are off.
+	Links *weblink.Store
 }
 
 // NewFileAPI creates a Lua file area API.
-func NewFileAPI(repo *filearea.Repo, currentUser func() *user.User) *FileAPI {
-	return &FileAPI{repo: repo, currentUser: currentUser}
+func NewFileAPI(repo *filearea.Repo, sess *session.Session) *FileAPI {
+	return &FileAPI{repo: repo, session: sess}
+}
+
+// areas returns the repository scoped to the caller's current conference,
+// which area lists, searches and new file scans cover.
+func (api *FileAPI) areas() *filearea.Repo {
+	if u := api.session.User(); u != nil {
+		return api.repo.InConferences(u.Conference)
+	}
+	return api.repo
 }
 
 // Register installs file functions in the Lua state.
@@ -25,23 +71,35 @@ func (api *FileAPI) Register(L *lua.LState) {
 
 	mod.RawSetString("areas", L.NewFunction(api.luaAreas))
 	mod.RawSetString("get_area", L.NewFunction(api.luaGetArea))
+	mod.RawSetString("pick_area", L.NewFunction(api.luaPickArea))
 	mod.RawSetString("list", L.NewFunction(api.luaList))
 	mod.RawSetString("get_file", L.NewFunction(api.luaGetFile))
 	mod.RawSetString("search", L.NewFunction(api.luaSearch))
+	mod.RawSetString("new_since_last_call", L.NewFunction(api.luaNewSinceLastCall))
+	mod.RawSetString("top", L.NewFunction(api.luaTop))
+	mod.RawSetString("mark_scanned", L.NewFunction(api.luaMarkScanned))
 	mod.RawSetString("add_entry", L.NewFunction(api.luaAddEntry))
 	mod.RawSetString("increment_download", L.NewFunction(api.luaIncrementDownload))
+	mod.RawSetString("view_archive", L.NewFunction(api.luaViewArchive))
+	mod.RawSetString("extract_member", L.NewFunction(api.luaExtractMember))
+	mod.RawSetString("tag", L.NewFunction(api.luaTag))
+	mod.RawSetString("untag", L.NewFunction(api.luaUntag))
+	mod.RawSetString("tagged", L.NewFunction(api.luaTagged))
+	mod.RawSetString("clear_tags", L.NewFunction(api.luaClearTags))
+	mod.RawSetString("download_tagged", L.NewFunction(api.luaDownloadTagged))
+	mod.RawSetString("web_link", L.NewFunction(api.luaWebLink))
 
 	L.SetGlobal("files", mod)
 }
 
 func (api *FileAPI) luaAreas(L *lua.LState) int {
-	u := api.currentUser()
+	u := api.session.User()
 	level := 0
 	if u != nil {
 		level = u.SecurityLevel
 	}
 
-	areas, err := api.repo.ListAreas(level)
+	areas, err := api.areas().ListAreas(level)
 	if err != nil {
 		L.Push(lua.LNil)
 		return 1
@@ -49,20 +107,25 @@ func (api *FileAPI) luaAreas(L *lua.LState) int {
 
 	tbl := L.NewTable()
 	for i, a := range areas {
-		at := L.NewTable()
-		at.RawSetString("id", lua.LNumber(a.ID))
-		at.RawSetString("name", lua.LString(a.Name))
-		at.RawSetString("description", lua.LString(a.Description))
-		at.RawSetString("files", lua.LNumber(a.FileCount))
-		at.RawSetString("download_level", lua.LNumber(a.DownloadLevel))
-		at.RawSetString("upload_level", lua.LNumber(a.UploadLevel))
-		at.RawSetString("path", lua.LString(a.DiskPath))
-		tbl.RawSetInt(i+1, at)
+		tbl.RawSetInt(i+1, fileAreaToTable(L, a))
 	}
 	L.Push(tbl)
 	return 1
 }
 
+// fileAreaToTable converts a file area to a Lua table.
+func fileAreaToTable(L *lua.LState, a *filearea.Area) *lua.LTable {
+	at := L.NewTable()
+	at.RawSetString("id", lua.LNumber(a.ID))
+	at.RawSetString("name", lua.LString(a.Name))
+	at.RawSetString("description", lua.LString(a.Description))
+	at.RawSetString("files", lua.LNumber(a.FileCount))
+	at.RawSetString("download_level", lua.LNumber(a.DownloadLevel))
+	at.RawSetString("upload_level", lua.LNumber(a.UploadLevel))
+	at.RawSetString("path", lua.LString(a.DiskPath))
+	return at
+}
+
 func (api *FileAPI) luaGetArea(L *lua.LState) int {
 	areaID := L.CheckInt(1)
 	a, err := api.repo.GetArea(areaID)
@@ -114,13 +177,13 @@ func (api *FileAPI) luaGetFile(L *lua.LState) int {
 
 func (api *FileAPI) luaSearch(L *lua.LState) int {
 	pattern := L.CheckString(1)
-	u := api.currentUser()
+	u := api.session.User()
 	level := 0
 	if u != nil {
 		level = u.SecurityLevel
 	}
 
-	entries, err := api.repo.FindByName(pattern, level)
+	entries, err := api.areas().FindByName(pattern, level)
 	if err != nil {
 		L.Push(lua.LNil)
 		return 1
@@ -134,8 +197,42 @@ func (api *FileAPI) luaSearch(L *lua.LState) int {
 	return 1
 }
 
+// luaTop handles: files.top([n[, period]]) → files | nil, err. The files
+// downloaded most in the period, in areas of the current conference the
+// caller may download from.
+func (api *FileAPI) luaTop(L *lua.LState) int {
+	n := L.OptInt(1, 10)
+	period, ok := filearea.ParsePeriod(L.OptString(2, ""))
+	if !ok {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("unknown period " + L.OptString(2, "")))
+		return 2
+	}
+	level := 0
+	if u := api.session.User(); u != nil {
+		level = u.SecurityLevel
+	}
+
+	top, err := api.areas().TopDownloads(n, period, time.Now(), level)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	tbl := L.NewTable()
+	for _, f := range top {
+		t := api.entryToTable(L, f.Entry)
+		t.RawSetString("area", lua.LString(f.AreaName))
+		t.RawSetString("downloads", lua.LNumber(f.Downloads))
+		t.RawSetString("total_downloads", lua.LNumber(f.DownloadCount))
+		tbl.Append(t)
+	}
+	L.Push(tbl)
+	return 1
+}
+
 func (api *FileAPI) luaAddEntry(L *lua.LState) int {
-	u := api.currentUser()
+	u := api.session.User()
 	if u == nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString("not logged in"))
@@ -160,6 +257,13 @@ func (api *FileAPI) luaAddEntry(L *lua.LState) int {
 		return 2
 	}
 
+	if msg, retry, refused := flooded(api.Flood, flood.File, "files"); refused {
+		L.Push(lua.LNil)
+		L.Push(msg)
+		L.Push(retry)
+		return 3
+	}
+
 	id, err := api.repo.AddEntry(areaID, filename, description, sizeBytes, u.ID)
 	if err != nil {
 		L.Push(lua.LNil)
@@ -182,6 +286,89 @@ func (api *FileAPI) luaIncrementDownload(L *lua.LState) int {
 	return 1
 }
 
+// lastScan returns when the user last scanned for new files: their saved
+// scan time, else their previous call, else when they signed up.
+func (api *FileAPI) lastScan(u *user.User) time.Time {
+	switch {
+	case u.FileScanAt != nil:
+		return *u.FileScanAt
+	case u.PreviousCallAt != nil:
+		return *u.PreviousCallAt
+	}
+	return u.CreatedAt
+}
+
+// luaNewSinceLastCall handles: files.new_since_last_call([limit]) →
+// entries, files, areas | nil, err. entries holds up to limit (default
+// 100) of the files uploaded since the user last scanned, grouped by area,
+// each with an area field; files and areas count all of them.
+func (api *FileAPI) luaNewSinceLastCall(L *lua.LState) int {
+	limit := L.OptInt(1, 100)
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+
+	// One scan time covers every conference, so the scan does too.
+	repo := api.repo
+	if api.Joined != nil {
+		ids, err := api.Joined()
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		repo = api.repo.InConferences(ids...)
+	}
+	since := api.lastScan(u)
+	api.scanAt = time.Now()
+	files, areas, err := repo.CountFilesSince(since, u.SecurityLevel)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	tbl := L.NewTable()
+	if files > 0 && limit > 0 {
+		entries, err := repo.ListFilesSince(since, u.SecurityLevel, limit)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		for _, e := range entries {
+			t := api.entryToTable(L, e)
+			t.RawSetString("area", lua.LString(e.AreaName))
+			tbl.Append(t)
+		}
+	}
+	L.Push(tbl)
+	L.Push(lua.LNumber(files))
+	L.Push(lua.LNumber(areas))
+	return 3
+}
+
+// luaMarkScanned handles: files.mark_scanned() → err. Files uploaded
+// before the last files.new_since_last_call are no longer new to the user.
+func (api *FileAPI) luaMarkScanned(L *lua.LState) int {
+	if api.SaveScan == nil {
+		L.Push(lua.LString("file scan not available"))
+		return 1
+	}
+	at := api.scanAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if err := api.SaveScan(at); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
 func (api *FileAPI) entryToTable(L *lua.LState, e *filearea.Entry) *lua.LTable {
 	t := L.NewTable()
 	t.RawSetString("id", lua.LNumber(e.ID))
@@ -214,3 +401,179 @@ func formatSize(bytes int64) string {
 		return fmt.Sprintf("%d B", bytes)
 	}
 }
+
+// downloadable returns a file and its area if u may download it.
+func (api *FileAPI) downloadable(u *user.User, fileID int) (*filearea.Entry, *filearea.Area, error) {
+	e, err := api.repo.GetFile(fileID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("file %d not found", fileID)
+	}
+	a, err := api.repo.GetArea(e.AreaID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("file %d not found", fileID)
+	}
+	if u.SecurityLevel < a.DownloadLevel {
+		return nil, nil, fmt.Errorf("you may not download from %s", a.Name)
+	}
+	return e, a, nil
+}
+
+// checkRatio returns an error when downloading extra more bytes would take
+// u past the ratio. This call's transfers and web links not yet used count
+// as downloaded.
+func (api *FileAPI) checkRatio(u *user.User, extra int64) error {
+	st := api.session.Stats()
+	downloaded := u.BytesDownloaded + st.BytesDown
+	if api.Links != nil {
+		downloaded += api.Links.Pending(u.ID)
+	}
+	return api.Ratio.Check(u.SecurityLevel, u.BytesUploaded+st.BytesUp, downloaded, extra)
+}
+
+// luaWebLink handles: files.web_link(id) → {url, minutes}, err. The link
+// lets the caller download the file once with a browser within minutes.
+// The ratio counts the file now, with links not yet used; the download
+// count goes up once the file has been fetched.
+func (api *FileAPI) luaWebLink(L *lua.LState) int {
+	fail := func(msg string) int {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(msg))
+		return 2
+	}
+	u := api.session.User()
+	if u == nil {
+		return fail("not logged in")
+	}
+	if api.Links == nil {
+		return fail("web downloads are not available")
+	}
+	e, a, err := api.downloadable(u, L.CheckInt(1))
+	if err != nil {
+		return fail(err.Error())
+	}
+	if err := api.checkRatio(u, e.SizeBytes); err != nil {
+		return fail(err.Error())
+	}
+	link, err := api.Links.Issue(u.ID, e.ID, filepath.Join(a.DiskPath, e.Filename))
+	if err != nil {
+		return fail(err.Error())
+	}
+	api.session.Untag(e.ID)
+	t := L.NewTable()
+	t.RawSetString("url", lua.LString(link))
+	t.RawSetString("minutes", lua.LNumber(int(api.Links.TTL/time.Minute)))
+	L.Push(t)
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaTag handles: files.tag(id) → err. Tagged files are kept for the rest
+// of the call, across menus.
+func (api *FileAPI) luaTag(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	fileID := L.CheckInt(1)
+	if _, _, err := api.downloadable(u, fileID); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	if !api.session.Tag(fileID) {
+		L.Push(lua.LString("already tagged"))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaUntag handles: files.untag(id) → bool (false if it was not tagged).
+func (api *FileAPI) luaUntag(L *lua.LState) int {
+	L.Push(lua.LBool(api.session.Untag(L.CheckInt(1))))
+	return 1
+}
+
+// luaTagged handles: files.tagged() → list, summary. Files that were
+// removed since they were tagged are dropped. summary has count, bytes and
+// size_str.
+func (api *FileAPI) luaTagged(L *lua.LState) int {
+	tbl := L.NewTable()
+	var total int64
+	for _, id := range api.session.Tagged() {
+		e, err := api.repo.GetFile(id)
+		if err != nil {
+			api.session.Untag(id)
+			continue
+		}
+		total += e.SizeBytes
+		tbl.Append(api.entryToTable(L, e))
+	}
+	summary := L.NewTable()
+	summary.RawSetString("count", lua.LNumber(tbl.Len()))
+	summary.RawSetString("bytes", lua.LNumber(total))
+	summary.RawSetString("size_str", lua.LString(formatSize(total)))
+	L.Push(tbl)
+	L.Push(summary)
+	return 2
+}
+
+// luaClearTags handles: files.clear_tags().
+func (api *FileAPI) luaClearTags(L *lua.LState) int {
+	api.session.ClearTags()
+	return 0
+}
+
+// luaDownloadTagged handles: files.download_tagged() → sent, err. The area
+// download levels and the ratio are checked for the whole batch before
+// anything is sent; files that arrive are counted and untagged.
+func (api *FileAPI) luaDownloadTagged(L *lua.LState) int {
+	fail := func(msg string) int {
+		L.Push(lua.LNumber(0))
+		L.Push(lua.LString(msg))
+		return 2
+	}
+	u := api.session.User()
+	if u == nil {
+		return fail("not logged in")
+	}
+	if api.Send == nil {
+		return fail("file transfer is not available")
+	}
+	ids := api.session.Tagged()
+	if len(ids) == 0 {
+		return fail("no files tagged")
+	}
+
+	var paths []string
+	var total int64
+	byName := make(map[string]*filearea.Entry)
+	for _, id := range ids {
+		e, a, err := api.downloadable(u, id)
+		if err != nil {
+			return fail(err.Error())
+		}
+		paths = append(paths, filepath.Join(a.DiskPath, e.Filename))
+		total += e.SizeBytes
+		byName[e.Filename] = e
+	}
+	if err := api.checkRatio(u, total); err != nil {
+		return fail(err.Error())
+	}
+
+	result, err := api.Send(paths)
+	if err != nil {
+		return fail(err.Error())
+	}
+	for _, f := range result.Files {
+		if e, ok := byName[filepath.Base(f.Name)]; ok {
+			if err := api.repo.IncrementDownload(e.ID); err != nil {
+				return fail(err.Error())
+			}
+			api.session.Untag(e.ID)
+		}
+	}
+	L.Push(lua.LNumber(len(result.Files)))
+	L.Push(lua.LNil)
+	return 2
+}
@@ -0,0 +1,44 @@
+package scripting
+
+import (
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/greeting"
+	"github.com/notepid/twilight_bbs/internal/session"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// GreetingAPI exposes the configured greetings to Lua.
+type GreetingAPI struct {
+	greetings *greeting.Service
+	session   *session.Session
+}
+
+// NewGreetingAPI creates a Lua greeting API.
+func NewGreetingAPI(greetings *greeting.Service, sess *session.Session) *GreetingAPI {
+	return &GreetingAPI{greetings: greetings, session: sess}
+}
+
+// Register installs greeting functions in the Lua state.
+func (api *GreetingAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+
+	mod.RawSetString("get", L.NewFunction(api.luaGet))
+
+	L.SetGlobal("greeting", mod)
+}
+
+// luaGet handles: greeting.get() → {kind, text, art}|nil
+func (api *GreetingAPI) luaGet(L *lua.LState) int {
+	g := api.greetings.Pick(api.session.User(), time.Now())
+	if g == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	tbl := L.NewTable()
+	tbl.RawSetString("kind", lua.LString(g.Kind))
+	tbl.RawSetString("text", lua.LString(g.Text))
+	tbl.RawSetString("art", lua.LString(g.Art))
+	L.Push(tbl)
+	return 1
+}
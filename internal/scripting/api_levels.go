@@ -0,0 +1,83 @@
+package scripting
+
+import (
+	"github.com/notepid/twilight_bbs/internal/user"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// LevelsAPI exposes the sysop's security level table to Lua.
+type LevelsAPI struct {
+	repo *user.Repo
+}
+
+// NewLevelsAPI creates a Lua levels API.
+func NewLevelsAPI(repo *user.Repo) *LevelsAPI {
+	return &LevelsAPI{repo: repo}
+}
+
+// Register installs the levels module in the Lua state.
+func (api *LevelsAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+	mod.RawSetString("list", L.NewFunction(api.luaList))
+	mod.RawSetString("get", L.NewFunction(api.luaGet))
+	mod.RawSetString("name", L.NewFunction(api.luaName))
+	mod.RawSetString("value", L.NewFunction(api.luaValue))
+	L.SetGlobal("levels", mod)
+}
+
+// luaList handles: levels.list() → table|nil, err
+func (api *LevelsAPI) luaList(L *lua.LState) int {
+	levels, err := api.repo.Levels()
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	tbl := L.NewTable()
+	for i, l := range levels {
+		tbl.RawSetInt(i+1, levelToTable(L, l))
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// luaGet handles: levels.get(level) → table|nil, err. It returns the entry
+// level falls in.
+func (api *LevelsAPI) luaGet(L *lua.LState) int {
+	l, err := api.repo.LevelOf(L.CheckInt(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(levelToTable(L, l))
+	return 1
+}
+
+// luaName handles: levels.name(level) → string
+func (api *LevelsAPI) luaName(L *lua.LState) int {
+	L.Push(lua.LString(api.repo.LevelName(L.CheckInt(1))))
+	return 1
+}
+
+// luaValue handles: levels.value(name) → number|nil
+func (api *LevelsAPI) luaValue(L *lua.LState) int {
+	l, ok := api.repo.LevelByName(L.CheckString(1))
+	if !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LNumber(l.Value))
+	return 1
+}
+
+func levelToTable(L *lua.LState, l user.Level) *lua.LTable {
+	tbl := L.NewTable()
+	tbl.RawSetString("value", lua.LNumber(l.Value))
+	tbl.RawSetString("name", lua.LString(l.Name))
+	tbl.RawSetString("color", lua.LNumber(l.Color))
+	tbl.RawSetString("time_limit", lua.LNumber(l.TimeLimit))
+	tbl.RawSetString("ratio", lua.LNumber(l.Ratio))
+	tbl.RawSetString("flags", lua.LString(l.Flags))
+	return tbl
+}
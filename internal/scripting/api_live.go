@@ -0,0 +1,244 @@
+package scripting
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/chat"
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/terminal"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// defaultAskSeconds is how long ev:ask waits when no timeout is given.
+const defaultAskSeconds = 30
+
+// LiveAPI exposes live multi-node events to Lua: hosting one from a script
+// and joining one as a player.
+type LiveAPI struct {
+	broker  *chat.Broker
+	term    *terminal.Terminal
+	session *session.Session
+	nodeID  int
+}
+
+// NewLiveAPI creates a Lua live event API.
+func NewLiveAPI(broker *chat.Broker, term *terminal.Terminal, sess *session.Session, nodeID int) *LiveAPI {
+	return &LiveAPI{broker: broker, term: term, session: sess, nodeID: nodeID}
+}
+
+// Register installs live event functions in the Lua state.
+func (api *LiveAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+
+	mod.RawSetString("host", L.NewFunction(api.luaHost))
+	mod.RawSetString("events", L.NewFunction(api.luaEvents))
+	mod.RawSetString("join", L.NewFunction(api.luaJoin))
+
+	L.SetGlobal("live", mod)
+}
+
+func (api *LiveAPI) userName() string {
+	if u := api.session.User(); u != nil {
+		return u.Username
+	}
+	return fmt.Sprintf("Node %d", api.nodeID)
+}
+
+// luaHost handles: live.host(name, title) → event, or nil, err
+func (api *LiveAPI) luaHost(L *lua.LState) int {
+	name := L.CheckString(1)
+	title := L.OptString(2, "")
+	if api.session.User() == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	ev, err := api.broker.StartEvent(api.nodeID, api.userName(), name, title)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(api.eventTable(L, ev))
+	return 1
+}
+
+// luaEvents handles: live.events() → {{name, title, host, host_node,
+// players}, ...}
+func (api *LiveAPI) luaEvents(L *lua.LState) int {
+	tbl := L.NewTable()
+	for i, ev := range api.broker.Events() {
+		t := L.NewTable()
+		t.RawSetString("name", lua.LString(ev.Name))
+		t.RawSetString("title", lua.LString(ev.Title))
+		t.RawSetString("host", lua.LString(ev.Host))
+		t.RawSetString("host_node", lua.LNumber(ev.HostNode))
+		t.RawSetString("players", lua.LNumber(len(ev.Players())))
+		tbl.RawSetInt(i+1, t)
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// luaJoin handles: live.join(name) → nil when the player leaves or the
+// event ends, or err
+func (api *LiveAPI) luaJoin(L *lua.LState) int {
+	name := L.CheckString(1)
+	ev := api.broker.Event(name)
+	if ev == nil {
+		L.Push(lua.LString("no event named " + name))
+		return 1
+	}
+	if ev.HostNode == api.nodeID {
+		L.Push(lua.LString("you are hosting this event"))
+		return 1
+	}
+	level := 0
+	if u := api.session.User(); u != nil {
+		level = u.SecurityLevel
+	}
+	if err := chat.RunEventSession(chat.EventSessionConfig{
+		Term:     api.term,
+		Broker:   api.broker,
+		Event:    ev,
+		NodeID:   api.nodeID,
+		UserName: api.userName(),
+		Level:    level,
+	}); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+// eventTable builds the host's handle for ev. Its functions may be called
+// as ev:broadcast(text) or ev.broadcast(text).
+func (api *LiveAPI) eventTable(L *lua.LState, ev *chat.Event) *lua.LTable {
+	self := L.NewTable()
+	self.RawSetString("name", lua.LString(ev.Name))
+	self.RawSetString("title", lua.LString(ev.Title))
+
+	method := func(name string, fn func(L *lua.LState, arg int) int) {
+		self.RawSetString(name, L.NewFunction(func(L *lua.LState) int {
+			arg := 1
+			if L.Get(1) == self {
+				arg = 2
+			}
+			return fn(L, arg)
+		}))
+	}
+
+	// ev:broadcast(text)
+	method("broadcast", func(L *lua.LState, arg int) int {
+		ev.Broadcast(L.CheckString(arg))
+		return 0
+	})
+
+	// ev:announce(text) → number of nodes notified
+	method("announce", func(L *lua.LState, arg int) int {
+		L.Push(lua.LNumber(ev.Announce(L.CheckString(arg))))
+		return 1
+	})
+
+	// ev:wait(players, seconds) → number of players
+	method("wait", func(L *lua.LState, arg int) int {
+		n := L.CheckInt(arg)
+		seconds := L.OptInt(arg+1, 60)
+		L.Push(lua.LNumber(ev.Wait(n, time.Duration(seconds)*time.Second)))
+		return 1
+	})
+
+	// ev:ask(text, {seconds, once, on_answer}) → answers, or answers, err
+	// when the event ended
+	method("ask", func(L *lua.LState, arg int) int {
+		text := L.CheckString(arg)
+		seconds, once := defaultAskSeconds, true
+		var onAnswer *lua.LFunction
+		if opts, ok := L.Get(arg + 1).(*lua.LTable); ok {
+			if n, ok := opts.RawGetString("seconds").(lua.LNumber); ok && n > 0 {
+				seconds = int(n)
+			}
+			if b, ok := opts.RawGetString("once").(lua.LBool); ok {
+				once = bool(b)
+			}
+			onAnswer, _ = opts.RawGetString("on_answer").(*lua.LFunction)
+		}
+
+		var each func(chat.Answer) bool
+		if onAnswer != nil {
+			each = func(a chat.Answer) bool {
+				if err := L.CallByParam(lua.P{Fn: onAnswer, NRet: 1, Protect: true}, answerTable(L, a)); err != nil {
+					LogError("live ask on_answer", err)
+					return false
+				}
+				stop := lua.LVAsBool(L.Get(-1))
+				L.Pop(1)
+				return stop
+			}
+		}
+
+		answers, err := ev.Ask(text, time.Duration(seconds)*time.Second, once, each)
+		tbl := L.NewTable()
+		for i, a := range answers {
+			tbl.RawSetInt(i+1, answerTable(L, a))
+		}
+		L.Push(tbl)
+		if err != nil {
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		return 1
+	})
+
+	// ev:players() → {{node, name}, ...}
+	method("players", func(L *lua.LState, arg int) int {
+		tbl := L.NewTable()
+		for i, p := range ev.Players() {
+			t := L.NewTable()
+			t.RawSetString("node", lua.LNumber(p.NodeID))
+			t.RawSetString("name", lua.LString(p.UserName))
+			tbl.RawSetInt(i+1, t)
+		}
+		L.Push(tbl)
+		return 1
+	})
+
+	// ev:score(name, points) → new total
+	method("score", func(L *lua.LState, arg int) int {
+		total := ev.AddScore(L.CheckString(arg), L.CheckInt(arg+1))
+		L.Push(lua.LNumber(total))
+		return 1
+	})
+
+	// ev:scores() → {{name, points}, ...}, highest first
+	method("scores", func(L *lua.LState, arg int) int {
+		tbl := L.NewTable()
+		for i, s := range ev.Scores() {
+			t := L.NewTable()
+			t.RawSetString("name", lua.LString(s.UserName))
+			t.RawSetString("points", lua.LNumber(s.Points))
+			tbl.RawSetInt(i+1, t)
+		}
+		L.Push(tbl)
+		return 1
+	})
+
+	// ev:close()
+	method("close", func(L *lua.LState, arg int) int {
+		ev.Close()
+		return 0
+	})
+
+	return self
+}
+
+func answerTable(L *lua.LState, a chat.Answer) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("node", lua.LNumber(a.NodeID))
+	t.RawSetString("name", lua.LString(a.UserName))
+	t.RawSetString("text", lua.LString(a.Text))
+	t.RawSetString("ms", lua.LNumber(a.After.Milliseconds()))
+	return t
+}
@@ -0,0 +1,63 @@
+package scripting
+
+import (
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/session"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// MailerAPI lets scripts email the sysop, a few times per call, such as
+// for a feedback form or a door that needs attention. It is only
+// registered when sysop alerts are configured.
+type MailerAPI struct {
+	session *session.Session
+	limit   int
+	sent    int
+
+	// NotifySysop queues the alert; username is the caller's, "" before
+	// login.
+	NotifySysop func(subject, body, username string) error
+}
+
+// NewMailerAPI creates a Lua mailer API allowing limit alerts per call.
+func NewMailerAPI(sess *session.Session, limit int, notify func(subject, body, username string) error) *MailerAPI {
+	return &MailerAPI{session: sess, limit: limit, NotifySysop: notify}
+}
+
+// Register installs mailer functions in the Lua state.
+func (api *MailerAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+	mod.RawSetString("notify_sysop", L.NewFunction(api.luaNotifySysop))
+	L.SetGlobal("mailer", mod)
+}
+
+// luaNotifySysop handles: mailer.notify_sysop(subject, body) → err.
+func (api *MailerAPI) luaNotifySysop(L *lua.LState) int {
+	subject := strings.TrimSpace(L.CheckString(1))
+	body := L.CheckString(2)
+	validator := &ValidateInput{}
+	if err := validator.ValidateString(subject, "subject", MaxSubjectLen); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	if err := validator.ValidateMessageBody(body); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	if api.sent >= api.limit {
+		L.Push(lua.LString("too many sysop alerts this call"))
+		return 1
+	}
+	username := ""
+	if u := api.session.User(); u != nil {
+		username = u.Username
+	}
+	if err := api.NotifySysop(subject, body, username); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	api.sent++
+	L.Push(lua.LNil)
+	return 1
+}
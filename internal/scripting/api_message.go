@@ -1,20 +1,55 @@
 package scripting
 
 import (
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/flood"
 	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/msgreader"
+	"github.com/notepid/twilight_bbs/internal/session"
 	"github.com/notepid/twilight_bbs/internal/user"
 	lua "github.com/yuin/gopher-lua"
 )
 
 // MessageAPI exposes message base functions to Lua.
 type MessageAPI struct {
-	repo        *message.Repo
-	currentUser func() *user.User
+	repo    *message.Repo
+	session *session.Session
+
+	// LookupUser resolves a recipient name for private mail.
+	LookupUser func(username string) (*user.User, error)
+
+	// Callback after private mail is stored, used for live notification
+	OnPrivateMail func(from, to *user.User, subject string)
+
+	// Pick shows the area picker (msg.pick_area)
+	Pick PickFunc
+
+	// ReadLoop runs the built-in reader (msg.read_loop)
+	ReadLoop ReadLoopFunc
+
+	// Publish announces posts on the event bus
+	Publish PublishFunc
+
+	// Flood limits how often the user may post and send mail
+	Flood FloodFunc
 }
 
+// ReadLoopFunc runs the message reader on the caller's terminal (see
+// msgreader.Run). It is set by the menu engine.
+type ReadLoopFunc func(areaID, startID int, reply func(m *message.Message) error) (msgreader.Result, error)
+
 // NewMessageAPI creates a Lua message API.
-func NewMessageAPI(repo *message.Repo, currentUser func() *user.User) *MessageAPI {
-	return &MessageAPI{repo: repo, currentUser: currentUser}
+func NewMessageAPI(repo *message.Repo, sess *session.Session) *MessageAPI {
+	return &MessageAPI{repo: repo, session: sess}
+}
+
+// areas returns the repository scoped to the caller's current conference,
+// which area lists and new scans cover.
+func (api *MessageAPI) areas() *message.Repo {
+	if u := api.session.User(); u != nil {
+		return api.repo.InConferences(u.Conference)
+	}
+	return api.repo
 }
 
 // Register installs message functions in the Lua state.
@@ -23,18 +58,29 @@ func (api *MessageAPI) Register(L *lua.LState) {
 
 	mod.RawSetString("areas", L.NewFunction(api.luaAreas))
 	mod.RawSetString("get_area", L.NewFunction(api.luaGetArea))
+	mod.RawSetString("pick_area", L.NewFunction(api.luaPickArea))
 	mod.RawSetString("list", L.NewFunction(api.luaList))
 	mod.RawSetString("read", L.NewFunction(api.luaRead))
+	mod.RawSetString("read_loop", L.NewFunction(api.luaReadLoop))
 	mod.RawSetString("post", L.NewFunction(api.luaPost))
 	mod.RawSetString("scan_new", L.NewFunction(api.luaScanNew))
+	mod.RawSetString("new_scan", L.NewFunction(api.luaNewScan))
 	mod.RawSetString("mark_read", L.NewFunction(api.luaMarkRead))
+	mod.RawSetString("catch_up", L.NewFunction(api.luaCatchUp))
+	mod.RawSetString("set_pointer", L.NewFunction(api.luaSetPointer))
 	mod.RawSetString("count", L.NewFunction(api.luaCount))
+	mod.RawSetString("send_private", L.NewFunction(api.luaSendPrivate))
+	mod.RawSetString("inbox", L.NewFunction(api.luaInbox))
+	mod.RawSetString("outbox", L.NewFunction(api.luaOutbox))
+	mod.RawSetString("read_private", L.NewFunction(api.luaReadPrivate))
+	mod.RawSetString("delete_private", L.NewFunction(api.luaDeletePrivate))
+	mod.RawSetString("unread_mail", L.NewFunction(api.luaUnreadMail))
 
 	L.SetGlobal("msg", mod)
 }
 
 func (api *MessageAPI) luaAreas(L *lua.LState) int {
-	u := api.currentUser()
+	u := api.session.User()
 	level := 0
 	userID := 0
 	if u != nil {
@@ -42,7 +88,7 @@ func (api *MessageAPI) luaAreas(L *lua.LState) int {
 		userID = u.ID
 	}
 
-	areas, err := api.repo.ListAreasWithNew(userID, level)
+	areas, err := api.areas().ListAreasWithNew(userID, level)
 	if err != nil {
 		L.Push(lua.LNil)
 		return 1
@@ -50,20 +96,25 @@ func (api *MessageAPI) luaAreas(L *lua.LState) int {
 
 	tbl := L.NewTable()
 	for i, a := range areas {
-		at := L.NewTable()
-		at.RawSetString("id", lua.LNumber(a.ID))
-		at.RawSetString("name", lua.LString(a.Name))
-		at.RawSetString("description", lua.LString(a.Description))
-		at.RawSetString("total", lua.LNumber(a.TotalMsgs))
-		at.RawSetString("new", lua.LNumber(a.NewMsgs))
-		at.RawSetString("read_level", lua.LNumber(a.ReadLevel))
-		at.RawSetString("write_level", lua.LNumber(a.WriteLevel))
-		tbl.RawSetInt(i+1, at)
+		tbl.RawSetInt(i+1, areaToTable(L, a))
 	}
 	L.Push(tbl)
 	return 1
 }
 
+// areaToTable converts a message area (with per-user counts) to a Lua table.
+func areaToTable(L *lua.LState, a *message.Area) *lua.LTable {
+	at := L.NewTable()
+	at.RawSetString("id", lua.LNumber(a.ID))
+	at.RawSetString("name", lua.LString(a.Name))
+	at.RawSetString("description", lua.LString(a.Description))
+	at.RawSetString("total", lua.LNumber(a.TotalMsgs))
+	at.RawSetString("new", lua.LNumber(a.NewMsgs))
+	at.RawSetString("read_level", lua.LNumber(a.ReadLevel))
+	at.RawSetString("write_level", lua.LNumber(a.WriteLevel))
+	return at
+}
+
 func (api *MessageAPI) luaGetArea(L *lua.LState) int {
 	areaID := L.CheckInt(1)
 	a, err := api.repo.GetArea(areaID)
@@ -111,7 +162,7 @@ func (api *MessageAPI) luaRead(L *lua.LState) int {
 	}
 
 	// Auto-mark as read
-	u := api.currentUser()
+	u := api.session.User()
 	if u != nil {
 		api.repo.MarkRead(u.ID, m.AreaID, m.ID)
 	}
@@ -120,8 +171,61 @@ func (api *MessageAPI) luaRead(L *lua.LState) int {
 	return 1
 }
 
+// luaReadLoop handles: msg.read_loop(area_id [, {start=id, on_reply=fn}])
+// → (action, message|nil) or (nil, errString)
+//
+// Runs the built-in reader. action is "quit", or "reply" when R is pressed
+// and no on_reply function was given; message is the last one shown.
+func (api *MessageAPI) luaReadLoop(L *lua.LState) int {
+	areaID := L.CheckInt(1)
+	opts := L.OptTable(2, L.NewTable())
+
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	if api.ReadLoop == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("reader not available"))
+		return 2
+	}
+	a, err := api.repo.GetArea(areaID)
+	if err != nil || u.SecurityLevel < a.ReadLevel {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("no such area"))
+		return 2
+	}
+
+	startID := 0
+	if n, ok := opts.RawGetString("start").(lua.LNumber); ok {
+		startID = int(n)
+	}
+	var reply func(m *message.Message) error
+	if fn, ok := opts.RawGetString("on_reply").(*lua.LFunction); ok {
+		reply = func(m *message.Message) error {
+			return L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, api.msgToTable(L, m, true))
+		}
+	}
+
+	res, err := api.ReadLoop(areaID, startID, reply)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LString(res.Action))
+	if res.Message != nil {
+		L.Push(api.msgToTable(L, res.Message, true))
+	} else {
+		L.Push(lua.LNil)
+	}
+	return 2
+}
+
 func (api *MessageAPI) luaPost(L *lua.LState) int {
-	u := api.currentUser()
+	u := api.session.User()
 	if u == nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString("not logged in"))
@@ -158,12 +262,23 @@ func (api *MessageAPI) luaPost(L *lua.LState) int {
 		replyToID = &replyTo
 	}
 
+	if msg, retry, refused := flooded(api.Flood, flood.Post, "posts"); refused {
+		L.Push(lua.LNil)
+		L.Push(msg)
+		L.Push(retry)
+		return 3
+	}
+
 	id, err := api.repo.Post(areaID, u.ID, toUserID, subject, body, replyToID)
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(err.Error()))
 		return 2
 	}
+	api.session.AddPost()
+	if api.Publish != nil {
+		api.Publish(event.Post, areaID)
+	}
 
 	L.Push(lua.LNumber(id))
 	L.Push(lua.LNil)
@@ -171,7 +286,7 @@ func (api *MessageAPI) luaPost(L *lua.LState) int {
 }
 
 func (api *MessageAPI) luaScanNew(L *lua.LState) int {
-	u := api.currentUser()
+	u := api.session.User()
 	if u == nil {
 		L.Push(lua.LNil)
 		return 1
@@ -193,8 +308,48 @@ func (api *MessageAPI) luaScanNew(L *lua.LState) int {
 	return 1
 }
 
+// luaNewScan handles: msg.new_scan() → iterator | (nil, errString)
+//
+// Finds every readable area with unread messages, in one query, and
+// returns an iterator over them for use with a generic for:
+//
+//	for area in msg.new_scan() do ... end
+//
+// Each area has the fields of msg.areas() plus last_read (the pointer when
+// the scan started) and last (the newest unread message, for catch_up).
+// Skipping an area leaves its pointer alone.
+func (api *MessageAPI) luaNewScan(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	areas, err := api.areas().NewScan(u.ID, u.SecurityLevel)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	next := 0
+	L.Push(L.NewFunction(func(L *lua.LState) int {
+		if next >= len(areas) {
+			L.Push(lua.LNil)
+			return 1
+		}
+		a := areas[next]
+		next++
+		at := areaToTable(L, a)
+		at.RawSetString("last_read", lua.LNumber(a.LastRead))
+		at.RawSetString("last", lua.LNumber(a.LastID))
+		L.Push(at)
+		return 1
+	}))
+	return 1
+}
+
 func (api *MessageAPI) luaMarkRead(L *lua.LState) int {
-	u := api.currentUser()
+	u := api.session.User()
 	if u == nil {
 		return 0
 	}
@@ -204,12 +359,209 @@ func (api *MessageAPI) luaMarkRead(L *lua.LState) int {
 	return 0
 }
 
+// luaCatchUp handles: msg.catch_up(area_id [, last_id])
+//
+// Moves the user's pointer in an area to last_id, or to the newest message
+// when it is not given, so the area no longer shows up as new.
+func (api *MessageAPI) luaCatchUp(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		return 0
+	}
+	areaID := L.CheckInt(1)
+	lastID := L.OptInt(2, 0)
+	if lastID <= 0 {
+		id, err := api.repo.LastID(areaID)
+		if err != nil {
+			return 0
+		}
+		lastID = id
+	}
+	api.repo.MarkRead(u.ID, areaID, lastID)
+	return 0
+}
+
+// luaSetPointer handles: msg.set_pointer(area_id, last_id) → err
+//
+// Sets the user's pointer in an area to last_id, backwards too, so the
+// messages after it are new again; 0 makes the whole area new.
+func (api *MessageAPI) luaSetPointer(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	areaID := L.CheckInt(1)
+	lastID := L.CheckInt(2)
+	if err := api.repo.SetPointer(u.ID, areaID, lastID); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
 func (api *MessageAPI) luaCount(L *lua.LState) int {
 	areaID := L.CheckInt(1)
 	L.Push(lua.LNumber(api.repo.CountMessages(areaID)))
 	return 1
 }
 
+func (api *MessageAPI) luaSendPrivate(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+
+	toName := L.CheckString(1)
+	subject := L.CheckString(2)
+	body := L.CheckString(3)
+
+	validator := &ValidateInput{}
+	if err := validator.ValidateString(subject, "subject", MaxSubjectLen); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if err := validator.ValidateMessageBody(body); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if api.LookupUser == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("private mail not available"))
+		return 2
+	}
+	to, err := api.LookupUser(toName)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("no such user"))
+		return 2
+	}
+
+	if msg, retry, refused := flooded(api.Flood, flood.Post, "messages"); refused {
+		L.Push(lua.LNil)
+		L.Push(msg)
+		L.Push(retry)
+		return 3
+	}
+
+	id, err := api.repo.SendPrivate(u.ID, to.ID, subject, body)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if api.OnPrivateMail != nil {
+		api.OnPrivateMail(u, to, subject)
+	}
+
+	L.Push(lua.LNumber(id))
+	L.Push(lua.LNil)
+	return 2
+}
+
+func (api *MessageAPI) luaInbox(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	mails, err := api.repo.Inbox(u.ID)
+	if err != nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(api.mailListToTable(L, mails))
+	return 1
+}
+
+func (api *MessageAPI) luaOutbox(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	mails, err := api.repo.Outbox(u.ID)
+	if err != nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(api.mailListToTable(L, mails))
+	return 1
+}
+
+func (api *MessageAPI) luaReadPrivate(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	id := L.CheckInt(1)
+	m, err := api.repo.ReadPrivate(id, u.ID)
+	if err != nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(api.mailToTable(L, m, true))
+	return 1
+}
+
+func (api *MessageAPI) luaDeletePrivate(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	id := L.CheckInt(1)
+	if err := api.repo.DeletePrivate(id, u.ID); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+func (api *MessageAPI) luaUnreadMail(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNumber(0))
+		return 1
+	}
+	L.Push(lua.LNumber(api.repo.UnreadMailCount(u.ID)))
+	return 1
+}
+
+func (api *MessageAPI) mailListToTable(L *lua.LState, mails []*message.Mail) *lua.LTable {
+	tbl := L.NewTable()
+	for i, m := range mails {
+		tbl.RawSetInt(i+1, api.mailToTable(L, m, false))
+	}
+	return tbl
+}
+
+// mailToTable converts a private Mail to a Lua table.
+func (api *MessageAPI) mailToTable(L *lua.LState, m *message.Mail, includeBody bool) *lua.LTable {
+	mt := L.NewTable()
+	mt.RawSetString("id", lua.LNumber(m.ID))
+	mt.RawSetString("from", lua.LString(m.FromName))
+	mt.RawSetString("from_id", lua.LNumber(m.FromUserID))
+	mt.RawSetString("to", lua.LString(m.ToName))
+	mt.RawSetString("to_id", lua.LNumber(m.ToUserID))
+	mt.RawSetString("subject", lua.LString(m.Subject))
+	mt.RawSetString("date", lua.LString(m.CreatedAt.Format("2006-01-02 15:04")))
+	mt.RawSetString("read", lua.LBool(m.ReadAt != nil))
+	if includeBody {
+		mt.RawSetString("body", lua.LString(m.Body))
+	}
+	return mt
+}
+
 // msgToTable converts a Message to a Lua table.
 func (api *MessageAPI) msgToTable(L *lua.LState, m *message.Message, includeBody bool) *lua.LTable {
 	mt := L.NewTable()
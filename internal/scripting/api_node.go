@@ -1,10 +1,13 @@
 package scripting
 
 import (
+	"errors"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/door"
 	"github.com/notepid/twilight_bbs/internal/terminal"
 	lua "github.com/yuin/gopher-lua"
 )
@@ -13,31 +16,59 @@ import (
 type NodeAPI struct {
 	term *terminal.Terminal
 
-	// sessionState holds per-connection/session state that should be shared
-	// across menus (unlike menu-local state stored via set_state/get_state).
+	// sessionState holds per-connection/session variables shared across
+	// menus (set_var/get_var), unlike menu-local set_state/get_state.
 	sessionState map[string]interface{}
 
-	// Navigation callbacks - set by the menu engine
+	// Navigation callbacks - set by the menu engine. Gosub arguments and
+	// return values are copies made with ToGo.
 	OnGotoMenu   func(name string) error
-	OnGosubMenu  func(name string) error
-	OnReturnMenu func() error
+	OnGosubMenu  func(name string, args interface{}) error
+	OnReturnMenu func(values interface{}) error
+	OnGetArgs    func() interface{}
+	OnGetReturn  func() (values interface{}, from string)
 	OnDisconnect func()
 	OnDisplay    func(name string) error
 
+	OnDisplayRandom func(pattern string) error
+	OnDisplayPaged  func(name string) error
+
+	// OnAnimate plays a display file at bps until a key is pressed,
+	// returning the key and whether one was.
+	OnAnimate func(name string, bps int) (byte, bool, error)
+
+	// OnDisplayAnimation plays a multi-frame animation at fps until a key
+	// is pressed, returning the key and whether one was.
+	OnDisplayAnimation func(name string, fps int) (byte, bool, error)
+
 	// State callbacks - set by the menu engine
 	OnSetMenuState func(menuName, key string, value interface{})
 	OnGetMenuState func(menuName, key string) (interface{}, bool)
 	OnGetField     func(id string) (ansi.Field, bool)
 
 	// Inter-node callbacks (Phase 7+)
-	OnShowOnline func() error
-	OnEnterChat  func() error
-	OnLaunchDoor func(name string) error
+	OnShowOnline func(pause bool) error
+	OnEnterChat  func(room string) error
+	OnLaunchDoor func(cfg door.Config) error
+
+	// Sysop callbacks
+	OnSpy func(nodeID int, takeover bool) error
+
+	// OnShutdownIn reports the time left before the board goes down, false
+	// when no shutdown is under way - set by the menu engine
+	OnShutdownIn func() (time.Duration, bool)
 
 	// Pre-auth callbacks - set by the menu engine
 	OnGetPreAuthUsername func() string
 	OnGetPreAuthPassword func() string
 
+	// Pick shows a picker for node:pick - set by the menu engine
+	Pick PickFunc
+
+	// OnMacro runs the caller's macro named input, reporting whether there
+	// was one - set by the menu engine
+	OnMacro func(input string) bool
+
 	// Current menu name for state access
 	CurrentMenuName string
 }
@@ -85,6 +116,16 @@ func (api *NodeAPI) nodeIndex(L *lua.LState) int {
 		L.Push(L.NewFunction(api.luaCls))
 	case "display":
 		L.Push(L.NewFunction(api.luaDisplay))
+	case "display_random":
+		L.Push(L.NewFunction(api.luaDisplayRandom))
+	case "display_paged":
+		L.Push(L.NewFunction(api.luaDisplayPaged))
+	case "view_text":
+		L.Push(L.NewFunction(api.luaViewText))
+	case "animate":
+		L.Push(L.NewFunction(api.luaAnimate))
+	case "display_animation":
+		L.Push(L.NewFunction(api.luaDisplayAnimation))
 	case "goto_xy":
 		L.Push(L.NewFunction(api.luaGotoXY))
 	case "color":
@@ -101,12 +142,34 @@ func (api *NodeAPI) nodeIndex(L *lua.LState) int {
 		L.Push(L.NewFunction(api.luaCursorOff))
 	case "cursor_on":
 		L.Push(L.NewFunction(api.luaCursorOn))
+	case "set_baud":
+		L.Push(L.NewFunction(api.luaSetBaud))
+	case "set_charset":
+		L.Push(L.NewFunction(api.luaSetCharset))
+	case "set_palette":
+		L.Push(L.NewFunction(api.luaSetPalette))
+	case "set_colors":
+		L.Push(L.NewFunction(api.luaSetColors))
+	case "color256":
+		L.Push(L.NewFunction(api.luaColor256))
+	case "color_rgb":
+		L.Push(L.NewFunction(api.luaColorRGB))
 
 	// Methods - Input
 	case "getkey":
 		L.Push(L.NewFunction(api.luaGetKey))
+	case "getkey_ex":
+		L.Push(L.NewFunction(api.luaGetKeyEx))
+	case "macro":
+		L.Push(L.NewFunction(api.luaMacro))
+	case "poll_key":
+		L.Push(L.NewFunction(api.luaPollKey))
+	case "getkey_timeout":
+		L.Push(L.NewFunction(api.luaGetKeyTimeout))
 	case "getline":
 		L.Push(L.NewFunction(api.luaGetLine))
+	case "getline_timeout":
+		L.Push(L.NewFunction(api.luaGetLineTimeout))
 	case "hotkey":
 		L.Push(L.NewFunction(api.luaHotkey))
 	case "ask":
@@ -138,15 +201,23 @@ func (api *NodeAPI) nodeIndex(L *lua.LState) int {
 	case "disconnect":
 		L.Push(L.NewFunction(api.luaDisconnect))
 
+	// Methods - Pickers
+	case "pick":
+		L.Push(L.NewFunction(api.luaPick))
+
 	// Methods - State
 	case "set_state":
 		L.Push(L.NewFunction(api.luaSetState))
 	case "get_state":
 		L.Push(L.NewFunction(api.luaGetState))
-	case "set_session":
-		L.Push(L.NewFunction(api.luaSetSession))
-	case "get_session":
-		L.Push(L.NewFunction(api.luaGetSession))
+	case "set_var", "set_session":
+		L.Push(L.NewFunction(api.luaSetVar))
+	case "get_var", "get_session":
+		L.Push(L.NewFunction(api.luaGetVar))
+	case "args":
+		L.Push(L.NewFunction(api.luaArgs))
+	case "returned":
+		L.Push(L.NewFunction(api.luaReturned))
 
 	// Methods - Inter-node (Phase 7+)
 	case "show_online":
@@ -156,6 +227,12 @@ func (api *NodeAPI) nodeIndex(L *lua.LState) int {
 	case "launch_door":
 		L.Push(L.NewFunction(api.luaLaunchDoor))
 
+	// Methods - Sysop
+	case "spy":
+		L.Push(L.NewFunction(api.luaSpy))
+	case "shutdown_in":
+		L.Push(L.NewFunction(api.luaShutdownIn))
+
 	// Methods - Pre-auth
 	case "preauth_username":
 		L.Push(L.NewFunction(api.luaGetPreAuthUsername))
@@ -164,11 +241,37 @@ func (api *NodeAPI) nodeIndex(L *lua.LState) int {
 
 	// Properties
 	case "width":
-		L.Push(lua.LNumber(api.term.Width))
+		width, _ := api.term.Size()
+		L.Push(lua.LNumber(width))
 	case "height":
-		L.Push(lua.LNumber(api.term.Height))
+		_, height := api.term.Size()
+		L.Push(lua.LNumber(height))
 	case "ansi":
 		L.Push(lua.LBool(api.term.ANSIEnabled))
+	case "baud":
+		L.Push(lua.LNumber(api.term.Baud()))
+	case "charset":
+		L.Push(lua.LString(api.term.Charset()))
+	case "palette":
+		L.Push(lua.LString(api.term.Palette()))
+	case "colors":
+		L.Push(lua.LString(api.term.ColorDepth()))
+	case "background":
+		L.Push(lua.LString(api.term.Probe.Background))
+	case "bytes_sent":
+		sent, _ := api.term.Traffic()
+		L.Push(lua.LNumber(sent))
+	case "bytes_received":
+		_, received := api.term.Traffic()
+		L.Push(lua.LNumber(received))
+	case "compressed":
+		L.Push(lua.LBool(api.term.Compressing()))
+	case "ssh_username":
+		if api.OnGetPreAuthUsername != nil {
+			L.Push(lua.LString(api.OnGetPreAuthUsername()))
+		} else {
+			L.Push(lua.LString(""))
+		}
 
 	default:
 		L.Push(lua.LNil)
@@ -217,6 +320,89 @@ func (api *NodeAPI) luaDisplay(L *lua.LState) int {
 	return 0
 }
 
+func (api *NodeAPI) luaDisplayRandom(L *lua.LState) int {
+	// node:display_random(dir) or node:display_random("dir/pattern*")
+	pattern := strings.TrimSpace(L.CheckString(2))
+	if pattern == "" {
+		L.ArgError(2, "empty display pattern")
+		return 0
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		pattern += "/*"
+	}
+	if api.OnDisplayRandom != nil {
+		if err := api.OnDisplayRandom(pattern); err != nil {
+			L.ArgError(2, err.Error())
+		}
+	}
+	return 0
+}
+
+func (api *NodeAPI) luaDisplayPaged(L *lua.LState) int {
+	name := strings.TrimSpace(L.CheckString(2))
+	if name == "" {
+		L.ArgError(2, "empty display name")
+		return 0
+	}
+	if api.OnDisplayPaged != nil {
+		if err := api.OnDisplayPaged(name); err != nil {
+			L.ArgError(2, err.Error())
+		}
+	}
+	return 0
+}
+
+// luaAnimate handles: node:animate(name [, bps]) → key pressed or nil; nil,
+// err when the art is not found or the connection is gone. A name with wildcards picks a random file
+// like node:display_random.
+func (api *NodeAPI) luaAnimate(L *lua.LState) int {
+	name := strings.TrimSpace(L.CheckString(2))
+	bps := L.OptInt(3, 0)
+	if api.OnAnimate == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	key, pressed, err := api.OnAnimate(name, bps)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if !pressed {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LString(string(key)))
+	return 1
+}
+
+// luaDisplayAnimation handles: node:display_animation(name [, fps]) → key
+// pressed or nil; nil, err when the animation is not found or the
+// connection is gone.
+func (api *NodeAPI) luaDisplayAnimation(L *lua.LState) int {
+	name := strings.TrimSpace(L.CheckString(2))
+	fps := L.OptInt(3, 0)
+	if fps < 0 {
+		L.ArgError(3, "fps cannot be negative")
+	}
+	if api.OnDisplayAnimation == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	key, pressed, err := api.OnDisplayAnimation(name, fps)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if !pressed {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LString(string(key)))
+	return 1
+}
+
 func (api *NodeAPI) luaGotoXY(L *lua.LState) int {
 	row := L.CheckInt(2)
 	col := L.CheckInt(3)
@@ -241,6 +427,65 @@ func (api *NodeAPI) luaPause(L *lua.LState) int {
 	return 0
 }
 
+// luaSetBaud handles: node:set_baud(bps) → err. 0 is full speed.
+func (api *NodeAPI) luaSetBaud(L *lua.LState) int {
+	if err := api.term.SetBaud(L.CheckInt(2)); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetCharset handles: node:set_charset(name) → err.
+func (api *NodeAPI) luaSetCharset(L *lua.LState) int {
+	cs, ok := terminal.ParseCharset(L.CheckString(2))
+	if !ok {
+		L.Push(lua.LString("unknown charset " + L.CheckString(2)))
+		return 1
+	}
+	api.term.SetCharset(cs)
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetPalette handles: node:set_palette(name) → err.
+func (api *NodeAPI) luaSetPalette(L *lua.LState) int {
+	p, ok := terminal.ParsePalette(L.CheckString(2))
+	if !ok {
+		L.Push(lua.LString("unknown palette " + L.CheckString(2)))
+		return 1
+	}
+	api.term.SetPalette(p)
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetColors handles: node:set_colors(depth) → err.
+func (api *NodeAPI) luaSetColors(L *lua.LState) int {
+	d, ok := terminal.ParseColorDepth(L.CheckString(2))
+	if !ok {
+		L.Push(lua.LString("unknown color depth " + L.CheckString(2)))
+		return 1
+	}
+	api.term.SetColorDepth(d)
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaColor256 handles: node:color256(fg [, bg]), colors 0-255 of the
+// xterm palette, -1 to leave one as it is.
+func (api *NodeAPI) luaColor256(L *lua.LState) int {
+	api.term.SetColor256(L.CheckInt(2), L.OptInt(3, -1))
+	return 0
+}
+
+// luaColorRGB handles: node:color_rgb(r, g, b [, background]).
+func (api *NodeAPI) luaColorRGB(L *lua.LState) int {
+	api.term.SetColorRGB(L.CheckInt(2), L.CheckInt(3), L.CheckInt(4), L.OptBool(5, false))
+	return 0
+}
+
 func (api *NodeAPI) luaSaveCursor(L *lua.LState) int {
 	if api.term.ANSIEnabled {
 		api.term.Send(terminal.SaveCursor())
@@ -281,6 +526,53 @@ func (api *NodeAPI) luaGetKey(L *lua.LState) int {
 	return 1
 }
 
+// luaGetKeyEx handles: node:getkey_ex() → name, char. Cursor, editing and
+// function keys come back by name ("up", "pgdn", "f1") with char nil;
+// printable input is "char" with the character. nil when the connection is
+// gone.
+func (api *NodeAPI) luaGetKeyEx(L *lua.LState) int {
+	key, r, err := api.term.ReadKey()
+	if err != nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LString(key))
+	if key == terminal.KeyChar || key == terminal.KeyCtrl || key == terminal.KeyEnter ||
+		key == terminal.KeyBackspace || key == terminal.KeyTab {
+		L.Push(lua.LString(string(r)))
+	} else {
+		L.Push(lua.LNil)
+	}
+	return 2
+}
+
+// luaMacro handles: node:macro(input) → true when input names one of the
+// caller's macros, whose keys are then typed in for the menu to read.
+func (api *NodeAPI) luaMacro(L *lua.LState) int {
+	input := L.CheckString(2)
+	L.Push(lua.LBool(api.OnMacro != nil && api.OnMacro(input)))
+	return 1
+}
+
+// luaPollKey handles: node:poll_key([ms]) → key, or nil when none is
+// pressed within ms milliseconds (default 0: only a key already typed);
+// nil, err when the connection is gone.
+func (api *NodeAPI) luaPollKey(L *lua.LState) int {
+	ms := L.OptInt(2, 0)
+	key, ok, err := api.term.PollKey(time.Duration(ms) * time.Millisecond)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LString(string(key)))
+	return 1
+}
+
 func (api *NodeAPI) luaGetLine(L *lua.LState) int {
 	maxLen := L.OptInt(2, 80)
 	line, err := api.term.GetLine(maxLen)
@@ -292,6 +584,49 @@ func (api *NodeAPI) luaGetLine(L *lua.LState) int {
 	return 1
 }
 
+// luaGetKeyTimeout handles: node:getkey_timeout(secs) → key, or nil when
+// none is pressed within secs seconds; nil, err when the connection is gone.
+func (api *NodeAPI) luaGetKeyTimeout(L *lua.LState) int {
+	key, err := api.term.GetKeyTimeout(luaSeconds(L, 2))
+	if err != nil {
+		return pushInputError(L, err)
+	}
+	L.Push(lua.LString(string(key)))
+	return 1
+}
+
+// luaGetLineTimeout handles: node:getline_timeout(maxlen, secs) → line, or
+// nil when the caller stops typing for secs seconds; nil, err when the
+// connection is gone.
+func (api *NodeAPI) luaGetLineTimeout(L *lua.LState) int {
+	maxLen := L.CheckInt(2)
+	line, err := api.term.GetLineTimeout(maxLen, luaSeconds(L, 3))
+	if err != nil {
+		return pushInputError(L, err)
+	}
+	L.Push(lua.LString(line))
+	return 1
+}
+
+// luaSeconds reads a (possibly fractional) number of seconds argument.
+func luaSeconds(L *lua.LState, n int) time.Duration {
+	secs := float64(L.CheckNumber(n))
+	if secs < 0 {
+		L.ArgError(n, "seconds cannot be negative")
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// pushInputError returns nil for an input timeout and nil, err otherwise.
+func pushInputError(L *lua.LState, err error) int {
+	L.Push(lua.LNil)
+	if errors.Is(err, terminal.ErrInputTimeout) {
+		return 1
+	}
+	L.Push(lua.LString(err.Error()))
+	return 2
+}
+
 func (api *NodeAPI) luaHotkey(L *lua.LState) int {
 	prompt := L.CheckString(2)
 	key, err := api.term.Hotkey(prompt)
@@ -542,8 +877,12 @@ func (api *NodeAPI) luaGotoMenu(L *lua.LState) int {
 
 func (api *NodeAPI) luaGosubMenu(L *lua.LState) int {
 	name := L.CheckString(2)
+	args, err := ToGo(L.Get(3))
+	if err != nil {
+		L.RaiseError("gosub_menu %s: args: %s", name, err.Error())
+	}
 	if api.OnGosubMenu != nil {
-		if err := api.OnGosubMenu(name); err != nil {
+		if err := api.OnGosubMenu(name, args); err != nil {
 			L.RaiseError("gosub_menu %s: %s", name, err.Error())
 		}
 	}
@@ -551,14 +890,47 @@ func (api *NodeAPI) luaGosubMenu(L *lua.LState) int {
 }
 
 func (api *NodeAPI) luaReturnMenu(L *lua.LState) int {
+	values, err := ToGo(L.Get(2))
+	if err != nil {
+		L.RaiseError("return_menu: %s", err.Error())
+	}
 	if api.OnReturnMenu != nil {
-		if err := api.OnReturnMenu(); err != nil {
+		if err := api.OnReturnMenu(values); err != nil {
 			L.RaiseError("return_menu: %s", err.Error())
 		}
 	}
 	return 0
 }
 
+// luaArgs returns the value passed to gosub_menu for the current menu.
+func (api *NodeAPI) luaArgs(L *lua.LState) int {
+	if api.OnGetArgs == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(FromGo(L, api.OnGetArgs()))
+	return 1
+}
+
+// luaReturned returns the value a gosub menu passed to return_menu and the
+// name of that menu, or nil when the current menu was not resumed from one.
+func (api *NodeAPI) luaReturned(L *lua.LState) int {
+	if api.OnGetReturn == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LNil)
+		return 2
+	}
+	values, from := api.OnGetReturn()
+	if from == "" {
+		L.Push(lua.LNil)
+		L.Push(lua.LNil)
+		return 2
+	}
+	L.Push(FromGo(L, values))
+	L.Push(lua.LString(from))
+	return 2
+}
+
 func (api *NodeAPI) luaDisconnect(L *lua.LState) int {
 	if api.OnDisconnect != nil {
 		api.OnDisconnect()
@@ -631,25 +1003,13 @@ func (api *NodeAPI) luaGetState(L *lua.LState) int {
 	return 1
 }
 
-func (api *NodeAPI) luaSetSession(L *lua.LState) int {
+// luaSetVar stores a session variable shared by all menus. Setting nil
+// removes it.
+func (api *NodeAPI) luaSetVar(L *lua.LState) int {
 	key := L.CheckString(2)
-	value := L.CheckAny(3)
-
-	// Convert Lua value to Go interface{}
-	var val interface{}
-	switch v := value.(type) {
-	case lua.LBool:
-		val = bool(v)
-	case lua.LNumber:
-		val = float64(v)
-	case lua.LString:
-		val = string(v)
-	default:
-		if value == lua.LNil {
-			val = nil
-		} else {
-			val = nil
-		}
+	val, err := ToGo(L.CheckAny(3))
+	if err != nil {
+		L.RaiseError("set_var %s: %s", key, err.Error())
 	}
 
 	if val == nil {
@@ -660,54 +1020,83 @@ func (api *NodeAPI) luaSetSession(L *lua.LState) int {
 	return 0
 }
 
-func (api *NodeAPI) luaGetSession(L *lua.LState) int {
+func (api *NodeAPI) luaGetVar(L *lua.LState) int {
 	key := L.CheckString(2)
-	val, ok := api.sessionState[key]
-	if !ok {
-		L.Push(lua.LNil)
-		return 1
-	}
-
-	switch v := val.(type) {
-	case nil:
-		L.Push(lua.LNil)
-	case bool:
-		L.Push(lua.LBool(v))
-	case float64:
-		L.Push(lua.LNumber(v))
-	case string:
-		L.Push(lua.LString(v))
-	case int:
-		L.Push(lua.LNumber(lua.LNumber(v)))
-	default:
-		L.Push(lua.LNil)
-	}
-
+	L.Push(FromGo(L, api.sessionState[key]))
 	return 1
 }
 
 // --- Inter-node Methods (stubs, implemented in later phases) ---
 
+// luaShowOnline handles: node:show_online([pause]). Without pause =
+// false, the caller is asked to press a key after the list.
 func (api *NodeAPI) luaShowOnline(L *lua.LState) int {
 	if api.OnShowOnline != nil {
-		api.OnShowOnline()
+		api.OnShowOnline(L.OptBool(2, true))
 	}
 	return 0
 }
 
+// luaEnterChat handles: node:enter_chat([room]) → err. The room defaults
+// to "main"; private rooms are open only to the two users of a page.
 func (api *NodeAPI) luaEnterChat(L *lua.LState) int {
 	if api.OnEnterChat != nil {
-		api.OnEnterChat()
+		if err := api.OnEnterChat(L.OptString(2, "")); err != nil {
+			L.Push(lua.LString(err.Error()))
+			return 1
+		}
 	}
 	return 0
 }
 
+// luaLaunchDoor handles: node:launch_door(cfgTable) → err, the same as
+// door.launch.
 func (api *NodeAPI) luaLaunchDoor(L *lua.LState) int {
-	name := L.CheckString(2)
-	if api.OnLaunchDoor != nil {
-		api.OnLaunchDoor(name)
+	if api.OnLaunchDoor == nil {
+		L.Push(lua.LString("doors are not available"))
+		return 1
 	}
-	return 0
+	cfg, err := parseDoorConfigFromLua(L.CheckTable(2))
+	if err == nil {
+		err = api.OnLaunchDoor(cfg)
+	}
+	if err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+func (api *NodeAPI) luaSpy(L *lua.LState) int {
+	// node:spy(nodeID [, takeover]) -> err or nil
+	nodeID := L.CheckInt(2)
+	takeover := L.OptBool(3, false)
+	if api.OnSpy == nil {
+		L.Push(lua.LString("node spy not available"))
+		return 1
+	}
+	if err := api.OnSpy(nodeID, takeover); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+func (api *NodeAPI) luaShutdownIn(L *lua.LState) int {
+	// node:shutdown_in() -> seconds until the board goes down, or nil
+	if api.OnShutdownIn == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	left, ok := api.OnShutdownIn()
+	if !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LNumber(int(left.Round(time.Second) / time.Second)))
+	return 1
 }
 
 func (api *NodeAPI) luaGetPreAuthUsername(L *lua.LState) int {
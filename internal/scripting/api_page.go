@@ -0,0 +1,129 @@
+package scripting
+
+import (
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/flood"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// pageTimeout is how long chat.page waits for an answer by default.
+const pageTimeout = 60 * time.Second
+
+// pagePoll is how often chat.page checks for a key while it waits.
+var pagePoll = 250 * time.Millisecond
+
+// luaPage handles: chat.page(node_id [, seconds]) → room | nil, err. It
+// pages the user on the node for a private chat and waits for them to
+// answer, the time to pass or the caller to press a key. The room is for
+// node:enter_chat; err is "declined", "no answer", "cancelled" or why the
+// page could not be sent.
+func (api *ChatAPI) luaPage(L *lua.LState) int {
+	toNode := L.CheckInt(1)
+	wait := pageTimeout
+	if L.GetTop() >= 2 {
+		wait = luaSeconds(L, 2)
+	}
+	if msg, retry, refused := flooded(api.Flood, flood.Chat, "pages"); refused {
+		L.Push(lua.LNil)
+		L.Push(msg)
+		L.Push(retry)
+		return 3
+	}
+
+	p, err := api.broker.Page(api.nodeID, api.userName(), toNode)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	// answered pushes the answer, if there is one yet.
+	answered := func() (int, bool) {
+		select {
+		case accepted := <-p.Answered():
+			if !accepted {
+				L.Push(lua.LNil)
+				L.Push(lua.LString("declined"))
+				return 2, true
+			}
+			L.Push(lua.LString(p.Room))
+			return 1, true
+		default:
+			return 0, false
+		}
+	}
+	// giveUp withdraws the page, unless the answer came in meanwhile.
+	giveUp := func(why string) int {
+		api.broker.CancelPage(p)
+		if n, ok := answered(); ok {
+			return n
+		}
+		L.Push(lua.LNil)
+		L.Push(lua.LString(why))
+		return 2
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		if n, ok := answered(); ok {
+			return n
+		}
+		if !time.Now().Before(deadline) {
+			return giveUp("no answer")
+		}
+		_, pressed, err := api.term.PollKey(min(pagePoll, time.Until(deadline)))
+		if err != nil || pressed {
+			return giveUp("cancelled")
+		}
+	}
+}
+
+// luaPaged handles: chat.paged() → {from, node, seconds} | nil, the page
+// waiting for this node.
+func (api *ChatAPI) luaPaged(L *lua.LState) int {
+	p := api.broker.PageFor(api.nodeID)
+	if p == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	t := L.NewTable()
+	t.RawSetString("from", lua.LString(p.FromUser))
+	t.RawSetString("node", lua.LNumber(p.FromNode))
+	t.RawSetString("seconds", lua.LNumber(int(time.Since(p.At).Seconds())))
+	L.Push(t)
+	return 1
+}
+
+// luaAnswer handles: chat.answer(accept) → room | nil, err. Declining
+// returns nil and no error.
+func (api *ChatAPI) luaAnswer(L *lua.LState) int {
+	accept := L.CheckBool(1)
+	p, err := api.broker.Answer(api.nodeID, accept)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if !accept {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LString(p.Room))
+	return 1
+}
+
+// luaSetDND handles: chat.set_dnd(on) → err. While on, nobody can page
+// the user; the setting is kept for their next call.
+func (api *ChatAPI) luaSetDND(L *lua.LState) int {
+	on := L.CheckBool(1)
+	if api.DoNotDisturb == nil {
+		L.Push(lua.LString("do not disturb not available"))
+		return 1
+	}
+	if err := api.DoNotDisturb(on); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
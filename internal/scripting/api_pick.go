@@ -0,0 +1,130 @@
+package scripting
+
+import (
+	"fmt"
+
+	"github.com/notepid/twilight_bbs/internal/picker"
+	"github.com/notepid/twilight_bbs/internal/user"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// PickFunc shows a picker on the caller's terminal (see picker.Run). It is
+// set by the menu engine.
+type PickFunc func(title string, items []picker.Item) (picker.Item, bool, error)
+
+// pickResult pushes the Lua result of a picker: the chosen value, or nil and
+// "cancelled" / an error message.
+func pickResult(L *lua.LState, pick PickFunc, title string, items []picker.Item, value func(picker.Item) lua.LValue) int {
+	if pick == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("picker not available"))
+		return 2
+	}
+	it, ok, err := pick(title, items)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if !ok {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("cancelled"))
+		return 2
+	}
+	L.Push(value(it))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaPick implements users.pick([title]).
+func (api *UserAPI) luaPick(L *lua.LState) int {
+	title := L.OptString(1, "Select a user")
+	users, err := api.repo.List()
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	items := make([]picker.Item, 0, len(users))
+	for i, u := range users {
+		if u.State != user.StateDeleted {
+			items = append(items, picker.Item{ID: i, Label: u.Username, Detail: u.Location})
+		}
+	}
+	return pickResult(L, api.Pick, title, items, func(it picker.Item) lua.LValue {
+		return api.userToTable(L, users[it.ID])
+	})
+}
+
+// luaPickArea implements msg.pick_area([title]), offering the areas the
+// current user may read.
+func (api *MessageAPI) luaPickArea(L *lua.LState) int {
+	title := L.OptString(1, "Select a message area")
+	level, userID := 0, 0
+	if u := api.session.User(); u != nil {
+		level, userID = u.SecurityLevel, u.ID
+	}
+	areas, err := api.areas().ListAreasWithNew(userID, level)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	items := make([]picker.Item, 0, len(areas))
+	for i, a := range areas {
+		items = append(items, picker.Item{ID: i, Label: a.Name,
+			Detail: fmt.Sprintf("%s (%d new)", a.Description, a.NewMsgs)})
+	}
+	return pickResult(L, api.Pick, title, items, func(it picker.Item) lua.LValue {
+		return areaToTable(L, areas[it.ID])
+	})
+}
+
+// luaPickArea implements files.pick_area([title]), offering the areas the
+// current user may download from.
+func (api *FileAPI) luaPickArea(L *lua.LState) int {
+	title := L.OptString(1, "Select a file area")
+	level := 0
+	if u := api.session.User(); u != nil {
+		level = u.SecurityLevel
+	}
+	areas, err := api.areas().ListAreas(level)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	items := make([]picker.Item, 0, len(areas))
+	for i, a := range areas {
+		items = append(items, picker.Item{ID: i, Label: a.Name,
+			Detail: fmt.Sprintf("%s (%d files)", a.Description, a.FileCount)})
+	}
+	return pickResult(L, api.Pick, title, items, func(it picker.Item) lua.LValue {
+		return fileAreaToTable(L, areas[it.ID])
+	})
+}
+
+// luaPick implements node:pick(title, items) for script-defined lists.
+// items is a list of strings or {label=, detail=} tables; the 1-based index
+// of the chosen item is returned.
+func (api *NodeAPI) luaPick(L *lua.LState) int {
+	title := L.CheckString(2)
+	tbl := L.CheckTable(3)
+	var items []picker.Item
+	for i := 1; i <= tbl.Len(); i++ {
+		it := picker.Item{ID: i}
+		switch v := tbl.RawGetInt(i).(type) {
+		case lua.LString:
+			it.Label = string(v)
+		case *lua.LTable:
+			it.Label = lua.LVAsString(v.RawGetString("label"))
+			it.Detail = lua.LVAsString(v.RawGetString("detail"))
+		default:
+			L.ArgError(3, fmt.Sprintf("item %d must be a string or table", i))
+		}
+		items = append(items, it)
+	}
+	return pickResult(L, api.Pick, title, items, func(it picker.Item) lua.LValue {
+		return lua.LNumber(it.ID)
+	})
+}
@@ -0,0 +1,140 @@
+package scripting
+
+import (
+	"github.com/notepid/twilight_bbs/internal/user"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaProfile handles: users.profile(username) → profile, err.
+//
+// Details the user hides are left out unless the caller is that user or a
+// sysop. The profile table has name, real_name, location, bio, signature,
+// avatar, last_seen, calls, posts, uploaded, downloaded, member_since and
+// private ({real_name, location, last_seen, stats}).
+func (api *UserAPI) luaProfile(L *lua.LState) int {
+	username := L.CheckString(1)
+	p, err := api.repo.GetProfile(username)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("no such user"))
+		return 2
+	}
+
+	viewer := api.session.User()
+	full := viewer != nil && (viewer.ID == p.UserID || viewer.SecurityLevel >= user.LevelSysop)
+	L.Push(profileToTable(L, p.ForViewer(full), full))
+	L.Push(lua.LNil)
+	return 2
+}
+
+func profileToTable(L *lua.LState, p *user.Profile, full bool) *lua.LTable {
+	tbl := L.NewTable()
+	tbl.RawSetString("name", lua.LString(p.Username))
+	tbl.RawSetString("bio", lua.LString(p.Bio))
+	tbl.RawSetString("signature", lua.LString(p.Signature))
+	tbl.RawSetString("member_since", lua.LString(p.MemberSince.Format("2006-01-02")))
+	if p.Avatar != "" {
+		tbl.RawSetString("avatar", lua.LString(p.Avatar))
+	}
+	if full || !p.Hide.RealName {
+		tbl.RawSetString("real_name", lua.LString(p.RealName))
+	}
+	if full || !p.Hide.Location {
+		tbl.RawSetString("location", lua.LString(p.Location))
+	}
+	if p.LastSeen != nil && (full || !p.Hide.LastSeen) {
+		tbl.RawSetString("last_seen", lua.LString(p.LastSeen.Format("2006-01-02 15:04")))
+	}
+	if full || !p.Hide.Stats {
+		tbl.RawSetString("calls", lua.LNumber(p.Calls))
+		tbl.RawSetString("posts", lua.LNumber(p.Posts))
+		tbl.RawSetString("uploaded", lua.LNumber(p.BytesUploaded))
+		tbl.RawSetString("downloaded", lua.LNumber(p.BytesDownloaded))
+	}
+	if full {
+		tbl.RawSetString("private", privacyToTable(L, p.Hide))
+	}
+	return tbl
+}
+
+func privacyToTable(L *lua.LState, hide user.Privacy) *lua.LTable {
+	tbl := L.NewTable()
+	tbl.RawSetString("real_name", lua.LBool(hide.RealName))
+	tbl.RawSetString("location", lua.LBool(hide.Location))
+	tbl.RawSetString("last_seen", lua.LBool(hide.LastSeen))
+	tbl.RawSetString("stats", lua.LBool(hide.Stats))
+	return tbl
+}
+
+// luaUpdateBio handles: users.update_bio(bio [, signature]) → err. The
+// signature is kept when not given.
+func (api *UserAPI) luaUpdateBio(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	bio := L.CheckString(1)
+	var signature string
+	if L.GetTop() >= 2 {
+		signature = L.CheckString(2)
+	} else if p, err := api.repo.GetProfile(u.Username); err == nil {
+		signature = p.Signature
+	}
+	if err := api.repo.UpdateBio(u.ID, bio, signature); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetAvatar handles: users.set_avatar(name) → err. name is a display
+// file in the art directories, "" for none.
+func (api *UserAPI) luaSetAvatar(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	if err := api.repo.SetAvatar(u.ID, L.CheckString(1)); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetPrivacy handles: users.set_privacy({real_name, location, last_seen,
+// stats}) → err. Each true field is hidden from other users; missing fields
+// keep their current setting.
+func (api *UserAPI) luaSetPrivacy(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	opts := L.CheckTable(1)
+	p, err := api.repo.GetProfile(u.Username)
+	if err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	hide := p.Hide
+	for key, field := range map[string]*bool{
+		"real_name": &hide.RealName,
+		"location":  &hide.Location,
+		"last_seen": &hide.LastSeen,
+		"stats":     &hide.Stats,
+	} {
+		if v := opts.RawGetString(key); v != lua.LNil {
+			*field = lua.LVAsBool(v)
+		}
+	}
+	if err := api.repo.SetPrivacy(u.ID, hide); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
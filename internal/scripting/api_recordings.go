@@ -0,0 +1,160 @@
+package scripting
+
+import (
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/recording"
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/terminal"
+	"github.com/notepid/twilight_bbs/internal/user"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// defaultMaxIdle is the longest pause recordings.play keeps by default.
+const defaultMaxIdle = 5
+
+// RecordingsAPI lists, searches and plays session recordings. Recordings
+// show other callers' sessions, so only sysops may use it.
+type RecordingsAPI struct {
+	store   *recording.Store
+	term    *terminal.Terminal
+	session *session.Session
+}
+
+// NewRecordingsAPI creates a Lua recordings API.
+func NewRecordingsAPI(store *recording.Store, term *terminal.Terminal, sess *session.Session) *RecordingsAPI {
+	return &RecordingsAPI{store: store, term: term, session: sess}
+}
+
+// denied pushes nil, err and returns true when the caller is not a sysop.
+func (api *RecordingsAPI) denied(L *lua.LState) bool {
+	if api.session.Level() >= user.LevelSysop {
+		return false
+	}
+	L.Push(lua.LNil)
+	L.Push(lua.LString("sysop level required"))
+	return true
+}
+
+// Register installs the recordings module in the Lua state.
+func (api *RecordingsAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+	mod.RawSetString("list", L.NewFunction(api.luaList))
+	mod.RawSetString("play", L.NewFunction(api.luaPlay))
+	mod.RawSetString("search", L.NewFunction(api.luaSearch))
+	L.SetGlobal("recordings", mod)
+}
+
+// luaList handles: recordings.list() → table|nil, err, newest first
+func (api *RecordingsAPI) luaList(L *lua.LState) int {
+	if api.denied(L) {
+		return 2
+	}
+	list, err := api.store.List()
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	tbl := L.NewTable()
+	for i, info := range list {
+		tbl.RawSetInt(i+1, infoTable(L, info))
+	}
+	L.Push(tbl)
+	return 1
+}
+
+func infoTable(L *lua.LState, info recording.Info) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("name", lua.LString(info.Name))
+	t.RawSetString("node", lua.LNumber(info.Node))
+	t.RawSetString("user", lua.LString(info.User))
+	t.RawSetString("started", lua.LString(info.Started.Format("2006-01-02 15:04")))
+	t.RawSetString("seconds", lua.LNumber(int(info.Ended.Sub(info.Started).Seconds())))
+	t.RawSetString("size", lua.LNumber(info.Size))
+	return t
+}
+
+// luaSearch handles: recordings.search{reason=, user=, node=, from=, to=,
+// keyword=, limit=} → hits|nil, err, newest first. Dates are YYYY-MM-DD;
+// to includes its whole day. Each hit is a recordings.list entry plus at
+// (seconds into the recording) and text (the matching line).
+func (api *RecordingsAPI) luaSearch(L *lua.LState) int {
+	if api.denied(L) {
+		return 2
+	}
+	opts := L.CheckTable(1)
+	q := recording.Query{
+		User:    lua.LVAsString(opts.RawGetString("user")),
+		Node:    int(lua.LVAsNumber(opts.RawGetString("node"))),
+		Keyword: lua.LVAsString(opts.RawGetString("keyword")),
+		Limit:   int(lua.LVAsNumber(opts.RawGetString("limit"))),
+		Reason:  lua.LVAsString(opts.RawGetString("reason")),
+	}
+	if u := api.session.User(); u != nil {
+		q.By = u.Username
+	}
+	for key, t := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		s := lua.LVAsString(opts.RawGetString(key))
+		if s == "" {
+			continue
+		}
+		d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			L.ArgError(1, key+" must be YYYY-MM-DD")
+		}
+		if key == "to" {
+			d = d.AddDate(0, 0, 1)
+		}
+		*t = d
+	}
+
+	hits, err := api.store.Search(q)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	tbl := L.NewTable()
+	for i, h := range hits {
+		t := infoTable(L, h.Info)
+		t.RawSetString("at", lua.LNumber(int(h.At.Seconds())))
+		t.RawSetString("text", lua.LString(h.Text))
+		tbl.RawSetInt(i+1, t)
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// luaPlay handles: recordings.play(name [, speed [, max_idle]]) → true
+// when it played to the end, false when a key stopped it; nil, err
+func (api *RecordingsAPI) luaPlay(L *lua.LState) int {
+	if api.denied(L) {
+		return 2
+	}
+	speed := float64(L.OptNumber(2, 1))
+	maxIdle := L.OptInt(3, defaultMaxIdle)
+	f, err := api.store.Open(L.CheckString(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	defer f.Close()
+
+	done, err := recording.Play(api.term, f, recording.PlayOptions{
+		Speed:   speed,
+		MaxIdle: time.Duration(maxIdle) * time.Second,
+		Wait: func(d time.Duration) bool {
+			_, pressed, err := api.term.PollKey(d)
+			return !pressed && err == nil
+		},
+	})
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LBool(done))
+	return 1
+}
@@ -0,0 +1,132 @@
+package scripting
+
+import (
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/slash"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// SlashAPI exposes the slash-command parser to Lua, for menus that read
+// lines with on_input.
+type SlashAPI struct {
+	session *session.Session
+	nodeID  int
+}
+
+// NewSlashAPI creates a Lua slash-command API. Access conditions are
+// checked against the logged-in user and nodeID.
+func NewSlashAPI(sess *session.Session, nodeID int) *SlashAPI {
+	return &SlashAPI{session: sess, nodeID: nodeID}
+}
+
+// Register installs slash functions in the Lua state.
+func (api *SlashAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+
+	mod.RawSetString("commands", L.NewFunction(api.luaCommands))
+	mod.RawSetString("tokenize", L.NewFunction(api.luaTokenize))
+
+	L.SetGlobal("slash", mod)
+}
+
+func (api *SlashAPI) who() slash.Who {
+	w := slash.Who{Node: api.nodeID}
+	if u := api.session.User(); u != nil {
+		w.Level = u.SecurityLevel
+	}
+	return w
+}
+
+// luaCommands handles: slash.commands({{name, aliases, acs, usage, help,
+// min_args}, ...}) → set with parse(line) and help(), or nil, err
+func (api *SlashAPI) luaCommands(L *lua.LState) int {
+	defs := L.CheckTable(1)
+	p := slash.New()
+	var addErr error
+	defs.ForEach(func(_, v lua.LValue) {
+		t, ok := v.(*lua.LTable)
+		if !ok || addErr != nil {
+			return
+		}
+		c := slash.Command{
+			Name:    lua.LVAsString(t.RawGetString("name")),
+			ACS:     lua.LVAsString(t.RawGetString("acs")),
+			Usage:   lua.LVAsString(t.RawGetString("usage")),
+			Help:    lua.LVAsString(t.RawGetString("help")),
+			MinArgs: int(lua.LVAsNumber(t.RawGetString("min_args"))),
+		}
+		if aliases, ok := t.RawGetString("aliases").(*lua.LTable); ok {
+			aliases.ForEach(func(_, a lua.LValue) {
+				c.Aliases = append(c.Aliases, lua.LVAsString(a))
+			})
+		}
+		addErr = p.Add(c)
+	})
+	if addErr != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(addErr.Error()))
+		return 2
+	}
+
+	set := L.NewTable()
+	set.RawSetString("parse", L.NewFunction(func(L *lua.LState) int {
+		return api.luaParse(L, p)
+	}))
+	set.RawSetString("help", L.NewFunction(func(L *lua.LState) int {
+		lines := L.NewTable()
+		for i, line := range p.Help(api.who()) {
+			lines.RawSetInt(i+1, lua.LString(line))
+		}
+		L.Push(lines)
+		return 1
+	}))
+	L.Push(set)
+	return 1
+}
+
+// luaParse handles: set.parse(line) → {name, typed, args, rest}; nil for a
+// line that is not a command; nil, err for an unknown command or bad
+// arguments. set:parse(line) works too.
+func (api *SlashAPI) luaParse(L *lua.LState, p *slash.Parser) int {
+	arg := 1
+	if _, ok := L.Get(1).(*lua.LTable); ok {
+		arg = 2
+	}
+	call, err := p.Parse(L.CheckString(arg), api.who())
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if call == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	t := L.NewTable()
+	t.RawSetString("name", lua.LString(call.Command.Name))
+	t.RawSetString("typed", lua.LString(call.Name))
+	t.RawSetString("args", stringList(L, call.Args))
+	t.RawSetString("rest", lua.LString(call.Rest))
+	L.Push(t)
+	return 1
+}
+
+// luaTokenize handles: slash.tokenize(s) → {words}, or nil, err
+func (api *SlashAPI) luaTokenize(L *lua.LState) int {
+	words, err := slash.Tokenize(L.CheckString(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(stringList(L, words))
+	return 1
+}
+
+func stringList(L *lua.LState, list []string) *lua.LTable {
+	t := L.NewTable()
+	for i, s := range list {
+		t.RawSetInt(i+1, lua.LString(s))
+	}
+	return t
+}
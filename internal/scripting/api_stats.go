@@ -0,0 +1,220 @@
+package scripting
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/callers"
+	"github.com/notepid/twilight_bbs/internal/stats"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// defaultActivityDays is the window stats.activity() covers by default.
+const defaultActivityDays = 30
+
+// defaultTopCallers is how many users stats.top_callers() returns by default.
+const defaultTopCallers = 10
+
+// PublishFunc announces an event (see the event package) for the current
+// node. It is set by the menu engine.
+type PublishFunc func(name string, data interface{})
+
+// StatsAPI exposes call statistics from the callers log and the daily
+// statistics to Lua.
+type StatsAPI struct {
+	callers *callers.Repo
+	stats   *stats.Repo
+
+	// AreaConferences maps message area IDs to their conference, for
+	// stats.today(conference); nil when there are no conferences.
+	AreaConferences func() (map[int]int, error)
+}
+
+// NewStatsAPI creates a Lua stats API.
+func NewStatsAPI(callerRepo *callers.Repo, statsRepo *stats.Repo) *StatsAPI {
+	return &StatsAPI{callers: callerRepo, stats: statsRepo}
+}
+
+// Register installs stats functions in the Lua state.
+func (api *StatsAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+
+	mod.RawSetString("activity", L.NewFunction(api.luaActivity))
+	mod.RawSetString("today", L.NewFunction(api.luaToday))
+	mod.RawSetString("history", L.NewFunction(api.luaHistory))
+	mod.RawSetString("top_callers", L.NewFunction(api.luaTopCallers))
+
+	L.SetGlobal("stats", mod)
+}
+
+// luaActivity handles: stats.activity([days]) → table|nil, err
+func (api *StatsAPI) luaActivity(L *lua.LState) int {
+	days := L.OptInt(1, defaultActivityDays)
+	if days <= 0 {
+		days = defaultActivityDays
+	}
+	a, err := api.callers.Activity(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	tbl := L.NewTable()
+	tbl.RawSetString("total", lua.LNumber(a.Total))
+	tbl.RawSetString("since", lua.LString(a.Since.Local().Format("2006-01-02")))
+
+	hours := a.Hours()
+	tbl.RawSetString("hours", intsToTable(L, hours[:]))
+
+	dayList := L.NewTable()
+	for _, d := range callers.Week {
+		day := L.NewTable()
+		day.RawSetString("name", lua.LString(d.String()[:3]))
+		day.RawSetString("calls", lua.LNumber(a.Day(d)))
+		day.RawSetString("hours", intsToTable(L, a.Counts[d][:]))
+		dayList.Append(day)
+	}
+	tbl.RawSetString("days", dayList)
+
+	ranked := a.RankedHours()
+	peak := L.NewTable()
+	for _, h := range ranked[:3] {
+		if hours[h] > 0 {
+			peak.Append(lua.LNumber(h))
+		}
+	}
+	tbl.RawSetString("peak_hours", peak)
+	quiet := L.NewTable()
+	for i := len(ranked) - 1; i >= len(ranked)-3; i-- {
+		quiet.Append(lua.LNumber(ranked[i]))
+	}
+	tbl.RawSetString("quiet_hours", quiet)
+
+	lines := L.NewTable()
+	for _, line := range a.Lines() {
+		lines.Append(lua.LString(line))
+	}
+	tbl.RawSetString("heatmap", lines)
+
+	L.Push(tbl)
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaToday handles: stats.today([conference]) → table|nil, err
+//
+// The table has one field per metric (calls, new_users, posts, uploads,
+// downloads, door_launches, peak_nodes) plus date and areas, a list of
+// {id, conference, posts} for message areas posted in today. Given a
+// conference ID, areas holds only its areas and conference_posts their
+// total.
+func (api *StatsAPI) luaToday(L *lua.LState) int {
+	conf := L.OptInt(1, 0)
+	d, err := api.stats.Day(time.Now())
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	var areaConfs map[int]int
+	if api.AreaConferences != nil {
+		if areaConfs, err = api.AreaConferences(); err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+	}
+
+	tbl := L.NewTable()
+	tbl.RawSetString("date", lua.LString(d.Date))
+	for _, m := range stats.Metrics {
+		tbl.RawSetString(m, lua.LNumber(d.Totals[m]))
+	}
+	ids := make([]int, 0, len(d.AreaPosts))
+	for id := range d.AreaPosts {
+		if conf == 0 || areaConfs[id] == conf {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	areas := L.NewTable()
+	confPosts := 0
+	for _, id := range ids {
+		a := L.NewTable()
+		a.RawSetString("id", lua.LNumber(id))
+		a.RawSetString("conference", lua.LNumber(areaConfs[id]))
+		a.RawSetString("posts", lua.LNumber(d.AreaPosts[id]))
+		areas.Append(a)
+		confPosts += d.AreaPosts[id]
+	}
+	tbl.RawSetString("areas", areas)
+	if conf != 0 {
+		tbl.RawSetString("conference_posts", lua.LNumber(confPosts))
+	}
+
+	L.Push(tbl)
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaHistory handles: stats.history(metric [, days]) → table|nil, err
+//
+// Returns the metric for each of the last days days, oldest first, with
+// today last.
+func (api *StatsAPI) luaHistory(L *lua.LState) int {
+	metric := L.CheckString(1)
+	days := L.OptInt(2, defaultActivityDays)
+	if days <= 0 {
+		days = defaultActivityDays
+	}
+	if !slices.Contains(stats.Metrics, metric) {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("unknown metric: " + metric))
+		return 2
+	}
+	series, err := api.stats.Series(metric, days, time.Now())
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(intsToTable(L, series))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaTopCallers handles: stats.top_callers([n]) → table|nil, err
+//
+// Returns a list of {name, calls}, most calls first.
+func (api *StatsAPI) luaTopCallers(L *lua.LState) int {
+	n := L.OptInt(1, defaultTopCallers)
+	if n <= 0 {
+		n = defaultTopCallers
+	}
+	top, err := api.stats.TopCallers(n)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	tbl := L.NewTable()
+	for _, c := range top {
+		row := L.NewTable()
+		row.RawSetString("name", lua.LString(c.Username))
+		row.RawSetString("calls", lua.LNumber(c.Calls))
+		tbl.Append(row)
+	}
+	L.Push(tbl)
+	L.Push(lua.LNil)
+	return 2
+}
+
+func intsToTable(L *lua.LState, ns []int) *lua.LTable {
+	tbl := L.NewTable()
+	for _, n := range ns {
+		tbl.Append(lua.LNumber(n))
+	}
+	return tbl
+}
@@ -0,0 +1,137 @@
+package scripting
+
+import (
+	"strconv"
+
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/user"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// StoreAPI exposes a persistent per-user key/value store to Lua. Values
+// are scoped to the logged-in user and survive between calls.
+type StoreAPI struct {
+	repo    *user.Repo
+	session *session.Session
+}
+
+// NewStoreAPI creates a Lua store API.
+func NewStoreAPI(repo *user.Repo, sess *session.Session) *StoreAPI {
+	return &StoreAPI{repo: repo, session: sess}
+}
+
+// Register installs store functions in the Lua state.
+func (api *StoreAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+
+	mod.RawSetString("get", L.NewFunction(api.luaGet))
+	mod.RawSetString("set", L.NewFunction(api.luaSet))
+	mod.RawSetString("del", L.NewFunction(api.luaDel))
+	mod.RawSetString("keys", L.NewFunction(api.luaKeys))
+
+	L.SetGlobal("store", mod)
+}
+
+func (api *StoreAPI) luaGet(L *lua.LState) int {
+	key := L.CheckString(1)
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	s, err := api.repo.GetSetting(u.ID, key)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if s == nil {
+		L.Push(L.Get(2)) // optional default
+		L.Push(lua.LNil)
+		return 2
+	}
+	L.Push(settingToLua(s))
+	L.Push(lua.LNil)
+	return 2
+}
+
+func (api *StoreAPI) luaSet(L *lua.LState) int {
+	key := L.CheckString(1)
+	val := L.Get(2)
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+
+	var err error
+	switch v := val.(type) {
+	case *lua.LNilType:
+		err = api.repo.DeleteSetting(u.ID, key)
+	case lua.LString:
+		err = api.repo.SetSetting(u.ID, key, user.SettingString, string(v))
+	case lua.LNumber:
+		err = api.repo.SetSetting(u.ID, key, user.SettingNumber, v.String())
+	case lua.LBool:
+		err = api.repo.SetSetting(u.ID, key, user.SettingBool, strconv.FormatBool(bool(v)))
+	default:
+		L.ArgError(2, "string, number, boolean or nil expected, got "+val.Type().String())
+		return 0
+	}
+	if err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+func (api *StoreAPI) luaDel(L *lua.LState) int {
+	key := L.CheckString(1)
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	if err := api.repo.DeleteSetting(u.ID, key); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+func (api *StoreAPI) luaKeys(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	list, err := api.repo.ListSettings(u.ID)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	tbl := L.NewTable()
+	for i, s := range list {
+		tbl.RawSetInt(i+1, lua.LString(s.Key))
+	}
+	L.Push(tbl)
+	L.Push(lua.LNil)
+	return 2
+}
+
+func settingToLua(s *user.Setting) lua.LValue {
+	switch s.Kind {
+	case user.SettingNumber:
+		if f, err := strconv.ParseFloat(s.Value, 64); err == nil {
+			return lua.LNumber(f)
+		}
+	case user.SettingBool:
+		return lua.LBool(s.Value == "true")
+	}
+	return lua.LString(s.Value)
+}
@@ -0,0 +1,96 @@
+package scripting
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/textview"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// maxViewFile is the most of a file node:view_text reads.
+const maxViewFile = 1 << 20
+
+// luaViewText handles: node:view_text(text_or_path [, opts]) → line | nil,
+// err. It shows text in a scrolling box until the caller quits and returns
+// the line that was at the top, for opts.start next time. With opts.file
+// the argument is the path of a file to show; callers' text is never
+// taken for a path.
+//
+// opts: field (a template field to fill), or row, col, width, height;
+// status (row for the status line, false for none), title, start, wrap
+// (default true) and file.
+func (api *NodeAPI) luaViewText(L *lua.LState) int {
+	text := L.CheckString(2)
+	opts := L.OptTable(3, L.NewTable())
+
+	if lua.LVAsBool(opts.RawGetString("file")) {
+		data, err := readViewFile(text)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		text = string(data)
+	}
+
+	o := textview.Options{
+		Row:    int(lua.LVAsNumber(opts.RawGetString("row"))),
+		Col:    int(lua.LVAsNumber(opts.RawGetString("col"))),
+		Width:  int(lua.LVAsNumber(opts.RawGetString("width"))),
+		Height: int(lua.LVAsNumber(opts.RawGetString("height"))),
+		Title:  lua.LVAsString(opts.RawGetString("title")),
+		Start:  int(lua.LVAsNumber(opts.RawGetString("start"))),
+		NoWrap: opts.RawGetString("wrap") == lua.LFalse,
+	}
+	if id := lua.LVAsString(opts.RawGetString("field")); id != "" {
+		var f ansi.Field
+		ok := false
+		if api.OnGetField != nil {
+			f, ok = api.OnGetField(strings.TrimSpace(id))
+		}
+		if !ok {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(fmt.Sprintf("no field %q on this screen", id)))
+			return 2
+		}
+		o.Row, o.Col, o.Width, o.Height = f.Row, f.Col, f.MaxLen, max(f.Height, 1)
+	}
+	switch v := opts.RawGetString("status").(type) {
+	case lua.LNumber:
+		o.StatusRow = int(v)
+	case lua.LBool:
+		if !v {
+			o.StatusRow = -1
+		}
+	}
+
+	line, err := textview.Run(api.term, textview.Decode([]byte(text)), o)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LNumber(line))
+	return 1
+}
+
+// readViewFile reads up to maxViewFile bytes of a regular file.
+func readViewFile(path string) ([]byte, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxViewFile))
+}
@@ -0,0 +1,64 @@
+package scripting
+
+import (
+	"github.com/notepid/twilight_bbs/internal/session"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Tour is the guided tour of the board shown to new callers.
+type Tour struct {
+	FirstCall bool // start the tour after login on a caller's first call
+	Steps     []TourStep
+}
+
+// TourStep is one stop of the tour: art (usually the menu it describes)
+// followed by text.
+type TourStep struct {
+	Title string
+	Art   string
+	Text  string
+}
+
+// TourAPI exposes the tour to Lua.
+type TourAPI struct {
+	tour    *Tour
+	session *session.Session
+}
+
+// NewTourAPI creates a Lua tour API.
+func NewTourAPI(tour *Tour, sess *session.Session) *TourAPI {
+	return &TourAPI{tour: tour, session: sess}
+}
+
+// Register installs tour functions in the Lua state.
+func (api *TourAPI) Register(L *lua.LState) {
+	mod := L.NewTable()
+
+	mod.RawSetString("steps", L.NewFunction(api.luaSteps))
+	mod.RawSetString("due", L.NewFunction(api.luaDue))
+
+	L.SetGlobal("tour", mod)
+}
+
+// luaSteps handles: tour.steps() → {{title, art, text}, ...}
+func (api *TourAPI) luaSteps(L *lua.LState) int {
+	tbl := L.NewTable()
+	for i, st := range api.tour.Steps {
+		t := L.NewTable()
+		t.RawSetString("title", lua.LString(st.Title))
+		t.RawSetString("art", lua.LString(st.Art))
+		t.RawSetString("text", lua.LString(st.Text))
+		tbl.RawSetInt(i+1, t)
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// luaDue handles: tour.due() → bool, true when the tour should start by
+// itself: on the first call of the logged-in user.
+func (api *TourAPI) luaDue(L *lua.LState) int {
+	u := api.session.User()
+	due := u != nil && api.tour.FirstCall && len(api.tour.Steps) > 0 && u.TotalCalls <= 1
+	L.Push(lua.LBool(due))
+	return 1
+}
@@ -1,9 +1,14 @@
 package scripting
 
 import (
+	"errors"
 	"io"
 	"log"
+	"time"
 
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/picker"
+	"github.com/notepid/twilight_bbs/internal/session"
 	"github.com/notepid/twilight_bbs/internal/transfer"
 	lua "github.com/yuin/gopher-lua"
 )
@@ -13,14 +18,31 @@ type TransferAPI struct {
 	config       *transfer.Config
 	binaryMode   func() (io.ReadWriter, func(), bool) // returns raw RW, cleanup, isTelnet
 	nodeID       int
+	session      *session.Session // transferred bytes are counted here
+
+	// Publish announces completed transfers on the event bus
+	Publish PublishFunc
+
+	// Suspend pauses the engine while a transfer has the connection
+	Suspend SuspendFunc
+
+	// Pick shows the protocol picker (transfer.pick_protocol)
+	Pick PickFunc
+
+	protocol string           // key of the caller's chosen protocol, "" = the first
+	last     *transfer.Result // the caller's latest completed transfer
 }
 
+// progressLogEvery is how often a running transfer's progress is logged.
+const progressLogEvery = 10 * time.Second
+
 // NewTransferAPI creates a Lua transfer API.
-func NewTransferAPI(config *transfer.Config, binaryMode func() (io.ReadWriter, func(), bool), nodeID int) *TransferAPI {
+func NewTransferAPI(config *transfer.Config, binaryMode func() (io.ReadWriter, func(), bool), nodeID int, sess *session.Session) *TransferAPI {
 	return &TransferAPI{
 		config:     config,
 		binaryMode: binaryMode,
 		nodeID:     nodeID,
+		session:    sess,
 	}
 }
 
@@ -31,10 +53,18 @@ func (api *TransferAPI) Register(L *lua.LState) {
 	mod.RawSetString("send", L.NewFunction(api.luaSend))
 	mod.RawSetString("receive", L.NewFunction(api.luaReceive))
 	mod.RawSetString("available", L.NewFunction(api.luaAvailable))
+	mod.RawSetString("protocols", L.NewFunction(api.luaProtocols))
+	mod.RawSetString("protocol", L.NewFunction(api.luaProtocol))
+	mod.RawSetString("set_protocol", L.NewFunction(api.luaSetProtocol))
+	mod.RawSetString("pick_protocol", L.NewFunction(api.luaPickProtocol))
+	mod.RawSetString("last", L.NewFunction(api.luaLast))
 
 	L.SetGlobal("transfer", mod)
 }
 
+// errNoProtocol is returned when no transfer program is installed.
+var errNoProtocol = errors.New("no transfer protocol is installed")
+
 // luaSend handles: transfer.send(filepath | {filepath1, filepath2, ...} | filepath1, filepath2, ...)
 // → (bool, errString|nil)
 //
@@ -71,33 +101,45 @@ func (api *TransferAPI) luaSend(L *lua.LState) int {
 		return 2
 	}
 
-	if !api.config.Available() {
+	if _, err := api.SendFiles(filePaths); err != nil {
 		L.Push(lua.LBool(false))
-		L.Push(lua.LString("SEXYZ binary not found"))
+		L.Push(lua.LString(err.Error()))
 		return 2
 	}
+	L.Push(lua.LBool(true))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// SendFiles sends files to the caller in one batch with the chosen
+// protocol, counting the bytes and announcing the download.
+// files.download_tagged uses it.
+func (api *TransferAPI) SendFiles(filePaths []string) (*transfer.Result, error) {
+	p := api.current()
+	if p == nil {
+		return nil, errNoProtocol
+	}
 
+	defer suspend(api.Suspend, api.session, session.BusyTransfer)()
 	rw, cleanup, isTelnet := api.binaryMode()
 	if rw == nil {
-		L.Push(lua.LBool(false))
-		L.Push(lua.LString("connection does not support binary mode"))
-		return 2
+		return nil, errors.New("connection does not support binary mode")
 	}
 	defer cleanup()
 
 	log.Printf("[transfer] Node %d: sending %d file(s)", api.nodeID, len(filePaths))
 
-	result, err := api.config.Send(rw, isTelnet, filePaths...)
+	result, err := api.config.Send(p, rw, isTelnet, api.progress("sending"), filePaths...)
 	if err != nil {
-		L.Push(lua.LBool(false))
-		L.Push(lua.LString(err.Error()))
-		return 2
+		return nil, err
 	}
+	api.last = result
 
-	_ = result
-	L.Push(lua.LBool(true))
-	L.Push(lua.LNil)
-	return 2
+	api.session.AddTransfer(0, totalSize(result.Files))
+	if api.Publish != nil {
+		api.Publish(event.Download, len(result.Files))
+	}
+	return result, nil
 }
 
 // luaReceive handles: transfer.receive(uploadDir) → (table|nil, errString|nil)
@@ -109,12 +151,14 @@ func (api *TransferAPI) luaSend(L *lua.LState) int {
 func (api *TransferAPI) luaReceive(L *lua.LState) int {
 	uploadDir := L.CheckString(1)
 
-	if !api.config.Available() {
+	p := api.current()
+	if p == nil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString("SEXYZ binary not found"))
+		L.Push(lua.LString(errNoProtocol.Error()))
 		return 2
 	}
 
+	defer suspend(api.Suspend, api.session, session.BusyTransfer)()
 	rw, cleanup, isTelnet := api.binaryMode()
 	if rw == nil {
 		L.Push(lua.LNil)
@@ -125,12 +169,18 @@ func (api *TransferAPI) luaReceive(L *lua.LState) int {
 
 	log.Printf("[transfer] Node %d: receiving files into %s", api.nodeID, uploadDir)
 
-	result, err := api.config.Receive(rw, isTelnet, uploadDir)
+	result, err := api.config.Receive(p, rw, isTelnet, uploadDir, api.progress("receiving"))
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(err.Error()))
 		return 2
 	}
+	api.last = result
+
+	api.session.AddTransfer(totalSize(result.Files), 0)
+	if api.Publish != nil {
+		api.Publish(event.Upload, len(result.Files))
+	}
 
 	// Build a Lua table of received files.
 	tbl := L.NewTable()
@@ -151,3 +201,120 @@ func (api *TransferAPI) luaAvailable(L *lua.LState) int {
 	L.Push(lua.LBool(api.config.Available()))
 	return 1
 }
+
+// progress returns the callback a transfer reports to. The terminal belongs
+// to the protocol while it runs, so progress goes to the log.
+func (api *TransferAPI) progress(verb string) func(transfer.Progress) {
+	var logged time.Duration
+	return func(p transfer.Progress) {
+		if p.Elapsed-logged < progressLogEvery {
+			return
+		}
+		logged = p.Elapsed
+		if pct := p.Percent(); pct >= 0 {
+			log.Printf("[transfer] Node %d: %s %s, %d%%, %d cps", api.nodeID, verb, p.File, pct, p.CPS())
+			return
+		}
+		log.Printf("[transfer] Node %d: %s, %d bytes out, %d in, %d cps", api.nodeID, verb, p.Sent, p.Received, p.CPS())
+	}
+}
+
+// luaLast handles: transfer.last() → the caller's latest completed
+// transfer as {files, bytes, sent, received, seconds, cps}, or nil before
+// the first one. Menus show it once the terminal is back.
+func (api *TransferAPI) luaLast(L *lua.LState) int {
+	r := api.last
+	if r == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	t := L.NewTable()
+	t.RawSetString("files", lua.LNumber(len(r.Files)))
+	t.RawSetString("bytes", lua.LNumber(r.Bytes()))
+	t.RawSetString("sent", lua.LNumber(r.Sent))
+	t.RawSetString("received", lua.LNumber(r.Received))
+	t.RawSetString("seconds", lua.LNumber(r.Elapsed.Seconds()))
+	t.RawSetString("cps", lua.LNumber(r.CPS()))
+	L.Push(t)
+	return 1
+}
+
+func totalSize(files []transfer.TransferredFile) int64 {
+	var n int64
+	for _, f := range files {
+		n += f.Size
+	}
+	return n
+}
+
+// current returns the caller's chosen protocol, or the first installed one.
+func (api *TransferAPI) current() *transfer.Protocol {
+	if p := api.config.Protocol(api.protocol); p != nil {
+		return p
+	}
+	if all := api.config.Protocols(); len(all) > 0 {
+		return all[0]
+	}
+	return nil
+}
+
+func protocolToTable(L *lua.LState, p *transfer.Protocol) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("key", lua.LString(p.Key))
+	t.RawSetString("name", lua.LString(p.Name))
+	t.RawSetString("send", lua.LBool(len(p.Send) > 0))
+	t.RawSetString("receive", lua.LBool(len(p.Receive) > 0))
+	return t
+}
+
+// luaProtocols handles: transfer.protocols() → list of installed protocols,
+// each {key, name, send, receive}.
+func (api *TransferAPI) luaProtocols(L *lua.LState) int {
+	tbl := L.NewTable()
+	for _, p := range api.config.Protocols() {
+		tbl.Append(protocolToTable(L, p))
+	}
+	L.Push(tbl)
+	return 1
+}
+
+// luaProtocol handles: transfer.protocol() → the protocol transfers use, or
+// nil when none is installed.
+func (api *TransferAPI) luaProtocol(L *lua.LState) int {
+	p := api.current()
+	if p == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(protocolToTable(L, p))
+	return 1
+}
+
+// luaSetProtocol handles: transfer.set_protocol(key) → err. The choice
+// lasts for the rest of the call.
+func (api *TransferAPI) luaSetProtocol(L *lua.LState) int {
+	key := L.CheckString(1)
+	if api.config.Protocol(key) == nil {
+		L.Push(lua.LString("unknown protocol " + key))
+		return 1
+	}
+	api.protocol = key
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaPickProtocol handles: transfer.pick_protocol([title]) → protocol, err,
+// and makes the chosen protocol the one transfers use.
+func (api *TransferAPI) luaPickProtocol(L *lua.LState) int {
+	title := L.OptString(1, "Select a transfer protocol")
+	protocols := api.config.Protocols()
+	items := make([]picker.Item, 0, len(protocols))
+	for i, p := range protocols {
+		items = append(items, picker.Item{ID: i, Label: p.Name, Detail: "[" + p.Key + "]"})
+	}
+	return pickResult(L, api.Pick, title, items, func(it picker.Item) lua.LValue {
+		p := protocols[it.ID]
+		api.protocol = p.Key
+		return protocolToTable(L, p)
+	})
+}
@@ -1,27 +1,69 @@
 package scripting
 
 import (
+	"fmt"
+
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/terminal"
 	"github.com/notepid/twilight_bbs/internal/user"
 	lua "github.com/yuin/gopher-lua"
 )
 
 // UserAPI exposes user-related functions to Lua.
 type UserAPI struct {
-	repo        *user.Repo
-	currentUser *user.User
+	repo    *user.Repo
+	session *session.Session
 
 	// Callback when user logs in
 	OnLogin func(u *user.User)
+
+	// PreAuth returns the credentials the caller authenticated with at the
+	// transport level (SSH), if any.
+	PreAuth func() (username, password string)
+
+	// Pick shows the user picker (users.pick)
+	Pick PickFunc
+
+	// Publish announces registrations on the event bus
+	Publish PublishFunc
+
+	// SendResetCode mails u a password reset code; nil when the board
+	// sends no mail.
+	SendResetCode func(u *user.User) error
+	resetRequests int
+
+	// pending is a user who gave the right password but must still pass
+	// two-factor verification, or enroll when the policy requires it.
+	pending      *user.User
+	pendingTries int
 }
 
-// NewUserAPI creates a Lua user API.
-func NewUserAPI(repo *user.Repo) *UserAPI {
-	return &UserAPI{repo: repo}
+// maxTOTPTries is how many wrong codes end a pending login; the caller
+// must then give their password again.
+const maxTOTPTries = 3
+
+// Errors users.login returns while a login waits on a second factor.
+const (
+	errTOTPRequired = "totp required"
+	errTOTPEnroll   = "totp enrollment required"
+)
+
+// maxResetRequests is how many reset codes one call may ask for.
+const maxResetRequests = 3
+
+// NewUserAPI creates a Lua user API. Logins are recorded in sess.
+func NewUserAPI(repo *user.Repo, sess *session.Session) *UserAPI {
+	return &UserAPI{repo: repo, session: sess}
 }
 
-// SetCurrentUser updates the current user reference.
-func (api *UserAPI) SetCurrentUser(u *user.User) {
-	api.currentUser = u
+// login records u in the session and notifies OnLogin, which may still
+// refuse the login by clearing the session.
+func (api *UserAPI) login(u *user.User) {
+	api.session.SetUser(u)
+	if api.OnLogin != nil {
+		api.OnLogin(u)
+	}
 }
 
 // Register installs user functions in the Lua state.
@@ -29,12 +71,38 @@ func (api *UserAPI) Register(L *lua.LState) {
 	userMod := L.NewTable()
 
 	userMod.RawSetString("login", L.NewFunction(api.luaLogin))
+	userMod.RawSetString("login_preauth", L.NewFunction(api.luaLoginPreAuth))
 	userMod.RawSetString("register", L.NewFunction(api.luaRegister))
 	userMod.RawSetString("exists", L.NewFunction(api.luaExists))
 	userMod.RawSetString("get_current", L.NewFunction(api.luaGetCurrent))
 	userMod.RawSetString("update_profile", L.NewFunction(api.luaUpdateProfile))
 	userMod.RawSetString("update_password", L.NewFunction(api.luaUpdatePassword))
+	userMod.RawSetString("set_birthday", L.NewFunction(api.luaSetBirthday))
+	userMod.RawSetString("set_baud", L.NewFunction(api.luaSetBaud))
+	userMod.RawSetString("set_charset", L.NewFunction(api.luaSetCharset))
+	userMod.RawSetString("set_palette", L.NewFunction(api.luaSetPalette))
+	userMod.RawSetString("set_colors", L.NewFunction(api.luaSetColors))
+	userMod.RawSetString("set_mail_notify", L.NewFunction(api.luaSetMailNotify))
+	userMod.RawSetString("macros", L.NewFunction(api.luaMacros))
+	userMod.RawSetString("set_macro", L.NewFunction(api.luaSetMacro))
+	userMod.RawSetString("delete_macro", L.NewFunction(api.luaDeleteMacro))
+	userMod.RawSetString("reset_available", L.NewFunction(api.luaResetAvailable))
+	userMod.RawSetString("request_reset", L.NewFunction(api.luaRequestReset))
+	userMod.RawSetString("reset_password", L.NewFunction(api.luaResetPassword))
+	userMod.RawSetString("check_password", L.NewFunction(api.luaCheckPassword))
+	userMod.RawSetString("password_rules", L.NewFunction(api.luaPasswordRules))
+	userMod.RawSetString("totp_pending", L.NewFunction(api.luaTOTPPending))
+	userMod.RawSetString("totp_verify", L.NewFunction(api.luaTOTPVerify))
+	userMod.RawSetString("totp_enroll", L.NewFunction(api.luaTOTPEnroll))
+	userMod.RawSetString("totp_confirm", L.NewFunction(api.luaTOTPConfirm))
+	userMod.RawSetString("totp_status", L.NewFunction(api.luaTOTPStatus))
+	userMod.RawSetString("totp_disable", L.NewFunction(api.luaTOTPDisable))
 	userMod.RawSetString("list", L.NewFunction(api.luaList))
+	userMod.RawSetString("pick", L.NewFunction(api.luaPick))
+	userMod.RawSetString("profile", L.NewFunction(api.luaProfile))
+	userMod.RawSetString("update_bio", L.NewFunction(api.luaUpdateBio))
+	userMod.RawSetString("set_avatar", L.NewFunction(api.luaSetAvatar))
+	userMod.RawSetString("set_privacy", L.NewFunction(api.luaSetPrivacy))
 
 	L.SetGlobal("users", userMod)
 }
@@ -49,12 +117,57 @@ func (api *UserAPI) luaLogin(L *lua.LState) int {
 		L.Push(lua.LString(err.Error()))
 		return 2
 	}
+	return api.passwordLogin(L, u)
+}
 
-	api.currentUser = u
-	if api.OnLogin != nil {
-		api.OnLogin(u)
+// luaLoginPreAuth logs in with the SSH credentials, giving SSH callers a
+// one-step login.
+func (api *UserAPI) luaLoginPreAuth(L *lua.LState) int {
+	var username, password string
+	if api.PreAuth != nil {
+		username, password = api.PreAuth()
+	}
+	if username == "" || password == "" {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("no SSH credentials"))
+		return 2
+	}
+
+	u, err := api.repo.Authenticate(username, password)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	return api.passwordLogin(L, u)
+}
+
+// passwordLogin logs in u after a correct password, unless a second
+// factor is needed first; then u is kept pending for users.totp_verify or
+// users.totp_confirm and the login returns errTOTPRequired or errTOTPEnroll.
+func (api *UserAPI) passwordLogin(L *lua.LState, u *user.User) int {
+	api.pending, api.pendingTries = nil, 0
+	enabled, err := api.repo.TOTPEnabled(u.ID)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	switch {
+	case enabled:
+		api.pending = u
+		L.Push(lua.LNil)
+		L.Push(lua.LString(errTOTPRequired))
+		return 2
+	case api.repo.TOTPRequired(u):
+		api.pending = u
+		L.Push(lua.LNil)
+		L.Push(lua.LString(errTOTPEnroll))
+		return 2
 	}
 
+	api.login(u)
+
 	L.Push(api.userToTable(L, u))
 	L.Push(lua.LNil)
 	return 2
@@ -107,12 +220,12 @@ func (api *UserAPI) luaRegister(L *lua.LState) int {
 		L.Push(lua.LString(err.Error()))
 		return 2
 	}
-
-	api.currentUser = u
-	if api.OnLogin != nil {
-		api.OnLogin(u)
+	if api.Publish != nil {
+		api.Publish(event.NewUser, u)
 	}
 
+	api.login(u)
+
 	L.Push(api.userToTable(L, u))
 	L.Push(lua.LNil)
 	return 2
@@ -125,16 +238,18 @@ func (api *UserAPI) luaExists(L *lua.LState) int {
 }
 
 func (api *UserAPI) luaGetCurrent(L *lua.LState) int {
-	if api.currentUser == nil {
+	u := api.session.User()
+	if u == nil {
 		L.Push(lua.LNil)
 		return 1
 	}
-	L.Push(api.userToTable(L, api.currentUser))
+	L.Push(api.userToTable(L, u))
 	return 1
 }
 
 func (api *UserAPI) luaUpdateProfile(L *lua.LState) int {
-	if api.currentUser == nil {
+	u := api.session.User()
+	if u == nil {
 		L.Push(lua.LString("not logged in"))
 		return 1
 	}
@@ -157,20 +272,250 @@ func (api *UserAPI) luaUpdateProfile(L *lua.LState) int {
 		return 1
 	}
 
-	if err := api.repo.UpdateProfile(api.currentUser.ID, realName, location, email); err != nil {
+	if err := api.repo.UpdateProfile(u.ID, realName, location, email); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+
+	u.RealName = realName
+	u.Location = location
+	u.Email = email
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetBirthday handles: users.set_birthday(mmdd) → err
+func (api *UserAPI) luaSetBirthday(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	mmdd := L.CheckString(1)
+	if err := api.repo.SetBirthday(u.ID, mmdd); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	u.Birthday = mmdd
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetBaud handles: users.set_baud(bps) → err. It saves the preference;
+// node:set_baud applies it to the current call.
+func (api *UserAPI) luaSetBaud(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	bps := L.CheckInt(1)
+	if !terminal.ValidBaud(bps) {
+		L.Push(lua.LString(fmt.Sprintf("unsupported baud rate %d", bps)))
+		return 1
+	}
+	if err := api.repo.SetBaudRate(u.ID, bps); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	u.BaudRate = bps
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetCharset handles: users.set_charset(name) → err. It saves the
+// preference; node:set_charset applies it to the current call.
+func (api *UserAPI) luaSetCharset(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	cs, ok := terminal.ParseCharset(L.CheckString(1))
+	if !ok {
+		L.Push(lua.LString("unknown charset " + L.CheckString(1)))
+		return 1
+	}
+	if err := api.repo.SetCharset(u.ID, string(cs)); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	u.Charset = string(cs)
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetPalette handles: users.set_palette(name) → err. It saves the
+// preference; node:set_palette applies it to the current call.
+func (api *UserAPI) luaSetPalette(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	p, ok := terminal.ParsePalette(L.CheckString(1))
+	if !ok {
+		L.Push(lua.LString("unknown palette " + L.CheckString(1)))
+		return 1
+	}
+	if err := api.repo.SetPalette(u.ID, string(p)); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	u.Palette = string(p)
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetColors handles: users.set_colors(depth) → err. It saves the
+// preference; node:set_colors applies it to the current call.
+func (api *UserAPI) luaSetColors(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	d, ok := terminal.ParseColorDepth(L.CheckString(1))
+	if !ok {
+		L.Push(lua.LString("unknown color depth " + L.CheckString(1)))
+		return 1
+	}
+	if err := api.repo.SetColors(u.ID, string(d)); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	u.Colors = string(d)
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaSetMailNotify handles: users.set_mail_notify(on) → err.
+func (api *UserAPI) luaSetMailNotify(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	on := L.CheckBool(1)
+	if err := api.repo.SetMailNotify(u.ID, on); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	u.MailNotify = on
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaMacros handles: users.macros() → {{name, keys}, ...}, err.
+func (api *UserAPI) luaMacros(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	list, err := api.repo.Macros(u.ID)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	tbl := L.NewTable()
+	for i, m := range list {
+		row := L.NewTable()
+		row.RawSetString("name", lua.LString(m.Name))
+		row.RawSetString("keys", lua.LString(m.Keys))
+		tbl.RawSetInt(i+1, row)
+	}
+	L.Push(tbl)
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaSetMacro handles: users.set_macro(name, keys) → err.
+func (api *UserAPI) luaSetMacro(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	if err := api.repo.SetMacro(u.ID, L.CheckString(1), L.CheckString(2)); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaDeleteMacro handles: users.delete_macro(name) → err.
+func (api *UserAPI) luaDeleteMacro(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	if err := api.repo.DeleteMacro(u.ID, L.CheckString(1)); err != nil {
 		L.Push(lua.LString(err.Error()))
 		return 1
 	}
+	L.Push(lua.LNil)
+	return 1
+}
 
-	api.currentUser.RealName = realName
-	api.currentUser.Location = location
-	api.currentUser.Email = email
+// luaResetAvailable handles: users.reset_available() → bool.
+func (api *UserAPI) luaResetAvailable(L *lua.LState) int {
+	L.Push(lua.LBool(api.SendResetCode != nil))
+	return 1
+}
+
+// luaRequestReset handles: users.request_reset(username) → err. It mails
+// the user a code for users.reset_password.
+func (api *UserAPI) luaRequestReset(L *lua.LState) int {
+	username := L.CheckString(1)
+	if api.SendResetCode == nil {
+		L.Push(lua.LString("password reset by email is not available"))
+		return 1
+	}
+	if api.resetRequests >= maxResetRequests {
+		L.Push(lua.LString("too many reset requests this call"))
+		return 1
+	}
+	api.resetRequests++
+	u, err := api.repo.GetByUsername(username)
+	if err != nil {
+		L.Push(lua.LString("no such user"))
+		return 1
+	}
+	if err := api.SendResetCode(u); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaResetPassword handles: users.reset_password(username, code,
+// new_password) → err.
+func (api *UserAPI) luaResetPassword(L *lua.LState) int {
+	username := L.CheckString(1)
+	code := L.CheckString(2)
+	password := L.CheckString(3)
+	u, err := api.repo.GetByUsername(username)
+	if err != nil {
+		L.Push(lua.LString(user.ErrResetCode.Error()))
+		return 1
+	}
+	if err := api.repo.ResetPassword(u.ID, code, password); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
 	L.Push(lua.LNil)
 	return 1
 }
 
 func (api *UserAPI) luaUpdatePassword(L *lua.LState) int {
-	if api.currentUser == nil {
+	u := api.session.User()
+	if u == nil {
 		L.Push(lua.LString("not logged in"))
 		return 1
 	}
@@ -183,7 +528,199 @@ func (api *UserAPI) luaUpdatePassword(L *lua.LState) int {
 		return 1
 	}
 
-	if err := api.repo.UpdatePassword(api.currentUser.ID, newPassword); err != nil {
+	if err := api.repo.UpdatePassword(u.ID, newPassword); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaCheckPassword checks a password against the password policy before
+// it is submitted: users.check_password(password [, username]) returns nil
+// or the reason it would be refused. The username defaults to the logged-in
+// user's.
+func (api *UserAPI) luaCheckPassword(L *lua.LState) int {
+	password := L.CheckString(1)
+	username := L.OptString(2, "")
+	if u := api.session.User(); username == "" && u != nil {
+		username = u.Username
+	}
+
+	validator := &ValidateInput{}
+	if err := validator.ValidatePassword(password); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	if err := api.repo.PasswordPolicy().Check(username, password); err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	L.Push(lua.LNil)
+	return 1
+}
+
+// luaPasswordRules returns the password policy as text for prompts.
+func (api *UserAPI) luaPasswordRules(L *lua.LState) int {
+	L.Push(lua.LString(api.repo.PasswordPolicy().Describe()))
+	return 1
+}
+
+// luaTOTPPending returns what a login pending on a second factor needs:
+// "verify" for a code, "enroll" for mandatory enrollment, or nil.
+func (api *UserAPI) luaTOTPPending(L *lua.LState) int {
+	u := api.pending
+	if u == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	if enabled, _ := api.repo.TOTPEnabled(u.ID); enabled {
+		L.Push(lua.LString("verify"))
+	} else {
+		L.Push(lua.LString("enroll"))
+	}
+	return 1
+}
+
+// luaTOTPVerify completes a pending login with a code from the user's
+// authenticator app or a backup code: users.totp_verify(code) returns
+// user, err like users.login.
+func (api *UserAPI) luaTOTPVerify(L *lua.LState) int {
+	code := L.CheckString(1)
+	u := api.pending
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("no login pending"))
+		return 2
+	}
+	ok, err := api.repo.VerifyTOTP(u.ID, code)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if !ok {
+		api.pendingTries++
+		msg := "invalid code"
+		if api.pendingTries >= maxTOTPTries {
+			api.pending = nil
+			msg = "too many attempts"
+		}
+		L.Push(lua.LNil)
+		L.Push(lua.LString(msg))
+		return 2
+	}
+
+	api.pending = nil
+	api.login(u)
+
+	L.Push(api.userToTable(L, u))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// totpUser is the user two-factor enrollment applies to: the logged-in
+// user, or one whose login is pending mandatory enrollment.
+func (api *UserAPI) totpUser() *user.User {
+	if u := api.session.User(); u != nil {
+		return u
+	}
+	return api.pending
+}
+
+// luaTOTPEnroll starts enrollment: users.totp_enroll() returns the secret
+// to type into an authenticator app and its otpauth:// URI.
+func (api *UserAPI) luaTOTPEnroll(L *lua.LState) int {
+	u := api.totpUser()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	secret, uri, err := api.repo.BeginTOTP(u)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LString(secret))
+	L.Push(lua.LString(uri))
+	return 2
+}
+
+// luaTOTPConfirm finishes enrollment with a first code from the app:
+// users.totp_confirm(code) returns the backup codes, or nil and an error.
+// A login pending on enrollment is completed.
+func (api *UserAPI) luaTOTPConfirm(L *lua.LState) int {
+	code := L.CheckString(1)
+	u := api.totpUser()
+	if u == nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("not logged in"))
+		return 2
+	}
+	codes, err := api.repo.ConfirmTOTP(u.ID, code)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if api.pending == u {
+		api.pending = nil
+		api.login(u)
+	}
+
+	tbl := L.NewTable()
+	for _, c := range codes {
+		tbl.Append(lua.LString(c))
+	}
+	L.Push(tbl)
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaTOTPStatus returns {enabled, required, backup_codes} for the
+// logged-in user, or nil.
+func (api *UserAPI) luaTOTPStatus(L *lua.LState) int {
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	enabled, _ := api.repo.TOTPEnabled(u.ID)
+	left, _ := api.repo.BackupCodesLeft(u.ID)
+	tbl := L.NewTable()
+	tbl.RawSetString("enabled", lua.LBool(enabled))
+	tbl.RawSetString("required", lua.LBool(api.repo.TOTPRequired(u)))
+	tbl.RawSetString("backup_codes", lua.LNumber(left))
+	L.Push(tbl)
+	return 1
+}
+
+// luaTOTPDisable turns off two-factor authentication for the logged-in
+// user after checking a current code: users.totp_disable(code) returns
+// nil or an error.
+func (api *UserAPI) luaTOTPDisable(L *lua.LState) int {
+	code := L.CheckString(1)
+	u := api.session.User()
+	if u == nil {
+		L.Push(lua.LString("not logged in"))
+		return 1
+	}
+	if api.repo.TOTPRequired(u) {
+		L.Push(lua.LString("two-factor authentication is required for your account"))
+		return 1
+	}
+	ok, err := api.repo.VerifyTOTP(u.ID, code)
+	if err != nil {
+		L.Push(lua.LString(err.Error()))
+		return 1
+	}
+	if !ok {
+		L.Push(lua.LString("invalid code"))
+		return 1
+	}
+	if err := api.repo.DisableTOTP(u.ID); err != nil {
 		L.Push(lua.LString(err.Error()))
 		return 1
 	}
@@ -199,8 +736,10 @@ func (api *UserAPI) luaList(L *lua.LState) int {
 	}
 
 	tbl := L.NewTable()
-	for i, u := range users {
-		tbl.RawSetInt(i+1, api.userToTable(L, u))
+	for _, u := range users {
+		if u.State != user.StateDeleted {
+			tbl.Append(api.userToTable(L, u))
+		}
 	}
 	L.Push(tbl)
 	return 1
@@ -215,11 +754,24 @@ func (api *UserAPI) userToTable(L *lua.LState, u *user.User) *lua.LTable {
 	tbl.RawSetString("location", lua.LString(u.Location))
 	tbl.RawSetString("email", lua.LString(u.Email))
 	tbl.RawSetString("level", lua.LNumber(u.SecurityLevel))
+	tbl.RawSetString("level_name", lua.LString(api.repo.LevelName(u.SecurityLevel)))
+	tbl.RawSetString("state", lua.LString(u.State))
 	tbl.RawSetString("calls", lua.LNumber(u.TotalCalls))
+	tbl.RawSetString("posts", lua.LNumber(u.TotalPosts))
+	tbl.RawSetString("time_used", lua.LNumber(u.TimeUsedSecs/60))
+	tbl.RawSetString("uploaded", lua.LNumber(u.BytesUploaded))
+	tbl.RawSetString("downloaded", lua.LNumber(u.BytesDownloaded))
 	tbl.RawSetString("ansi", lua.LBool(u.ANSIEnabled))
 	if u.LastCallAt != nil {
 		tbl.RawSetString("last_on", lua.LString(u.LastCallAt.Format("2006-01-02 15:04")))
 	}
+	tbl.RawSetString("birthday", lua.LString(u.Birthday))
+	tbl.RawSetString("baud", lua.LNumber(u.BaudRate))
+	tbl.RawSetString("charset", lua.LString(u.Charset))
+	tbl.RawSetString("palette", lua.LString(u.Palette))
+	tbl.RawSetString("colors", lua.LString(u.Colors))
+	tbl.RawSetString("mail_notify", lua.LBool(u.MailNotify))
+	tbl.RawSetString("flags", lua.LString(u.Flags))
 	tbl.RawSetString("created", lua.LString(u.CreatedAt.Format("2006-01-02")))
 	return tbl
 }
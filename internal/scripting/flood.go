@@ -0,0 +1,45 @@
+package scripting
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// FloodFunc checks an action (flood.Post, flood.Chat or flood.File) by the
+// session's user against flood control and records it when allowed. When
+// it is refused, retry is the cooldown. It is set by the menu engine; nil
+// means no limits.
+type FloodFunc func(action string) (retry time.Duration, ok bool)
+
+// flooded checks an action against flood control. When it is refused, it
+// returns the error for Lua ("too many <what>, try again in 2 minutes") and
+// the cooldown in whole seconds, which functions return after the error.
+func flooded(check FloodFunc, action, what string) (lua.LString, lua.LNumber, bool) {
+	if check == nil {
+		return "", 0, false
+	}
+	retry, ok := check(action)
+	if ok {
+		return "", 0, false
+	}
+	secs := int(math.Ceil(retry.Seconds()))
+	return lua.LString(fmt.Sprintf("too many %s, try again in %s", what, cooldown(secs))), lua.LNumber(secs), true
+}
+
+// cooldown describes a wait in the largest whole unit, rounded up.
+func cooldown(secs int) string {
+	unit, n := "second", secs
+	switch {
+	case secs > 3600:
+		unit, n = "hour", (secs+3599)/3600
+	case secs > 60:
+		unit, n = "minute", (secs+59)/60
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
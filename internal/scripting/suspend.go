@@ -0,0 +1,18 @@
+package scripting
+
+import "github.com/notepid/twilight_bbs/internal/session"
+
+// SuspendFunc hands the terminal to a door or file transfer for activity
+// (session.BusyDoor or session.BusyTransfer) and returns the function that
+// takes it back. It is set by the menu engine.
+type SuspendFunc func(activity string) (resume func())
+
+// suspend calls fn, or only marks the session busy when no engine is
+// attached.
+func suspend(fn SuspendFunc, sess *session.Session, activity string) func() {
+	if fn != nil {
+		return fn(activity)
+	}
+	sess.SetBusy(activity)
+	return func() { sess.SetBusy("") }
+}
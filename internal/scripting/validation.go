@@ -29,6 +29,13 @@ func (v *ValidateInput) ValidateString(value, fieldName string, maxLen int) erro
 	if !utf8.ValidString(value) {
 		return fmt.Errorf("%s contains invalid UTF-8", fieldName)
 	}
+	// C1 controls are what stray high-bit bytes from a client in the
+	// wrong charset decode to; no text needs them.
+	for _, r := range value {
+		if r >= 0x80 && r < 0xa0 {
+			return fmt.Errorf("%s contains control characters", fieldName)
+		}
+	}
 	
 	length := utf8.RuneCountInString(value)
 	if length > maxLen {
@@ -59,17 +66,10 @@ func (v *ValidateInput) ValidateUsername(username string) error {
 	return nil
 }
 
-// ValidatePassword checks password requirements.
+// ValidatePassword checks password encoding and length limits. Strength
+// rules are the user repo's password policy.
 func (v *ValidateInput) ValidatePassword(password string) error {
-	if err := v.ValidateString(password, "password", MaxPasswordLen); err != nil {
-		return err
-	}
-	
-	if len(password) < 6 {
-		return fmt.Errorf("password too short (minimum 6 characters)")
-	}
-	
-	return nil
+	return v.ValidateString(password, "password", MaxPasswordLen)
 }
 
 // ValidateEmail checks basic email format.
@@ -158,8 +158,8 @@ func (v *ValidateInput) SanitizeForDisplay(input string) string {
 		// Keep printable characters, newlines, and tabs
 		if (r >= 32 && r < 127) || r == '\n' || r == '\r' || r == '\t' {
 			result.WriteRune(r)
-		} else if r >= 128 {
-			// Keep valid UTF-8 high characters
+		} else if r >= 0xa0 {
+			// Keep valid UTF-8 high characters, but not C1 controls
 			result.WriteRune(r)
 		}
 		// Skip other control characters
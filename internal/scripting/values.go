@@ -0,0 +1,101 @@
+package scripting
+
+import (
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// maxValueDepth bounds table nesting when copying values out of a VM, which
+// also stops self-referencing tables.
+const maxValueDepth = 16
+
+// ToGo copies a Lua value into plain Go values so it can outlive the VM that
+// created it (each menu runs in a fresh VM). Supported values are nil,
+// booleans, numbers, strings and tables of those. Tables whose keys are
+// 1..n become []interface{}; tables with string keys become
+// map[string]interface{}.
+func ToGo(v lua.LValue) (interface{}, error) {
+	return toGo(v, 0)
+}
+
+func toGo(v lua.LValue, depth int) (interface{}, error) {
+	switch v := v.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		if depth >= maxValueDepth {
+			return nil, fmt.Errorf("table nested deeper than %d levels", maxValueDepth)
+		}
+		return tableToGo(v, depth+1)
+	default:
+		return nil, fmt.Errorf("cannot store a %s value", v.Type())
+	}
+}
+
+func tableToGo(t *lua.LTable, depth int) (interface{}, error) {
+	n := t.Len()
+	count := 0
+	var err error
+	t.ForEach(func(lua.LValue, lua.LValue) { count++ })
+
+	if n > 0 && count == n {
+		list := make([]interface{}, 0, n)
+		for i := 1; i <= n && err == nil; i++ {
+			var item interface{}
+			item, err = toGo(t.RawGetInt(i), depth)
+			list = append(list, item)
+		}
+		return list, err
+	}
+
+	m := make(map[string]interface{}, count)
+	t.ForEach(func(k, v lua.LValue) {
+		if err != nil {
+			return
+		}
+		key, ok := k.(lua.LString)
+		if !ok {
+			err = fmt.Errorf("table key %s is not a string", k.String())
+			return
+		}
+		m[string(key)], err = toGo(v, depth)
+	})
+	return m, err
+}
+
+// FromGo converts a value produced by ToGo back into a Lua value in L.
+func FromGo(L *lua.LState, v interface{}) lua.LValue {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case int:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		t := L.CreateTable(len(v), 0)
+		for _, item := range v {
+			t.Append(FromGo(L, item))
+		}
+		return t
+	case map[string]interface{}:
+		t := L.CreateTable(0, len(v))
+		for key, item := range v {
+			t.RawSetString(key, FromGo(L, item))
+		}
+		return t
+	default:
+		return lua.LNil
+	}
+}
@@ -0,0 +1,39 @@
+package scripting
+
+import (
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestValuesRoundTripAcrossVMs(t *testing.T) {
+	src := lua.NewState()
+	defer src.Close()
+	if err := src.DoString(`v = { area = 3, tags = { "new", "hot" }, opts = { quiet = true } }`); err != nil {
+		t.Fatal(err)
+	}
+	val, err := ToGo(src.GetGlobal("v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := lua.NewState()
+	defer dst.Close()
+	dst.SetGlobal("v", FromGo(dst, val))
+	if err := dst.DoString(`assert(v.area == 3 and #v.tags == 2 and v.tags[2] == "hot" and v.opts.quiet)`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToGoRejectsUnsupportedValues(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	if err := L.DoString(`f = { cb = function() end }; c = {}; c.self = c; k = { [true] = 1 }`); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"f", "c", "k"} {
+		if _, err := ToGo(L.GetGlobal(name)); err == nil {
+			t.Errorf("ToGo(%s) succeeded", name)
+		}
+	}
+}
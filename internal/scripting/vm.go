@@ -1,21 +1,49 @@
 package scripting
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
 )
 
 // VM wraps a Lua state with BBS-specific configuration.
 type VM struct {
 	L *lua.LState
+
+	// With modules set, scripts run as modules in environments of their
+	// own over the shared globals; see UseModules.
+	modules map[string]compiled
+	menu    *lua.LTable // menu table of the module last loaded
+}
+
+// compiled is a script compiled once and run on every visit.
+type compiled struct {
+	modTime time.Time
+	proto   *lua.FunctionProto
+}
+
+// Limits caps what the scripts of one session may use, so a runaway or
+// hostile script on one node fails on its own instead of slowing down or
+// taking down the whole BBS.
+type Limits struct {
+	RegistrySize  int // Lua value stack slots, grown on demand up to this
+	CallStackSize int // nested Lua function calls
 }
 
+// DefaultLimits are used for zero fields of the limits passed to NewVM.
+var DefaultLimits = Limits{RegistrySize: 64 * 1024, CallStackSize: 200}
+
+// registryStart is the initial registry size; it grows up to
+// Limits.RegistrySize as scripts need it.
+const registryStart = 120 * 20
+
 const (
-	luaMaxMemoryBytes = 32 * 1024 * 1024
 	// luaLoadTimeout bounds script file loading/execution on startup.
 	// Menu handler calls (on_enter/on_key/etc) are allowed to block on user I/O.
 	luaLoadTimeout = 5 * time.Second
@@ -25,17 +53,45 @@ const (
 	luaHandlerTimeout = 0
 )
 
-// NewVM creates a new Lua VM with the standard libraries loaded.
-func NewVM() *VM {
+// NewVM creates a new Lua VM with the standard libraries loaded, within
+// limits. Exceeding a limit raises a Lua error in the script.
+//
+// os.exit is removed: it would end the whole process, not just the
+// script. There is no per-VM memory limit, as gopher-lua can only watch
+// the memory of the whole process.
+func NewVM(limits Limits) *VM {
+	if limits.RegistrySize <= 0 {
+		limits.RegistrySize = DefaultLimits.RegistrySize
+	}
+	if limits.CallStackSize <= 0 {
+		limits.CallStackSize = DefaultLimits.CallStackSize
+	}
 	L := lua.NewState(lua.Options{
-		CallStackSize: 120,
-		RegistrySize:  120 * 20,
+		CallStackSize:   limits.CallStackSize,
+		RegistrySize:    min(registryStart, limits.RegistrySize),
+		RegistryMaxSize: limits.RegistrySize,
 	})
-	L.SetMx(luaMaxMemoryBytes)
+	if osMod, ok := L.GetGlobal("os").(*lua.LTable); ok {
+		osMod.RawSetString("exit", L.NewFunction(func(L *lua.LState) int {
+			L.RaiseError("os.exit is not available to BBS scripts")
+			return 0
+		}))
+	}
 
 	return &VM{L: L}
 }
 
+// UseModules keeps the VM for a whole session. Each script then runs in
+// an environment of its own whose globals fall back to the VM's, so menus
+// cannot see each other's functions and variables, and each visit starts
+// from a fresh environment as with a VM per menu. Scripts are compiled
+// once and recompiled when their file changes. The global table shared
+// lasts for the session, for state that several menus build up.
+func (vm *VM) UseModules() {
+	vm.modules = make(map[string]compiled)
+	vm.L.SetGlobal("shared", vm.L.NewTable())
+}
+
 // Close shuts down the Lua VM.
 func (vm *VM) Close() {
 	vm.L.Close()
@@ -44,6 +100,11 @@ func (vm *VM) Close() {
 // LoadScript loads and executes a Lua script file.
 // The script is expected to return a table with menu handler functions.
 func (vm *VM) LoadScript(path string) error {
+	if vm.modules != nil {
+		tbl, err := vm.loadModule(path)
+		vm.menu = tbl
+		return err
+	}
 	return vm.withTimeout(luaLoadTimeout, func() error {
 		if err := vm.L.DoFile(path); err != nil {
 			return fmt.Errorf("load script %s: %w", path, err)
@@ -52,6 +113,69 @@ func (vm *VM) LoadScript(path string) error {
 	})
 }
 
+// loadModule runs the script at path in a fresh environment and returns
+// the table it returns, or its global menu table.
+func (vm *VM) loadModule(path string) (*lua.LTable, error) {
+	proto, err := vm.compile(path)
+	if err != nil {
+		return nil, err
+	}
+	L := vm.L
+	env := L.NewTable()
+	mt := L.NewTable()
+	mt.RawSetString("__index", L.Get(lua.GlobalsIndex))
+	L.SetMetatable(env, mt)
+	fn := L.NewFunctionFromProto(proto)
+	fn.Env = env
+
+	var ret lua.LValue = lua.LNil
+	err = vm.withTimeout(luaLoadTimeout, func() error {
+		if err := L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}); err != nil {
+			return fmt.Errorf("load script %s: %w", path, err)
+		}
+		ret = L.Get(-1)
+		L.Pop(1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tbl, ok := ret.(*lua.LTable); ok {
+		return tbl, nil
+	}
+	if tbl, ok := env.RawGetString("menu").(*lua.LTable); ok {
+		return tbl, nil
+	}
+	return nil, nil
+}
+
+// compile returns the compiled script at path, compiling it again when
+// the file has changed since.
+func (vm *VM) compile(path string) (*lua.FunctionProto, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("load script %s: %w", path, err)
+	}
+	if c, ok := vm.modules[path]; ok && c.modTime.Equal(info.ModTime()) {
+		return c.proto, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("load script %s: %w", path, err)
+	}
+	defer f.Close()
+	chunk, err := parse.Parse(bufio.NewReader(f), path)
+	if err != nil {
+		return nil, fmt.Errorf("load script %s: %w", path, err)
+	}
+	proto, err := lua.Compile(chunk, path)
+	if err != nil {
+		return nil, fmt.Errorf("load script %s: %w", path, err)
+	}
+	vm.modules[path] = compiled{modTime: info.ModTime(), proto: proto}
+	return proto, nil
+}
+
 // CallMenuHandler calls a function on the menu table returned by the script.
 // The menu table should be at the top of the stack after DoFile.
 func (vm *VM) CallMenuHandler(funcName string, args ...lua.LValue) error {
@@ -83,6 +207,50 @@ func (vm *VM) CallMenuHandler(funcName string, args ...lua.LValue) error {
 	})
 }
 
+// RunCommand loads the script at path, which returns a table, and calls
+// its run function with args. The menu table stays where the menu
+// handlers look for it.
+func (vm *VM) RunCommand(path string, args ...lua.LValue) error {
+	tbl, err := vm.loadCommand(path)
+	if err != nil {
+		return err
+	}
+	fn, ok := tbl.RawGetString("run").(*lua.LFunction)
+	if !ok {
+		return fmt.Errorf("command %s has no run function", path)
+	}
+	return vm.withTimeout(luaHandlerTimeout, func() error {
+		if err := vm.L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, args...); err != nil {
+			return fmt.Errorf("call %s run: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// loadCommand runs the command script at path and returns its table.
+func (vm *VM) loadCommand(path string) (*lua.LTable, error) {
+	if vm.modules != nil {
+		tbl, err := vm.loadModule(path)
+		if err != nil {
+			return nil, err
+		}
+		if tbl == nil {
+			return nil, fmt.Errorf("command %s does not return a table", path)
+		}
+		return tbl, nil
+	}
+	top := vm.L.GetTop()
+	defer vm.L.SetTop(top)
+	if err := vm.withTimeout(luaLoadTimeout, func() error { return vm.L.DoFile(path) }); err != nil {
+		return nil, fmt.Errorf("load script %s: %w", path, err)
+	}
+	tbl, ok := vm.L.Get(-1).(*lua.LTable)
+	if !ok || vm.L.GetTop() == top {
+		return nil, fmt.Errorf("command %s does not return a table", path)
+	}
+	return tbl, nil
+}
+
 // HasMenuHandler checks if the menu table has a specific handler function.
 func (vm *VM) HasMenuHandler(funcName string) bool {
 	menuTable := vm.getMenuTable()
@@ -94,6 +262,16 @@ func (vm *VM) HasMenuHandler(funcName string) bool {
 	return ok
 }
 
+// MenuField returns a field of the menu table, such as menu.meta, or
+// lua.LNil when there is no menu table.
+func (vm *VM) MenuField(name string) lua.LValue {
+	menuTable := vm.getMenuTable()
+	if menuTable == nil {
+		return lua.LNil
+	}
+	return menuTable.RawGetString(name)
+}
+
 func (vm *VM) withTimeout(timeout time.Duration, fn func() error) error {
 	prev := vm.L.Context()
 
@@ -118,6 +296,9 @@ func (vm *VM) withTimeout(timeout time.Duration, fn func() error) error {
 // getMenuTable finds the menu table - either as the return value of the
 // script or as a global named "menu".
 func (vm *VM) getMenuTable() *lua.LTable {
+	if vm.modules != nil {
+		return vm.menu
+	}
 	// Check top of stack first (return value)
 	top := vm.L.Get(-1)
 	if tbl, ok := top.(*lua.LTable); ok {
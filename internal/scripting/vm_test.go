@@ -0,0 +1,128 @@
+package scripting
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestVMRegistryLimit(t *testing.T) {
+	vm := NewVM(Limits{RegistrySize: 4096})
+	defer vm.Close()
+
+	// Fine within the limit, and the registry grows past its initial size.
+	if err := vm.L.DoString(`local t = {} for i = 1, 3000 do t[i] = i end local _ = {unpack(t)}`); err != nil {
+		t.Fatalf("unpack 3000: %v", err)
+	}
+	if err := vm.L.DoString(`local t = {} for i = 1, 10000 do t[i] = i end local _ = {unpack(t)}`); err == nil {
+		t.Fatal("unpack 10000 within a 4096 registry succeeded")
+	}
+}
+
+func TestVMCallStackLimit(t *testing.T) {
+	vm := NewVM(Limits{CallStackSize: 50})
+	defer vm.Close()
+
+	if err := vm.L.DoString(`local function f(n) if n > 0 then return 1 + f(n - 1) end return 0 end f(1000)`); err == nil {
+		t.Fatal("deep recursion succeeded")
+	}
+}
+
+func TestVMOSExitDisabled(t *testing.T) {
+	vm := NewVM(Limits{})
+	defer vm.Close()
+
+	err := vm.L.DoString(`os.exit(1)`)
+	if err == nil || !strings.Contains(err.Error(), "os.exit is not available") {
+		t.Fatalf("os.exit err = %v", err)
+	}
+}
+
+func TestVMRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	menuPath := filepath.Join(dir, "menu.lua")
+	cmdPath := filepath.Join(dir, "cmd.lua")
+	os.WriteFile(menuPath, []byte(`local menu = {} function menu.on_key(node, key) seen = key end return menu`), 0644)
+	os.WriteFile(cmdPath, []byte(`return { run = function(node, args) ran = args end }`), 0644)
+
+	vm := NewVM(Limits{})
+	defer vm.Close()
+	if err := vm.LoadScript(menuPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.RunCommand(cmdPath, lua.LNil, lua.LString("now")); err != nil {
+		t.Fatal(err)
+	}
+	if got := vm.L.GetGlobal("ran").String(); got != "now" {
+		t.Errorf("command got args %q", got)
+	}
+	// The menu's handlers are still found after the command.
+	if err := vm.CallMenuHandler("on_key", lua.LNil, lua.LString("x")); err != nil || vm.L.GetGlobal("seen").String() != "x" {
+		t.Errorf("on_key after command: %v", err)
+	}
+
+	os.WriteFile(cmdPath, []byte(`ran = "loaded"`), 0644)
+	if err := vm.RunCommand(cmdPath); err == nil {
+		t.Error("command without a table succeeded")
+	}
+}
+
+func TestVMModules(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.lua")
+	second := filepath.Join(dir, "second.lua")
+	cmdPath := filepath.Join(dir, "cmd.lua")
+	os.WriteFile(first, []byte(`
+count = (count or 0) + 1
+function helper() end
+local menu = {}
+function menu.on_key(node, key) shared.name = key; shared.visits = count end
+return menu`), 0644)
+	os.WriteFile(second, []byte(`
+menu = {}
+function menu.on_key(node, key) shared.saw = shared.name; shared.helper = helper ~= nil end`), 0644)
+	os.WriteFile(cmdPath, []byte(`return { run = function() shared.ran = true end }`), 0644)
+
+	vm := NewVM(Limits{})
+	defer vm.Close()
+	vm.UseModules()
+	shared := vm.L.GetGlobal("shared").(*lua.LTable)
+
+	for range 2 {
+		if err := vm.LoadScript(first); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := vm.CallMenuHandler("on_key", lua.LNil, lua.LString("alice")); err != nil {
+		t.Fatal(err)
+	}
+	// Each visit starts from a fresh environment.
+	if got := shared.RawGetString("visits").String(); got != "1" {
+		t.Errorf("visits = %s, want 1", got)
+	}
+
+	if err := vm.LoadScript(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.RunCommand(cmdPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.CallMenuHandler("on_key", lua.LNil, lua.LString("x")); err != nil {
+		t.Fatal(err)
+	}
+	if got := shared.RawGetString("saw").String(); got != "alice" {
+		t.Errorf("second menu saw %q in shared, want alice", got)
+	}
+	if shared.RawGetString("helper") != lua.LFalse {
+		t.Error("second menu sees the first menu's globals")
+	}
+	if shared.RawGetString("ran") != lua.LTrue {
+		t.Error("command did not run")
+	}
+	if vm.L.GetGlobal("helper") != lua.LNil || vm.L.GetGlobal("menu") != lua.LNil {
+		t.Error("module globals leaked into the VM's globals")
+	}
+}
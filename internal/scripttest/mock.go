@@ -0,0 +1,336 @@
+package scripttest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// nodeProperties are the node fields scripts read rather than call, with
+// the values a mock node has unless the case sets its own.
+var nodeProperties = map[string]lua.LValue{
+	"width":          lua.LNumber(80),
+	"height":         lua.LNumber(24),
+	"ansi":           lua.LTrue,
+	"baud":           lua.LNumber(0),
+	"charset":        lua.LString("cp437"),
+	"palette":        lua.LString(""),
+	"colors":         lua.LNumber(16),
+	"background":     lua.LString(""),
+	"bytes_sent":     lua.LNumber(0),
+	"bytes_received": lua.LNumber(0),
+	"compressed":     lua.LFalse,
+	"ssh_username":   lua.LString(""),
+}
+
+// errNoInput ends a case whose script waits for more input than the case
+// gave, as a hang-up would.
+var errNoInput = errors.New("script wants more input than the test gives")
+
+// mock is the pretend BBS a case runs in.
+type mock struct {
+	L       *lua.LState
+	returns map[string]lua.LValue // canned return values by call name
+	input   []lua.LValue          // keys and lines, in the order asked for
+	props   map[string]lua.LValue // node properties
+	state   map[string]lua.LValue // set_state and set_var values
+	mocked  map[string]bool       // modules replaced by mocks
+	run     *Run
+}
+
+func newMock(L *lua.LState, c, spec *lua.LTable) *mock {
+	m := &mock{
+		L:       L,
+		returns: make(map[string]lua.LValue),
+		props:   make(map[string]lua.LValue),
+		state:   make(map[string]lua.LValue),
+		mocked:  make(map[string]bool),
+		run:     &Run{},
+	}
+	for k, v := range nodeProperties {
+		m.props[k] = v
+	}
+	for _, name := range DefaultModules {
+		m.mocked[name] = true
+	}
+
+	// The file's set-up first, then the case's on top.
+	for _, t := range []*lua.LTable{spec, c} {
+		if u, ok := t.RawGetString("user").(*lua.LTable); ok {
+			m.returns["users.get_current"] = u
+		}
+		if r, ok := t.RawGetString("returns").(*lua.LTable); ok {
+			r.ForEach(func(k, v lua.LValue) {
+				name := lua.LVAsString(k)
+				m.returns[name] = v
+				if mod, _, ok := strings.Cut(name, "."); ok && !strings.Contains(mod, ":") {
+					m.mocked[mod] = true
+				}
+			})
+		}
+		if mods, ok := t.RawGetString("mocks").(*lua.LTable); ok {
+			mods.ForEach(func(_, v lua.LValue) { m.mocked[lua.LVAsString(v)] = true })
+		}
+		if n, ok := t.RawGetString("node").(*lua.LTable); ok {
+			n.ForEach(func(k, v lua.LValue) { m.props[lua.LVAsString(k)] = v })
+		}
+	}
+	if in, ok := c.RawGetString("input").(*lua.LTable); ok {
+		for i := 1; i <= in.Len(); i++ {
+			m.input = append(m.input, in.RawGetInt(i))
+		}
+	}
+	return m
+}
+
+// install sets the mock node and modules as globals and returns the node.
+func (m *mock) install() lua.LValue {
+	L := m.L
+	for name := range m.mocked {
+		L.SetGlobal(name, m.module(name, "."))
+	}
+	node := m.module("node", ":")
+	L.SetGlobal("node", node)
+	return node
+}
+
+// module returns a table whose every field is a function recording its
+// calls as prefix+sep+field.
+func (m *mock) module(prefix, sep string) *lua.LTable {
+	L := m.L
+	tbl := L.NewTable()
+	fns := make(map[string]*lua.LFunction)
+	mt := L.NewTable()
+	mt.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		key := L.CheckString(2)
+		if prefix == "node" {
+			if v, ok := m.props[key]; ok {
+				L.Push(v)
+				return 1
+			}
+		}
+		fn, ok := fns[key]
+		if !ok {
+			fn = L.NewFunction(m.recorder(prefix+sep+key, sep == ":"))
+			fns[key] = fn
+		}
+		L.Push(fn)
+		return 1
+	}))
+	L.SetMetatable(tbl, mt)
+	return tbl
+}
+
+// recorder returns the function standing in for the named call. Methods
+// are called with the node first, which is not recorded.
+func (m *mock) recorder(name string, method bool) lua.LGFunction {
+	return func(L *lua.LState) int {
+		first := 1
+		if method {
+			first = 2
+		}
+		var args []lua.LValue
+		for i := first; i <= L.GetTop(); i++ {
+			args = append(args, L.Get(i))
+		}
+		m.run.Calls = append(m.run.Calls, Call{Name: name, Args: args})
+
+		if v, ok := m.returns[name]; ok {
+			fn, ok := v.(*lua.LFunction)
+			if !ok {
+				L.Push(v)
+				return 1
+			}
+			top := L.GetTop()
+			L.Push(fn)
+			for _, a := range args {
+				L.Push(a)
+			}
+			L.Call(len(args), lua.MultRet)
+			return L.GetTop() - top
+		}
+		if method {
+			return m.node(L, strings.TrimPrefix(name, "node:"), args)
+		}
+		return 0
+	}
+}
+
+// node does what the node methods that matter to a script's flow do:
+// output is collected, input is taken from the case and state is kept.
+// Other methods return nothing.
+func (m *mock) node(L *lua.LState, method string, args []lua.LValue) int {
+	arg := func(i int) lua.LValue {
+		if i < len(args) {
+			return args[i]
+		}
+		return lua.LNil
+	}
+	switch method {
+	case "send":
+		m.run.Output += lua.LVAsString(arg(0))
+	case "sendln":
+		m.run.Output += lua.LVAsString(arg(0)) + "\n"
+	case "getkey", "getkey_ex", "hotkey", "getline", "ask", "password":
+		v, ok := m.next()
+		if !ok {
+			L.RaiseError("%v", errNoInput)
+		}
+		L.Push(v)
+		return 1
+	case "poll_key", "getkey_timeout", "getline_timeout":
+		// Running out of input here is a timeout, not a hang-up.
+		v, _ := m.next()
+		L.Push(v)
+		return 1
+	case "yesno":
+		v, ok := m.next()
+		if !ok {
+			L.RaiseError("%v", errNoInput)
+		}
+		if s, isStr := v.(lua.LString); isStr {
+			v = lua.LBool(strings.HasPrefix(strings.ToUpper(string(s)), "Y"))
+		}
+		L.Push(lua.LBool(lua.LVAsBool(v)))
+		return 1
+	case "set_state", "set_var", "set_session":
+		m.state[stateKey(method, arg(0))] = arg(1)
+	case "get_state", "get_var", "get_session":
+		v, ok := m.state[stateKey(method, arg(0))]
+		if !ok {
+			v = lua.LNil
+		}
+		L.Push(v)
+		return 1
+	}
+	return 0
+}
+
+// stateKey keeps menu state apart from session variables, which
+// set_var and set_session both name.
+func stateKey(method string, key lua.LValue) string {
+	if strings.HasSuffix(method, "_state") {
+		return "state." + lua.LVAsString(key)
+	}
+	return "var." + lua.LVAsString(key)
+}
+
+// next takes the next input.
+func (m *mock) next() (lua.LValue, bool) {
+	if len(m.input) == 0 {
+		return lua.LNil, false
+	}
+	v := m.input[0]
+	m.input = m.input[1:]
+	return v, true
+}
+
+// check compares what the script did with the case's expectations:
+//
+//	calls      calls that must have been made, in this order, each given
+//	           as the name and its leading arguments, compared as text
+//	not_called names of calls that must not have been made
+//	output     text that must have been sent
+//	check      a function given the run, which fails the case by raising
+//	           an error (as assert does)
+func (m *mock) check(c *lua.LTable) error {
+	if calls, ok := c.RawGetString("calls").(*lua.LTable); ok {
+		at := 0
+		for i := 1; i <= calls.Len(); i++ {
+			want, ok := calls.RawGetInt(i).(*lua.LTable)
+			if !ok {
+				return fmt.Errorf("calls[%d] is not a table", i)
+			}
+			found := false
+			for ; at < len(m.run.Calls); at++ {
+				if matches(m.run.Calls[at], want) {
+					found, at = true, at+1
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("no call %s%s", expected(want), m.made())
+			}
+		}
+	}
+	if names, ok := c.RawGetString("not_called").(*lua.LTable); ok {
+		for i := 1; i <= names.Len(); i++ {
+			name := lua.LVAsString(names.RawGetInt(i))
+			for _, call := range m.run.Calls {
+				if call.Name == name {
+					return fmt.Errorf("unexpected call %s", call)
+				}
+			}
+		}
+	}
+	if out, ok := c.RawGetString("output").(*lua.LTable); ok {
+		for i := 1; i <= out.Len(); i++ {
+			if want := lua.LVAsString(out.RawGetInt(i)); !strings.Contains(m.run.Output, want) {
+				return fmt.Errorf("output does not contain %q; it was:\n%s", want, m.run.Output)
+			}
+		}
+	}
+	if fn, ok := c.RawGetString("check").(*lua.LFunction); ok {
+		if err := m.L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, m.runTable()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches reports whether call is the one want describes.
+func matches(call Call, want *lua.LTable) bool {
+	if call.Name != lua.LVAsString(want.RawGetInt(1)) {
+		return false
+	}
+	for i := 2; i <= want.Len(); i++ {
+		if i-2 >= len(call.Args) || call.Args[i-2].String() != want.RawGetInt(i).String() {
+			return false
+		}
+	}
+	return true
+}
+
+func expected(want *lua.LTable) string {
+	c := Call{Name: lua.LVAsString(want.RawGetInt(1))}
+	for i := 2; i <= want.Len(); i++ {
+		c.Args = append(c.Args, want.RawGetInt(i))
+	}
+	return c.String()
+}
+
+// made lists the calls the script made, for a failure message.
+func (m *mock) made() string {
+	if len(m.run.Calls) == 0 {
+		return "; the script made no calls"
+	}
+	var b strings.Builder
+	b.WriteString("; the script called:")
+	for _, c := range m.run.Calls {
+		b.WriteString("\n  " + c.String())
+	}
+	return b.String()
+}
+
+// runTable gives the run to a check function as {calls = {{name = ...,
+// args = {...}}, ...}, output = "..."}.
+func (m *mock) runTable() *lua.LTable {
+	L := m.L
+	calls := L.NewTable()
+	for _, c := range m.run.Calls {
+		args := L.NewTable()
+		for _, a := range c.Args {
+			args.Append(a)
+		}
+		t := L.NewTable()
+		t.RawSetString("name", lua.LString(c.Name))
+		t.RawSetString("args", args)
+		calls.Append(t)
+	}
+	run := L.NewTable()
+	run.RawSetString("calls", calls)
+	run.RawSetString("output", lua.LString(m.run.Output))
+	return run
+}
@@ -0,0 +1,222 @@
+// Package scripttest runs unit tests for menu and command scripts without
+// a terminal or a database. A test file is a Lua script returning the
+// script under test and a list of cases. Each case runs in a fresh VM in
+// which node, users, msg and files are mocks: every call is recorded,
+// node input comes from the case's input list, and any call can be given
+// a canned return value. The case then checks the calls made and the text
+// sent.
+//
+//	return {
+//	    script = "../menus/main_menu.lua",
+//	    tests = {
+//	        {
+//	            name = "G logs off",
+//	            call = "on_key", args = { "G" },
+//	            calls = { { "node:goto_menu", "goodbye" } },
+//	        },
+//	    },
+//	}
+package scripttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/scripting"
+	"github.com/notepid/twilight_bbs/internal/session"
+	"github.com/notepid/twilight_bbs/internal/user"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// caseTimeout stops a case whose script loops without asking for input.
+const caseTimeout = 5 * time.Second
+
+// DefaultModules are mocked in every case; others are mocked when a case
+// lists them in mocks or gives one of their functions a return value.
+var DefaultModules = []string{"users", "msg", "files"}
+
+// Result is the outcome of one case.
+type Result struct {
+	Name string
+	Err  error // nil when the case passed
+	Run  *Run  // what the script did, nil if it could not be started
+}
+
+// Run records what a script did during a case.
+type Run struct {
+	Calls  []Call
+	Output string // text sent with node:send and node:sendln
+}
+
+// Call is a recorded call to a mock, such as node:goto_menu("goodbye").
+type Call struct {
+	Name string // "node:goto_menu", "files.areas"
+	Args []lua.LValue
+}
+
+func (c Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = format(a)
+	}
+	return c.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func format(v lua.LValue) string {
+	switch v := v.(type) {
+	case lua.LString:
+		return fmt.Sprintf("%q", string(v))
+	case *lua.LTable:
+		return "{...}"
+	}
+	return v.String()
+}
+
+// RunFile runs every case in the test file at path.
+func RunFile(path string) ([]Result, error) {
+	// A first load finds the cases; each then runs in a VM of its own,
+	// since the functions in a case belong to the state that loaded it.
+	vm, spec, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	tests, ok := spec.RawGetString("tests").(*lua.LTable)
+	n := 0
+	if ok {
+		n = tests.Len()
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = caseName(tests.RawGetInt(i+1), i+1)
+	}
+	vm.Close()
+	if n == 0 {
+		return nil, fmt.Errorf("%s: no tests", path)
+	}
+
+	results := make([]Result, n)
+	for i, name := range names {
+		run, err := runCase(path, i+1)
+		results[i] = Result{Name: name, Err: err, Run: run}
+	}
+	return results, nil
+}
+
+// load runs the test file in a fresh VM and returns the table it returns.
+func load(path string) (*scripting.VM, *lua.LTable, error) {
+	vm := scripting.NewVM(scripting.Limits{})
+	ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+	defer cancel()
+	vm.L.SetContext(ctx)
+	defer vm.L.RemoveContext()
+	if err := vm.L.DoFile(path); err != nil {
+		vm.Close()
+		return nil, nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	spec, ok := vm.L.Get(-1).(*lua.LTable)
+	vm.L.Pop(1)
+	if !ok {
+		vm.Close()
+		return nil, nil, fmt.Errorf("%s does not return a table", path)
+	}
+	return vm, spec, nil
+}
+
+func caseName(v lua.LValue, i int) string {
+	if t, ok := v.(*lua.LTable); ok {
+		if name := lua.LVAsString(t.RawGetString("name")); name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("test %d", i)
+}
+
+// runCase runs case i of the test file at path.
+func runCase(path string, i int) (*Run, error) {
+	vm, spec, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	defer vm.Close()
+	L := vm.L
+	c, ok := spec.RawGetString("tests").(*lua.LTable).RawGetInt(i).(*lua.LTable)
+	if !ok {
+		return nil, errors.New("test is not a table")
+	}
+	// A case's fields override the file's, so shared set-up can be given
+	// once at the top.
+	field := func(name string) lua.LValue {
+		if v := c.RawGetString(name); v != lua.LNil {
+			return v
+		}
+		return spec.RawGetString(name)
+	}
+
+	script := lua.LVAsString(field("script"))
+	if script == "" {
+		return nil, errors.New("no script to test")
+	}
+	if !filepath.IsAbs(script) {
+		script = filepath.Join(filepath.Dir(path), script)
+	}
+
+	m := newMock(L, c, spec)
+	vm.UseModules()
+	node := m.install()
+	userTbl, _ := field("user").(*lua.LTable)
+	sess := session.New()
+	if userTbl != nil {
+		sess.SetUser(&user.User{
+			ID:            int(lua.LVAsNumber(userTbl.RawGetString("id"))),
+			Username:      lua.LVAsString(userTbl.RawGetString("name")),
+			SecurityLevel: int(lua.LVAsNumber(userTbl.RawGetString("level"))),
+		})
+	}
+	// The slash-command parser has no side effects, so the real one is used.
+	if !m.mocked["slash"] {
+		scripting.NewSlashAPI(sess, 1).Register(L)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+	err = vm.LoadScript(script)
+	if err == nil {
+		err = call(vm, lua.LVAsString(field("call")), node, c.RawGetString("args"))
+	}
+	L.RemoveContext()
+
+	if want := lua.LVAsString(c.RawGetString("error")); want != "" {
+		if err == nil {
+			return m.run, fmt.Errorf("script did not fail with %q", want)
+		}
+		if !strings.Contains(err.Error(), want) {
+			return m.run, fmt.Errorf("script failed with %v, want %q", err, want)
+		}
+	} else if err != nil {
+		return m.run, err
+	}
+	return m.run, m.check(c)
+}
+
+// call calls the named handler, on_enter by default, with the node and
+// the case's args.
+func call(vm *scripting.VM, name string, node lua.LValue, args lua.LValue) error {
+	if name == "" {
+		name = "on_enter"
+	}
+	if !vm.HasMenuHandler(name) {
+		return fmt.Errorf("script has no %s function", name)
+	}
+	params := []lua.LValue{node}
+	if t, ok := args.(*lua.LTable); ok {
+		for i := 1; i <= t.Len(); i++ {
+			params = append(params, t.RawGetInt(i))
+		}
+	}
+	return vm.CallMenuHandler(name, params...)
+}
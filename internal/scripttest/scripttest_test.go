@@ -0,0 +1,110 @@
+package scripttest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const menuScript = `
+local menu = {}
+
+function menu.on_enter(node)
+    node:sendln("Files in " .. files.get_area(1).name)
+    while true do
+        local key = node:getkey()
+        if key == "Q" then
+            node:goto_menu("main_menu")
+            return
+        elseif key == "D" and node:yesno("Download? ") then
+            local ok, err = files.download(7)
+            node:sendln(ok and "Sent" or err)
+        end
+    end
+end
+
+function menu.on_key(node, key)
+    node:set_state("last", key)
+    error("boom " .. node:get_state("last"))
+end
+
+return menu
+`
+
+const testFile = `
+return {
+    script = "menu.lua",
+    returns = { ["files.get_area"] = { name = "Uploads" } },
+    tests = {
+        {
+            name = "download",
+            input = { "D", "y", "Q" },
+            returns = { ["files.download"] = function(id) return nil, "no ratio for " .. id end },
+            calls = { { "files.download", 7 }, { "node:goto_menu", "main_menu" } },
+            output = { "Files in Uploads", "no ratio for 7" },
+        },
+        {
+            name = "wrong menu",
+            input = { "Q" },
+            calls = { { "node:goto_menu", "file_menu" } },
+        },
+        {
+            name = "hang-up",
+            input = { "D", "n" },
+        },
+        {
+            name = "expected error",
+            call = "on_key", args = { "X" },
+            error = "boom X",
+        },
+        {
+            name = "check",
+            input = { "Q" },
+            check = function(run)
+                assert(#run.calls == 4, "calls: " .. #run.calls)
+                assert(run.calls[1].name == "files.get_area")
+                assert(run.output == "Files in Uploads\n", "output was " .. run.output)
+            end,
+        },
+    },
+}
+`
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "menu.lua"), []byte(menuScript), 0644)
+	path := filepath.Join(dir, "menu_test.lua")
+	os.WriteFile(path, []byte(testFile), 0644)
+
+	results, err := RunFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"download":       "",
+		"wrong menu":     `no call node:goto_menu("file_menu"); the script called:`,
+		"hang-up":        "more input than the test gives",
+		"expected error": "",
+		"check":          "",
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results", len(results))
+	}
+	for _, r := range results {
+		switch msg := want[r.Name]; {
+		case msg == "" && r.Err != nil:
+			t.Errorf("%s: %v", r.Name, r.Err)
+		case msg != "" && (r.Err == nil || !strings.Contains(r.Err.Error(), msg)):
+			t.Errorf("%s: err = %v, want %q", r.Name, r.Err, msg)
+		}
+	}
+	if calls := results[0].Run.Calls; len(calls) == 0 || calls[0].String() != `files.get_area(1)` {
+		t.Errorf("calls = %v", calls)
+	}
+
+	os.WriteFile(path, []byte(`return { script = "menu.lua" }`), 0644)
+	if _, err := RunFile(path); err == nil {
+		t.Error("file without tests ran")
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// timeoutError is returned when a read deadline expires. Like net.Conn
+// timeouts it reports Timeout() so callers can tell it from a closed stream.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// deadlineReader adds SetReadDeadline to a stream without deadlines (an SSH
+// channel). A goroutine started on the first Read pumps data from the
+// stream; reads wait for that data, the deadline, or a deadline change.
+type deadlineReader struct {
+	r    io.Reader
+	once sync.Once
+	data chan []byte
+	done chan struct{}
+	err  error // set before data is closed
+	rest []byte
+
+	mu       sync.Mutex
+	deadline time.Time
+	changed  chan struct{} // closed and replaced when the deadline changes
+	closed   bool
+}
+
+func newDeadlineReader(r io.Reader) *deadlineReader {
+	return &deadlineReader{
+		r:       r,
+		data:    make(chan []byte),
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+}
+
+func (d *deadlineReader) pump() {
+	defer close(d.data)
+	for {
+		buf := make([]byte, 1024)
+		n, err := d.r.Read(buf)
+		if n > 0 {
+			select {
+			case d.data <- buf[:n]:
+			case <-d.done:
+				return
+			}
+		}
+		if err != nil {
+			d.err = err
+			return
+		}
+	}
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	if len(d.rest) > 0 {
+		n := copy(p, d.rest)
+		d.rest = d.rest[n:]
+		return n, nil
+	}
+	d.once.Do(func() { go d.pump() })
+
+	for {
+		d.mu.Lock()
+		deadline, changed := d.deadline, d.changed
+		d.mu.Unlock()
+
+		var expired <-chan time.Time
+		var timer *time.Timer
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, timeoutError{}
+			}
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+
+		select {
+		case buf, ok := <-d.data:
+			if timer != nil {
+				timer.Stop()
+			}
+			if !ok {
+				return 0, d.err
+			}
+			n := copy(p, buf)
+			d.rest = buf[n:]
+			return n, nil
+		case <-expired:
+			return 0, timeoutError{}
+		case <-d.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return 0, io.EOF
+		case <-changed:
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}
+}
+
+// SetReadDeadline sets the deadline for current and future reads. A zero
+// time means no deadline.
+func (d *deadlineReader) SetReadDeadline(t time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadline = t
+	close(d.changed)
+	d.changed = make(chan struct{})
+	return nil
+}
+
+// Close stops the pump goroutine once the underlying stream is closed,
+// and ends pending and future reads with io.EOF.
+func (d *deadlineReader) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.closed {
+		d.closed = true
+		close(d.done)
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDeadlineReaderTimesOutAndResumes(t *testing.T) {
+	pr, pw := io.Pipe()
+	d := newDeadlineReader(pr)
+	defer d.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.Read(make([]byte, 8))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	d.SetReadDeadline(time.Now())
+
+	select {
+	case err := <-done:
+		var te interface{ Timeout() bool }
+		if !errors.As(err, &te) || !te.Timeout() {
+			t.Fatalf("got %v, want timeout", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("deadline did not interrupt the read")
+	}
+
+	d.SetReadDeadline(time.Time{})
+	go pw.Write([]byte("hi"))
+	buf := make([]byte, 8)
+	n, err := d.Read(buf)
+	if err != nil || string(buf[:n]) != "hi" {
+		t.Fatalf("Read = %q, %v", buf[:n], err)
+	}
+
+	pw.Close()
+	if _, err := d.Read(buf); err != io.EOF {
+		t.Fatalf("Read after close = %v, want EOF", err)
+	}
+}
+
+func TestDeadlineReaderCloseEndsRead(t *testing.T) {
+	pr, _ := io.Pipe()
+	d := newDeadlineReader(pr)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.Read(make([]byte, 8))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	d.Close()
+
+	select {
+	case err := <-done:
+		if err != io.EOF {
+			t.Fatalf("got %v, want EOF", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not end the read")
+	}
+}
@@ -1,11 +1,18 @@
 package server
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
+	"sync/atomic"
+	"time"
 )
 
+// rejectTimeout bounds telling a refused caller to try later, TLS
+// handshake included.
+const rejectTimeout = 5 * time.Second
+
 // ConnectionHandler is called for each new telnet connection.
 // The handler is responsible for running the connection and closing it.
 type ConnectionHandler func(tc *TelnetConn)
@@ -13,17 +20,34 @@ type ConnectionHandler func(tc *TelnetConn)
 // Listener accepts incoming telnet connections.
 type Listener struct {
 	addr    string
+	opts    Options
+	limiter *RateLimiter
 	handler ConnectionHandler
+
+	listening atomic.Bool
 }
 
-// NewListener creates a new TCP listener for telnet connections.
-func NewListener(port int, handler ConnectionHandler) *Listener {
+// NewListener creates a new TCP listener for telnet connections on addr
+// (host:port, host may be empty for all interfaces).
+func NewListener(addr string, opts Options, handler ConnectionHandler) *Listener {
 	return &Listener{
-		addr:    fmt.Sprintf(":%d", port),
+		addr:    addr,
+		opts:    opts,
+		limiter: NewRateLimiter("telnet", addr, opts),
 		handler: handler,
 	}
 }
 
+// Limiter returns the listener's rate limiter, for metrics.
+func (l *Listener) Limiter() *RateLimiter {
+	return l.limiter
+}
+
+// Listening reports whether the listener is accepting connections.
+func (l *Listener) Listening() bool {
+	return l.listening.Load()
+}
+
 // ListenAndServe starts accepting connections. Blocks until the listener
 // is closed or a fatal error occurs.
 func (l *Listener) ListenAndServe() error {
@@ -32,8 +56,15 @@ func (l *Listener) ListenAndServe() error {
 		return fmt.Errorf("listen %s: %w", l.addr, err)
 	}
 	defer ln.Close()
+	l.listening.Store(true)
+	defer l.listening.Store(false)
 
-	log.Printf("Telnet server listening on %s", l.addr)
+	if l.opts.TLSConfig != nil {
+		ln = tls.NewListener(ln, l.opts.TLSConfig)
+		log.Printf("Telnet server listening on %s (TLS)", l.addr)
+	} else {
+		log.Printf("Telnet server listening on %s", l.addr)
+	}
 
 	for {
 		conn, err := ln.Accept()
@@ -42,7 +73,33 @@ func (l *Listener) ListenAndServe() error {
 			continue
 		}
 
-		tc := NewTelnetConn(conn)
-		go l.handler(tc)
+		host := remoteHost(conn)
+		delay, reason, ok := l.limiter.Admit(host)
+		if !ok {
+			if reason != RejectRate {
+				log.Printf("Telnet: rejecting %s on %s (%s)", host, l.addr, rejectMessage[reason])
+			}
+			go reject(conn)
+			continue
+		}
+
+		go func() {
+			defer l.limiter.Release(host)
+			time.Sleep(delay)
+			tc := NewTelnetConn(watchIdle(conn, l.opts.IdleTimeout))
+			if l.opts.Compress {
+				tc.OfferCompression()
+			}
+			l.handler(tc)
+		}()
 	}
 }
+
+// reject tells a refused caller to try again later and hangs up. It runs
+// apart from the accept loop with a deadline: on a TLS listener the write
+// starts the handshake, which a client may never finish.
+func reject(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(rejectTimeout))
+	conn.Write([]byte("Too many connections, try again later.\r\n"))
+	conn.Close()
+}
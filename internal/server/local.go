@@ -0,0 +1,113 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// ErrNotTerminal is returned by NewLocalConn when standard input or output
+// is not a terminal.
+var ErrNotTerminal = errors.New("not a terminal")
+
+// LocalConn is the sysop's local console: the daemon's own terminal,
+// switched to raw mode, used as a connection without any listener.
+type LocalConn struct {
+	in, out *os.File
+	reader  *deadlineReader
+	state   *term.State
+	once    sync.Once
+	mu      sync.Mutex
+
+	resizeMu sync.Mutex
+	onResize func(width, height int)
+	stop     func() // stops watching for window size changes
+
+	Width     int
+	Height    int
+	TermType  string // $TERM
+	ColorTerm string // $COLORTERM
+}
+
+// NewLocalConn switches the terminal on in and out to raw mode and wraps
+// it. Close puts the terminal back.
+func NewLocalConn(in, out *os.File) (*LocalConn, error) {
+	if !term.IsTerminal(in.Fd()) || !term.IsTerminal(out.Fd()) {
+		return nil, ErrNotTerminal
+	}
+	state, err := term.MakeRaw(in.Fd())
+	if err != nil {
+		return nil, err
+	}
+	width, height, err := term.GetSize(out.Fd())
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	lc := &LocalConn{
+		in:        in,
+		out:       out,
+		reader:    newDeadlineReader(in),
+		state:     state,
+		Width:     width,
+		Height:    height,
+		TermType:  os.Getenv("TERM"),
+		ColorTerm: os.Getenv("COLORTERM"),
+	}
+	lc.stop = watchSize(lc.sizeChanged)
+	return lc, nil
+}
+
+// Read implements io.Reader.
+func (lc *LocalConn) Read(p []byte) (int, error) {
+	return lc.reader.Read(p)
+}
+
+// SetReadDeadline sets the read deadline; terminal reads have none of
+// their own, so they go through a deadlineReader.
+func (lc *LocalConn) SetReadDeadline(t time.Time) error {
+	return lc.reader.SetReadDeadline(t)
+}
+
+// Write implements io.Writer.
+func (lc *LocalConn) Write(p []byte) (int, error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.out.Write(p)
+}
+
+// SetResizeHandler registers fn to be called when the terminal window is
+// resized.
+func (lc *LocalConn) SetResizeHandler(fn func(width, height int)) {
+	lc.resizeMu.Lock()
+	defer lc.resizeMu.Unlock()
+	lc.onResize = fn
+}
+
+// sizeChanged reads the new window size and passes it on.
+func (lc *LocalConn) sizeChanged() {
+	width, height, err := term.GetSize(lc.out.Fd())
+	if err != nil || width <= 0 || height <= 0 {
+		return
+	}
+	lc.resizeMu.Lock()
+	fn := lc.onResize
+	lc.resizeMu.Unlock()
+	if fn != nil {
+		fn(width, height)
+	}
+}
+
+// Close restores the terminal and ends pending reads. Standard input
+// itself stays open.
+func (lc *LocalConn) Close() error {
+	var err error
+	lc.once.Do(func() {
+		lc.stop()
+		lc.reader.Close()
+		err = term.Restore(lc.in.Fd(), lc.state)
+	})
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package server
+
+// watchSize does nothing where terminals send no resize signal; the
+// console keeps the size it started with.
+func watchSize(fn func()) func() {
+	return func() {}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options holds per-listener settings shared by the telnet and SSH servers.
+type Options struct {
+	TLSConfig   *tls.Config   // wrap accepted connections in TLS (telnet only)
+	Compress    bool          // offer MCCP2 output compression (telnet only)
+	MaxPerIP    int           // concurrent sessions per remote IP, 0 = unlimited
+	IdleTimeout time.Duration // disconnect after this long without input, 0 = never
+	Rate        RateLimits    // connection rate thresholds
+	SSH         SSHAlgorithms // algorithms offered (ssh only)
+}
+
+// ipLimiter counts concurrent sessions per remote host.
+type ipLimiter struct {
+	max    int
+	mu     sync.Mutex
+	active map[string]int
+}
+
+func newIPLimiter(max int) *ipLimiter {
+	return &ipLimiter{max: max, active: make(map[string]int)}
+}
+
+// acquire reserves a session slot for host. It returns false when the host
+// is at its limit.
+func (l *ipLimiter) acquire(host string) bool {
+	if l.max <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[host] >= l.max {
+		return false
+	}
+	l.active[host]++
+	return true
+}
+
+func (l *ipLimiter) release(host string) {
+	if l.max <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[host] <= 1 {
+		delete(l.active, host)
+	} else {
+		l.active[host]--
+	}
+}
+
+// remoteHost returns the host part of a connection's remote address.
+func remoteHost(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+// idleConn closes the wrapped connection when nothing has been read from it
+// for the timeout. It uses a watchdog instead of read deadlines so terminal
+// code remains free to set its own deadlines.
+type idleConn struct {
+	net.Conn
+	last atomic.Int64 // unix nanos of the last read with data
+	done chan struct{}
+	once sync.Once
+}
+
+// watchIdle wraps conn with an idle watchdog, or returns it unchanged when
+// timeout is zero.
+func watchIdle(conn net.Conn, timeout time.Duration) net.Conn {
+	if timeout <= 0 {
+		return conn
+	}
+	ic := &idleConn{Conn: conn, done: make(chan struct{})}
+	ic.last.Store(time.Now().UnixNano())
+
+	interval := timeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ic.done:
+				return
+			case now := <-ticker.C:
+				if now.Sub(time.Unix(0, ic.last.Load())) >= timeout {
+					ic.Close()
+					return
+				}
+			}
+		}
+	}()
+	return ic
+}
+
+func (ic *idleConn) Read(p []byte) (int, error) {
+	n, err := ic.Conn.Read(p)
+	if n > 0 {
+		ic.last.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (ic *idleConn) Close() error {
+	ic.once.Do(func() { close(ic.done) })
+	return ic.Conn.Close()
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIPLimiter(t *testing.T) {
+	l := newIPLimiter(2)
+	if !l.acquire("1.2.3.4") || !l.acquire("1.2.3.4") {
+		t.Fatal("first two sessions should be allowed")
+	}
+	if l.acquire("1.2.3.4") {
+		t.Fatal("third session should be refused")
+	}
+	if !l.acquire("5.6.7.8") {
+		t.Fatal("other hosts are counted separately")
+	}
+	l.release("1.2.3.4")
+	if !l.acquire("1.2.3.4") {
+		t.Fatal("released slot should be reusable")
+	}
+
+	if unlimited := newIPLimiter(0); !unlimited.acquire("x") || !unlimited.acquire("x") {
+		t.Fatal("zero limit means unlimited")
+	}
+}
+
+func TestIdleConnClosesAfterTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := watchIdle(server, 1500*time.Millisecond)
+
+	errCh := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+
+	// Input keeps the connection alive past the timeout.
+	for i := 0; i < 3; i++ {
+		time.Sleep(700 * time.Millisecond)
+		if _, err := client.Write([]byte{'x'}); err != nil {
+			t.Fatalf("connection closed while active: %v", err)
+		}
+	}
+
+	select {
+	case <-errCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle connection was not closed")
+	}
+}
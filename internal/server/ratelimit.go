@@ -0,0 +1,222 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimits holds a listener's connection rate thresholds. Zero fields use
+// the defaults below.
+type RateLimits struct {
+	Burst      int           // connections per host before backoff starts (3)
+	Max        int           // connections per host within Window before refusing (30), -1 = no per-host limit
+	Window     time.Duration // how long a host's attempts are remembered (10s)
+	AcceptRate float64       // new connections per second across all hosts, 0 = unlimited
+}
+
+// Default rate thresholds, and the backoff each attempt beyond the burst
+// adds before the connection is served.
+const (
+	defaultRateBurst  = 3
+	defaultRateMax    = 30
+	defaultRateWindow = 10 * time.Second
+	backoffStep       = 250 * time.Millisecond
+	maxBackoff        = 5 * time.Second
+)
+
+// Rejection reasons, as counted by RateLimiter.
+const (
+	RejectRate   = "rate"   // the host connected too often
+	RejectPerIP  = "per_ip" // the host has too many sessions open
+	RejectAccept = "accept" // the listener is over its accept rate
+)
+
+var rejectReasons = []string{RejectRate, RejectPerIP, RejectAccept}
+
+// rejectMessage describes each reason in the log.
+var rejectMessage = map[string]string{
+	RejectRate:   "too many connection attempts from this address",
+	RejectPerIP:  "too many sessions from this address",
+	RejectAccept: "over the accept rate",
+}
+
+// RateLimiter decides whether a listener serves a new connection: per-host
+// backoff on repeated attempts, a cap on concurrent sessions per host and a
+// global accept rate. It counts the connections it refuses.
+type RateLimiter struct {
+	kind, addr string // metric labels
+	limits     RateLimits
+	sessions   *ipLimiter
+
+	mu       sync.Mutex
+	attempts map[string]*attempt
+	pruned   time.Time
+	tokens   float64 // accept rate bucket
+	filled   time.Time
+
+	rejected [3]atomic.Int64 // by rejectReasons index
+}
+
+type attempt struct {
+	last  time.Time
+	count int
+}
+
+// NewRateLimiter creates the limiter for a listener of the given kind
+// ("telnet" or "ssh") on addr.
+func NewRateLimiter(kind, addr string, opts Options) *RateLimiter {
+	limits := opts.Rate
+	if limits.Burst == 0 {
+		limits.Burst = defaultRateBurst
+	}
+	if limits.Max == 0 {
+		limits.Max = defaultRateMax
+	}
+	if limits.Window <= 0 {
+		limits.Window = defaultRateWindow
+	}
+	return &RateLimiter{
+		kind:     kind,
+		addr:     addr,
+		limits:   limits,
+		sessions: newIPLimiter(opts.MaxPerIP),
+		attempts: make(map[string]*attempt),
+		tokens:   acceptBurst(limits.AcceptRate),
+	}
+}
+
+// acceptBurst is how many connections the accept rate lets through at once.
+func acceptBurst(rate float64) float64 {
+	return math.Max(1, math.Ceil(rate))
+}
+
+// Admit is called for each new connection from host. When it returns true
+// the caller must wait delay before serving the connection and call Release
+// when the session ends; otherwise it must close the connection with the
+// returned reason.
+func (rl *RateLimiter) Admit(host string) (delay time.Duration, reason string, ok bool) {
+	now := time.Now()
+	rl.mu.Lock()
+	accepted := rl.takeToken(now)
+	if accepted {
+		delay, ok = rl.backoff(host, now)
+	}
+	rl.mu.Unlock()
+
+	switch {
+	case !accepted:
+		reason = RejectAccept
+	case !ok:
+		reason = RejectRate
+	case !rl.sessions.acquire(host):
+		reason = RejectPerIP
+	default:
+		return delay, "", true
+	}
+	rl.count(reason)
+	return 0, reason, false
+}
+
+// Release frees the session slot taken by a successful Admit.
+func (rl *RateLimiter) Release(host string) {
+	rl.sessions.release(host)
+}
+
+// takeToken applies the accept rate. rl.mu must be held.
+func (rl *RateLimiter) takeToken(now time.Time) bool {
+	rate := rl.limits.AcceptRate
+	if rate <= 0 {
+		return true
+	}
+	if !rl.filled.IsZero() {
+		rl.tokens = math.Min(acceptBurst(rate), rl.tokens+now.Sub(rl.filled).Seconds()*rate)
+	}
+	rl.filled = now
+	if rl.tokens < 1 {
+		return false
+	}
+	rl.tokens--
+	return true
+}
+
+// backoff applies the per-host attempt limits: the first Burst connections
+// in a window are served at once, later ones after a growing delay, and
+// more than Max are refused. rl.mu must be held.
+func (rl *RateLimiter) backoff(host string, now time.Time) (time.Duration, bool) {
+	if rl.limits.Max < 0 {
+		return 0, true
+	}
+	rl.prune(now)
+
+	a := rl.attempts[host]
+	if a == nil {
+		a = &attempt{}
+		rl.attempts[host] = a
+	}
+	if now.Sub(a.last) <= rl.limits.Window {
+		a.count++
+	} else {
+		a.count = 1
+	}
+	a.last = now
+
+	if a.count > rl.limits.Max {
+		return 0, false
+	}
+	if a.count <= rl.limits.Burst {
+		return 0, true
+	}
+	return min(time.Duration(a.count-rl.limits.Burst)*backoffStep, maxBackoff), true
+}
+
+// prune forgets hosts whose attempts have expired, at most once a window.
+func (rl *RateLimiter) prune(now time.Time) {
+	if now.Sub(rl.pruned) < rl.limits.Window {
+		return
+	}
+	rl.pruned = now
+	for host, a := range rl.attempts {
+		if now.Sub(a.last) > rl.limits.Window {
+			delete(rl.attempts, host)
+		}
+	}
+}
+
+func (rl *RateLimiter) count(reason string) {
+	for i, r := range rejectReasons {
+		if r == reason {
+			rl.rejected[i].Add(1)
+		}
+	}
+}
+
+// Rejected returns how many connections were refused, by reason.
+func (rl *RateLimiter) Rejected() map[string]int64 {
+	out := make(map[string]int64, len(rejectReasons))
+	for i, r := range rejectReasons {
+		out[r] = rl.rejected[i].Load()
+	}
+	return out
+}
+
+// WriteMetrics writes the rejection counters of limiters in the Prometheus
+// text format.
+func WriteMetrics(w io.Writer, limiters []*RateLimiter) error {
+	const name = "twilight_connections_rejected_total"
+	if _, err := fmt.Fprintf(w, "# HELP %s Connections refused by listener limits.\n# TYPE %s counter\n", name, name); err != nil {
+		return err
+	}
+	for _, rl := range limiters {
+		for i, r := range rejectReasons {
+			_, err := fmt.Fprintf(w, "%s{listener=%q,addr=%q,reason=%q} %d\n", name, rl.kind, rl.addr, r, rl.rejected[i].Load())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
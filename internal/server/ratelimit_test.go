@@ -0,0 +1,63 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBacksOffAndRefuses(t *testing.T) {
+	rl := NewRateLimiter("telnet", ":2323", Options{Rate: RateLimits{Burst: 2, Max: 4}})
+
+	for i := 1; i <= 4; i++ {
+		delay, _, ok := rl.Admit("1.2.3.4")
+		if !ok {
+			t.Fatalf("attempt %d refused", i)
+		}
+		rl.Release("1.2.3.4")
+		if want := time.Duration(max(0, i-2)) * backoffStep; delay != want {
+			t.Fatalf("attempt %d delay = %v, want %v", i, delay, want)
+		}
+	}
+	if _, reason, ok := rl.Admit("1.2.3.4"); ok || reason != RejectRate {
+		t.Fatalf("fifth attempt = %v, %q; want refused for rate", ok, reason)
+	}
+	if _, _, ok := rl.Admit("5.6.7.8"); !ok {
+		t.Fatal("other hosts are tracked separately")
+	}
+}
+
+func TestRateLimiterSessionCap(t *testing.T) {
+	rl := NewRateLimiter("ssh", ":2222", Options{MaxPerIP: 1})
+	if _, _, ok := rl.Admit("1.2.3.4"); !ok {
+		t.Fatal("first session refused")
+	}
+	if _, reason, ok := rl.Admit("1.2.3.4"); ok || reason != RejectPerIP {
+		t.Fatalf("second session = %v, %q; want refused per IP", ok, reason)
+	}
+	rl.Release("1.2.3.4")
+	if _, _, ok := rl.Admit("1.2.3.4"); !ok {
+		t.Fatal("released slot should be reusable")
+	}
+}
+
+func TestRateLimiterAcceptRate(t *testing.T) {
+	rl := NewRateLimiter("telnet", ":2323", Options{Rate: RateLimits{Max: -1, AcceptRate: 2}})
+	for i := 0; i < 2; i++ {
+		if _, _, ok := rl.Admit("10.0.0.1"); !ok {
+			t.Fatalf("accept %d within the burst refused", i+1)
+		}
+	}
+	if _, reason, ok := rl.Admit("10.0.0.2"); ok || reason != RejectAccept {
+		t.Fatalf("third accept = %v, %q; want refused for accept rate", ok, reason)
+	}
+
+	var b strings.Builder
+	if err := WriteMetrics(&b, []*RateLimiter{rl}); err != nil {
+		t.Fatal(err)
+	}
+	want := `twilight_connections_rejected_total{listener="telnet",addr=":2323",reason="accept"} 1`
+	if !strings.Contains(b.String(), want) {
+		t.Fatalf("metrics missing %q:\n%s", want, b.String())
+	}
+}
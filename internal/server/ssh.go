@@ -11,7 +11,9 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"crypto/x509"
@@ -23,13 +25,18 @@ import (
 // compatible with the BBS terminal system.
 type SSHConn struct {
 	channel ssh.Channel
+	reader  *deadlineReader
 	mu      sync.Mutex
 
+	resizeMu sync.Mutex
+	onResize func(width, height int)
+
 	// Terminal properties from SSH
 	Width       int
 	Height      int
 	ANSICapable bool
 	TermType    string
+	ColorTerm   string // COLORTERM, if the client sent it
 
 	// Pre-authenticated credentials from SSH handshake
 	Username string
@@ -40,6 +47,7 @@ type SSHConn struct {
 func NewSSHConn(channel ssh.Channel, width, height int, termType string) *SSHConn {
 	return &SSHConn{
 		channel:     channel,
+		reader:      newDeadlineReader(channel),
 		Width:       width,
 		Height:      height,
 		ANSICapable: true, // SSH clients are typically ANSI-capable
@@ -49,7 +57,30 @@ func NewSSHConn(channel ssh.Channel, width, height int, termType string) *SSHCon
 
 // Read implements io.Reader.
 func (sc *SSHConn) Read(p []byte) (int, error) {
-	return sc.channel.Read(p)
+	return sc.reader.Read(p)
+}
+
+// SetReadDeadline sets the read deadline. SSH channels have no deadlines of
+// their own, so reads go through a deadlineReader.
+func (sc *SSHConn) SetReadDeadline(t time.Time) error {
+	return sc.reader.SetReadDeadline(t)
+}
+
+// SetResizeHandler registers fn to be called on window-change requests.
+func (sc *SSHConn) SetResizeHandler(fn func(width, height int)) {
+	sc.resizeMu.Lock()
+	defer sc.resizeMu.Unlock()
+	sc.onResize = fn
+}
+
+// resize is called from the channel's request goroutine.
+func (sc *SSHConn) resize(width, height int) {
+	sc.resizeMu.Lock()
+	fn := sc.onResize
+	sc.resizeMu.Unlock()
+	if fn != nil {
+		fn(width, height)
+	}
 }
 
 // Write implements io.Writer.
@@ -61,6 +92,7 @@ func (sc *SSHConn) Write(p []byte) (int, error) {
 
 // Close implements io.Closer.
 func (sc *SSHConn) Close() error {
+	sc.reader.Close()
 	return sc.channel.Close()
 }
 
@@ -74,88 +106,127 @@ func (sc *SSHConn) SetEcho(on bool) error {
 	return nil
 }
 
-// EnterBinaryMode returns the raw SSH channel for binary transfers.
-// SSH channels are already binary-safe so no special handling is needed.
+// EnterBinaryMode returns the SSH channel for binary transfers. SSH channels
+// are already binary-safe; reads still go through the deadline reader so no
+// input is lost between the terminal and the transfer.
 func (sc *SSHConn) EnterBinaryMode() (io.ReadWriter, func(), bool) {
+	_ = sc.reader.SetReadDeadline(time.Time{})
 	rw := struct {
 		io.Reader
 		io.Writer
-	}{sc.channel, sc.channel}
+	}{sc.reader, sc.channel}
 	return rw, func() {}, false
 }
 
 // SSHListener accepts incoming SSH connections.
 type SSHListener struct {
 	addr        string
+	opts        Options
+	limiter     *RateLimiter
 	config      *ssh.ServerConfig
 	handler     func(conn *SSHConn, remoteAddr, username, password string)
 	hostKeyPath string
 
-	attemptMu sync.Mutex
-	attempts  map[string]*sshAttempt
-
-	// Captured credentials from SSH auth
-	username string
-	password string
-
 	// User authenticator for validating SSH passwords
 	authenticator PasswordAuthenticator
+
+	listening atomic.Bool
+
+	// exec runs exec channel commands; nil disables exec
+	exec ExecHandler
+
+	// sftp serves the sftp subsystem; nil disables it
+	sftp SFTPHandler
+
+	// scp serves "scp -f/-t" exec commands; nil disables scp
+	scp SCPHandler
 }
 
+// passwordExtension is the Permissions.Extensions key holding the password a
+// connection authenticated with, so each session gets its own credentials.
+const passwordExtension = "twilight-password"
+
+// keyExtension is the Permissions.Extensions key set to the key fingerprint
+// when a connection authenticated with a public key.
+const keyExtension = "twilight-key"
+
+// secondFactorExtension is the Permissions.Extensions key set when a
+// connection authenticated with only a password for an account that uses
+// two-factor authentication, or must.
+const secondFactorExtension = "twilight-second-factor"
+
 // PasswordAuthenticator validates username/password credentials.
 type PasswordAuthenticator interface {
 	Authenticate(username, password string) (bool, error)
 }
 
-// NewSSHListener creates a new SSH listener.
-func NewSSHListener(port int, hostKeyPath string, authenticator PasswordAuthenticator, handler func(conn *SSHConn, remoteAddr, username, password string)) (*SSHListener, error) {
+// PublicKeyAuthenticator is implemented by authenticators that also accept
+// registered public keys.
+type PublicKeyAuthenticator interface {
+	AuthenticateKey(username string, key ssh.PublicKey) (bool, error)
+}
+
+// SecondFactorAuthenticator is implemented by authenticators that know
+// which accounts use two-factor authentication. The SSH handshake cannot
+// ask for the code, so a password alone gets those accounts the shell,
+// where the login menus ask for it, but not file access.
+type SecondFactorAuthenticator interface {
+	NeedsSecondFactor(username string) (bool, error)
+}
+
+// ExecRequest is a command sent on an exec channel ("ssh bbs 'cmd'").
+type ExecRequest struct {
+	Username   string
+	Command    string
+	RemoteAddr string
+}
+
+// ExecHandler runs an exec command and returns its exit status. Exec is
+// only offered to connections that authenticated with a public key.
+type ExecHandler func(req ExecRequest, stdin io.Reader, stdout, stderr io.Writer) int
+
+// SetExecHandler enables exec channels, served by h.
+func (l *SSHListener) SetExecHandler(h ExecHandler) {
+	l.exec = h
+}
+
+// SFTPHandler serves the sftp subsystem on ch for an authenticated user
+// and returns when the client is done.
+type SFTPHandler func(username, remoteAddr string, ch io.ReadWriter)
+
+// SCPHandler serves a legacy scp command on ch for an authenticated user
+// and returns its exit status.
+type SCPHandler func(username, remoteAddr, command string, ch io.ReadWriter, stderr io.Writer) int
+
+// SetSCPHandler enables scp exec commands, served by h. Unlike other exec
+// commands, scp is allowed after password authentication.
+func (l *SSHListener) SetSCPHandler(h SCPHandler) {
+	l.scp = h
+}
+
+// SetSFTPHandler enables the sftp subsystem, served by h.
+func (l *SSHListener) SetSFTPHandler(h SFTPHandler) {
+	l.sftp = h
+}
+
+// NewSSHListener creates a new SSH listener on addr (host:port, host may be
+// empty for all interfaces), offering the algorithms in Options.SSH.
+// Options.TLSConfig is ignored.
+func NewSSHListener(addr string, opts Options, hostKeyPath string, authenticator PasswordAuthenticator, handler func(conn *SSHConn, remoteAddr, username, password string)) (*SSHListener, error) {
+	if err := opts.SSH.Check(); err != nil {
+		return nil, err
+	}
 	l := &SSHListener{
-		addr:          fmt.Sprintf(":%d", port),
+		addr:          addr,
+		opts:          opts,
+		limiter:       NewRateLimiter("ssh", addr, opts),
 		handler:       handler,
 		hostKeyPath:   hostKeyPath,
 		authenticator: authenticator,
-		attempts:      make(map[string]*sshAttempt),
 	}
 
 	config := &ssh.ServerConfig{
-		Config: ssh.Config{
-			KeyExchanges: []string{
-				// Modern first
-				"curve25519-sha256",
-				"curve25519-sha256@libssh.org",
-				"ecdh-sha2-nistp256",
-				"ecdh-sha2-nistp384",
-				"ecdh-sha2-nistp521",
-				"diffie-hellman-group-exchange-sha256",
-				"diffie-hellman-group14-sha256",
-				"diffie-hellman-group16-sha512",
-				// Legacy for older SSH clients (SyncTerm cryptlib/libssh2)
-				"diffie-hellman-group-exchange-sha1",
-				"diffie-hellman-group14-sha1",
-				"diffie-hellman-group1-sha1",
-			},
-			Ciphers: []string{
-				// Modern first
-				"chacha20-poly1305@openssh.com",
-				"aes128-gcm@openssh.com",
-				"aes256-gcm@openssh.com",
-				"aes128-ctr",
-				"aes192-ctr",
-				"aes256-ctr",
-				// Legacy CBC modes for older SSH clients
-				"aes128-cbc",
-				"3des-cbc",
-			},
-			MACs: []string{
-				// Modern first
-				"hmac-sha2-256-etm@openssh.com",
-				"hmac-sha2-512-etm@openssh.com",
-				"hmac-sha2-256",
-				"hmac-sha2-512",
-				// Legacy
-				"hmac-sha1",
-			},
-		},
+		Config:        opts.SSH.config(),
 		ServerVersion: "SSH-2.0-TwilightBBS",
 		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
 			username := c.User()
@@ -174,13 +245,35 @@ func NewSSHListener(port int, hostKeyPath string, authenticator PasswordAuthenti
 				}
 			}
 			
-			// Store credentials for pre-auth in BBS
-			l.username = username
-			l.password = password
-			return nil, nil
+			// Carry the password with this connection for pre-auth in the BBS.
+			perms := &ssh.Permissions{Extensions: map[string]string{passwordExtension: password}}
+			if sf, ok := l.authenticator.(SecondFactorAuthenticator); ok {
+				need, err := sf.NeedsSecondFactor(username)
+				if err != nil {
+					log.Printf("SSH auth error for user %s: %v", username, err)
+					return nil, fmt.Errorf("authentication failed")
+				}
+				if need {
+					perms.Extensions[secondFactorExtension] = "1"
+				}
+			}
+			return perms, nil
 		},
 		NoClientAuth: false,  // Require authentication
 	}
+	if ka, ok := authenticator.(PublicKeyAuthenticator); ok {
+		config.PublicKeyCallback = func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			ok, err := ka.AuthenticateKey(c.User(), key)
+			if err != nil {
+				log.Printf("SSH key auth error for user %s: %v", c.User(), err)
+				return nil, fmt.Errorf("authentication failed")
+			}
+			if !ok {
+				return nil, fmt.Errorf("unknown key")
+			}
+			return &ssh.Permissions{Extensions: map[string]string{keyExtension: ssh.FingerprintSHA256(key)}}, nil
+		}
+	}
 
 	l.config = config
 
@@ -313,55 +406,14 @@ func (l *SSHListener) loadOrGenerateHostKey() error {
 	return nil
 }
 
-type sshAttempt struct {
-	last  time.Time
-	count int
+// Limiter returns the listener's rate limiter, for metrics.
+func (l *SSHListener) Limiter() *RateLimiter {
+	return l.limiter
 }
 
-func (l *SSHListener) allowConnection(host string) (time.Duration, bool) {
-	// Simple per-host backoff to reduce anonymous connection abuse.
-	// Not perfect, but it meaningfully raises the cost of flooding.
-	const (
-		window     = 10 * time.Second
-		resetAfter = 30 * time.Second
-		maxCount   = 30
-		step       = 250 * time.Millisecond
-		maxDelay   = 5 * time.Second
-	)
-
-	now := time.Now()
-
-	l.attemptMu.Lock()
-	defer l.attemptMu.Unlock()
-
-	a := l.attempts[host]
-	if a == nil {
-		a = &sshAttempt{last: now}
-		l.attempts[host] = a
-	}
-
-	if now.Sub(a.last) > resetAfter {
-		a.count = 0
-	}
-	if now.Sub(a.last) <= window {
-		a.count++
-	} else {
-		a.count = 1
-	}
-	a.last = now
-
-	if a.count > maxCount {
-		return 0, false
-	}
-
-	if a.count <= 3 {
-		return 0, true
-	}
-	d := time.Duration(a.count-3) * step
-	if d > maxDelay {
-		d = maxDelay
-	}
-	return d, true
+// Listening reports whether the listener is accepting connections.
+func (l *SSHListener) Listening() bool {
+	return l.listening.Load()
 }
 
 // ListenAndServe starts accepting SSH connections.
@@ -371,6 +423,8 @@ func (l *SSHListener) ListenAndServe() error {
 		return fmt.Errorf("listen %s: %w", l.addr, err)
 	}
 	defer ln.Close()
+	l.listening.Store(true)
+	defer l.listening.Store(false)
 
 	log.Printf("SSH server listening on %s", l.addr)
 
@@ -392,12 +446,17 @@ func (l *SSHListener) handleConnection(conn net.Conn) {
 	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
 		host = h
 	}
-	if delay, ok := l.allowConnection(host); !ok {
+	delay, reason, ok := l.limiter.Admit(host)
+	if !ok {
+		if reason != RejectRate {
+			log.Printf("SSH: rejecting %s on %s (%s)", host, l.addr, rejectMessage[reason])
+		}
 		conn.Close()
 		return
-	} else if delay > 0 {
-		time.Sleep(delay)
 	}
+	defer l.limiter.Release(host)
+	time.Sleep(delay)
+	conn = watchIdle(conn, l.opts.IdleTimeout)
 
 	_ = conn.SetDeadline(time.Now().Add(20 * time.Second))
 	defer conn.SetDeadline(time.Time{})
@@ -434,8 +493,13 @@ func (l *SSHListener) handleConnection(conn net.Conn) {
 		width := 80
 		height := 24
 		termType := "xterm"
+		colorTerm := ""
 
+		// The request loop keeps running during the session so that
+		// window-change requests reach the terminal.
 		go func() {
+			var sc *SSHConn
+			execStarted := false
 			for req := range requests {
 				switch req.Type {
 				case "pty-req":
@@ -456,16 +520,92 @@ func (l *SSHListener) handleConnection(conn net.Conn) {
 					}
 
 				case "shell":
+					if sc != nil {
+						if req.WantReply {
+							req.Reply(false, nil)
+						}
+						continue
+					}
 					if req.WantReply {
 						req.Reply(true, nil)
 					}
 					// Create SSHConn and hand off to BBS
-					sc := NewSSHConn(channel, width, height, termType)
-					sc.Username = l.username
-					sc.Password = l.password
-					l.handler(sc, remoteAddr, sc.Username, sc.Password)
-					channel.Close()
-					return
+					sc = NewSSHConn(channel, width, height, termType)
+					sc.ColorTerm = colorTerm
+					sc.Username = sshConn.User()
+					if sshConn.Permissions != nil {
+						sc.Password = sshConn.Permissions.Extensions[passwordExtension]
+					}
+					go func(sc *SSHConn) {
+						l.handler(sc, remoteAddr, sc.Username, sc.Password)
+						sc.Close()
+					}(sc)
+
+				case "exec":
+					if sc != nil || execStarted {
+						if req.WantReply {
+							req.Reply(false, nil)
+						}
+						continue
+					}
+					execStarted = true
+					var payload struct{ Command string }
+					if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+						if req.WantReply {
+							req.Reply(false, nil)
+						}
+						continue
+					}
+					if req.WantReply {
+						req.Reply(true, nil)
+					}
+					if l.scp != nil && strings.HasPrefix(payload.Command, "scp ") {
+						go l.runSCP(channel, sshConn.User(), remoteAddr, payload.Command, needsSecondFactor(sshConn))
+						continue
+					}
+					keyAuth := sshConn.Permissions != nil && sshConn.Permissions.Extensions[keyExtension] != ""
+					go l.runExec(channel, ExecRequest{
+						Username:   sshConn.User(),
+						Command:    payload.Command,
+						RemoteAddr: remoteAddr,
+					}, keyAuth)
+
+				case "subsystem":
+					var payload struct{ Name string }
+					// Without an authenticator any password is accepted, so
+					// file access is never offered.
+					if sc != nil || execStarted || l.sftp == nil || l.authenticator == nil ||
+						ssh.Unmarshal(req.Payload, &payload) != nil || payload.Name != "sftp" {
+						if req.WantReply {
+							req.Reply(false, nil)
+						}
+						continue
+					}
+					execStarted = true
+					if needsSecondFactor(sshConn) {
+						log.Printf("SSH sftp refused for %s (user: %s): two-factor account without a key", remoteAddr, sshConn.User())
+						fmt.Fprintln(channel.Stderr(), errSecondFactor)
+						if req.WantReply {
+							req.Reply(false, nil)
+						}
+						continue
+					}
+					if req.WantReply {
+						req.Reply(true, nil)
+					}
+					go l.runSFTP(channel, sshConn.User(), remoteAddr)
+
+				case "env":
+					// Only COLORTERM is of use: it tells truecolor clients
+					// apart, as TERM rarely does.
+					var env struct{ Name, Value string }
+					ok := ssh.Unmarshal(req.Payload, &env) == nil && env.Name == "COLORTERM"
+					if ok {
+						colorTerm = env.Value
+					}
+					if req.WantReply {
+						req.Reply(ok, nil)
+					}
 
 				case "window-change":
 					if len(req.Payload) >= 8 {
@@ -473,6 +613,9 @@ func (l *SSHListener) handleConnection(conn net.Conn) {
 							int(req.Payload[2])<<8 | int(req.Payload[3])
 						height = int(req.Payload[4])<<24 | int(req.Payload[5])<<16 |
 							int(req.Payload[6])<<8 | int(req.Payload[7])
+						if sc != nil {
+							sc.resize(width, height)
+						}
 					}
 
 				default:
@@ -485,5 +628,60 @@ func (l *SSHListener) handleConnection(conn net.Conn) {
 	}
 }
 
+// runExec runs one exec command on channel and reports its exit status.
+func (l *SSHListener) runExec(channel ssh.Channel, req ExecRequest, keyAuth bool) {
+	defer channel.Close()
+
+	status := 1
+	switch {
+	case l.exec == nil:
+		fmt.Fprintln(channel.Stderr(), "exec is not enabled on this server")
+	case !keyAuth:
+		fmt.Fprintln(channel.Stderr(), "exec requires public key authentication")
+	default:
+		log.Printf("SSH exec from %s (user: %s): %s", req.RemoteAddr, req.Username, req.Command)
+		status = l.exec(req, channel, channel, channel.Stderr())
+	}
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+}
+
+// runSFTP serves the sftp subsystem on channel until the client closes it.
+func (l *SSHListener) runSFTP(channel ssh.Channel, username, remoteAddr string) {
+	defer channel.Close()
+	log.Printf("SSH sftp session from %s (user: %s)", remoteAddr, username)
+	l.sftp(username, remoteAddr, channel)
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+}
+
+// errSecondFactor is shown to a two-factor account that asks for file
+// access after authenticating with only a password.
+const errSecondFactor = "file access for accounts with two-factor authentication requires public key authentication"
+
+// needsSecondFactor reports whether conn authenticated with only a
+// password for an account that uses two-factor authentication.
+func needsSecondFactor(conn *ssh.ServerConn) bool {
+	return conn.Permissions != nil && conn.Permissions.Extensions[secondFactorExtension] != ""
+}
+
+// runSCP runs one scp transfer on channel and reports its exit status.
+// secondFactor is set when the account needed a code the connection did
+// not give.
+func (l *SSHListener) runSCP(channel ssh.Channel, username, remoteAddr, command string, secondFactor bool) {
+	defer channel.Close()
+
+	status := 1
+	switch {
+	case l.authenticator == nil:
+		fmt.Fprintln(channel.Stderr(), "scp requires authentication")
+	case secondFactor:
+		log.Printf("SSH scp refused for %s (user: %s): two-factor account without a key", remoteAddr, username)
+		fmt.Fprintln(channel.Stderr(), errSecondFactor)
+	default:
+		log.Printf("SSH scp from %s (user: %s): %s", remoteAddr, username, command)
+		status = l.scp(username, remoteAddr, command, channel, channel.Stderr())
+	}
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+}
+
 // Ensure SSHConn implements io.ReadWriteCloser.
 var _ io.ReadWriteCloser = (*SSHConn)(nil)
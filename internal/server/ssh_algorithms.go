@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHAlgorithms chooses the algorithms SSH listeners offer. The zero value
+// offers only modern ones.
+type SSHAlgorithms struct {
+	// Legacy also offers SHA-1 key exchanges and MACs, CBC ciphers and
+	// diffie-hellman-group1, which older clients such as SyncTERM
+	// (cryptlib/libssh2) need.
+	Legacy bool
+
+	// Lists that replace the built-in ones, in preference order; Legacy
+	// does not add to a list that is set.
+	KeyExchanges []string
+	Ciphers      []string
+	MACs         []string
+}
+
+// Built-in algorithm lists, modern first.
+var (
+	modernKeyExchanges = []string{
+		"curve25519-sha256",
+		"curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256",
+		"ecdh-sha2-nistp384",
+		"ecdh-sha2-nistp521",
+		"diffie-hellman-group-exchange-sha256",
+		"diffie-hellman-group14-sha256",
+		"diffie-hellman-group16-sha512",
+	}
+	legacyKeyExchanges = []string{
+		"diffie-hellman-group-exchange-sha1",
+		"diffie-hellman-group14-sha1",
+		"diffie-hellman-group1-sha1",
+	}
+	modernCiphers = []string{
+		"chacha20-poly1305@openssh.com",
+		"aes128-gcm@openssh.com",
+		"aes256-gcm@openssh.com",
+		"aes128-ctr",
+		"aes192-ctr",
+		"aes256-ctr",
+	}
+	legacyCiphers = []string{
+		"aes128-cbc",
+		"3des-cbc",
+	}
+	modernMACs = []string{
+		"hmac-sha2-256-etm@openssh.com",
+		"hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256",
+		"hmac-sha2-512",
+	}
+	legacyMACs = []string{
+		"hmac-sha1",
+	}
+)
+
+// Check reports algorithms in the lists that the SSH library does not
+// implement.
+func (a SSHAlgorithms) Check() error {
+	known := ssh.SupportedAlgorithms()
+	insecure := ssh.InsecureAlgorithms()
+	for _, list := range []struct {
+		what  string
+		names []string
+		known []string
+	}{
+		{"key exchange", a.KeyExchanges, append(known.KeyExchanges, insecure.KeyExchanges...)},
+		{"cipher", a.Ciphers, append(known.Ciphers, insecure.Ciphers...)},
+		{"MAC", a.MACs, append(known.MACs, insecure.MACs...)},
+	} {
+		for _, name := range list.names {
+			if !slices.Contains(list.known, name) {
+				return fmt.Errorf("unsupported SSH %s %q", list.what, name)
+			}
+		}
+	}
+	return nil
+}
+
+// config returns the algorithm part of an SSH server config.
+func (a SSHAlgorithms) config() ssh.Config {
+	pick := func(set, modern, legacy []string) []string {
+		if len(set) > 0 {
+			return slices.Clone(set)
+		}
+		if a.Legacy {
+			return slices.Concat(modern, legacy)
+		}
+		return slices.Clone(modern)
+	}
+	return ssh.Config{
+		KeyExchanges: pick(a.KeyExchanges, modernKeyExchanges, legacyKeyExchanges),
+		Ciphers:      pick(a.Ciphers, modernCiphers, legacyCiphers),
+		MACs:         pick(a.MACs, modernMACs, legacyMACs),
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// handshake connects a client that offers only the given ciphers and
+// returns the handshake error.
+func handshake(t *testing.T, l *SSHListener, ciphers []string) error {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			l.handleConnection(conn)
+		}
+	}()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	c, _, _, err := ssh.NewClientConn(client, "bbs", &ssh.ClientConfig{
+		Config:          ssh.Config{Ciphers: ciphers},
+		User:            "sysop",
+		Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err == nil {
+		c.Close()
+	}
+	return err
+}
+
+func TestSSHAlgorithms(t *testing.T) {
+	cfg := SSHAlgorithms{}.config()
+	if slices.Contains(cfg.Ciphers, "aes128-cbc") || slices.Contains(cfg.MACs, "hmac-sha1") ||
+		slices.Contains(cfg.KeyExchanges, "diffie-hellman-group1-sha1") {
+		t.Errorf("modern profile offers legacy algorithms: %+v", cfg)
+	}
+	cfg = SSHAlgorithms{Legacy: true}.config()
+	if !slices.Contains(cfg.Ciphers, "3des-cbc") || cfg.Ciphers[0] != "chacha20-poly1305@openssh.com" {
+		t.Errorf("legacy ciphers = %v", cfg.Ciphers)
+	}
+	cfg = SSHAlgorithms{Legacy: true, MACs: []string{"hmac-sha2-256"}}.config()
+	if !slices.Equal(cfg.MACs, []string{"hmac-sha2-256"}) {
+		t.Errorf("MACs from the list = %v", cfg.MACs)
+	}
+
+	if err := (SSHAlgorithms{Ciphers: []string{"aes128-ctr", "rot13"}}).Check(); err == nil {
+		t.Error("unknown cipher accepted")
+	}
+	if err := (SSHAlgorithms{KeyExchanges: []string{"diffie-hellman-group1-sha1"}}).Check(); err != nil {
+		t.Errorf("insecure but implemented key exchange refused: %v", err)
+	}
+
+	dir := t.TempDir()
+	for _, legacy := range []bool{false, true} {
+		l, err := NewSSHListener("127.0.0.1:0", Options{SSH: SSHAlgorithms{Legacy: legacy}},
+			filepath.Join(dir, "host_key"), testAuth{}, func(*SSHConn, string, string, string) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := handshake(t, l, []string{"aes128-ctr"}); err != nil {
+			t.Errorf("legacy=%v: modern client: %v", legacy, err)
+		}
+		err = handshake(t, l, []string{"aes128-cbc"})
+		if legacy && err != nil {
+			t.Errorf("legacy=%v: CBC client: %v", legacy, err)
+		}
+		if !legacy && err == nil {
+			t.Errorf("legacy=%v: CBC client got in", legacy)
+		}
+	}
+}
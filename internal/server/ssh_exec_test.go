@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type testAuth struct{ key ssh.PublicKey }
+
+func (a testAuth) Authenticate(username, password string) (bool, error) {
+	return password == "secret", nil
+}
+
+func (a testAuth) AuthenticateKey(username string, key ssh.PublicKey) (bool, error) {
+	return bytes.Equal(key.Marshal(), a.key.Marshal()), nil
+}
+
+// dial serves one connection on a loopback socket and returns a client.
+func dial(t *testing.T, l *SSHListener, auth ssh.AuthMethod) *ssh.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			l.handleConnection(conn)
+		}
+	}()
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(clientConn, "bbs", &ssh.ClientConfig{
+		User:            "sysop",
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestSSHExec(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewSSHListener("127.0.0.1:0", Options{}, filepath.Join(t.TempDir(), "host_key"),
+		testAuth{signer.PublicKey()}, func(*SSHConn, string, string, string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetExecHandler(func(req ExecRequest, stdin io.Reader, stdout, stderr io.Writer) int {
+		body, _ := io.ReadAll(stdin)
+		io.WriteString(stdout, req.Username+" ran "+req.Command+" with "+string(body))
+		return 3
+	})
+
+	sess, err := dial(t, l, ssh.PublicKeys(signer)).NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	sess.Stdout = &out
+	sess.Stdin = strings.NewReader("hello")
+	err = sess.Run("post --area 1")
+	if exit, ok := err.(*ssh.ExitError); !ok || exit.ExitStatus() != 3 {
+		t.Fatalf("run error = %v", err)
+	}
+	if out.String() != "sysop ran post --area 1 with hello" {
+		t.Fatalf("output = %q", out.String())
+	}
+
+	// Password logins get the interactive shell only.
+	sess, err = dial(t, l, ssh.Password("secret")).NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errOut bytes.Buffer
+	sess.Stderr = &errOut
+	if err := sess.Run("areas"); err == nil || !strings.Contains(errOut.String(), "public key") {
+		t.Fatalf("password exec: err %v, stderr %q", err, errOut.String())
+	}
+}
+
+// twoFactorAuth is testAuth for an account with two-factor authentication.
+type twoFactorAuth struct{ testAuth }
+
+func (twoFactorAuth) NeedsSecondFactor(username string) (bool, error) { return true, nil }
+
+func TestSSHFileAccessNeedsKeyWithTwoFactor(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewSSHListener("127.0.0.1:0", Options{}, filepath.Join(t.TempDir(), "host_key"),
+		twoFactorAuth{testAuth{signer.PublicKey()}}, func(*SSHConn, string, string, string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := 0
+	l.SetSFTPHandler(func(username, remoteAddr string, ch io.ReadWriter) { served++ })
+	l.SetSCPHandler(func(username, remoteAddr, command string, ch io.ReadWriter, stderr io.Writer) int {
+		served++
+		return 0
+	})
+
+	// A password alone opens neither sftp nor scp.
+	client := dial(t, l, ssh.Password("secret"))
+	sess, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.RequestSubsystem("sftp"); err == nil {
+		t.Error("sftp subsystem granted with only a password")
+	}
+	sess, err = client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errOut bytes.Buffer
+	sess.Stderr = &errOut
+	if err := sess.Run("scp -f uploads/FILE.ZIP"); err == nil || !strings.Contains(errOut.String(), "two-factor") {
+		t.Errorf("password scp: err %v, stderr %q", err, errOut.String())
+	}
+	if served != 0 {
+		t.Fatalf("file access served %d time(s)", served)
+	}
+
+	// A key is a factor of its own.
+	sess, err = dial(t, l, ssh.PublicKeys(signer)).NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Run("scp -f uploads/FILE.ZIP"); err != nil || served != 1 {
+		t.Errorf("key scp: err %v, served %d", err, served)
+	}
+}
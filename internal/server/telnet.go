@@ -3,6 +3,7 @@ package server
 import (
 	"bufio"
 	"bytes"
+	"compress/zlib"
 	"fmt"
 	"io"
 	"net"
@@ -27,6 +28,7 @@ const (
 	OptTType   byte = 24 // Terminal Type
 	OptNAWS    byte = 31 // Negotiate About Window Size
 	OptLinemod byte = 34 // Linemode
+	OptMCCP2   byte = 86 // Mud Client Compression Protocol v2
 )
 
 // TelnetConn wraps a raw TCP connection with telnet protocol handling.
@@ -42,6 +44,28 @@ type TelnetConn struct {
 	Width       int
 	Height      int
 	ANSICapable bool
+
+	onResize func(width, height int)
+
+	// MCCP2 output compression, guarded by mu. zw is the open zlib stream,
+	// nil while output is plain.
+	offerMCCP bool // WILL MCCP2 is sent by Negotiate
+	mccp      bool // the client accepted MCCP2
+	zw        *zlib.Writer
+	wire      countingWriter // bytes the zlib streams put on the wire
+	plain     int64          // bytes written into the zlib streams
+}
+
+// countingWriter counts what is written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // NewTelnetConn wraps a raw TCP connection with telnet protocol handling.
@@ -52,9 +76,16 @@ func NewTelnetConn(conn net.Conn) *TelnetConn {
 		Width:       80,
 		Height:      24,
 		ANSICapable: true, // assume ANSI until told otherwise
+		wire:        countingWriter{w: conn},
 	}
 }
 
+// OfferCompression makes Negotiate offer MCCP2, which compresses
+// everything sent to clients that accept it. Call it before Negotiate.
+func (tc *TelnetConn) OfferCompression() {
+	tc.offerMCCP = true
+}
+
 // Negotiate sends initial telnet option negotiations.
 func (tc *TelnetConn) Negotiate() error {
 	// WILL ECHO - we control echo (important for password prompts)
@@ -81,6 +112,12 @@ func (tc *TelnetConn) Negotiate() error {
 	if err := tc.sendCommand(DO, OptTType); err != nil {
 		return err
 	}
+	// WILL MCCP2 - offer to compress output
+	if tc.offerMCCP {
+		if err := tc.sendCommand(WILL, OptMCCP2); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -88,10 +125,62 @@ func (tc *TelnetConn) Negotiate() error {
 func (tc *TelnetConn) sendCommand(cmd, option byte) error {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
-	_, err := tc.conn.Write([]byte{IAC, cmd, option})
+	return tc.write([]byte{IAC, cmd, option})
+}
+
+// write sends p as it is, through the zlib stream while compressing. The
+// stream is flushed so the client sees p now. The caller holds mu.
+func (tc *TelnetConn) write(p []byte) error {
+	if tc.zw == nil {
+		_, err := tc.conn.Write(p)
+		return err
+	}
+	if _, err := tc.zw.Write(p); err != nil {
+		return err
+	}
+	tc.plain += int64(len(p))
+	return tc.zw.Flush()
+}
+
+// startCompression begins an MCCP2 stream: everything after IAC SB MCCP2
+// IAC SE is zlib compressed. The caller holds mu.
+func (tc *TelnetConn) startCompression() error {
+	if tc.zw != nil {
+		return nil
+	}
+	if _, err := tc.conn.Write([]byte{IAC, SB, OptMCCP2, IAC, SE}); err != nil {
+		return err
+	}
+	tc.zw = zlib.NewWriter(&tc.wire)
+	return nil
+}
+
+// stopCompression ends the MCCP2 stream; the client reads plain telnet
+// after it. The caller holds mu.
+func (tc *TelnetConn) stopCompression() error {
+	if tc.zw == nil {
+		return nil
+	}
+	err := tc.zw.Close()
+	tc.zw = nil
 	return err
 }
 
+// Compressing reports whether output is MCCP2 compressed.
+func (tc *TelnetConn) Compressing() bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.zw != nil
+}
+
+// CompressionStats returns how many bytes were compressed and how many
+// went on the wire for them.
+func (tc *TelnetConn) CompressionStats() (plain, wire int64) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.plain, tc.wire.n
+}
+
 // ReadByte reads a single byte from the connection, handling IAC sequences.
 func (tc *TelnetConn) ReadByte() (byte, error) {
 	for {
@@ -178,27 +267,12 @@ func (tc *TelnetConn) Write(p []byte) (int, error) {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
 
-	written := 0
-	for i, b := range p {
-		if b == IAC {
-			// Write everything up to this byte
-			if i > written {
-				if _, err := tc.conn.Write(p[written:i]); err != nil {
-					return written, err
-				}
-			}
-			// Escape the IAC byte
-			if _, err := tc.conn.Write([]byte{IAC, IAC}); err != nil {
-				return i, err
-			}
-			written = i + 1
-		}
+	out := p
+	if bytes.IndexByte(p, IAC) >= 0 {
+		out = bytes.ReplaceAll(p, []byte{IAC}, []byte{IAC, IAC})
 	}
-	// Write remaining bytes
-	if written < len(p) {
-		if _, err := tc.conn.Write(p[written:]); err != nil {
-			return written, err
-		}
+	if err := tc.write(out); err != nil {
+		return 0, err
 	}
 	return len(p), nil
 }
@@ -207,7 +281,10 @@ func (tc *TelnetConn) Write(p []byte) (int, error) {
 func (tc *TelnetConn) WriteRaw(p []byte) (int, error) {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
-	return tc.conn.Write(p)
+	if err := tc.write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
 }
 
 // rawTelnetRW wraps the raw TCP connection for binary mode transfers.
@@ -221,14 +298,20 @@ func (r *rawTelnetRW) Read(p []byte) (int, error)  { return r.conn.Read(p) }
 func (r *rawTelnetRW) Write(p []byte) (int, error) { return r.conn.Write(p) }
 
 // EnterBinaryMode switches the telnet connection into raw binary mode for
-// file transfers. It drains any bytes already buffered in the internal
-// bufio.Reader and returns:
+// file transfers, ending any MCCP2 stream first since transfer tools write
+// to the socket themselves; compression resumes after the transfer. It
+// drains any bytes already buffered in the internal bufio.Reader and
+// returns:
 //   - rw: a raw io.ReadWriter bypassing all IAC processing
 //   - cleanup: a function to call when the transfer is done to restore
 //     normal telnet operation
 //   - isTelnet: true, indicating the caller should tell the transfer tool
 //     that this is a telnet connection (e.g. SEXYZ -telnet flag)
 func (tc *TelnetConn) EnterBinaryMode() (io.ReadWriter, func(), bool) {
+	tc.mu.Lock()
+	_ = tc.stopCompression()
+	tc.mu.Unlock()
+
 	// Drain any data already buffered in the bufio.Reader. These bytes
 	// have been read from the TCP socket but not yet consumed by the BBS.
 	// We must include them in the raw stream so the transfer tool sees them.
@@ -257,6 +340,12 @@ func (tc *TelnetConn) EnterBinaryMode() (io.ReadWriter, func(), bool) {
 		// Rebuild the buffered reader around the raw connection so that
 		// normal telnet IAC processing resumes.
 		tc.reader = bufio.NewReaderSize(tc.conn, 1024)
+
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+		if tc.mccp {
+			_ = tc.startCompression()
+		}
 	}
 
 	return rw, cleanup, true
@@ -271,8 +360,11 @@ type prefixedReadWriter struct {
 func (p *prefixedReadWriter) Read(buf []byte) (int, error)  { return p.reader.Read(buf) }
 func (p *prefixedReadWriter) Write(buf []byte) (int, error) { return p.writer.Write(buf) }
 
-// Close closes the underlying connection.
+// Close ends any MCCP2 stream and closes the underlying connection.
 func (tc *TelnetConn) Close() error {
+	tc.mu.Lock()
+	_ = tc.stopCompression()
+	tc.mu.Unlock()
 	return tc.conn.Close()
 }
 
@@ -287,6 +379,12 @@ func (tc *TelnetConn) RemoteAddr() net.Addr {
 	return tc.conn.RemoteAddr()
 }
 
+// SetResizeHandler registers fn to be called when the client reports a new
+// window size via NAWS after the initial negotiation.
+func (tc *TelnetConn) SetResizeHandler(fn func(width, height int)) {
+	tc.onResize = fn
+}
+
 // SetEcho enables or disables server-side echo.
 func (tc *TelnetConn) SetEcho(on bool) error {
 	// Telnet ECHO negotiation controls whether the client should perform local echo.
@@ -309,7 +407,7 @@ func (tc *TelnetConn) handleWillWont(cmd, opt byte) {
 			// Client supports terminal type - request it
 			// Send SB TTYPE SEND SE
 			tc.mu.Lock()
-			tc.conn.Write([]byte{IAC, SB, OptTType, 1, IAC, SE})
+			tc.write([]byte{IAC, SB, OptTType, 1, IAC, SE})
 			tc.mu.Unlock()
 		}
 	case OptLinemod:
@@ -325,6 +423,21 @@ func (tc *TelnetConn) handleDoDont(cmd, opt byte) {
 	switch opt {
 	case OptEcho, OptSGA:
 		// We already said WILL for these, client confirms with DO
+	case OptMCCP2:
+		if !tc.offerMCCP {
+			if cmd == DO {
+				tc.sendCommand(WONT, opt)
+			}
+			return
+		}
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+		tc.mccp = cmd == DO
+		if tc.mccp {
+			tc.startCompression()
+		} else {
+			tc.stopCompression()
+		}
 	default:
 		if cmd == DO {
 			// We don't support this option - send WONT
@@ -377,6 +490,9 @@ func (tc *TelnetConn) handleSubNegotiation() error {
 		if len(buf) >= 5 {
 			tc.Width = int(buf[1])<<8 | int(buf[2])
 			tc.Height = int(buf[3])<<8 | int(buf[4])
+			if tc.onResize != nil {
+				tc.onResize(tc.Width, tc.Height)
+			}
 		}
 	case OptTType:
 		// TTYPE: option(1) + IS(1) + type string
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"net"
+	"testing"
+)
+
+// readCompressed expects an MCCP2 start at the front of r and returns what
+// the zlib stream after it decompresses to, leaving r after the stream.
+func readCompressed(t *testing.T, r *bytes.Reader) string {
+	t.Helper()
+	start := make([]byte, 5)
+	if _, err := io.ReadFull(r, start); err != nil || !bytes.Equal(start, []byte{IAC, SB, OptMCCP2, IAC, SE}) {
+		t.Fatalf("expected MCCP2 start, got %q (%v)", start, err)
+	}
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decompress: %v", err)
+	}
+	return string(out)
+}
+
+func TestTelnetMCCP2(t *testing.T) {
+	server, client := net.Pipe()
+	tc := NewTelnetConn(server)
+	tc.OfferCompression()
+
+	received := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(client)
+		received <- b
+	}()
+
+	if err := tc.Negotiate(); err != nil {
+		t.Fatal(err)
+	}
+	go client.Write([]byte{IAC, DO, OptMCCP2, 'x'})
+	if b, err := tc.ReadByte(); err != nil || b != 'x' {
+		t.Fatalf("ReadByte = %q, %v", b, err)
+	}
+	if !tc.Compressing() {
+		t.Fatal("not compressing after DO MCCP2")
+	}
+	tc.Write([]byte("hello \xff world"))
+
+	// Transfers bypass the stream and compression resumes after them.
+	raw, cleanup, _ := tc.EnterBinaryMode()
+	if tc.Compressing() {
+		t.Fatal("compressing in binary mode")
+	}
+	raw.Write([]byte("ZMODEM"))
+	cleanup()
+	tc.Write([]byte("after"))
+	tc.Close()
+
+	r := bytes.NewReader(<-received)
+	negotiation := []byte{IAC, WILL, OptEcho, IAC, WILL, OptSGA, IAC, DO, OptSGA, IAC, DONT, OptLinemod,
+		IAC, DO, OptNAWS, IAC, DO, OptTType, IAC, WILL, OptMCCP2}
+	head := make([]byte, len(negotiation))
+	io.ReadFull(r, head)
+	if !bytes.Equal(head, negotiation) {
+		t.Fatalf("negotiation = %v", head)
+	}
+	if got := readCompressed(t, r); got != "hello \xff\xff world" {
+		t.Fatalf("first stream = %q", got)
+	}
+	plain := make([]byte, 6)
+	io.ReadFull(r, plain)
+	if string(plain) != "ZMODEM" {
+		t.Fatalf("transfer bytes = %q", plain)
+	}
+	if got := readCompressed(t, r); got != "after" {
+		t.Fatalf("second stream = %q", got)
+	}
+	if r.Len() != 0 {
+		t.Fatalf("%d bytes after the last stream", r.Len())
+	}
+	if plain, wire := tc.CompressionStats(); plain != int64(len("hello \xff\xff world")+len("after")) || wire == 0 {
+		t.Fatalf("CompressionStats = %d, %d", plain, wire)
+	}
+}
+
+func TestTelnetRefusesUnofferedMCCP2(t *testing.T) {
+	server, client := net.Pipe()
+	tc := NewTelnetConn(server)
+	defer tc.Close()
+
+	reply := make(chan []byte)
+	go func() {
+		client.Write([]byte{IAC, DO, OptMCCP2, 'x'})
+		b := make([]byte, 3)
+		io.ReadFull(client, b)
+		reply <- b
+	}()
+	done := make(chan struct{})
+	go func() {
+		tc.ReadByte()
+		close(done)
+	}()
+	if b := <-reply; !bytes.Equal(b, []byte{IAC, WONT, OptMCCP2}) {
+		t.Fatalf("reply = %v", b)
+	}
+	<-done
+	if tc.Compressing() {
+		t.Fatal("compressing without offering MCCP2")
+	}
+}
@@ -0,0 +1,234 @@
+// Package session holds the identity of the caller on a node. The node, the
+// menu engine and every Lua API share one Session, so they always agree on
+// who is logged in no matter how often the menu VMs are rebuilt.
+package session
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// Session is the login state of one connection.
+type Session struct {
+	mu    sync.RWMutex
+	user  *user.User
+	stats Stats
+	busy  string
+
+	// Notices that arrived while busy, shown when the caller is back
+	notices []string
+
+	// deadline is when the per-call time limit ends; zero = unlimited
+	deadline time.Time
+
+	// shutdown is when the board goes down; zero = not shutting down
+	shutdown time.Time
+
+	// tagged holds file entry IDs queued for a batch download, in the
+	// order they were tagged
+	tagged []int
+}
+
+// Activities that must not be interrupted; see SetBusy.
+const (
+	BusyDoor     = "door"
+	BusyTransfer = "transfer"
+)
+
+// Stats counts what the caller did during the call. They are written to
+// the callers log when the session ends.
+type Stats struct {
+	ConnectedAt time.Time
+	Posts       int
+	BytesUp     int64 // file bytes uploaded by the caller
+	BytesDown   int64 // file bytes downloaded by the caller
+	LastMenu    string
+}
+
+// New creates an anonymous session starting now.
+func New() *Session {
+	return &Session{stats: Stats{ConnectedAt: time.Now()}}
+}
+
+// User returns the logged-in user, or nil before login. The returned user
+// is shared, so profile edits made through it are seen by every holder.
+// A nil Session is anonymous.
+func (s *Session) User() *user.User {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.user
+}
+
+// SetUser records the logged-in user. nil logs the session out.
+func (s *Session) SetUser(u *user.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = u
+}
+
+// LoggedIn reports whether a user is logged in.
+func (s *Session) LoggedIn() bool {
+	return s.User() != nil
+}
+
+// Level returns the logged-in user's security level, or 0 before login.
+func (s *Session) Level() int {
+	if u := s.User(); u != nil {
+		return u.SecurityLevel
+	}
+	return 0
+}
+
+// Stats returns a copy of the session statistics.
+func (s *Session) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stats
+}
+
+// AddPost counts a message posted by the caller.
+func (s *Session) AddPost() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Posts++
+}
+
+// AddTransfer counts file bytes uploaded and downloaded by the caller.
+func (s *Session) AddTransfer(up, down int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.BytesUp += up
+	s.stats.BytesDown += down
+}
+
+// SetBusy records that the caller is in a door or file transfer, or ""
+// when they are back in the menus. Notices for busy callers are held (see
+// Notify), as text would garble the door screen or corrupt the transfer.
+func (s *Session) SetBusy(activity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.busy = activity
+}
+
+// Busy returns what the caller is busy with, or "". A nil Session is not
+// busy.
+func (s *Session) Busy() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.busy
+}
+
+// SetMenu records the menu the caller is in.
+func (s *Session) SetMenu(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LastMenu = name
+}
+
+// SetDeadline records when the caller's time on the node runs out; the
+// zero time means no limit.
+func (s *Session) SetDeadline(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadline = t
+}
+
+// TimeLeft returns the caller's remaining time and true, or false when the
+// call has no time limit.
+func (s *Session) TimeLeft() (time.Duration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.deadline.IsZero() {
+		return 0, false
+	}
+	return max(time.Until(s.deadline), 0), true
+}
+
+// SetShutdown records that the board goes down at t, so scripts can tell
+// how long they have left.
+func (s *Session) SetShutdown(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdown = t
+}
+
+// ShutdownIn returns the time until the board goes down and true, or
+// false when no shutdown is under way.
+func (s *Session) ShutdownIn() (time.Duration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.shutdown.IsZero() {
+		return 0, false
+	}
+	return max(time.Until(s.shutdown), 0), true
+}
+
+// Tag queues a file for batch download. It reports false if the file was
+// already tagged.
+func (s *Session) Tag(fileID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Contains(s.tagged, fileID) {
+		return false
+	}
+	s.tagged = append(s.tagged, fileID)
+	return true
+}
+
+// Untag removes a file from the batch. It reports false if it was not
+// tagged.
+func (s *Session) Untag(fileID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.Index(s.tagged, fileID)
+	if i < 0 {
+		return false
+	}
+	s.tagged = slices.Delete(s.tagged, i, i+1)
+	return true
+}
+
+// Tagged returns the tagged file IDs in the order they were tagged.
+func (s *Session) Tagged() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.tagged)
+}
+
+// ClearTags empties the batch.
+func (s *Session) ClearTags() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tagged = nil
+}
+
+// Notify holds a notice for a busy caller and reports true, or reports
+// false when the caller is not busy and the notice can be shown now.
+func (s *Session) Notify(text string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy == "" {
+		return false
+	}
+	s.notices = append(s.notices, text)
+	return true
+}
+
+// TakeNotices returns the notices held while the caller was busy and
+// forgets them.
+func (s *Session) TakeNotices() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notices := s.notices
+	s.notices = nil
+	return notices
+}
@@ -0,0 +1,78 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+func TestSessionSharesUser(t *testing.T) {
+	s := New()
+	if s.LoggedIn() || s.Level() != 0 {
+		t.Fatal("new session should be anonymous")
+	}
+
+	u := &user.User{ID: 7, Username: "alice", SecurityLevel: 50}
+	s.SetUser(u)
+	s.User().Location = "Oslo"
+	if u.Location != "Oslo" || s.Level() != 50 {
+		t.Fatalf("user = %+v, level %d", s.User(), s.Level())
+	}
+
+	s.SetUser(nil)
+	if s.LoggedIn() {
+		t.Fatal("SetUser(nil) should log out")
+	}
+}
+
+func TestSessionStats(t *testing.T) {
+	s := New()
+	s.AddPost()
+	s.AddPost()
+	s.AddTransfer(100, 0)
+	s.AddTransfer(0, 2048)
+	s.SetMenu("file_menu")
+
+	st := s.Stats()
+	if st.Posts != 2 || st.BytesUp != 100 || st.BytesDown != 2048 || st.LastMenu != "file_menu" {
+		t.Fatalf("stats = %+v", st)
+	}
+	if st.ConnectedAt.IsZero() {
+		t.Fatal("ConnectedAt not set")
+	}
+}
+
+func TestSessionTags(t *testing.T) {
+	s := New()
+	if !s.Tag(3) || !s.Tag(1) || s.Tag(3) {
+		t.Fatal("Tag did not report new tags")
+	}
+	s.Tag(7)
+	if !s.Untag(1) || s.Untag(1) {
+		t.Fatal("Untag did not report removed tags")
+	}
+	if got := s.Tagged(); len(got) != 2 || got[0] != 3 || got[1] != 7 {
+		t.Fatalf("tagged = %v", got)
+	}
+	s.ClearTags()
+	if len(s.Tagged()) != 0 {
+		t.Fatal("ClearTags left tags")
+	}
+}
+
+func TestSessionHoldsNoticesWhileBusy(t *testing.T) {
+	s := New()
+	if s.Notify("before") {
+		t.Fatal("Notify held a notice for an idle caller")
+	}
+	s.SetBusy(BusyDoor)
+	s.Notify("one")
+	s.Notify("two")
+	s.SetBusy("")
+	if got := s.TakeNotices(); len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Fatalf("notices = %v", got)
+	}
+	if len(s.TakeNotices()) != 0 {
+		t.Fatal("TakeNotices did not forget the notices")
+	}
+}
@@ -0,0 +1,354 @@
+package sftp
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/scripting"
+	"github.com/notepid/twilight_bbs/internal/transfer"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// maxListing caps the entries listed per area directory.
+const maxListing = 10000
+
+// AreaOptions controls what an AreaFS allows.
+type AreaOptions struct {
+	Uploads    bool   // allow uploads into areas the user may upload to
+	StagingDir string // uploads are written here and moved into the area when closed
+
+	// Ratio is checked before each download, as it is on the BBS
+	Ratio filearea.Ratio
+	// Pending returns the bytes of the user's web download links not yet
+	// used, which the ratio counts as downloaded; nil when there are none.
+	Pending func(userID int) int64
+}
+
+// AreaFS presents the file areas a user may download from as one
+// directory per area, holding the cataloged files of that area. Area
+// download and upload levels apply as they do on the BBS.
+type AreaFS struct {
+	files *filearea.Repo
+	user  *user.User
+	opts  AreaOptions
+
+	mu        sync.Mutex
+	bytesUp   int64
+	bytesDown int64
+	filesUp   int
+	filesDown int
+}
+
+// NewAreaFS creates the file area tree for u.
+func NewAreaFS(files *filearea.Repo, u *user.User, opts AreaOptions) *AreaFS {
+	return &AreaFS{files: files, user: u, opts: opts}
+}
+
+// Transferred returns the bytes uploaded and downloaded so far.
+func (a *AreaFS) Transferred() (up, down int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.bytesUp, a.bytesDown
+}
+
+// Files returns the number of files uploaded and downloaded so far.
+func (a *AreaFS) Files() (up, down int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.filesUp, a.filesDown
+}
+
+// addTransfer records one finished upload or download.
+func (a *AreaFS) addTransfer(up, down int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.bytesUp += up
+	a.bytesDown += down
+	if up > 0 {
+		a.filesUp++
+	}
+	if down > 0 {
+		a.filesDown++
+	}
+}
+
+// dirName is the directory name shown for an area.
+func dirName(area *filearea.Area) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < 32 || r == 127 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(area.Name))
+	if name == "" || name == "." || name == ".." {
+		name = fmt.Sprintf("area%d", area.ID)
+	}
+	return name
+}
+
+// areas returns the user's areas keyed by directory name. Areas whose
+// names collide get their ID appended.
+func (a *AreaFS) areas() ([]*filearea.Area, map[string]*filearea.Area, error) {
+	list, err := a.files.ListAreas(a.user.SecurityLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	byName := make(map[string]*filearea.Area, len(list))
+	for _, area := range list {
+		name := dirName(area)
+		if _, dup := byName[name]; dup {
+			name = fmt.Sprintf("%s-%d", name, area.ID)
+		}
+		byName[name] = area
+	}
+	return list, byName, nil
+}
+
+// resolve splits p into its area and file name. file is empty for the
+// area directory itself; area is nil for the root.
+func (a *AreaFS) resolve(p string) (area *filearea.Area, dir, file string, err error) {
+	if p == "/" {
+		return nil, "", "", nil
+	}
+	parts := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	if len(parts) > 2 {
+		return nil, "", "", fs.ErrNotExist
+	}
+	_, byName, err := a.areas()
+	if err != nil {
+		return nil, "", "", err
+	}
+	area = byName[parts[0]]
+	if area == nil {
+		return nil, "", "", fs.ErrNotExist
+	}
+	if len(parts) == 2 {
+		file = parts[1]
+	}
+	return area, parts[0], file, nil
+}
+
+func (a *AreaFS) canUpload(area *filearea.Area) bool {
+	return a.opts.Uploads && a.user.SecurityLevel >= area.UploadLevel
+}
+
+// entryInfo describes a cataloged file, preferring the size on disk.
+func entryInfo(area *filearea.Area, e *filearea.Entry) *FileInfo {
+	fi := &FileInfo{Name: e.Filename, Size: e.SizeBytes, ModTime: e.UploadedAt}
+	if st, err := os.Stat(filepath.Join(area.DiskPath, e.Filename)); err == nil {
+		fi.Size = st.Size()
+	}
+	return fi
+}
+
+// Stat implements FileSystem.
+func (a *AreaFS) Stat(p string) (*FileInfo, error) {
+	area, dir, file, err := a.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case area == nil:
+		return &FileInfo{Name: "/", Dir: true}, nil
+	case file == "":
+		return &FileInfo{Name: dir, Dir: true, Writable: a.canUpload(area)}, nil
+	}
+	e, err := a.files.GetFileByName(area.ID, file)
+	if err != nil {
+		return nil, fs.ErrNotExist
+	}
+	return entryInfo(area, e), nil
+}
+
+// ReadDir implements FileSystem.
+func (a *AreaFS) ReadDir(p string) ([]*FileInfo, error) {
+	area, _, file, err := a.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if file != "" {
+		return nil, fmt.Errorf("%s is not a directory", p)
+	}
+
+	if area == nil {
+		list, byName, err := a.areas()
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[int]string, len(byName))
+		for name, area := range byName {
+			names[area.ID] = name
+		}
+		out := make([]*FileInfo, 0, len(list))
+		for _, area := range list {
+			out = append(out, &FileInfo{Name: names[area.ID], Dir: true, Writable: a.canUpload(area)})
+		}
+		return out, nil
+	}
+
+	entries, err := a.files.ListFiles(area.ID, 0, maxListing)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*FileInfo, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, entryInfo(area, e))
+	}
+	return out, nil
+}
+
+// checkRatio returns an error when downloading extra more bytes would take
+// the user past the ratio.
+func (a *AreaFS) checkRatio(extra int64) error {
+	up, down := a.Transferred()
+	down += a.user.BytesDownloaded
+	if a.opts.Pending != nil {
+		down += a.opts.Pending(a.user.ID)
+	}
+	return a.opts.Ratio.Check(a.user.SecurityLevel, a.user.BytesUploaded+up, down, extra)
+}
+
+// Open implements FileSystem. The download ratio is checked first, with
+// this session's transfers; the download is counted once the file has been
+// read from.
+func (a *AreaFS) Open(p string) (ReadFile, error) {
+	area, _, file, err := a.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if file == "" {
+		return nil, fmt.Errorf("%s is a directory", p)
+	}
+	e, err := a.files.GetFileByName(area.ID, file)
+	if err != nil {
+		return nil, fs.ErrNotExist
+	}
+	f, err := os.Open(filepath.Join(area.DiskPath, e.Filename))
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := a.checkRatio(st.Size()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &download{File: f, fs: a, entry: e, size: st.Size()}, nil
+}
+
+// Create implements FileSystem. The upload is written to the staging
+// directory and cataloged in the area when closed. Existing files are
+// never replaced.
+func (a *AreaFS) Create(p string) (WriteFile, error) {
+	area, _, file, err := a.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if file == "" || !a.canUpload(area) {
+		return nil, fs.ErrPermission
+	}
+	v := &scripting.ValidateInput{}
+	if err := v.ValidateFilename(file); err != nil {
+		return nil, err
+	}
+	dst := filepath.Join(area.DiskPath, file)
+	if _, err := a.files.GetFileByName(area.ID, file); err == nil {
+		return nil, fmt.Errorf("%s already exists", file)
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return nil, fmt.Errorf("%s already exists", file)
+	}
+
+	dir := a.opts.StagingDir
+	if dir == "" {
+		dir = area.DiskPath
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, "upload-sftp-")
+	if err != nil {
+		return nil, err
+	}
+	return &upload{File: f, fs: a, area: area, name: file, dst: dst}, nil
+}
+
+// download counts bytes read and bumps the download count on close.
+type download struct {
+	*os.File
+	fs    *AreaFS
+	entry *filearea.Entry
+	size  int64
+	read  int64
+}
+
+func (d *download) Size() int64 { return d.size }
+
+func (d *download) ReadAt(p []byte, off int64) (int, error) {
+	n, err := d.File.ReadAt(p, off)
+	d.read += int64(n)
+	return n, err
+}
+
+func (d *download) Close() error {
+	err := d.File.Close()
+	if d.read > 0 {
+		d.fs.addTransfer(0, d.read)
+		if err := d.fs.files.IncrementDownload(d.entry.ID); err != nil {
+			log.Printf("SFTP: count download of %s: %v", d.entry.Filename, err)
+		}
+	}
+	return err
+}
+
+// upload moves the staged file into the area and catalogs it on close.
+type upload struct {
+	*os.File
+	fs   *AreaFS
+	area *filearea.Area
+	name string
+	dst  string
+}
+
+func (u *upload) Close() error {
+	staged := u.File.Name()
+	defer os.Remove(staged)
+
+	st, err := u.File.Stat()
+	if cerr := u.File.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(u.area.DiskPath, 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(u.dst); err == nil {
+		return fmt.Errorf("%s already exists", u.name)
+	}
+	if err := transfer.MoveFile(staged, u.dst); err != nil {
+		return err
+	}
+	if _, err := u.fs.files.AddEntry(u.area.ID, u.name, "Uploaded via SFTP", st.Size(), u.fs.user.ID); err != nil {
+		return err
+	}
+	u.fs.addTransfer(st.Size(), 0)
+	log.Printf("SFTP: %s uploaded %s to %s (%d bytes)", u.fs.user.Username, u.name, u.area.Name, st.Size())
+	return nil
+}
+
+// Abort discards an incomplete upload.
+func (u *upload) Abort() {
+	u.File.Close()
+	os.Remove(u.File.Name())
+}
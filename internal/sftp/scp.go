@@ -0,0 +1,312 @@
+package sftp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxSCPLine bounds a protocol line from the client.
+const maxSCPLine = 1024
+
+// aborter is implemented by WriteFiles that can discard an incomplete
+// upload instead of committing it.
+type aborter interface {
+	Abort()
+}
+
+// scp is the state of one legacy scp transfer.
+type scp struct {
+	r         *bufio.Reader
+	w         io.Writer
+	fs        FileSystem
+	recursive bool
+	failed    bool
+}
+
+// ServeSCP runs the server side of a legacy scp (rcp protocol) transfer on
+// rw for command, the exec line the client sent, e.g. "scp -f /area/file"
+// to download or "scp -t /area" to upload. Directories are sent with -r but
+// cannot be uploaded. It returns an error if any file failed.
+func ServeSCP(command string, rw io.ReadWriter, fsys FileSystem) error {
+	args := splitCommand(command)
+	if len(args) == 0 || args[0] != "scp" {
+		return errors.New("not an scp command")
+	}
+	s := &scp{r: bufio.NewReader(rw), w: rw, fs: fsys}
+	var from, to bool
+	var paths []string
+	for _, a := range args[1:] {
+		if !strings.HasPrefix(a, "-") || len(paths) > 0 {
+			paths = append(paths, a)
+			continue
+		}
+		for _, f := range a[1:] {
+			switch f {
+			case 'f':
+				from = true
+			case 't':
+				to = true
+			case 'r':
+				s.recursive = true
+			}
+		}
+	}
+	switch {
+	case from == to || len(paths) == 0:
+		return errors.New("usage: scp -f|-t [-r] path")
+	case from:
+		if err := s.source(paths); err != nil {
+			return err
+		}
+	default:
+		if len(paths) != 1 {
+			return errors.New("scp -t takes one target")
+		}
+		if err := s.sink(Clean(paths[0])); err != nil {
+			return err
+		}
+	}
+	if s.failed {
+		return errors.New("some files were not transferred")
+	}
+	return nil
+}
+
+// warn reports a per-file problem to the client, which prints it and
+// carries on.
+func (s *scp) warn(err error) {
+	s.failed = true
+	fmt.Fprintf(s.w, "\x01scp: %s\n", strings.ReplaceAll(err.Error(), "\n", " "))
+}
+
+// ack waits for the client's reply to the last line or file.
+func (s *scp) ack() error {
+	b, err := s.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == 0 {
+		return nil
+	}
+	msg, _ := s.r.ReadString('\n')
+	return fmt.Errorf("client: %s", strings.TrimSpace(msg))
+}
+
+func (s *scp) source(paths []string) error {
+	if err := s.ack(); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		p = Clean(p)
+		fi, err := s.fs.Stat(p)
+		if err != nil {
+			s.warn(fmt.Errorf("%s: %w", p, err))
+			continue
+		}
+		if err := s.send(p, fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send transmits a file, or a directory tree with -r.
+func (s *scp) send(p string, fi *FileInfo) error {
+	name := path.Base(p)
+	if fi.Dir {
+		if !s.recursive {
+			s.warn(fmt.Errorf("%s: not a regular file", p))
+			return nil
+		}
+		entries, err := s.fs.ReadDir(p)
+		if err != nil {
+			s.warn(fmt.Errorf("%s: %w", p, err))
+			return nil
+		}
+		fmt.Fprintf(s.w, "D0755 0 %s\n", name)
+		if err := s.ack(); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := s.send(path.Join(p, e.Name), e); err != nil {
+				return err
+			}
+		}
+		io.WriteString(s.w, "E\n")
+		return s.ack()
+	}
+
+	f, err := s.fs.Open(p)
+	if err != nil {
+		s.warn(fmt.Errorf("%s: %w", p, err))
+		return nil
+	}
+	defer f.Close()
+	fmt.Fprintf(s.w, "C0644 %d %s\n", f.Size(), name)
+	if err := s.ack(); err != nil {
+		return err
+	}
+	if _, err := io.Copy(s.w, io.NewSectionReader(f, 0, f.Size())); err != nil {
+		return err
+	}
+	if _, err := s.w.Write([]byte{0}); err != nil {
+		return err
+	}
+	return s.ack()
+}
+
+func (s *scp) sink(target string) error {
+	into := false
+	if fi, err := s.fs.Stat(target); err == nil && fi.Dir {
+		into = true
+	}
+	s.w.Write([]byte{0})
+
+	for {
+		line, err := s.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch line[0] {
+		case 'T':
+			s.w.Write([]byte{0}) // times are not kept
+		case 'C':
+			size, name, err := parseFileLine(line)
+			if err != nil {
+				return err
+			}
+			dst := target
+			if into {
+				dst = path.Join(target, name)
+			}
+			if err := s.receive(dst, size); err != nil {
+				return err
+			}
+		case 'D', 'E':
+			fmt.Fprintf(s.w, "\x02scp: directory uploads are not supported\n")
+			return errors.New("directory upload refused")
+		case 1, 2:
+			return fmt.Errorf("client: %s", strings.TrimSpace(line[1:]))
+		default:
+			return fmt.Errorf("unexpected scp line %q", line)
+		}
+	}
+}
+
+// receive stores one uploaded file of size bytes at dst.
+func (s *scp) receive(dst string, size int64) error {
+	f, err := s.fs.Create(dst)
+	if err != nil {
+		s.warn(fmt.Errorf("%s: %w", dst, err))
+		return nil // the client skips the data after a refusal
+	}
+	s.w.Write([]byte{0})
+
+	if _, err := io.Copy(io.NewOffsetWriter(f, 0), io.LimitReader(s.r, size)); err != nil {
+		abort(f)
+		return err
+	}
+	if err := s.ack(); err != nil {
+		abort(f)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		s.warn(fmt.Errorf("%s: %w", dst, err))
+		return nil
+	}
+	s.w.Write([]byte{0})
+	return nil
+}
+
+func abort(f WriteFile) {
+	if a, ok := f.(aborter); ok {
+		a.Abort()
+		return
+	}
+	f.Close()
+}
+
+func (s *scp) readLine() (string, error) {
+	var b strings.Builder
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && b.Len() > 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if c == '\n' {
+			if b.Len() == 0 {
+				return "", errors.New("empty scp line")
+			}
+			return b.String(), nil
+		}
+		if b.Len() >= maxSCPLine {
+			return "", errors.New("scp line too long")
+		}
+		b.WriteByte(c)
+	}
+}
+
+// parseFileLine parses "C<mode> <size> <name>".
+func parseFileLine(line string) (int64, string, error) {
+	fields := strings.SplitN(line[1:], " ", 3)
+	if len(fields) != 3 {
+		return 0, "", fmt.Errorf("bad scp file line %q", line)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	name := fields[2]
+	if err != nil || size < 0 || name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return 0, "", fmt.Errorf("bad scp file line %q", line)
+	}
+	return size, name, nil
+}
+
+// splitCommand splits an exec line into words, honouring the single and
+// double quotes and backslashes clients use to escape remote paths.
+func splitCommand(s string) []string {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words
+}
@@ -0,0 +1,425 @@
+// Package sftp serves the SSH "sftp" subsystem (protocol version 3) over a
+// small virtual filesystem, so users can browse and fetch files with
+// standard clients:
+//
+//	sftp -P 2222 alice@bbs.example.org
+//
+// Only the requests needed to list, download and upload files are
+// implemented; the rest are answered with SSH_FX_OP_UNSUPPORTED.
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"time"
+)
+
+// Packet types.
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpWrite    = 6
+	fxpLstat    = 7
+	fxpFstat    = 8
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRealpath = 16
+	fxpStat     = 17
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+)
+
+// Status codes.
+const (
+	fxOK               = 0
+	fxEOF              = 1
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+	fxFailure          = 4
+	fxBadMessage       = 5
+	fxOpUnsupported    = 8
+)
+
+// Open flags.
+const (
+	fxfRead  = 0x01
+	fxfWrite = 0x02
+)
+
+// Attribute flags.
+const (
+	attrSize        = 0x01
+	attrPermissions = 0x04
+	attrModTime     = 0x08
+)
+
+const (
+	protocolVersion = 3
+	maxPacket       = 256 * 1024 // larger than any client sends
+	maxRead         = 32 * 1024  // per READ reply
+	maxHandles      = 64
+	dirBatch        = 100 // entries per READDIR reply
+)
+
+// FileInfo describes a file or directory.
+type FileInfo struct {
+	Name     string
+	Size     int64
+	ModTime  time.Time
+	Dir      bool
+	Writable bool // directory accepts uploads, or file may be written
+}
+
+// ReadFile is a file opened for download.
+type ReadFile interface {
+	io.ReaderAt
+	io.Closer
+	Size() int64
+}
+
+// WriteFile is a file opened for upload. Close commits the upload.
+type WriteFile interface {
+	io.WriterAt
+	io.Closer
+}
+
+// FileSystem is the tree served to a client. Paths are absolute and clean.
+// Errors wrapping fs.ErrNotExist or fs.ErrPermission are reported to the
+// client as such; anything else is a generic failure.
+type FileSystem interface {
+	Stat(path string) (*FileInfo, error)
+	ReadDir(path string) ([]*FileInfo, error)
+	Open(path string) (ReadFile, error)
+	Create(path string) (WriteFile, error)
+}
+
+// handle is an open file or directory listing.
+type handle struct {
+	r       ReadFile
+	w       WriteFile
+	entries []*FileInfo // remaining directory entries
+	dir     bool
+}
+
+// server is the state of one subsystem channel.
+type server struct {
+	rw      io.ReadWriter
+	fs      FileSystem
+	handles map[string]*handle
+	next    int
+}
+
+// Serve runs the SFTP protocol on rw until the client disconnects. Open
+// files are closed before it returns.
+func Serve(rw io.ReadWriter, fsys FileSystem) error {
+	s := &server{rw: rw, fs: fsys, handles: make(map[string]*handle)}
+	defer s.closeAll()
+
+	for {
+		typ, data, err := s.readPacket()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := s.dispatch(typ, &reader{b: data}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) readPacket() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(s.rw, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n < 1 || n > maxPacket {
+		return 0, nil, fmt.Errorf("sftp: bad packet length %d", n)
+	}
+	data := make([]byte, n-1)
+	if _, err := io.ReadFull(s.rw, data); err != nil {
+		return 0, nil, err
+	}
+	return hdr[4], data, nil
+}
+
+func (s *server) send(typ byte, w *writer) error {
+	pkt := make([]byte, 5, 5+len(w.b))
+	binary.BigEndian.PutUint32(pkt, uint32(1+len(w.b)))
+	pkt[4] = typ
+	_, err := s.rw.Write(append(pkt, w.b...))
+	return err
+}
+
+func (s *server) dispatch(typ byte, r *reader) error {
+	if typ == fxpInit {
+		w := &writer{}
+		w.uint32(protocolVersion)
+		return s.send(fxpVersion, w)
+	}
+
+	id := r.uint32()
+	if r.err != nil {
+		return fmt.Errorf("sftp: short packet type %d", typ)
+	}
+	switch typ {
+	case fxpRealpath:
+		return s.realpath(id, r)
+	case fxpStat, fxpLstat:
+		return s.stat(id, r)
+	case fxpFstat:
+		return s.fstat(id, r)
+	case fxpOpendir:
+		return s.opendir(id, r)
+	case fxpReaddir:
+		return s.readdir(id, r)
+	case fxpOpen:
+		return s.open(id, r)
+	case fxpRead:
+		return s.read(id, r)
+	case fxpWrite:
+		return s.write(id, r)
+	case fxpClose:
+		return s.close(id, r)
+	default:
+		return s.status(id, fxOpUnsupported, "operation not supported")
+	}
+}
+
+func (s *server) status(id uint32, code uint32, msg string) error {
+	w := &writer{}
+	w.uint32(id)
+	w.uint32(code)
+	w.string(msg)
+	w.string("en")
+	return s.send(fxpStatus, w)
+}
+
+// statusErr reports err to the client.
+func (s *server) statusErr(id uint32, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return s.status(id, fxNoSuchFile, "no such file")
+	case errors.Is(err, fs.ErrPermission):
+		return s.status(id, fxPermissionDenied, "permission denied")
+	default:
+		return s.status(id, fxFailure, err.Error())
+	}
+}
+
+func (s *server) badMessage(id uint32) error {
+	return s.status(id, fxBadMessage, "malformed request")
+}
+
+func (s *server) realpath(id uint32, r *reader) error {
+	p := r.string()
+	if r.err != nil {
+		return s.badMessage(id)
+	}
+	p = Clean(p)
+	w := &writer{}
+	w.uint32(id)
+	w.uint32(1)
+	w.string(p)
+	w.string(p)
+	w.uint32(0) // no attributes
+	return s.send(fxpName, w)
+}
+
+func (s *server) stat(id uint32, r *reader) error {
+	p := r.string()
+	if r.err != nil {
+		return s.badMessage(id)
+	}
+	fi, err := s.fs.Stat(Clean(p))
+	if err != nil {
+		return s.statusErr(id, err)
+	}
+	w := &writer{}
+	w.uint32(id)
+	w.attrs(fi)
+	return s.send(fxpAttrs, w)
+}
+
+func (s *server) fstat(id uint32, r *reader) error {
+	h := s.handles[r.string()]
+	if r.err != nil || h == nil || h.dir {
+		return s.status(id, fxFailure, "invalid handle")
+	}
+	fi := &FileInfo{}
+	if h.r != nil {
+		fi.Size = h.r.Size()
+	}
+	w := &writer{}
+	w.uint32(id)
+	w.attrs(fi)
+	return s.send(fxpAttrs, w)
+}
+
+func (s *server) opendir(id uint32, r *reader) error {
+	p := r.string()
+	if r.err != nil {
+		return s.badMessage(id)
+	}
+	entries, err := s.fs.ReadDir(Clean(p))
+	if err != nil {
+		return s.statusErr(id, err)
+	}
+	return s.newHandle(id, &handle{dir: true, entries: entries})
+}
+
+func (s *server) readdir(id uint32, r *reader) error {
+	h := s.handles[r.string()]
+	if r.err != nil || h == nil || !h.dir {
+		return s.status(id, fxFailure, "invalid handle")
+	}
+	if len(h.entries) == 0 {
+		return s.status(id, fxEOF, "end of directory")
+	}
+	batch := h.entries
+	if len(batch) > dirBatch {
+		batch = batch[:dirBatch]
+	}
+	h.entries = h.entries[len(batch):]
+
+	w := &writer{}
+	w.uint32(id)
+	w.uint32(uint32(len(batch)))
+	for _, fi := range batch {
+		w.string(fi.Name)
+		w.string(longName(fi))
+		w.attrs(fi)
+	}
+	return s.send(fxpName, w)
+}
+
+func (s *server) open(id uint32, r *reader) error {
+	p := r.string()
+	pflags := r.uint32()
+	if r.err != nil {
+		return s.badMessage(id)
+	}
+	p = Clean(p)
+
+	switch {
+	case pflags&fxfRead != 0 && pflags&fxfWrite != 0:
+		return s.status(id, fxOpUnsupported, "files may not be opened for read and write")
+	case pflags&fxfWrite != 0:
+		f, err := s.fs.Create(p)
+		if err != nil {
+			return s.statusErr(id, err)
+		}
+		return s.newHandle(id, &handle{w: f})
+	default:
+		f, err := s.fs.Open(p)
+		if err != nil {
+			return s.statusErr(id, err)
+		}
+		return s.newHandle(id, &handle{r: f})
+	}
+}
+
+func (s *server) read(id uint32, r *reader) error {
+	h := s.handles[r.string()]
+	offset := r.uint64()
+	length := r.uint32()
+	if r.err != nil || h == nil || h.r == nil {
+		return s.status(id, fxFailure, "invalid handle")
+	}
+	if length > maxRead {
+		length = maxRead
+	}
+	buf := make([]byte, length)
+	n, err := h.r.ReadAt(buf, int64(offset))
+	if n == 0 {
+		if err == nil || errors.Is(err, io.EOF) {
+			return s.status(id, fxEOF, "end of file")
+		}
+		return s.statusErr(id, err)
+	}
+	w := &writer{}
+	w.uint32(id)
+	w.bytes(buf[:n])
+	return s.send(fxpData, w)
+}
+
+func (s *server) write(id uint32, r *reader) error {
+	h := s.handles[r.string()]
+	offset := r.uint64()
+	data := r.bytes()
+	if r.err != nil || h == nil || h.w == nil {
+		return s.status(id, fxFailure, "invalid handle")
+	}
+	if _, err := h.w.WriteAt(data, int64(offset)); err != nil {
+		return s.statusErr(id, err)
+	}
+	return s.status(id, fxOK, "")
+}
+
+func (s *server) close(id uint32, r *reader) error {
+	key := r.string()
+	h := s.handles[key]
+	if r.err != nil || h == nil {
+		return s.status(id, fxFailure, "invalid handle")
+	}
+	delete(s.handles, key)
+	if err := h.close(); err != nil {
+		return s.statusErr(id, err)
+	}
+	return s.status(id, fxOK, "")
+}
+
+func (s *server) newHandle(id uint32, h *handle) error {
+	if len(s.handles) >= maxHandles {
+		h.close()
+		return s.status(id, fxFailure, "too many open handles")
+	}
+	s.next++
+	key := strconv.Itoa(s.next)
+	s.handles[key] = h
+	w := &writer{}
+	w.uint32(id)
+	w.string(key)
+	return s.send(fxpHandle, w)
+}
+
+func (s *server) closeAll() {
+	for key, h := range s.handles {
+		h.close()
+		delete(s.handles, key)
+	}
+}
+
+func (h *handle) close() error {
+	switch {
+	case h.r != nil:
+		return h.r.Close()
+	case h.w != nil:
+		return h.w.Close()
+	}
+	return nil
+}
+
+// longName formats an "ls -l" style line; clients show it as-is.
+func longName(fi *FileInfo) string {
+	mode := "-r--r--r--"
+	if fi.Dir {
+		mode = "dr-xr-xr-x"
+		if fi.Writable {
+			mode = "drwxr-xr-x"
+		}
+	}
+	return fmt.Sprintf("%s 1 bbs bbs %12d %s %s", mode, fi.Size, fi.ModTime.Format("Jan _2  2006"), fi.Name)
+}
@@ -0,0 +1,268 @@
+package sftp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/filearea"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// client is a bare-bones SFTP client for driving the server.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	id   uint32
+}
+
+func (c *client) call(typ byte, build func(w *writer)) (byte, *reader) {
+	c.t.Helper()
+	c.id++
+	w := &writer{}
+	if typ != fxpInit {
+		w.uint32(c.id)
+	}
+	build(w)
+	pkt := binary.BigEndian.AppendUint32(nil, uint32(1+len(w.b)))
+	pkt = append(append(pkt, typ), w.b...)
+	if _, err := c.conn.Write(pkt); err != nil {
+		c.t.Fatal(err)
+	}
+
+	var hdr [5]byte
+	if _, err := io.ReadFull(c.conn, hdr[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	body := make([]byte, binary.BigEndian.Uint32(hdr[:4])-1)
+	if _, err := io.ReadFull(c.conn, body); err != nil {
+		c.t.Fatal(err)
+	}
+	r := &reader{b: body}
+	if hdr[4] != fxpVersion && r.uint32() != c.id {
+		c.t.Fatalf("reply id mismatch")
+	}
+	return hdr[4], r
+}
+
+func (c *client) status(typ byte, r *reader) uint32 {
+	if typ != fxpStatus {
+		return fxOK
+	}
+	return r.uint32()
+}
+
+func (c *client) handle(typ byte, r *reader) string {
+	c.t.Helper()
+	if typ != fxpHandle {
+		c.t.Fatalf("want handle, got type %d status %d", typ, c.status(typ, r))
+	}
+	return r.string()
+}
+
+// areaFS returns alice's view of a test database with one readable area
+// holding readme.txt and one sysop-only area.
+func areaFS(t *testing.T, opts AreaOptions) (*AreaFS, *filearea.Repo, string) {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("hello sftp"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		`INSERT INTO users (id, username, password_hash, security_level) VALUES (1, 'alice', 'x', 20)`,
+		`UPDATE file_areas SET disk_path = '` + dir + `', upload_level = 20 WHERE id = 1`,
+		`INSERT INTO file_areas (id, name, disk_path, download_level) VALUES (2, 'Sysop Only', '` + dir + `', 100)`,
+		`INSERT INTO file_entries (area_id, filename, size_bytes, uploader_id) VALUES (1, 'readme.txt', 10, 1)`,
+	} {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := filearea.NewRepo(database.DB)
+	u, err := user.NewRepo(database.DB).GetByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAreaFS(files, u, opts), files, dir
+}
+
+func setup(t *testing.T, opts AreaOptions) (*client, *filearea.Repo, string) {
+	t.Helper()
+	fsys, files, dir := areaFS(t, opts)
+	srv, cli := net.Pipe()
+	go func() {
+		Serve(srv, fsys)
+		srv.Close()
+	}()
+	t.Cleanup(func() { cli.Close() })
+
+	c := &client{t: t, conn: cli}
+	if typ, _ := c.call(fxpInit, func(w *writer) { w.uint32(3) }); typ != fxpVersion {
+		t.Fatalf("init reply type %d", typ)
+	}
+	return c, files, dir
+}
+
+func TestListAndDownload(t *testing.T) {
+	c, files, _ := setup(t, AreaOptions{})
+
+	h := c.handle(c.call(fxpOpendir, func(w *writer) { w.string("/") }))
+	typ, r := c.call(fxpReaddir, func(w *writer) { w.string(h) })
+	if typ != fxpName || r.uint32() != 1 || r.string() != "General Files" {
+		t.Fatalf("root listing wrong (type %d)", typ)
+	}
+	if typ, r := c.call(fxpReaddir, func(w *writer) { w.string(h) }); c.status(typ, r) != fxEOF {
+		t.Fatal("want EOF after listing")
+	}
+
+	// Areas above the user's level do not exist.
+	if typ, r := c.call(fxpStat, func(w *writer) { w.string("/Sysop Only") }); c.status(typ, r) != fxNoSuchFile {
+		t.Fatal("hidden area is visible")
+	}
+
+	h = c.handle(c.call(fxpOpen, func(w *writer) {
+		w.string("General Files/readme.txt")
+		w.uint32(fxfRead)
+		w.uint32(0)
+	}))
+	typ, r = c.call(fxpRead, func(w *writer) {
+		w.string(h)
+		w.uint64(0)
+		w.uint32(1024)
+	})
+	if typ != fxpData || string(r.bytes()) != "hello sftp" {
+		t.Fatalf("read type %d", typ)
+	}
+	if typ, r := c.call(fxpClose, func(w *writer) { w.string(h) }); c.status(typ, r) != fxOK {
+		t.Fatal("close failed")
+	}
+
+	e, err := files.GetFileByName(1, "readme.txt")
+	if err != nil || e.DownloadCount != 1 {
+		t.Fatalf("download count = %v, %v", e, err)
+	}
+
+	// Uploads are off.
+	typ, r = c.call(fxpOpen, func(w *writer) {
+		w.string("/General Files/new.txt")
+		w.uint32(fxfWrite | 0x08)
+		w.uint32(0)
+	})
+	if c.status(typ, r) != fxPermissionDenied {
+		t.Fatal("upload allowed with uploads disabled")
+	}
+}
+
+func TestDownloadRatio(t *testing.T) {
+	var pending int64
+	fsys, _, _ := areaFS(t, AreaOptions{
+		Ratio:   filearea.Ratio{PerUpload: 2, FreeKB: 1},
+		Pending: func(int) int64 { return pending },
+	})
+
+	f, err := fsys.Open("/General Files/readme.txt")
+	if err != nil {
+		t.Fatalf("download within the free allowance refused: %v", err)
+	}
+	f.Close()
+
+	// Web links not yet used count against the ratio.
+	pending = 1020
+	if _, err := fsys.Open("/General Files/readme.txt"); err == nil {
+		t.Fatal("download past the ratio allowed")
+	}
+}
+
+func TestUpload(t *testing.T) {
+	c, files, dir := setup(t, AreaOptions{Uploads: true, StagingDir: t.TempDir()})
+
+	open := func(name string) (byte, *reader) {
+		return c.call(fxpOpen, func(w *writer) {
+			w.string(name)
+			w.uint32(fxfWrite | 0x08)
+			w.uint32(0)
+		})
+	}
+	h := c.handle(open("/General Files/new.txt"))
+	if typ, r := c.call(fxpWrite, func(w *writer) {
+		w.string(h)
+		w.uint64(0)
+		w.string("uploaded")
+	}); c.status(typ, r) != fxOK {
+		t.Fatal("write failed")
+	}
+	if typ, r := c.call(fxpClose, func(w *writer) { w.string(h) }); c.status(typ, r) != fxOK {
+		t.Fatal("close failed")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "new.txt"))
+	if err != nil || string(data) != "uploaded" {
+		t.Fatalf("uploaded file = %q, %v", data, err)
+	}
+	if e, err := files.GetFileByName(1, "new.txt"); err != nil || e.SizeBytes != 8 || e.UploaderID != 1 {
+		t.Fatalf("entry = %v, %v", e, err)
+	}
+
+	// Existing files are never replaced.
+	if typ, r := open("/General Files/readme.txt"); c.status(typ, r) != fxFailure {
+		t.Fatal("overwrite allowed")
+	}
+}
+
+func TestSCP(t *testing.T) {
+	fsys, files, dir := areaFS(t, AreaOptions{Uploads: true, StagingDir: t.TempDir()})
+
+	// Download: the client acks each step with a zero byte.
+	var out bytes.Buffer
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader("\x00\x00\x00"), &out}
+	if err := ServeSCP(`scp -f '/General Files/readme.txt'`, rw, fsys); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "C0644 10 readme.txt\nhello sftp\x00" {
+		t.Fatalf("download stream = %q", out.String())
+	}
+
+	// Upload into the area directory.
+	out.Reset()
+	rw.Reader = strings.NewReader("C0644 3 up.txt\nabc\x00")
+	if err := ServeSCP(`scp -t "/General Files"`, rw, fsys); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "\x00\x00\x00" {
+		t.Fatalf("upload replies = %q", out.String())
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "up.txt")); err != nil || string(data) != "abc" {
+		t.Fatalf("uploaded file = %q, %v", data, err)
+	}
+	if _, err := files.GetFileByName(1, "up.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hidden areas and existing files are refused with a warning.
+	out.Reset()
+	rw.Reader = strings.NewReader("\x00")
+	if err := ServeSCP("scp -f /Sysop\\ Only/readme.txt", rw, fsys); err == nil || !strings.HasPrefix(out.String(), "\x01") {
+		t.Fatalf("hidden download: %v, %q", err, out.String())
+	}
+	out.Reset()
+	rw.Reader = strings.NewReader("C0644 3 readme.txt\n")
+	if err := ServeSCP("scp -t /General\\ Files", rw, fsys); err == nil || !strings.Contains(out.String(), "\x01") {
+		t.Fatalf("overwrite: %v, %q", err, out.String())
+	}
+}
@@ -0,0 +1,111 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"path"
+	"strings"
+)
+
+var errShort = errors.New("sftp: short packet")
+
+// File type bits of the permissions attribute.
+const (
+	sIFDIR = 0o040000
+	sIFREG = 0o100000
+)
+
+// reader decodes SSH wire types from a packet body. The first decoding
+// error sticks; later reads return zero values.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) uint32() uint32 {
+	if r.err != nil || len(r.b) < 4 {
+		r.err = errShort
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *reader) uint64() uint64 {
+	if r.err != nil || len(r.b) < 8 {
+		r.err = errShort
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.b)) < n {
+		r.err = errShort
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+// writer encodes SSH wire types into a packet body.
+type writer struct {
+	b []byte
+}
+
+func (w *writer) uint32(v uint32) {
+	w.b = binary.BigEndian.AppendUint32(w.b, v)
+}
+
+func (w *writer) uint64(v uint64) {
+	w.b = binary.BigEndian.AppendUint64(w.b, v)
+}
+
+func (w *writer) bytes(v []byte) {
+	w.uint32(uint32(len(v)))
+	w.b = append(w.b, v...)
+}
+
+func (w *writer) string(v string) {
+	w.uint32(uint32(len(v)))
+	w.b = append(w.b, v...)
+}
+
+// attrs encodes size, permissions and modification time.
+func (w *writer) attrs(fi *FileInfo) {
+	perm := uint32(sIFREG | 0o444)
+	switch {
+	case fi.Dir && fi.Writable:
+		perm = sIFDIR | 0o755
+	case fi.Dir:
+		perm = sIFDIR | 0o555
+	}
+	flags := uint32(attrSize | attrPermissions)
+	if !fi.ModTime.IsZero() {
+		flags |= attrModTime
+	}
+	w.uint32(flags)
+	w.uint64(uint64(fi.Size))
+	w.uint32(perm)
+	if flags&attrModTime != 0 {
+		t := uint32(fi.ModTime.Unix())
+		w.uint32(t) // atime
+		w.uint32(t) // mtime
+	}
+}
+
+// Clean turns a client path into an absolute, clean path. Relative paths
+// are taken from the root.
+func Clean(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	return path.Clean("/" + p)
+}
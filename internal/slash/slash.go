@@ -0,0 +1,236 @@
+// Package slash parses slash commands typed at line-input prompts, such as
+// "/msg Night Owl" in a chat room: quoted arguments, aliases and per-command
+// access conditions, so menus and chat share one syntax.
+package slash
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by Parse. Unknown and denied commands are reported the
+// same way to callers, so they do not learn what they may not use.
+var (
+	ErrUnknown = errors.New("unknown command")
+	ErrQuote   = errors.New("unterminated quote")
+)
+
+// Command is one slash command.
+type Command struct {
+	Name    string
+	Aliases []string
+	ACS     string // access condition, "" = everyone; see Who.Allowed
+	Usage   string // arguments, e.g. "<user> <text>"
+	Help    string
+	MinArgs int
+}
+
+// Who is the caller a command is checked against.
+type Who struct {
+	Level int // security level
+	Node  int
+}
+
+// Call is a parsed command line.
+type Call struct {
+	Command *Command
+	Name    string   // as typed, lowercased
+	Args    []string // unquoted arguments
+	Rest    string   // raw text after the name, for free-text commands
+}
+
+// Parser knows a set of commands.
+type Parser struct {
+	cmds   []*Command
+	byName map[string]*Command
+}
+
+// New creates a parser for cmds. It panics on a bad ACS or a name used
+// twice, which are programming errors; use Add for commands from scripts.
+func New(cmds ...Command) *Parser {
+	p := &Parser{byName: make(map[string]*Command)}
+	for _, c := range cmds {
+		if err := p.Add(c); err != nil {
+			panic(err)
+		}
+	}
+	return p
+}
+
+// Add registers a command.
+func (p *Parser) Add(c Command) error {
+	c.Name = strings.ToLower(strings.TrimPrefix(c.Name, "/"))
+	if c.Name == "" || strings.ContainsAny(c.Name, " \t\"'") {
+		return fmt.Errorf("invalid command name %q", c.Name)
+	}
+	if _, err := parseACS(c.ACS); err != nil {
+		return fmt.Errorf("command /%s: %w", c.Name, err)
+	}
+	names := append([]string{c.Name}, c.Aliases...)
+	seen := make(map[string]bool)
+	for i, n := range names {
+		n = strings.ToLower(strings.TrimPrefix(n, "/"))
+		if _, ok := p.byName[n]; ok || seen[n] {
+			return fmt.Errorf("command /%s is defined twice", n)
+		}
+		seen[n] = true
+		names[i] = n
+	}
+	cmd := &c
+	cmd.Aliases = names[1:]
+	for _, n := range names {
+		p.byName[n] = cmd
+	}
+	p.cmds = append(p.cmds, cmd)
+	return nil
+}
+
+// Parse parses line. Lines that do not start with "/" are not commands:
+// Parse returns nil and no error, and the caller treats the line as text.
+// A command the caller may not use is ErrUnknown.
+func (p *Parser) Parse(line string, who Who) (*Call, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "/") {
+		return nil, nil
+	}
+	name, rest, _ := strings.Cut(line[1:], " ")
+	name = strings.ToLower(name)
+	cmd := p.byName[name]
+	if cmd == nil || !who.Allowed(cmd.ACS) {
+		return nil, fmt.Errorf("/%s: %w", name, ErrUnknown)
+	}
+	args, err := Tokenize(rest)
+	if err != nil {
+		return nil, fmt.Errorf("/%s: %w", name, err)
+	}
+	if len(args) < cmd.MinArgs {
+		return nil, fmt.Errorf("usage: /%s %s", cmd.Name, cmd.Usage)
+	}
+	return &Call{Command: cmd, Name: name, Args: args, Rest: strings.TrimSpace(rest)}, nil
+}
+
+// Help returns one line per command the caller may use, by name.
+func (p *Parser) Help(who Who) []string {
+	var cmds []*Command
+	for _, c := range p.cmds {
+		if who.Allowed(c.ACS) {
+			cmds = append(cmds, c)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	lines := make([]string, 0, len(cmds))
+	for _, c := range cmds {
+		syntax := "/" + c.Name
+		if c.Usage != "" {
+			syntax += " " + c.Usage
+		}
+		line := fmt.Sprintf("%-24s %s", syntax, c.Help)
+		if len(c.Aliases) > 0 {
+			line += " (also /" + strings.Join(c.Aliases, ", /") + ")"
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	return lines
+}
+
+// Tokenize splits s into words. Double or single quotes group words, and a
+// backslash escapes the next character outside single quotes.
+func Tokenize(s string) ([]string, error) {
+	var (
+		args  []string
+		cur   strings.Builder
+		inArg bool
+		quote rune
+		esc   bool
+	)
+	for _, r := range s {
+		switch {
+		case esc:
+			cur.WriteRune(r)
+			esc = false
+		case r == '\\' && quote != '\'':
+			esc, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, ErrQuote
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// Allowed reports whether who meets acs, a space-separated list of
+// conditions that must all hold:
+//
+//	s50   security level 50 or more
+//	n1    on node 1
+//	!s90  a leading ! negates: below level 90
+//
+// An invalid acs allows no one.
+func (w Who) Allowed(acs string) bool {
+	conds, err := parseACS(acs)
+	if err != nil {
+		return false
+	}
+	for _, c := range conds {
+		var ok bool
+		switch c.kind {
+		case 's':
+			ok = w.Level >= c.n
+		case 'n':
+			ok = w.Node == c.n
+		}
+		if ok == c.not {
+			return false
+		}
+	}
+	return true
+}
+
+type acsCond struct {
+	not  bool
+	kind byte
+	n    int
+}
+
+func parseACS(acs string) ([]acsCond, error) {
+	var conds []acsCond
+	for _, f := range strings.Fields(strings.ToLower(acs)) {
+		c := acsCond{}
+		if strings.HasPrefix(f, "!") {
+			c.not, f = true, f[1:]
+		}
+		if len(f) < 2 || (f[0] != 's' && f[0] != 'n') {
+			return nil, fmt.Errorf("invalid access condition %q", f)
+		}
+		n, err := strconv.Atoi(f[1:])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid access condition %q", f)
+		}
+		c.kind, c.n = f[0], n
+		conds = append(conds, c)
+	}
+	return conds, nil
+}
@@ -0,0 +1,87 @@
+package slash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := map[string][]string{
+		``:                          nil,
+		`  one   two `:              {"one", "two"},
+		`"Night Owl" hello there`:   {"Night Owl", "hello", "there"},
+		`'it''s' "say \"hi\"" a\ b`: {"its", `say "hi"`, "a b"},
+		`'back\slash' ""`:           {`back\slash`, ""},
+		`mid"dle quote"d`:           {"middle quoted"},
+	}
+	for in, want := range cases {
+		got, err := Tokenize(in)
+		if err != nil || strings.Join(got, "|") != strings.Join(want, "|") || len(got) != len(want) {
+			t.Errorf("Tokenize(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := Tokenize(`"open`); !errors.Is(err, ErrQuote) {
+		t.Errorf("unterminated quote: %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	p := New(
+		Command{Name: "quit", Aliases: []string{"q", "bye"}, Help: "Leave"},
+		Command{Name: "msg", Usage: "<user> <text>", MinArgs: 2, Help: "Private message"},
+		Command{Name: "kick", ACS: "s90", Usage: "<user>", MinArgs: 1, Help: "Remove a user"},
+	)
+	user := Who{Level: 20, Node: 2}
+	sysop := Who{Level: 100, Node: 1}
+
+	if c, err := p.Parse("hello /quit", user); c != nil || err != nil {
+		t.Errorf("plain text parsed as %+v, %v", c, err)
+	}
+	if c, err := p.Parse(" /Q ", user); err != nil || c.Command.Name != "quit" || c.Name != "q" {
+		t.Errorf("/Q = %+v, %v", c, err)
+	}
+	c, err := p.Parse(`/msg "Night Owl" see you  later`, user)
+	if err != nil || len(c.Args) != 4 || c.Args[0] != "Night Owl" || c.Rest != `"Night Owl" see you  later` {
+		t.Errorf("/msg = %+v, %v", c, err)
+	}
+	if _, err := p.Parse("/msg bob", user); err == nil || !strings.Contains(err.Error(), "usage: /msg <user> <text>") {
+		t.Errorf("/msg bob: %v", err)
+	}
+	if _, err := p.Parse("/kick bob", user); !errors.Is(err, ErrUnknown) {
+		t.Errorf("/kick as user: %v", err)
+	}
+	if _, err := p.Parse("/kick bob", sysop); err != nil {
+		t.Errorf("/kick as sysop: %v", err)
+	}
+	if _, err := p.Parse("/nope", sysop); !errors.Is(err, ErrUnknown) {
+		t.Errorf("/nope: %v", err)
+	}
+
+	if help := p.Help(user); len(help) != 2 || !strings.HasPrefix(help[0], "/msg <user> <text>") ||
+		!strings.HasSuffix(help[1], "Leave (also /q, /bye)") {
+		t.Errorf("Help(user) = %q", help)
+	}
+	if len(p.Help(sysop)) != 3 {
+		t.Errorf("Help(sysop) = %q", p.Help(sysop))
+	}
+
+	if err := p.Add(Command{Name: "exit", Aliases: []string{"Q"}}); err == nil {
+		t.Error("alias clash accepted")
+	}
+	if err := p.Add(Command{Name: "wall", ACS: "level90"}); err == nil {
+		t.Error("bad ACS accepted")
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	w := Who{Level: 50, Node: 3}
+	for acs, want := range map[string]bool{
+		"": true, "s50": true, "s51": false, "!s90": true, "!s50": false,
+		"n3": true, "s20 n3": true, "s20 !n3": false, "S20": true, "x5": false,
+	} {
+		if got := w.Allowed(acs); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", acs, got, want)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Package sshexec runs the restricted command set offered on SSH exec
+// channels, so scripts can post and read messages without driving the
+// interactive menus:
+//
+//	ssh -i key sysop@bbs 'post --area 1 --subject "Nightly build"' < notes.txt
+package sshexec
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/notepid/twilight_bbs/internal/event"
+	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/scripting"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// Exit statuses returned to the SSH client.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// Runner executes exec commands on behalf of a user.
+type Runner struct {
+	users    *user.Repo
+	messages *message.Repo
+
+	Events *event.Bus // receives event.Post for messages posted
+}
+
+// New creates a Runner.
+func New(users *user.Repo, messages *message.Repo) *Runner {
+	return &Runner{users: users, messages: messages}
+}
+
+// command is one entry of the restricted command set.
+type command struct {
+	usage string
+	run   func(c *call, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":   {"help", (*call).help},
+		"whoami": {"whoami", (*call).whoami},
+		"areas":  {"areas [--json]", (*call).areas},
+		"list":   {"list --area ID [--new] [--offset N] [--limit N] [--json]", (*call).list},
+		"read":   {"read [--json] [--no-mark] ID", (*call).read},
+		"post":   {"post --area ID --subject TEXT [--reply-to ID] [--body TEXT]  (body from stdin if --body is omitted)", (*call).post},
+	}
+}
+
+// call is the state of one exec request.
+type call struct {
+	r      *Runner
+	user   *user.User
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errUsage marks errors that should print the command's usage.
+var errUsage = errors.New("usage")
+
+// Run executes command line for username and returns the exit status.
+func (r *Runner) Run(username, line string, stdin io.Reader, stdout, stderr io.Writer) int {
+	args, err := splitArgs(line)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitUsage
+	}
+	if len(args) == 0 {
+		args = []string{"help"}
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q (try help)\n", args[0])
+		return exitUsage
+	}
+
+	u, err := r.users.GetByUsername(username)
+	if err != nil {
+		fmt.Fprintln(stderr, "error: unknown user")
+		return exitError
+	}
+
+	c := &call{r: r, user: u, stdin: stdin, stdout: stdout, stderr: stderr}
+	if err := cmd.run(c, args[1:]); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(stderr, "usage: %s\n", cmd.usage)
+			return exitUsage
+		}
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func (c *call) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func (c *call) help(args []string) error {
+	fmt.Fprintln(c.stdout, "Commands:")
+	for _, name := range []string{"whoami", "areas", "list", "read", "post", "help"} {
+		fmt.Fprintf(c.stdout, "  %s\n", commands[name].usage)
+	}
+	return nil
+}
+
+func (c *call) whoami(args []string) error {
+	fmt.Fprintf(c.stdout, "%s (level %d)\n", c.user.Username, c.user.SecurityLevel)
+	return nil
+}
+
+type areaJSON struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Messages    int    `json:"messages"`
+	CanPost     bool   `json:"can_post"`
+}
+
+func (c *call) areas(args []string) error {
+	fs := c.flags("areas")
+	asJSON := fs.Bool("json", false, "")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	areas, err := c.r.messages.ListAreas(c.user.SecurityLevel)
+	if err != nil {
+		return err
+	}
+	out := make([]areaJSON, 0, len(areas))
+	for _, a := range areas {
+		out = append(out, areaJSON{a.ID, a.Name, a.Description, a.TotalMsgs, c.user.SecurityLevel >= a.WriteLevel})
+	}
+	if *asJSON {
+		return c.json(out)
+	}
+	for _, a := range out {
+		fmt.Fprintf(c.stdout, "%d\t%s\t%d\t%s\n", a.ID, a.Name, a.Messages, a.Description)
+	}
+	return nil
+}
+
+type messageJSON struct {
+	ID      int    `json:"id"`
+	AreaID  int    `json:"area_id"`
+	From    string `json:"from"`
+	To      string `json:"to,omitempty"`
+	Subject string `json:"subject"`
+	Date    string `json:"date"`
+	ReplyTo int    `json:"reply_to,omitempty"`
+	Body    string `json:"body,omitempty"`
+}
+
+func toJSON(m *message.Message) messageJSON {
+	j := messageJSON{
+		ID: m.ID, AreaID: m.AreaID, From: m.FromName, To: m.ToName,
+		Subject: m.Subject, Date: m.CreatedAt.Format("2006-01-02 15:04"), Body: m.Body,
+	}
+	if m.ReplyToID != nil {
+		j.ReplyTo = *m.ReplyToID
+	}
+	return j
+}
+
+func (c *call) list(args []string) error {
+	fs := c.flags("list")
+	areaID := fs.Int("area", 0, "")
+	onlyNew := fs.Bool("new", false, "")
+	offset := fs.Int("offset", 0, "")
+	limit := fs.Int("limit", 50, "")
+	asJSON := fs.Bool("json", false, "")
+	if err := fs.Parse(args); err != nil || *areaID == 0 || fs.NArg() > 0 {
+		return errUsage
+	}
+	if _, err := c.readableArea(*areaID); err != nil {
+		return err
+	}
+
+	var msgs []*message.Message
+	var err error
+	if *onlyNew {
+		msgs, err = c.r.messages.GetNewMessages(c.user.ID, *areaID)
+	} else {
+		msgs, err = c.r.messages.ListMessages(*areaID, *offset, *limit)
+	}
+	if err != nil {
+		return err
+	}
+
+	out := make([]messageJSON, 0, len(msgs))
+	for _, m := range msgs {
+		if !c.visible(m) {
+			continue
+		}
+		j := toJSON(m)
+		j.Body = ""
+		out = append(out, j)
+	}
+	if *asJSON {
+		return c.json(out)
+	}
+	for _, m := range out {
+		fmt.Fprintf(c.stdout, "%d\t%s\t%s\t%s\n", m.ID, m.Date, m.From, m.Subject)
+	}
+	return nil
+}
+
+func (c *call) read(args []string) error {
+	fs := c.flags("read")
+	asJSON := fs.Bool("json", false, "")
+	noMark := fs.Bool("no-mark", false, "")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	var id int
+	if _, err := fmt.Sscan(fs.Arg(0), &id); err != nil {
+		return errUsage
+	}
+
+	m, err := c.r.messages.GetMessage(id)
+	if err != nil || !c.visible(m) {
+		return fmt.Errorf("message %d not found", id)
+	}
+	if _, err := c.readableArea(m.AreaID); err != nil {
+		return fmt.Errorf("message %d not found", id)
+	}
+	if !*noMark {
+		if err := c.r.messages.MarkRead(c.user.ID, m.AreaID, m.ID); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		return c.json(toJSON(m))
+	}
+	fmt.Fprintf(c.stdout, "Message: %d\nFrom: %s\n", m.ID, m.FromName)
+	if m.ToName != "" {
+		fmt.Fprintf(c.stdout, "To: %s\n", m.ToName)
+	}
+	fmt.Fprintf(c.stdout, "Date: %s\nSubject: %s\n\n%s\n", m.CreatedAt.Format("2006-01-02 15:04"), m.Subject, m.Body)
+	return nil
+}
+
+func (c *call) post(args []string) error {
+	fs := c.flags("post")
+	areaID := fs.Int("area", 0, "")
+	subject := fs.String("subject", "", "")
+	replyTo := fs.Int("reply-to", 0, "")
+	body := fs.String("body", "", "")
+	if err := fs.Parse(args); err != nil || *areaID == 0 || *subject == "" || fs.NArg() > 0 {
+		return errUsage
+	}
+
+	a, err := c.readableArea(*areaID)
+	if err != nil {
+		return err
+	}
+	if c.user.SecurityLevel < a.WriteLevel {
+		return fmt.Errorf("no permission to post in %s", a.Name)
+	}
+
+	text := *body
+	if text == "" {
+		data, err := io.ReadAll(io.LimitReader(c.stdin, scripting.MaxMessageLen*4+1))
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		text = string(data)
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	v := &scripting.ValidateInput{}
+	if err := v.ValidateString(*subject, "subject", scripting.MaxSubjectLen); err != nil {
+		return err
+	}
+	if err := v.ValidateMessageBody(text); err != nil {
+		return err
+	}
+
+	var replyToID *int
+	if *replyTo > 0 {
+		parent, err := c.r.messages.GetMessage(*replyTo)
+		if err != nil || parent.AreaID != *areaID {
+			return fmt.Errorf("reply-to message %d not found in area %d", *replyTo, *areaID)
+		}
+		replyToID = replyTo
+	}
+
+	id, err := c.r.messages.Post(*areaID, c.user.ID, nil, *subject, text, replyToID)
+	if err != nil {
+		return err
+	}
+	c.r.Events.Publish(event.Event{Name: event.Post, Data: *areaID})
+	fmt.Fprintln(c.stdout, id)
+	return nil
+}
+
+// readableArea returns the area if the user may read it.
+func (c *call) readableArea(id int) (*message.Area, error) {
+	a, err := c.r.messages.GetArea(id)
+	if err != nil || c.user.SecurityLevel < a.ReadLevel {
+		return nil, fmt.Errorf("area %d not found", id)
+	}
+	return a, nil
+}
+
+// visible reports whether a message may be shown: public, or addressed
+// to or from the user.
+func (c *call) visible(m *message.Message) bool {
+	return m.ToUserID == nil || *m.ToUserID == c.user.ID || m.FromUserID == c.user.ID
+}
+
+func (c *call) json(v interface{}) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// splitArgs splits a command line into words, honouring single and double
+// quotes and backslash escapes outside single quotes. No other shell
+// syntax is interpreted.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
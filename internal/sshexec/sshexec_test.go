@@ -0,0 +1,72 @@
+package sshexec
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/message"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+func TestSplitArgs(t *testing.T) {
+	got, err := splitArgs(`post --area 1 --subject "Hello world" --body 'it''s' a\ b`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"post", "--area", "1", "--subject", "Hello world", "--body", "its", "a b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if _, err := splitArgs(`post "open`); err == nil {
+		t.Fatal("unterminated quote accepted")
+	}
+}
+
+func TestPostListRead(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash, security_level) VALUES (1, 'sysop', 'x', 100), (2, 'newbie', 'x', 0)`); err != nil {
+		t.Fatal(err)
+	}
+	msgs := message.NewRepo(database.DB)
+	r := New(user.NewRepo(database.DB), msgs)
+
+	run := func(username, line, stdin string) (int, string, string) {
+		var out, errOut bytes.Buffer
+		status := r.Run(username, line, strings.NewReader(stdin), &out, &errOut)
+		return status, out.String(), errOut.String()
+	}
+
+	status, out, errOut := run("sysop", `post --area 1 --subject "Build done"`, "All green.\r\n")
+	if status != 0 {
+		t.Fatalf("post: status %d, %s", status, errOut)
+	}
+	id := strings.TrimSpace(out)
+
+	if status, out, _ = run("sysop", "list --area 1", ""); status != 0 || !strings.Contains(out, "Build done") {
+		t.Fatalf("list: status %d, %q", status, out)
+	}
+	if status, out, _ = run("sysop", "read "+id, ""); status != 0 || !strings.Contains(out, "All green.\n") {
+		t.Fatalf("read: status %d, %q", status, out)
+	}
+	if status, out, _ = run("sysop", "list --area 1 --new", ""); status != 0 || out != "" {
+		t.Fatalf("list --new after read: %q", out)
+	}
+
+	if status, _, errOut = run("newbie", "post --area 1 --subject x --body y", ""); status != exitError {
+		t.Fatalf("low-level post: status %d, %s", status, errOut)
+	}
+	if status, _, _ = run("sysop", "post --area 1", ""); status != exitUsage {
+		t.Fatalf("missing subject: status %d", status)
+	}
+	if status, _, _ = run("sysop", "rm -rf /", ""); status != exitUsage {
+		t.Fatalf("unknown command: status %d", status)
+	}
+}
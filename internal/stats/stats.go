@@ -0,0 +1,248 @@
+// Package stats keeps daily board statistics (calls, new users, posts per
+// area, file transfers, door launches, peak nodes and terminal traffic),
+// maintained from event bus hooks.
+package stats
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/callers"
+	"github.com/notepid/twilight_bbs/internal/event"
+)
+
+// Metric names.
+const (
+	Calls        = "calls"
+	NewUsers     = "new_users"
+	Posts        = "posts" // per message area
+	Uploads      = "uploads"
+	Downloads    = "downloads"
+	DoorLaunches = "door_launches"
+	PeakNodes    = "peak_nodes"       // highest simultaneous nodes, not a sum
+	TermKBSent   = "term_kb_sent"     // terminal output in KB, transfers excluded
+	TermKBRecv   = "term_kb_received" // terminal input in KB
+)
+
+// Metrics lists the metrics in the order reports show them.
+var Metrics = []string{Calls, NewUsers, Posts, Uploads, Downloads, DoorLaunches, PeakNodes, TermKBSent, TermKBRecv}
+
+// dayFormat keys rows by local calendar day.
+const dayFormat = "2006-01-02"
+
+// Repo stores daily statistics.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo creates a statistics repository.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+// Add adds n to a metric for the day t falls on. Subject splits a metric,
+// e.g. posts by area ID; it is 0 otherwise.
+func (r *Repo) Add(t time.Time, metric string, subject, n int) error {
+	_, err := r.db.Exec(`
+		INSERT INTO daily_stats (day, metric, subject, value) VALUES (?, ?, ?, ?)
+		ON CONFLICT(day, metric, subject) DO UPDATE SET value = value + excluded.value
+	`, t.Local().Format(dayFormat), metric, subject, n)
+	if err != nil {
+		return fmt.Errorf("add %s stat: %w", metric, err)
+	}
+	return nil
+}
+
+// Peak raises a metric for the day t falls on to v if v is higher.
+func (r *Repo) Peak(t time.Time, metric string, v int) error {
+	_, err := r.db.Exec(`
+		INSERT INTO daily_stats (day, metric, subject, value) VALUES (?, ?, 0, ?)
+		ON CONFLICT(day, metric, subject) DO UPDATE SET value = MAX(value, excluded.value)
+	`, t.Local().Format(dayFormat), metric, v)
+	if err != nil {
+		return fmt.Errorf("peak %s stat: %w", metric, err)
+	}
+	return nil
+}
+
+// Subscribe keeps the statistics from events published on bus.
+func (r *Repo) Subscribe(bus *event.Bus) {
+	for _, name := range []string{event.Connect, event.Login, event.NewUser, event.Post,
+		event.Upload, event.Download, event.DoorLaunch, event.Logoff} {
+		bus.Subscribe(name, r.Handle)
+	}
+}
+
+// Handle records one event.
+func (r *Repo) Handle(ev event.Event) {
+	now := time.Now()
+	n, _ := ev.Data.(int)
+	var err error
+	switch ev.Name {
+	case event.Connect:
+		err = r.Peak(now, PeakNodes, n)
+	case event.Login:
+		err = r.Add(now, Calls, 0, 1)
+	case event.NewUser:
+		err = r.Add(now, NewUsers, 0, 1)
+	case event.Post:
+		err = r.Add(now, Posts, n, 1)
+	case event.Upload:
+		err = r.Add(now, Uploads, 0, n)
+	case event.Download:
+		err = r.Add(now, Downloads, 0, n)
+	case event.DoorLaunch:
+		err = r.Add(now, DoorLaunches, 0, 1)
+	case event.Logoff:
+		if c, ok := ev.Data.(*callers.Call); ok {
+			if err = r.Add(now, TermKBSent, 0, int(c.TermSent/1024)); err == nil {
+				err = r.Add(now, TermKBRecv, 0, int(c.TermReceived/1024))
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("Node %d: %v", ev.NodeID, err)
+	}
+}
+
+// Day is the statistics for one day.
+type Day struct {
+	Date      string         // YYYY-MM-DD
+	Totals    map[string]int // by metric; missing metrics are 0
+	AreaPosts map[int]int    // posts by message area ID
+}
+
+// Day returns the statistics for the day t falls on.
+func (r *Repo) Day(t time.Time) (*Day, error) {
+	d := &Day{Date: t.Local().Format(dayFormat), Totals: make(map[string]int), AreaPosts: make(map[int]int)}
+	rows, err := r.db.Query(`
+		SELECT metric, subject, value FROM daily_stats WHERE day = ?
+	`, d.Date)
+	if err != nil {
+		return nil, fmt.Errorf("day stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var metric string
+		var subject, value int
+		if err := rows.Scan(&metric, &subject, &value); err != nil {
+			return nil, fmt.Errorf("day stats: %w", err)
+		}
+		d.Totals[metric] += value
+		if metric == Posts {
+			d.AreaPosts[subject] += value
+		}
+	}
+	return d, rows.Err()
+}
+
+// Series returns a metric for each of the days days ending on the day end
+// falls on, oldest first. Peak nodes are the day's peak; other metrics are
+// summed over subjects.
+func (r *Repo) Series(metric string, days int, end time.Time) ([]int, error) {
+	if days <= 0 {
+		return nil, nil
+	}
+	end = end.Local()
+	start := end.AddDate(0, 0, -(days - 1))
+	rows, err := r.db.Query(`
+		SELECT day, SUM(value) FROM daily_stats
+		WHERE metric = ? AND day BETWEEN ? AND ?
+		GROUP BY day
+	`, metric, start.Format(dayFormat), end.Format(dayFormat))
+	if err != nil {
+		return nil, fmt.Errorf("%s series: %w", metric, err)
+	}
+	defer rows.Close()
+
+	byDay := make(map[string]int)
+	for rows.Next() {
+		var day string
+		var v int
+		if err := rows.Scan(&day, &v); err != nil {
+			return nil, fmt.Errorf("%s series: %w", metric, err)
+		}
+		byDay[day] = v
+	}
+	series := make([]int, days)
+	for i := range series {
+		series[i] = byDay[start.AddDate(0, 0, i).Format(dayFormat)]
+	}
+	return series, rows.Err()
+}
+
+// AreaPosts returns posts by message area ID over the days days ending on
+// the day end falls on.
+func (r *Repo) AreaPosts(days int, end time.Time) (map[int]int, error) {
+	end = end.Local()
+	start := end.AddDate(0, 0, -(days - 1))
+	rows, err := r.db.Query(`
+		SELECT subject, SUM(value) FROM daily_stats
+		WHERE metric = ? AND day BETWEEN ? AND ?
+		GROUP BY subject
+	`, Posts, start.Format(dayFormat), end.Format(dayFormat))
+	if err != nil {
+		return nil, fmt.Errorf("area posts: %w", err)
+	}
+	defer rows.Close()
+	posts := make(map[int]int)
+	for rows.Next() {
+		var area, n int
+		if err := rows.Scan(&area, &n); err != nil {
+			return nil, fmt.Errorf("area posts: %w", err)
+		}
+		posts[area] = n
+	}
+	return posts, rows.Err()
+}
+
+// Caller is a user ranked by calls.
+type Caller struct {
+	Username string `json:"username"`
+	Calls    int    `json:"calls"`
+}
+
+// TopCallers returns up to n users with the most calls, all time.
+func (r *Repo) TopCallers(n int) ([]Caller, error) {
+	rows, err := r.db.Query(`
+		SELECT username, total_calls FROM users
+		WHERE total_calls > 0
+		ORDER BY total_calls DESC, username
+		LIMIT ?
+	`, n)
+	if err != nil {
+		return nil, fmt.Errorf("top callers: %w", err)
+	}
+	defer rows.Close()
+	var top []Caller
+	for rows.Next() {
+		var c Caller
+		if err := rows.Scan(&c.Username, &c.Calls); err != nil {
+			return nil, fmt.Errorf("top callers: %w", err)
+		}
+		top = append(top, c)
+	}
+	return top, rows.Err()
+}
+
+// sparkBars are the sparkline levels, lowest first.
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as one bar character each, scaled to the largest.
+func Sparkline(values []int) string {
+	peak := 0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	bars := make([]rune, len(values))
+	for i, v := range values {
+		if peak == 0 || v <= 0 {
+			bars[i] = sparkBars[0]
+			continue
+		}
+		bars[i] = sparkBars[(v*(len(sparkBars)-1)+peak-1)/peak]
+	}
+	return string(bars)
+}
@@ -0,0 +1,129 @@
+package stats
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/callers"
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/event"
+)
+
+func TestEventsAggregateIntoToday(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+	bus := event.NewBus()
+	repo.Subscribe(bus)
+
+	for _, ev := range []event.Event{
+		{Name: event.Connect, NodeID: 1, Data: 1},
+		{Name: event.Login, NodeID: 1},
+		{Name: event.Connect, NodeID: 2, Data: 3},
+		{Name: event.Connect, NodeID: 3, Data: 2},
+		{Name: event.Login, NodeID: 2},
+		{Name: event.NewUser, NodeID: 2},
+		{Name: event.Post, NodeID: 1, Data: 4},
+		{Name: event.Post, NodeID: 2, Data: 4},
+		{Name: event.Post, NodeID: 2, Data: 7},
+		{Name: event.Upload, NodeID: 1, Data: 2},
+		{Name: event.Download, NodeID: 1, Data: 3},
+		{Name: event.DoorLaunch, NodeID: 2, Data: "LORD"},
+		{Name: event.Logoff, NodeID: 2}, // no call attached
+		{Name: event.Logoff, NodeID: 1, Data: &callers.Call{TermSent: 5000, TermReceived: 300}},
+		{Name: event.Logoff, NodeID: 3, Data: &callers.Call{TermSent: 3000, TermReceived: 2000}},
+	} {
+		bus.Publish(ev)
+	}
+
+	d, err := repo.Day(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{Calls: 2, NewUsers: 1, Posts: 3, Uploads: 2, Downloads: 3, DoorLaunches: 1, PeakNodes: 3,
+		TermKBSent: 6, TermKBRecv: 1}
+	for m, n := range want {
+		if d.Totals[m] != n {
+			t.Errorf("%s = %d, want %d", m, d.Totals[m], n)
+		}
+	}
+	if d.AreaPosts[4] != 2 || d.AreaPosts[7] != 1 {
+		t.Errorf("area posts = %v, want 4:2 7:1", d.AreaPosts)
+	}
+}
+
+func TestSeriesFillsMissingDays(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+
+	end := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+	for _, add := range []struct {
+		daysAgo, subject, n int
+	}{{0, 1, 2}, {0, 2, 1}, {2, 1, 5}, {7, 1, 9}} {
+		if err := repo.Add(end.AddDate(0, 0, -add.daysAgo), Posts, add.subject, add.n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	series, err := repo.Series(Posts, 4, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 5, 0, 3}; !slices.Equal(series, want) {
+		t.Errorf("series = %v, want %v", series, want)
+	}
+	posts, err := repo.AreaPosts(4, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posts[1] != 7 || posts[2] != 1 {
+		t.Errorf("area posts = %v, want 1:7 2:1", posts)
+	}
+}
+
+func TestTopCallers(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`
+		INSERT INTO users (username, password_hash, total_calls) VALUES
+			('alice', 'x', 5), ('bob', 'x', 12), ('carol', 'x', 0), ('dave', 'x', 5)
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	top, err := NewRepo(database.DB).TopCallers(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Caller{{"bob", 12}, {"alice", 5}}; !slices.Equal(top, want) {
+		t.Errorf("top = %v, want %v", top, want)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	for _, tc := range []struct {
+		in   []int
+		want string
+	}{
+		{nil, ""},
+		{[]int{0, 0}, "▁▁"},
+		{[]int{0, 1, 4, 8}, "▁▂▅█"},
+		{[]int{3, 3}, "██"},
+	} {
+		if got := Sparkline(tc.in); got != tc.want {
+			t.Errorf("Sparkline(%v) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
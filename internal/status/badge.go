@@ -0,0 +1,40 @@
+package status
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"unicode/utf8"
+)
+
+// Badge colors.
+const (
+	colorOK   = "#4c1"    // nodes free
+	colorBusy = "#e05d44" // every node busy, or shutting down
+	colorInfo = "#007ec6" // other figures
+)
+
+// charWidth is the average width of a character of the badge font, in
+// pixels; the SVG has no way to measure text.
+const charWidth = 7
+
+// Badge renders a flat two-part status badge with label on the left and
+// value on the right, in the style of the badges on project pages.
+func Badge(label, value, color string) []byte {
+	lw := utf8.RuneCountInString(label)*charWidth + 10
+	vw := utf8.RuneCountInString(value)*charWidth + 10
+	label, value = html.EscapeString(label), html.EscapeString(value)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, lw+vw, label, value)
+	fmt.Fprintf(&b, `<title>%s: %s</title>`, label, value)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, lw+vw)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`,
+		lw, lw, vw, color, lw+vw)
+	b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw/2, label, lw/2, label)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw+vw/2, value, lw+vw/2, value)
+	b.WriteString("</g></svg>\n")
+	return b.Bytes()
+}
@@ -0,0 +1,217 @@
+// Package status serves the board's public status for sysops to embed on
+// their websites: /api/public/stats as JSON and /api/public/badge.svg as
+// a status badge. Only the fields the sysop chose are published, and
+// figures are cached so the endpoints are cheap to hit.
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/control"
+	"github.com/notepid/twilight_bbs/internal/stats"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+// Fields that can be published.
+const (
+	Users         = "users"           // registered users
+	Online        = "online"          // nodes in use
+	MaxNodes      = "max_nodes"       // nodes the board has
+	CallsToday    = "calls_today"     // calls since midnight
+	PostsToday    = "posts_today"     // posts since midnight
+	NewUsersToday = "new_users_today" // registrations since midnight
+	UpSince       = "up_since"        // when the BBS started, RFC 3339
+)
+
+// AllFields lists every field, in the order the JSON shows them.
+var AllFields = []string{Users, Online, MaxNodes, CallsToday, PostsToday, NewUsersToday, UpSince}
+
+// DefaultFields are published when the sysop does not choose.
+var DefaultFields = []string{Users, CallsToday, Online}
+
+// cacheFor is how long figures are reused between requests.
+const cacheFor = 30 * time.Second
+
+// Handler serves /api/public/stats and /api/public/badge.svg.
+type Handler struct {
+	name   string
+	ctl    *control.Server
+	users  *user.Repo
+	fields []string
+
+	mu      sync.Mutex
+	cached  map[string]any
+	full    bool // every node is busy or the board is draining
+	expires time.Time
+}
+
+// New returns a handler publishing fields, which must come from
+// AllFields. Mount it at "/api/public/".
+func New(name string, ctl *control.Server, users *user.Repo, fields []string) (*Handler, error) {
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+	for _, f := range fields {
+		if !slices.Contains(AllFields, f) {
+			return nil, fmt.Errorf("unknown public stats field %q (have %s)", f, strings.Join(AllFields, ", "))
+		}
+	}
+	return &Handler{name: name, ctl: ctl, users: users, fields: fields}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/api/public/stats":
+		h.serveStats(w)
+	case "/api/public/badge.svg":
+		h.serveBadge(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) serveStats(w http.ResponseWriter) {
+	values, _, err := h.values()
+	if err != nil {
+		log.Printf("Public stats: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	// The JSON keeps AllFields' order rather than sorting the keys.
+	var b strings.Builder
+	b.WriteString(`{"board":`)
+	name, _ := json.Marshal(h.name)
+	b.Write(name)
+	for _, f := range AllFields {
+		if v, ok := values[f]; ok {
+			val, _ := json.Marshal(v)
+			fmt.Fprintf(&b, ",%q:%s", f, val)
+		}
+	}
+	b.WriteString("}\n")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(cacheFor.Seconds())))
+	w.Write([]byte(b.String()))
+}
+
+func (h *Handler) serveBadge(w http.ResponseWriter, r *http.Request) {
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		field = h.fields[0]
+		if slices.Contains(h.fields, Online) {
+			field = Online
+		}
+	}
+	if !slices.Contains(h.fields, field) {
+		http.NotFound(w, r)
+		return
+	}
+	values, full, err := h.values()
+	if err != nil {
+		log.Printf("Public stats: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	text, color := badgeValue(field, values), colorInfo
+	if field == Online {
+		color = colorOK
+		if full {
+			color = colorBusy
+		}
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(cacheFor.Seconds())))
+	w.Write(Badge(h.name, text, color))
+}
+
+// badgeValue is the right-hand text of a field's badge.
+func badgeValue(field string, values map[string]any) string {
+	switch field {
+	case Online:
+		return fmt.Sprintf("%v/%v online", values[Online], values[MaxNodes])
+	case Users:
+		return fmt.Sprintf("%v users", values[Users])
+	case MaxNodes:
+		return fmt.Sprintf("%v nodes", values[MaxNodes])
+	case CallsToday:
+		return fmt.Sprintf("%v calls today", values[CallsToday])
+	case PostsToday:
+		return fmt.Sprintf("%v posts today", values[PostsToday])
+	case NewUsersToday:
+		return fmt.Sprintf("%v new today", values[NewUsersToday])
+	case UpSince:
+		if t, err := time.Parse(time.RFC3339, fmt.Sprint(values[UpSince])); err == nil {
+			return "up " + upFor(time.Since(t))
+		}
+	}
+	return fmt.Sprint(values[field])
+}
+
+// upFor spells out an uptime in its largest unit, e.g. "3 days".
+func upFor(d time.Duration) string {
+	n, unit := int(d.Hours()/24), "day"
+	if n == 0 {
+		n, unit = int(d.Hours()), "hour"
+	}
+	if n == 0 {
+		n, unit = int(d.Minutes()), "minute"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// values returns the published figures, refreshing them when the cache
+// has expired. Online and MaxNodes are always kept for the badge.
+func (h *Handler) values() (map[string]any, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if time.Now().Before(h.expires) {
+		return h.cached, h.full, nil
+	}
+
+	st, err := h.ctl.Status()
+	if err != nil {
+		return nil, false, err
+	}
+	all := map[string]any{
+		Online:        st.Nodes,
+		MaxNodes:      st.MaxNodes,
+		CallsToday:    st.Today[stats.Calls],
+		PostsToday:    st.Today[stats.Posts],
+		NewUsersToday: st.Today[stats.NewUsers],
+		UpSince:       st.Started.UTC().Format(time.RFC3339),
+	}
+	if slices.Contains(h.fields, Users) {
+		if all[Users], err = h.users.Count(); err != nil {
+			return nil, false, err
+		}
+	}
+	values := make(map[string]any, len(h.fields))
+	for _, f := range h.fields {
+		values[f] = all[f]
+	}
+	if slices.Contains(h.fields, Online) {
+		values[MaxNodes] = all[MaxNodes]
+	}
+
+	h.cached = values
+	h.full = st.Draining || st.Nodes >= st.MaxNodes
+	h.expires = time.Now().Add(cacheFor)
+	return h.cached, h.full, nil
+}
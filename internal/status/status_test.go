@@ -0,0 +1,102 @@
+package status
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/control"
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/node"
+	"github.com/notepid/twilight_bbs/internal/stats"
+	"github.com/notepid/twilight_bbs/internal/user"
+)
+
+func newHandler(t *testing.T, fields []string) *Handler {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	users := user.NewRepo(database.DB)
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := users.Create(name, "secret99", "", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statsRepo := stats.NewRepo(database.DB)
+	statsRepo.Add(time.Now(), stats.Calls, 0, 3)
+	ctl := &control.Server{
+		Nodes:    node.NewManager(4, "Test BBS", "Sysop"),
+		Stats:    statsRepo,
+		MaxNodes: 4,
+		Started:  time.Now().Add(-50 * time.Hour),
+	}
+	h, err := New("Test <BBS>", ctl, users, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func get(h http.Handler, path string) *http.Response {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Result()
+}
+
+func TestPublicStats(t *testing.T) {
+	h := newHandler(t, nil)
+	resp := get(h, "/api/public/stats")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("stats = %s %v", resp.Status, resp.Header)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"board": "Test <BBS>", "users": 2.0, "online": 0.0, "max_nodes": 4.0, "calls_today": 3.0}
+	if len(got) != len(want) {
+		t.Errorf("stats = %s", body)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("stats[%s] = %v, want %v", k, got[k], v)
+		}
+	}
+
+	if _, err := New("x", nil, nil, []string{"passwords"}); err == nil {
+		t.Error("unknown field accepted")
+	}
+	if resp := get(h, "/api/public/other"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("other path = %s", resp.Status)
+	}
+}
+
+func TestBadge(t *testing.T) {
+	h := newHandler(t, []string{CallsToday, Online, UpSince})
+	for path, want := range map[string]string{
+		"/api/public/badge.svg":                   "0/4 online",
+		"/api/public/badge.svg?field=calls_today": "3 calls today",
+		"/api/public/badge.svg?field=up_since":    "up 2 days",
+	} {
+		resp := get(h, path)
+		body, _ := io.ReadAll(resp.Body)
+		if resp.Header.Get("Content-Type") != "image/svg+xml" || !strings.Contains(string(body), ">"+want+"<") {
+			t.Errorf("%s: %s", path, body)
+		}
+		if !strings.Contains(string(body), "Test &lt;BBS&gt;") {
+			t.Errorf("%s: label not escaped", path)
+		}
+	}
+	if resp := get(h, "/api/public/badge.svg?field=users"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("badge of an unpublished field = %s", resp.Status)
+	}
+}
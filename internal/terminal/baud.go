@@ -0,0 +1,107 @@
+package terminal
+
+import (
+	"fmt"
+	"time"
+)
+
+// BaudRates are the line speeds, in bits per second, that SetBaud accepts
+// besides 0 (full speed).
+var BaudRates = []int{300, 1200, 2400, 4800, 9600, 14400, 19200, 28800, 33600, 38400, 57600, 115200}
+
+// baudTick is how often paced output is written. Each write carries the
+// bytes the line would have sent in that time.
+const baudTick = 10 * time.Millisecond
+
+// ValidBaud reports whether bps is 0 or one of BaudRates.
+func ValidBaud(bps int) bool {
+	if bps == 0 {
+		return true
+	}
+	for _, r := range BaudRates {
+		if r == bps {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBaud paces output to bps, as a modem of that speed would, so art
+// draws in the way callers remember it. 0 turns pacing off.
+func (t *Terminal) SetBaud(bps int) error {
+	if !ValidBaud(bps) {
+		return fmt.Errorf("unsupported baud rate %d", bps)
+	}
+	t.baudMu.Lock()
+	defer t.baudMu.Unlock()
+	t.baud = bps
+	t.baudNext = time.Time{}
+	return nil
+}
+
+// Baud returns the emulated line speed, 0 for full speed.
+func (t *Terminal) Baud() int {
+	t.baudMu.Lock()
+	defer t.baudMu.Unlock()
+	return t.baud
+}
+
+// SuspendBaud turns pacing off until resume is called, for doors and
+// file transfers. Suspensions nest.
+func (t *Terminal) SuspendBaud() (resume func()) {
+	t.baudMu.Lock()
+	t.baudOff++
+	t.baudMu.Unlock()
+
+	done := false
+	return func() {
+		if done {
+			return
+		}
+		done = true
+		t.baudMu.Lock()
+		t.baudOff--
+		t.baudNext = time.Time{}
+		t.baudMu.Unlock()
+	}
+}
+
+// SendNow writes data at full speed even when output is paced, for
+// prompts the caller is waiting on such as "Press any key".
+func (t *Terminal) SendNow(data string) error {
+	_, err := t.writeRaw([]byte(data))
+	return err
+}
+
+// writePaced writes p at the emulated line speed, 10 bits per byte (8N1).
+// The schedule carries over between writes so many small writes are paced
+// as one stream.
+func (t *Terminal) writePaced(p []byte) (int, error) {
+	t.baudMu.Lock()
+	defer t.baudMu.Unlock()
+	if t.baud == 0 || t.baudOff > 0 {
+		return t.writeRaw(p)
+	}
+
+	bytesPerSec := t.baud / 10
+	chunk := max(1, bytesPerSec*int(baudTick)/int(time.Second))
+	perChunk := time.Duration(chunk) * time.Second / time.Duration(bytesPerSec)
+
+	written := 0
+	for written < len(p) {
+		now := time.Now()
+		if t.baudNext.Before(now) {
+			t.baudNext = now
+		}
+		time.Sleep(time.Until(t.baudNext))
+
+		end := min(written+chunk, len(p))
+		n, err := t.writeRaw(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		t.baudNext = t.baudNext.Add(perChunk)
+	}
+	return written, nil
+}
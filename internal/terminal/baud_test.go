@@ -0,0 +1,51 @@
+package terminal
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type bufConn struct{ bytes.Buffer }
+
+func (c *bufConn) Close() error { return nil }
+
+func TestBaudPacing(t *testing.T) {
+	conn := &bufConn{}
+	term := New(conn, 80, 24, false)
+	if err := term.SetBaud(1234); err == nil {
+		t.Fatal("SetBaud accepted an odd rate")
+	}
+	if err := term.SetBaud(9600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 9600 bps is 960 bytes a second, so 240 bytes take about 250ms.
+	data := bytes.Repeat([]byte("x"), 240)
+	start := time.Now()
+	if err := term.SendBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond || d > 2*time.Second {
+		t.Fatalf("240 bytes at 9600 bps took %v", d)
+	}
+	if conn.Len() != 240 {
+		t.Fatalf("wrote %d bytes", conn.Len())
+	}
+
+	start = time.Now()
+	_ = term.SendNow(string(data))
+	resume := term.SuspendBaud()
+	_ = term.SendBytes(data)
+	resume()
+	resume()
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("unpaced output took %v", d)
+	}
+
+	start = time.Now()
+	_ = term.SendBytes(data[:96])
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Fatalf("pacing not back after resume: %v", d)
+	}
+}
@@ -0,0 +1,185 @@
+package terminal
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset is how a client encodes characters outside ASCII in what it
+// sends. Input is decoded to UTF-8, so what callers read and store is the
+// same whatever the caller's terminal.
+type Charset string
+
+const (
+	CharsetUTF8   Charset = "utf8"
+	CharsetCP437  Charset = "cp437"  // DOS terminals: SyncTERM, NetRunner, ...
+	CharsetLatin1 Charset = "latin1" // ISO-8859-1
+)
+
+// ParseCharset returns the charset named s, accepting the usual spellings
+// ("UTF-8", "IBM437", "ISO-8859-1", ...). ok is false for unknown names.
+func ParseCharset(s string) (Charset, bool) {
+	switch strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(s)) {
+	case "utf8", "":
+		return CharsetUTF8, true
+	case "cp437", "ibm437", "437", "dos", "ibmpc":
+		return CharsetCP437, true
+	case "latin1", "iso88591", "l1":
+		return CharsetLatin1, true
+	}
+	return "", false
+}
+
+// CP437 maps each CP437 byte to the glyph a DOS screen shows for it.
+var CP437 = []rune(" ☺☻♥♦♣♠•◘○◙♂♀♪♫☼►◄↕‼¶§▬↨↑↓→←∟↔▲▼" +
+	" !\"#$%&'()*+,-./0123456789:;<=>?" +
+	"@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_" +
+	"`abcdefghijklmnopqrstuvwxyz{|}~⌂" +
+	"ÇüéâäàåçêëèïîìÄÅÉæÆôöòûùÿÖÜ¢£¥₧ƒ" +
+	"áíóúñÑªº¿⌐¬½¼¡«»░▒▓│┤╡╢╖╕╣║╗╝╜╛┐" +
+	"└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀" +
+	"αßΓπΣσµτΦΘΩδ∞φε∩≡±≥≤⌠⌡÷≈°∙·√ⁿ²■\u00a0")
+
+// cp437Bytes is the reverse of CP437. Where two bytes show the same glyph
+// the lower one wins.
+var cp437Bytes = func() map[rune]byte {
+	m := make(map[rune]byte, 256)
+	for i := len(CP437) - 1; i >= 0; i-- {
+		m[CP437[i]] = byte(i)
+	}
+	return m
+}()
+
+// CP437Byte returns the CP437 byte a DOS screen shows as r. ok is false
+// when there is none. Bytes below 0x20 are control characters as well as
+// glyphs; callers writing to a live terminal should not send them.
+func CP437Byte(r rune) (b byte, ok bool) {
+	b, ok = cp437Bytes[r]
+	return b, ok
+}
+
+// DecodeText decodes text in the MIME charset named charset. Latin-1 and
+// Windows-1252 are converted; anything else that is not valid UTF-8 has
+// its bad bytes replaced.
+func DecodeText(b []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso8859-1", "latin1", "windows-1252", "cp1252":
+		if !utf8.Valid(b) {
+			r := make([]rune, len(b))
+			for i, c := range b {
+				r[i] = rune(c)
+			}
+			return string(r)
+		}
+	}
+	return strings.ToValidUTF8(string(b), "?")
+}
+
+// writeRune writes r to b in charset c, or '?' when c has no such
+// character.
+func (c Charset) writeRune(b *strings.Builder, r rune) {
+	switch {
+	case r < 0x80 || c == CharsetUTF8 || c == "":
+		b.WriteRune(r)
+	case c == CharsetCP437:
+		if x, ok := CP437Byte(r); ok && x >= 0x80 {
+			b.WriteByte(x)
+			return
+		}
+		b.WriteByte('?')
+	case c == CharsetLatin1 && r >= 0xa0 && r <= 0xff:
+		b.WriteByte(byte(r))
+	default:
+		b.WriteByte('?')
+	}
+}
+
+// SetCharset sets how the client encodes its input; see ReadChar.
+func (t *Terminal) SetCharset(c Charset) {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	t.charset = c
+}
+
+// ChosenCharset returns the charset set with SetCharset, and false when
+// none was: the caller never said, and Charset's UTF-8 is only a guess.
+func (t *Terminal) ChosenCharset() (Charset, bool) {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	return t.charset, t.charset != ""
+}
+
+// Charset returns the charset set with SetCharset, UTF-8 by default.
+func (t *Terminal) Charset() Charset {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	if t.charset == "" {
+		return CharsetUTF8
+	}
+	return t.charset
+}
+
+// ReadChar reads one character in the terminal's charset and returns it
+// with the bytes it was sent as, for echoing back unchanged. ASCII,
+// including control characters, comes back as it is. r is -1 for bytes
+// that do not decode: a broken UTF-8 sequence or a Latin-1 C1 control.
+func (t *Terminal) ReadChar() (r rune, raw []byte, err error) {
+	b, err := t.ReadByte()
+	if err != nil {
+		return -1, nil, err
+	}
+	return t.decodeChar(b)
+}
+
+// decodeChar finishes reading the character that starts with b.
+func (t *Terminal) decodeChar(b byte) (r rune, raw []byte, err error) {
+	raw = []byte{b}
+	if b < 0x80 {
+		return rune(b), raw, nil
+	}
+	switch t.Charset() {
+	case CharsetCP437:
+		return CP437[b], raw, nil
+	case CharsetLatin1:
+		if b < 0xa0 {
+			return -1, raw, nil
+		}
+		return rune(b), raw, nil
+	}
+
+	n := 0
+	switch {
+	case b&0xe0 == 0xc0:
+		n = 2
+	case b&0xf0 == 0xe0:
+		n = 3
+	case b&0xf8 == 0xf0:
+		n = 4
+	default:
+		return -1, raw, nil // a stray continuation byte
+	}
+	for len(raw) < n {
+		c, err := t.ReadByte()
+		if err != nil {
+			return -1, raw, err
+		}
+		if c&0xc0 != 0x80 {
+			// The sequence broke off; c starts the next character.
+			t.unread(c)
+			return -1, raw, nil
+		}
+		raw = append(raw, c)
+	}
+	r, _ = utf8.DecodeRune(raw)
+	if r == utf8.RuneError {
+		return -1, raw, nil
+	}
+	return r, raw, nil
+}
+
+// unread puts b back at the front of the input.
+func (t *Terminal) unread(b byte) {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	t.injected = append([]byte{b}, t.injected...)
+}
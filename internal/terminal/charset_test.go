@@ -0,0 +1,97 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// typedConn reads from in and collects what is written in out.
+type typedConn struct {
+	in  *strings.Reader
+	out bytes.Buffer
+}
+
+func (c *typedConn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *typedConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+func (c *typedConn) Close() error                { return nil }
+
+func TestGetLineCharsets(t *testing.T) {
+	tests := []struct {
+		charset Charset
+		typed   string
+		want    string
+	}{
+		{CharsetUTF8, "caf\xc3\xa9 \xe2\x95\x94\r", "café ╔"},
+		{CharsetCP437, "caf\x82 \xc9\r", "café ╔"},
+		{CharsetLatin1, "caf\xe9 \x85x\r", "café x"}, // 0x85 is a C1 control
+		// Backspace takes off a whole character.
+		{CharsetUTF8, "nai\xc3\xaf\x08\x08\xc3\xafve\r", "naïve"},
+		// A broken sequence is dropped without eating the next key.
+		{CharsetUTF8, "a\xc3b\xa9c\r", "abc"},
+		// Beyond maxLen (6) characters are ignored, not bytes.
+		{CharsetUTF8, "ééééééé\r", "éééééé"},
+	}
+	for _, tt := range tests {
+		conn := &typedConn{in: strings.NewReader(tt.typed)}
+		term := New(conn, 80, 24, true)
+		term.SetCharset(tt.charset)
+		got, err := term.GetLine(6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.charset, tt.typed, got, tt.want)
+		}
+	}
+}
+
+func TestGetLineEchoesRawBytes(t *testing.T) {
+	conn := &typedConn{in: strings.NewReader("\x82\r")}
+	term := New(conn, 80, 24, true)
+	term.SetCharset(CharsetCP437)
+	if got, _ := term.GetLine(10); got != "é" {
+		t.Fatalf("got %q", got)
+	}
+	if out := conn.out.String(); out != "\x82\r\n" {
+		t.Errorf("echo = %q", out)
+	}
+}
+
+func TestScreenEncodesForCharset(t *testing.T) {
+	conn := &bufConn{}
+	term := New(conn, 80, 24, true)
+	term.SetCharset(CharsetCP437)
+	s := NewScreen(term, 1, 10)
+	s.Print(1, 1, 4, "é╔€☺", DefaultAttr) // ☺ is CP437 0x01, a control byte
+	s.Flush()
+	if out := conn.String(); !strings.Contains(out, "\x82\xc9??") {
+		t.Errorf("flush = %q", out)
+	}
+}
+
+func TestParseCharset(t *testing.T) {
+	for name, want := range map[string]Charset{
+		"UTF-8": CharsetUTF8, "IBM437": CharsetCP437, "cp437": CharsetCP437,
+		"ISO-8859-1": CharsetLatin1, "latin1": CharsetLatin1,
+	} {
+		if got, ok := ParseCharset(name); !ok || got != want {
+			t.Errorf("ParseCharset(%q) = %q, %v", name, got, ok)
+		}
+	}
+	if _, ok := ParseCharset("ebcdic"); ok {
+		t.Error("ParseCharset accepted ebcdic")
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	if got := DecodeText([]byte("caf\xe9"), "ISO-8859-1"); got != "café" {
+		t.Errorf("latin1 = %q", got)
+	}
+	if got := DecodeText([]byte("café"), "latin1"); got != "café" {
+		t.Errorf("utf8 labelled latin1 = %q", got)
+	}
+	if got := DecodeText([]byte("caf\xe9"), "utf-8"); got != "caf?" {
+		t.Errorf("bad utf8 = %q", got)
+	}
+}
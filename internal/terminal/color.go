@@ -0,0 +1,290 @@
+package terminal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ColorDepth is how many colors a client can show.
+type ColorDepth string
+
+const (
+	ColorsAuto ColorDepth = "auto"      // what the connection advertised
+	Colors16   ColorDepth = "16"        // the classic ANSI colors
+	Colors256  ColorDepth = "256"       // the xterm 256-color palette
+	ColorsTrue ColorDepth = "truecolor" // 24-bit RGB
+)
+
+// ParseColorDepth returns the color depth named s, accepting the usual
+// spellings ("24bit", "8bit", "ansi", ...). ok is false for unknown names.
+func ParseColorDepth(s string) (ColorDepth, bool) {
+	switch strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(s)) {
+	case "auto", "":
+		return ColorsAuto, true
+	case "16", "ansi", "4bit", "classic":
+		return Colors16, true
+	case "256", "8bit", "xterm256":
+		return Colors256, true
+	case "truecolor", "true", "24bit", "rgb", "direct":
+		return ColorsTrue, true
+	}
+	return "", false
+}
+
+// DetectColorDepth guesses a client's colors from its terminal type (the
+// telnet TTYPE or the SSH pty TERM) and, for SSH, the COLORTERM variable
+// the client sent. Without either hint it is Colors16, which every ANSI
+// client shows.
+func DetectColorDepth(termType, colorTerm string) ColorDepth {
+	switch strings.ToLower(colorTerm) {
+	case "truecolor", "24bit":
+		return ColorsTrue
+	}
+	tt := strings.ToLower(termType)
+	switch {
+	case strings.Contains(tt, "truecolor"), strings.Contains(tt, "24bit"), strings.HasSuffix(tt, "-direct"):
+		return ColorsTrue
+	case strings.Contains(tt, "256"):
+		return Colors256
+	}
+	return Colors16
+}
+
+// rank orders depths from fewest to most colors.
+func (d ColorDepth) rank() int {
+	switch d {
+	case Colors256:
+		return 1
+	case ColorsTrue:
+		return 2
+	}
+	return 0
+}
+
+// SetColorDepth sets the colors the caller asked for; ColorsAuto goes by
+// TermColors.
+func (t *Terminal) SetColorDepth(d ColorDepth) {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	t.colors = d
+}
+
+// ColorDepth returns the colors the terminal shows: the depth set with
+// SetColorDepth, else TermColors, else Colors16.
+func (t *Terminal) ColorDepth() ColorDepth {
+	t.tapMu.Lock()
+	d := t.colors
+	t.tapMu.Unlock()
+	if d == "" || d == ColorsAuto {
+		d = t.TermColors
+	}
+	if d == "" || d == ColorsAuto {
+		return Colors16
+	}
+	return d
+}
+
+// Color256 returns the SGR sequence for foreground color n (0-255) of the
+// xterm palette.
+func Color256(fg int) string {
+	return fmt.Sprintf("\033[38;5;%dm", clampByte(fg))
+}
+
+// ColorRGB returns the SGR sequence for a 24-bit foreground color.
+func ColorRGB(r, g, b int) string {
+	return fmt.Sprintf("\033[38;2;%d;%d;%dm", clampByte(r), clampByte(g), clampByte(b))
+}
+
+// SetColor256 sets xterm palette colors fg and bg (-1 leaves one as it
+// is), as the nearest of the 16 ANSI colors on clients that lack them.
+func (t *Terminal) SetColor256(fg, bg int) error {
+	if !t.ANSIEnabled {
+		return nil
+	}
+	var params []string
+	if fg >= 0 {
+		params = append(params, "38", "5", strconv.Itoa(clampByte(fg)))
+	}
+	if bg >= 0 {
+		params = append(params, "48", "5", strconv.Itoa(clampByte(bg)))
+	}
+	if params == nil {
+		return nil
+	}
+	return t.Send(Downgrade("\033["+strings.Join(params, ";")+"m", t.ColorDepth()))
+}
+
+// SetColorRGB sets a 24-bit foreground color, or background with
+// background set, as the nearest color the client has.
+func (t *Terminal) SetColorRGB(r, g, b int, background bool) error {
+	if !t.ANSIEnabled {
+		return nil
+	}
+	seq := ColorRGB(r, g, b)
+	if background {
+		seq = strings.Replace(seq, "[38;", "[48;", 1)
+	}
+	return t.Send(Downgrade(seq, t.ColorDepth()))
+}
+
+// Downgrade rewrites the 256-color and 24-bit colors in the SGR sequences
+// of s to the nearest colors a client of depth d has: 24-bit to the 256
+// palette, either to the 16 ANSI colors. Other text passes unchanged.
+func Downgrade(s string, d ColorDepth) string {
+	if d == ColorsTrue || !strings.Contains(s, "\033[") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "\033[")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		end := 2
+		for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == ';') {
+			end++
+		}
+		if end == len(s) || s[end] != 'm' {
+			b.WriteString(s[:2])
+			s = s[2:]
+			continue
+		}
+		b.WriteString(downgradeSGR(s[2:end], d))
+		s = s[end+1:]
+	}
+}
+
+// downgradeSGR returns the SGR sequence with parameters params for a
+// client of depth d.
+func downgradeSGR(params string, d ColorDepth) string {
+	ps := strings.Split(params, ";")
+	out := make([]string, 0, len(ps))
+	num := func(i int) int {
+		if i >= len(ps) {
+			return 0
+		}
+		n, _ := strconv.Atoi(ps[i])
+		return clampByte(n)
+	}
+	for i := 0; i < len(ps); i++ {
+		if (ps[i] != "38" && ps[i] != "48") || i+1 >= len(ps) {
+			out = append(out, ps[i])
+			continue
+		}
+		key, bg := ps[i], ps[i] == "48"
+		var r, g, bl, n int
+		switch ps[i+1] {
+		case "5":
+			n = num(i + 2)
+			r, g, bl = rgb256(n)
+			i += 2
+		case "2":
+			r, g, bl = num(i+2), num(i+3), num(i+4)
+			n = -1
+			i += 4
+		default:
+			out = append(out, ps[i])
+			continue
+		}
+		switch {
+		case d.rank() >= Colors256.rank():
+			if n < 0 {
+				n = Nearest256(r, g, bl)
+			}
+			out = append(out, key, "5", strconv.Itoa(n))
+		default:
+			c := n
+			if c < 0 || c >= 16 {
+				c = Nearest16(r, g, bl)
+			}
+			out = append(out, sgr16(c, bg)...)
+		}
+	}
+	return "\033[" + strings.Join(out, ";") + "m"
+}
+
+// sgr16 returns the SGR parameters for color c (0-15) of the 16 ANSI
+// colors. Bright foregrounds are bold; backgrounds have no bright half.
+func sgr16(c int, background bool) []string {
+	if background {
+		return []string{strconv.Itoa(40 + c&7)}
+	}
+	if c >= 8 {
+		return []string{"1", strconv.Itoa(30 + c&7)}
+	}
+	return []string{"22", strconv.Itoa(30 + c)}
+}
+
+// cubeLevels are the channel values of the xterm 6x6x6 color cube.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// rgb256 returns the RGB value of color n of the xterm palette, taking
+// the first 16 to be the CGA colors.
+func rgb256(n int) (r, g, b int) {
+	switch {
+	case n < 16:
+		c := cga[n]
+		return int(c[0]), int(c[1]), int(c[2])
+	case n < 232:
+		n -= 16
+		return cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]
+	}
+	v := 8 + (n-232)*10
+	return v, v, v
+}
+
+// Nearest256 returns the color of the xterm palette closest to r, g, b,
+// from the color cube or the gray ramp.
+func Nearest256(r, g, b int) int {
+	level := func(v int) int {
+		best := 0
+		for i, l := range cubeLevels {
+			if abs(v-l) < abs(v-cubeLevels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	cube := 16 + 36*level(r) + 6*level(g) + level(b)
+	gray := 232 + min(max((r+g+b)/3-8+5, 0)/10, 23)
+	cr, cg, cb := rgb256(cube)
+	gr, gg, gb := rgb256(gray)
+	if dist(r, g, b, gr, gg, gb) < dist(r, g, b, cr, cg, cb) {
+		return gray
+	}
+	return cube
+}
+
+// Nearest16 returns the one of the 16 ANSI colors, as a DOS terminal
+// shows them, closest to r, g, b.
+func Nearest16(r, g, b int) int {
+	best, bestDist := 0, -1
+	for i, c := range cga {
+		if d := dist(r, g, b, int(c[0]), int(c[1]), int(c[2])); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// dist is the squared distance between two colors, weighted for how the
+// eye sees red, green and blue.
+func dist(r1, g1, b1, r2, g2, b2 int) int {
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	return 3*dr*dr + 4*dg*dg + 2*db*db
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func clampByte(n int) int {
+	return min(max(n, 0), 255)
+}
@@ -0,0 +1,85 @@
+package terminal
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDetectColorDepth(t *testing.T) {
+	cases := []struct {
+		term, colorTerm string
+		want            ColorDepth
+	}{
+		{"ANSI", "", Colors16},
+		{"xterm", "", Colors16},
+		{"xterm-256color", "", Colors256},
+		{"tmux-256color", "truecolor", ColorsTrue},
+		{"xterm-direct", "", ColorsTrue},
+		{"", "24bit", ColorsTrue},
+	}
+	for _, c := range cases {
+		if got := DetectColorDepth(c.term, c.colorTerm); got != c.want {
+			t.Errorf("DetectColorDepth(%q, %q) = %q, want %q", c.term, c.colorTerm, got, c.want)
+		}
+	}
+	if d, ok := ParseColorDepth("24-bit"); !ok || d != ColorsTrue {
+		t.Errorf("ParseColorDepth = %q, %v", d, ok)
+	}
+	if _, ok := ParseColorDepth("65536"); ok {
+		t.Error("unknown depth accepted")
+	}
+}
+
+func TestDowngrade(t *testing.T) {
+	cases := []struct {
+		in    string
+		depth ColorDepth
+		want  string
+	}{
+		{"\033[38;2;1;2;3mhi", ColorsTrue, "\033[38;2;1;2;3mhi"},
+		{"\033[38;2;255;0;0mhi", Colors256, "\033[38;5;196mhi"},
+		{"\033[48;2;128;128;128m", Colors256, "\033[48;5;244m"},
+		{"\033[38;5;196m", Colors256, "\033[38;5;196m"},
+		{"\033[38;5;203m", Colors16, "\033[1;31m"},      // bright red
+		{"\033[38;5;4m", Colors16, "\033[22;34m"},       // the first 16 map as they are
+		{"\033[0;48;5;21;1m", Colors16, "\033[0;44;1m"}, // other parameters stay
+		{"a\033[2Jb\033[38;2;0;0;0mc", Colors16, "a\033[2Jb\033[22;30mc"},
+	}
+	for _, c := range cases {
+		if got := Downgrade(c.in, c.depth); got != c.want {
+			t.Errorf("Downgrade(%q, %s) = %q, want %q", c.in, c.depth, got, c.want)
+		}
+	}
+	for n := 16; n < 256; n++ {
+		if got := Nearest256(rgb256(n)); got != n {
+			t.Errorf("Nearest256 of color %d = %d", n, got)
+		}
+	}
+}
+
+func TestSetColor256(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	term := New(server, 80, 24, true)
+	read := func() string {
+		buf := make([]byte, 64)
+		n, _ := client.Read(buf)
+		return string(buf[:n])
+	}
+
+	go term.SetColor256(208, -1)
+	if got := read(); got != "\033[1;31m" {
+		t.Errorf("classic client got %q", got)
+	}
+	term.TermColors = Colors256
+	go term.SetColor256(208, 17)
+	if got := read(); got != "\033[38;5;208;48;5;17m" {
+		t.Errorf("256-color client got %q", got)
+	}
+	term.SetColorDepth(Colors16)
+	go term.SetColorRGB(0, 0, 170, true)
+	if got := read(); got != "\033[44m" {
+		t.Errorf("caller's choice ignored: %q", got)
+	}
+}
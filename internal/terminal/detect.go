@@ -0,0 +1,163 @@
+package terminal
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultProbeTimeout is how long Detect waits for the client to answer.
+const DefaultProbeTimeout = 750 * time.Millisecond
+
+// daGrace is how much longer Detect waits for a device attributes reply
+// once the cursor position report has arrived. Many clients never answer
+// it, so the full timeout is not spent waiting for it.
+const daGrace = 75 * time.Millisecond
+
+// probeSeq saves the cursor, moves it as far down and right as the screen
+// allows, asks for the cursor position (ESC[6n), restores the cursor,
+// asks for the background color (OSC 11, which xterm-like clients answer)
+// and last for the device attributes (ESC[c), which more clients answer
+// than the older ESC Z, SyncTERM among them. Replies come in order, so
+// the device attributes reply ends the probe.
+const probeSeq = "\x1b7\x1b[999;999H\x1b[6n\x1b8\x1b]11;?\x1b\\\x1b[c"
+
+var (
+	cprReply = regexp.MustCompile(`\x1b\[(\d+);(\d+)R`)
+	daReply  = regexp.MustCompile(`\x1b\[[?=][0-9;]*c|\x1b/Z`)
+	bgReply  = regexp.MustCompile(`\x1b\]11;rgb:([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})(?:\x07|\x1b\\)`)
+)
+
+// Probe is what Detect learned about the client terminal.
+type Probe struct {
+	Ran      bool // false when the connection cannot time out reads
+	ANSI     bool // the client answered the cursor position report
+	Width    int  // screen size from the report, 0 if unknown
+	Height   int
+	Identity string // raw device attributes reply, if any
+
+	// Background is how light the client's background color is, from
+	// its answer to OSC 11; unknown when it did not answer.
+	Background Background
+}
+
+// cTermID starts the device attributes reply of CTerm, the terminal in
+// SyncTERM and clients built on it ("CTerm" in decimal).
+const cTermID = "\x1b[=67;84;101;114;109"
+
+// CTerm reports whether the client identified as SyncTERM's CTerm, which
+// understands its font selection and iCE color sequences.
+func (p Probe) CTerm() bool {
+	return strings.HasPrefix(p.Identity, cTermID)
+}
+
+// Detect probes the client for ANSI support and screen size by sending a
+// cursor position report request and a device attributes request and
+// waiting up to timeout for the answers. It should run before any menu is shown.
+//
+// A client that answers has ANSIEnabled set; one that stays silent keeps
+// the existing setting (from TTYPE), since a slow link looks the same. The
+// reported size is used unless the client already sent one (telnet NAWS).
+// Anything else the caller typed meanwhile is kept for the next read. The
+// result is stored in Probe.
+func (t *Terminal) Detect(timeout time.Duration) Probe {
+	if _, ok := t.rwc.(readDeadliner); !ok {
+		return t.Probe
+	}
+	if err := t.Send(probeSeq); err != nil {
+		return t.Probe
+	}
+
+	var buf []byte
+	deadline := time.Now().Add(timeout)
+	t.SetReadDeadline(deadline)
+	chunk := make([]byte, 64)
+	for {
+		n, err := t.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if cprReply.Match(buf) {
+			if daReply.Match(buf) {
+				break
+			}
+			if grace := time.Now().Add(daGrace); grace.Before(deadline) {
+				deadline = grace
+				t.SetReadDeadline(deadline)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	t.SetReadDeadline(time.Time{})
+
+	p := parseProbe(buf)
+	t.keepInput(stripReplies(buf))
+	t.applyResize()
+
+	if p.ANSI {
+		t.ANSIEnabled = true
+		if !t.sizeReported && p.Width > 0 && p.Height > 0 {
+			t.Width, t.Height = p.Width, p.Height
+		}
+	} else {
+		// Wipe the probe text a dumb terminal printed literally.
+		t.Send("\r" + strings.Repeat(" ", 30) + "\r")
+	}
+	t.Probe = p
+	return p
+}
+
+// parseProbe extracts the replies to probeSeq from what the client sent.
+func parseProbe(buf []byte) Probe {
+	p := Probe{Ran: true}
+	if m := cprReply.FindSubmatch(buf); m != nil {
+		p.ANSI = true
+		row, _ := strconv.Atoi(string(m[1]))
+		col, _ := strconv.Atoi(string(m[2]))
+		// A terminal that ignored the cursor move reports 1;1 or so;
+		// treat implausibly small sizes as unknown.
+		if row >= 10 && col >= 20 {
+			p.Width, p.Height = col, row
+		}
+	}
+	if m := daReply.Find(buf); m != nil {
+		p.Identity = string(m)
+	}
+	if m := bgReply.FindSubmatch(buf); m != nil {
+		p.Background = BackgroundDark
+		if rgbLuminance(m[1], m[2], m[3]) > 0.5 {
+			p.Background = BackgroundLight
+		}
+	}
+	return p
+}
+
+// rgbLuminance returns the relative luminance of an X11 color whose
+// channels are 1 to 4 hex digits each.
+func rgbLuminance(r, g, b []byte) float64 {
+	channel := func(h []byte) float64 {
+		v, _ := strconv.ParseUint(string(h), 16, 16)
+		return float64(v) / float64(uint64(1)<<(4*len(h))-1)
+	}
+	return 0.2126*channel(r) + 0.7152*channel(g) + 0.0722*channel(b)
+}
+
+// stripReplies removes probe replies, leaving any keys the caller typed.
+func stripReplies(buf []byte) []byte {
+	buf = cprReply.ReplaceAll(buf, nil)
+	buf = bgReply.ReplaceAll(buf, nil)
+	return daReply.ReplaceAll(buf, nil)
+}
+
+// keepInput queues bytes read during the probe for the next Read.
+func (t *Terminal) keepInput(p []byte) {
+	p = bytes.TrimLeft(p, "\x00")
+	if len(p) == 0 {
+		return
+	}
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	t.injected = append(p, t.injected...)
+}
@@ -0,0 +1,89 @@
+package terminal
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDetectReadsSizeAndKeepsTypedInput(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	term := New(server, 80, 24, false)
+	go func() {
+		buf := make([]byte, 64)
+		client.Read(buf) // the probe
+		client.Write([]byte("x\x1b[50;132R\x1b[?1;2c"))
+		for {
+			if _, err := client.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	p := term.Detect(time.Second)
+	if !p.ANSI || !term.ANSIEnabled {
+		t.Fatalf("probe = %+v, want ANSI", p)
+	}
+	if term.Width != 132 || term.Height != 50 {
+		t.Fatalf("size = %dx%d, want 132x50", term.Width, term.Height)
+	}
+	if p.Identity != "\x1b[?1;2c" {
+		t.Fatalf("identity = %q", p.Identity)
+	}
+	if key, err := term.GetKey(); err != nil || key != 'x' {
+		t.Fatalf("typed key = %q, %v", key, err)
+	}
+}
+
+func TestDetectSilentClientKeepsSettings(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			if _, err := client.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	term := New(server, 80, 24, true)
+	start := time.Now()
+	p := term.Detect(50 * time.Millisecond)
+	if p.ANSI || !p.Ran {
+		t.Fatalf("probe = %+v", p)
+	}
+	if !term.ANSIEnabled || term.Width != 80 {
+		t.Fatal("silent client changed terminal settings")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("probe did not time out")
+	}
+}
+
+func TestParseProbeIgnoresUnmovedCursor(t *testing.T) {
+	p := parseProbe([]byte("\x1b[1;1R"))
+	if !p.ANSI || p.Width != 0 || p.Height != 0 {
+		t.Fatalf("probe = %+v", p)
+	}
+}
+
+func TestParseProbeBackground(t *testing.T) {
+	for reply, want := range map[string]Background{
+		"\x1b[24;80R\x1b]11;rgb:ffff/ffff/dddd\x1b\\\x1b[?1;2c": BackgroundLight,
+		"\x1b[24;80R\x1b]11;rgb:1e/1e/2e\x07\x1b[?1;2c":         BackgroundDark,
+		"\x1b[24;80R\x1b[?1;2c":                                 BackgroundUnknown,
+	} {
+		if p := parseProbe([]byte(reply)); p.Background != want {
+			t.Errorf("background from %q = %q, want %q", reply, p.Background, want)
+		}
+		if rest := stripReplies([]byte("a" + reply + "b")); string(rest) != "ab" {
+			t.Errorf("left after stripping %q: %q", reply, rest)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package terminal
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInputTimeout is returned when the caller typed nothing in time.
+var ErrInputTimeout = errors.New("input timed out")
+
+// GetKeyTimeout waits up to d for a keypress, returning ErrInputTimeout
+// when none came. See PollKey for connections without read deadlines.
+func (t *Terminal) GetKeyTimeout(d time.Duration) (byte, error) {
+	b, ok, err := t.PollKey(d)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrInputTimeout
+	}
+	return b, nil
+}
+
+// GetLineTimeout is GetLine giving up when the caller goes d without
+// typing. The wait starts over with each key, so a slow typist is not cut
+// off. On timeout it returns what was typed so far with ErrInputTimeout.
+func (t *Terminal) GetLineTimeout(maxLen int, d time.Duration) (string, error) {
+	return t.getLine(maxLen, func() (rune, []byte, error) {
+		b, err := t.GetKeyTimeout(d)
+		if err != nil {
+			return -1, nil, err
+		}
+		return t.decodeChar(b)
+	})
+}
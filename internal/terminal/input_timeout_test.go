@@ -0,0 +1,60 @@
+package terminal
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGetKeyTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+	term := New(server, 80, 24, false)
+
+	if _, err := term.GetKeyTimeout(20 * time.Millisecond); err != ErrInputTimeout {
+		t.Fatalf("no key: %v", err)
+	}
+	go client.Write([]byte{'x'})
+	if b, err := term.GetKeyTimeout(time.Second); err != nil || b != 'x' {
+		t.Fatalf("key = %q, %v", b, err)
+	}
+}
+
+func TestGetLineTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+	term := New(server, 80, 24, false)
+
+	// Keys keep coming more often than the timeout, so the line completes
+	// although typing it takes longer than the timeout.
+	go func() {
+		for _, c := range []byte("hello\r") {
+			time.Sleep(30 * time.Millisecond)
+			client.Write([]byte{c})
+		}
+	}()
+	line, err := term.GetLineTimeout(20, 100*time.Millisecond)
+	if err != nil || line != "hello" {
+		t.Fatalf("line = %q, %v", line, err)
+	}
+
+	go client.Write([]byte("he"))
+	line, err = term.GetLineTimeout(20, 100*time.Millisecond)
+	if err != ErrInputTimeout || line != "he" {
+		t.Fatalf("idle line = %q, %v; want \"he\" and a timeout", line, err)
+	}
+
+	// The deadline is cleared afterwards.
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		client.Write([]byte{'k'})
+	}()
+	if b, err := term.GetKey(); err != nil || b != 'k' {
+		t.Fatalf("GetKey after timeout = %q, %v", b, err)
+	}
+}
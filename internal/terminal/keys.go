@@ -0,0 +1,141 @@
+package terminal
+
+import (
+	"io"
+	"time"
+)
+
+// Key names a key that ReadKey decoded. Printable input is KeyChar, with
+// the character alongside.
+type Key string
+
+const (
+	KeyChar      Key = "char"
+	KeyEnter     Key = "enter"
+	KeyBackspace Key = "backspace"
+	KeyTab       Key = "tab"
+	KeyEsc       Key = "esc"
+	KeyUp        Key = "up"
+	KeyDown      Key = "down"
+	KeyLeft      Key = "left"
+	KeyRight     Key = "right"
+	KeyHome      Key = "home"
+	KeyEnd       Key = "end"
+	KeyInsert    Key = "insert"
+	KeyDelete    Key = "delete"
+	KeyPgUp      Key = "pgup"
+	KeyPgDn      Key = "pgdn"
+	KeyF1        Key = "f1"
+	KeyF2        Key = "f2"
+	KeyF3        Key = "f3"
+	KeyF4        Key = "f4"
+	KeyF5        Key = "f5"
+	KeyF6        Key = "f6"
+	KeyF7        Key = "f7"
+	KeyF8        Key = "f8"
+	KeyF9        Key = "f9"
+	KeyF10       Key = "f10"
+	KeyF11       Key = "f11"
+	KeyF12       Key = "f12"
+	KeyCtrl      Key = "ctrl" // another control character, such as Ctrl-C
+)
+
+// EscTimeout is how long ReadKey waits after ESC for the rest of a
+// sequence before taking it as the Esc key.
+const EscTimeout = 100 * time.Millisecond
+
+// escKeys maps the part of a key sequence after ESC to its key. It covers
+// the xterm and VT220 forms (CSI), VT100 application mode (SS3) and the
+// Linux console's F1-F5 ("[[A").
+var escKeys = map[string]Key{
+	"[A": KeyUp, "[B": KeyDown, "[C": KeyRight, "[D": KeyLeft,
+	"OA": KeyUp, "OB": KeyDown, "OC": KeyRight, "OD": KeyLeft,
+	"[H": KeyHome, "[F": KeyEnd, "OH": KeyHome, "OF": KeyEnd,
+	"[1~": KeyHome, "[2~": KeyInsert, "[3~": KeyDelete, "[4~": KeyEnd,
+	"[5~": KeyPgUp, "[6~": KeyPgDn, "[7~": KeyHome, "[8~": KeyEnd,
+	"OP": KeyF1, "OQ": KeyF2, "OR": KeyF3, "OS": KeyF4,
+	"[11~": KeyF1, "[12~": KeyF2, "[13~": KeyF3, "[14~": KeyF4, "[15~": KeyF5,
+	"[17~": KeyF6, "[18~": KeyF7, "[19~": KeyF8, "[20~": KeyF9, "[21~": KeyF10,
+	"[23~": KeyF11, "[24~": KeyF12,
+	"[[A": KeyF1, "[[B": KeyF2, "[[C": KeyF3, "[[D": KeyF4, "[[E": KeyF5,
+}
+
+// ReadKey waits for a key and decodes it: cursor, editing and function
+// keys come back by name, anything printable as KeyChar with the
+// character in the terminal's charset. After ESC the rest of a sequence
+// must follow within EscTimeout; a lone ESC is KeyEsc. Sequences it does
+// not know, such as modified keys, are skipped.
+func (t *Terminal) ReadKey() (Key, rune, error) {
+	for {
+		r, _, err := t.ReadChar()
+		if err != nil {
+			return "", 0, err
+		}
+		switch {
+		case r == '\r' || r == '\n':
+			return KeyEnter, r, nil
+		case r == 8 || r == 127:
+			return KeyBackspace, r, nil
+		case r == '\t':
+			return KeyTab, r, nil
+		case r == 0x1b:
+			key, ok, err := t.readEscape()
+			if err != nil {
+				return "", 0, err
+			}
+			if ok {
+				return key, 0, nil
+			}
+		case r >= 0 && r < 32:
+			return KeyCtrl, r, nil
+		case r >= 32:
+			return KeyChar, r, nil
+		}
+	}
+}
+
+// readEscape decodes what follows ESC. It reports false for a sequence it
+// does not know, which the caller skips.
+func (t *Terminal) readEscape() (Key, bool, error) {
+	b, ok, err := t.nextInSequence()
+	if err != nil || !ok {
+		return KeyEsc, err == nil, err
+	}
+	if b != '[' && b != 'O' {
+		// Esc followed by another key (or Alt+key); keep the key.
+		t.unread(b)
+		return KeyEsc, true, nil
+	}
+
+	seq := []byte{b}
+	for len(seq) < 8 {
+		c, ok, err := t.nextInSequence()
+		if err != nil {
+			return "", false, err
+		}
+		if !ok {
+			return "", false, nil
+		}
+		seq = append(seq, c)
+		// The Linux console's "[[" is followed by one more letter.
+		if c >= 0x40 && c <= 0x7e && !(len(seq) == 2 && c == '[') {
+			break
+		}
+	}
+	key, ok := escKeys[string(seq)]
+	return key, ok, nil
+}
+
+// nextInSequence reads the next byte of a key sequence, reporting false
+// when none comes within EscTimeout. Connections without read deadlines
+// just block for it.
+func (t *Terminal) nextInSequence() (byte, bool, error) {
+	if _, ok := t.rwc.(readDeadliner); !ok {
+		b, err := t.ReadByte()
+		if err == io.EOF {
+			return 0, false, nil
+		}
+		return b, err == nil, err
+	}
+	return t.PollKey(EscTimeout)
+}
@@ -0,0 +1,64 @@
+package terminal
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadKey(t *testing.T) {
+	type key struct {
+		k Key
+		r rune
+	}
+	typed := "a\x1b[A\x1bOB\x1b[5~\x1b[6~\x1bOP\x1b[24~\x1b[[E\x1b[1;5C\x1b[3~" +
+		"\xc3\xa9\r\x7f\t\x03\x1bx"
+	want := []key{
+		{KeyChar, 'a'}, {KeyUp, 0}, {KeyDown, 0}, {KeyPgUp, 0}, {KeyPgDn, 0},
+		{KeyF1, 0}, {KeyF12, 0}, {KeyF5, 0},
+		// Ctrl+Right is not known and skipped.
+		{KeyDelete, 0},
+		{KeyChar, 'é'}, {KeyEnter, '\r'}, {KeyBackspace, 127}, {KeyTab, '\t'},
+		{KeyCtrl, 3},
+		// Esc before another key keeps that key.
+		{KeyEsc, 0}, {KeyChar, 'x'},
+	}
+	term := New(&typedConn{in: strings.NewReader(typed)}, 80, 24, true)
+	for i, w := range want {
+		k, r, err := term.ReadKey()
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+		if k != w.k || r != w.r {
+			t.Errorf("key %d = %s %q, want %s %q", i, k, r, w.k, w.r)
+		}
+	}
+	if _, _, err := term.ReadKey(); err != io.EOF {
+		t.Errorf("after the input: %v", err)
+	}
+}
+
+func TestReadKeyLoneEsc(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+	term := New(server, 80, 24, true)
+
+	go client.Write([]byte{0x1b})
+	if k, _, err := term.ReadKey(); err != nil || k != KeyEsc {
+		t.Fatalf("lone ESC = %s, %v", k, err)
+	}
+
+	// The rest of a sequence arriving within the timeout still decodes.
+	go func() {
+		client.Write([]byte{0x1b})
+		time.Sleep(EscTimeout / 4)
+		client.Write([]byte("[D"))
+	}()
+	if k, _, err := term.ReadKey(); err != nil || k != KeyLeft {
+		t.Fatalf("split sequence = %s, %v", k, err)
+	}
+}
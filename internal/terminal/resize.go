@@ -0,0 +1,89 @@
+package terminal
+
+// Resizer is implemented by connections that report window size changes
+// after the session has started (telnet NAWS, SSH window-change).
+type Resizer interface {
+	SetResizeHandler(fn func(width, height int))
+}
+
+// SizeProvider reports the live window size of a caller's terminal. Doors
+// and scripts use it rather than reading Width and Height, which only the
+// reading goroutine may touch.
+type SizeProvider interface {
+	Size() (width, height int)
+	WatchSize(fn func(width, height int)) (cancel func())
+}
+
+// Resize records a new window size. It may be called from any goroutine;
+// the size is applied to Width and Height by the goroutine reading from the
+// terminal, which then calls OnResize. A blocked read is interrupted so the
+// change is seen without waiting for a keypress. Size watchers are told at
+// once.
+func (t *Terminal) Resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	t.tapMu.Lock()
+	t.pendingSize = [2]int{width, height}
+	t.lastSize = t.pendingSize
+	t.interruptLocked()
+	watchers := make([]func(int, int), 0, len(t.watchers))
+	for _, fn := range t.watchers {
+		watchers = append(watchers, fn)
+	}
+	t.tapMu.Unlock()
+
+	for _, fn := range watchers {
+		fn(width, height)
+	}
+}
+
+// Size returns the caller's window size, including a change the reading
+// goroutine has not applied yet. It is safe to call from any goroutine.
+func (t *Terminal) Size() (width, height int) {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	if t.lastSize[0] > 0 {
+		return t.lastSize[0], t.lastSize[1]
+	}
+	return t.Width, t.Height
+}
+
+// WatchSize calls fn with every window size the client reports until
+// cancel is called. fn runs on the goroutine that received the report, so
+// it must not read from the terminal.
+func (t *Terminal) WatchSize(fn func(width, height int)) (cancel func()) {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	if t.watchers == nil {
+		t.watchers = make(map[int]func(int, int))
+	}
+	id := t.nextWatcher
+	t.nextWatcher++
+	t.watchers[id] = fn
+	return func() {
+		t.tapMu.Lock()
+		defer t.tapMu.Unlock()
+		delete(t.watchers, id)
+	}
+}
+
+// applyResize applies a pending size change. It runs on the reading goroutine.
+func (t *Terminal) applyResize() {
+	t.tapMu.Lock()
+	size := t.pendingSize
+	t.pendingSize = [2]int{}
+	t.tapMu.Unlock()
+
+	if size[0] == 0 {
+		return
+	}
+	t.sizeReported = true
+	if size[0] == t.Width && size[1] == t.Height {
+		return
+	}
+	t.Width, t.Height = size[0], size[1]
+	if t.OnResize != nil {
+		t.OnResize(t.Width, t.Height)
+	}
+}
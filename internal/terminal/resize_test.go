@@ -0,0 +1,75 @@
+package terminal
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestResizeInterruptsBlockedRead(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	term := New(server, 80, 24, true)
+	resized := make(chan [2]int, 1)
+	term.OnResize = func(w, h int) { resized <- [2]int{w, h} }
+
+	keyCh := make(chan byte, 1)
+	go func() {
+		key, err := term.GetKey()
+		if err == nil {
+			keyCh <- key
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	term.Resize(132, 50)
+
+	select {
+	case size := <-resized:
+		if size != [2]int{132, 50} {
+			t.Fatalf("OnResize got %v", size)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked read was not interrupted by Resize")
+	}
+	if term.Width != 132 || term.Height != 50 {
+		t.Fatalf("size = %dx%d, want 132x50", term.Width, term.Height)
+	}
+
+	// The read keeps waiting for real input afterwards.
+	client.Write([]byte("x"))
+	select {
+	case key := <-keyCh:
+		if key != 'x' {
+			t.Fatalf("got key %q", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read did not resume after resize")
+	}
+}
+
+func TestSizeAndWatchers(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	term := New(server, 80, 24, true)
+	if w, h := term.Size(); w != 80 || h != 24 {
+		t.Fatalf("Size = %dx%d, want 80x24", w, h)
+	}
+
+	var seen [][2]int
+	cancel := term.WatchSize(func(w, h int) { seen = append(seen, [2]int{w, h}) })
+	term.Resize(100, 30)
+	// Size reflects the report before any read applies it.
+	if w, h := term.Size(); w != 100 || h != 30 {
+		t.Fatalf("Size = %dx%d, want 100x30", w, h)
+	}
+	cancel()
+	term.Resize(120, 40)
+	if len(seen) != 1 || seen[0] != [2]int{100, 30} {
+		t.Fatalf("watcher saw %v", seen)
+	}
+}
@@ -0,0 +1,331 @@
+package terminal
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoANSI is returned by Screen.Flush on terminals without ANSI, which
+// cannot position the cursor.
+var ErrNoANSI = errors.New("terminal has no ANSI support")
+
+// Attr is the colors of a screen cell: ANSI color numbers 0-7 (as passed
+// to Color, less 30 and 40) and bold for the bright foreground. BG may
+// also be BgDefault.
+type Attr struct {
+	FG, BG uint8
+	Bold   bool
+}
+
+// DefaultAttr is gray on black.
+var DefaultAttr = Attr{FG: 7}
+
+// Cell is one character position on a Screen.
+type Cell struct {
+	Ch   rune // 0 = not drawn by the Screen; left as it is on the terminal
+	Attr Attr
+}
+
+// unknown marks a cell whose content on the terminal is not known, so the
+// next Flush writes it whatever it holds.
+const unknown rune = -1
+
+// Screen is an off-screen buffer of rows × cols cells in front of a
+// Terminal. Drawing changes only the buffer; Flush sends the cells that
+// differ from what the terminal shows, in one write and with the cursor
+// hidden, so regions can be redrawn without clearing them first and
+// without flicker. Cells the Screen never drew are left alone, so it can
+// manage the fields of a template painted by other means. Characters are
+// sent in the terminal's charset, '?' standing in for any it lacks.
+//
+// A Screen is safe for use by several goroutines. Drawing and flushing
+// under one lock keeps output from async writers (say, chat messages
+// arriving while the caller types) from interleaving, as long as they all
+// go through the Screen.
+type Screen struct {
+	mu    sync.Mutex
+	term  *Terminal
+	rows  int
+	cols  int
+	cells []Cell // what the terminal should show
+	shown []Cell // what it shows, as far as the Screen knows
+
+	// cursor is where Flush leaves the cursor, 1-based; zero = wherever
+	// the last write ended
+	cursorRow, cursorCol int
+	cursorShown          [2]int // where the last Flush left it
+}
+
+// NewScreen returns an empty Screen covering rows × cols of term from the
+// top-left corner.
+func NewScreen(term *Terminal, rows, cols int) *Screen {
+	s := &Screen{term: term}
+	s.resize(rows, cols)
+	return s
+}
+
+func (s *Screen) resize(rows, cols int) {
+	s.rows, s.cols = max(rows, 0), max(cols, 0)
+	s.cells = make([]Cell, s.rows*s.cols)
+	s.shown = make([]Cell, s.rows*s.cols)
+	for i := range s.shown {
+		s.shown[i].Ch = unknown
+	}
+}
+
+// Resize changes the size of the Screen. The buffer is emptied and the
+// terminal treated as unknown, so the caller redraws everything.
+func (s *Screen) Resize(rows, cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resize(rows, cols)
+}
+
+// Size returns the size of the Screen.
+func (s *Screen) Size() (rows, cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rows, s.cols
+}
+
+// index returns the offset of the 1-based row and col, or -1 when they
+// are off the Screen.
+func (s *Screen) index(row, col int) int {
+	if row < 1 || col < 1 || row > s.rows || col > s.cols {
+		return -1
+	}
+	return (row-1)*s.cols + col - 1
+}
+
+// Set puts ch at row, col (1-based, like GotoXY). Control characters are
+// drawn as spaces.
+func (s *Screen) Set(row, col int, ch rune, attr Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(row, col, ch, attr)
+}
+
+func (s *Screen) set(row, col int, ch rune, attr Attr) {
+	if i := s.index(row, col); i >= 0 {
+		if ch < ' ' || ch == 0x7f {
+			ch = ' '
+		}
+		s.cells[i] = Cell{Ch: ch, Attr: attr}
+	}
+}
+
+// Cell returns the buffered cell at row, col.
+func (s *Screen) Cell(row, col int) Cell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.index(row, col); i >= 0 {
+		return s.cells[i]
+	}
+	return Cell{}
+}
+
+// Print writes text from row, col to the right, clipped at width cells
+// (or the edge of the Screen when width <= 0), and pads the rest of the
+// width with spaces. It returns the number of cells the text took.
+func (s *Screen) Print(row, col, width int, text string, attr Attr) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.print(row, col, width, text, attr)
+}
+
+func (s *Screen) print(row, col, width int, text string, attr Attr) int {
+	if width <= 0 {
+		width = s.cols - col + 1
+	}
+	n := 0
+	for _, r := range text {
+		if n == width {
+			break
+		}
+		s.set(row, col+n, r, attr)
+		n++
+	}
+	for i := n; i < width; i++ {
+		s.set(row, col+i, ' ', attr)
+	}
+	return n
+}
+
+// PrintBox writes the lines of text into a box of height rows and width
+// columns at row, col, clipping each line and blanking the rest of the
+// box.
+func (s *Screen) PrintBox(row, col, height, width int, text string, attr Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.printBox(row, col, height, width, text, attr)
+}
+
+func (s *Screen) printBox(row, col, height, width int, text string, attr Attr) {
+	lines := strings.Split(text, "\n")
+	for i := 0; i < height; i++ {
+		line := ""
+		if i < len(lines) {
+			line = strings.TrimRight(lines[i], "\r")
+		}
+		s.print(row+i, col, width, line, attr)
+	}
+}
+
+// Fill sets a box of height rows and width columns at row, col to ch.
+func (s *Screen) Fill(row, col, height, width int, ch rune, attr Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for r := row; r < row+height; r++ {
+		for c := col; c < col+width; c++ {
+			s.set(r, c, ch, attr)
+		}
+	}
+}
+
+// SetCursor sets where Flush leaves the cursor, e.g. in an input field.
+// Zero row and col leave it after the last cell written.
+func (s *Screen) SetCursor(row, col int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursorRow, s.cursorCol = row, col
+}
+
+// Invalidate forgets what the terminal shows, so the next Flush rewrites
+// every cell the Screen has drawn. Call it after something else wrote
+// over them, e.g. a full-screen clear.
+func (s *Screen) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.shown {
+		s.shown[i].Ch = unknown
+	}
+	s.cursorShown = [2]int{}
+}
+
+// Update runs fn with the lock held and then flushes, so a redraw made of
+// several steps reaches the terminal as one. fn must only use the
+// Locked methods of the Screen it is given.
+func (s *Screen) Update(fn func(l *Locked)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&Locked{s})
+	return s.flush()
+}
+
+// Locked is a Screen whose lock is held, passed to Update.
+type Locked struct{ s *Screen }
+
+// Print is Screen.Print without locking.
+func (l *Locked) Print(row, col, width int, text string, attr Attr) int {
+	return l.s.print(row, col, width, text, attr)
+}
+
+// PrintBox is Screen.PrintBox without locking.
+func (l *Locked) PrintBox(row, col, height, width int, text string, attr Attr) {
+	l.s.printBox(row, col, height, width, text, attr)
+}
+
+// Set is Screen.Set without locking.
+func (l *Locked) Set(row, col int, ch rune, attr Attr) {
+	l.s.set(row, col, ch, attr)
+}
+
+// SetCursor is Screen.SetCursor without locking.
+func (l *Locked) SetCursor(row, col int) {
+	l.s.cursorRow, l.s.cursorCol = row, col
+}
+
+// Flush sends the cells that changed since the last Flush.
+func (s *Screen) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// maxSkip is the longest run of unchanged cells rewritten rather than
+// jumped over; a cursor move costs about as many bytes.
+const maxSkip = 6
+
+func (s *Screen) flush() error {
+	if !s.term.ANSIEnabled {
+		return ErrNoANSI
+	}
+	var b strings.Builder
+	var attr *Attr // the terminal's attribute; nil = unknown
+	curRow := 0    // the terminal's cursor; 0 = unknown
+	curCol := 0
+	width, height := s.term.Size()
+	cs := s.term.Charset()
+	theme := s.term.theme()
+	for row := 1; row <= s.rows; row++ {
+		for col := 1; col <= s.cols; col++ {
+			i := s.index(row, col)
+			c := s.cells[i]
+			if c.Ch == 0 || c == s.shown[i] {
+				continue
+			}
+			// Writing the bottom-right corner scrolls some terminals.
+			if row >= height && col >= width {
+				continue
+			}
+			if row != curRow || col < curCol || col > curCol+maxSkip {
+				b.WriteString(MoveTo(row, col))
+			} else {
+				// Rewrite the few unchanged cells in between.
+				for c2 := curCol; c2 < col; c2++ {
+					j := s.index(row, c2)
+					if s.cells[j].Ch == 0 {
+						b.WriteString(MoveTo(row, col))
+						break
+					}
+					writeCell(&b, &attr, cs, theme, s.cells[j])
+					s.shown[j] = s.cells[j]
+				}
+			}
+			writeCell(&b, &attr, cs, theme, c)
+			s.shown[i] = c
+			curRow, curCol = row, col+1
+			if curCol > width {
+				curRow = 0 // the cursor is in the margin or has wrapped
+			}
+		}
+	}
+	cursor := [2]int{s.cursorRow, s.cursorCol}
+	if b.Len() == 0 && (cursor[0] == 0 || cursor == s.cursorShown) {
+		return nil
+	}
+	if cursor[0] > 0 && cursor != [2]int{curRow, curCol} {
+		b.WriteString(MoveTo(cursor[0], cursor[1]))
+	}
+	s.cursorShown = cursor
+	if attr != nil {
+		b.WriteString(Reset) // leave other writers the default colors
+	}
+	return s.term.Send(HideCursor() + b.String() + ShowCursor())
+}
+
+// writeCell writes c in charset cs, changing the attribute first when it
+// differs. Attributes are sent through theme.
+func writeCell(b *strings.Builder, cur **Attr, cs Charset, theme func(Attr) Attr, c Cell) {
+	if *cur == nil || **cur != c.Attr {
+		b.WriteString(sgr(theme(c.Attr)))
+		a := c.Attr
+		*cur = &a
+	}
+	cs.writeRune(b, c.Ch)
+}
+
+// sgr returns the sequence that sets all of a.
+func sgr(a Attr) string {
+	s := "\033[0;"
+	if a.Bold {
+		s += "1;"
+	}
+	bg := "4" + strconv.Itoa(int(a.BG&7))
+	if a.BG == BgDefault {
+		bg = "49"
+	}
+	return s + "3" + strconv.Itoa(int(a.FG&7)) + ";" + bg + "m"
+}
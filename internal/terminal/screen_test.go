@@ -0,0 +1,69 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScreenFlushesOnlyChanges(t *testing.T) {
+	conn := &bufConn{}
+	term := New(conn, 80, 24, true)
+	s := NewScreen(term, 5, 20)
+	red := Attr{FG: 1, Bold: true}
+
+	s.Print(2, 3, 10, "hello", DefaultAttr)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	out := conn.String()
+	for _, want := range []string{HideCursor(), MoveTo(2, 3), "\033[0;37;40mhello     ", Reset, ShowCursor()} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("first flush %q lacks %q", out, want)
+		}
+	}
+	// Cells never drawn are left alone.
+	if strings.Contains(out, MoveTo(1, 1)) {
+		t.Errorf("first flush touched undrawn cells: %q", out)
+	}
+
+	conn.Reset()
+	if err := s.Flush(); err != nil || conn.Len() != 0 {
+		t.Fatalf("unchanged flush wrote %q, %v", conn.String(), err)
+	}
+
+	// "hello" -> "help!": only the changed cells are sent; the unchanged
+	// 'l' between them is cheaper to rewrite than to jump over.
+	s.Print(2, 3, 10, "help!", DefaultAttr)
+	s.Set(4, 1, 'x', red)
+	s.SetCursor(5, 1)
+	conn.Reset()
+	s.Flush()
+	out = conn.String()
+	want := HideCursor() + MoveTo(2, 6) + "\033[0;37;40mp!" + MoveTo(4, 1) + "\033[0;1;31;40mx" + MoveTo(5, 1) + Reset + ShowCursor()
+	if out != want {
+		t.Errorf("second flush = %q, want %q", out, want)
+	}
+
+	s.Invalidate()
+	conn.Reset()
+	s.Flush()
+	if out := conn.String(); !strings.Contains(out, "help!") || !strings.Contains(out, "x") {
+		t.Errorf("flush after Invalidate = %q", out)
+	}
+
+	term.ANSIEnabled = false
+	if err := s.Flush(); err != ErrNoANSI {
+		t.Errorf("flush without ANSI: %v", err)
+	}
+}
+
+func TestScreenSkipsBottomRightCorner(t *testing.T) {
+	conn := &bufConn{}
+	term := New(conn, 10, 2, true)
+	s := NewScreen(term, 2, 10)
+	s.Print(2, 1, 10, "0123456789", DefaultAttr)
+	s.Flush()
+	if out := conn.String(); !strings.Contains(out, "012345678") || strings.Contains(out, "9") {
+		t.Errorf("flush = %q", out)
+	}
+}
@@ -0,0 +1,202 @@
+package terminal
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Tap receives a copy of the traffic flowing through a Terminal.
+// Either writer may be nil. Tap writers are called from the goroutine
+// doing the I/O, so they should not block for long; wrap a writer that
+// may in a TapBuffer.
+type Tap struct {
+	Output io.Writer // bytes sent to the user
+	Input  io.Writer // bytes received from the user
+}
+
+// AddTap attaches a tap to the terminal and returns a function that
+// detaches it again. Binary-mode transfers bypass the terminal and are
+// never copied to taps.
+func (t *Terminal) AddTap(tap *Tap) (remove func()) {
+	t.tapMu.Lock()
+	t.taps = append(t.taps, tap)
+	t.tapMu.Unlock()
+
+	return func() {
+		t.tapMu.Lock()
+		defer t.tapMu.Unlock()
+		for i, existing := range t.taps {
+			if existing == tap {
+				t.taps = append(t.taps[:i], t.taps[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// tapBufferSize is how many writes a TapBuffer holds for a slow reader.
+const tapBufferSize = 256
+
+// TapBuffer is a tap writer that passes what it is given to another
+// writer from its own goroutine, so a slow or stalled reader never holds
+// up the terminal being tapped. Writes that find the buffer full are
+// dropped.
+type TapBuffer struct {
+	w    io.Writer
+	ch   chan []byte
+	quit chan struct{}
+	once sync.Once
+}
+
+// NewTapBuffer starts passing writes on to w. Close stops it.
+func NewTapBuffer(w io.Writer) *TapBuffer {
+	b := &TapBuffer{w: w, ch: make(chan []byte, tapBufferSize), quit: make(chan struct{})}
+	go b.run()
+	return b
+}
+
+func (b *TapBuffer) run() {
+	for {
+		select {
+		case p := <-b.ch:
+			if _, err := b.w.Write(p); err != nil {
+				return
+			}
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// Write queues a copy of p. It never blocks and never fails.
+func (b *TapBuffer) Write(p []byte) (int, error) {
+	select {
+	case <-b.quit:
+	case b.ch <- append([]byte(nil), p...):
+	default:
+	}
+	return len(p), nil
+}
+
+// Close stops passing writes on; those still queued are dropped.
+func (b *TapBuffer) Close() error {
+	b.once.Do(func() { close(b.quit) })
+	return nil
+}
+
+// Inject queues input as if the user had typed it. A read that is already
+// blocked is interrupted via the read deadline when the connection supports
+// one; otherwise the input is delivered on the next read.
+func (t *Terminal) Inject(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+
+	t.injected = append(t.injected, p...)
+	t.interruptLocked()
+}
+
+// HasInjected reports whether injected input is waiting to be read, which
+// tells it apart from what the caller is typing.
+func (t *Terminal) HasInjected() bool {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	return len(t.injected) > 0
+}
+
+// interruptLocked wakes a blocked read via the read deadline when the
+// connection supports one. The caller must hold tapMu.
+func (t *Terminal) interruptLocked() {
+	if rd, ok := t.rwc.(readDeadliner); ok {
+		t.interrupted = true
+		_ = rd.SetReadDeadline(time.Now())
+	}
+}
+
+// SetReadDeadline sets the read deadline on the underlying connection if it
+// supports one. The terminal remembers the deadline so it can be restored
+// after an Inject interrupts a blocked read.
+func (t *Terminal) SetReadDeadline(deadline time.Time) error {
+	rd, ok := t.rwc.(readDeadliner)
+	if !ok {
+		return nil
+	}
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+
+	t.readDeadline = deadline
+	t.interrupted = false
+	return rd.SetReadDeadline(deadline)
+}
+
+// takeInjected copies queued injected input into p.
+func (t *Terminal) takeInjected(p []byte) int {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+
+	if len(t.injected) == 0 {
+		return 0
+	}
+	n := copy(p, t.injected)
+	t.injected = t.injected[n:]
+	if len(t.injected) == 0 {
+		t.injected = nil
+		t.restoreDeadlineLocked()
+	}
+	return n
+}
+
+// consumeInterrupt reports whether err is the timeout caused by Inject, in
+// which case the caller's own deadline is restored and the read retried.
+func (t *Terminal) consumeInterrupt(err error) bool {
+	ne, ok := err.(interface{ Timeout() bool })
+	if !ok || !ne.Timeout() {
+		return false
+	}
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+
+	if !t.interrupted {
+		return false
+	}
+	t.restoreDeadlineLocked()
+	return true
+}
+
+func (t *Terminal) restoreDeadlineLocked() {
+	if !t.interrupted {
+		return
+	}
+	t.interrupted = false
+	if rd, ok := t.rwc.(readDeadliner); ok {
+		_ = rd.SetReadDeadline(t.readDeadline)
+	}
+}
+
+func (t *Terminal) snapshotTaps() []*Tap {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	if len(t.taps) == 0 {
+		return nil
+	}
+	return append([]*Tap(nil), t.taps...)
+}
+
+func (t *Terminal) teeOutput(p []byte) {
+	for _, tap := range t.snapshotTaps() {
+		if tap.Output != nil {
+			_, _ = tap.Output.Write(p)
+		}
+	}
+}
+
+func (t *Terminal) teeInput(p []byte) {
+	for _, tap := range t.snapshotTaps() {
+		if tap.Input != nil {
+			_, _ = tap.Input.Write(p)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,19 +31,80 @@ type Terminal struct {
 	Height      int
 	ANSIEnabled bool
 
+	// Probe holds what Detect learned about the client, if it ran.
+	Probe Probe
+
+	// TermColors is the color depth the connection advertised (see
+	// DetectColorDepth), "" when unknown.
+	TermColors ColorDepth
+
+	// OnResize is called from the reading goroutine after a window size
+	// change has been applied to Width and Height.
+	OnResize func(width, height int)
+
+	// OnWake is called from the reading goroutine after Wake.
+	OnWake func()
+
 	// echoControl is called to enable/disable safe echo behavior.
 	// For telnet, this typically controls whether the client performs local echo.
 	echoControl func(on bool) error
+
+	// tapMu guards taps, injected input and deadline bookkeeping (see tee.go).
+	tapMu        sync.Mutex
+	taps         []*Tap
+	injected     []byte
+	interrupted  bool
+	readDeadline time.Time
+	pendingSize  [2]int // width, height; zero when no resize is pending
+	pendingWake  bool
+	sizeReported bool   // the client has sent its size (NAWS, window-change)
+	lastSize     [2]int // the latest size the client sent, for Size
+	watchers     map[int]func(width, height int)
+	nextWatcher  int
+
+	// baudMu guards the emulated line speed and serializes paced writes
+	// (see baud.go).
+	baudMu   sync.Mutex
+	baud     int
+	baudOff  int // SuspendBaud calls not yet resumed
+	baudNext time.Time
+
+	// charset is how the client encodes input (see charset.go); guarded
+	// by tapMu.
+	charset Charset
+
+	// palette is how widgets choose colors (see theme.go); guarded by
+	// tapMu.
+	palette Palette
+
+	// colors is the color depth the caller chose (see color.go); guarded
+	// by tapMu.
+	colors ColorDepth
+
+	// sendMu guards the SendTimeout state (see timeout.go): sendTurn is
+	// held by the write in progress, and sendStalled is set while that
+	// write has outlived its timeout.
+	sendMu      sync.Mutex
+	sendTurn    chan struct{}
+	sendStalled bool
+
+	// Byte counters for the connection (see traffic.go)
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
 }
 
 // New creates a new Terminal wrapping the given ReadWriteCloser.
 func New(rwc io.ReadWriteCloser, width, height int, ansiEnabled bool) *Terminal {
-	return &Terminal{
+	t := &Terminal{
 		rwc:         rwc,
 		Width:       width,
 		Height:      height,
 		ANSIEnabled: ansiEnabled,
 	}
+	if r, ok := rwc.(Resizer); ok {
+		r.SetResizeHandler(t.Resize)
+	}
+	return t
 }
 
 // SetEchoControl registers a callback for enabling/disabling echo behavior.
@@ -50,12 +113,21 @@ func (t *Terminal) SetEchoControl(fn func(on bool) error) {
 }
 
 // EnterBinaryMode switches the underlying connection to raw binary mode
-// for file transfers. Returns a raw ReadWriter, a cleanup function, and
-// whether this is a telnet connection. Returns nil if the connection
+// for file transfers, with baud emulation suspended until cleanup. Returns
+// a raw ReadWriter, a cleanup function, and whether this is a telnet
+// connection. Returns nil if the connection
 // type does not support binary mode.
 func (t *Terminal) EnterBinaryMode() (io.ReadWriter, func(), bool) {
 	if bms, ok := t.rwc.(BinaryModeSwitcher); ok {
-		return bms.EnterBinaryMode()
+		// Transfers run at full speed.
+		resume := t.SuspendBaud()
+		raw, cleanup, telnet := bms.EnterBinaryMode()
+		return raw, func() {
+			if cleanup != nil {
+				cleanup()
+			}
+			resume()
+		}, telnet
 	}
 	return nil, nil, false
 }
@@ -66,24 +138,54 @@ func (t *Terminal) Close() error {
 }
 
 // Read implements io.Reader, delegating to the underlying connection.
+// Input queued with Inject is returned ahead of connection data, and
+// everything read is copied to any attached taps.
 func (t *Terminal) Read(p []byte) (int, error) {
-	return t.rwc.Read(p)
+	for {
+		t.applyResize()
+		t.applyWake()
+		if n := t.takeInjected(p); n > 0 {
+			return n, nil
+		}
+		n, err := t.rwc.Read(p)
+		if n > 0 {
+			t.bytesReceived.Add(int64(n))
+			t.teeInput(p[:n])
+			return n, err
+		}
+		if err != nil && t.consumeInterrupt(err) {
+			continue
+		}
+		return n, err
+	}
 }
 
 // Write implements io.Writer, delegating to the underlying connection.
+// Output is paced when baud emulation is on (see SetBaud). Everything
+// written is copied to any attached taps.
 func (t *Terminal) Write(p []byte) (int, error) {
-	return t.rwc.Write(p)
+	return t.writePaced(p)
+}
+
+// writeRaw writes p to the connection at full speed.
+func (t *Terminal) writeRaw(p []byte) (int, error) {
+	n, err := t.rwc.Write(p)
+	if n > 0 {
+		t.bytesSent.Add(int64(n))
+		t.teeOutput(p[:n])
+	}
+	return n, err
 }
 
 // Send writes raw bytes to the terminal.
 func (t *Terminal) Send(data string) error {
-	_, err := io.WriteString(t.rwc, data)
+	_, err := t.Write([]byte(data))
 	return err
 }
 
 // SendBytes writes raw bytes to the terminal.
 func (t *Terminal) SendBytes(data []byte) error {
-	_, err := t.rwc.Write(data)
+	_, err := t.Write(data)
 	return err
 }
 
@@ -128,7 +230,7 @@ func (t *Terminal) ResetColor() error {
 // ReadByte reads a single byte from the terminal.
 func (t *Terminal) ReadByte() (byte, error) {
 	buf := make([]byte, 1)
-	_, err := t.rwc.Read(buf)
+	_, err := t.Read(buf)
 	return buf[0], err
 }
 
@@ -137,17 +239,48 @@ func (t *Terminal) GetKey() (byte, error) {
 	return t.ReadByte()
 }
 
+// PollKey waits up to d for a keypress, for screens that keep moving until
+// the caller presses a key. It reports whether a key came. On connections
+// without read deadlines only injected input is seen, after waiting d.
+func (t *Terminal) PollKey(d time.Duration) (byte, bool, error) {
+	if _, ok := t.rwc.(readDeadliner); !ok {
+		time.Sleep(d)
+		buf := make([]byte, 1)
+		if t.takeInjected(buf) > 0 {
+			return buf[0], true, nil
+		}
+		return 0, false, nil
+	}
+
+	_ = t.SetReadDeadline(time.Now().Add(max(d, time.Millisecond)))
+	defer t.SetReadDeadline(time.Time{})
+	b, err := t.ReadByte()
+	if err != nil {
+		if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return b, true, nil
+}
+
 // GetLine reads a line of input up to maxLen characters, with echo.
-// Returns the entered string (without trailing CR/LF).
+// Returns the entered string (without trailing CR/LF). Characters beyond
+// ASCII are decoded from the terminal's charset (see ReadChar).
 func (t *Terminal) GetLine(maxLen int) (string, error) {
-	var buf []byte
+	return t.getLine(maxLen, t.ReadChar)
+}
+
+// getLine is GetLine reading characters with read.
+func (t *Terminal) getLine(maxLen int, read func() (rune, []byte, error)) (string, error) {
+	var buf []rune
 	for {
-		b, err := t.ReadByte()
+		r, raw, err := read()
 		if err != nil {
 			return string(buf), err
 		}
 
-		switch b {
+		switch r {
 		case '\r', '\n':
 			t.Send("\r\n")
 			return string(buf), nil
@@ -157,9 +290,9 @@ func (t *Terminal) GetLine(maxLen int) (string, error) {
 				t.Send("\b \b")
 			}
 		default:
-			if b >= 32 && b < 127 && len(buf) < maxLen {
-				buf = append(buf, b)
-				t.Send(string(b))
+			if r >= 32 && len(buf) < maxLen {
+				buf = append(buf, r)
+				t.Send(string(raw))
 			}
 		}
 	}
@@ -172,9 +305,9 @@ func (t *Terminal) GetPassword(maxLen int) (string, error) {
 		t.echoControl(false)
 	}
 
-	var buf []byte
+	var buf []rune
 	for {
-		b, err := t.ReadByte()
+		r, _, err := t.ReadChar()
 		if err != nil {
 			// Re-enable echo before returning
 			if t.echoControl != nil {
@@ -183,7 +316,7 @@ func (t *Terminal) GetPassword(maxLen int) (string, error) {
 			return string(buf), err
 		}
 
-		switch b {
+		switch r {
 		case '\r', '\n':
 			// Re-enable echo
 			if t.echoControl != nil {
@@ -197,8 +330,8 @@ func (t *Terminal) GetPassword(maxLen int) (string, error) {
 				t.Send("\b \b")
 			}
 		default:
-			if b >= 32 && b < 127 && len(buf) < maxLen {
-				buf = append(buf, b)
+			if r >= 32 && len(buf) < maxLen {
+				buf = append(buf, r)
 				t.Send("*")
 			}
 		}
@@ -206,20 +339,21 @@ func (t *Terminal) GetPassword(maxLen int) (string, error) {
 }
 
 // Pause displays "Press any key to continue..." and waits for a keypress.
+// Pause prompts are sent at full speed even with baud emulation on.
 func (t *Terminal) Pause() error {
 	if t.ANSIEnabled {
 		// Hide the cursor before displaying the pause message
-		t.Send("\033[?25l")
-		t.Send(FgBrightCyan + "Press any key to continue..." + Reset)
+		t.SendNow("\033[?25l")
+		t.SendNow(t.Style(PromptAttr) + "Press any key to continue..." + Reset)
 	} else {
-		t.Send("Press any key to continue...")
+		t.SendNow("Press any key to continue...")
 	}
 	_, err := t.GetKey()
 	// Show the cursor after the keypress
 	if t.ANSIEnabled {
-		t.Send("\033[?25h")
+		t.SendNow("\033[?25h")
 	}
-	t.Send("\r\n")
+	t.SendNow("\r\n")
 	return err
 }
 
@@ -231,44 +365,44 @@ func (t *Terminal) PauseWithTimeout(timeoutSeconds int) error {
 	}
 
 	if t.ANSIEnabled {
-		t.Send("\033[?25l")
+		t.SendNow("\033[?25l")
 	}
 
 	err := t.displayPauseCountdown(timeoutSeconds)
 
 	// Show the cursor after the keypress or timeout
 	if t.ANSIEnabled {
-		t.Send("\033[?25h")
+		t.SendNow("\033[?25h")
 	}
-	t.Send("\r\n")
+	t.SendNow("\r\n")
 	return err
 }
 
 
 func (t *Terminal) displayPauseCountdown(seconds int) error {
-	rd, canDeadline := t.rwc.(readDeadliner)
+	_, canDeadline := t.rwc.(readDeadliner)
 	if canDeadline {
-		defer rd.SetReadDeadline(time.Time{})
+		defer t.SetReadDeadline(time.Time{})
 	}
 
 	remaining := seconds
 	for remaining > 0 {
 		// Update display
 		if t.ANSIEnabled {
-			_ = t.Send(fmt.Sprintf("\r%sPress any key to continue... (%d)%s", FgBrightCyan, remaining, Reset))
+			_ = t.SendNow(fmt.Sprintf("\r%sPress any key to continue... (%d)%s", t.Style(PromptAttr), remaining, Reset))
 		} else {
-			_ = t.Send(fmt.Sprintf("\rPress any key to continue... (%d)", remaining))
+			_ = t.SendNow(fmt.Sprintf("\rPress any key to continue... (%d)", remaining))
 		}
 
 		if canDeadline {
-			_ = rd.SetReadDeadline(time.Now().Add(time.Second))
+			_ = t.SetReadDeadline(time.Now().Add(time.Second))
 			_, err := t.GetKey()
 			if err == nil {
 				// Key was pressed, clear the countdown line and return
 				if t.ANSIEnabled {
-					_ = t.Send("\r" + strings.Repeat(" ", 40) + "\r")
+					_ = t.SendNow("\r" + strings.Repeat(" ", 40) + "\r")
 				} else {
-					_ = t.Send("\r" + strings.Repeat(" ", 30) + "\r")
+					_ = t.SendNow("\r" + strings.Repeat(" ", 30) + "\r")
 				}
 				return nil
 			}
@@ -287,9 +421,9 @@ func (t *Terminal) displayPauseCountdown(seconds int) error {
 
 	// Timeout reached, clear the countdown line
 	if t.ANSIEnabled {
-		_ = t.Send("\r" + strings.Repeat(" ", 40) + "\r")
+		_ = t.SendNow("\r" + strings.Repeat(" ", 40) + "\r")
 	} else {
-		_ = t.Send("\r" + strings.Repeat(" ", 30) + "\r")
+		_ = t.SendNow("\r" + strings.Repeat(" ", 30) + "\r")
 	}
 	return nil
 }
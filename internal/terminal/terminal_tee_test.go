@@ -0,0 +1,168 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestTapMirrorsOutputUntilRemoved(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+
+	term := New(server, 80, 24, false)
+	out := &lockedBuffer{}
+	remove := term.AddTap(&Tap{Output: out})
+
+	if err := term.Send("hello"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	remove()
+	if err := term.Send("world"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if got := out.String(); got != "hello" {
+		t.Fatalf("expected tap to see %q, got %q", "hello", got)
+	}
+}
+
+func TestInjectInterruptsBlockedRead(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+
+	term := New(server, 80, 24, false)
+
+	keyCh := make(chan byte, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		b, err := term.GetKey()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		keyCh <- b
+	}()
+
+	// Give the reader time to block on the connection.
+	time.Sleep(50 * time.Millisecond)
+	term.Inject([]byte{'X'})
+
+	select {
+	case b := <-keyCh:
+		if b != 'X' {
+			t.Fatalf("expected injected key 'X', got %q", b)
+		}
+	case err := <-errCh:
+		t.Fatalf("GetKey returned error: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("injected key was not delivered")
+	}
+
+	// The interrupt must not leave a stale deadline behind.
+	go func() {
+		b, err := term.GetKey()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		keyCh <- b
+	}()
+	if _, err := client.Write([]byte{'A'}); err != nil {
+		t.Fatalf("client write: %v", err)
+	}
+	select {
+	case b := <-keyCh:
+		if b != 'A' {
+			t.Fatalf("expected 'A', got %q", b)
+		}
+	case err := <-errCh:
+		t.Fatalf("GetKey returned error: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("real key was not delivered")
+	}
+}
+
+func TestTrafficCountsConnectionBytes(t *testing.T) {
+	conn := &bufConn{}
+	term := New(conn, 80, 24, false)
+
+	if err := term.SendLn("hello"); err != nil {
+		t.Fatal(err)
+	}
+	term.Inject([]byte("x"))
+	buf := make([]byte, 16)
+	if n, _ := term.Read(buf); n != 1 {
+		t.Fatalf("read %d injected bytes", n)
+	}
+	if n, _ := term.Read(buf); n != 7 {
+		t.Fatalf("read %d bytes", n)
+	}
+
+	if sent, received := term.Traffic(); sent != 7 || received != 7 {
+		t.Fatalf("traffic = %d sent, %d received", sent, received)
+	}
+}
+
+// blockedWriter takes nothing until release is closed.
+type blockedWriter struct {
+	lockedBuffer
+	release chan struct{}
+}
+
+func (w *blockedWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.lockedBuffer.Write(p)
+}
+
+func TestTapBufferDropsForStalledReader(t *testing.T) {
+	w := &blockedWriter{release: make(chan struct{})}
+	b := NewTapBuffer(w)
+	defer b.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < tapBufferSize*4; i++ {
+			b.Write([]byte("x"))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writes blocked behind a stalled reader")
+	}
+
+	close(w.release)
+	deadline := time.Now().Add(time.Second)
+	for len(w.String()) < tapBufferSize && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := len(w.String()); n < tapBufferSize || n > tapBufferSize+1 {
+		t.Fatalf("reader got %d writes, want the %d buffered (plus the one in hand)", n, tapBufferSize)
+	}
+}
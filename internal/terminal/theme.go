@@ -0,0 +1,152 @@
+package terminal
+
+import (
+	"math"
+	"strings"
+)
+
+// Background is how light the client's own background is, as far as the
+// probe could tell.
+type Background string
+
+const (
+	BackgroundUnknown Background = ""
+	BackgroundDark    Background = "dark"
+	BackgroundLight   Background = "light"
+)
+
+// Palette is how built-in widgets choose colors.
+type Palette string
+
+const (
+	PaletteAuto   Palette = "auto"   // contrast-safe when the background probed light
+	PaletteNormal Palette = "normal" // colors as designed
+	PaletteSafe   Palette = "safe"   // contrast-safe, for the probed background or a dark one
+	PaletteLight  Palette = "light"  // contrast-safe for a light background
+)
+
+// ParsePalette returns the palette named s. ok is false for unknown
+// names.
+func ParsePalette(s string) (Palette, bool) {
+	switch p := Palette(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PaletteAuto, true
+	case PaletteAuto, PaletteNormal, PaletteSafe, PaletteLight:
+		return p, true
+	}
+	return "", false
+}
+
+// BgDefault as Attr.BG is the terminal's own background (SGR 49) rather
+// than one of the eight colors.
+const BgDefault uint8 = 9
+
+// PromptAttr is the bright cyan of prompts such as "Press any key".
+var PromptAttr = Attr{FG: 6, BG: BgDefault, Bold: true}
+
+// minContrast is the lowest contrast ratio (WCAG, 1 to 21) the safe
+// palettes leave alone. Blue on black is about 1.6, red on black 2.7.
+const minContrast = 2.5
+
+// cga is the 16-color palette DOS terminals show, normal colors first.
+var cga = [16][3]uint8{
+	{0x00, 0x00, 0x00}, {0xaa, 0x00, 0x00}, {0x00, 0xaa, 0x00}, {0xaa, 0x55, 0x00},
+	{0x00, 0x00, 0xaa}, {0xaa, 0x00, 0xaa}, {0x00, 0xaa, 0xaa}, {0xaa, 0xaa, 0xaa},
+	{0x55, 0x55, 0x55}, {0xff, 0x55, 0x55}, {0x55, 0xff, 0x55}, {0xff, 0xff, 0x55},
+	{0x55, 0x55, 0xff}, {0xff, 0x55, 0xff}, {0x55, 0xff, 0xff}, {0xff, 0xff, 0xff},
+}
+
+// luminance is the relative luminance of color c of cga.
+func luminance(c int) float64 {
+	lin := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	rgb := cga[c]
+	return 0.2126*lin(rgb[0]) + 0.7152*lin(rgb[1]) + 0.0722*lin(rgb[2])
+}
+
+// contrast is the WCAG contrast ratio of two cga colors.
+func contrast(a, b int) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// contrastSafe returns a with a readable foreground on its background,
+// taking BgDefault to be bg. Text with too little contrast switches
+// intensity (dark blue on black becomes bright blue, bright cyan on white
+// plain cyan) or, failing that, becomes white or black.
+func contrastSafe(a Attr, bg Background) Attr {
+	back := int(a.BG & 7)
+	if a.BG == BgDefault {
+		back = 0
+		if bg == BackgroundLight {
+			back = 15
+		}
+	}
+	fore := func(a Attr) int {
+		if a.Bold {
+			return int(a.FG&7) + 8
+		}
+		return int(a.FG & 7)
+	}
+	if contrast(fore(a), back) >= minContrast {
+		return a
+	}
+	if b := (Attr{FG: a.FG, BG: a.BG, Bold: !a.Bold}); contrast(fore(b), back) >= minContrast {
+		return b
+	}
+	if luminance(back) > 0.2 {
+		return Attr{FG: 0, BG: a.BG}
+	}
+	return Attr{FG: 7, BG: a.BG, Bold: true}
+}
+
+// SetPalette sets how built-in widgets choose colors; see Style.
+func (t *Terminal) SetPalette(p Palette) {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	t.palette = p
+}
+
+// Palette returns the palette set with SetPalette, PaletteAuto by
+// default.
+func (t *Terminal) Palette() Palette {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	if t.palette == "" {
+		return PaletteAuto
+	}
+	return t.palette
+}
+
+// theme returns the color mapping of the terminal's palette.
+func (t *Terminal) theme() func(Attr) Attr {
+	bg := t.Probe.Background
+	switch t.Palette() {
+	case PaletteNormal:
+	case PaletteAuto:
+		if bg == BackgroundLight {
+			return func(a Attr) Attr { return contrastSafe(a, bg) }
+		}
+	case PaletteSafe:
+		return func(a Attr) Attr { return contrastSafe(a, bg) }
+	case PaletteLight:
+		return func(a Attr) Attr { return contrastSafe(a, BackgroundLight) }
+	}
+	return func(a Attr) Attr { return a }
+}
+
+// Style returns the sequence that sets the colors of a, through the
+// terminal's palette. Built-in widgets draw with it (and with Screen,
+// which uses it too) so their text stays readable whatever the client's
+// background.
+func (t *Terminal) Style(a Attr) string {
+	return sgr(t.theme()(a))
+}
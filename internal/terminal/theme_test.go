@@ -0,0 +1,76 @@
+package terminal
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestContrastSafe(t *testing.T) {
+	cases := []struct {
+		in   Attr
+		bg   Background
+		want Attr
+	}{
+		{Attr{FG: 4, BG: 0}, BackgroundDark, Attr{FG: 4, BG: 0, Bold: true}},                  // dark blue on black
+		{Attr{FG: 2, BG: 0}, BackgroundDark, Attr{FG: 2, BG: 0}},                              // green is fine
+		{Attr{FG: 4, BG: BgDefault}, BackgroundDark, Attr{FG: 4, BG: BgDefault, Bold: true}},  // default is black
+		{Attr{FG: 6, BG: BgDefault, Bold: true}, BackgroundLight, Attr{FG: 6, BG: BgDefault}}, // bright cyan on white
+		{Attr{FG: 7, BG: BgDefault}, BackgroundLight, Attr{FG: 0, BG: BgDefault}},             // gray on white
+		{Attr{FG: 7, BG: 6}, BackgroundLight, Attr{FG: 7, BG: 6, Bold: true}},                 // explicit backgrounds stay
+	}
+	for _, c := range cases {
+		if got := contrastSafe(c.in, c.bg); got != c.want {
+			t.Errorf("contrastSafe(%+v, %q) = %+v, want %+v", c.in, c.bg, got, c.want)
+		}
+	}
+	if p, ok := ParsePalette(" Safe "); !ok || p != PaletteSafe {
+		t.Errorf("ParsePalette = %q, %v", p, ok)
+	}
+	if _, ok := ParsePalette("neon"); ok {
+		t.Error("unknown palette accepted")
+	}
+}
+
+func TestStyleFollowsPalette(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	term := New(server, 80, 24, true)
+	blue := Attr{FG: 4}
+
+	if got := term.Style(blue); got != "\033[0;34;40m" {
+		t.Errorf("auto on an unknown background = %q", got)
+	}
+	term.Probe.Background = BackgroundLight
+	if got := term.Style(PromptAttr); got != "\033[0;36;49m" {
+		t.Errorf("auto prompt on a light background = %q", got)
+	}
+	term.SetPalette(PaletteNormal)
+	if got := term.Style(PromptAttr); got != "\033[0;1;36;49m" {
+		t.Errorf("normal prompt = %q", got)
+	}
+	term.Probe.Background = BackgroundUnknown
+	term.SetPalette(PaletteSafe)
+	if got := term.Style(blue); got != "\033[0;1;34;40m" {
+		t.Errorf("safe blue on black = %q", got)
+	}
+
+	// Screen cells go through the palette too.
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		buf := make([]byte, 256)
+		n, _ := client.Read(buf)
+		out.Write(buf[:n])
+		close(done)
+	}()
+	s := NewScreen(term, 1, 5)
+	s.Print(1, 1, 5, "hi", blue)
+	s.Flush()
+	<-done
+	if !strings.Contains(out.String(), "\033[0;1;34;40mhi") {
+		t.Errorf("screen output %q", out.String())
+	}
+}
@@ -0,0 +1,69 @@
+package terminal
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrWriteTimeout is returned by SendTimeout when the client did not
+	// take the data in time. The write carries on in the background, so
+	// the data may still arrive.
+	ErrWriteTimeout = errors.New("terminal: write timed out")
+
+	// ErrWriteSkipped is returned by SendTimeout when the data was not
+	// written at all: an earlier write is stalled, or the writes queued
+	// ahead did not finish in time.
+	ErrWriteSkipped = errors.New("terminal: write skipped, the client is not reading")
+)
+
+// SendTimeout writes data like Send, but gives up after d so a stalled
+// client cannot hold up the caller. Concurrent calls take turns. A write
+// that times out carries on in the background, and later calls are
+// skipped at once until it completes, so a stuck connection holds at most
+// one pending write.
+func (t *Terminal) SendTimeout(data string, d time.Duration) error {
+	t.sendMu.Lock()
+	if t.sendTurn == nil {
+		t.sendTurn = make(chan struct{}, 1)
+	}
+	turn, stalled := t.sendTurn, t.sendStalled
+	t.sendMu.Unlock()
+	if stalled {
+		return ErrWriteSkipped
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case turn <- struct{}{}:
+	case <-timer.C:
+		return ErrWriteSkipped
+	}
+
+	done := make(chan error, 1)
+	finished := false // guarded by sendMu
+	go func() {
+		err := t.Send(data)
+		t.sendMu.Lock()
+		finished = true
+		t.sendStalled = false
+		t.sendMu.Unlock()
+		<-turn
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+	}
+	t.sendMu.Lock()
+	if !finished {
+		t.sendStalled = true
+		t.sendMu.Unlock()
+		return ErrWriteTimeout
+	}
+	t.sendMu.Unlock()
+	return <-done
+}
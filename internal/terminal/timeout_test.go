@@ -0,0 +1,76 @@
+package terminal
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	term := New(server, 80, 24, false)
+
+	// Nobody reads from client, so the write stalls.
+	if err := term.SendTimeout("hello", 20*time.Millisecond); err != ErrWriteTimeout {
+		t.Fatalf("stalled write: %v", err)
+	}
+	start := time.Now()
+	if err := term.SendTimeout("again", time.Second); err != ErrWriteSkipped {
+		t.Fatalf("write behind a stalled one: %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("write behind a stalled one waited %v", d)
+	}
+
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(client, buf); err != nil || string(buf) != "hello" {
+		t.Fatalf("read %q, %v", buf, err)
+	}
+	go io.Copy(io.Discard, client)
+	deadline := time.Now().Add(time.Second)
+	for term.SendTimeout("ok", 100*time.Millisecond) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("writes still failing after the client caught up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSendTimeoutConcurrent(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	term := New(server, 80, 24, false)
+	got := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(client)
+		got <- string(b)
+	}()
+
+	// Senders to a healthy client take turns; none is turned away.
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- term.SendTimeout("x", time.Second)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent send: %v", err)
+		}
+	}
+	server.Close()
+	if out := <-got; out != strings.Repeat("x", 10) {
+		t.Errorf("client read %q", out)
+	}
+}
@@ -0,0 +1,24 @@
+package terminal
+
+// Traffic returns the bytes written to and read from the caller's
+// connection so far. File transfers use the raw connection from
+// EnterBinaryMode and are not counted; injected input is not either.
+func (t *Terminal) Traffic() (sent, received int64) {
+	return t.bytesSent.Load(), t.bytesReceived.Load()
+}
+
+// Compressor is implemented by connections that may compress what is sent,
+// such as telnet with MCCP2.
+type Compressor interface {
+	// Compressing reports whether output is compressed right now.
+	Compressing() bool
+}
+
+// Compressing reports whether the connection compresses what is sent to
+// the caller. It is false during file transfers, which are sent as they are.
+func (t *Terminal) Compressing() bool {
+	if c, ok := t.rwc.(Compressor); ok {
+		return c.Compressing()
+	}
+	return false
+}
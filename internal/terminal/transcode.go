@@ -0,0 +1,110 @@
+package terminal
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Transcoder is a writer that converts text from one charset to another,
+// for programs such as DOS doors whose output is in a fixed charset.
+// ASCII, and with it control characters and ANSI sequences, passes
+// through unchanged; characters the target charset lacks become '?'. A
+// UTF-8 character split across writes is held until the rest arrives.
+type Transcoder struct {
+	w        io.Writer
+	from, to Charset
+	pending  []byte // an incomplete UTF-8 sequence from the last write
+}
+
+// NewTranscoder returns a writer converting from one charset to another
+// before writing to w. When both are the same, writes pass through.
+func NewTranscoder(w io.Writer, from, to Charset) *Transcoder {
+	if from == "" {
+		from = CharsetUTF8
+	}
+	if to == "" {
+		to = CharsetUTF8
+	}
+	return &Transcoder{w: w, from: from, to: to}
+}
+
+// Write converts p and writes the result.
+func (tc *Transcoder) Write(p []byte) (int, error) {
+	if tc.from == tc.to {
+		return tc.w.Write(p)
+	}
+	data := p
+	if len(tc.pending) > 0 {
+		data = append(tc.pending, p...)
+		tc.pending = nil
+	}
+
+	var b strings.Builder
+	b.Grow(len(data) * 2)
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c < 0x80 {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		switch tc.from {
+		case CharsetCP437:
+			tc.to.writeRune(&b, CP437[c])
+			i++
+		case CharsetLatin1:
+			if c < 0xa0 {
+				b.WriteByte('?') // a C1 control
+			} else {
+				tc.to.writeRune(&b, rune(c))
+			}
+			i++
+		default:
+			if !utf8.FullRune(data[i:]) {
+				tc.pending = append([]byte(nil), data[i:]...)
+				i = len(data)
+				continue
+			}
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size == 1 {
+				r = '?'
+			}
+			tc.to.writeRune(&b, r)
+			i += size
+		}
+	}
+	if _, err := io.WriteString(tc.w, b.String()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// DoorWriter returns where the CP437 output of a program such as a DOS
+// door goes on its way to w: through a Transcoder to the charset the
+// caller chose, or straight to w when they chose CP437 or never chose at
+// all, as callers on CP437 terminals such as SyncTERM seldom do. flush is
+// called when the program ends.
+func DoorWriter(w io.Writer) (out io.Writer, flush func() error) {
+	cs, ok := w.(interface{ ChosenCharset() (Charset, bool) })
+	if !ok {
+		return w, func() error { return nil }
+	}
+	to, chosen := cs.ChosenCharset()
+	if !chosen || to == CharsetCP437 {
+		return w, func() error { return nil }
+	}
+	tc := NewTranscoder(w, CharsetCP437, to)
+	return tc, tc.Flush
+}
+
+// Flush writes out what is held of an incomplete character, as '?', for
+// when the source has finished.
+func (tc *Transcoder) Flush() error {
+	if len(tc.pending) == 0 {
+		return nil
+	}
+	tc.pending = nil
+	_, err := tc.w.Write([]byte{'?'})
+	return err
+}
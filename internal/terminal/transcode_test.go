@@ -0,0 +1,73 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestTranscoder(t *testing.T) {
+	tests := []struct {
+		from, to Charset
+		writes   []string
+		want     string
+	}{
+		// A DOS door's box drawing and shading for a UTF-8 caller, ANSI
+		// sequences untouched.
+		{CharsetCP437, CharsetUTF8, []string{"\x1b[1;34m\xc9\xcd\xbb", "\xb0\xb1\xb2\x1b[0m\r\n"}, "\x1b[1;34m╔═╗░▒▓\x1b[0m\r\n"},
+		// CP437 callers get the door's bytes as they are.
+		{CharsetCP437, CharsetCP437, []string{"\xc9\xcd\xbb"}, "\xc9\xcd\xbb"},
+		// Latin-1 has é but no box drawing.
+		{CharsetCP437, CharsetLatin1, []string{"caf\x82 \xc9"}, "caf\xe9 ?"},
+		// A UTF-8 character split across writes is put back together.
+		{CharsetUTF8, CharsetCP437, []string{"\xe2\x95", "\x94 caf\xc3", "\xa9"}, "\xc9 caf\x82"},
+		{CharsetUTF8, CharsetCP437, []string{"a\xffb"}, "a?b"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		tc := NewTranscoder(&out, tt.from, tt.to)
+		for _, w := range tt.writes {
+			if n, err := tc.Write([]byte(w)); n != len(w) || err != nil {
+				t.Fatalf("%s to %s: write = %d, %v", tt.from, tt.to, n, err)
+			}
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("%s to %s: %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	var out bytes.Buffer
+	tc := NewTranscoder(&out, CharsetUTF8, CharsetLatin1)
+	tc.Write([]byte("ok\xc3"))
+	tc.Flush()
+	if out.String() != "ok?" {
+		t.Errorf("flushed %q", out.String())
+	}
+}
+
+func TestDoorWriter(t *testing.T) {
+	box := "\x1b[1;34m\xc9\xcd\xbb"
+	for _, tt := range []struct {
+		name    string
+		charset Charset // "" = never chosen
+		want    string
+	}{
+		// A caller who never chose may well be on a CP437 terminal such
+		// as SyncTERM, so the door's bytes go out as they are.
+		{"unset", "", box},
+		{"cp437", CharsetCP437, box},
+		{"utf8", CharsetUTF8, "\x1b[1;34m╔═╗"},
+	} {
+		conn := &bufConn{}
+		term := New(conn, 80, 24, true)
+		if tt.charset != "" {
+			term.SetCharset(tt.charset)
+		}
+		w, flush := DoorWriter(term)
+		io.WriteString(w, box)
+		flush()
+		if got := conn.String(); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
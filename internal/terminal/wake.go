@@ -0,0 +1,26 @@
+package terminal
+
+// Wake asks the goroutine reading from the terminal to call OnWake, for
+// events that must be handled where the session's scripts run. It may be
+// called from any goroutine. Like Resize, it interrupts a blocked read
+// when the connection supports read deadlines; otherwise OnWake runs at
+// the start of the next read.
+func (t *Terminal) Wake() {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	t.pendingWake = true
+	t.interruptLocked()
+}
+
+// applyWake calls OnWake if Wake was called since the last read. It runs
+// on the reading goroutine.
+func (t *Terminal) applyWake() {
+	t.tapMu.Lock()
+	wake := t.pendingWake
+	t.pendingWake = false
+	t.tapMu.Unlock()
+
+	if wake && t.OnWake != nil {
+		t.OnWake()
+	}
+}
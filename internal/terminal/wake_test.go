@@ -0,0 +1,43 @@
+package terminal
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWakeInterruptsBlockedRead(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	term := New(server, 80, 24, true)
+	woken := make(chan struct{}, 1)
+	term.OnWake = func() { woken <- struct{}{} }
+
+	keyCh := make(chan byte, 1)
+	go func() {
+		key, err := term.GetKey()
+		if err == nil {
+			keyCh <- key
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	term.Wake()
+	select {
+	case <-woken:
+	case <-time.After(time.Second):
+		t.Fatal("blocked read was not interrupted by Wake")
+	}
+
+	client.Write([]byte("y"))
+	select {
+	case key := <-keyCh:
+		if key != 'y' {
+			t.Fatalf("got key %q", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read did not resume after wake")
+	}
+}
@@ -0,0 +1,443 @@
+// Package textview shows long text in a scrolling box: a full screen or a
+// rectangle of a menu template, with line and page scrolling and search.
+package textview
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+// Options says where to show the text and how.
+type Options struct {
+	// The box, 1-based like GotoXY. Zero Row and Col start at the top
+	// left; zero Width and Height reach the right edge and the row above
+	// the status line.
+	Row, Col      int
+	Width, Height int
+
+	// StatusRow is the row for the position, keys and search prompt: 0
+	// is the bottom row of the terminal, -1 none.
+	StatusRow int
+
+	Title  string // shown at the start of the status line
+	Start  int    // first line shown, 1-based
+	NoWrap bool   // cut long lines at the box edge instead of wrapping
+}
+
+// maxQuery is the longest search the status line takes.
+const maxQuery = 40
+
+// Keys the viewer understands.
+const (
+	keyNone = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keySearch
+	keyNext
+	keyQuit
+)
+
+// Colors of the text, a search match and the status line.
+var (
+	attrText   = terminal.Attr{FG: 7, BG: terminal.BgDefault}
+	attrMatch  = terminal.Attr{FG: 0, BG: 6}
+	attrStatus = terminal.Attr{FG: 7, BG: 4, Bold: true}
+)
+
+// Decode returns data as text: as it is when it is UTF-8, else read as
+// CP437, as DOS-era files such as FILE_ID.DIZ are.
+func Decode(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return ansi.DecodeCP437(data)
+}
+
+// clean makes text fit for the box: line ends become \n, tabs become
+// spaces, ANSI escape sequences and other control characters are dropped,
+// and a ^Z (and the SAUCE record after it) ends the text.
+func clean(text string) string {
+	if i := strings.IndexByte(text, 0x1a); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var b strings.Builder
+	col := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == 0x1b:
+			i += escapeLen(text[i:])
+			continue
+		case r == '\n' || r == '\r':
+			b.WriteByte('\n')
+			col = 0
+		case r == '\t':
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		case r < ' ' || r == 0x7f:
+		default:
+			b.WriteRune(r)
+			col++
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// escapeLen returns the length of the escape sequence s starts with.
+func escapeLen(s string) int {
+	if len(s) < 2 || s[1] != '[' {
+		return min(len(s), 2)
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// layout splits text into the lines of a box width columns wide, wrapping
+// at spaces where possible or cutting when wrap is false.
+func layout(text string, width int, wrap bool) []string {
+	width = max(width, 1)
+	var out []string
+	for _, para := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		r := []rune(strings.TrimRight(para, " "))
+		if !wrap && len(r) > width {
+			r = r[:width]
+		}
+		for len(r) > width {
+			cut := width
+			for i := width; i > width/2; i-- {
+				if r[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			out = append(out, strings.TrimRight(string(r[:cut]), " "))
+			r = []rune(strings.TrimLeft(string(r[cut:]), " "))
+		}
+		out = append(out, string(r))
+	}
+	return out
+}
+
+// model holds the viewer state independently of the terminal.
+type model struct {
+	lines  []string
+	height int
+	top    int    // first line shown, 0-based
+	query  string // last search
+	match  int    // line of the last match, -1 = none
+}
+
+func newModel(lines []string, height, start int) *model {
+	m := &model{lines: lines, height: max(height, 1), match: -1}
+	m.scrollTo(start - 1)
+	return m
+}
+
+// scrollTo shows line top first, as far as the text allows.
+func (m *model) scrollTo(top int) {
+	m.top = max(min(top, len(m.lines)-m.height), 0)
+}
+
+// handle applies a scrolling key and reports whether the viewer is done.
+func (m *model) handle(key int) (done bool) {
+	switch key {
+	case keyUp:
+		m.scrollTo(m.top - 1)
+	case keyDown:
+		m.scrollTo(m.top + 1)
+	case keyPageUp:
+		m.scrollTo(m.top - m.height)
+	case keyPageDown:
+		m.scrollTo(m.top + m.height)
+	case keyHome:
+		m.scrollTo(0)
+	case keyEnd:
+		m.scrollTo(len(m.lines))
+	case keyQuit:
+		return true
+	}
+	return false
+}
+
+// search finds the next line after the last match (or from the top of
+// the box) that contains query, ignoring case and wrapping around, and
+// scrolls it into view. It reports whether there was one.
+func (m *model) search(query string) bool {
+	if query == "" {
+		return false
+	}
+	from := m.top
+	if query == m.query && m.match >= 0 {
+		from = m.match + 1
+	}
+	m.query = query
+	q := strings.ToLower(query)
+	for i := range m.lines {
+		n := (from + i) % len(m.lines)
+		if strings.Contains(strings.ToLower(m.lines[n]), q) {
+			m.match = n
+			if n < m.top || n >= m.top+m.height {
+				m.scrollTo(n)
+			}
+			return true
+		}
+	}
+	m.match = -1
+	return false
+}
+
+// position describes the lines shown, e.g. "Lines 1-20 of 75 (26%)".
+func (m *model) position() string {
+	last := min(m.top+m.height, len(m.lines))
+	return fmt.Sprintf("Lines %d-%d of %d (%d%%)", m.top+1, last, len(m.lines), last*100/max(len(m.lines), 1))
+}
+
+// Run shows text and returns the 1-based line at the top of the box when
+// the caller closes it. Text that is not UTF-8 is read as CP437 (see
+// Decode) and is sent in the terminal's charset.
+//
+// Up/Down scroll a line, PgUp/PgDn (or B/Space) a box, Home/End go to
+// either end, / searches, N finds the next match and Q or Esc closes
+// the viewer. Without ANSI the text is paged instead.
+func Run(term *terminal.Terminal, text string, opts Options) (int, error) {
+	text = clean(text)
+	if !term.ANSIEnabled {
+		return runPlain(term, text, opts)
+	}
+
+	width, height := term.Size()
+	row, col := max(opts.Row, 1), max(opts.Col, 1)
+	status := opts.StatusRow
+	if status == 0 {
+		status = height
+	}
+	boxW := opts.Width
+	if boxW <= 0 || col+boxW-1 > width {
+		boxW = width - col + 1
+	}
+	boxH := opts.Height
+	if boxH <= 0 {
+		boxH = height - row + 1
+		if status >= row && status <= height {
+			boxH = status - row
+		}
+	}
+	if boxW < 1 || boxH < 1 {
+		return 0, fmt.Errorf("text box %dx%d at %d,%d does not fit the screen", opts.Width, opts.Height, row, col)
+	}
+
+	m := newModel(layout(text, boxW, !opts.NoWrap), boxH, opts.Start)
+	screen := terminal.NewScreen(term, max(height, row+boxH-1, status), max(width, col+boxW-1))
+	note := ""
+	for {
+		screen.Update(func(l *terminal.Locked) {
+			draw(l, m, row, col, boxW)
+			if status > 0 {
+				line := m.position() + "  Up/Dn PgUp/PgDn Home/End /=Search N=Next Q=Quit"
+				if note != "" {
+					line = note
+				}
+				if opts.Title != "" {
+					line = opts.Title + "  " + line
+				}
+				l.Print(status, 1, width, " "+line, attrStatus)
+			}
+			l.SetCursor(status, width)
+		})
+		note = ""
+
+		key, err := readKey(term)
+		if err != nil {
+			return m.top + 1, err
+		}
+		switch key {
+		case keySearch, keyNext:
+			query := m.query
+			if key == keySearch || query == "" {
+				if status <= 0 {
+					break
+				}
+				if query, err = ask(term, screen, status, width); err != nil {
+					return m.top + 1, err
+				}
+			}
+			if query != "" && !m.search(query) {
+				note = fmt.Sprintf("Not found: %s", query)
+			}
+		default:
+			if m.handle(key) {
+				return m.top + 1, nil
+			}
+		}
+	}
+}
+
+// draw fills the box with the lines shown, highlighting the last match.
+func draw(l *terminal.Locked, m *model, row, col, width int) {
+	q := []rune(strings.ToLower(m.query))
+	for i := 0; i < m.height; i++ {
+		n := m.top + i
+		line := ""
+		if n < len(m.lines) {
+			line = m.lines[n]
+		}
+		l.Print(row+i, col, width, line, attrText)
+		if n != m.match || len(q) == 0 {
+			continue
+		}
+		r := []rune(line)
+		lower := []rune(strings.ToLower(line))
+		if len(lower) != len(r) {
+			continue // case folding changed the length; skip the highlight
+		}
+		for at := 0; at+len(q) <= len(lower) && at < width; at++ {
+			if string(lower[at:at+len(q)]) == string(q) {
+				for j := at; j < at+len(q) && j < width; j++ {
+					l.Set(row+i, col+j, r[j], attrMatch)
+				}
+				break
+			}
+		}
+	}
+}
+
+// ask reads a search on the status line. Esc cancels with "".
+func ask(term *terminal.Terminal, screen *terminal.Screen, row, width int) (string, error) {
+	var query []rune
+	for {
+		prompt := " Search: " + string(query)
+		screen.Update(func(l *terminal.Locked) {
+			l.Print(row, 1, width, prompt, attrStatus)
+			l.SetCursor(row, min(utf8.RuneCountInString(prompt)+1, width))
+		})
+		r, _, err := term.ReadChar()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case r == '\r' || r == '\n':
+			return string(query), nil
+		case r == 0x1b || r == 3:
+			return "", nil
+		case r == 8 || r == 127:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+		case r >= ' ' && len(query) < maxQuery:
+			query = append(query, r)
+		}
+	}
+}
+
+// runPlain pages text on a terminal without cursor positioning.
+func runPlain(term *terminal.Terminal, text string, opts Options) (int, error) {
+	width, height := term.Size()
+	boxW := opts.Width
+	if boxW <= 0 || boxW > width {
+		boxW = width - 1
+	}
+	m := newModel(layout(text, boxW, !opts.NoWrap), max(height-2, 5), opts.Start)
+	if opts.Title != "" {
+		term.SendLn("")
+		term.SendLn(opts.Title)
+	}
+	for {
+		term.SendLn("")
+		for n := m.top; n < min(m.top+m.height, len(m.lines)); n++ {
+			term.SendLn(m.lines[n])
+		}
+		if m.top+m.height >= len(m.lines) {
+			return m.top + 1, nil
+		}
+		term.Send(fmt.Sprintf("-- %s -- [Enter] More, / Search, Q Quit: ", m.position()))
+		b, err := term.GetKey()
+		if err != nil {
+			return m.top + 1, err
+		}
+		term.SendLn("")
+		switch b {
+		case 'q', 'Q', 0x1b:
+			return m.top + 1, nil
+		case '/':
+			query, err := term.Ask("Search: ", maxQuery)
+			if err != nil {
+				return m.top + 1, err
+			}
+			if query == "" {
+				break
+			}
+			if m.search(query) {
+				m.scrollTo(m.match)
+			} else {
+				term.SendLn("Not found: " + query)
+			}
+		default:
+			m.handle(keyPageDown)
+		}
+	}
+}
+
+// readKey reads one keypress and maps it to a viewer key.
+func readKey(term *terminal.Terminal) (int, error) {
+	key, r, err := term.ReadKey()
+	if err != nil {
+		return keyNone, err
+	}
+	switch key {
+	case terminal.KeyEsc:
+		return keyQuit, nil
+	case terminal.KeyEnter, terminal.KeyDown:
+		return keyDown, nil
+	case terminal.KeyUp:
+		return keyUp, nil
+	case terminal.KeyPgUp, terminal.KeyLeft:
+		return keyPageUp, nil
+	case terminal.KeyPgDn, terminal.KeyRight:
+		return keyPageDown, nil
+	case terminal.KeyHome:
+		return keyHome, nil
+	case terminal.KeyEnd:
+		return keyEnd, nil
+	case terminal.KeyCtrl:
+		if r == 3 { // Ctrl-C
+			return keyQuit, nil
+		}
+	case terminal.KeyChar:
+		switch r {
+		case 'q', 'Q':
+			return keyQuit, nil
+		case 'j', 'J':
+			return keyDown, nil
+		case 'k', 'K':
+			return keyUp, nil
+		case ' ', 'f', 'F':
+			return keyPageDown, nil
+		case 'b', 'B':
+			return keyPageUp, nil
+		case 'g':
+			return keyHome, nil
+		case 'G':
+			return keyEnd, nil
+		case '/':
+			return keySearch, nil
+		case 'n', 'N':
+			return keyNext, nil
+		}
+	}
+	return keyNone, nil
+}
@@ -0,0 +1,109 @@
+package textview
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+)
+
+func TestDecodeAndClean(t *testing.T) {
+	if got := Decode([]byte("caf\xc3\xa9")); got != "café" {
+		t.Errorf("UTF-8 decoded to %q", got)
+	}
+	if got := Decode([]byte("\xc9\xcd\xbb caf\x82")); got != "╔═╗ café" {
+		t.Errorf("CP437 decoded to %q", got)
+	}
+	got := clean("\x1b[1;33mHi\x1b[0m\r\na\tb\x07\x1aSAUCE00")
+	if want := "Hi\na       b"; got != want {
+		t.Errorf("clean = %q, want %q", got, want)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	got := layout("the quick brown fox\n\njumps", 10, true)
+	want := []string{"the quick", "brown fox", "", "jumps"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("wrapped = %q, want %q", got, want)
+	}
+	if got := layout("the quick brown fox", 10, false); len(got) != 1 || got[0] != "the quick " {
+		t.Errorf("cut = %q", got)
+	}
+}
+
+func TestModel(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 50; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	lines[9] = "a Needle here"
+	lines[44] = "another needle"
+	m := newModel(lines, 20, 100)
+	if m.top != 30 {
+		t.Fatalf("start past the end: top = %d, want 30", m.top)
+	}
+	m.handle(keyHome)
+	m.handle(keyUp)
+	if m.top != 0 {
+		t.Fatalf("top = %d after Home and Up", m.top)
+	}
+	m.handle(keyPageDown)
+	m.handle(keyDown)
+	if m.top != 21 || m.position() != "Lines 22-41 of 50 (82%)" {
+		t.Fatalf("top = %d, position %q", m.top, m.position())
+	}
+
+	if !m.search("NEEDLE") || m.match != 44 || m.top != 30 {
+		t.Fatalf("first search: match %d, top %d", m.match, m.top)
+	}
+	if !m.search("NEEDLE") || m.match != 9 || m.top != 9 {
+		t.Fatalf("search wraps: match %d, top %d", m.match, m.top)
+	}
+	if m.search("haystack") || m.match != -1 {
+		t.Errorf("search for missing text matched line %d", m.match)
+	}
+	if !m.handle(keyQuit) {
+		t.Error("quit did not close the viewer")
+	}
+}
+
+func TestRun(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&out, client)
+		close(done)
+	}()
+
+	var text strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&text, "line %d\n", i)
+	}
+	text.WriteString("the end\n")
+
+	term := terminal.New(server, 80, 24, true)
+	term.SetCharset(terminal.CharsetCP437)
+	go client.Write([]byte("/THE END\r \x1b[5~q"))
+	top, err := Run(term, text.String()+"╔═╗", Options{Title: "Test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top != 57 {
+		t.Errorf("closed at line %d, want 57", top)
+	}
+	server.Close()
+	<-done
+	if !bytes.Contains(out.Bytes(), []byte("\xc9\xcd\xbb")) {
+		t.Error("box drawing not sent in CP437")
+	}
+	if !bytes.Contains(out.Bytes(), []byte("Lines 80-102 of 102")) {
+		t.Error("no status line at the end of the text")
+	}
+}
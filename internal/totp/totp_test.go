@@ -0,0 +1,50 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 appendix B SHA-1 key, "12345678901234567890".
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238(t *testing.T) {
+	// Appendix B values, truncated to six digits.
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil || got != want {
+			t.Errorf("Code at %d = %q, %v; want %q", unix, got, err, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	prev, _ := Code(secret, Step(now)-1)
+	if step, ok := Verify(secret, prev[:3]+" "+prev[3:], now); !ok || step != Step(now)-1 {
+		t.Errorf("previous step code: %d, %v", step, ok)
+	}
+	old, _ := Code(secret, Step(now)-3)
+	if _, ok := Verify(secret, old, now); ok {
+		t.Error("code three steps old accepted")
+	}
+	if _, ok := Verify(secret, "12345", now); ok {
+		t.Error("short code accepted")
+	}
+
+	uri := URI("Test BBS", "alice", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Test%20BBS:alice?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("URI = %q", uri)
+	}
+}
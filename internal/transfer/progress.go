@@ -0,0 +1,169 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often a running transfer reports progress.
+const progressInterval = time.Second
+
+// Progress is a snapshot of a running transfer.
+type Progress struct {
+	Elapsed  time.Duration
+	Sent     int64  // bytes bridged to the caller so far
+	Received int64  // bytes bridged from the caller so far
+	File     string // file being transferred, "" when the program does not say
+	FileSize int64  // its size, 0 = unknown
+	FileDone int64  // bytes of it done, 0 = unknown
+}
+
+// Percent returns how much of the current file is done, or -1 when the
+// program does not report it.
+func (p Progress) Percent() int {
+	if p.FileSize <= 0 {
+		return -1
+	}
+	return int(min(p.FileDone*100/p.FileSize, 100))
+}
+
+// CPS returns the bytes per second bridged so far in the busier direction.
+func (p Progress) CPS() int64 {
+	return cps(max(p.Sent, p.Received), p.Elapsed)
+}
+
+func cps(n int64, d time.Duration) int64 {
+	if d < time.Second {
+		d = time.Second
+	}
+	return int64(float64(n) / d.Seconds())
+}
+
+// countingWriter adds what is written through it to n, so a running
+// transfer's byte counts can be read while the copy goes on.
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// meter tracks a running transfer for Progress snapshots.
+type meter struct {
+	start    time.Time
+	sent     atomic.Int64
+	received atomic.Int64
+	status   statusLog
+}
+
+func (m *meter) snapshot() Progress {
+	file, size, done := m.status.status()
+	return Progress{
+		Elapsed:  time.Since(m.start),
+		Sent:     m.sent.Load(),
+		Received: m.received.Load(),
+		File:     file,
+		FileSize: size,
+		FileDone: done,
+	}
+}
+
+// report calls progress with a snapshot every progressInterval until stop
+// is closed, then once more with the final counts.
+func (m *meter) report(progress func(Progress), stop <-chan struct{}) {
+	t := time.NewTicker(progressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			progress(m.snapshot())
+		case <-stop:
+			progress(m.snapshot())
+			return
+		}
+	}
+}
+
+// SEXYZ status lines: "Sending NAME (N KB) via ..." or "Receiving ..." when
+// a file starts, and "KByte: done/total ..." as it goes.
+var (
+	statusStart = regexp.MustCompile(`(?:Sending|Receiving) (.+?) \((\d+) KB\)`)
+	statusKByte = regexp.MustCompile(`KByte:\s*(\d+)/(\d+)`)
+)
+
+// statusLog keeps what a protocol program writes to stderr, for error
+// messages, and follows its status lines for Progress. Output it does not
+// recognise is only kept.
+type statusLog struct {
+	mu   sync.Mutex
+	all  bytes.Buffer
+	line []byte
+	file string
+	size int64
+	done int64
+}
+
+func (s *statusLog) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.all.Write(p)
+	for _, b := range p {
+		if b == '\r' || b == '\n' {
+			s.parse(s.line)
+			s.line = s.line[:0]
+			continue
+		}
+		if len(s.line) < 512 {
+			s.line = append(s.line, b)
+		}
+	}
+	return len(p), nil
+}
+
+// parse reads one status line. The caller holds mu.
+func (s *statusLog) parse(line []byte) {
+	if m := statusStart.FindSubmatch(line); m != nil {
+		s.file = filepath.Base(string(m[1]))
+		kb, _ := strconv.ParseInt(string(m[2]), 10, 64)
+		s.size, s.done = kb*1024, 0
+		return
+	}
+	if m := statusKByte.FindSubmatch(line); m != nil {
+		done, _ := strconv.ParseInt(string(m[1]), 10, 64)
+		total, _ := strconv.ParseInt(string(m[2]), 10, 64)
+		s.done = done * 1024
+		if total > 0 {
+			s.size = total * 1024
+		}
+	}
+}
+
+// status returns the current file, its size and how much of it is done.
+func (s *statusLog) status() (string, int64, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file, s.size, s.done
+}
+
+// Len and String return everything written, for error messages.
+func (s *statusLog) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.all.Len()
+}
+
+func (s *statusLog) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.all.String()
+}
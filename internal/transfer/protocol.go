@@ -0,0 +1,97 @@
+package transfer
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Protocol is an external transfer program run on the caller's raw
+// connection (stdin and stdout). Send and Receive are argument templates:
+// "{files}" expands to the paths being sent, "{dir}" to the upload
+// directory (ending in "/"), and "{telnet}" to TelnetFlag on telnet
+// connections and to nothing otherwise.
+type Protocol struct {
+	Key        string   // picker hotkey, e.g. "Z"
+	Name       string   // shown to callers
+	Command    string   // program path, or a name looked up in PATH
+	Send       []string // download arguments; empty when it cannot send
+	Receive    []string // upload arguments; empty when it cannot receive
+	Env        []string // extra environment, "NAME=value"
+	TelnetFlag string   // tells the program to handle telnet IAC itself
+}
+
+// zmodem is the built-in ZMODEM-8K protocol run with SEXYZ.
+//
+// "-y" allows overwriting existing files and "-8" selects ZMODEM-8K
+// (ZedZap). SEXYZ concatenates the directory and file name, hence the
+// trailing "/" on {dir}. In stdio mode its telnet handling is off unless
+// -telnet is given.
+func (c *Config) zmodem() *Protocol {
+	return &Protocol{
+		Key:        "Z",
+		Name:       "ZMODEM-8K",
+		Command:    c.SexyzPath,
+		Send:       []string{"{telnet}", "-y", "-8", "sz", "{files}"},
+		Receive:    []string{"{telnet}", "-y", "-8", "rz", "{dir}"},
+		TelnetFlag: "-telnet",
+	}
+}
+
+// Protocols returns the protocols whose programs are installed: ZMODEM
+// through SEXYZ first, then the configured ones in order.
+func (c *Config) Protocols() []*Protocol {
+	var out []*Protocol
+	if z := c.zmodem(); z.installed() {
+		out = append(out, z)
+	}
+	for i := range c.Extra {
+		if p := &c.Extra[i]; p.installed() {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Protocol returns the installed protocol with the given key (any case), or
+// nil.
+func (c *Config) Protocol(key string) *Protocol {
+	for _, p := range c.Protocols() {
+		if strings.EqualFold(p.Key, key) {
+			return p
+		}
+	}
+	return nil
+}
+
+// installed reports whether the protocol's program exists.
+func (p *Protocol) installed() bool {
+	path := p.Command
+	if !strings.Contains(path, "/") {
+		found, err := exec.LookPath(path)
+		if err != nil {
+			return false
+		}
+		path = found
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Size() > 0
+}
+
+// expand fills in an argument template.
+func (p *Protocol) expand(tmpl []string, isTelnet bool, files []string, dir string) []string {
+	var args []string
+	for _, a := range tmpl {
+		switch a {
+		case "{telnet}":
+			if isTelnet && p.TelnetFlag != "" {
+				args = append(args, p.TelnetFlag)
+			}
+		case "{files}":
+			args = append(args, files...)
+		default:
+			args = append(args, strings.ReplaceAll(a, "{dir}", dir))
+		}
+	}
+	return args
+}
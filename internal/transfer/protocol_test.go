@@ -0,0 +1,136 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProtocolExpand(t *testing.T) {
+	c := &Config{SexyzPath: "/usr/local/bin/sexyz"}
+	z := c.zmodem()
+	got := z.expand(z.Send, true, []string{"/a", "/b"}, "")
+	want := []string{"-telnet", "-y", "-8", "sz", "/a", "/b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("send args = %q, want %q", got, want)
+	}
+	got = z.expand(z.Receive, false, nil, "/up/")
+	want = []string{"-y", "-8", "rz", "/up/"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("receive args = %q, want %q", got, want)
+	}
+}
+
+func TestExternalProtocolSend(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hello.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{
+		SexyzPath: filepath.Join(dir, "missing"),
+		Extra: []Protocol{{
+			Key:     "C",
+			Name:    "Cat",
+			Command: "sh",
+			Send:    []string{"-c", `printf "$GREETING"; cat "$@"; printf 'Sending x/hello.txt (1 KB)\rKByte: 1/1\n' >&2`, "sh", "{files}"},
+			Env:     []string{"GREETING=>"},
+		}},
+	}
+	all := c.Protocols()
+	if len(all) != 1 || c.Protocol("c") != all[0] {
+		t.Fatalf("protocols = %+v", all)
+	}
+
+	var out bytes.Buffer
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), &out}
+	var last Progress
+	result, err := c.Send(all[0], rw, false, func(p Progress) { last = p }, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 6 || result.Bytes() != 5 {
+		t.Fatalf("result sent %d bytes for %d file bytes", result.Sent, result.Bytes())
+	}
+	if last.Sent != 6 || last.File != "hello.txt" || last.Percent() != 100 {
+		t.Fatalf("final progress = %+v", last)
+	}
+	if len(result.Files) != 1 || result.Files[0].Name != "hello.txt" {
+		t.Fatalf("result = %+v", result.Files)
+	}
+	if got := out.String(); got != ">hello" {
+		t.Fatalf("connection got %q", got)
+	}
+	if _, err := c.Receive(all[0], rw, false, dir, nil); err == nil {
+		t.Fatal("Receive with a send-only protocol succeeded")
+	}
+}
+
+func TestStatusLog(t *testing.T) {
+	var s statusLog
+	s.Write([]byte("Receiving /up/big.zip (200 KB) via ZMODEM\r\nKByte: 5"))
+	s.Write([]byte("0/200 25%\r"))
+	if file, size, done := s.status(); file != "big.zip" || size != 200*1024 || done != 50*1024 {
+		t.Fatalf("status = %q %d %d", file, size, done)
+	}
+	if p := (Progress{FileSize: 200 * 1024, FileDone: 50 * 1024}); p.Percent() != 25 {
+		t.Fatalf("Percent = %d", p.Percent())
+	}
+	if !bytes.Contains([]byte(s.String()), []byte("big.zip")) {
+		t.Fatalf("stderr not kept: %q", s.String())
+	}
+}
+
+func TestReceiveKeepsExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	area := filepath.Join(dir, "area")
+	if err := os.MkdirAll(area, 0755); err != nil {
+		t.Fatal(err)
+	}
+	mine := filepath.Join(area, "hello.txt")
+	if err := os.WriteFile(mine, []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{
+		SexyzPath:  filepath.Join(dir, "missing"),
+		StagingDir: filepath.Join(dir, "staging"),
+		Extra: []Protocol{{
+			Key:     "C",
+			Name:    "Cat",
+			Command: "sh",
+			Receive: []string{"-c", `for f in $NAMES; do printf theirs > "$1$f"; done`, "sh", "{dir}"},
+		}},
+	}
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), io.Discard}
+
+	c.Extra[0].Env = []string{"NAMES=hello.txt new.txt"}
+	result, err := c.Receive(c.Protocols()[0], rw, false, area, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Name != "new.txt" {
+		t.Fatalf("received %+v, want only new.txt", result.Files)
+	}
+	if data, _ := os.ReadFile(mine); string(data) != "mine" {
+		t.Fatalf("existing file now holds %q", data)
+	}
+
+	c.Extra[0].Env = []string{"NAMES=hello.txt"}
+	if _, err := c.Receive(c.Protocols()[0], rw, false, area, nil); err == nil {
+		t.Fatal("upload of only an existing name succeeded")
+	}
+	if data, _ := os.ReadFile(mine); string(data) != "mine" {
+		t.Fatalf("existing file now holds %q", data)
+	}
+}
@@ -1,7 +1,6 @@
 package transfer
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -10,22 +9,29 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"sync/atomic"
 	"time"
 )
 
 // defaultTimeout is the maximum duration for a single transfer.
 const defaultTimeout = 30 * time.Minute
 
-// Send initiates a ZMODEM-8K download (BBS → user) of the given files
-// using SEXYZ via the supplied raw ReadWriter.
+// Send initiates a download (BBS → user) of the given files with protocol
+// p, ZMODEM-8K through SEXYZ when p is nil, via the supplied raw
+// ReadWriter.
 //
-// If isTelnet is true, the -telnet flag is passed to SEXYZ so it handles
-// IAC escaping/filtering itself.
-func (c *Config) Send(rw io.ReadWriter, isTelnet bool, filePaths ...string) (*Result, error) {
+// If isTelnet is true, the protocol's telnet flag is passed so the program
+// handles IAC escaping/filtering itself. A non-nil progress is called about
+// once a second while the program runs and once when it exits.
+func (c *Config) Send(p *Protocol, rw io.ReadWriter, isTelnet bool, progress func(Progress), filePaths ...string) (*Result, error) {
 	if len(filePaths) == 0 {
 		return nil, fmt.Errorf("no files to send")
 	}
+	if p == nil {
+		p = c.zmodem()
+	}
+	if len(p.Send) == 0 {
+		return nil, fmt.Errorf("%s cannot send files", p.Name)
+	}
 
 	// Convert all paths to absolute and verify they exist.
 	absPaths := make([]string, len(filePaths))
@@ -48,15 +54,11 @@ func (c *Config) Send(rw io.ReadWriter, isTelnet bool, filePaths ...string) (*Re
 		}
 	}
 
-	// Build SEXYZ command:
-	//   sexyz [-telnet] -y -8 sz <file1> [file2 ...]
-	//
-	// "sz" = send via ZMODEM-8K.
-	args := buildArgs(isTelnet, "sz", absPaths)
+	args := p.expand(p.Send, isTelnet, absPaths, "")
 
-	log.Printf("[transfer] SEND starting: sexyz %v", args)
+	log.Printf("[transfer] SEND starting (%s): %s %v", p.Name, p.Command, args)
 
-	result, err := c.run(rw, args, "")
+	result, err := c.run(p, rw, args, "", progress)
 	if err != nil {
 		return nil, formatError("send failed", err)
 	}
@@ -74,14 +76,23 @@ func (c *Config) Send(rw io.ReadWriter, isTelnet bool, filePaths ...string) (*Re
 		})
 	}
 
-	log.Printf("[transfer] SEND complete: %d file(s)", len(result.Files))
+	log.Printf("[transfer] SEND complete: %d file(s), %d bytes in %v (%d cps)",
+		len(result.Files), result.Bytes(), result.Elapsed.Round(time.Second), result.CPS())
 	return result, nil
 }
 
-// Receive initiates a ZMODEM upload (user → BBS) into the given directory
-// using SEXYZ via the supplied raw ReadWriter. Returns information about
-// the file(s) received.
-func (c *Config) Receive(rw io.ReadWriter, isTelnet bool, uploadDir string) (*Result, error) {
+// Receive initiates an upload (user → BBS) into the given directory with
+// protocol p, ZMODEM through SEXYZ when p is nil, via the supplied raw
+// ReadWriter. Returns information about the file(s) received. progress is
+// as for Send.
+func (c *Config) Receive(p *Protocol, rw io.ReadWriter, isTelnet bool, uploadDir string, progress func(Progress)) (*Result, error) {
+	if p == nil {
+		p = c.zmodem()
+	}
+	if len(p.Receive) == 0 {
+		return nil, fmt.Errorf("%s cannot receive files", p.Name)
+	}
+
 	// Convert to absolute path so SEXYZ resolves it unambiguously.
 	absDir, err := filepath.Abs(uploadDir)
 	if err != nil {
@@ -97,7 +108,7 @@ func (c *Config) Receive(rw io.ReadWriter, isTelnet bool, uploadDir string) (*Re
 	}
 
 	// SEXYZ concatenates the directory path with the filename directly,
-	// so it MUST end with a path separator.
+	// so {dir} always ends with a path separator.
 	if !strings.HasSuffix(absDir, "/") {
 		absDir += "/"
 	}
@@ -107,32 +118,52 @@ func (c *Config) Receive(rw io.ReadWriter, isTelnet bool, uploadDir string) (*Re
 		return nil, formatError("create upload dir", err)
 	}
 
+	// With a staging dir configured, receive into a private per-transfer
+	// directory and only move completed files into the area afterwards, so
+	// partial uploads never appear in the file area. Staging dirs left by a
+	// crash are removed by the cleanup sweeper.
+	targetDir := absDir
+	if c.StagingDir != "" {
+		if err := os.MkdirAll(c.StagingDir, 0755); err != nil {
+			return nil, formatError("create staging dir", err)
+		}
+		stage, err := os.MkdirTemp(c.StagingDir, "upload-")
+		if err != nil {
+			return nil, formatError("create staging dir", err)
+		}
+		defer os.RemoveAll(stage)
+		absStage, err := filepath.Abs(stage)
+		if err != nil {
+			return nil, formatError("resolve staging dir", err)
+		}
+		absDir = absStage + "/"
+	}
+
 	// Snapshot existing files before the transfer so we can detect new ones.
 	before, err := snapshotDir(absDir)
 	if err != nil {
 		return nil, formatError("snapshot dir", err)
 	}
 
-	// Build SEXYZ command:
-	//   sexyz [-telnet] -y -8 rz <absDir>
-	//
-	// "rz" = receive via ZMODEM. -y allows overwriting existing files.
-	// The absolute directory path tells SEXYZ where to save received files.
-	args := buildArgs(isTelnet, "rz", nil)
-	args = append(args, absDir)
+	// The absolute directory path tells the program where to save received
+	// files. Programs that ignore it receive into their working directory,
+	// which is the same directory.
+	args := p.expand(p.Receive, isTelnet, nil, absDir)
 
-	log.Printf("[transfer] RECEIVE starting into %s: sexyz %v", absDir, args)
+	log.Printf("[transfer] RECEIVE starting into %s (%s): %s %v", absDir, p.Name, p.Command, args)
 
-	// Don't set workDir — the absolute path in args is sufficient.
-	_, runErr := c.run(rw, args, "")
+	run, runErr := c.run(p, rw, args, absDir, progress)
 
-	// Even if SEXYZ exits non-zero (e.g. user cancelled), check what arrived.
+	// Even if the program exits non-zero (e.g. user cancelled), check what arrived.
 	after, err := snapshotDir(absDir)
 	if err != nil {
 		return nil, formatError("snapshot dir after receive", err)
 	}
 
 	result := &Result{}
+	if run != nil {
+		result.Sent, result.Received, result.Elapsed = run.Sent, run.Received, run.Elapsed
+	}
 	for name, size := range after {
 		if _, existed := before[name]; !existed {
 			result.Files = append(result.Files, TransferredFile{
@@ -146,13 +177,44 @@ func (c *Config) Receive(rw io.ReadWriter, isTelnet bool, uploadDir string) (*Re
 		return nil, formatError("receive failed", runErr)
 	}
 
-	log.Printf("[transfer] RECEIVE complete: %d new file(s)", len(result.Files))
+	if targetDir != absDir {
+		// A failed run may leave a truncated file behind; only keep files
+		// from a clean exit.
+		if runErr != nil {
+			return nil, formatError("receive failed", runErr)
+		}
+		// Every staged file is new to the stage, so check the area: a file
+		// already there is never replaced by an upload of the same name.
+		var kept []TransferredFile
+		var refused []string
+		for _, f := range result.Files {
+			dst := filepath.Join(targetDir, f.Name)
+			if _, err := os.Lstat(dst); err == nil {
+				log.Printf("[transfer] RECEIVE refused %s: already exists in %s", f.Name, targetDir)
+				refused = append(refused, f.Name)
+				continue
+			}
+			if err := MoveFile(filepath.Join(absDir, f.Name), dst); err != nil {
+				return nil, formatError("move upload", err)
+			}
+			kept = append(kept, f)
+		}
+		result.Files = kept
+		if len(kept) == 0 && len(refused) > 0 {
+			return nil, fmt.Errorf("%s already exists", strings.Join(refused, ", "))
+		}
+	}
+
+	log.Printf("[transfer] RECEIVE complete: %d new file(s), %d bytes in %v (%d cps)",
+		len(result.Files), result.Bytes(), result.Elapsed.Round(time.Second), result.CPS())
 	return result, nil
 }
 
-// run spawns SEXYZ with the given arguments, bridges I/O between the
-// raw connection and the process, and waits for completion.
-func (c *Config) run(rw io.ReadWriter, args []string, workDir string) (*Result, error) {
+// run spawns the protocol's program with the given arguments, bridges I/O
+// between the raw connection and the process, and waits for completion.
+// The result carries the bridged byte counts and elapsed time; a non-nil
+// progress gets snapshots while it runs.
+func (c *Config) run(p *Protocol, rw io.ReadWriter, args []string, workDir string, progress func(Progress)) (*Result, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
@@ -166,58 +228,70 @@ func (c *Config) run(rw io.ReadWriter, args []string, workDir string) (*Result,
 		return nil, fmt.Errorf("create socketpair: %w", err)
 	}
 
-	cmd := exec.CommandContext(ctx, c.SexyzPath, args...)
+	cmd := exec.CommandContext(ctx, p.Command, args...)
 	if workDir != "" {
 		cmd.Dir = workDir
 	}
+	if len(p.Env) > 0 {
+		cmd.Env = append(os.Environ(), p.Env...)
+	}
 	cmd.Stdin = childFile
 	cmd.Stdout = childFile
-	// Capture stderr so we can return actionable errors.
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	// Capture stderr so we can return actionable errors; the meter also
+	// follows the program's status lines in it.
+	m := &meter{}
+	stderr := &m.status
+	cmd.Stderr = stderr
 
 	if err := cmd.Start(); err != nil {
 		childFile.Close()
 		parentConn.Close()
-		return nil, fmt.Errorf("start sexyz: %w", err)
+		return nil, fmt.Errorf("start %s: %w", p.Name, err)
 	}
-	// Child fd is now inherited by SEXYZ; close our copy so EOF propagates.
+	// Child fd is now inherited by the program; close our copy so EOF propagates.
 	childFile.Close()
 
-	log.Printf("[transfer] sexyz started (pid %d): %s %s", cmd.Process.Pid, c.SexyzPath, strings.Join(args, " "))
+	log.Printf("[transfer] %s started (pid %d): %s %s", p.Name, cmd.Process.Pid, p.Command, strings.Join(args, " "))
 
-	// Bridge I/O: remote client <-> SEXYZ process (via socketpair)
-	var inputBytes int64
-	var outputBytes int64
+	// Bridge I/O: remote client <-> program (via socketpair), counting as
+	// it goes so progress can be reported mid-transfer.
+	m.start = time.Now()
+	stopReport := make(chan struct{})
+	reportDone := make(chan struct{})
+	go func() {
+		defer close(reportDone)
+		if progress != nil {
+			m.report(progress, stopReport)
+		}
+	}()
 
 	inputDone := make(chan struct{})
 	go func() {
 		defer close(inputDone)
-		n, err := io.Copy(parentConn, rw)
-		atomic.StoreInt64(&inputBytes, n)
-		log.Printf("[transfer] input goroutine done: %d bytes client→sexyz, err=%v", n, err)
+		_, err := io.Copy(countingWriter{parentConn, &m.received}, rw)
+		log.Printf("[transfer] input goroutine done: %d bytes client→%s, err=%v", m.received.Load(), p.Name, err)
 	}()
 
 	outputDone := make(chan struct{})
 	go func() {
 		defer close(outputDone)
-		n, err := io.Copy(rw, parentConn)
-		atomic.StoreInt64(&outputBytes, n)
-		log.Printf("[transfer] output goroutine done: %d bytes sexyz→client, err=%v", n, err)
+		_, err := io.Copy(countingWriter{rw, &m.sent}, parentConn)
+		log.Printf("[transfer] output goroutine done: %d bytes %s→client, err=%v", m.sent.Load(), p.Name, err)
 	}()
 
-	// Wait for SEXYZ to exit.
+	// Wait for the program to exit.
 	waitErr := cmd.Wait()
+	elapsed := time.Since(m.start)
 
-	log.Printf("[transfer] sexyz exited: err=%v, input=%d bytes, output=%d bytes",
-		waitErr, atomic.LoadInt64(&inputBytes), atomic.LoadInt64(&outputBytes))
+	log.Printf("[transfer] %s exited: err=%v, input=%d bytes, output=%d bytes",
+		p.Name, waitErr, m.received.Load(), m.sent.Load())
 
 	// Close our end of the socketpair to unblock the copy goroutines.
 	parentConn.Close()
 
 	// Always log stderr for debugging.
 	if stderr.Len() > 0 {
-		log.Printf("[transfer] sexyz stderr:\n%s", stderr.String())
+		log.Printf("[transfer] %s stderr:\n%s", p.Name, stderr.String())
 	}
 
 	// Wait for the output goroutine to drain any remaining data.
@@ -230,38 +304,28 @@ func (c *Config) run(rw io.ReadWriter, args []string, workDir string) (*Result,
 	// The input goroutine may be blocked on rw.Read(); it will unblock
 	// when the connection sends more data or closes. We don't wait.
 
+	close(stopReport)
+	<-reportDone
+
 	if ctx.Err() == context.DeadlineExceeded {
 		return nil, fmt.Errorf("transfer timed out after %v", defaultTimeout)
 	}
 
-	result := &Result{}
+	result := &Result{Sent: m.sent.Load(), Received: m.received.Load(), Elapsed: elapsed}
 	if waitErr != nil {
 		if stderr.Len() > 0 {
 			waitErr = fmt.Errorf("%w: %s", waitErr, strings.TrimSpace(stderr.String()))
 		}
-		// Non-zero exit from SEXYZ. This can happen if the user cancels
+		// Non-zero exit from the program. This can happen if the user cancels
 		// the transfer. We return the error but the caller may still check
 		// for partially received files.
 		result.Error = waitErr
-		log.Printf("[transfer] sexyz exited with: %v", waitErr)
+		log.Printf("[transfer] %s exited with: %v", p.Name, waitErr)
 	}
 
 	return result, waitErr
 }
 
-// buildArgs constructs the SEXYZ command-line arguments.
-func buildArgs(isTelnet bool, mode string, files []string) []string {
-	var args []string
-	if isTelnet {
-		// In stdio mode, Telnet handling is disabled by default.
-		args = append(args, "-telnet")
-	}
-	// -y allows overwriting existing files. -8 selects ZMODEM-8K (ZedZap).
-	args = append(args, "-y", "-8", mode)
-	args = append(args, files...)
-	return args
-}
-
 // snapshotDir returns a map of filename → size for all regular files
 // in the given directory (non-recursive).
 func snapshotDir(dir string) (map[string]int64, error) {
@@ -282,3 +346,31 @@ func snapshotDir(dir string) (map[string]int64, error) {
 	}
 	return m, nil
 }
+
+// MoveFile renames src to dst, falling back to copy+remove when they are on
+// different filesystems.
+func MoveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -2,13 +2,15 @@ package transfer
 
 import (
 	"fmt"
-	"os"
+	"time"
 )
 
 // Config holds file transfer protocol settings.
 type Config struct {
 	SexyzPath     string         // path to the sexyz binary
 	PathValidator *PathValidator // optional validator for file paths
+	StagingDir    string         // optional dir for in-progress uploads
+	Extra         []Protocol     // configured protocols besides ZMODEM
 }
 
 // TransferredFile describes a single file that was transferred.
@@ -19,18 +21,30 @@ type TransferredFile struct {
 
 // Result describes the outcome of a file transfer operation.
 type Result struct {
-	Files []TransferredFile
-	Error error
+	Files    []TransferredFile
+	Error    error
+	Sent     int64         // bytes bridged to the caller, protocol overhead included
+	Received int64         // bytes bridged from the caller
+	Elapsed  time.Duration // how long the program ran
 }
 
-// Available checks whether the SEXYZ binary exists and is executable.
-func (c *Config) Available() bool {
-	info, err := os.Stat(c.SexyzPath)
-	if err != nil {
-		return false
+// Bytes returns the total size of the transferred files.
+func (r *Result) Bytes() int64 {
+	var n int64
+	for _, f := range r.Files {
+		n += f.Size
 	}
-	// On Linux, check that it's not a directory and has some size.
-	return !info.IsDir() && info.Size() > 0
+	return n
+}
+
+// CPS returns the transfer's throughput in file bytes per second.
+func (r *Result) CPS() int64 {
+	return cps(r.Bytes(), r.Elapsed)
+}
+
+// Available reports whether any transfer protocol is installed.
+func (c *Config) Available() bool {
+	return len(c.Protocols()) > 0
 }
 
 // formatError creates a user-friendly error message.
@@ -6,11 +6,17 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-const bcryptCost = 12
+// DefaultBcryptCost is the bcrypt work factor used when the password policy
+// does not set one.
+const DefaultBcryptCost = 12
 
-// HashPassword hashes a plaintext password with bcrypt.
+// HashPassword hashes a plaintext password with bcrypt at the default cost.
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	return hashPassword(password, DefaultBcryptCost)
+}
+
+func hashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return "", fmt.Errorf("hash password: %w", err)
 	}
@@ -22,3 +28,9 @@ func CheckPassword(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// needsRehash reports whether hash was made with a lower cost than cost.
+func needsRehash(hash string, cost int) bool {
+	c, err := bcrypt.Cost([]byte(hash))
+	return err == nil && c < cost
+}
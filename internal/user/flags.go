@@ -0,0 +1,37 @@
+package user
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NormalizeFlags returns flags as upper-case letters A-Z, sorted and without
+// repeats. Spaces and commas are ignored so "a, d" and "DA" both give "AD".
+func NormalizeFlags(flags string) (string, error) {
+	seen := make(map[rune]bool)
+	var out []rune
+	for _, r := range strings.ToUpper(flags) {
+		switch {
+		case r == ' ' || r == ',':
+			continue
+		case r < 'A' || r > 'Z':
+			return "", fmt.Errorf("invalid flag %q: flags are letters A-Z", r)
+		case !seen[r]:
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return string(out), nil
+}
+
+// HasFlags reports whether the user holds every flag in required.
+func (u *User) HasFlags(required string) bool {
+	for _, r := range strings.ToUpper(required) {
+		if r >= 'A' && r <= 'Z' && !strings.ContainsRune(u.Flags, r) {
+			return false
+		}
+	}
+	return true
+}
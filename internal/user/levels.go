@@ -0,0 +1,148 @@
+package user
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Level is a named security level from the sysop's level table, seeded
+// with New, Validated, Regular, Trusted, CoSysop and Sysop. A user belongs
+// to the highest entry at or below their security level, so a user at 40
+// is Regular with the default table.
+type Level struct {
+	Value     int
+	Name      string
+	Color     int    // Palette color 0-15 the name is shown in, 8-15 bright
+	TimeLimit int    // minutes per call, 0 = unlimited
+	Ratio     int    // download ratio, 0 = the board's, -1 = exempt
+	Flags     string // flags granted when a user is set to this level
+}
+
+// Levels returns the level table, lowest first.
+func (r *Repo) Levels() ([]Level, error) {
+	rows, err := r.db.Query(`
+		SELECT level, name, color, time_limit, ratio, flags FROM security_levels ORDER BY level
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list levels: %w", err)
+	}
+	defer rows.Close()
+	var levels []Level
+	for rows.Next() {
+		var l Level
+		if err := rows.Scan(&l.Value, &l.Name, &l.Color, &l.TimeLimit, &l.Ratio, &l.Flags); err != nil {
+			return nil, fmt.Errorf("list levels: %w", err)
+		}
+		levels = append(levels, l)
+	}
+	return levels, rows.Err()
+}
+
+// LevelOf returns the level a security level falls in: the highest entry
+// at or below it. Below every entry, it is a nameless Level of that value.
+func (r *Repo) LevelOf(level int) (Level, error) {
+	var l Level
+	err := r.db.QueryRow(`
+		SELECT level, name, color, time_limit, ratio, flags FROM security_levels
+		WHERE level <= ? ORDER BY level DESC LIMIT 1
+	`, level).Scan(&l.Value, &l.Name, &l.Color, &l.TimeLimit, &l.Ratio, &l.Flags)
+	if err == sql.ErrNoRows {
+		return Level{Value: level, Color: 7}, nil
+	}
+	if err != nil {
+		return Level{}, fmt.Errorf("get level: %w", err)
+	}
+	return l, nil
+}
+
+// LevelName returns the name of the level a security level falls in,
+// or the number when it has none.
+func (r *Repo) LevelName(level int) string {
+	if l, err := r.LevelOf(level); err == nil && l.Name != "" {
+		return l.Name
+	}
+	return fmt.Sprint(level)
+}
+
+// LevelByName returns the entry named name, ignoring case.
+func (r *Repo) LevelByName(name string) (Level, bool) {
+	levels, err := r.Levels()
+	if err != nil {
+		return Level{}, false
+	}
+	for _, l := range levels {
+		if strings.EqualFold(l.Name, name) {
+			return l, true
+		}
+	}
+	return Level{}, false
+}
+
+// SaveLevel adds an entry to the level table or replaces the one with
+// the same value.
+func (r *Repo) SaveLevel(l Level) error {
+	if l.Value < 0 || l.Value > LevelSysop {
+		return fmt.Errorf("level must be 0-%d, got %d", LevelSysop, l.Value)
+	}
+	if strings.TrimSpace(l.Name) == "" {
+		return fmt.Errorf("level %d needs a name", l.Value)
+	}
+	if l.Color < 0 || l.Color > 15 {
+		return fmt.Errorf("color must be 0-15, got %d", l.Color)
+	}
+	if l.TimeLimit < 0 || l.Ratio < -1 {
+		return fmt.Errorf("time limit must not be negative and ratio not below -1")
+	}
+	flags, err := NormalizeFlags(l.Flags)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		INSERT INTO security_levels (level, name, color, time_limit, ratio, flags) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(level) DO UPDATE SET name = excluded.name, color = excluded.color,
+			time_limit = excluded.time_limit, ratio = excluded.ratio, flags = excluded.flags
+	`, l.Value, strings.TrimSpace(l.Name), l.Color, l.TimeLimit, l.Ratio, flags)
+	if err != nil {
+		return fmt.Errorf("save level: %w", err)
+	}
+	return nil
+}
+
+// DeleteLevel removes the entry for value from the level table. Users at
+// that level fall into the entry below it.
+func (r *Repo) DeleteLevel(value int) error {
+	res, err := r.db.Exec(`DELETE FROM security_levels WHERE level = ?`, value)
+	if err != nil {
+		return fmt.Errorf("delete level: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no level %d", value)
+	}
+	return nil
+}
+
+// SetLevel changes a user's security level and grants the flags of the
+// level it falls in, keeping the flags they already hold.
+func (r *Repo) SetLevel(id, level int) error {
+	u, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	l, err := r.LevelOf(level)
+	if err != nil {
+		return err
+	}
+	flags, err := NormalizeFlags(u.Flags + l.Flags)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		UPDATE users SET security_level = ?, flags = ?, updated_at = ? WHERE id = ?
+	`, level, flags, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set level: %w", err)
+	}
+	return nil
+}
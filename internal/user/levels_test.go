@@ -0,0 +1,66 @@
+package user
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestLevels(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+
+	levels, err := repo.Levels()
+	if err != nil || len(levels) != 6 || levels[0].Name != "New" || levels[5].Value != LevelSysop {
+		t.Fatalf("seeded levels = %+v, %v", levels, err)
+	}
+	for level, want := range map[int]string{5: "5", 10: "New", 40: "Regular", 99: "CoSysop", 100: "Sysop"} {
+		if got := repo.LevelName(level); got != want {
+			t.Errorf("LevelName(%d) = %q, want %q", level, got, want)
+		}
+	}
+	if l, ok := repo.LevelByName("trusted"); !ok || l.Value != LevelTrusted {
+		t.Errorf("LevelByName(trusted) = %+v, %v", l, ok)
+	}
+
+	if err := repo.SaveLevel(Level{Value: 40, Name: "Elite", Color: 13, TimeLimit: 90, Flags: "ed"}); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := repo.LevelOf(45); err != nil || l.Name != "Elite" || l.Flags != "DE" || l.TimeLimit != 90 {
+		t.Errorf("LevelOf(45) = %+v, %v", l, err)
+	}
+	for _, bad := range []Level{{Value: 40}, {Value: 101, Name: "x"}, {Value: 40, Name: "x", Color: 16}, {Value: 40, Name: "x", Ratio: -2}} {
+		if err := repo.SaveLevel(bad); err == nil {
+			t.Errorf("SaveLevel(%+v) accepted", bad)
+		}
+	}
+
+	u, err := repo.Create("alice", "secret99", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetFlags(u.ID, "A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetLevel(u.ID, 40); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ = repo.GetByID(u.ID); u.SecurityLevel != 40 || u.Flags != "ADE" {
+		t.Errorf("after SetLevel: level %d flags %q", u.SecurityLevel, u.Flags)
+	}
+
+	if err := repo.DeleteLevel(40); err != nil {
+		t.Fatal(err)
+	}
+	if got := repo.LevelName(45); got != "Regular" {
+		t.Errorf("after delete, LevelName(45) = %q", got)
+	}
+	if err := repo.DeleteLevel(40); err == nil {
+		t.Error("deleted a missing level")
+	}
+}
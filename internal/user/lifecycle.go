@@ -0,0 +1,229 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// State is where an account is in its lifecycle. Only active accounts can
+// log in.
+type State string
+
+const (
+	StateActive  State = "active"
+	StateLocked  State = "locked"  // locked by the sysop
+	StateExpired State = "expired" // no calls for too long
+	StateDeleted State = "deleted" // soft-deleted; the name stays taken until purged
+)
+
+// PurgedPrefix starts the user name of a purged account, followed by its ID.
+const PurgedPrefix = "deleted-"
+
+// StateError is returned by Authenticate for an account that is not active.
+type StateError struct {
+	State  State
+	Reason string // the sysop's reason, for locked accounts
+}
+
+func (e *StateError) Error() string {
+	switch e.State {
+	case StateLocked:
+		if e.Reason != "" {
+			return "account locked: " + e.Reason
+		}
+		return "account locked by the sysop"
+	case StateExpired:
+		return "account expired for lack of calls; ask the sysop to reactivate it"
+	case StateDeleted:
+		return "account deleted"
+	}
+	return "account " + string(e.State)
+}
+
+// loginError returns the error Authenticate gives for an account in state
+// s, or nil if it may log in.
+func (s State) loginError(reason string) error {
+	if s == StateActive || s == "" {
+		return nil
+	}
+	return &StateError{State: s, Reason: reason}
+}
+
+// LoginError returns the *StateError a login as u fails with, or nil if
+// the account may log in.
+func (u *User) LoginError() error {
+	return u.State.loginError(u.StateReason)
+}
+
+// Lock stops a user from logging in until Unlock. The reason is shown at
+// login.
+func (r *Repo) Lock(id int, reason string) error {
+	return r.setState(id, StateLocked, reason, StateActive, StateExpired)
+}
+
+// Unlock makes a locked or expired account active again. The inactivity
+// period restarts, so an expired account is not expired again at once.
+func (r *Repo) Unlock(id int) error {
+	return r.setState(id, StateActive, "", StateLocked, StateExpired)
+}
+
+// Delete soft-deletes a user: the account can no longer log in but keeps
+// its name and data until Purge.
+func (r *Repo) Delete(id int) error {
+	return r.setState(id, StateDeleted, "", StateActive, StateLocked, StateExpired)
+}
+
+// Restore undoes Delete for an account that has not been purged.
+func (r *Repo) Restore(id int) error {
+	u, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if u.Purged() {
+		return fmt.Errorf("user %d has been purged", id)
+	}
+	return r.setState(id, StateActive, "", StateDeleted)
+}
+
+// setState moves a user to state, provided it is in one of from.
+func (r *Repo) setState(id int, state State, reason string, from ...State) error {
+	u, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	allowed := false
+	for _, f := range from {
+		allowed = allowed || u.State == f
+	}
+	if !allowed {
+		return fmt.Errorf("user %s is %s, cannot make it %s", u.Username, u.State, state)
+	}
+	_, err = r.db.Exec(`
+		UPDATE users SET state = ?, state_reason = ?, state_changed_at = ?, updated_at = ?
+		WHERE id = ?
+	`, state, reason, time.Now(), time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set state of user %d: %w", id, err)
+	}
+	return nil
+}
+
+// Purged reports whether the account has been anonymized by Purge.
+func (u *User) Purged() bool {
+	return u.State == StateDeleted && u.Username == PurgedPrefix+strconv.Itoa(u.ID)
+}
+
+// ExpireInactive expires active accounts with no call, and no change of
+// state, in the last days days. Accounts at exemptLevel or above and
+// accounts that cannot log in (see CreateLocked) are left alone. It
+// returns how many accounts were expired.
+func (r *Repo) ExpireInactive(days, exemptLevel int) (int, error) {
+	if days <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	now := time.Now()
+	res, err := r.db.Exec(`
+		UPDATE users SET state = ?, state_reason = '', state_changed_at = ?, updated_at = ?
+		WHERE state = ? AND security_level < ? AND password_hash != '!'
+		  AND COALESCE(last_call_at, created_at) < ?
+		  AND COALESCE(state_changed_at, created_at) < ?
+	`, StateExpired, now, now, StateActive, exemptLevel, cutoff, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("expire inactive users: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// Purge anonymizes a deleted account. The row stays, renamed to
+// PurgedPrefix plus its ID, so messages and mail it sent keep a valid
+// author; its personal details, password, keys, settings, profile and
+// unread mail are removed, and the callers log forgets its name.
+func (r *Repo) Purge(id int) error {
+	u, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if u.State != StateDeleted {
+		return fmt.Errorf("user %s is %s; delete it before purging", u.Username, u.State)
+	}
+	if u.Purged() {
+		return nil
+	}
+	name := PurgedPrefix + strconv.Itoa(id)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("purge user %d: %w", id, err)
+	}
+	defer tx.Rollback()
+
+	stmts := []struct {
+		sql  string
+		args []any
+	}{
+		{`UPDATE users SET username = ?, password_hash = '!', real_name = '', location = '', email = '',
+			birthday = '', flags = '', state_reason = '', updated_at = ? WHERE id = ?`, []any{name, time.Now(), id}},
+		{`DELETE FROM user_ssh_keys WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM user_settings WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM user_totp WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM user_backup_codes WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM user_profiles WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM bulletin_seen WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM message_read WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM private_mail WHERE to_user_id = ?`, []any{id}},
+		{`UPDATE callers SET username = ?, remote = '' WHERE user_id = ?`, []any{name, id}},
+		{`UPDATE door_sessions SET username = ? WHERE user_id = ?`, []any{name, id}},
+	}
+	for _, st := range stmts {
+		if _, err := tx.Exec(st.sql, st.args...); err != nil {
+			return fmt.Errorf("purge user %d: %w", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("purge user %d: %w", id, err)
+	}
+	return nil
+}
+
+// PurgeDeleted purges accounts deleted more than days days ago and returns
+// how many it purged.
+func (r *Repo) PurgeDeleted(days int) (int, error) {
+	if days <= 0 {
+		return 0, nil
+	}
+	rows, err := r.db.Query(`
+		SELECT id FROM users
+		WHERE state = ? AND COALESCE(state_changed_at, updated_at) < ? AND username != ? || id
+	`, StateDeleted, time.Now().AddDate(0, 0, -days), PurgedPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("list deleted users: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("list deleted users: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("list deleted users: %w", err)
+	}
+
+	var errs []error
+	n := 0
+	for _, id := range ids {
+		if err := r.Purge(id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
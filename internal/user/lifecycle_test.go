@@ -0,0 +1,162 @@
+package user
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestLockAndUnlock(t *testing.T) {
+	repo, _ := lifecycleRepo(t)
+	u, err := repo.Create("alice", "tangerine7", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Lock(u.ID, "spamming the wall"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.Authenticate("alice", "tangerine7")
+	var se *StateError
+	if !errors.As(err, &se) || se.State != StateLocked {
+		t.Fatalf("locked login err = %v", err)
+	}
+	if err.Error() != "account locked: spamming the wall" {
+		t.Errorf("message = %q", err)
+	}
+	if _, err := repo.Authenticate("alice", "wrong"); err == nil || errors.As(err, &se) {
+		t.Errorf("wrong password on a locked account gave %v, want invalid password", err)
+	}
+	if ok, _ := repo.AuthenticateForSSH("alice", "tangerine7"); ok {
+		t.Error("SSH accepted a locked account")
+	}
+
+	if err := repo.Unlock(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Authenticate("alice", "tangerine7"); err != nil {
+		t.Errorf("unlocked login: %v", err)
+	}
+	if err := repo.Unlock(u.ID); err == nil {
+		t.Error("unlocked an active account")
+	}
+}
+
+func TestExpireInactive(t *testing.T) {
+	repo, database := lifecycleRepo(t)
+	old, _ := repo.Create("oldtimer", "tangerine7", "", "", "")
+	recent, _ := repo.Create("regular", "tangerine7", "", "", "")
+	sysop, _ := repo.Create("thesysop", "tangerine7", "", "", "")
+	repo.UpdateSecurityLevel(sysop.ID, LevelSysop)
+	owner, _ := repo.CreateLocked("usenet")
+	long := time.Now().AddDate(0, 0, -400)
+	database.Exec(`UPDATE users SET last_call_at = ?, created_at = ? WHERE id IN (?, ?, ?)`, long, long, old.ID, sysop.ID, owner.ID)
+	database.Exec(`UPDATE users SET created_at = ? WHERE id = ?`, long, recent.ID)
+	database.Exec(`UPDATE users SET last_call_at = ? WHERE id = ?`, time.Now(), recent.ID)
+
+	n, err := repo.ExpireInactive(365, LevelCoSysop)
+	if err != nil || n != 1 {
+		t.Fatalf("expired %d, %v; want 1", n, err)
+	}
+	for id, want := range map[int]State{old.ID: StateExpired, recent.ID: StateActive, sysop.ID: StateActive, owner.ID: StateActive} {
+		if u, _ := repo.GetByID(id); u.State != want {
+			t.Errorf("%s is %s, want %s", u.Username, u.State, want)
+		}
+	}
+	if _, err := repo.Authenticate("oldtimer", "tangerine7"); err == nil {
+		t.Error("expired account logged in")
+	}
+
+	// Reactivating restarts the inactivity period.
+	if err := repo.Unlock(old.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := repo.ExpireInactive(365, LevelCoSysop); n != 0 {
+		t.Errorf("reactivated account expired again")
+	}
+}
+
+func TestDeleteAndPurge(t *testing.T) {
+	repo, database := lifecycleRepo(t)
+	u, _ := repo.Create("alice", "tangerine7", "Alice Liddell", "Oxford", "alice@example.com")
+	other, _ := repo.Create("bob", "tangerine7", "", "", "")
+	if err := repo.SetBirthday(u.ID, "05-04"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`INSERT INTO messages (area_id, from_user_id, subject, body) VALUES (1, ?, 'hi', 'hello')`, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	database.Exec(`INSERT INTO private_mail (from_user_id, to_user_id, subject, body) VALUES (?, ?, 'to bob', '')`, u.ID, other.ID)
+	database.Exec(`INSERT INTO private_mail (from_user_id, to_user_id, subject, body) VALUES (?, ?, 'to alice', '')`, other.ID, u.ID)
+	database.Exec(`INSERT INTO callers (user_id, username, node_id, remote, connected_at, disconnected_at) VALUES (?, 'alice', 1, '192.0.2.7', ?, ?)`,
+		u.ID, time.Now(), time.Now())
+
+	if err := repo.Purge(u.ID); err == nil {
+		t.Fatal("purged an active account")
+	}
+	if err := repo.Delete(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Authenticate("alice", "tangerine7"); err == nil || err.Error() != "account deleted" {
+		t.Errorf("deleted login err = %v", err)
+	}
+	if !repo.Exists("alice") {
+		t.Error("a deleted account's name is free before purging")
+	}
+	if n, _ := repo.Count(); n != 1 {
+		t.Errorf("count = %d, want 1", n)
+	}
+	if n, _ := repo.PurgeDeleted(30); n != 0 {
+		t.Errorf("purged an account deleted just now")
+	}
+
+	if err := repo.Purge(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	p, err := repo.GetByID(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Purged() || p.Username != "deleted-"+strconv.Itoa(u.ID) || p.RealName != "" || p.Email != "" || p.Birthday != "" || p.PasswordHash != "!" {
+		t.Errorf("purged user = %+v", p)
+	}
+	var msgs, toBob, toAlice int
+	database.QueryRow(`SELECT COUNT(*) FROM messages WHERE from_user_id = ?`, u.ID).Scan(&msgs)
+	database.QueryRow(`SELECT COUNT(*) FROM private_mail WHERE to_user_id = ?`, other.ID).Scan(&toBob)
+	database.QueryRow(`SELECT COUNT(*) FROM private_mail WHERE to_user_id = ?`, u.ID).Scan(&toAlice)
+	if msgs != 1 || toBob != 1 || toAlice != 0 {
+		t.Errorf("after purge: %d messages, %d mail to bob, %d to alice; want 1, 1, 0", msgs, toBob, toAlice)
+	}
+	var name, remote string
+	database.QueryRow(`SELECT username, remote FROM callers WHERE user_id = ?`, u.ID).Scan(&name, &remote)
+	if name != p.Username || remote != "" {
+		t.Errorf("callers log = %q %q", name, remote)
+	}
+	if repo.Exists("alice") {
+		t.Error("name still taken after purge")
+	}
+	if err := repo.Restore(u.ID); err == nil {
+		t.Error("restored a purged account")
+	}
+	if _, err := repo.Create("Deleted-99", "tangerine7", "", "", ""); err == nil {
+		t.Error("created a user named like a purged account")
+	}
+}
+
+func lifecycleRepo(t *testing.T) (*Repo, *db.DB) {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	repo := NewRepo(database.DB)
+	repo.SetPasswordPolicy(PasswordPolicy{MinLength: 6, BcryptCost: bcrypt.MinCost})
+	return repo, database
+}
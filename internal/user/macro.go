@@ -0,0 +1,133 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Macros are a user's shortcuts: typing a macro's name at a menu types its
+// keys instead, so "J2" can stand for joining conference 2 from the main
+// menu. They are kept in the user's settings under macroPrefix.
+const (
+	MaxMacros       = 20
+	MaxMacroNameLen = 8
+	MaxMacroKeysLen = 60
+
+	macroPrefix = "macro:"
+)
+
+var (
+	ErrMacroName = errors.New("macro names are 1-8 letters and digits")
+	ErrMacroKeys = errors.New("macro keys must be 1-60 printable characters")
+	ErrMacroMax  = fmt.Errorf("no more than %d macros", MaxMacros)
+)
+
+// Macro is one shortcut. In Keys, "|" stands for Enter.
+type Macro struct {
+	Name string
+	Keys string
+}
+
+// Input returns the keystrokes the macro types.
+func (m Macro) Input() []byte {
+	return []byte(strings.ReplaceAll(m.Keys, "|", "\r"))
+}
+
+// NormalizeMacroName returns name as macros are stored, in upper case, or
+// ErrMacroName when it is not a valid name.
+func NormalizeMacroName(name string) (string, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "" || len(name) > MaxMacroNameLen {
+		return "", ErrMacroName
+	}
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return "", ErrMacroName
+		}
+	}
+	return name, nil
+}
+
+// validMacroKeys allows printable ASCII only, so a macro types the same
+// whatever the caller's charset and cannot send control keys.
+func validMacroKeys(keys string) bool {
+	if keys == "" || len(keys) > MaxMacroKeysLen {
+		return false
+	}
+	for i := 0; i < len(keys); i++ {
+		if keys[i] < 32 || keys[i] > 126 {
+			return false
+		}
+	}
+	return true
+}
+
+// Macros returns a user's macros ordered by name.
+func (r *Repo) Macros(userID int) ([]Macro, error) {
+	rows, err := r.db.Query(`
+		SELECT key, value FROM user_settings WHERE user_id = ? AND key LIKE 'macro:%' ORDER BY key
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list macros: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Macro
+	for rows.Next() {
+		var key string
+		var m Macro
+		if err := rows.Scan(&key, &m.Keys); err != nil {
+			return nil, fmt.Errorf("list macros: %w", err)
+		}
+		m.Name = strings.TrimPrefix(key, macroPrefix)
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// GetMacro returns a user's macro by name, in any case, or nil when there
+// is none.
+func (r *Repo) GetMacro(userID int, name string) (*Macro, error) {
+	name, err := NormalizeMacroName(name)
+	if err != nil {
+		return nil, nil
+	}
+	s, err := r.GetSetting(userID, macroPrefix+name)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return &Macro{Name: name, Keys: s.Value}, nil
+}
+
+// SetMacro defines or replaces a user's macro.
+func (r *Repo) SetMacro(userID int, name, keys string) error {
+	name, err := NormalizeMacroName(name)
+	if err != nil {
+		return err
+	}
+	if !validMacroKeys(keys) {
+		return ErrMacroKeys
+	}
+	var count, exists int
+	if err := r.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(key = ?), 0) FROM user_settings
+		WHERE user_id = ? AND key LIKE 'macro:%'
+	`, macroPrefix+name, userID).Scan(&count, &exists); err != nil {
+		return fmt.Errorf("set macro: %w", err)
+	}
+	if exists == 0 && count >= MaxMacros {
+		return ErrMacroMax
+	}
+	return r.SetSetting(userID, macroPrefix+name, SettingString, keys)
+}
+
+// DeleteMacro removes a user's macro. Deleting a missing macro is not an
+// error.
+func (r *Repo) DeleteMacro(userID int, name string) error {
+	name, err := NormalizeMacroName(name)
+	if err != nil {
+		return nil
+	}
+	return r.DeleteSetting(userID, macroPrefix+name)
+}
@@ -13,12 +13,34 @@ type User struct {
 	SecurityLevel int
 	TotalCalls    int
 	LastCallAt    *time.Time
+	PreviousCallAt *time.Time // last call before this one; set by Authenticate
 	ANSIEnabled   bool
+	LastNode      int // node number used on the previous call (0 = none)
+	Birthday      string // "MM-DD", "" = not given
+	BaudRate      int    // emulated line speed in bps, 0 = full speed
+	Charset       string // how the user's terminal encodes input: utf8, cp437 or latin1, "" = never chosen
+	Palette       string // how widgets pick colors: auto, normal, safe or light
+	Colors        string // colors the terminal shows: auto, 16, 256 or truecolor
+	Flags         string // group flags, sorted letters A-Z (e.g. "AD")
+	State         State  // active, locked, expired or deleted
+	StateReason   string // why the account was locked, shown at login
+	DoNotDisturb  bool   // refuses pages for private chat
+	MailNotify    bool   // wants an email for new private mail
+	FileScanAt    *time.Time // when the user last scanned for new files, nil = never
+	Conference    int        // current conference ID (see the conference package)
+
+	// Lifetime counters, updated when each call ends
+	TimeUsedSecs    int64
+	TotalPosts      int
+	BytesUploaded   int64
+	BytesDownloaded int64
+
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 }
 
-// SecurityLevel constants following classic BBS conventions.
+// SecurityLevel constants following classic BBS conventions. The names
+// callers see come from the level table (see Level).
 const (
 	LevelNew      = 10  // New user (just registered)
 	LevelValidated = 20 // Validated user
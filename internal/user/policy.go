@@ -0,0 +1,114 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicy sets the rules for new and changed passwords and the cost
+// they are hashed with.
+type PasswordPolicy struct {
+	MinLength  int  // characters
+	MinClasses int  // of lowercase letters, uppercase letters, digits and symbols
+	BanCommon  bool // refuse well-known passwords and ones containing the username
+	BcryptCost int  // 0 = DefaultBcryptCost
+}
+
+// DefaultPasswordPolicy is used until SetPasswordPolicy is called.
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 6, BanCommon: true, BcryptCost: DefaultBcryptCost}
+
+// WeakPasswordError reports why a password was refused by the policy.
+type WeakPasswordError struct {
+	Reason string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return "password " + e.Reason
+}
+
+// classNames names the character classes counted by MinClasses.
+var classNames = []string{"lowercase letters", "uppercase letters", "digits", "symbols"}
+
+// Check returns a *WeakPasswordError if password does not meet the policy
+// for an account named username.
+func (p PasswordPolicy) Check(username, password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &WeakPasswordError{fmt.Sprintf("must be at least %d characters", p.MinLength)}
+	}
+	if p.MinClasses > 0 && classes(password) < p.MinClasses {
+		return &WeakPasswordError{fmt.Sprintf("must use at least %d of: %s", p.MinClasses, strings.Join(classNames, ", "))}
+	}
+	if p.BanCommon {
+		lower := strings.ToLower(password)
+		if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+			return &WeakPasswordError{"must not contain your username"}
+		}
+		if commonPasswords[lower] {
+			return &WeakPasswordError{"is too common"}
+		}
+	}
+	return nil
+}
+
+// Describe returns the rules in a sentence fragment for prompts, e.g.
+// "at least 8 characters, using 3 of lowercase letters, ...".
+func (p PasswordPolicy) Describe() string {
+	rules := []string{fmt.Sprintf("at least %d characters", p.MinLength)}
+	if p.MinClasses > 0 {
+		rules = append(rules, fmt.Sprintf("using %d of %s", p.MinClasses, strings.Join(classNames, ", ")))
+	}
+	if p.BanCommon {
+		rules = append(rules, "not a common password or your username")
+	}
+	return strings.Join(rules, ", ")
+}
+
+func (p PasswordPolicy) cost() int {
+	if p.BcryptCost == 0 {
+		return DefaultBcryptCost
+	}
+	return p.BcryptCost
+}
+
+// classes counts the character classes present in s.
+func classes(s string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// commonPasswords are refused when BanCommon is set, compared without case.
+var commonPasswords = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, p := range strings.Fields(`
+		123456 1234567 12345678 123456789 1234567890 123123 111111 000000
+		121212 654321 666666 696969 112233 123321 987654321 159753 147258369
+		password password1 password123 passw0rd p@ssw0rd qwerty qwerty123
+		qwertyuiop asdfgh asdfghjkl zxcvbn zxcvbnm 1q2w3e 1q2w3e4r 1qaz2wsx
+		abc123 abcdef abcd1234 aaaaaa letmein welcome welcome1 monkey dragon
+		master shadow sunshine princess football baseball soccer hockey
+		superman batman trustno1 iloveyou starwars whatever freedom secret
+		michael jordan jordan23 charlie hunter ranger buster killer pepper
+		ginger summer winter hello123 login admin admin123 root toor guest
+		changeme default access computer internet matrix mustang harley
+		cheese cookie flower banana orange purple silver golden lovely
+		nintendo pokemon zaq12wsx passpass test123 testing sysop modem
+		bbs123 telnet
+	`) {
+		m[p] = true
+	}
+	return m
+}()
@@ -0,0 +1,70 @@
+package user
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	p := PasswordPolicy{MinLength: 8, MinClasses: 3, BanCommon: true}
+	for _, tc := range []struct {
+		password, want string
+	}{
+		{"Ab1!", "password must be at least 8 characters"},
+		{"abcdefgh1", "password must use at least 3 of: lowercase letters, uppercase letters, digits, symbols"},
+		{"xAlice-2024", "password must not contain your username"},
+		{"Hunter2!Zq", ""},
+	} {
+		err := p.Check("alice", tc.password)
+		var weak *WeakPasswordError
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%q: %v", tc.password, err)
+		case tc.want != "" && (!errors.As(err, &weak) || err.Error() != tc.want):
+			t.Errorf("%q: got %v, want %q", tc.password, err, tc.want)
+		}
+	}
+	if err := DefaultPasswordPolicy.Check("bob", "Password"); err == nil || err.Error() != "password is too common" {
+		t.Errorf("common password: %v", err)
+	}
+}
+
+func TestPasswordPolicyEnforcedAndRehash(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+	repo.SetPasswordPolicy(PasswordPolicy{MinLength: 6, BanCommon: true, BcryptCost: bcrypt.MinCost})
+
+	if _, err := repo.Create("alice", "qwerty", "", "", ""); err == nil {
+		t.Fatal("common password accepted")
+	}
+	u, err := repo.Create("alice", "tangerine7", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdatePassword(u.ID, "alice1"); err == nil {
+		t.Fatal("password containing username accepted")
+	}
+
+	repo.SetPasswordPolicy(PasswordPolicy{MinLength: 6, BcryptCost: bcrypt.MinCost + 1})
+	if _, err := repo.Authenticate("alice", "wrong-password"); err == nil {
+		t.Fatal("wrong password accepted")
+	}
+	if _, err := repo.Authenticate("alice", "tangerine7"); err != nil {
+		t.Fatal(err)
+	}
+	u, _ = repo.GetByID(u.ID)
+	if cost, _ := bcrypt.Cost([]byte(u.PasswordHash)); cost != bcrypt.MinCost+1 {
+		t.Errorf("cost after login = %d, want %d", cost, bcrypt.MinCost+1)
+	}
+	if !CheckPassword("tangerine7", u.PasswordHash) {
+		t.Error("rehashed password no longer matches")
+	}
+}
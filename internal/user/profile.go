@@ -0,0 +1,178 @@
+package user
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits on the user-editable profile fields.
+const (
+	MaxBioLen         = 1024
+	MaxBioLines       = 10
+	MaxSignatureLen   = 240
+	MaxSignatureLines = 3
+	MaxAvatarLen      = 64
+)
+
+// Privacy says which profile details are hidden from other users. The
+// user and sysops always see everything.
+type Privacy struct {
+	RealName bool
+	Location bool
+	LastSeen bool
+	Stats    bool // calls, posts and transfer totals
+}
+
+// Profile is what users.profile shows about a user. Fields hidden by the
+// user's privacy settings are left zero by ForViewer.
+type Profile struct {
+	UserID    int
+	Username  string
+	RealName  string
+	Location  string
+	Bio       string
+	Signature string
+	Avatar    string // display file name of the user's ANSI avatar, "" = none
+
+	LastSeen        *time.Time
+	Calls           int
+	Posts           int
+	BytesUploaded   int64
+	BytesDownloaded int64
+	MemberSince     time.Time
+
+	Hide Privacy
+}
+
+// GetProfile returns a user's full profile, by username (case-insensitive).
+func (r *Repo) GetProfile(username string) (*Profile, error) {
+	p := &Profile{}
+	var lastCall, created sql.NullTime
+	err := r.db.QueryRow(`
+		SELECT u.id, u.username, u.real_name, u.location, u.last_call_at,
+		       u.total_calls, COALESCE(u.total_posts, 0), COALESCE(u.bytes_uploaded, 0),
+		       COALESCE(u.bytes_downloaded, 0), u.created_at,
+		       COALESCE(p.bio, ''), COALESCE(p.signature, ''), COALESCE(p.avatar, ''),
+		       COALESCE(p.hide_real_name, 0), COALESCE(p.hide_location, 0),
+		       COALESCE(p.hide_last_seen, 0), COALESCE(p.hide_stats, 0)
+		FROM users u LEFT JOIN user_profiles p ON p.user_id = u.id
+		WHERE u.username = ? COLLATE NOCASE
+	`, username).Scan(
+		&p.UserID, &p.Username, &p.RealName, &p.Location, &lastCall,
+		&p.Calls, &p.Posts, &p.BytesUploaded, &p.BytesDownloaded, &created,
+		&p.Bio, &p.Signature, &p.Avatar,
+		&p.Hide.RealName, &p.Hide.Location, &p.Hide.LastSeen, &p.Hide.Stats,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get profile %s: %w", username, err)
+	}
+	if lastCall.Valid {
+		p.LastSeen = &lastCall.Time
+	}
+	if created.Valid {
+		p.MemberSince = created.Time
+	}
+	return p, nil
+}
+
+// ForViewer returns the profile as another user sees it: without the
+// details the user chose to hide, unless full is set (the user themself or
+// a sysop).
+func (p *Profile) ForViewer(full bool) *Profile {
+	if full {
+		return p
+	}
+	out := *p
+	if p.Hide.RealName {
+		out.RealName = ""
+	}
+	if p.Hide.Location {
+		out.Location = ""
+	}
+	if p.Hide.LastSeen {
+		out.LastSeen = nil
+	}
+	if p.Hide.Stats {
+		out.Calls, out.Posts, out.BytesUploaded, out.BytesDownloaded = 0, 0, 0, 0
+	}
+	return &out
+}
+
+// UpdateBio replaces a user's bio and message signature.
+func (r *Repo) UpdateBio(userID int, bio, signature string) error {
+	bio = normalizeText(bio)
+	signature = normalizeText(signature)
+	if err := checkText("bio", bio, MaxBioLen, MaxBioLines); err != nil {
+		return err
+	}
+	if err := checkText("signature", signature, MaxSignatureLen, MaxSignatureLines); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO user_profiles (user_id, bio, signature) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET bio = excluded.bio, signature = excluded.signature
+	`, userID, bio, signature)
+	if err != nil {
+		return fmt.Errorf("update bio: %w", err)
+	}
+	return nil
+}
+
+// SetAvatar sets the display file shown as a user's avatar; "" clears it.
+// The name is looked up like any other display file, so it may not leave
+// the art directories.
+func (r *Repo) SetAvatar(userID int, name string) error {
+	name = strings.TrimSpace(name)
+	if len(name) > MaxAvatarLen || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid avatar name %q", name)
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO user_profiles (user_id, avatar) VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET avatar = excluded.avatar
+	`, userID, name)
+	if err != nil {
+		return fmt.Errorf("set avatar: %w", err)
+	}
+	return nil
+}
+
+// SetPrivacy sets which profile details are hidden from other users.
+func (r *Repo) SetPrivacy(userID int, hide Privacy) error {
+	_, err := r.db.Exec(`
+		INSERT INTO user_profiles (user_id, hide_real_name, hide_location, hide_last_seen, hide_stats)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			hide_real_name = excluded.hide_real_name, hide_location = excluded.hide_location,
+			hide_last_seen = excluded.hide_last_seen, hide_stats = excluded.hide_stats
+	`, userID, hide.RealName, hide.Location, hide.LastSeen, hide.Stats)
+	if err != nil {
+		return fmt.Errorf("set privacy: %w", err)
+	}
+	return nil
+}
+
+// normalizeText uses LF line endings and drops trailing blank lines.
+func normalizeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.TrimRight(s, " \n")
+}
+
+func checkText(field, s string, maxLen, maxLines int) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("%s is not valid text", field)
+	}
+	if len(s) > maxLen {
+		return fmt.Errorf("%s must be at most %d characters", field, maxLen)
+	}
+	if strings.Count(s, "\n") >= maxLines {
+		return fmt.Errorf("%s must be at most %d lines", field, maxLines)
+	}
+	if strings.ContainsRune(s, 0x1b) {
+		return fmt.Errorf("%s may not contain escape sequences", field)
+	}
+	return nil
+}
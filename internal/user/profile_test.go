@@ -0,0 +1,59 @@
+package user
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestProfileAndPrivacy(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash, real_name, location, total_calls)
+		VALUES (1, 'alice', 'x', 'Alice A', 'Oslo', 7)`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepo(database.DB)
+
+	p, err := repo.GetProfile("ALICE")
+	if err != nil || p.Username != "alice" || p.Bio != "" || p.Calls != 7 {
+		t.Fatalf("empty profile = %+v, %v", p, err)
+	}
+
+	if err := repo.UpdateBio(1, "Hi there\r\nI like doors\r\n\r\n", "-- alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetAvatar(1, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetPrivacy(1, Privacy{Location: true, Stats: true}); err != nil {
+		t.Fatal(err)
+	}
+	p, err = repo.GetProfile("alice")
+	if err != nil || p.Bio != "Hi there\nI like doors" || p.Signature != "-- alice" || p.Avatar != "alice" {
+		t.Fatalf("profile = %+v, %v", p, err)
+	}
+
+	other := p.ForViewer(false)
+	if other.Location != "" || other.Calls != 0 || other.RealName != "Alice A" {
+		t.Fatalf("other users see %+v", other)
+	}
+	if full := p.ForViewer(true); full.Location != "Oslo" || full.Calls != 7 {
+		t.Fatalf("owner sees %+v", full)
+	}
+
+	if err := repo.UpdateBio(1, strings.Repeat("line\n", MaxBioLines+1), ""); err == nil {
+		t.Fatal("over-long bio accepted")
+	}
+	if err := repo.UpdateBio(1, "\x1b[31mred", ""); err == nil {
+		t.Fatal("escape sequence accepted")
+	}
+	if err := repo.SetAvatar(1, "../etc/passwd"); err == nil {
+		t.Fatal("avatar path accepted")
+	}
+}
@@ -3,29 +3,59 @@ package user
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
 // Repo handles database operations for users.
 type Repo struct {
 	db *sql.DB
+
+	mu     sync.RWMutex // guards the policies, which can be reloaded live
+	policy PasswordPolicy
+	totp   TOTPPolicy
+	keys   int // settings quota per user
 }
 
-// NewRepo creates a new user repository.
+// NewRepo creates a new user repository using DefaultPasswordPolicy.
 func NewRepo(db *sql.DB) *Repo {
-	return &Repo{db: db}
+	return &Repo{db: db, policy: DefaultPasswordPolicy, keys: MaxSettingsPerUser}
+}
+
+// SetPasswordPolicy replaces the policy applied to new and changed passwords.
+func (r *Repo) SetPasswordPolicy(p PasswordPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policy = p
 }
 
-// Create inserts a new user with a hashed password.
+// PasswordPolicy returns the policy applied to new and changed passwords.
+func (r *Repo) PasswordPolicy() PasswordPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.policy
+}
+
+// Create inserts a new user with a hashed password. The password must meet
+// the password policy, and the name cannot look like a purged account's.
 func (r *Repo) Create(username, password, realName, location, email string) (*User, error) {
-	hash, err := HashPassword(password)
+	if strings.HasPrefix(strings.ToLower(username), PurgedPrefix) {
+		return nil, fmt.Errorf("user names cannot start with %q", PurgedPrefix)
+	}
+	policy := r.PasswordPolicy()
+	if err := policy.Check(username, password); err != nil {
+		return nil, err
+	}
+	hash, err := hashPassword(password, policy.cost())
 	if err != nil {
 		return nil, err
 	}
 
 	result, err := r.db.Exec(`
-		INSERT INTO users (username, password_hash, real_name, location, email, security_level)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO users (username, password_hash, real_name, location, email, security_level, charset)
+		VALUES (?, ?, ?, ?, ?, ?, '')
 	`, username, hash, realName, location, email, LevelNew)
 	if err != nil {
 		return nil, fmt.Errorf("create user %s: %w", username, err)
@@ -39,6 +69,25 @@ func (r *Repo) Create(username, password, realName, location, email string) (*Us
 	return r.GetByID(int(id))
 }
 
+// CreateLocked inserts a user that cannot log in, to own messages that
+// come from outside the board such as newsgroup articles.
+func (r *Repo) CreateLocked(username string) (*User, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO users (username, password_hash, security_level, charset)
+		VALUES (?, '!', ?, '')
+	`, username, LevelNew)
+	if err != nil {
+		return nil, fmt.Errorf("create user %s: %w", username, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("get user id: %w", err)
+	}
+
+	return r.GetByID(int(id))
+}
+
 // Authenticate checks username/password and returns the user if valid.
 func (r *Repo) Authenticate(username, password string) (*User, error) {
 	u, err := r.GetByUsername(username)
@@ -49,9 +98,14 @@ func (r *Repo) Authenticate(username, password string) (*User, error) {
 	if !CheckPassword(password, u.PasswordHash) {
 		return nil, fmt.Errorf("invalid password")
 	}
+	if err := u.LoginError(); err != nil {
+		return nil, err
+	}
+	r.upgradeHash(u, password)
 
 	// Update last call and total calls
 	now := time.Now()
+	u.PreviousCallAt = u.LastCallAt
 	r.db.Exec(`
 		UPDATE users SET last_call_at = ?, total_calls = total_calls + 1, updated_at = ?
 		WHERE id = ?
@@ -78,24 +132,28 @@ func (r *Repo) AuthenticateForSSH(username, password string) (bool, error) {
 		return false, nil
 	}
 
-	return true, nil
+	return u.LoginError() == nil, nil
 }
 
 // GetByID retrieves a user by ID.
 func (r *Repo) GetByID(id int) (*User, error) {
 	u := &User{}
-	var lastCall sql.NullTime
+	var lastCall, fileScan sql.NullTime
 	var created, updated sql.NullTime
 
 	err := r.db.QueryRow(`
 		SELECT id, username, password_hash, real_name, location, email,
 		       security_level, total_calls, last_call_at, ansi_enabled,
-		       created_at, updated_at
+		       COALESCE(last_node, 0), COALESCE(time_used_secs, 0), COALESCE(total_posts, 0),
+		       COALESCE(bytes_uploaded, 0), COALESCE(bytes_downloaded, 0), birthday, baud_rate, charset, palette, colors, mail_notify, conference_id, flags,
+		       state, state_reason, do_not_disturb, file_scan_at, created_at, updated_at
 		FROM users WHERE id = ?
 	`, id).Scan(
 		&u.ID, &u.Username, &u.PasswordHash, &u.RealName, &u.Location, &u.Email,
 		&u.SecurityLevel, &u.TotalCalls, &lastCall, &u.ANSIEnabled,
-		&created, &updated,
+		&u.LastNode, &u.TimeUsedSecs, &u.TotalPosts,
+		&u.BytesUploaded, &u.BytesDownloaded, &u.Birthday, &u.BaudRate, &u.Charset, &u.Palette, &u.Colors, &u.MailNotify, &u.Conference, &u.Flags,
+		&u.State, &u.StateReason, &u.DoNotDisturb, &fileScan, &created, &updated,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("get user %d: %w", id, err)
@@ -104,6 +162,9 @@ func (r *Repo) GetByID(id int) (*User, error) {
 	if lastCall.Valid {
 		u.LastCallAt = &lastCall.Time
 	}
+	if fileScan.Valid {
+		u.FileScanAt = &fileScan.Time
+	}
 	if created.Valid {
 		u.CreatedAt = created.Time
 	}
@@ -117,18 +178,22 @@ func (r *Repo) GetByID(id int) (*User, error) {
 // GetByUsername retrieves a user by username (case-insensitive).
 func (r *Repo) GetByUsername(username string) (*User, error) {
 	u := &User{}
-	var lastCall sql.NullTime
+	var lastCall, fileScan sql.NullTime
 	var created, updated sql.NullTime
 
 	err := r.db.QueryRow(`
 		SELECT id, username, password_hash, real_name, location, email,
 		       security_level, total_calls, last_call_at, ansi_enabled,
-		       created_at, updated_at
+		       COALESCE(last_node, 0), COALESCE(time_used_secs, 0), COALESCE(total_posts, 0),
+		       COALESCE(bytes_uploaded, 0), COALESCE(bytes_downloaded, 0), birthday, baud_rate, charset, palette, colors, mail_notify, conference_id, flags,
+		       state, state_reason, do_not_disturb, file_scan_at, created_at, updated_at
 		FROM users WHERE username = ? COLLATE NOCASE
 	`, username).Scan(
 		&u.ID, &u.Username, &u.PasswordHash, &u.RealName, &u.Location, &u.Email,
 		&u.SecurityLevel, &u.TotalCalls, &lastCall, &u.ANSIEnabled,
-		&created, &updated,
+		&u.LastNode, &u.TimeUsedSecs, &u.TotalPosts,
+		&u.BytesUploaded, &u.BytesDownloaded, &u.Birthday, &u.BaudRate, &u.Charset, &u.Palette, &u.Colors, &u.MailNotify, &u.Conference, &u.Flags,
+		&u.State, &u.StateReason, &u.DoNotDisturb, &fileScan, &created, &updated,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("get user %s: %w", username, err)
@@ -137,6 +202,9 @@ func (r *Repo) GetByUsername(username string) (*User, error) {
 	if lastCall.Valid {
 		u.LastCallAt = &lastCall.Time
 	}
+	if fileScan.Valid {
+		u.FileScanAt = &fileScan.Time
+	}
 	if created.Valid {
 		u.CreatedAt = created.Time
 	}
@@ -154,6 +222,16 @@ func (r *Repo) Exists(username string) bool {
 	return count > 0
 }
 
+// Count returns how many users are registered, not counting deleted
+// accounts.
+func (r *Repo) Count() (int, error) {
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM users WHERE state != 'deleted'").Scan(&count); err != nil {
+		return 0, fmt.Errorf("count users: %w", err)
+	}
+	return count, nil
+}
+
 // UpdateProfile updates a user's profile fields.
 func (r *Repo) UpdateProfile(id int, realName, location, email string) error {
 	_, err := r.db.Exec(`
@@ -163,6 +241,126 @@ func (r *Repo) UpdateProfile(id int, realName, location, email string) error {
 	return err
 }
 
+// SetBirthday records a user's birthday as "MM-DD"; "" clears it.
+func (r *Repo) SetBirthday(id int, mmdd string) error {
+	if mmdd != "" {
+		if _, err := time.Parse("01-02", mmdd); err != nil {
+			return fmt.Errorf("birthday must be MM-DD, got %q", mmdd)
+		}
+	}
+	_, err := r.db.Exec(`
+		UPDATE users SET birthday = ?, updated_at = ? WHERE id = ?
+	`, mmdd, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set birthday: %w", err)
+	}
+	return nil
+}
+
+// SetBaudRate records the line speed a user wants emulated, applied when
+// they log in; 0 means full speed.
+func (r *Repo) SetBaudRate(id int, bps int) error {
+	if bps < 0 {
+		return fmt.Errorf("invalid baud rate %d", bps)
+	}
+	_, err := r.db.Exec(`
+		UPDATE users SET baud_rate = ?, updated_at = ? WHERE id = ?
+	`, bps, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set baud rate: %w", err)
+	}
+	return nil
+}
+
+// SetCharset records how a user's terminal encodes what they type, applied
+// when they log in.
+func (r *Repo) SetCharset(id int, charset string) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET charset = ?, updated_at = ? WHERE id = ?
+	`, charset, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set charset: %w", err)
+	}
+	return nil
+}
+
+// SetPalette records how widgets should pick colors for a user: auto,
+// normal, safe or light (see terminal.Palette).
+func (r *Repo) SetPalette(id int, palette string) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET palette = ?, updated_at = ? WHERE id = ?
+	`, palette, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set palette: %w", err)
+	}
+	return nil
+}
+
+// SetColors records how many colors a user's terminal shows: auto, 16,
+// 256 or truecolor (see terminal.ColorDepth).
+func (r *Repo) SetColors(id int, colors string) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET colors = ?, updated_at = ? WHERE id = ?
+	`, colors, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set colors: %w", err)
+	}
+	return nil
+}
+
+// SetDoNotDisturb records whether a user refuses pages from other users.
+func (r *Repo) SetDoNotDisturb(id int, on bool) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET do_not_disturb = ?, updated_at = ? WHERE id = ?
+	`, on, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set do not disturb: %w", err)
+	}
+	return nil
+}
+
+// SetFileScan records when a user last scanned the file areas for new
+// files.
+func (r *Repo) SetFileScan(id int, at time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET file_scan_at = ?, updated_at = ? WHERE id = ?
+	`, at, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set file scan: %w", err)
+	}
+	return nil
+}
+
+// SetFlags replaces a user's group flags. Flags are letters A-Z; case and
+// order do not matter and the stored form is NormalizeFlags(flags).
+func (r *Repo) SetFlags(id int, flags string) error {
+	norm, err := NormalizeFlags(flags)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		UPDATE users SET flags = ?, updated_at = ? WHERE id = ?
+	`, norm, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set flags: %w", err)
+	}
+	return nil
+}
+
+// AddTransfer adds bytes moved outside a node session, such as SFTP or a
+// web download link, to a user's lifetime upload and download totals.
+func (r *Repo) AddTransfer(id int, up, down int64) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET bytes_uploaded = COALESCE(bytes_uploaded, 0) + ?,
+		                 bytes_downloaded = COALESCE(bytes_downloaded, 0) + ?
+		WHERE id = ?
+	`, up, down, id)
+	if err != nil {
+		return fmt.Errorf("add transfer for user %d: %w", id, err)
+	}
+	return nil
+}
+
 // UpdateSecurityLevel changes a user's security level.
 func (r *Repo) UpdateSecurityLevel(id int, level int) error {
 	_, err := r.db.Exec(`
@@ -179,9 +377,30 @@ func (r *Repo) UpdateANSI(id int, enabled bool) error {
 	return err
 }
 
-// UpdatePassword changes a user's password.
+// UpdateLastNode records the node number a user is currently connected on,
+// so the next call can prefer the same node.
+func (r *Repo) UpdateLastNode(id int, nodeID int) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET last_node = ? WHERE id = ?
+	`, nodeID, id)
+	return err
+}
+
+// UpdatePassword changes a user's password. The new password must meet
+// the password policy.
 func (r *Repo) UpdatePassword(id int, newPassword string) error {
-	hash, err := HashPassword(newPassword)
+	u, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := r.PasswordPolicy().Check(u.Username, newPassword); err != nil {
+		return err
+	}
+	return r.setPassword(id, newPassword)
+}
+
+func (r *Repo) setPassword(id int, password string) error {
+	hash, err := hashPassword(password, r.PasswordPolicy().cost())
 	if err != nil {
 		return err
 	}
@@ -191,10 +410,22 @@ func (r *Repo) UpdatePassword(id int, newPassword string) error {
 	return err
 }
 
-// List returns all users, ordered by username.
+// upgradeHash rehashes a just-verified password whose hash was made with a
+// lower cost than the policy's, so raising bcrypt_cost takes effect as
+// users log in. Failures are logged and leave the old hash in place.
+func (r *Repo) upgradeHash(u *User, password string) {
+	if !needsRehash(u.PasswordHash, r.PasswordPolicy().cost()) {
+		return
+	}
+	if err := r.setPassword(u.ID, password); err != nil {
+		log.Printf("Rehash password for %s: %v", u.Username, err)
+	}
+}
+
+// List returns all users, ordered by username, including deleted ones.
 func (r *Repo) List() ([]*User, error) {
 	rows, err := r.db.Query(`
-		SELECT id, username, real_name, location, security_level, total_calls, last_call_at
+		SELECT id, username, real_name, location, security_level, total_calls, last_call_at, state
 		FROM users ORDER BY username
 	`)
 	if err != nil {
@@ -207,7 +438,7 @@ func (r *Repo) List() ([]*User, error) {
 		u := &User{}
 		var lastCall sql.NullTime
 		if err := rows.Scan(&u.ID, &u.Username, &u.RealName, &u.Location,
-			&u.SecurityLevel, &u.TotalCalls, &lastCall); err != nil {
+			&u.SecurityLevel, &u.TotalCalls, &lastCall, &u.State); err != nil {
 			return nil, err
 		}
 		if lastCall.Valid {
@@ -0,0 +1,115 @@
+package user
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestAuthenticateKeepsPreviousCall(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+	repo.SetPasswordPolicy(PasswordPolicy{MinLength: 6, BcryptCost: bcrypt.MinCost})
+	if _, err := repo.Create("alice", "tangerine7", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := repo.Authenticate("alice", "tangerine7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.PreviousCallAt != nil {
+		t.Errorf("first call has a previous call: %v", first.PreviousCallAt)
+	}
+	second, err := repo.Authenticate("alice", "tangerine7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.PreviousCallAt == nil || !second.PreviousCallAt.Equal(*first.LastCallAt) {
+		t.Errorf("previous call = %v, want %v", second.PreviousCallAt, first.LastCallAt)
+	}
+}
+
+func TestSetBirthday(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+	repo.SetPasswordPolicy(PasswordPolicy{MinLength: 6, BcryptCost: bcrypt.MinCost})
+	u, err := repo.Create("alice", "tangerine7", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []string{"13-01", "1-5", "02-30", "tomorrow"} {
+		if err := repo.SetBirthday(u.ID, bad); err == nil {
+			t.Errorf("birthday %q accepted", bad)
+		}
+	}
+	if err := repo.SetBirthday(u.ID, "02-29"); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ = repo.GetByUsername("alice"); u.Birthday != "02-29" {
+		t.Errorf("birthday = %q", u.Birthday)
+	}
+}
+
+func TestSetFlags(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+	repo.SetPasswordPolicy(PasswordPolicy{MinLength: 6, BcryptCost: bcrypt.MinCost})
+	u, err := repo.Create("alice", "tangerine7", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.SetFlags(u.ID, "A1"); err == nil {
+		t.Error("flag '1' accepted")
+	}
+	if err := repo.SetFlags(u.ID, "d, a, D"); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ = repo.GetByUsername("alice"); u.Flags != "AD" {
+		t.Errorf("flags = %q", u.Flags)
+	}
+	if !u.HasFlags("da") || u.HasFlags("AB") || !u.HasFlags("") {
+		t.Errorf("HasFlags wrong for %q", u.Flags)
+	}
+}
+
+func TestAddTransfer(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+	repo.SetPasswordPolicy(PasswordPolicy{MinLength: 6, BcryptCost: bcrypt.MinCost})
+	u, err := repo.Create("alice", "tangerine7", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.AddTransfer(u.ID, 100, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddTransfer(u.ID, 5, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ = repo.GetByID(u.ID); u.BytesUploaded != 105 || u.BytesDownloaded != 2048 {
+		t.Errorf("totals = %d up, %d down", u.BytesUploaded, u.BytesDownloaded)
+	}
+}
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Password reset codes are mailed to the user and typed back at the login
+// prompt. A user has at most one code at a time.
+const (
+	resetAttempts = 5           // wrong codes before the code is void
+	resetInterval = time.Minute // least time between two codes for a user
+)
+
+var (
+	ErrResetCode     = errors.New("wrong or expired reset code")
+	ErrResetTooSoon  = errors.New("a reset code was sent less than a minute ago")
+	ErrResetNoEmail  = errors.New("no email address on the account")
+	ErrResetDisabled = errors.New("the account cannot log in")
+)
+
+// CreateResetCode issues a password reset code for u, valid for ttl, and
+// replaces any earlier one. The code is returned for mailing; only its
+// hash is stored.
+func (r *Repo) CreateResetCode(u *User, ttl time.Duration) (string, error) {
+	if u.Email == "" {
+		return "", ErrResetNoEmail
+	}
+	if u.State != StateActive && u.State != "" {
+		return "", ErrResetDisabled
+	}
+	var created time.Time
+	err := r.db.QueryRow(`SELECT created_at FROM password_resets WHERE user_id = ?`, u.ID).Scan(&created)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("reset code: %w", err)
+	}
+	now := time.Now()
+	if err == nil && now.Sub(created) < resetInterval {
+		return "", ErrResetTooSoon
+	}
+	code, err := newBackupCode()
+	if err != nil {
+		return "", err
+	}
+	_, err = r.db.Exec(`
+		INSERT INTO password_resets (user_id, code_hash, attempts, created_at, expires_at)
+		VALUES (?, ?, 0, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET code_hash = excluded.code_hash, attempts = 0,
+			created_at = excluded.created_at, expires_at = excluded.expires_at
+	`, u.ID, hashBackupCode(code), now, now.Add(ttl))
+	if err != nil {
+		return "", fmt.Errorf("reset code: %w", err)
+	}
+	return code, nil
+}
+
+// ResetPassword sets a new password for userID with a code from
+// CreateResetCode, which is then spent. Wrong codes count against the
+// code, which is void after a few.
+func (r *Repo) ResetPassword(userID int, code, newPassword string) error {
+	var hash string
+	var attempts int
+	var expires time.Time
+	err := r.db.QueryRow(`
+		SELECT code_hash, attempts, expires_at FROM password_resets WHERE user_id = ?
+	`, userID).Scan(&hash, &attempts, &expires)
+	if err == sql.ErrNoRows {
+		return ErrResetCode
+	}
+	if err != nil {
+		return fmt.Errorf("reset password: %w", err)
+	}
+	if attempts >= resetAttempts || time.Now().After(expires) {
+		return ErrResetCode
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashBackupCode(code))) != 1 {
+		if _, err := r.db.Exec(`UPDATE password_resets SET attempts = attempts + 1 WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("reset password: %w", err)
+		}
+		return ErrResetCode
+	}
+	// The policy check comes first so a weak password leaves the code
+	// usable for a better one.
+	if err := r.UpdatePassword(userID, newPassword); err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(`DELETE FROM password_resets WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("reset password: %w", err)
+	}
+	return nil
+}
+
+// SetMailNotify records whether a user wants an email for new private
+// mail.
+func (r *Repo) SetMailNotify(id int, on bool) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET mail_notify = ?, updated_at = ? WHERE id = ?
+	`, on, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set mail notify: %w", err)
+	}
+	return nil
+}
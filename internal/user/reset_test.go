@@ -0,0 +1,64 @@
+package user
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestResetPassword(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	repo := NewRepo(database.DB)
+	repo.SetPasswordPolicy(PasswordPolicy{MinLength: 6, BcryptCost: bcrypt.MinCost})
+	u, err := repo.Create("alice", "tangerine7", "", "", "alice@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateResetCode(&User{ID: 99, State: StateActive}, time.Minute); !errors.Is(err, ErrResetNoEmail) {
+		t.Errorf("code without an address: %v", err)
+	}
+
+	code, err := repo.CreateResetCode(u, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateResetCode(u, time.Minute); !errors.Is(err, ErrResetTooSoon) {
+		t.Errorf("second code right away: %v", err)
+	}
+	if err := repo.ResetPassword(u.ID, "wrong-code", "clementine8"); !errors.Is(err, ErrResetCode) {
+		t.Errorf("wrong code: %v", err)
+	}
+	if err := repo.ResetPassword(u.ID, code, "abc"); err == nil {
+		t.Error("weak password accepted")
+	}
+	if err := repo.ResetPassword(u.ID, code, "clementine8"); err != nil {
+		t.Fatalf("reset with the code: %v", err)
+	}
+	if _, err := repo.Authenticate("alice", "clementine8"); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+	if err := repo.ResetPassword(u.ID, code, "grapefruit9"); !errors.Is(err, ErrResetCode) {
+		t.Errorf("code used twice: %v", err)
+	}
+
+	// Wrong guesses void the code.
+	code, err = repo.CreateResetCode(u, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range resetAttempts {
+		repo.ResetPassword(u.ID, "nope", "grapefruit9")
+	}
+	if err := repo.ResetPassword(u.ID, code, "grapefruit9"); !errors.Is(err, ErrResetCode) {
+		t.Errorf("code after too many guesses: %v", err)
+	}
+}
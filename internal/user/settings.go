@@ -0,0 +1,139 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Limits on what scripts may store per user. MaxSettingsPerUser is the
+// default key quota; see SetSettingsQuota.
+const (
+	MaxSettingKeyLen   = 64
+	MaxSettingValueLen = 4096
+	MaxSettingsPerUser = 256
+)
+
+// ErrSettingQuota is returned when a write would exceed the per-user limits.
+var ErrSettingQuota = errors.New("setting quota exceeded")
+
+// Setting kinds, so scripts get back the Lua type they stored.
+const (
+	SettingString = "string"
+	SettingNumber = "number"
+	SettingBool   = "boolean"
+)
+
+// Setting is one persisted key/value pair owned by a user.
+type Setting struct {
+	Key   string
+	Kind  string
+	Value string
+}
+
+// GetSetting returns a user's setting, or nil if the key is not set.
+func (r *Repo) GetSetting(userID int, key string) (*Setting, error) {
+	s := &Setting{Key: key}
+	err := r.db.QueryRow(`
+		SELECT kind, value FROM user_settings WHERE user_id = ? AND key = ?
+	`, userID, key).Scan(&s.Kind, &s.Value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get setting %q: %w", key, err)
+	}
+	return s, nil
+}
+
+// ListSettings returns a user's settings ordered by key.
+func (r *Repo) ListSettings(userID int) ([]*Setting, error) {
+	rows, err := r.db.Query(`
+		SELECT key, kind, value FROM user_settings WHERE user_id = ? ORDER BY key
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list settings: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*Setting
+	for rows.Next() {
+		s := &Setting{}
+		if err := rows.Scan(&s.Key, &s.Kind, &s.Value); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// SetSettingsQuota sets how many keys a user may hold; n <= 0 restores
+// MaxSettingsPerUser. Users already over a lowered quota keep their keys
+// but cannot add more.
+func (r *Repo) SetSettingsQuota(n int) {
+	if n <= 0 {
+		n = MaxSettingsPerUser
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = n
+}
+
+func (r *Repo) settingsQuota() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys
+}
+
+// SetSetting stores a value for a user, replacing any previous value.
+// Keys and values are bounded by MaxSettingKeyLen and MaxSettingValueLen,
+// and a user may hold at most the settings quota of keys.
+func (r *Repo) SetSetting(userID int, key, kind, value string) error {
+	if key == "" {
+		return fmt.Errorf("set setting: key is empty")
+	}
+	if len(key) > MaxSettingKeyLen {
+		return fmt.Errorf("set setting %q: key longer than %d bytes: %w", key, MaxSettingKeyLen, ErrSettingQuota)
+	}
+	if len(value) > MaxSettingValueLen {
+		return fmt.Errorf("set setting %q: value longer than %d bytes: %w", key, MaxSettingValueLen, ErrSettingQuota)
+	}
+	switch kind {
+	case SettingString, SettingNumber, SettingBool:
+	default:
+		return fmt.Errorf("set setting %q: unsupported kind %q", key, kind)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("set setting %q: %w", key, err)
+	}
+	defer tx.Rollback()
+
+	var exists, count int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(key = ?), 0) FROM user_settings WHERE user_id = ?
+	`, key, userID).Scan(&count, &exists); err != nil {
+		return fmt.Errorf("set setting %q: %w", key, err)
+	}
+	if quota := r.settingsQuota(); exists == 0 && count >= quota {
+		return fmt.Errorf("set setting %q: more than %d keys: %w", key, quota, ErrSettingQuota)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO user_settings (user_id, key, kind, value) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, key) DO UPDATE SET
+			kind = excluded.kind, value = excluded.value, updated_at = CURRENT_TIMESTAMP
+	`, userID, key, kind, value); err != nil {
+		return fmt.Errorf("set setting %q: %w", key, err)
+	}
+	return tx.Commit()
+}
+
+// DeleteSetting removes a user's setting. Deleting a missing key is not an error.
+func (r *Repo) DeleteSetting(userID int, key string) error {
+	if _, err := r.db.Exec(`DELETE FROM user_settings WHERE user_id = ? AND key = ?`, userID, key); err != nil {
+		return fmt.Errorf("delete setting %q: %w", key, err)
+	}
+	return nil
+}
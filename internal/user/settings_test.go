@@ -0,0 +1,141 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+)
+
+func TestSettingsRoundTripAndQuota(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x'), (2, 'bob', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepo(database.DB)
+
+	if err := repo.SetSetting(1, "score", SettingNumber, "10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetSetting(1, "score", SettingNumber, "42"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := repo.GetSetting(1, "score")
+	if err != nil || s == nil || s.Kind != SettingNumber || s.Value != "42" {
+		t.Fatalf("get score = %+v, %v", s, err)
+	}
+	if s, _ := repo.GetSetting(2, "score"); s != nil {
+		t.Fatalf("bob sees alice's setting: %+v", s)
+	}
+
+	long := strings.Repeat("x", MaxSettingValueLen+1)
+	if err := repo.SetSetting(1, "big", SettingString, long); !errors.Is(err, ErrSettingQuota) {
+		t.Fatalf("oversized value err = %v", err)
+	}
+
+	for i := 1; i < MaxSettingsPerUser; i++ {
+		if err := repo.SetSetting(1, fmt.Sprintf("k%d", i), SettingBool, "true"); err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+	}
+	if err := repo.SetSetting(1, "one_too_many", SettingString, "x"); !errors.Is(err, ErrSettingQuota) {
+		t.Fatalf("key count err = %v", err)
+	}
+	if err := repo.SetSetting(1, "score", SettingNumber, "43"); err != nil {
+		t.Fatalf("overwrite at quota: %v", err)
+	}
+
+	if err := repo.DeleteSetting(1, "score"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := repo.GetSetting(1, "score"); s != nil {
+		t.Fatalf("score still set after delete: %+v", s)
+	}
+}
+
+func TestSettingsQuotaConfigurable(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepo(database.DB)
+	repo.SetSettingsQuota(2)
+
+	for _, key := range []string{"a", "b"} {
+		if err := repo.SetSetting(1, key, SettingBool, "true"); err != nil {
+			t.Fatalf("key %s: %v", key, err)
+		}
+	}
+	if err := repo.SetSetting(1, "c", SettingBool, "true"); !errors.Is(err, ErrSettingQuota) {
+		t.Fatalf("third key err = %v", err)
+	}
+	if err := repo.SetSetting(1, "a", SettingBool, "false"); err != nil {
+		t.Fatalf("overwrite at quota: %v", err)
+	}
+}
+
+func TestMacros(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepo(database.DB)
+
+	if err := repo.SetMacro(1, "j2", "J2|"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := repo.GetMacro(1, "J2")
+	if err != nil || m == nil || m.Name != "J2" || string(m.Input()) != "J2\r" {
+		t.Fatalf("get J2 = %+v, %v", m, err)
+	}
+	if m, _ := repo.GetMacro(1, "hello there"); m != nil {
+		t.Errorf("invalid name found %+v", m)
+	}
+	if err := repo.SetMacro(1, "bad name", "x"); err != ErrMacroName {
+		t.Errorf("bad name err = %v", err)
+	}
+	if err := repo.SetMacro(1, "ctl", "a\x1bb"); err != ErrMacroKeys {
+		t.Errorf("control key err = %v", err)
+	}
+
+	// Macros are settings, but scripts' own settings do not count against
+	// the macro limit.
+	repo.SetSetting(1, "score", SettingNumber, "1")
+	for i := 1; i < MaxMacros; i++ {
+		if err := repo.SetMacro(1, fmt.Sprintf("M%d", i), "x"); err != nil {
+			t.Fatalf("macro %d: %v", i, err)
+		}
+	}
+	if err := repo.SetMacro(1, "MORE", "x"); err != ErrMacroMax {
+		t.Errorf("over the limit err = %v", err)
+	}
+	if err := repo.SetMacro(1, "J2", "J3|"); err != nil {
+		t.Errorf("replace at the limit: %v", err)
+	}
+	list, err := repo.Macros(1)
+	if err != nil || len(list) != MaxMacros || list[0].Name != "J2" {
+		t.Fatalf("macros = %d, %v", len(list), err)
+	}
+
+	if err := repo.DeleteMacro(1, "j2"); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := repo.GetMacro(1, "J2"); m != nil {
+		t.Errorf("J2 still set after delete: %+v", m)
+	}
+}
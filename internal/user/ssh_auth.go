@@ -1,5 +1,7 @@
 package user
 
+import "golang.org/x/crypto/ssh"
+
 // SSHAuthenticator adapts Repo to be used as an SSH password authenticator.
 type SSHAuthenticator struct {
 	repo *Repo
@@ -14,3 +16,21 @@ func NewSSHAuthenticator(repo *Repo) *SSHAuthenticator {
 func (a *SSHAuthenticator) Authenticate(username, password string) (bool, error) {
 	return a.repo.AuthenticateForSSH(username, password)
 }
+
+// AuthenticateKey validates a public key for SSH authentication.
+func (a *SSHAuthenticator) AuthenticateKey(username string, key ssh.PublicKey) (bool, error) {
+	return a.repo.AuthenticateKey(username, key)
+}
+
+// NeedsSecondFactor reports whether username has two-factor authentication
+// enabled or is required to, so a password alone is not enough.
+func (a *SSHAuthenticator) NeedsSecondFactor(username string) (bool, error) {
+	u, err := a.repo.GetByUsername(username)
+	if err != nil {
+		return false, err
+	}
+	if a.repo.TOTPRequired(u) {
+		return true, nil
+	}
+	return a.repo.TOTPEnabled(u.ID)
+}
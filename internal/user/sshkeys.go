@@ -0,0 +1,96 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHKey is a public key a user may authenticate with over SSH.
+type SSHKey struct {
+	ID            int
+	UserID        int
+	Fingerprint   string // SHA256:...
+	AuthorizedKey string // authorized_keys line, including any comment
+}
+
+// ListSSHKeys returns the public keys registered for a user.
+func (r *Repo) ListSSHKeys(userID int) ([]*SSHKey, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, fingerprint, authorized_key FROM user_ssh_keys
+		WHERE user_id = ? ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list ssh keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*SSHKey
+	for rows.Next() {
+		k := &SSHKey{}
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Fingerprint, &k.AuthorizedKey); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// SetSSHKeys replaces a user's public keys with the given authorized_keys
+// lines. Blank lines and # comments are skipped; any unparsable line fails
+// the whole update.
+func (r *Repo) SetSSHKeys(userID int, lines []string) error {
+	type parsed struct{ fingerprint, line string }
+	var keys []parsed
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return fmt.Errorf("parse ssh key %q: %w", abbreviate(line), err)
+		}
+		keys = append(keys, parsed{ssh.FingerprintSHA256(pub), line})
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("set ssh keys: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_ssh_keys WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("set ssh keys: %w", err)
+	}
+	for _, k := range keys {
+		if _, err := tx.Exec(`
+			INSERT INTO user_ssh_keys (user_id, fingerprint, authorized_key) VALUES (?, ?, ?)
+		`, userID, k.fingerprint, k.line); err != nil {
+			return fmt.Errorf("add ssh key %s: %w", k.fingerprint, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// AuthenticateKey reports whether key is registered to username and the
+// account is active. Like AuthenticateForSSH it does not count as a call.
+func (r *Repo) AuthenticateKey(username string, key ssh.PublicKey) (bool, error) {
+	var n int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM user_ssh_keys k JOIN users u ON u.id = k.user_id
+		WHERE u.username = ? COLLATE NOCASE AND k.fingerprint = ? AND u.state = 'active'
+	`, username, ssh.FingerprintSHA256(key)).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("check ssh key: %w", err)
+	}
+	return n > 0, nil
+}
+
+func abbreviate(s string) string {
+	if len(s) > 30 {
+		return s[:30] + "..."
+	}
+	return s
+}
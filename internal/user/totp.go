@@ -0,0 +1,226 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/totp"
+)
+
+// TOTPPolicy configures two-factor authentication.
+type TOTPPolicy struct {
+	Issuer       string // shown in authenticator apps, usually the BBS name
+	RequireLevel int    // users at or above this level must enroll, 0 = optional for all
+}
+
+// backupCodeCount is how many single-use backup codes enrollment issues.
+const backupCodeCount = 10
+
+// backupCodeChars avoids characters that are easily misread (0/o, 1/l/i).
+const backupCodeChars = "abcdefghjkmnpqrstuvwxyz23456789"
+
+var (
+	ErrTOTPEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotStarted = errors.New("two-factor enrollment has not been started")
+)
+
+// SetTOTPPolicy replaces the two-factor policy.
+func (r *Repo) SetTOTPPolicy(p TOTPPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totp = p
+}
+
+func (r *Repo) totpPolicy() TOTPPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.totp
+}
+
+// TOTPRequired reports whether the policy makes two-factor authentication
+// mandatory for u.
+func (r *Repo) TOTPRequired(u *User) bool {
+	p := r.totpPolicy()
+	return p.RequireLevel > 0 && u.SecurityLevel >= p.RequireLevel
+}
+
+// TOTPEnabled reports whether a user has completed two-factor enrollment.
+func (r *Repo) TOTPEnabled(userID int) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(`SELECT enabled FROM user_totp WHERE user_id = ?`, userID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("totp status: %w", err)
+	}
+	return enabled, nil
+}
+
+// BeginTOTP starts enrollment for u with a new secret, replacing any
+// unfinished enrollment. It returns the secret and the otpauth:// URI for
+// the user's authenticator app. Nothing changes at login until ConfirmTOTP.
+func (r *Repo) BeginTOTP(u *User) (secret, uri string, err error) {
+	if on, err := r.TOTPEnabled(u.ID); err != nil {
+		return "", "", err
+	} else if on {
+		return "", "", ErrTOTPEnabled
+	}
+	secret, err = totp.NewSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := r.db.Exec(`
+		INSERT INTO user_totp (user_id, secret, enabled, last_step) VALUES (?, ?, 0, 0)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_step = 0, created_at = CURRENT_TIMESTAMP
+	`, u.ID, secret); err != nil {
+		return "", "", fmt.Errorf("begin totp: %w", err)
+	}
+	issuer := r.totpPolicy().Issuer
+	if issuer == "" {
+		issuer = "BBS"
+	}
+	return secret, totp.URI(issuer, u.Username, secret), nil
+}
+
+// ConfirmTOTP finishes enrollment once the user enters a code from their
+// app, and returns a fresh set of single-use backup codes. Only their
+// hashes are stored, so this is the only time they can be shown.
+func (r *Repo) ConfirmTOTP(userID int, code string) ([]string, error) {
+	var secret string
+	var enabled bool
+	err := r.db.QueryRow(`SELECT secret, enabled FROM user_totp WHERE user_id = ?`, userID).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrTOTPNotStarted
+	}
+	if err != nil {
+		return nil, fmt.Errorf("confirm totp: %w", err)
+	}
+	if enabled {
+		return nil, ErrTOTPEnabled
+	}
+	step, ok := totp.Verify(secret, code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+
+	codes := make([]string, backupCodeCount)
+	for i := range codes {
+		if codes[i], err = newBackupCode(); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("confirm totp: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE user_totp SET enabled = 1, last_step = ? WHERE user_id = ?`, step, userID); err != nil {
+		return nil, fmt.Errorf("confirm totp: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM user_backup_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, fmt.Errorf("confirm totp: %w", err)
+	}
+	for _, c := range codes {
+		if _, err := tx.Exec(`
+			INSERT INTO user_backup_codes (user_id, code_hash) VALUES (?, ?)
+		`, userID, hashBackupCode(c)); err != nil {
+			return nil, fmt.Errorf("store backup code: %w", err)
+		}
+	}
+	return codes, tx.Commit()
+}
+
+// VerifyTOTP checks a code from the user's app, or one of their unused
+// backup codes, which is then used up. An app code is refused if it, or
+// a later one, was already accepted, so an observed code cannot be
+// replayed.
+func (r *Repo) VerifyTOTP(userID int, code string) (bool, error) {
+	var secret string
+	var lastStep int64
+	err := r.db.QueryRow(`
+		SELECT secret, last_step FROM user_totp WHERE user_id = ? AND enabled = 1
+	`, userID).Scan(&secret, &lastStep)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("verify totp: %w", err)
+	}
+
+	if step, ok := totp.Verify(secret, code, time.Now()); ok {
+		res, err := r.db.Exec(`
+			UPDATE user_totp SET last_step = ? WHERE user_id = ? AND last_step < ?
+		`, step, userID, step)
+		if err != nil {
+			return false, fmt.Errorf("verify totp: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		return n == 1, nil
+	}
+
+	res, err := r.db.Exec(`
+		UPDATE user_backup_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, time.Now(), userID, hashBackupCode(code))
+	if err != nil {
+		return false, fmt.Errorf("verify backup code: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// BackupCodesLeft returns how many unused backup codes a user has.
+func (r *Repo) BackupCodesLeft(userID int) (int, error) {
+	var n int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM user_backup_codes WHERE user_id = ? AND used_at IS NULL
+	`, userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count backup codes: %w", err)
+	}
+	return n, nil
+}
+
+// DisableTOTP removes a user's two-factor enrollment and backup codes, for
+// example when a sysop resets a user who lost their device.
+func (r *Repo) DisableTOTP(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("disable totp: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("disable totp: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM user_backup_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("disable totp: %w", err)
+	}
+	return tx.Commit()
+}
+
+// newBackupCode returns a random code formatted as "xxxx-xxxx".
+func newBackupCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("backup code: %w", err)
+	}
+	for i := range b {
+		b[i] = backupCodeChars[int(b[i])%len(backupCodeChars)]
+	}
+	return string(b[:4]) + "-" + string(b[4:]), nil
+}
+
+// hashBackupCode hashes a backup code as typed, ignoring case, spaces and
+// dashes. The codes are random, so a plain SHA-256 is enough.
+func hashBackupCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
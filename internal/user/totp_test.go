@@ -0,0 +1,86 @@
+package user
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/notepid/twilight_bbs/internal/db"
+	"github.com/notepid/twilight_bbs/internal/totp"
+)
+
+func TestTOTPEnrollment(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "bbs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`INSERT INTO users (id, username, password_hash, security_level) VALUES (1, 'alice', 'x', 100)`); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepo(database.DB)
+	repo.SetTOTPPolicy(TOTPPolicy{Issuer: "Test BBS", RequireLevel: 90})
+	alice, _ := repo.GetByID(1)
+	if !repo.TOTPRequired(alice) {
+		t.Error("sysop not required to enroll")
+	}
+	ssh := NewSSHAuthenticator(repo)
+	if need, err := ssh.NeedsSecondFactor("alice"); !need || err != nil {
+		t.Errorf("required sysop needs no second factor over SSH: %v", err)
+	}
+
+	secret, _, err := repo.BeginTOTP(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if on, _ := repo.TOTPEnabled(1); on {
+		t.Fatal("enabled before confirmation")
+	}
+	if _, err := repo.ConfirmTOTP(1, "000000x"); err == nil {
+		t.Fatal("bad code confirmed enrollment")
+	}
+	code, _ := totp.Code(secret, totp.Step(time.Now()))
+	backups, err := repo.ConfirmTOTP(1, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != backupCodeCount {
+		t.Fatalf("%d backup codes", len(backups))
+	}
+	repo.SetTOTPPolicy(TOTPPolicy{Issuer: "Test BBS"})
+	if need, _ := ssh.NeedsSecondFactor("alice"); !need {
+		t.Error("enrolled user needs no second factor over SSH")
+	}
+	if _, _, err := repo.BeginTOTP(alice); err != ErrTOTPEnabled {
+		t.Errorf("re-enroll while enabled: %v", err)
+	}
+
+	// The confirmation code, and any earlier one, cannot be replayed.
+	if ok, _ := repo.VerifyTOTP(1, code); ok {
+		t.Error("confirmation code replayed")
+	}
+	next, _ := totp.Code(secret, totp.Step(time.Now())+1)
+	if ok, err := repo.VerifyTOTP(1, next); !ok || err != nil {
+		t.Errorf("next code: %v, %v", ok, err)
+	}
+
+	if ok, _ := repo.VerifyTOTP(1, " "+backups[0]+" "); !ok {
+		t.Error("backup code refused")
+	}
+	if ok, _ := repo.VerifyTOTP(1, backups[0]); ok {
+		t.Error("backup code used twice")
+	}
+	if left, _ := repo.BackupCodesLeft(1); left != backupCodeCount-1 {
+		t.Errorf("backup codes left = %d", left)
+	}
+
+	if err := repo.DisableTOTP(1); err != nil {
+		t.Fatal(err)
+	}
+	if on, _ := repo.TOTPEnabled(1); on {
+		t.Error("still enabled after reset")
+	}
+	if ok, _ := repo.VerifyTOTP(1, backups[1]); ok {
+		t.Error("backup code works after reset")
+	}
+}
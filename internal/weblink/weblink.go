@@ -0,0 +1,230 @@
+// Package weblink serves file area downloads over HTTP through signed,
+// expiring, single-use links, so a caller on telnet can fetch a file with
+// a browser. A link belongs to the first address that uses it: that client
+// may retry and resume with range requests until the link expires, and
+// the link is spent once the whole file has been delivered.
+package weblink
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix is the URL path the handler is mounted at.
+const Prefix = "/dl/"
+
+const (
+	nonceLen = 9
+	sigLen   = 9
+)
+
+// Grant is a download a link allows.
+type Grant struct {
+	UserID  int
+	EntryID int
+	Path    string // file on disk
+	Size    int64
+	Expires time.Time
+
+	claimed string // remote address of the first request, "" = unused
+	served  int64  // bytes delivered so far
+}
+
+// Store issues links and serves them.
+type Store struct {
+	BaseURL string        // public URL of the HTTP server, without a trailing slash
+	TTL     time.Duration // how long a link stays valid
+
+	// Complete is called once per link, when the whole file has been
+	// delivered, to count the download.
+	Complete func(g Grant, remote string)
+
+	secret []byte
+	mu     sync.Mutex
+	grants map[string]*Grant // by nonce
+}
+
+// New creates a store with a fresh signing key. Links do not survive a
+// restart.
+func New(baseURL string, ttl time.Duration) (*Store, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("web links: %w", err)
+	}
+	return &Store{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		TTL:     ttl,
+		secret:  secret,
+		grants:  make(map[string]*Grant),
+	}, nil
+}
+
+// Issue creates a link that lets userID download the file at path, the
+// file entry entryID, and returns its URL.
+func (s *Store) Issue(userID, entryID int, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("file not available")
+	}
+	nonce := make([]byte, nonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("web links: %w", err)
+	}
+	g := &Grant{UserID: userID, EntryID: entryID, Path: path, Size: info.Size(),
+		Expires: time.Now().Add(s.TTL).Truncate(time.Second)}
+
+	s.mu.Lock()
+	s.expire(time.Now())
+	s.grants[string(nonce)] = g
+	s.mu.Unlock()
+
+	token := base64.RawURLEncoding.EncodeToString(append(nonce, s.sign(nonce, g)...))
+	return s.BaseURL + Prefix + token + "/" + url.PathEscape(filepath.Base(path)), nil
+}
+
+// Pending returns the size of the files userID has links for but has not
+// downloaded yet, so a ratio check can count them.
+func (s *Store) Pending(userID int) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	var n int64
+	for _, g := range s.grants {
+		if g.UserID == userID {
+			n += g.Size
+		}
+	}
+	return n
+}
+
+// expire drops links past their time. The caller holds mu.
+func (s *Store) expire(now time.Time) {
+	for k, g := range s.grants {
+		if now.After(g.Expires) {
+			delete(s.grants, k)
+		}
+	}
+}
+
+// sign returns the signature binding nonce to the grant's entry and expiry.
+func (s *Store) sign(nonce []byte, g *Grant) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(nonce)
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(g.EntryID))
+	binary.BigEndian.PutUint64(b[8:], uint64(g.Expires.Unix()))
+	mac.Write(b[:])
+	return mac.Sum(nil)[:sigLen]
+}
+
+var errGone = errors.New("link expired or already used")
+
+// claim returns the grant token names for a request from remote, claiming
+// it for remote on first use.
+func (s *Store) claim(token, remote string) (*Grant, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != nonceLen+sigLen {
+		return nil, "", errGone
+	}
+	nonce, sig := raw[:nonceLen], raw[nonceLen:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.grants[string(nonce)]
+	if !ok || time.Now().After(g.Expires) || subtle.ConstantTimeCompare(sig, s.sign(nonce, g)) != 1 {
+		return nil, "", errGone
+	}
+	if g.claimed == "" {
+		g.claimed = remote
+	} else if g.claimed != remote {
+		return nil, "", errGone
+	}
+	return g, string(nonce), nil
+}
+
+// delivered adds n served bytes to the grant and reports whether that
+// completed it, in which case the link is spent.
+func (s *Store) delivered(key string, g *Grant, n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.grants[key]; !ok {
+		return false
+	}
+	g.served += n
+	if g.served < g.Size {
+		return false
+	}
+	delete(s.grants, key)
+	return true
+}
+
+// ServeHTTP serves Prefix + token + "/" + name, with range support.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, Prefix), "/")
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	g, key, err := s.claim(token, remote)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	f, err := os.Open(g.Path)
+	if err != nil {
+		http.Error(w, "file not available", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "file not available", http.StatusNotFound)
+		return
+	}
+
+	name := filepath.Base(g.Path)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, r, name, info.ModTime(), f)
+	if r.Method == http.MethodHead || cw.n == 0 {
+		return
+	}
+	if s.delivered(key, g, cw.n) {
+		log.Printf("Web download: %s by user %d from %s", name, g.UserID, remote)
+		if s.Complete != nil {
+			s.Complete(*g, remote)
+		}
+	}
+}
+
+// countingWriter counts the body bytes written.
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,96 @@
+package weblink
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkIsSingleUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GAME.ZIP")
+	if err := os.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New("http://bbs.example/", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var completed []Grant
+	s.Complete = func(g Grant, remote string) { completed = append(completed, g) }
+
+	link, err := s.Issue(7, 42, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(link, "http://bbs.example/dl/") || !strings.HasSuffix(link, "/GAME.ZIP") {
+		t.Fatalf("link = %q", link)
+	}
+	if n := s.Pending(7); n != 10 {
+		t.Fatalf("Pending = %d, want 10", n)
+	}
+	target := strings.TrimPrefix(link, "http://bbs.example")
+
+	get := func(remote, rng string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.RemoteAddr = remote + ":4000"
+		if rng != "" {
+			r.Header.Set("Range", rng)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	// The first half, then a resume for the rest.
+	if w := get("192.0.2.1", "bytes=0-4"); w.Code != http.StatusPartialContent || w.Body.String() != "01234" {
+		t.Fatalf("first range = %d %q", w.Code, w.Body.String())
+	}
+	if w := get("192.0.2.9", ""); w.Code != http.StatusGone {
+		t.Fatalf("other address got %d, want 410", w.Code)
+	}
+	if len(completed) != 0 {
+		t.Fatal("counted before the file was delivered")
+	}
+	if w := get("192.0.2.1", "bytes=5-"); w.Code != http.StatusPartialContent || w.Body.String() != "56789" {
+		t.Fatalf("resume = %d %q", w.Code, w.Body.String())
+	}
+	if len(completed) != 1 || completed[0].EntryID != 42 || completed[0].UserID != 7 {
+		t.Fatalf("completed = %+v", completed)
+	}
+	if w := get("192.0.2.1", ""); w.Code != http.StatusGone {
+		t.Fatalf("spent link got %d, want 410", w.Code)
+	}
+	if n := s.Pending(7); n != 0 {
+		t.Fatalf("Pending after download = %d", n)
+	}
+}
+
+func TestLinkRejectsForgeryAndExpiry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(path, []byte("x"), 0644)
+	s, _ := New("", time.Minute)
+
+	link, _ := s.Issue(1, 1, path)
+	token, _, _ := strings.Cut(strings.TrimPrefix(link, Prefix), "/")
+	forged := []byte(token)
+	forged[len(forged)-1] ^= 1
+	for _, tok := range []string{string(forged), "junk"} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, Prefix+tok+"/a.txt", nil))
+		if w.Code != http.StatusGone {
+			t.Errorf("token %q got %d, want 410", tok, w.Code)
+		}
+	}
+
+	s.TTL = -time.Second
+	expired, _ := s.Issue(1, 1, path)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expired, nil))
+	if w.Code != http.StatusGone {
+		t.Errorf("expired link got %d, want 410", w.Code)
+	}
+}
@@ -0,0 +1,163 @@
+// Package ansi loads BBS display files (.ans/.asc), indexes their {{ID}}
+// placeholder fields and renders them to a terminal.
+package ansi
+
+import (
+	"github.com/notepid/twilight_bbs/internal/ansi"
+	"github.com/notepid/twilight_bbs/pkg/internal/termbridge"
+	"github.com/notepid/twilight_bbs/pkg/sauce"
+	"github.com/notepid/twilight_bbs/pkg/terminal"
+)
+
+// DisplayFile is a loaded ANSI or ASCII display file. Data holds the
+// content without its SAUCE block, which is parsed into Sauce.
+type DisplayFile struct {
+	Name   string
+	Path   string
+	IsANSI bool
+	Data   []byte
+	Sauce  *sauce.Record
+}
+
+func wrapFile(df *ansi.DisplayFile) *DisplayFile {
+	return &DisplayFile{
+		Name:   df.Name,
+		Path:   df.Path,
+		IsANSI: df.IsANSI,
+		Data:   df.Data,
+		Sauce:  (*sauce.Record)(df.Sauce),
+	}
+}
+
+func (df *DisplayFile) unwrap() *ansi.DisplayFile {
+	return &ansi.DisplayFile{
+		Name:   df.Name,
+		Path:   df.Path,
+		IsANSI: df.IsANSI,
+		Data:   df.Data,
+		Sauce:  (*ansi.SAUCE)(df.Sauce),
+	}
+}
+
+// Loader finds display files by name across a list of directories.
+type Loader struct {
+	l *ansi.Loader
+}
+
+// NewLoader creates a loader that searches the given directories in order.
+func NewLoader(dirs ...string) *Loader {
+	return &Loader{l: ansi.NewLoader(dirs...)}
+}
+
+// Find locates a display file by name (without extension), preferring
+// .ans over .asc. With ansiEnabled false only .asc files are considered.
+func (l *Loader) Find(name string, ansiEnabled bool) (*DisplayFile, error) {
+	df, err := l.l.Find(name, ansiEnabled)
+	if err != nil {
+		return nil, err
+	}
+	return wrapFile(df), nil
+}
+
+// Load reads a display file by path. Files ending in .ans are treated as
+// ANSI; anything else as plain ASCII.
+func (l *Loader) Load(path string) (*DisplayFile, error) {
+	df, err := l.l.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return wrapFile(df), nil
+}
+
+// Field is a {{ID}} placeholder location within a display file.
+type Field struct {
+	ID     string
+	Row    int
+	Col    int
+	MaxLen int
+	Height int
+}
+
+// LoadFile reads a display file by path. Files ending in .ans are treated
+// as ANSI; anything else as plain ASCII.
+func LoadFile(path string) (*DisplayFile, error) {
+	return NewLoader().Load(path)
+}
+
+// BlankPlaceholders replaces {{ID}} placeholders with spaces of equal width.
+func BlankPlaceholders(data []byte) []byte {
+	return ansi.BlankPlaceholders(data)
+}
+
+// IndexFields locates the placeholders in a display file as they would
+// appear on a terminal termWidth columns wide.
+func IndexFields(df *DisplayFile, termWidth int) map[string]Field {
+	fields := make(map[string]Field)
+	for id, f := range ansi.IndexFields(df.unwrap(), termWidth) {
+		fields[id] = Field(f)
+	}
+	return fields
+}
+
+// SplitPages splits display data into pages of at most pageHeight lines,
+// carrying colors across page breaks.
+func SplitPages(df *DisplayFile, pageHeight int) [][]byte {
+	return ansi.SplitPages(df.unwrap(), pageHeight)
+}
+
+// Display streams a display file to a terminal.
+func Display(term *terminal.Terminal, df *DisplayFile) error {
+	return ansi.Display(termbridge.Unwrap(term), df.unwrap())
+}
+
+// DisplayPaged shows a display file one screen at a time when it is taller
+// than the terminal.
+func DisplayPaged(term *terminal.Terminal, df *DisplayFile) error {
+	return ansi.DisplayPaged(termbridge.Unwrap(term), df.unwrap())
+}
+
+// Severity is how serious a LintIssue is.
+type Severity int
+
+// Lint severities.
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string { return ansi.Severity(s).String() }
+
+// LintIssue is one problem found by LintFile or LintDir.
+type LintIssue struct {
+	Path     string
+	Line     int // 1-based, 0 when the issue concerns the whole file
+	Severity Severity
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	return ansi.LintIssue{Path: i.Path, Line: i.Line, Severity: ansi.Severity(i.Severity), Message: i.Message}.String()
+}
+
+func wrapIssues(issues []ansi.LintIssue, err error) ([]LintIssue, error) {
+	if issues == nil {
+		return nil, err
+	}
+	out := make([]LintIssue, len(issues))
+	for i, is := range issues {
+		out[i] = LintIssue{Path: is.Path, Line: is.Line, Severity: Severity(is.Severity), Message: is.Message}
+	}
+	return out, err
+}
+
+// LintFile checks a display file for SAUCE inconsistencies, width
+// overflows, unsupported escape sequences and broken placeholders.
+func LintFile(path string) ([]LintIssue, error) {
+	return wrapIssues(ansi.LintFile(path))
+}
+
+// LintDir runs LintFile on every display file below dir and reports .ans
+// files without an .asc fallback.
+func LintDir(dir string) ([]LintIssue, error) {
+	return wrapIssues(ansi.LintDir(dir))
+}
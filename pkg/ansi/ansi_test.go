@@ -0,0 +1,45 @@
+package ansi
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	rec := make([]byte, 128)
+	copy(rec, "SAUCE00")
+	rec[94] = 1
+	rec[95] = 1
+	binary.LittleEndian.PutUint16(rec[96:], 40)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "menu.ans")
+	data := append([]byte("Name: {{NAME,20}}\r\n\x1a"), rec...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	df, err := NewLoader(dir).Find("menu", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !df.IsANSI || df.Sauce == nil || df.Sauce.Width() != 40 {
+		t.Fatalf("unexpected file %+v", df)
+	}
+	f, ok := IndexFields(df, 80)["NAME"]
+	if !ok || f.Row != 1 || f.Col != 7 {
+		t.Errorf("NAME field = %+v, %v", f, ok)
+	}
+
+	issues, err := LintFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, is := range issues {
+		if is.Severity == SeverityError {
+			t.Errorf("unexpected issue %s", is)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// Package pkg is the root of Twilight BBS's supported public API.
+//
+// Everything under internal/ may change between releases. The packages
+// below pkg/ are the stable surface for third-party tools (art validators,
+// door wrappers, converters) and follow semantic versioning:
+//
+//   - pkg/sauce: SAUCE metadata parsing
+//   - pkg/ansi: display file loading, placeholder fields and paging
+//   - pkg/terminal: the BBS terminal and ANSI escape helpers
+//
+// They are thin facades over the implementation in internal/, so behavior
+// is identical to what the BBS itself uses.
+package pkg
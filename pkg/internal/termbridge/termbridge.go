@@ -0,0 +1,9 @@
+// Package termbridge lets the pkg/ facades reach the BBS terminal behind a
+// pkg/terminal.Terminal without making it part of the public API.
+package termbridge
+
+import "github.com/notepid/twilight_bbs/internal/terminal"
+
+// Unwrap returns the terminal behind a *pkg/terminal.Terminal. It is set
+// by pkg/terminal, which every package taking its Terminal imports.
+var Unwrap func(t any) *terminal.Terminal
@@ -0,0 +1,52 @@
+// Package sauce parses SAUCE (Standard Architecture for Universal Comment
+// Extensions) records appended to ANSI and ASCII art files.
+package sauce
+
+import "github.com/notepid/twilight_bbs/internal/ansi"
+
+// Record is a parsed SAUCE record. Width, Height, HasICEColors,
+// LetterSpacing and AspectRatio interpret its fields.
+type Record struct {
+	Version      string
+	Title        string
+	Author       string
+	Group        string
+	Date         string
+	FileSize     uint32
+	DataType     byte
+	FileType     byte
+	TInfo1       uint16 // Width (for ANSI/ASCII)
+	TInfo2       uint16 // Height (for ANSI/ASCII)
+	TInfo3       uint16
+	TInfo4       uint16
+	Comments     byte
+	Flags        byte
+	TInfoS       string // SAUCE 00.5 font name
+	CommentLines []string
+}
+
+// Width returns the display width, or 80 if the record does not set one.
+func (r *Record) Width() int { return (*ansi.SAUCE)(r).Width() }
+
+// Height returns the display height, or 0 if unknown.
+func (r *Record) Height() int { return (*ansi.SAUCE)(r).Height() }
+
+// HasICEColors reports whether the file uses iCE colors (blink bit =
+// bright background).
+func (r *Record) HasICEColors() bool { return (*ansi.SAUCE)(r).HasICEColors() }
+
+// LetterSpacing returns the letter spacing mode: 0 = legacy, 1 = 8px,
+// 2 = 9px.
+func (r *Record) LetterSpacing() int { return (*ansi.SAUCE)(r).LetterSpacing() }
+
+// AspectRatio returns the aspect ratio mode: 0 = legacy, 1 = stretch,
+// 2 = square.
+func (r *Record) AspectRatio() int { return (*ansi.SAUCE)(r).AspectRatio() }
+
+// Parse extracts a SAUCE record from the end of file data. It returns the
+// record and the data without the SAUCE/comment block, or nil and the
+// original data if no record is present.
+func Parse(data []byte) (*Record, []byte) {
+	s, content := ansi.ParseSAUCE(data)
+	return (*Record)(s), content
+}
@@ -0,0 +1,33 @@
+package sauce
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	rec := make([]byte, 128)
+	copy(rec, "SAUCE00")
+	copy(rec[7:], "Title")
+	rec[94] = 1 // character
+	rec[95] = 1 // ANSi
+	binary.LittleEndian.PutUint16(rec[96:], 132)
+	binary.LittleEndian.PutUint16(rec[98:], 50)
+	rec[105] = 0x01
+
+	data := append([]byte("\x1b[31mhi\x1a"), rec...)
+	r, content := Parse(data)
+	if r == nil {
+		t.Fatal("expected a SAUCE record")
+	}
+	if string(content) != "\x1b[31mhi" {
+		t.Errorf("unexpected content %q", content)
+	}
+	if r.Title != "Title" || r.Width() != 132 || r.Height() != 50 || !r.HasICEColors() {
+		t.Errorf("unexpected record %+v", r)
+	}
+
+	if r, content := Parse([]byte("plain")); r != nil || string(content) != "plain" {
+		t.Errorf("expected no record for plain data")
+	}
+}
@@ -0,0 +1,189 @@
+// Package terminal provides the BBS terminal abstraction (CRLF handling,
+// line input, hotkeys, pause prompts) and ANSI escape helpers.
+package terminal
+
+import (
+	"io"
+
+	"github.com/notepid/twilight_bbs/internal/terminal"
+	"github.com/notepid/twilight_bbs/pkg/internal/termbridge"
+)
+
+func init() {
+	termbridge.Unwrap = func(t any) *terminal.Terminal { return t.(*Terminal).t }
+}
+
+// Terminal wraps a connection with BBS-oriented I/O methods. It offers
+// the methods covered by the compatibility promise; the BBS's own
+// terminal behind it has more, which may change.
+type Terminal struct {
+	t *terminal.Terminal
+}
+
+// New creates a Terminal over rwc with the given screen size.
+func New(rwc io.ReadWriteCloser, width, height int, ansiEnabled bool) *Terminal {
+	return &Terminal{t: terminal.New(rwc, width, height, ansiEnabled)}
+}
+
+// ANSI reports whether the terminal takes ANSI escape sequences.
+func (t *Terminal) ANSI() bool { return t.t.ANSIEnabled }
+
+// Size returns the terminal's window size.
+func (t *Terminal) Size() (width, height int) { return t.t.Size() }
+
+// Resize records a new window size, as reported by the connection.
+func (t *Terminal) Resize(width, height int) { t.t.Resize(width, height) }
+
+// Write implements io.Writer, sending p unchanged.
+func (t *Terminal) Write(p []byte) (int, error) { return t.t.Write(p) }
+
+// Send writes raw text to the terminal.
+func (t *Terminal) Send(data string) error { return t.t.Send(data) }
+
+// SendLn writes a line of text followed by CR+LF.
+func (t *Terminal) SendLn(text string) error { return t.t.SendLn(text) }
+
+// Cls clears the screen.
+func (t *Terminal) Cls() error { return t.t.Cls() }
+
+// GotoXY positions the cursor (1-based row, col).
+func (t *Terminal) GotoXY(row, col int) error { return t.t.GotoXY(row, col) }
+
+// SetColor sets the text color using ANSI SGR codes (see Color).
+func (t *Terminal) SetColor(fg, bg int) error { return t.t.SetColor(fg, bg) }
+
+// ResetColor resets to default colors.
+func (t *Terminal) ResetColor() error { return t.t.ResetColor() }
+
+// GetKey waits for and returns a single keypress.
+func (t *Terminal) GetKey() (byte, error) { return t.t.GetKey() }
+
+// GetLine reads a line of input up to maxLen characters, with echo.
+func (t *Terminal) GetLine(maxLen int) (string, error) { return t.t.GetLine(maxLen) }
+
+// GetPassword reads a line of input without echo, displaying asterisks.
+func (t *Terminal) GetPassword(maxLen int) (string, error) { return t.t.GetPassword(maxLen) }
+
+// Pause displays "Press any key to continue..." and waits for a keypress.
+func (t *Terminal) Pause() error { return t.t.Pause() }
+
+// YesNo displays a prompt and waits for Y or N.
+func (t *Terminal) YesNo(prompt string) (bool, error) { return t.t.YesNo(prompt) }
+
+// Hotkey displays a prompt and waits for a single keypress, returning it.
+func (t *Terminal) Hotkey(prompt string) (byte, error) { return t.t.Hotkey(prompt) }
+
+// Ask displays a prompt and reads a line of input.
+func (t *Terminal) Ask(prompt string, maxLen int) (string, error) { return t.t.Ask(prompt, maxLen) }
+
+// Close closes the connection.
+func (t *Terminal) Close() error { return t.t.Close() }
+
+// ANSI attribute and color sequences.
+const (
+	Reset      = terminal.Reset
+	Bold       = terminal.Bold
+	Dim        = terminal.Dim
+	Underscore = terminal.Underscore
+	Blink      = terminal.Blink
+	Reverse    = terminal.Reverse
+	Hidden     = terminal.Hidden
+
+	FgBlack   = terminal.FgBlack
+	FgRed     = terminal.FgRed
+	FgGreen   = terminal.FgGreen
+	FgBrown   = terminal.FgBrown
+	FgBlue    = terminal.FgBlue
+	FgMagenta = terminal.FgMagenta
+	FgCyan    = terminal.FgCyan
+	FgGray    = terminal.FgGray
+
+	BgBlack   = terminal.BgBlack
+	BgRed     = terminal.BgRed
+	BgGreen   = terminal.BgGreen
+	BgBrown   = terminal.BgBrown
+	BgBlue    = terminal.BgBlue
+	BgMagenta = terminal.BgMagenta
+	BgCyan    = terminal.BgCyan
+	BgGray    = terminal.BgGray
+
+	FgDarkGray      = terminal.FgDarkGray
+	FgBrightRed     = terminal.FgBrightRed
+	FgBrightGreen   = terminal.FgBrightGreen
+	FgYellow        = terminal.FgYellow
+	FgBrightBlue    = terminal.FgBrightBlue
+	FgBrightMagenta = terminal.FgBrightMagenta
+	FgBrightCyan    = terminal.FgBrightCyan
+	FgWhite         = terminal.FgWhite
+)
+
+// ClearScreen sends the ANSI clear-screen sequence and homes the cursor.
+func ClearScreen() string {
+	return terminal.ClearScreen()
+}
+
+// MoveTo returns an ANSI cursor positioning sequence.
+func MoveTo(row, col int) string {
+	return terminal.MoveTo(row, col)
+}
+
+// CursorUp returns an ANSI cursor-up sequence.
+func CursorUp(n int) string {
+	return terminal.CursorUp(n)
+}
+
+// CursorDown returns an ANSI cursor-down sequence.
+func CursorDown(n int) string {
+	return terminal.CursorDown(n)
+}
+
+// CursorRight returns an ANSI cursor-right sequence.
+func CursorRight(n int) string {
+	return terminal.CursorRight(n)
+}
+
+// CursorLeft returns an ANSI cursor-left sequence.
+func CursorLeft(n int) string {
+	return terminal.CursorLeft(n)
+}
+
+// Color returns an ANSI SGR sequence for the given foreground and background.
+// fg: 30-37, bg: 40-47. Pass -1 to leave unchanged.
+func Color(fg, bg int) string {
+	return terminal.Color(fg, bg)
+}
+
+// SaveCursor returns the ANSI save-cursor-position sequence.
+func SaveCursor() string {
+	return terminal.SaveCursor()
+}
+
+// RestoreCursor returns the ANSI restore-cursor-position sequence.
+func RestoreCursor() string {
+	return terminal.RestoreCursor()
+}
+
+// HideCursor returns the ANSI hide-cursor sequence.
+func HideCursor() string {
+	return terminal.HideCursor()
+}
+
+// ShowCursor returns the ANSI show-cursor sequence.
+func ShowCursor() string {
+	return terminal.ShowCursor()
+}
+
+// ClearLine clears the current line.
+func ClearLine() string {
+	return terminal.ClearLine()
+}
+
+// ClearToEOL clears from cursor to end of line.
+func ClearToEOL() string {
+	return terminal.ClearToEOL()
+}
+
+// ResetTerminal sends the full terminal reset sequence (ESC c).
+func ResetTerminal() string {
+	return terminal.ResetTerminal()
+}
@@ -0,0 +1,40 @@
+package terminal_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/notepid/twilight_bbs/pkg/ansi"
+	"github.com/notepid/twilight_bbs/pkg/terminal"
+)
+
+func TestTerminal(t *testing.T) {
+	server, client := net.Pipe()
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&out, client)
+		close(done)
+	}()
+	go client.Write([]byte("y"))
+
+	term := terminal.New(server, 80, 24, true)
+	if w, h := term.Size(); w != 80 || h != 24 || !term.ANSI() {
+		t.Fatalf("size %dx%d, ansi %v", w, h, term.ANSI())
+	}
+	if yes, err := term.YesNo("Continue? "); err != nil || !yes {
+		t.Fatalf("YesNo = %v, %v", yes, err)
+	}
+	if err := ansi.Display(term, &ansi.DisplayFile{Data: []byte("Hello\r\n")}); err != nil {
+		t.Fatal(err)
+	}
+	term.Close()
+	<-done
+	for _, want := range []string{"Continue? ", "Hello"} {
+		if !bytes.Contains(out.Bytes(), []byte(want)) {
+			t.Errorf("output %q lacks %q", out.String(), want)
+		}
+	}
+}